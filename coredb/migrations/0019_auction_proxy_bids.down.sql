@@ -0,0 +1,7 @@
+-- 0019_auction_proxy_bids.down.sql
+-- Rollback: Remove proxy bidding
+
+ALTER TABLE bids DROP COLUMN IF EXISTS is_auto;
+
+DROP TABLE IF EXISTS auction_proxy_bids;
+DROP TYPE IF EXISTS proxy_bid_status;
@@ -0,0 +1,25 @@
+-- 0019_auction_proxy_bids.up.sql
+-- Proxy (automatic max) bidding: per-user ceilings and auto-bid marker on bids
+
+CREATE TYPE proxy_bid_status AS ENUM ('active','exhausted','cancelled');
+
+CREATE TABLE auction_proxy_bids (
+    id BIGSERIAL PRIMARY KEY,
+    auction_id BIGINT NOT NULL REFERENCES auctions(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    max_amount NUMERIC(12,2) NOT NULL CHECK (max_amount > 0),
+    status proxy_bid_status NOT NULL DEFAULT 'active',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_auction_proxy_bids_auction_user UNIQUE (auction_id, user_id)
+);
+CREATE INDEX idx_auction_proxy_bids_active ON auction_proxy_bids(auction_id, max_amount DESC) WHERE status = 'active';
+CREATE INDEX idx_auction_proxy_bids_user_id ON auction_proxy_bids(user_id);
+
+CREATE TRIGGER update_auction_proxy_bids_updated_at BEFORE UPDATE ON auction_proxy_bids FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Bids placed by the platform on behalf of a proxy ceiling
+ALTER TABLE bids ADD COLUMN is_auto BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON TABLE auction_proxy_bids IS 'الحد الأقصى للمزايدة التلقائية لكل مستخدم في المزاد';
+COMMENT ON COLUMN bids.is_auto IS 'مزايدة تلقائية نيابة عن المستخدم ضمن حده الأقصى';
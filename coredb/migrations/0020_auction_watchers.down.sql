@@ -0,0 +1,4 @@
+-- 0020_auction_watchers.down.sql
+-- Rollback: Remove auction watchlist
+
+DROP TABLE IF EXISTS auction_watchers;
@@ -0,0 +1,17 @@
+-- 0020_auction_watchers.up.sql
+-- Auction watchlist: users following an auction and reminder bookkeeping
+
+CREATE TABLE auction_watchers (
+    id BIGSERIAL PRIMARY KEY,
+    auction_id BIGINT NOT NULL REFERENCES auctions(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    starting_soon_notified_at TIMESTAMPTZ,
+    ending_soon_notified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_auction_watchers_auction_user UNIQUE (auction_id, user_id)
+);
+CREATE INDEX idx_auction_watchers_user_id ON auction_watchers(user_id, created_at DESC);
+
+COMMENT ON TABLE auction_watchers IS 'المستخدمون المتابعون للمزادات (قائمة المتابعة)';
+COMMENT ON COLUMN auction_watchers.starting_soon_notified_at IS 'وقت إرسال تذكير قرب بدء المزاد';
+COMMENT ON COLUMN auction_watchers.ending_soon_notified_at IS 'وقت إرسال تذكير قرب انتهاء المزاد';
@@ -0,0 +1,5 @@
+-- 0021_auction_event_log.down.sql
+-- Rollback: Remove auction event replay log
+
+DROP TABLE IF EXISTS auction_event_log;
+DROP TABLE IF EXISTS auction_event_sequences;
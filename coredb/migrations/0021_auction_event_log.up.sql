@@ -0,0 +1,20 @@
+-- 0021_auction_event_log.up.sql
+-- Sequenced per-auction realtime events kept for SSE Last-Event-ID / WS resume replay
+
+CREATE TABLE auction_event_sequences (
+    auction_id BIGINT PRIMARY KEY REFERENCES auctions(id) ON DELETE CASCADE,
+    last_seq BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE auction_event_log (
+    auction_id BIGINT NOT NULL REFERENCES auctions(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL,
+    user_ids BIGINT[],
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (auction_id, seq)
+);
+
+COMMENT ON TABLE auction_event_log IS 'سجل محدود لأحداث المزاد الفورية لإعادة إرسال ما فات العميل عند إعادة الاتصال';
+COMMENT ON COLUMN auction_event_log.user_ids IS 'المستخدمون المستهدفون بالحدث (NULL = جميع المتابعين)';
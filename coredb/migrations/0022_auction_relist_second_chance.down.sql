@@ -0,0 +1,10 @@
+-- 0022_auction_relist_second_chance.down.sql
+-- Rollback: Remove auto-relist and second-chance offers
+
+DROP TABLE IF EXISTS second_chance_offers;
+DROP INDEX IF EXISTS uq_auctions_relisted_from;
+ALTER TABLE auctions DROP COLUMN IF EXISTS relist_count;
+ALTER TABLE auctions DROP COLUMN IF EXISTS relisted_from_auction_id;
+DROP TABLE IF EXISTS auction_failure_policies;
+DROP TYPE IF EXISTS second_chance_offer_status;
+DROP TYPE IF EXISTS auction_failure_action;
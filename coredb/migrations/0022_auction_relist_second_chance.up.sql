@@ -0,0 +1,52 @@
+-- 0022_auction_relist_second_chance.up.sql
+-- Per-auction policies for failed auctions (reserve not met / winner unpaid):
+-- scheduled auto-relist or second-chance offers to runner-up bidders
+
+CREATE TYPE auction_failure_action AS ENUM ('none','relist','second_chance');
+CREATE TYPE second_chance_offer_status AS ENUM ('pending','accepted','declined','expired');
+
+CREATE TABLE auction_failure_policies (
+    auction_id BIGINT PRIMARY KEY REFERENCES auctions(id) ON DELETE CASCADE,
+    action auction_failure_action NOT NULL DEFAULT 'none',
+    -- Relist schedule and pricing
+    relist_delay_minutes INT NOT NULL DEFAULT 60 CHECK (relist_delay_minutes >= 0),
+    relist_duration_minutes INT NOT NULL DEFAULT 1440 CHECK (relist_duration_minutes > 0),
+    relist_start_price NUMERIC(12,2) CHECK (relist_start_price IS NULL OR relist_start_price > 0),
+    relist_reserve_price NUMERIC(12,2) CHECK (relist_reserve_price IS NULL OR relist_reserve_price > 0),
+    relist_clear_reserve BOOLEAN NOT NULL DEFAULT FALSE,
+    max_relists INT NOT NULL DEFAULT 1 CHECK (max_relists >= 0),
+    -- Second-chance acceptance window
+    offer_window_hours INT NOT NULL DEFAULT 24 CHECK (offer_window_hours > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE TRIGGER update_auction_failure_policies_updated_at BEFORE UPDATE ON auction_failure_policies FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Relist lineage
+ALTER TABLE auctions ADD COLUMN relisted_from_auction_id BIGINT REFERENCES auctions(id) ON DELETE SET NULL;
+ALTER TABLE auctions ADD COLUMN relist_count INT NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX uq_auctions_relisted_from ON auctions(relisted_from_auction_id) WHERE relisted_from_auction_id IS NOT NULL;
+
+CREATE TABLE second_chance_offers (
+    id BIGSERIAL PRIMARY KEY,
+    auction_id BIGINT NOT NULL REFERENCES auctions(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount NUMERIC(12,2) NOT NULL CHECK (amount > 0),
+    status second_chance_offer_status NOT NULL DEFAULT 'pending',
+    expires_at TIMESTAMPTZ NOT NULL,
+    responded_at TIMESTAMPTZ,
+    order_id BIGINT REFERENCES orders(id) ON DELETE SET NULL,
+    invoice_id BIGINT REFERENCES invoices(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_second_chance_offers_auction_user UNIQUE (auction_id, user_id)
+);
+-- At most one open offer per auction
+CREATE UNIQUE INDEX uq_second_chance_offers_pending ON second_chance_offers(auction_id) WHERE status = 'pending';
+CREATE INDEX idx_second_chance_offers_user_id ON second_chance_offers(user_id, created_at DESC);
+CREATE INDEX idx_second_chance_offers_expiry ON second_chance_offers(expires_at) WHERE status = 'pending';
+CREATE TRIGGER update_second_chance_offers_updated_at BEFORE UPDATE ON second_chance_offers FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE auction_failure_policies IS 'سياسة المزاد عند الفشل: إعادة الطرح تلقائياً أو عرض فرصة ثانية';
+COMMENT ON TABLE second_chance_offers IS 'عروض الفرصة الثانية للمزايدين التاليين بمبلغ آخر مزايدة لهم';
+COMMENT ON COLUMN auctions.relisted_from_auction_id IS 'المزاد الأصلي الذي أعيد طرح هذا المزاد منه';
@@ -0,0 +1,6 @@
+-- 0023_payment_reconciliation.down.sql
+-- Rollback: Remove payment reconciliation reports
+
+DROP TABLE IF EXISTS payment_discrepancies;
+DROP TABLE IF EXISTS payment_reconciliation_reports;
+DROP TYPE IF EXISTS payment_discrepancy_kind;
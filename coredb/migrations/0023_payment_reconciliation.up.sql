@@ -0,0 +1,42 @@
+-- 0023_payment_reconciliation.up.sql
+-- Daily payment reconciliation reports: gateway state vs. recorded payments
+
+CREATE TYPE payment_discrepancy_kind AS ENUM ('amount_mismatch','refund_mismatch','status_mismatch','orphaned_gateway_payment','missing_at_gateway');
+
+CREATE TABLE payment_reconciliation_reports (
+    id BIGSERIAL PRIMARY KEY,
+    report_date DATE NOT NULL UNIQUE,
+    window_start TIMESTAMPTZ NOT NULL,
+    window_end TIMESTAMPTZ NOT NULL,
+    payments_checked INT NOT NULL DEFAULT 0,
+    fetch_errors INT NOT NULL DEFAULT 0,
+    discrepancies_count INT NOT NULL DEFAULT 0,
+    recorded_captured NUMERIC(12,2) NOT NULL DEFAULT 0.00,
+    gateway_captured NUMERIC(12,2) NOT NULL DEFAULT 0.00,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_payment_reconciliation_reports_date ON payment_reconciliation_reports(report_date DESC);
+CREATE TRIGGER update_payment_reconciliation_reports_updated_at BEFORE UPDATE ON payment_reconciliation_reports FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE payment_discrepancies (
+    id BIGSERIAL PRIMARY KEY,
+    report_id BIGINT NOT NULL REFERENCES payment_reconciliation_reports(id) ON DELETE CASCADE,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    invoice_id BIGINT NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    kind payment_discrepancy_kind NOT NULL,
+    gateway TEXT NOT NULL,
+    gateway_ref TEXT NOT NULL,
+    recorded_status TEXT NOT NULL,
+    gateway_status TEXT NOT NULL DEFAULT '',
+    recorded_captured NUMERIC(12,2) NOT NULL DEFAULT 0.00,
+    gateway_captured NUMERIC(12,2) NOT NULL DEFAULT 0.00,
+    recorded_refunded NUMERIC(12,2) NOT NULL DEFAULT 0.00,
+    gateway_refunded NUMERIC(12,2) NOT NULL DEFAULT 0.00,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_payment_discrepancies_report_payment_kind UNIQUE (report_id, payment_id, kind)
+);
+CREATE INDEX idx_payment_discrepancies_payment_id ON payment_discrepancies(payment_id);
+
+COMMENT ON TABLE payment_reconciliation_reports IS 'تقرير المطابقة اليومي بين بوابة الدفع وسجلات المدفوعات';
+COMMENT ON TABLE payment_discrepancies IS 'الفروقات المكتشفة: مبالغ محصلة/مستردة غير مطابقة أو مدفوعات يتيمة في البوابة';
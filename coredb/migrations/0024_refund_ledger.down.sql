@@ -0,0 +1,6 @@
+-- 0024_refund_ledger.down.sql
+-- Rollback: Remove refund ledger
+
+DROP TABLE IF EXISTS refund_items;
+DROP TABLE IF EXISTS refunds;
+DROP TYPE IF EXISTS refund_status;
@@ -0,0 +1,43 @@
+-- 0024_refund_ledger.up.sql
+-- Per-refund ledger with item-level breakdown and customer credit notes
+
+CREATE TYPE refund_status AS ENUM ('pending','succeeded','failed');
+
+CREATE TABLE refunds (
+    id BIGSERIAL PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    invoice_id BIGINT NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    amount NUMERIC(12,2) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL DEFAULT 'SAR',
+    reason TEXT NOT NULL DEFAULT '',
+    actor_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    gateway TEXT NOT NULL,
+    gateway_refund_id TEXT,
+    status refund_status NOT NULL DEFAULT 'pending',
+    failure_reason TEXT,
+    restock BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Credit note issued against the original invoices.number (succeeded refunds only)
+    credit_note_number TEXT UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_refunds_payment_id ON refunds(payment_id, created_at DESC);
+CREATE INDEX idx_refunds_invoice_id ON refunds(invoice_id, created_at DESC);
+CREATE INDEX idx_refunds_status ON refunds(status);
+CREATE TRIGGER update_refunds_updated_at BEFORE UPDATE ON refunds FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE refund_items (
+    id BIGSERIAL PRIMARY KEY,
+    refund_id BIGINT NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+    order_item_id BIGINT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    qty INTEGER NOT NULL CHECK (qty > 0),
+    amount NUMERIC(12,2) NOT NULL CHECK (amount >= 0),
+    restocked BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_refund_items_refund_order_item UNIQUE (refund_id, order_item_id)
+);
+CREATE INDEX idx_refund_items_order_item_id ON refund_items(order_item_id);
+
+COMMENT ON TABLE refunds IS 'سجل عمليات الاسترداد: المبلغ والسبب ومنفذ العملية ومرجع البوابة وإشعار الدائن';
+COMMENT ON TABLE refund_items IS 'تفصيل الاسترداد على مستوى عناصر الطلب والكميات';
+COMMENT ON COLUMN refunds.credit_note_number IS 'رقم إشعار الدائن المرتبط برقم الفاتورة الأصلية';
@@ -0,0 +1,5 @@
+-- 0025_outbox.down.sql
+-- Rollback: Remove transactional outbox
+
+DROP INDEX IF EXISTS idx_notifications_outbox_key;
+DROP TABLE IF EXISTS outbox_events;
@@ -0,0 +1,31 @@
+-- 0025_outbox.up.sql
+-- Transactional outbox: notifications and Pub/Sub events written in the same transaction
+-- as the business change and delivered at least once by a dispatcher
+
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    -- Stable key of the logical event; inserting the same key twice is a no-op
+    dedupe_key TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL CHECK (kind IN ('internal','email','pubsub')),
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    -- Template ID for notifications, topic name for Pub/Sub events
+    target TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','processing','delivered','failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_outbox_events_due ON outbox_events(kind, next_attempt_at) WHERE status IN ('pending','processing');
+CREATE INDEX idx_outbox_events_status ON outbox_events(status, created_at DESC);
+CREATE TRIGGER update_outbox_events_updated_at BEFORE UPDATE ON outbox_events FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Lets the dispatcher detect a notification already enqueued for an outbox row (crash between enqueue and ack)
+CREATE INDEX idx_notifications_outbox_key ON notifications((payload->>'outbox_key')) WHERE payload ? 'outbox_key';
+
+COMMENT ON TABLE outbox_events IS 'صندوق الصادر للمعاملات: إشعارات وأحداث تُكتب مع المعاملة وتُسلَّم لاحقاً مرة واحدة على الأقل';
+COMMENT ON COLUMN outbox_events.dedupe_key IS 'مفتاح منع التكرار للحدث المنطقي';
@@ -0,0 +1,5 @@
+-- 0026_notification_preferences.down.sql
+-- Rollback: Remove notification preferences and digest queue
+
+DROP TABLE IF EXISTS notification_digest_items;
+DROP TABLE IF EXISTS notification_preferences;
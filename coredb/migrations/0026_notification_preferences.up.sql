@@ -0,0 +1,31 @@
+-- 0026_notification_preferences.up.sql
+-- Per-user, per-category notification preferences with an optional daily email digest
+
+CREATE TABLE notification_preferences (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Category ID from pkg/templates (bidding, watchlist, admin, other, ...)
+    category TEXT NOT NULL,
+    internal_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    sms_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    -- 'daily' holds emails of this category and sends them as one digest
+    digest TEXT NOT NULL DEFAULT 'off' CHECK (digest IN ('off','daily')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, category)
+);
+CREATE TRIGGER update_notification_preferences_updated_at BEFORE UPDATE ON notification_preferences FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE notification_digest_items (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category TEXT NOT NULL,
+    template_id TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    digested_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_notification_digest_items_pending ON notification_digest_items(user_id, created_at) WHERE digested_at IS NULL;
+
+COMMENT ON TABLE notification_preferences IS 'تفضيلات الإشعارات لكل مستخدم وفئة وقناة';
+COMMENT ON TABLE notification_digest_items IS 'رسائل بريد مؤجلة تُرسل ضمن الملخص اليومي';
@@ -0,0 +1,7 @@
+-- 0027_seller_tax_profile.down.sql
+-- Rollback: Remove seller tax profile settings
+
+DELETE FROM system_settings WHERE key IN (
+    'company.legal_name_ar', 'company.legal_name_en', 'company.vat_number', 'company.cr_number',
+    'company.address_street', 'company.address_city', 'company.postal_code'
+);
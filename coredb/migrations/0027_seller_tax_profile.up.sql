@@ -0,0 +1,13 @@
+-- 0027_seller_tax_profile.up.sql
+-- Seller details printed on ZATCA tax invoices (PDF/XML). The VAT number must be set
+-- before invoice documents can be downloaded.
+
+INSERT INTO system_settings (key, value, description, allowed_values) VALUES
+('company.legal_name_ar', 'لوفت الدغيري', 'الاسم النظامي للبائع بالعربية (يظهر في الفاتورة الضريبية)', NULL),
+('company.legal_name_en', 'Loft Dughairi', 'الاسم النظامي للبائع بالإنجليزية', NULL),
+('company.vat_number', '', 'الرقم الضريبي للبائع (15 رقماً يبدأ وينتهي بالرقم 3)', NULL),
+('company.cr_number', '', 'رقم السجل التجاري', NULL),
+('company.address_street', '', 'عنوان البائع: الشارع والحي', NULL),
+('company.address_city', '', 'عنوان البائع: المدينة', NULL),
+('company.postal_code', '', 'عنوان البائع: الرمز البريدي', NULL)
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,13 @@
+-- 0028_stock_reservations.down.sql
+-- Rollback: Remove checkout stock reservations
+-- Note: the 'reserved' product_status value stays (PostgreSQL cannot drop enum values);
+-- reserved pigeons are returned to 'available' first.
+
+UPDATE products SET status='available' WHERE status::text='reserved';
+
+DELETE FROM system_settings WHERE key IN (
+    'stock.checkout_hold_minutes', 'stock.supplies_hold_minutes', 'stock.max_active_holds_per_user'
+);
+
+DROP TABLE IF EXISTS stock_reservations;
+DROP TYPE IF EXISTS stock_reservation_status;
@@ -0,0 +1,40 @@
+-- 0028_stock_reservations.up.sql
+-- Checkout holds: pigeons move to 'reserved' and supply stock is decremented when checkout
+-- starts. A hold is consumed by the paid webhook or released (stock/status restored) by the
+-- payment session cleaner once it expires.
+
+ALTER TYPE product_status ADD VALUE IF NOT EXISTS 'reserved';
+
+CREATE TYPE stock_reservation_status AS ENUM ('active','consumed','released');
+
+CREATE TABLE stock_reservations (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    invoice_id BIGINT REFERENCES invoices(id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    qty INTEGER NOT NULL CHECK (qty > 0),
+    status stock_reservation_status NOT NULL DEFAULT 'active',
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    released_at TIMESTAMPTZ,
+    release_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_stock_reservations_order_product UNIQUE (order_id, product_id)
+);
+CREATE INDEX idx_stock_reservations_active_expiry ON stock_reservations(expires_at) WHERE status='active';
+CREATE INDEX idx_stock_reservations_user_active ON stock_reservations(user_id) WHERE status='active';
+CREATE INDEX idx_stock_reservations_product_id ON stock_reservations(product_id, created_at DESC);
+CREATE INDEX idx_stock_reservations_invoice_id ON stock_reservations(invoice_id) WHERE invoice_id IS NOT NULL;
+CREATE TRIGGER update_stock_reservations_updated_at BEFORE UPDATE ON stock_reservations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+INSERT INTO system_settings (key, value, description, allowed_values) VALUES
+('stock.checkout_hold_minutes', '10', 'مدة حجز الحمام عند بدء الدفع (بالدقائق)', NULL),
+('stock.supplies_hold_minutes', '15', 'مدة حجز كمية المستلزمات عند بدء الدفع (بالدقائق)', NULL),
+('stock.max_active_holds_per_user', '5', 'الحد الأقصى لعمليات الدفع المعلقة ذات الحجز النشط لكل مستخدم', NULL)
+ON CONFLICT (key) DO NOTHING;
+
+COMMENT ON TABLE stock_reservations IS 'حجوزات المخزون أثناء الدفع: تُستهلك عند السداد أو تُحرر عند انتهاء الجلسة';
+COMMENT ON COLUMN stock_reservations.qty IS 'الكمية المحجوزة (مخصومة من stock_qty للمستلزمات، 1 للحمام)';
+COMMENT ON COLUMN stock_reservations.release_reason IS 'سبب التحرير: expired | payment_failed | superseded | admin';
@@ -0,0 +1,11 @@
+-- 0029_product_search.down.sql
+-- Rollback: Remove full-text product search
+
+DROP TRIGGER IF EXISTS pigeons_search_vector_update ON pigeons;
+DROP TRIGGER IF EXISTS products_search_vector_update ON products;
+DROP FUNCTION IF EXISTS pigeons_search_vector_trigger();
+DROP FUNCTION IF EXISTS products_search_vector_trigger();
+DROP INDEX IF EXISTS idx_products_search_vector;
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
+DROP FUNCTION IF EXISTS product_search_vector(TEXT, TEXT, TEXT, TEXT);
+DROP FUNCTION IF EXISTS normalize_ar(TEXT);
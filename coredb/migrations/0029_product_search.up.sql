@@ -0,0 +1,65 @@
+-- 0029_product_search.up.sql
+-- Full-text product search across title, description and pigeon ring number/lineage.
+-- Arabic text is normalized before indexing and querying (diacritics/tatweel dropped,
+-- hamza/alef variants folded, ta marbuta → ha, alef maqsura → ya, Arabic-Indic digits → ASCII).
+
+CREATE OR REPLACE FUNCTION normalize_ar(txt TEXT) RETURNS TEXT AS $$
+    SELECT translate(
+        regexp_replace(lower(COALESCE(txt, '')), '[\u064B-\u065F\u0670\u0640]', '', 'g'),
+        'أإآٱؤئىة٠١٢٣٤٥٦٧٨٩',
+        'ااااوييه0123456789'
+    )
+$$ LANGUAGE SQL IMMUTABLE PARALLEL SAFE;
+
+-- 'simple' keeps tokens as-is (no stemming), which works for mixed Arabic/English text and ring numbers
+CREATE OR REPLACE FUNCTION product_search_vector(title TEXT, description TEXT, ring_number TEXT, lineage TEXT) RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('simple', normalize_ar(title)), 'A')
+        || setweight(to_tsvector('simple', normalize_ar(ring_number)), 'A')
+        || setweight(to_tsvector('simple', normalize_ar(lineage)), 'B')
+        || setweight(to_tsvector('simple', normalize_ar(description)), 'C')
+$$ LANGUAGE SQL IMMUTABLE PARALLEL SAFE;
+
+ALTER TABLE products ADD COLUMN search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION products_search_vector_trigger() RETURNS TRIGGER AS $$
+DECLARE
+    ring TEXT;
+    lin TEXT;
+BEGIN
+    SELECT ring_number, lineage INTO ring, lin FROM pigeons WHERE product_id = NEW.id;
+    NEW.search_vector := product_search_vector(NEW.title, NEW.description, ring, lin);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_search_vector_update
+BEFORE INSERT OR UPDATE OF title, description ON products
+FOR EACH ROW EXECUTE FUNCTION products_search_vector_trigger();
+
+-- Pigeon rows are written after their product, so refresh the product's vector from here
+CREATE OR REPLACE FUNCTION pigeons_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE products
+    SET search_vector = product_search_vector(title, description, NEW.ring_number, NEW.lineage)
+    WHERE id = NEW.product_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER pigeons_search_vector_update
+AFTER INSERT OR UPDATE OF ring_number, lineage ON pigeons
+FOR EACH ROW EXECUTE FUNCTION pigeons_search_vector_trigger();
+
+-- Backfill without touching updated_at
+ALTER TABLE products DISABLE TRIGGER update_products_updated_at;
+UPDATE products p
+SET search_vector = product_search_vector(p.title, p.description, pg.ring_number, pg.lineage)
+FROM products p2
+LEFT JOIN pigeons pg ON pg.product_id = p2.id
+WHERE p2.id = p.id;
+ALTER TABLE products ENABLE TRIGGER update_products_updated_at;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+
+COMMENT ON COLUMN products.search_vector IS 'متجه البحث النصي (العنوان، الوصف، رقم الحلقة، السلالة) بعد توحيد الكتابة العربية';
+COMMENT ON FUNCTION normalize_ar(TEXT) IS 'توحيد النص العربي للبحث: إزالة التشكيل والتطويل وتوحيد الهمزات والتاء المربوطة والأرقام';
@@ -0,0 +1,7 @@
+-- 0030_pigeon_pedigree.down.sql
+-- Rollback: Remove structured pedigree
+
+DROP TRIGGER IF EXISTS sync_pedigree_bird_ring_trigger ON pigeons;
+DROP FUNCTION IF EXISTS sync_pedigree_bird_ring();
+DROP TABLE IF EXISTS pedigree_race_results;
+DROP TABLE IF EXISTS pedigree_birds;
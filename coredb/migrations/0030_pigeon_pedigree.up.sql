@@ -0,0 +1,64 @@
+-- 0030_pigeon_pedigree.up.sql
+-- Structured pedigree: every bird in a family tree is a pedigree_birds row keyed by ring
+-- number, linked to its sire and dam. Birds sold here are linked to their pigeons row
+-- (internal); ancestors bred elsewhere have no product (external). pigeons.lineage stays as
+-- free-text notes.
+
+CREATE TABLE pedigree_birds (
+    id BIGSERIAL PRIMARY KEY,
+    ring_number TEXT NOT NULL,
+    product_id BIGINT NULL UNIQUE REFERENCES pigeons(product_id) ON DELETE SET NULL,
+    name TEXT,
+    sex pigeon_sex NOT NULL DEFAULT 'unknown',
+    birth_year INTEGER CHECK (birth_year BETWEEN 1900 AND 2100),
+    color TEXT,
+    breeder TEXT,
+    notes TEXT,
+    sire_id BIGINT REFERENCES pedigree_birds(id) ON DELETE SET NULL,
+    dam_id BIGINT REFERENCES pedigree_birds(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_pedigree_birds_not_own_parent CHECK (sire_id <> id AND dam_id <> id)
+);
+CREATE UNIQUE INDEX uq_pedigree_birds_ring_number ON pedigree_birds(upper(ring_number));
+CREATE INDEX idx_pedigree_birds_sire_id ON pedigree_birds(sire_id) WHERE sire_id IS NOT NULL;
+CREATE INDEX idx_pedigree_birds_dam_id ON pedigree_birds(dam_id) WHERE dam_id IS NOT NULL;
+CREATE TRIGGER update_pedigree_birds_updated_at BEFORE UPDATE ON pedigree_birds FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE pedigree_race_results (
+    id BIGSERIAL PRIMARY KEY,
+    bird_id BIGINT NOT NULL REFERENCES pedigree_birds(id) ON DELETE CASCADE,
+    race_name TEXT NOT NULL,
+    race_date DATE,
+    distance_km NUMERIC(8,2) CHECK (distance_km > 0),
+    position INTEGER CHECK (position > 0),
+    total_birds INTEGER CHECK (total_birds > 0),
+    speed_mpm NUMERIC(10,2) CHECK (speed_mpm > 0),
+    notes TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_pedigree_race_results_bird_id ON pedigree_race_results(bird_id, race_date DESC);
+
+-- Keep internal birds linked to their pigeon when a product is created or its ring number changes
+CREATE OR REPLACE FUNCTION sync_pedigree_bird_ring() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        UPDATE pedigree_birds b SET ring_number = NEW.ring_number
+        WHERE b.product_id = NEW.product_id
+          AND NOT EXISTS (SELECT 1 FROM pedigree_birds o WHERE upper(o.ring_number) = upper(NEW.ring_number) AND o.id <> b.id);
+    END IF;
+    UPDATE pedigree_birds SET product_id = NEW.product_id
+    WHERE upper(ring_number) = upper(NEW.ring_number) AND product_id IS NULL
+      AND NOT EXISTS (SELECT 1 FROM pedigree_birds WHERE product_id = NEW.product_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sync_pedigree_bird_ring_trigger
+AFTER INSERT OR UPDATE OF ring_number ON pigeons
+FOR EACH ROW EXECUTE FUNCTION sync_pedigree_bird_ring();
+
+COMMENT ON TABLE pedigree_birds IS 'طيور شجرة النسب (داخلية مرتبطة بمنتج أو خارجية) مع روابط الأب والأم';
+COMMENT ON COLUMN pedigree_birds.product_id IS 'الحمامة المعروضة في المتجر إن كان الطير داخلياً';
+COMMENT ON TABLE pedigree_race_results IS 'نتائج السباقات لطيور شجرة النسب';
+COMMENT ON COLUMN pedigree_race_results.speed_mpm IS 'السرعة بالمتر في الدقيقة';
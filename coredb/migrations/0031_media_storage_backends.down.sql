@@ -0,0 +1,11 @@
+-- 0031_media_storage_backends.down.sql
+-- Rollback: Remove storage backend settings and restore the original provider values
+
+DELETE FROM system_settings WHERE key IN (
+    'media.local.dir', 'media.s3.endpoint', 'media.s3.region', 'media.s3.bucket', 'media.s3.path_style'
+);
+
+UPDATE system_settings
+SET value = CASE WHEN value = 'gcs' THEN 'local' ELSE value END,
+    allowed_values = ARRAY['local','s3','cloudinary']
+WHERE key = 'media.storage_provider';
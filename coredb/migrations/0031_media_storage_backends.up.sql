@@ -0,0 +1,18 @@
+-- 0031_media_storage_backends.up.sql
+-- Media storage backends: media.storage_provider now selects the driver (gcs | local | s3).
+-- Media has always been stored in GCS whatever the setting said, so the old 'local' default
+-- becomes 'gcs' to keep existing deployments unchanged.
+
+UPDATE system_settings
+SET value = CASE WHEN value IN ('local', 'cloudinary') THEN 'gcs' ELSE value END,
+    allowed_values = ARRAY['gcs','local','s3']
+WHERE key = 'media.storage_provider';
+
+INSERT INTO system_settings (key, value, description, allowed_values) VALUES
+('media.storage_provider', 'gcs', 'مزود تخزين الوسائط', ARRAY['gcs','local','s3']),
+('media.local.dir', 'data/media', 'مجلد تخزين الوسائط عند استخدام التخزين المحلي', NULL),
+('media.s3.endpoint', '', 'عنوان خدمة S3 المتوافقة (فارغ = AWS حسب المنطقة)', NULL),
+('media.s3.region', 'us-east-1', 'منطقة S3', NULL),
+('media.s3.bucket', '', 'اسم حاوية S3', NULL),
+('media.s3.path_style', 'true', 'استخدام عناوين path-style (MinIO / R2)', ARRAY['true','false'])
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,21 @@
+-- 0032_media_processing_pipeline.down.sql
+-- Rollback: Remove media variants and processing status
+
+DELETE FROM system_settings WHERE key IN (
+    'media.variants.widths', 'media.variants.formats', 'media.variants.quality'
+);
+
+DROP TABLE IF EXISTS media_variants;
+
+DROP INDEX IF EXISTS idx_media_processing_status;
+
+ALTER TABLE media
+    DROP COLUMN IF EXISTS variants_signature,
+    DROP COLUMN IF EXISTS original_watermarked,
+    DROP COLUMN IF EXISTS original_path,
+    DROP COLUMN IF EXISTS processed_at,
+    DROP COLUMN IF EXISTS processing_attempts,
+    DROP COLUMN IF EXISTS processing_error,
+    DROP COLUMN IF EXISTS processing_status;
+
+DROP TYPE IF EXISTS media_processing_status;
@@ -0,0 +1,48 @@
+-- 0032_media_processing_pipeline.up.sql
+-- Asynchronous media processing: uploads keep the original under originals/ and a Pub/Sub
+-- worker builds responsive variants (several widths × AVIF/WebP/JPEG, metadata stripped).
+-- media.gcs_path / thumb_path point at the largest / smallest JPEG variant once ready.
+
+CREATE TYPE media_processing_status AS ENUM ('pending', 'processing', 'ready', 'failed');
+
+ALTER TABLE media
+    ADD COLUMN processing_status media_processing_status NOT NULL DEFAULT 'ready',
+    ADD COLUMN processing_error TEXT,
+    ADD COLUMN processing_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN processed_at TIMESTAMPTZ,
+    ADD COLUMN original_path TEXT,
+    ADD COLUMN original_watermarked BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN variants_signature TEXT;
+
+COMMENT ON COLUMN media.original_path IS 'الملف الأصلي كما رُفع (لا يُعرض للعامة)';
+COMMENT ON COLUMN media.original_watermarked IS 'الأصل يحمل علامة مائية مسبقًا (وسائط قديمة عولجت أثناء الرفع)';
+COMMENT ON COLUMN media.variants_signature IS 'بصمة إعدادات المعالجة التي أُنشئت بها النسخ';
+
+-- Existing images were processed inline: their stored file becomes the original (already
+-- watermarked) so re-processing does not stamp the logo twice
+UPDATE media
+SET original_path = gcs_path,
+    original_watermarked = watermark_applied
+WHERE kind = 'image' AND gcs_path NOT LIKE 'http%';
+
+CREATE INDEX idx_media_processing_status ON media(processing_status)
+WHERE processing_status <> 'ready';
+
+CREATE TABLE media_variants (
+    id BIGSERIAL PRIMARY KEY,
+    media_id BIGINT NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    format TEXT NOT NULL CHECK (format IN ('avif', 'webp', 'jpeg')),
+    width INTEGER NOT NULL CHECK (width > 0),
+    height INTEGER NOT NULL CHECK (height > 0),
+    path TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (media_id, format, width)
+);
+CREATE INDEX idx_media_variants_media_id ON media_variants(media_id);
+
+INSERT INTO system_settings (key, value, description, allowed_values) VALUES
+('media.variants.widths', '320,640,1024,1600', 'عروض النسخ المتجاوبة للصور (بكسل)', NULL),
+('media.variants.formats', 'avif,webp,jpeg', 'صيغ النسخ المتجاوبة (JPEG تُنشأ دائمًا)', NULL),
+('media.variants.quality', '80', 'جودة ترميز النسخ (1-100)', NULL)
+ON CONFLICT (key) DO NOTHING;
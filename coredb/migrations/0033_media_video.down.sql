@@ -0,0 +1,17 @@
+-- 0033_media_video.down.sql
+-- Rollback: Remove video metadata columns and settings
+
+UPDATE system_settings
+SET value = replace(value, ',video/quicktime,video/webm', '')
+WHERE key = 'media.allowed_types';
+
+DELETE FROM system_settings WHERE key IN (
+    'media.video.max_duration', 'media.video.allowed_codecs', 'media.video.transcoder'
+);
+
+ALTER TABLE media
+    DROP COLUMN IF EXISTS audio_codec,
+    DROP COLUMN IF EXISTS video_codec,
+    DROP COLUMN IF EXISTS height,
+    DROP COLUMN IF EXISTS width,
+    DROP COLUMN IF EXISTS duration_ms;
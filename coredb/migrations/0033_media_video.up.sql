@@ -0,0 +1,24 @@
+-- 0033_media_video.up.sql
+-- Video uploads go through the media worker too: probed (container, codec, duration),
+-- poster frame extracted (stored as thumb_path + responsive poster variants) and remuxed or
+-- transcoded to a web-safe H.264/AAC MP4 without metadata (gcs_path).
+
+ALTER TABLE media
+    ADD COLUMN duration_ms INTEGER CHECK (duration_ms IS NULL OR duration_ms >= 0),
+    ADD COLUMN width INTEGER,
+    ADD COLUMN height INTEGER,
+    ADD COLUMN video_codec TEXT,
+    ADD COLUMN audio_codec TEXT;
+
+COMMENT ON COLUMN media.duration_ms IS 'مدة الفيديو بالمللي ثانية';
+COMMENT ON COLUMN media.video_codec IS 'ترميز الفيديو المنشور (بعد التحويل إن وُجد)';
+
+INSERT INTO system_settings (key, value, description, allowed_values) VALUES
+('media.video.max_duration', '180', 'أقصى مدة للفيديو بالثواني', NULL),
+('media.video.allowed_codecs', 'h264,hevc,vp8,vp9,av1', 'ترميزات الفيديو المقبولة عند الرفع', NULL),
+('media.video.transcoder', 'local', 'محوّل الفيديو (local = ffmpeg على الخادم، none = نشر الملف كما رُفع)', ARRAY['local','none'])
+ON CONFLICT (key) DO NOTHING;
+
+UPDATE system_settings
+SET value = value || ',video/quicktime,video/webm'
+WHERE key = 'media.allowed_types' AND value NOT LIKE '%video/webm%';
@@ -0,0 +1,4 @@
+-- 0034_refresh_tokens.down.sql
+-- Rollback refresh token store
+
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,23 @@
+-- 0034_refresh_tokens.up.sql
+-- Server-side record of issued refresh tokens. Every /auth/refresh rotates the token: the
+-- presented one is marked used and a new one is issued in the same family (one family per
+-- login). Presenting a used token again means it leaked, so the whole family is revoked.
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    token_id UUID NOT NULL UNIQUE,     -- jti claim of the refresh JWT
+    family_id UUID NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ NULL,          -- rotated into replaced_by
+    replaced_by UUID NULL,
+    revoked_at TIMESTAMPTZ NULL,
+    revoked_reason TEXT NULL,          -- logout | reuse | password_reset | user_inactive
+    ip_address TEXT NULL,
+    user_agent TEXT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_active ON refresh_tokens(user_id) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
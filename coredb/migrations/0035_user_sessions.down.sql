@@ -0,0 +1,6 @@
+-- 0035_user_sessions.down.sql
+-- Rollback persistent sessions
+
+DROP INDEX IF EXISTS idx_refresh_tokens_session;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS session_id;
+DROP TABLE IF EXISTS user_sessions;
@@ -0,0 +1,26 @@
+-- 0035_user_sessions.up.sql
+-- Login sessions (one per device) shared by all instances, so users and admins can list
+-- them and log out other devices. Session IDs are carried in the JWTs (sid claim); refresh
+-- tokens of a deleted session stop working.
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_changes INT NOT NULL DEFAULT 0,
+    last_ip_change TIMESTAMPTZ NULL,
+    metadata JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_expires_at ON user_sessions(expires_at);
+
+-- Refresh tokens belong to the session (device) that obtained them
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_id TEXT NULL;
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session ON refresh_tokens(session_id) WHERE revoked_at IS NULL;
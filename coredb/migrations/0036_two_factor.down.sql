@@ -0,0 +1,6 @@
+-- 0036_two_factor.down.sql
+-- Rollback two-factor authentication
+
+DROP TABLE IF EXISTS login_challenges;
+DROP TABLE IF EXISTS user_recovery_codes;
+DROP TABLE IF EXISTS user_two_factor;
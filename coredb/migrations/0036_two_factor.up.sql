@@ -0,0 +1,41 @@
+-- 0036_two_factor.up.sql
+-- Two-factor authentication: TOTP enrolment (secret encrypted at rest), SMS OTP opt-in,
+-- single-use recovery codes, and the step-up challenges issued by login before tokens.
+-- Mandatory for role=admin.
+
+CREATE TABLE IF NOT EXISTS user_two_factor (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    totp_secret TEXT NULL,            -- AES-GCM sealed base32 secret
+    totp_pending_secret TEXT NULL,    -- sealed secret awaiting confirmation during enrolment
+    totp_enabled_at TIMESTAMPTZ NULL,
+    totp_last_step BIGINT NOT NULL DEFAULT 0, -- last accepted time step (replay protection)
+    sms_enabled_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user ON user_recovery_codes(user_id) WHERE used_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS login_challenges (
+    id UUID PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sms_code_hash TEXT NULL,          -- set when an SMS OTP was generated locally (not Twilio Verify)
+    sms_sent_at TIMESTAMPTZ NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_challenges_user ON login_challenges(user_id);
+CREATE INDEX IF NOT EXISTS idx_login_challenges_expires_at ON login_challenges(expires_at);
@@ -0,0 +1,5 @@
+-- 0037_maintenance_windows.down.sql
+-- Rollback maintenance windows
+
+DELETE FROM system_settings WHERE key = 'app.maintenance_message';
+DROP TABLE IF EXISTS maintenance_windows;
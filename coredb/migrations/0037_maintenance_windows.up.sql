@@ -0,0 +1,20 @@
+-- 0037_maintenance_windows.up.sql
+-- Scheduled maintenance windows announced on the public status endpoint. While a window is
+-- running (or app.maintenance_mode is true) non-admin writes are rejected with APP_MAINTENANCE.
+
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id BIGSERIAL PRIMARY KEY,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    created_by BIGINT NULL REFERENCES users(id) ON DELETE SET NULL,
+    cancelled_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT maintenance_windows_range CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_windows_ends_at ON maintenance_windows(ends_at) WHERE cancelled_at IS NULL;
+
+INSERT INTO system_settings (key, value, description, allowed_values) VALUES
+('app.maintenance_message', '', 'رسالة وضع الصيانة المعروضة للمستخدمين (اختياري)', NULL)
+ON CONFLICT (key) DO NOTHING;
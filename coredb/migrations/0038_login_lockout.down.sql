@@ -0,0 +1,5 @@
+-- 0038_login_lockout.down.sql
+-- Rollback login failure tracking and account unlock tokens
+
+DROP TABLE IF EXISTS account_unlock_tokens;
+DROP TABLE IF EXISTS login_failures;
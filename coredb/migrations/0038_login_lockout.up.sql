@@ -0,0 +1,35 @@
+-- 0038_login_lockout.up.sql
+-- Persistent failed sign-in tracking per account (normalised email) and per client IP, with
+-- progressive delays and temporary lockout (security.max_login_attempts /
+-- security.lockout_duration), plus single-use unlock links emailed when an account locks.
+
+CREATE TABLE IF NOT EXISTS login_failures (
+    scope TEXT NOT NULL CHECK (scope IN ('account', 'ip')),
+    key TEXT NOT NULL,                -- lower-cased email or client IP
+    user_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE, -- set for known accounts
+    failures INT NOT NULL DEFAULT 0,
+    first_failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    next_attempt_at TIMESTAMPTZ NULL, -- progressive delay: attempts before this are rejected
+    locked_until TIMESTAMPTZ NULL,
+    lock_count INT NOT NULL DEFAULT 0, -- locks so far, for admin visibility
+    last_ip TEXT NOT NULL DEFAULT '',
+    last_user_agent TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_failures_locked_until ON login_failures(locked_until) WHERE locked_until IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_login_failures_last_failed_at ON login_failures(last_failed_at);
+CREATE INDEX IF NOT EXISTS idx_login_failures_user ON login_failures(user_id) WHERE user_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS account_unlock_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,  -- sha256 of the emailed token
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_unlock_tokens_user ON account_unlock_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_account_unlock_tokens_expires_at ON account_unlock_tokens(expires_at);
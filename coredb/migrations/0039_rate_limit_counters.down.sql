@@ -0,0 +1,5 @@
+-- 0039_rate_limit_counters.down.sql
+-- Rollback shared rate limit counters
+
+DELETE FROM system_settings WHERE key LIKE 'ratelimit.%';
+DROP TABLE IF EXISTS rate_limit_counters;
@@ -0,0 +1,31 @@
+-- 0039_rate_limit_counters.up.sql
+-- Shared rate limit counters (pkg/ratelimit.PostgresStorage): one sliding window counter per
+-- key, updated under a row lock so limits hold across instances and survive deploys.
+-- Per-action limits are tunable through ratelimit.<action>.* system settings.
+
+CREATE TABLE IF NOT EXISTS rate_limit_counters (
+    key TEXT PRIMARY KEY,
+    window_start TIMESTAMPTZ NOT NULL,  -- start of the current fixed window
+    window_ms BIGINT NOT NULL DEFAULT 0,
+    curr_count INT NOT NULL DEFAULT 0,  -- attempts in the current window
+    prev_count INT NOT NULL DEFAULT 0,  -- attempts in the previous window (weighted on read)
+    last_seen TIMESTAMPTZ NOT NULL,
+    blocked_at TIMESTAMPTZ NULL,
+    expires_at TIMESTAMPTZ NOT NULL     -- the row no longer affects any decision after this
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_counters_expires_at ON rate_limit_counters(expires_at);
+
+INSERT INTO system_settings (key, value, description, allowed_values) VALUES
+('ratelimit.login.max_attempts', '10', 'محاولات تسجيل الدخول المسموحة لكل IP أو بريد في النافذة', NULL),
+('ratelimit.login.window_seconds', '600', 'نافذة حد تسجيل الدخول بالثواني', NULL),
+('ratelimit.login.block_seconds', '300', 'مدة الحظر بعد تجاوز حد تسجيل الدخول بالثواني', NULL),
+('ratelimit.register.max_attempts', '5', 'محاولات التسجيل المسموحة لكل IP أو جوال في النافذة', NULL),
+('ratelimit.register.window_seconds', '3600', 'نافذة حد التسجيل بالثواني', NULL),
+('ratelimit.register.block_seconds', '1800', 'مدة الحظر بعد تجاوز حد التسجيل بالثواني', NULL),
+('ratelimit.verify.max_attempts', '3', 'محاولات التحقق (البريد ورموز التحقق) المسموحة في النافذة', NULL),
+('ratelimit.verify.window_seconds', '3600', 'نافذة حد التحقق بالثواني', NULL),
+('ratelimit.verify.block_seconds', '900', 'مدة الحظر بعد تجاوز حد التحقق بالثواني', NULL),
+('ratelimit.payments_init.max_attempts', '5', 'طلبات بدء الدفع المسموحة لكل مستخدم في النافذة', NULL),
+('ratelimit.payments_init.window_seconds', '60', 'نافذة حد بدء الدفع بالثواني', NULL)
+ON CONFLICT (key) DO NOTHING;
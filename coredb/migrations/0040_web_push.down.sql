@@ -0,0 +1,7 @@
+-- 0040_web_push.down.sql
+-- Rollback Web Push subscriptions. Postgres cannot drop an enum value, so 'push' stays in
+-- notification_channel; queued push messages are removed instead.
+
+DROP TABLE IF EXISTS push_subscriptions;
+ALTER TABLE notification_preferences DROP COLUMN IF EXISTS push_enabled;
+DELETE FROM notifications WHERE channel::text = 'push';
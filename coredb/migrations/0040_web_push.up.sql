@@ -0,0 +1,33 @@
+-- 0040_web_push.up.sql
+-- Web Push channel: browser push subscriptions (one per browser/device) and a per-category
+-- push preference. Messages are queued in notifications with channel 'push' and sent by
+-- notifications.ProcessPushQueue; subscriptions the push service reports gone are deleted.
+
+ALTER TYPE notification_channel ADD VALUE IF NOT EXISTS 'push';
+
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS push_enabled BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Login session of the device; logging the device out stops its pushes
+    session_id TEXT NULL REFERENCES user_sessions(id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL UNIQUE,
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    device_name TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    -- PushSubscription.expirationTime when the browser sets one
+    expires_at TIMESTAMPTZ NULL,
+    -- Consecutive failed deliveries; reset on success, pruned past a limit
+    failure_count INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    last_success_at TIMESTAMPTZ NULL,
+    last_failure_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user_id ON push_subscriptions(user_id);
+CREATE TRIGGER update_push_subscriptions_updated_at BEFORE UPDATE ON push_subscriptions FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE push_subscriptions IS 'اشتراكات الإشعارات الفورية للمتصفح لكل جهاز';
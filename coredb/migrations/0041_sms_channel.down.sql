@@ -0,0 +1,5 @@
+-- 0041_sms_channel.down.sql
+-- Rollback SMS notifications. Postgres cannot drop an enum value, so 'sms' stays in
+-- notification_channel; queued SMS messages are removed instead.
+
+DELETE FROM notifications WHERE channel::text = 'sms';
@@ -0,0 +1,6 @@
+-- 0041_sms_channel.up.sql
+-- SMS channel: short text notifications queued in notifications with channel 'sms' and sent by
+-- notifications.ProcessSMSQueue to the user's phone. Gated by notifications.sms_enabled and the
+-- per-category sms_enabled preference (opt-in).
+
+ALTER TYPE notification_channel ADD VALUE IF NOT EXISTS 'sms';
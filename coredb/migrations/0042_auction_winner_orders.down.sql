@@ -0,0 +1,4 @@
+-- 0042_auction_winner_orders.down.sql
+
+DROP INDEX IF EXISTS uq_orders_auction_winner;
+ALTER TABLE orders DROP COLUMN IF EXISTS auction_id;
@@ -0,0 +1,8 @@
+-- 0042_auction_winner_orders.up.sql
+-- Link auction orders to their auction so order_mgmt.CreateAuctionWinnerOrder is idempotent:
+-- a retried winner or second-chance acceptance gets the existing order instead of a second one.
+
+ALTER TABLE orders ADD COLUMN auction_id BIGINT REFERENCES auctions(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX uq_orders_auction_winner ON orders(auction_id, user_id) WHERE auction_id IS NOT NULL;
+
+COMMENT ON COLUMN orders.auction_id IS 'المزاد الذي أنشئ منه الطلب (طلبات الفائز والفرصة الثانية)';
@@ -0,0 +1,8 @@
+-- 0043_reconciliation_gateway_listing.down.sql
+-- Postgres cannot drop an enum value, so 'missing_in_db' stays in payment_discrepancy_kind;
+-- the gateway-only discrepancies are removed instead.
+
+DROP INDEX IF EXISTS uq_payment_discrepancies_report_gateway_only;
+DELETE FROM payment_discrepancies WHERE payment_id IS NULL OR invoice_id IS NULL;
+ALTER TABLE payment_discrepancies ALTER COLUMN invoice_id SET NOT NULL;
+ALTER TABLE payment_discrepancies ALTER COLUMN payment_id SET NOT NULL;
@@ -0,0 +1,11 @@
+-- 0043_reconciliation_gateway_listing.up.sql
+-- The daily reconciliation report lists the gateway's transactions for the period and diffs
+-- both ways: gateway transactions with no payments row are recorded as 'missing_in_db', so
+-- those discrepancies have no payment (and possibly no invoice) to point at.
+
+ALTER TYPE payment_discrepancy_kind ADD VALUE IF NOT EXISTS 'missing_in_db';
+
+ALTER TABLE payment_discrepancies ALTER COLUMN payment_id DROP NOT NULL;
+ALTER TABLE payment_discrepancies ALTER COLUMN invoice_id DROP NOT NULL;
+CREATE UNIQUE INDEX uq_payment_discrepancies_report_gateway_only
+    ON payment_discrepancies(report_id, gateway, gateway_ref) WHERE payment_id IS NULL;
@@ -0,0 +1,3 @@
+-- 0044_invoice_issued_at.down.sql
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS issued_at;
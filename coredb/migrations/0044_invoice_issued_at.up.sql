@@ -0,0 +1,7 @@
+-- 0044_invoice_issued_at.up.sql
+-- When a tax invoice was issued (first marked paid). The ZATCA QR timestamp and the UBL
+-- IssueDate/IssueTime use it; rows paid before this migration fall back to created_at.
+
+ALTER TABLE invoices ADD COLUMN issued_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN invoices.issued_at IS 'وقت إصدار الفاتورة الضريبية (أول سداد)';
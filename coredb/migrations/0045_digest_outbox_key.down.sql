@@ -0,0 +1,3 @@
+-- 0045_digest_outbox_key.down.sql
+
+DROP INDEX IF EXISTS idx_notification_digest_items_outbox_key;
@@ -0,0 +1,5 @@
+-- 0045_digest_outbox_key.up.sql
+-- Emails parked for the daily digest keep the outbox dedupe key in their payload; index it so a
+-- redelivered outbox row can tell it was already parked.
+
+CREATE INDEX idx_notification_digest_items_outbox_key ON notification_digest_items((payload->>'outbox_key')) WHERE payload ? 'outbox_key';
@@ -0,0 +1,3 @@
+-- 0046_ws_connect_rate_limit.down.sql
+
+DELETE FROM system_settings WHERE key LIKE 'ratelimit.ws_connect.%';
@@ -0,0 +1,8 @@
+-- 0046_ws_connect_rate_limit.up.sql
+-- WebSocket connect attempts get their own limit instead of reading ws.max_connections_per_host,
+-- which caps concurrent connections
+
+INSERT INTO system_settings (key, value, description, allowed_values) VALUES
+('ratelimit.ws_connect.max_attempts', '120', 'محاولات اتصال WebSocket المسموحة لكل IP في النافذة', NULL),
+('ratelimit.ws_connect.window_seconds', '60', 'نافذة حد محاولات اتصال WebSocket بالثواني', NULL)
+ON CONFLICT (key) DO NOTHING;
@@ -31,7 +31,7 @@ var _ = cron.NewJob("auction-tick", cron.JobConfig{
 	Endpoint: RunAuctionTick,
 })
 
-// Reservation cleaner removed (no-reservation model)
+// Checkout stock holds are released by the payment-in-progress cleaner below
 
 //encore:api private
 func RunPaymentInProgressCleaner(ctx context.Context) (*worker.CleanupResponse, error) {
@@ -39,11 +39,44 @@ func RunPaymentInProgressCleaner(ctx context.Context) (*worker.CleanupResponse,
 }
 
 var _ = cron.NewJob("payment-in-progress-cleaner", cron.JobConfig{
-	Title:    "Cleanup stale payment_in_progress sessions",
+	Title:    "Cleanup stale payment_in_progress sessions and expired stock holds",
 	Every:    10 * cron.Minute,
 	Endpoint: RunPaymentInProgressCleaner,
 })
 
+//encore:api private
+func RunPaymentReconciler(ctx context.Context) (*worker.ReconcileResponse, error) {
+	return worker.ReconcilePayments(ctx)
+}
+
+var _ = cron.NewJob("payment-reconciler", cron.JobConfig{
+	Title:    "Reconcile in-flight payments with the gateway",
+	Every:    5 * cron.Minute,
+	Endpoint: RunPaymentReconciler,
+})
+
+//encore:api private
+func RunPaymentDiscrepancyReport(ctx context.Context) (*worker.ReconciliationReport, error) {
+	return worker.GeneratePaymentDiscrepancyReport(ctx)
+}
+
+var _ = cron.NewJob("payment-discrepancy-report", cron.JobConfig{
+	Title:    "Daily payment discrepancy report",
+	Schedule: "30 2 * * *",
+	Endpoint: RunPaymentDiscrepancyReport,
+})
+
+//encore:api private
+func RunPaymentEventsOutbox(ctx context.Context) (*worker.DispatchPaymentEventsResponse, error) {
+	return worker.DispatchPaymentEvents(ctx)
+}
+
+var _ = cron.NewJob("payment-events-outbox", cron.JobConfig{
+	Title:    "Publish outboxed payment webhook events",
+	Every:    1 * cron.Minute,
+	Endpoint: RunPaymentEventsOutbox,
+})
+
 //encore:api private
 func RunDailyAdminDigest(ctx context.Context) error {
 	// Disabled by default via system setting key 'admin.digest.enabled' (string 'true' to enable)
@@ -131,6 +164,8 @@ type RunAllCronResponse struct {
 	PaymentCleaner      string                    `json:"payment_cleaner"`
 	DailyAdminDigest    string                    `json:"daily_admin_digest"`
 	PaymentCleanerStats *worker.CleanupResponse   `json:"payment_cleaner_stats,omitempty"`
+	PaymentReconciler   string                    `json:"payment_reconciler"`
+	ReconcilerStats     *worker.ReconcileResponse `json:"reconciler_stats,omitempty"`
 }
 
 //encore:api auth method=POST path=/admin/cron/run-all
@@ -144,6 +179,13 @@ func RunAllCronJobs(ctx context.Context) (*RunAllCronResponse, error) {
         out.AuctionTick = "ok"
     }
 
+    if resp, err := RunPaymentReconciler(ctx); err != nil {
+        out.PaymentReconciler = err.Error()
+    } else {
+        out.PaymentReconciler = "ok"
+        out.ReconcilerStats = resp
+    }
+
     if resp, err := RunPaymentInProgressCleaner(ctx); err != nil {
         out.PaymentCleaner = err.Error()
     } else {
@@ -172,6 +214,12 @@ func RunPaymentCleanerAdmin(ctx context.Context) (*worker.CleanupResponse, error
     return RunPaymentInProgressCleaner(ctx)
 }
 
+//encore:api auth method=POST path=/admin/cron/payment-discrepancy-report
+func RunPaymentDiscrepancyReportAdmin(ctx context.Context) (*worker.ReconciliationReport, error) {
+    if err := ensureAdmin(ctx); err != nil { return nil, err }
+    return RunPaymentDiscrepancyReport(ctx)
+}
+
 //encore:api auth method=POST path=/admin/cron/daily-admin-digest
 func RunDailyAdminDigestAdmin(ctx context.Context) error {
     if err := ensureAdmin(ctx); err != nil { return err }
@@ -196,9 +244,14 @@ func ListCronJobs(ctx context.Context) (*ListCronJobsResponse, error) {
     // Keep in sync with cron.NewJob registrations above
     return &ListCronJobsResponse{Jobs: []CronJobInfo{
         {ID: "auction-tick", Title: "Tick auctions (start/close)", Schedule: "every:1m"},
-        {ID: "payment-in-progress-cleaner", Title: "Cleanup stale payment_in_progress sessions", Schedule: "every:10m"},
+        {ID: "payment-in-progress-cleaner", Title: "Cleanup stale payment_in_progress sessions and expired stock holds", Schedule: "every:10m"},
+        {ID: "payment-reconciler", Title: "Reconcile in-flight payments with the gateway", Schedule: "every:5m"},
+        {ID: "payment-discrepancy-report", Title: "Daily payment discrepancy report", Schedule: "cron:30 2 * * *"},
+        {ID: "payment-events-outbox", Title: "Publish outboxed payment webhook events", Schedule: "every:1m"},
         {ID: "daily-admin-digest", Title: "Daily admin digest (optional)", Schedule: "every:24h"},
         {ID: "notifications-retention-cleanup", Title: "Clean up old notifications based on retention policy", Schedule: "cron:0 3 * * *"},
         {ID: "notifications-email-queue", Title: "Process email notifications queue", Schedule: "every:1m"},
+        {ID: "notifications-outbox-dispatcher", Title: "Deliver transactional outbox notifications", Schedule: "every:1m"},
+        {ID: "notifications-email-digest", Title: "Send daily notification digests", Schedule: "cron:0 6 * * *"},
     }}, nil
 }
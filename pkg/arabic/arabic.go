@@ -0,0 +1,258 @@
+// Package arabic is a minimal Arabic text layout for drawing with fonts that have no shaping
+// engine (the invoice PDF, generated images): contextual shaping through the Unicode
+// presentation forms (which most Arabic fonts map) and a simplified bidi pass that produces
+// left-to-right visual order. Enough for names, titles and labels; harakat are dropped.
+package arabic
+
+import "unicode"
+
+// presentation forms: isolated, final, initial, medial (0 = form does not exist)
+var arabicForms = map[rune][4]rune{
+	0x0621: {0xFE80, 0, 0, 0},
+	0x0622: {0xFE81, 0xFE82, 0, 0},
+	0x0623: {0xFE83, 0xFE84, 0, 0},
+	0x0624: {0xFE85, 0xFE86, 0, 0},
+	0x0625: {0xFE87, 0xFE88, 0, 0},
+	0x0626: {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C},
+	0x0627: {0xFE8D, 0xFE8E, 0, 0},
+	0x0628: {0xFE8F, 0xFE90, 0xFE91, 0xFE92},
+	0x0629: {0xFE93, 0xFE94, 0, 0},
+	0x062A: {0xFE95, 0xFE96, 0xFE97, 0xFE98},
+	0x062B: {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C},
+	0x062C: {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0},
+	0x062D: {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4},
+	0x062E: {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8},
+	0x062F: {0xFEA9, 0xFEAA, 0, 0},
+	0x0630: {0xFEAB, 0xFEAC, 0, 0},
+	0x0631: {0xFEAD, 0xFEAE, 0, 0},
+	0x0632: {0xFEAF, 0xFEB0, 0, 0},
+	0x0633: {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4},
+	0x0634: {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8},
+	0x0635: {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC},
+	0x0636: {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0},
+	0x0637: {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4},
+	0x0638: {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8},
+	0x0639: {0xFEC9, 0xFECA, 0xFECB, 0xFECC},
+	0x063A: {0xFECD, 0xFECE, 0xFECF, 0xFED0},
+	0x0641: {0xFED1, 0xFED2, 0xFED3, 0xFED4},
+	0x0642: {0xFED5, 0xFED6, 0xFED7, 0xFED8},
+	0x0643: {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC},
+	0x0644: {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0},
+	0x0645: {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4},
+	0x0646: {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8},
+	0x0647: {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC},
+	0x0648: {0xFEED, 0xFEEE, 0, 0},
+	0x0649: {0xFEEF, 0xFEF0, 0, 0},
+	0x064A: {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4},
+}
+
+// lam-alef ligatures: isolated, final
+var lamAlef = map[rune][2]rune{
+	0x0622: {0xFEF5, 0xFEF6},
+	0x0623: {0xFEF7, 0xFEF8},
+	0x0625: {0xFEF9, 0xFEFA},
+	0x0627: {0xFEFB, 0xFEFC},
+}
+
+const (
+	lam     = 0x0644
+	tatweel = 0x0640
+)
+
+// Base returns the letter a presentation form was derived from (0 if r is not one), for
+// fonts that lack the form
+func Base(r rune) rune { return basePresentation[r] }
+
+var basePresentation = func() map[rune]rune {
+	m := map[rune]rune{}
+	for base, forms := range arabicForms {
+		for _, f := range forms {
+			if f != 0 {
+				m[f] = base
+			}
+		}
+	}
+	return m
+}()
+
+func isHaraka(r rune) bool { return (r >= 0x064B && r <= 0x065F) || r == 0x0670 }
+
+// joinsBoth reports whether r connects to the letter after it (dual-joining)
+func joinsBoth(r rune) bool {
+	if r == tatweel {
+		return true
+	}
+	f, ok := arabicForms[r]
+	return ok && f[2] != 0
+}
+
+// joins reports whether r connects to the letter before it
+func joins(r rune) bool {
+	if r == tatweel {
+		return true
+	}
+	f, ok := arabicForms[r]
+	return ok && f[1] != 0
+}
+
+// Shape replaces Arabic letters with their contextual presentation forms (logical order)
+func Shape(in []rune) []rune {
+	src := make([]rune, 0, len(in))
+	for _, r := range in {
+		if !isHaraka(r) {
+			src = append(src, r)
+		}
+	}
+	out := make([]rune, 0, len(src))
+	for i := 0; i < len(src); i++ {
+		r := src[i]
+		prevJoins := i > 0 && joinsBoth(src[i-1])
+		if r == lam && i+1 < len(src) {
+			if lig, ok := lamAlef[src[i+1]]; ok {
+				if prevJoins {
+					out = append(out, lig[1])
+				} else {
+					out = append(out, lig[0])
+				}
+				i++
+				continue
+			}
+		}
+		forms, ok := arabicForms[r]
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+		prev := prevJoins && joins(r)
+		next := joinsBoth(r) && i+1 < len(src) && joins(src[i+1])
+		switch {
+		case prev && next:
+			out = append(out, forms[3])
+		case prev:
+			out = append(out, forms[1])
+		case next:
+			out = append(out, forms[2])
+		default:
+			out = append(out, forms[0])
+		}
+	}
+	return out
+}
+
+type bidiClass uint8
+
+const (
+	bidiN bidiClass = iota // neutral
+	bidiL
+	bidiR
+)
+
+func isDigit(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 0x0660 && r <= 0x0669) || (r >= 0x06F0 && r <= 0x06F9)
+}
+
+func classOf(r rune) bidiClass {
+	switch {
+	case isDigit(r):
+		return bidiL // numbers always read left to right
+	case (r >= 0x0600 && r <= 0x06FF) || (r >= 0xFB50 && r <= 0xFDFF) || (r >= 0xFE70 && r <= 0xFEFF):
+		return bidiR
+	case unicode.IsLetter(r):
+		return bidiL
+	}
+	return bidiN
+}
+
+var mirrored = map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '<': '>', '>': '<', '{': '}', '}': '{'}
+
+// Visual shapes s and reorders it for left-to-right drawing. The paragraph direction is
+// taken from the first strong character; neutrals between two runs of the same direction
+// join them, otherwise they follow the paragraph. Separators inside numbers stay with them.
+func Visual(s string) []rune {
+	rs := Shape([]rune(s))
+	classes := make([]bidiClass, len(rs))
+	para := bidiN
+	for i, r := range rs {
+		classes[i] = classOf(r)
+		if para == bidiN && classes[i] != bidiN {
+			para = classes[i]
+		}
+	}
+	if para != bidiR {
+		hasR := false
+		for _, c := range classes {
+			hasR = hasR || c == bidiR
+		}
+		if !hasR {
+			return rs
+		}
+		para = bidiL
+	}
+
+	for i, r := range rs {
+		if classes[i] != bidiN || i == 0 || !isDigit(rs[i-1]) {
+			continue
+		}
+		if r == '%' || ((r == '.' || r == ',' || r == '-' || r == '/' || r == ':') && i+1 < len(rs) && isDigit(rs[i+1])) {
+			classes[i] = bidiL
+		}
+	}
+	for i := 0; i < len(rs); i++ {
+		if classes[i] != bidiN {
+			continue
+		}
+		j := i
+		for j < len(rs) && classes[j] == bidiN {
+			j++
+		}
+		before, after := para, para
+		if i > 0 {
+			before = classes[i-1]
+		}
+		if j < len(rs) {
+			after = classes[j]
+		}
+		dir := para
+		if before == after {
+			dir = before
+		}
+		for k := i; k < j; k++ {
+			classes[k] = dir
+		}
+		i = j - 1
+	}
+
+	type run struct {
+		dir        bidiClass
+		start, end int
+	}
+	var runs []run
+	for i := 0; i < len(rs); {
+		j := i
+		for j < len(rs) && classes[j] == classes[i] {
+			j++
+		}
+		runs = append(runs, run{classes[i], i, j})
+		i = j
+	}
+	if para == bidiR {
+		for a, b := 0, len(runs)-1; a < b; a, b = a+1, b-1 {
+			runs[a], runs[b] = runs[b], runs[a]
+		}
+	}
+	out := make([]rune, 0, len(rs))
+	for _, rn := range runs {
+		if rn.dir == bidiR {
+			for k := rn.end - 1; k >= rn.start; k-- {
+				r := rs[k]
+				if m, ok := mirrored[r]; ok {
+					r = m
+				}
+				out = append(out, r)
+			}
+			continue
+		}
+		out = append(out, rs[rn.start:rn.end]...)
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package arabic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShape(t *testing.T) {
+	// ب initial + lam-alef final ligature
+	if got := Shape([]rune("بلا")); string(got) != "ﺑﻼ" {
+		t.Errorf("Shape(بلا) = %U", got)
+	}
+	// م isolated after a right-joining letter, harakat dropped
+	if got := Shape([]rune("دَم")); string(got) != "ﺩﻡ" {
+		t.Errorf("Shape(دَم) = %U", got)
+	}
+}
+
+func TestVisual(t *testing.T) {
+	if got := string(Visual("Invoice 12")); got != "Invoice 12" {
+		t.Errorf("LTR text changed: %q", got)
+	}
+	// RTL paragraph: the number comes first visually, Arabic is reversed after it
+	got := string(Visual("رقم: INV-1"))
+	if !strings.HasPrefix(got, "INV-1 :") {
+		t.Errorf("visual = %q", got)
+	}
+	if got := string(Visual("ضريبة 15%")); !strings.HasPrefix(got, "15% ") {
+		t.Errorf("percent should stay with the number: %q", got)
+	}
+}
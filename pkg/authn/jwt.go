@@ -33,18 +33,38 @@ var (
 
 // CustomClaims represents the custom JWT claims for our application
 type CustomClaims struct {
-	UserID int64  `json:"user_id"`
-	Role   string `json:"role"`
-	Email  string `json:"email"`
+	UserID    int64  `json:"user_id"`
+	Role      string `json:"role"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid,omitempty"` // login session (device) the token belongs to
 	jwt.RegisteredClaims
 }
 
+// SessionBound is implemented by auth data that carries the login session of its access token,
+// so services can read it without importing the auth service
+type SessionBound interface {
+	LoginSessionID() string
+}
+
+// SessionIDOf returns the login session of auth data (auth.Data()), or "" when it carries none
+func SessionIDOf(data any) string {
+	if sb, ok := data.(SessionBound); ok {
+		return sb.LoginSessionID()
+	}
+	return ""
+}
+
 // TokenPair represents a pair of access and refresh tokens
 type TokenPair struct {
 	AccessToken  string    `json:"access_token"`
 	RefreshToken string    `json:"refresh_token"`
 	ExpiresAt    time.Time `json:"expires_at"`
 	TokenType    string    `json:"token_type"`
+
+	// RefreshTokenID is the jti of the refresh token, used to track it server-side
+	RefreshTokenID string `json:"-"`
+	// RefreshExpiresAt is when the refresh token expires
+	RefreshExpiresAt time.Time `json:"-"`
 }
 
 // JWTManager handles JWT token operations
@@ -63,13 +83,19 @@ func NewJWTManager(accessSecret, refreshSecret string) *JWTManager {
 
 // GenerateTokens creates a new access and refresh token pair for the user
 func (j *JWTManager) GenerateTokens(userID int64, role, email string) (*TokenPair, error) {
+	return j.GenerateTokensForSession(userID, role, email, "")
+}
+
+// GenerateTokensForSession creates a token pair bound to a login session (sid claim)
+func (j *JWTManager) GenerateTokensForSession(userID int64, role, email, sessionID string) (*TokenPair, error) {
 	now := time.Now().UTC()
 
 	// Generate access token
 	accessClaims := &CustomClaims{
-		UserID: userID,
-		Role:   role,
-		Email:  email,
+		UserID:    userID,
+		Role:      role,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenDuration)),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -87,17 +113,19 @@ func (j *JWTManager) GenerateTokens(userID int64, role, email string) (*TokenPai
 	}
 
 	// Generate refresh token
+	refreshID := uuid.New().String()
 	refreshClaims := &CustomClaims{
-		UserID: userID,
-		Role:   role,
-		Email:  email,
+		UserID:    userID,
+		Role:      role,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(now.Add(RefreshTokenDuration)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "loft-dughairi",
 			Subject:   fmt.Sprintf("user:%d", userID),
-			ID:        uuid.New().String(), // Unique token ID
+			ID:        refreshID, // Unique token ID
 		},
 	}
 
@@ -112,6 +140,9 @@ func (j *JWTManager) GenerateTokens(userID int64, role, email string) (*TokenPai
 		RefreshToken: refreshTokenString,
 		ExpiresAt:    now.Add(AccessTokenDuration),
 		TokenType:    "Bearer",
+
+		RefreshTokenID:   refreshID,
+		RefreshExpiresAt: now.Add(RefreshTokenDuration),
 	}, nil
 }
 
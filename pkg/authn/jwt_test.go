@@ -164,6 +164,14 @@ func TestValidateRefreshToken(t *testing.T) {
 		t.Errorf("User ID mismatch: got %d, want %d", claims.UserID, userID)
 	}
 
+	// The token ID is exposed for the server-side refresh token store
+	if claims.ID != tokenPair.RefreshTokenID {
+		t.Errorf("Refresh token ID mismatch: got %s, want %s", claims.ID, tokenPair.RefreshTokenID)
+	}
+	if !claims.ExpiresAt.Time.Equal(tokenPair.RefreshExpiresAt.Truncate(time.Second)) {
+		t.Errorf("Refresh expiry mismatch: got %v, want %v", claims.ExpiresAt.Time, tokenPair.RefreshExpiresAt)
+	}
+
 	// Test invalid refresh token
 	_, err = manager.ValidateRefreshToken("invalid.token")
 	if err == nil {
@@ -171,6 +179,23 @@ func TestValidateRefreshToken(t *testing.T) {
 	}
 }
 
+func TestGenerateTokensForSession(t *testing.T) {
+	manager := NewJWTManager("test-access-secret", "test-refresh-secret")
+
+	tokenPair, err := manager.GenerateTokensForSession(123, "verified", "test@example.com", "sess-1")
+	if err != nil {
+		t.Fatalf("GenerateTokensForSession failed: %v", err)
+	}
+	access, err := manager.ValidateAccessToken(tokenPair.AccessToken)
+	if err != nil || access.SessionID != "sess-1" {
+		t.Fatalf("access token sid = %v, %v", access, err)
+	}
+	refresh, err := manager.ValidateRefreshToken(tokenPair.RefreshToken)
+	if err != nil || refresh.SessionID != "sess-1" {
+		t.Fatalf("refresh token sid = %v, %v", refresh, err)
+	}
+}
+
 func TestRefreshTokens(t *testing.T) {
 	manager := NewJWTManager("test-access-secret", "test-refresh-secret")
 
@@ -420,3 +445,19 @@ func BenchmarkValidateAccessToken(b *testing.B) {
 		}
 	}
 }
+
+type sessionData struct{ sid string }
+
+func (d sessionData) LoginSessionID() string { return d.sid }
+
+func TestSessionIDOf(t *testing.T) {
+	if got := SessionIDOf(sessionData{sid: "sess-1"}); got != "sess-1" {
+		t.Errorf("SessionIDOf = %q, want sess-1", got)
+	}
+	if got := SessionIDOf(map[string]any{"session_id": "sess-1"}); got != "" {
+		t.Errorf("auth data without a session must yield \"\", got %q", got)
+	}
+	if got := SessionIDOf(nil); got != "" {
+		t.Errorf("SessionIDOf(nil) = %q", got)
+	}
+}
@@ -0,0 +1,92 @@
+// Package authn provides authentication utilities including password hashing and JWT management
+package authn
+
+import "time"
+
+// LockoutPolicy describes how failed sign-ins slow down and then lock an account or IP
+type LockoutPolicy struct {
+	// MaxAttempts is the number of consecutive failures that locks the key
+	MaxAttempts int
+	// LockoutDuration is how long a lock lasts; failures older than this are forgotten
+	LockoutDuration time.Duration
+	// BaseDelay is the wait required after the second failure; it doubles with each failure
+	BaseDelay time.Duration
+	// MaxDelay caps the progressive delay
+	MaxDelay time.Duration
+}
+
+// DefaultLockoutPolicy mirrors the security.max_login_attempts / security.lockout_duration defaults
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxAttempts:     5,
+	LockoutDuration: 15 * time.Minute,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+}
+
+// FailureState is the failed sign-in record of an account or IP
+type FailureState struct {
+	Failures      int
+	FirstFailedAt time.Time
+	LastFailedAt  time.Time
+	LockedUntil   time.Time // zero when not locked
+	NextAttemptAt time.Time // progressive delay: attempts before this are rejected
+	LockedNow     bool      // set by RecordFailure when this failure caused the lock
+}
+
+// Delay returns the wait required after the given number of consecutive failures: none after
+// the first, then BaseDelay doubling up to MaxDelay
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	if failures < 2 || p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 2; i < failures && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// Check reports whether a sign-in may be attempted now. It returns how long the caller must
+// wait and whether that wait is a lock (as opposed to a progressive delay).
+func (p LockoutPolicy) Check(s FailureState, now time.Time) (wait time.Duration, locked bool) {
+	if !s.LockedUntil.IsZero() && now.Before(s.LockedUntil) {
+		return s.LockedUntil.Sub(now), true
+	}
+	if p.expired(s, now) {
+		return 0, false
+	}
+	if !s.NextAttemptAt.IsZero() && now.Before(s.NextAttemptAt) {
+		return s.NextAttemptAt.Sub(now), false
+	}
+	return 0, false
+}
+
+// RecordFailure returns the state after another failed sign-in. Failures restart from one
+// when the previous ones are older than LockoutDuration or a lock has run out.
+func (p LockoutPolicy) RecordFailure(s FailureState, now time.Time) FailureState {
+	var next FailureState
+	if p.expired(s, now) || (!s.LockedUntil.IsZero() && !now.Before(s.LockedUntil)) {
+		next.Failures = 1
+		next.FirstFailedAt = now
+	} else {
+		next.Failures = s.Failures + 1
+		next.FirstFailedAt = s.FirstFailedAt
+		next.LockedUntil = s.LockedUntil
+	}
+	next.LastFailedAt = now
+	next.NextAttemptAt = now.Add(p.Delay(next.Failures))
+
+	if p.MaxAttempts > 0 && next.Failures >= p.MaxAttempts && next.LockedUntil.IsZero() {
+		next.LockedUntil = now.Add(p.LockoutDuration)
+		next.LockedNow = true
+	}
+	return next
+}
+
+// expired reports whether the recorded failures are too old to count
+func (p LockoutPolicy) expired(s FailureState, now time.Time) bool {
+	return s.Failures == 0 || (p.LockoutDuration > 0 && now.Sub(s.LastFailedAt) > p.LockoutDuration)
+}
@@ -0,0 +1,69 @@
+package authn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDelay(t *testing.T) {
+	p := DefaultLockoutPolicy
+	want := map[int]time.Duration{0: 0, 1: 0, 2: time.Second, 3: 2 * time.Second, 4: 4 * time.Second, 6: 16 * time.Second, 7: 30 * time.Second, 40: 30 * time.Second}
+	for failures, d := range want {
+		if got := p.Delay(failures); got != d {
+			t.Errorf("Delay(%d) = %v, want %v", failures, got, d)
+		}
+	}
+}
+
+func TestLockoutRecordFailure(t *testing.T) {
+	p := LockoutPolicy{MaxAttempts: 3, LockoutDuration: 15 * time.Minute, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var s FailureState
+	s = p.RecordFailure(s, now)
+	if s.Failures != 1 || !s.LockedUntil.IsZero() {
+		t.Fatalf("first failure: %+v", s)
+	}
+	if wait, locked := p.Check(s, now); wait != 0 || locked {
+		t.Errorf("no delay expected after the first failure, got %v locked=%v", wait, locked)
+	}
+
+	now = now.Add(5 * time.Second)
+	s = p.RecordFailure(s, now)
+	if wait, locked := p.Check(s, now); wait != time.Second || locked {
+		t.Errorf("expected a 1s progressive delay, got %v locked=%v", wait, locked)
+	}
+
+	now = now.Add(5 * time.Second)
+	s = p.RecordFailure(s, now)
+	if !s.LockedNow || s.LockedUntil != now.Add(15*time.Minute) {
+		t.Fatalf("third failure should lock: %+v", s)
+	}
+	if wait, locked := p.Check(s, now.Add(time.Minute)); !locked || wait != 14*time.Minute {
+		t.Errorf("expected a 14m lock, got %v locked=%v", wait, locked)
+	}
+
+	// Failing while locked keeps the original lock
+	again := p.RecordFailure(s, now.Add(time.Minute))
+	if again.LockedNow || again.LockedUntil != s.LockedUntil {
+		t.Errorf("a failure during the lock must not extend it: %+v", again)
+	}
+
+	// After the lock runs out the count restarts
+	after := p.RecordFailure(s, s.LockedUntil.Add(time.Second))
+	if after.Failures != 1 || !after.LockedUntil.IsZero() {
+		t.Errorf("count should restart after the lock: %+v", after)
+	}
+}
+
+func TestLockoutForgetsOldFailures(t *testing.T) {
+	p := DefaultLockoutPolicy
+	now := time.Now()
+	s := FailureState{Failures: 4, FirstFailedAt: now.Add(-time.Hour), LastFailedAt: now.Add(-20 * time.Minute), NextAttemptAt: now.Add(time.Hour)}
+	if wait, _ := p.Check(s, now); wait != 0 {
+		t.Errorf("old failures must not delay sign-in, got %v", wait)
+	}
+	if next := p.RecordFailure(s, now); next.Failures != 1 {
+		t.Errorf("old failures must be forgotten, got %d", next.Failures)
+	}
+}
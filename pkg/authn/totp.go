@@ -0,0 +1,210 @@
+// Package authn provides authentication utilities including password hashing and JWT management
+package authn
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238 defaults understood by every authenticator app)
+const (
+	// TOTPPeriod is the time step of a code
+	TOTPPeriod = 30 * time.Second
+	// TOTPDigits is the length of a code
+	TOTPDigits = 6
+	// totpSkew is the number of steps accepted on each side of the current one (clock drift)
+	totpSkew = 1
+	// totpSecretLength is the secret size in bytes (160 bits, as recommended by RFC 4226)
+	totpSecretLength = 20
+	// recoveryCodeLength is the number of characters of a recovery code (without the dash)
+	recoveryCodeLength = 10
+)
+
+var (
+	// ErrInvalidTOTPCode is returned when a TOTP code does not match
+	ErrInvalidTOTPCode = errors.New("invalid totp code")
+	// ErrInvalidSecretBox is returned when an encrypted secret cannot be opened
+	ErrInvalidSecretBox = errors.New("invalid encrypted secret")
+)
+
+// base32 without padding, the form used in otpauth:// URIs
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new random base32 TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, totpSecretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI rendered as a QR code by the enrolment screen
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	q.Set("period", fmt.Sprintf("%d", int(TOTPPeriod.Seconds())))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// TOTPCode returns the code of a secret at the given time
+func TOTPCode(secret string, at time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, totpStep(at), TOTPDigits), nil
+}
+
+// ValidateTOTP checks a code against the secret, allowing one step of clock drift either way.
+// It returns the time step the code belongs to; callers store it and reject steps that are
+// not newer than the last accepted one so a code cannot be replayed.
+func ValidateTOTP(secret, code string, at time.Time) (int64, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, ErrInvalidTOTPCode
+	}
+	current := totpStep(at)
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := current + int64(i)
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step, TOTPDigits)), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+	return 0, ErrInvalidTOTPCode
+}
+
+func totpStep(at time.Time) int64 {
+	return at.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	key, err := totpEncoding.DecodeString(strings.TrimRight(normalized, "="))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("invalid totp secret")
+	}
+	return key, nil
+}
+
+// hotp implements RFC 4226 with HMAC-SHA1 and dynamic truncation
+func hotp(key []byte, counter int64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// recoveryAlphabet avoids characters that are easy to misread (0/O, 1/I/L)
+const recoveryAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// GenerateRecoveryCodes returns n single-use recovery codes formatted as XXXXX-XXXXX
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	buf := make([]byte, recoveryCodeLength)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+		}
+		var sb strings.Builder
+		for j, b := range buf {
+			if j == recoveryCodeLength/2 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+		}
+		codes = append(codes, sb.String())
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode uppercases a recovery code and strips dashes and spaces so codes
+// typed by hand match the stored hash
+func NormalizeRecoveryCode(code string) string {
+	r := strings.NewReplacer("-", "", " ", "")
+	return strings.ToUpper(r.Replace(strings.TrimSpace(code)))
+}
+
+// HashRecoveryCode returns the SHA-256 hex digest stored for a recovery code or OTP. Codes
+// are random and short-lived enough that a fast hash is sufficient.
+func HashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(NormalizeRecoveryCode(code)))
+	return fmt.Sprintf("%x", sum)
+}
+
+// SealSecret encrypts a secret (e.g. a TOTP seed) with AES-GCM under a key derived from
+// keyMaterial. The nonce is prepended and the result is base64 encoded.
+func SealSecret(keyMaterial, plaintext string) (string, error) {
+	aesGCM, err := secretBoxCipher(keyMaterial)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	ciphertext := aesGCM.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// OpenSecret decrypts a value produced by SealSecret
+func OpenSecret(keyMaterial, sealed string) (string, error) {
+	aesGCM, err := secretBoxCipher(keyMaterial)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aesGCM.NonceSize() {
+		return "", ErrInvalidSecretBox
+	}
+	nonce, ciphertext := data[:aesGCM.NonceSize()], data[aesGCM.NonceSize():]
+	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidSecretBox
+	}
+	return string(plaintext), nil
+}
+
+func secretBoxCipher(keyMaterial string) (cipher.AEAD, error) {
+	if keyMaterial == "" {
+		return nil, errors.New("encryption key is not configured")
+	}
+	key := sha256.Sum256([]byte(keyMaterial))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,126 @@
+package authn
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTPRFC6238Vectors(t *testing.T) {
+	// RFC 6238 Appendix B, SHA1 with the ASCII seed "12345678901234567890"
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+	}
+	for _, tt := range tests {
+		got := hotp(key, totpStep(time.Unix(tt.unix, 0)), 8)
+		if got != tt.want {
+			t.Errorf("hotp at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("TOTPCode failed: %v", err)
+	}
+	if code != "081804" {
+		t.Fatalf("TOTPCode = %s, want 081804", code)
+	}
+
+	step, err := ValidateTOTP(secret, code, now)
+	if err != nil {
+		t.Fatalf("ValidateTOTP rejected the current code: %v", err)
+	}
+	if step != totpStep(now) {
+		t.Errorf("step = %d, want %d", step, totpStep(now))
+	}
+
+	// One step of drift either way is accepted
+	if _, err := ValidateTOTP(secret, code, now.Add(TOTPPeriod)); err != nil {
+		t.Errorf("code from the previous step should be accepted: %v", err)
+	}
+	if _, err := ValidateTOTP(secret, code, now.Add(-TOTPPeriod)); err != nil {
+		t.Errorf("code from the next step should be accepted: %v", err)
+	}
+	if _, err := ValidateTOTP(secret, code, now.Add(3*TOTPPeriod)); err != ErrInvalidTOTPCode {
+		t.Errorf("expected ErrInvalidTOTPCode for an old code, got %v", err)
+	}
+	if _, err := ValidateTOTP(secret, "12345", now); err != ErrInvalidTOTPCode {
+		t.Errorf("expected ErrInvalidTOTPCode for a short code, got %v", err)
+	}
+	if _, err := ValidateTOTP("not base32!", code, now); err == nil {
+		t.Error("expected an error for an invalid secret")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret failed: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret length = %d, want 32", len(secret))
+	}
+	if _, err := TOTPCode(secret, time.Now()); err != nil {
+		t.Errorf("generated secret is not usable: %v", err)
+	}
+
+	uri := TOTPProvisioningURI("Loft", "admin@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Loft:admin@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("unexpected provisioning URI: %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' {
+			t.Errorf("unexpected code format: %s", c)
+		}
+		if seen[c] {
+			t.Errorf("duplicate code: %s", c)
+		}
+		seen[c] = true
+	}
+
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+strings.ToLower(strings.ReplaceAll(codes[0], "-", ""))) {
+		t.Error("hash should ignore case, dashes and surrounding spaces")
+	}
+}
+
+func TestSealSecret(t *testing.T) {
+	sealed, err := SealSecret("key-material", "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("SealSecret failed: %v", err)
+	}
+	opened, err := OpenSecret("key-material", sealed)
+	if err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("OpenSecret = %q, %v", opened, err)
+	}
+	if _, err := OpenSecret("other-key", sealed); err != ErrInvalidSecretBox {
+		t.Errorf("expected ErrInvalidSecretBox with the wrong key, got %v", err)
+	}
+	if _, err := SealSecret("", "x"); err == nil {
+		t.Error("expected an error without a key")
+	}
+}
@@ -43,11 +43,26 @@ type SystemSettings struct {
 	MediaWatermarkEnabled  bool     `json:"media.watermark.enabled"`
 	MediaWatermarkPosition string   `json:"media.watermark.position"`
 	MediaWatermarkOpacity  float64  `json:"media.watermark.opacity"`
+	// Storage backends (credentials come from Encore secrets)
+	MediaLocalDir    string `json:"media_local_dir"`
+	MediaS3Endpoint  string `json:"media_s3_endpoint"`
+	MediaS3Region    string `json:"media_s3_region"`
+	MediaS3Bucket    string `json:"media_s3_bucket"`
+	MediaS3PathStyle bool   `json:"media_s3_path_style"`
+	// Responsive variants built by the media worker
+	MediaVariantWidths  []int    `json:"media_variant_widths"`
+	MediaVariantFormats []string `json:"media_variant_formats"`
+	MediaVariantQuality int      `json:"media_variant_quality"`
+	// Video uploads
+	MediaVideoMaxDuration   int      `json:"media_video_max_duration"` // seconds
+	MediaVideoAllowedCodecs []string `json:"media_video_allowed_codecs"`
+	MediaVideoTranscoder    string   `json:"media_video_transcoder"` // local | none
 
 	// App settings
 	AppName                string `json:"app_name"`
 	AppVersion             string `json:"app_version"`
 	AppMaintenanceMode     bool   `json:"app_maintenance_mode"`
+	AppMaintenanceMessage  string `json:"app_maintenance_message"`
 	AppRegistrationEnabled bool   `json:"app_registration_enabled"`
 
 	// Notification settings
@@ -80,15 +95,36 @@ type SystemSettings struct {
 	BidsRateLimitPerMinute   int `json:"bids_rate_limit_per_minute"`
 	PaymentsRateLimitPer5Min int `json:"payments_rate_limit_per_5min"`
 
+	// RateLimits holds per-action limits from ratelimit.<action>.* keys (see RateLimitSetting)
+	RateLimits map[string]RateLimitSetting `json:"rate_limits"`
+
 	// Stock / Cart settings
 	StockCheckoutHoldMinutes   int `json:"stock_checkout_hold_minutes"`
 	StockSuppliesHoldMinutes   int `json:"stock_supplies_hold_minutes"`
 	StockMaxActiveHoldsPerUser int `json:"stock_max_active_holds_per_user"`
 
+	// Seller tax profile (ZATCA invoices)
+	CompanyLegalNameAR string `json:"company_legal_name_ar"`
+	CompanyLegalNameEN string `json:"company_legal_name_en"`
+	CompanyVATNumber   string `json:"company_vat_number"`
+	CompanyCRNumber    string `json:"company_cr_number"`
+	CompanyStreet      string `json:"company_address_street"`
+	CompanyCity        string `json:"company_address_city"`
+	CompanyPostalCode  string `json:"company_postal_code"`
+
 	// Metadata
 	LastUpdated time.Time `json:"last_updated"`
 }
 
+// RateLimitSetting overrides the limits of a rate-limited action. It is read from the
+// ratelimit.<action>.max_attempts, .window_seconds and .block_seconds keys; zero fields keep the
+// limiter's built-in defaults.
+type RateLimitSetting struct {
+	MaxAttempts   int `json:"max_attempts"`
+	WindowSeconds int `json:"window_seconds"`
+	BlockSeconds  int `json:"block_seconds"`
+}
+
 // ChangeListener is called when settings change
 type ChangeListener func(settings *SystemSettings)
 
@@ -201,8 +237,8 @@ func (cm *ConfigManager) populateSettings(settingsMap map[string]string) error {
 
 	// Media settings
 	settings.MediaMaxFileSize = parseInt64(settingsMap["media.max_file_size"], 10485760)
-	settings.MediaAllowedTypes = parseStringSlice(settingsMap["media.allowed_types"], []string{"image/jpeg", "image/png", "image/webp", "video/mp4"})
-	settings.MediaStorageProvider = parseString(settingsMap["media.storage_provider"], "local")
+	settings.MediaAllowedTypes = parseStringSlice(settingsMap["media.allowed_types"], []string{"image/jpeg", "image/png", "image/webp", "video/mp4", "video/quicktime", "video/webm"})
+	settings.MediaStorageProvider = parseString(settingsMap["media.storage_provider"], "gcs")
 	settings.MediaWatermarkEnabled = parseBool(settingsMap["media.watermark.enabled"], true)
 	settings.MediaWatermarkPosition = parseString(settingsMap["media.watermark.position"], "center")
 	// PRD: opacity as 0..100 int
@@ -214,11 +250,23 @@ func (cm *ConfigManager) populateSettings(settingsMap map[string]string) error {
 		_opacityPct = 100
 	}
 	settings.MediaWatermarkOpacity = float64(_opacityPct) / 100.0
+	settings.MediaLocalDir = parseString(settingsMap["media.local.dir"], "data/media")
+	settings.MediaS3Endpoint = parseString(settingsMap["media.s3.endpoint"], "")
+	settings.MediaS3Region = parseString(settingsMap["media.s3.region"], "us-east-1")
+	settings.MediaS3Bucket = parseString(settingsMap["media.s3.bucket"], "")
+	settings.MediaS3PathStyle = parseBool(settingsMap["media.s3.path_style"], true)
+	settings.MediaVariantWidths = parseIntSlice(settingsMap["media.variants.widths"], []int{320, 640, 1024, 1600})
+	settings.MediaVariantFormats = parseStringSlice(settingsMap["media.variants.formats"], []string{"avif", "webp", "jpeg"})
+	settings.MediaVariantQuality = parseInt(settingsMap["media.variants.quality"], 80)
+	settings.MediaVideoMaxDuration = parseInt(settingsMap["media.video.max_duration"], 180)
+	settings.MediaVideoAllowedCodecs = parseStringSlice(settingsMap["media.video.allowed_codecs"], []string{"h264", "hevc", "vp8", "vp9", "av1"})
+	settings.MediaVideoTranscoder = parseString(settingsMap["media.video.transcoder"], "local")
 
 	// App settings
 	settings.AppName = parseString(settingsMap["app.name"], "لوفت الدغيري")
 	settings.AppVersion = parseString(settingsMap["app.version"], "1.0.0")
 	settings.AppMaintenanceMode = parseBool(settingsMap["app.maintenance_mode"], false)
+	settings.AppMaintenanceMessage = parseString(settingsMap["app.maintenance_message"], "")
 	settings.AppRegistrationEnabled = parseBool(settingsMap["app.registration_enabled"], true)
 
 	// Notification settings
@@ -248,12 +296,22 @@ func (cm *ConfigManager) populateSettings(settingsMap map[string]string) error {
 	settings.NotificationsEmailRetention = parseInt(settingsMap["notifications.email.retention_days"], 7)
 	settings.BidsRateLimitPerMinute = parseInt(settingsMap["bids.rate_limit_per_minute"], 60)
 	settings.PaymentsRateLimitPer5Min = parseInt(settingsMap["payments.rate_limit_per_5min"], 5)
+	settings.RateLimits = parseRateLimits(settingsMap)
 
 	// Stock / Cart settings
 	settings.StockCheckoutHoldMinutes = parseInt(settingsMap["stock.checkout_hold_minutes"], 10)
 	settings.StockSuppliesHoldMinutes = parseInt(settingsMap["stock.supplies_hold_minutes"], 15)
 	settings.StockMaxActiveHoldsPerUser = parseInt(settingsMap["stock.max_active_holds_per_user"], 5)
 
+	// Seller tax profile
+	settings.CompanyLegalNameAR = parseString(settingsMap["company.legal_name_ar"], settings.AppName)
+	settings.CompanyLegalNameEN = parseString(settingsMap["company.legal_name_en"], "")
+	settings.CompanyVATNumber = strings.TrimSpace(settingsMap["company.vat_number"])
+	settings.CompanyCRNumber = strings.TrimSpace(settingsMap["company.cr_number"])
+	settings.CompanyStreet = parseString(settingsMap["company.address_street"], "")
+	settings.CompanyCity = parseString(settingsMap["company.address_city"], "")
+	settings.CompanyPostalCode = parseString(settingsMap["company.postal_code"], "")
+
 	settings.LastUpdated = time.Now().UTC()
 
 	// Update settings atomically
@@ -397,6 +455,7 @@ func (cm *ConfigManager) setDefaults() {
 		CORSAllowedHeaders:          []string{"Content-Type", "Authorization", "X-Requested-With"},
 		CORSMaxAge:                  86400,
 		AppName:                     "لوفت الدغيري",
+		CompanyLegalNameAR:          "لوفت الدغيري",
 		AppVersion:                  "1.0.0",
 		AppMaintenanceMode:          false,
 		AppRegistrationEnabled:      true,
@@ -409,6 +468,42 @@ func (cm *ConfigManager) setDefaults() {
 	}
 }
 
+// parseRateLimits collects the ratelimit.<action>.* keys. The older bids.rate_limit_per_minute
+// key still sets the bids action unless overridden.
+func parseRateLimits(settingsMap map[string]string) map[string]RateLimitSetting {
+	limits := map[string]RateLimitSetting{
+		"bids": {MaxAttempts: parseInt(settingsMap["bids.rate_limit_per_minute"], 0), WindowSeconds: 60},
+	}
+	for key, value := range settingsMap {
+		rest, ok := strings.CutPrefix(key, "ratelimit.")
+		if !ok {
+			continue
+		}
+		dot := strings.LastIndex(rest, ".")
+		if dot <= 0 {
+			continue
+		}
+		action, field := rest[:dot], rest[dot+1:]
+		n := parseInt(value, 0)
+		if n < 0 {
+			continue
+		}
+		l := limits[action]
+		switch field {
+		case "max_attempts":
+			l.MaxAttempts = n
+		case "window_seconds":
+			l.WindowSeconds = n
+		case "block_seconds":
+			l.BlockSeconds = n
+		default:
+			continue
+		}
+		limits[action] = l
+	}
+	return limits
+}
+
 // Helper parsing functions
 func parseBool(value string, defaultValue bool) bool {
 	if value == "" {
@@ -484,6 +579,19 @@ func parseStringSlice(value string, defaultValue []string) []string {
 	return result
 }
 
+func parseIntSlice(value string, defaultValue []int) []int {
+	var result []int
+	for _, part := range parseStringSlice(value, nil) {
+		if n, err := strconv.Atoi(part); err == nil && n > 0 {
+			result = append(result, n)
+		}
+	}
+	if len(result) == 0 {
+		return defaultValue
+	}
+	return result
+}
+
 // Global config manager instance
 var (
 	globalManager *ConfigManager
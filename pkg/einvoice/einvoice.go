@@ -0,0 +1,155 @@
+// Package einvoice builds Saudi simplified tax invoices (ZATCA e-invoicing, phase 1): the
+// TLV-encoded QR payload, a UBL 2.1 XML document and a bilingual Arabic/English PDF.
+//
+// Amounts coming from orders are VAT-inclusive (gross). VAT per line is extracted the same
+// way the calculate_order_totals trigger does it, so invoice totals match the order.
+package einvoice
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"encore.app/pkg/moneysar"
+)
+
+var (
+	// ErrSellerVATNumber is returned when the seller VAT number is missing or malformed
+	ErrSellerVATNumber = errors.New("einvoice: seller VAT number must be 15 digits starting and ending with 3")
+	// ErrNoLines is returned for an invoice without lines
+	ErrNoLines = errors.New("einvoice: invoice has no lines")
+)
+
+var vatNumberRe = regexp.MustCompile(`^3[0-9]{13}3$`)
+
+// saudiTime is used for the printed/XML issue date; KSA has no daylight saving
+var saudiTime = time.FixedZone("AST", 3*60*60)
+
+// Party is the seller or the buyer
+type Party struct {
+	NameAR     string
+	NameEN     string
+	VATNumber  string
+	CRNumber   string // commercial registration
+	Street     string
+	City       string
+	PostalCode string
+	Country    string // ISO 3166-1 alpha-2, defaults to SA
+}
+
+// Name returns the Arabic name, falling back to the English one
+func (p Party) Name() string {
+	if strings.TrimSpace(p.NameAR) != "" {
+		return p.NameAR
+	}
+	return p.NameEN
+}
+
+// Line is an invoiced item; prices are VAT-inclusive
+type Line struct {
+	Description string
+	Qty         int
+	UnitGross   float64
+	LineGross   float64
+}
+
+// Invoice is everything needed to render the tax invoice documents
+type Invoice struct {
+	Counter       int64  // invoice counter value (ICV)
+	Number        string // from next_invoice_number
+	UUID          string // derived from Number when empty
+	IssuedAt      time.Time
+	Currency      string  // defaults to SAR
+	VATRate       float64 // snapshot, e.g. 0.15
+	Seller        Party
+	Buyer         Party
+	Lines         []Line
+	ShippingGross float64
+}
+
+// LineTotals is a line with its VAT split out
+type LineTotals struct {
+	Line
+	UnitNet float64
+	Net     float64
+	VAT     float64
+}
+
+// Totals is the VAT breakdown of an invoice
+type Totals struct {
+	Lines []LineTotals
+	Net   float64
+	VAT   float64
+	Gross float64
+}
+
+// ValidVATNumber reports whether s looks like a Saudi VAT registration number
+func ValidVATNumber(s string) bool {
+	return vatNumberRe.MatchString(s)
+}
+
+// Validate checks the fields ZATCA requires on a simplified tax invoice
+func (inv *Invoice) Validate() error {
+	if !ValidVATNumber(inv.Seller.VATNumber) {
+		return ErrSellerVATNumber
+	}
+	if strings.TrimSpace(inv.Number) == "" {
+		return errors.New("einvoice: invoice number is required")
+	}
+	if len(inv.Lines) == 0 {
+		return ErrNoLines
+	}
+	return nil
+}
+
+// Totals splits VAT out of each line (shipping is billed as its own line)
+func (inv *Invoice) Totals() Totals {
+	lines := inv.Lines
+	if inv.ShippingGross > 0 {
+		lines = append(append([]Line(nil), lines...), Line{
+			Description: "الشحن / Shipping",
+			Qty:         1,
+			UnitGross:   inv.ShippingGross,
+			LineGross:   inv.ShippingGross,
+		})
+	}
+	var t Totals
+	for _, l := range lines {
+		lt := LineTotals{Line: l}
+		lt.VAT = moneysar.RoundHalfUp(l.LineGross*inv.VATRate/(1+inv.VATRate), 2)
+		lt.Net = moneysar.RoundHalfUp(l.LineGross-lt.VAT, 2)
+		if l.Qty > 0 {
+			lt.UnitNet = moneysar.RoundHalfUp(lt.Net/float64(l.Qty), 2)
+		}
+		t.Lines = append(t.Lines, lt)
+		t.Net += lt.Net
+		t.VAT += lt.VAT
+		t.Gross += l.LineGross
+	}
+	t.Net = moneysar.RoundHalfUp(t.Net, 2)
+	t.VAT = moneysar.RoundHalfUp(t.VAT, 2)
+	t.Gross = moneysar.RoundHalfUp(t.Gross, 2)
+	return t
+}
+
+func (inv *Invoice) currency() string {
+	if inv.Currency == "" {
+		return "SAR"
+	}
+	return inv.Currency
+}
+
+func (inv *Invoice) uuid() string {
+	if inv.UUID != "" {
+		return inv.UUID
+	}
+	// Stable across downloads so the XML for an invoice never changes
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("invoice:"+inv.Number)).String()
+}
+
+func (inv *Invoice) localIssueTime() time.Time {
+	return inv.IssuedAt.In(saudiTime)
+}
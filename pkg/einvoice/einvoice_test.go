@@ -0,0 +1,163 @@
+package einvoice
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleInvoice() *Invoice {
+	return &Invoice{
+		Counter:  42,
+		Number:   "INV-2025-000042",
+		IssuedAt: time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC),
+		VATRate:  0.15,
+		Seller: Party{
+			NameAR:    "لوفت الدغيري",
+			NameEN:    "Loft Dughairi",
+			VATNumber: "310122393500003",
+			CRNumber:  "1010010000",
+			City:      "الرياض",
+		},
+		Buyer: Party{NameAR: "محمد أحمد"},
+		Lines: []Line{
+			{Description: "حمام زاجل (Racing pigeon)", Qty: 1, UnitGross: 115, LineGross: 115},
+			{Description: "Feed", Qty: 2, UnitGross: 57.5, LineGross: 115},
+		},
+		ShippingGross: 23,
+	}
+}
+
+func TestTotals(t *testing.T) {
+	tot := sampleInvoice().Totals()
+	if len(tot.Lines) != 3 {
+		t.Fatalf("want shipping as a third line, got %d lines", len(tot.Lines))
+	}
+	if tot.Net != 220 || tot.VAT != 33 || tot.Gross != 253 {
+		t.Fatalf("totals = %+v", tot)
+	}
+	if l := tot.Lines[1]; l.Net != 100 || l.VAT != 15 || l.UnitNet != 50 {
+		t.Fatalf("line 2 = %+v", l)
+	}
+
+	zero := sampleInvoice()
+	zero.VATRate = 0
+	if tot := zero.Totals(); tot.VAT != 0 || tot.Net != tot.Gross {
+		t.Fatalf("zero-rated totals = %+v", tot)
+	}
+}
+
+func TestTLV(t *testing.T) {
+	inv := &Invoice{
+		Number:   "INV-1",
+		IssuedAt: time.Date(2022, 4, 25, 15, 30, 0, 0, time.UTC),
+		VATRate:  0.15,
+		Seller:   Party{NameEN: "Bobs Records", VATNumber: "310122393500003"},
+		Lines:    []Line{{Description: "x", Qty: 1, UnitGross: 1150, LineGross: 1150}},
+	}
+	var want []byte
+	for i, v := range []string{"Bobs Records", "310122393500003", "2022-04-25T15:30:00Z", "1150.00", "150.00"} {
+		want = append(want, byte(i+1), byte(len(v)))
+		want = append(want, v...)
+	}
+	got, err := inv.TLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("TLV = %x, want %x", got, want)
+	}
+	qr, _ := inv.QRPayload()
+	if qr != base64.StdEncoding.EncodeToString(want) {
+		t.Fatalf("QRPayload = %s", qr)
+	}
+
+	// Arabic names are counted in bytes, not runes
+	inv.Seller.NameAR = "لوفت"
+	got, _ = inv.TLV()
+	if got[0] != 1 || got[1] != 8 || string(got[2:10]) != "لوفت" {
+		t.Fatalf("Arabic seller tag = %x", got[:10])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	inv := sampleInvoice()
+	for _, vat := range []string{"", "31012239350000", "110122393500003", "31012239350000X"} {
+		inv.Seller.VATNumber = vat
+		if err := inv.Validate(); !errors.Is(err, ErrSellerVATNumber) {
+			t.Errorf("VAT %q: got %v", vat, err)
+		}
+	}
+	inv = sampleInvoice()
+	inv.Lines = nil
+	if err := inv.Validate(); !errors.Is(err, ErrNoLines) {
+		t.Errorf("no lines: got %v", err)
+	}
+}
+
+func TestUBL(t *testing.T) {
+	inv := sampleInvoice()
+	out, err := inv.UBL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(out)
+	qr, _ := inv.QRPayload()
+	for _, want := range []string{
+		`xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"`,
+		`xmlns:cbc="urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"`,
+		`<cbc:ID>INV-2025-000042</cbc:ID>`,
+		`<cbc:IssueDate>2025-03-01</cbc:IssueDate>`,
+		`<cbc:IssueTime>12:30:00</cbc:IssueTime>`,
+		`<cbc:InvoiceTypeCode name="0200000">388</cbc:InvoiceTypeCode>`,
+		`<cbc:EmbeddedDocumentBinaryObject mimeCode="text/plain">` + qr + `</cbc:EmbeddedDocumentBinaryObject>`,
+		`<cbc:CompanyID>310122393500003</cbc:CompanyID>`,
+		`<cbc:TaxInclusiveAmount currencyID="SAR">253.00</cbc:TaxInclusiveAmount>`,
+		`<cbc:Percent>15.00</cbc:Percent>`,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("XML is missing %s", want)
+		}
+	}
+	if err := xml.Unmarshal(out, new(struct{})); err != nil {
+		t.Fatalf("XML is not well-formed: %v", err)
+	}
+
+	// Same invoice, same document (stable UUID)
+	again, _ := sampleInvoice().UBL()
+	if !bytes.Equal(out, again) {
+		t.Error("UBL output is not deterministic")
+	}
+}
+
+func TestPDF(t *testing.T) {
+	font, err := os.ReadFile("../../assets/fonts/Tajawal-Regular.ttf")
+	if err != nil {
+		t.Skip("font not available")
+	}
+	inv := sampleInvoice()
+	for i := 0; i < 40; i++ { // force a second page
+		inv.Lines = append(inv.Lines, Line{Description: "مستلزمات", Qty: 1, UnitGross: 11.5, LineGross: 11.5})
+	}
+	out, err := inv.PDF(font)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-1.7")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("not a PDF")
+	}
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Error("expected two pages")
+	}
+	if !bytes.Contains(out, []byte("/FontFile2")) {
+		t.Error("font is not embedded")
+	}
+	if _, err := inv.PDF([]byte("not a font")); err == nil {
+		t.Error("invalid font should fail")
+	}
+}
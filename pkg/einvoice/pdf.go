@@ -0,0 +1,438 @@
+package einvoice
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"encore.app/pkg/arabic"
+	"encore.app/pkg/qrcode"
+)
+
+// PDF writer: a single embedded TrueType font (Type0/Identity-H, so glyph IDs are written
+// directly and Arabic presentation forms work), vector QR and simple table drawing on A4.
+
+const (
+	pageW, pageH = 595.0, 842.0
+	margin       = 40.0
+	footerY      = 40.0
+)
+
+// pdfFont measures and encodes text with an sfnt font
+type pdfFont struct {
+	f      *sfnt.Font
+	buf    sfnt.Buffer
+	raw    []byte
+	upem   float64
+	widths map[sfnt.GlyphIndex]int  // in 1/1000 em
+	uni    map[sfnt.GlyphIndex]rune // for the ToUnicode map
+}
+
+func newPDFFont(raw []byte) (*pdfFont, error) {
+	f, err := sfnt.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("einvoice: parse font: %w", err)
+	}
+	return &pdfFont{
+		f:      f,
+		raw:    raw,
+		upem:   float64(f.UnitsPerEm()),
+		widths: map[sfnt.GlyphIndex]int{},
+		uni:    map[sfnt.GlyphIndex]rune{},
+	}, nil
+}
+
+func (pf *pdfFont) glyph(r rune) sfnt.GlyphIndex {
+	g, err := pf.f.GlyphIndex(&pf.buf, r)
+	if (err != nil || g == 0) && arabic.Base(r) != 0 {
+		g, err = pf.f.GlyphIndex(&pf.buf, arabic.Base(r))
+	}
+	if err != nil {
+		return 0
+	}
+	if _, ok := pf.widths[g]; !ok {
+		adv, err := pf.f.GlyphAdvance(&pf.buf, g, fixed.I(int(pf.upem)), font.HintingNone)
+		if err != nil {
+			adv = 0
+		}
+		pf.widths[g] = int(float64(adv.Round()) * 1000 / pf.upem)
+		pf.uni[g] = r
+	}
+	return g
+}
+
+// encode returns the hex glyph string for s in visual order and its width in 1/1000 em
+func (pf *pdfFont) encode(s string) (string, float64) {
+	var sb strings.Builder
+	w := 0.0
+	for _, r := range arabic.Visual(s) {
+		g := pf.glyph(r)
+		fmt.Fprintf(&sb, "%04X", uint16(g))
+		w += float64(pf.widths[g])
+	}
+	return sb.String(), w
+}
+
+func (pf *pdfFont) width(s string, size float64) float64 {
+	_, w := pf.encode(s)
+	return w * size / 1000
+}
+
+// fit shortens s with a trailing "..." until it is at most maxW points wide
+func (pf *pdfFont) fit(s string, size, maxW float64) string {
+	if pf.width(s, size) <= maxW {
+		return s
+	}
+	rs := []rune(s)
+	for len(rs) > 0 {
+		rs = rs[:len(rs)-1]
+		if t := strings.TrimSpace(string(rs)) + "..."; pf.width(t, size) <= maxW {
+			return t
+		}
+	}
+	return ""
+}
+
+type align int
+
+const (
+	alignLeft align = iota
+	alignCenter
+	alignRight
+)
+
+// page accumulates a content stream
+type page struct {
+	font *pdfFont
+	buf  bytes.Buffer
+}
+
+func (p *page) text(x, y, size float64, s string, a align) {
+	if strings.TrimSpace(s) == "" {
+		return
+	}
+	hex, w := p.font.encode(s)
+	w = w * size / 1000
+	switch a {
+	case alignCenter:
+		x -= w / 2
+	case alignRight:
+		x -= w
+	}
+	fmt.Fprintf(&p.buf, "BT /F1 %.2f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, y, hex)
+}
+
+func (p *page) fill(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.buf, "%.2f g %.2f %.2f %.2f %.2f re f 0 g\n", gray, x, y, w, h)
+}
+
+func (p *page) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.buf, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+func (p *page) qr(c *qrcode.Code, x, y, size float64) {
+	quiet := 2 // modules of white border
+	m := size / float64(c.Size+2*quiet)
+	for row := 0; row < c.Size; row++ {
+		for col := 0; col < c.Size; col++ {
+			if c.Dark(col, row) {
+				fmt.Fprintf(&p.buf, "%.3f %.3f %.3f %.3f re ", x+float64(col+quiet)*m, y+size-float64(row+quiet+1)*m, m, m)
+			}
+		}
+	}
+	p.buf.WriteString("f\n")
+}
+
+// table columns, left to right
+var columns = []struct {
+	ar, en string
+	x, w   float64
+}{
+	{"#", "#", margin, 20},
+	{"الوصف", "Description", margin + 20, 205},
+	{"الكمية", "Qty", margin + 225, 40},
+	{"سعر الوحدة", "Unit price", margin + 265, 65},
+	{"المبلغ الخاضع", "Taxable", margin + 330, 65},
+	{"الضريبة", "VAT", margin + 395, 55},
+	{"الإجمالي", "Total", margin + 450, 65},
+}
+
+// PDF renders the bilingual invoice. fontData must be a TrueType font with Arabic glyphs.
+func (inv *Invoice) PDF(fontData []byte) ([]byte, error) {
+	if err := inv.Validate(); err != nil {
+		return nil, err
+	}
+	pf, err := newPDFFont(fontData)
+	if err != nil {
+		return nil, err
+	}
+	qrText, err := inv.QRPayload()
+	if err != nil {
+		return nil, err
+	}
+	code, err := qrcode.Encode([]byte(qrText))
+	if err != nil {
+		return nil, err
+	}
+	t := inv.Totals()
+	money := func(v float64) string { return fmt.Sprintf("%.2f", v) }
+	right := pageW - margin
+
+	var pages []*page
+	newPage := func() *page {
+		p := &page{font: pf}
+		pages = append(pages, p)
+		p.text(pageW/2, footerY, 8, "فاتورة ضريبية مبسطة صادرة إلكترونياً - This is an electronically issued simplified tax invoice", alignCenter)
+		return p
+	}
+	p := newPage()
+
+	// Seller header
+	y := pageH - margin - 16
+	p.text(right, y, 16, inv.Seller.NameAR, alignRight)
+	p.text(margin, y, 14, inv.Seller.NameEN, alignLeft)
+	y -= 18
+	p.text(right, y, 9, "الرقم الضريبي: "+inv.Seller.VATNumber, alignRight)
+	p.text(margin, y, 9, "VAT No.: "+inv.Seller.VATNumber, alignLeft)
+	if inv.Seller.CRNumber != "" {
+		y -= 13
+		p.text(right, y, 9, "السجل التجاري: "+inv.Seller.CRNumber, alignRight)
+		p.text(margin, y, 9, "CR No.: "+inv.Seller.CRNumber, alignLeft)
+	}
+	if addr := joinNonEmpty(", ", inv.Seller.Street, inv.Seller.City, inv.Seller.PostalCode); addr != "" {
+		y -= 13
+		p.text(pageW/2, y, 9, addr, alignCenter)
+	}
+	y -= 12
+	p.line(margin, y, right, y)
+
+	// Title
+	y -= 24
+	p.text(pageW/2, y, 16, "فاتورة ضريبية مبسطة", alignCenter)
+	y -= 16
+	p.text(pageW/2, y, 11, "Simplified Tax Invoice", alignCenter)
+
+	// Invoice details: English label | value | Arabic label
+	issued := inv.localIssueTime()
+	details := [][3]string{
+		{"Invoice No.", inv.Number, "رقم الفاتورة"},
+		{"Issue date", issued.Format("2006-01-02 15:04"), "تاريخ الإصدار"},
+		{"Customer", inv.Buyer.Name(), "العميل"},
+		{"Currency", inv.currency(), "العملة"},
+	}
+	y -= 14
+	for _, d := range details {
+		y -= 15
+		p.text(margin, y, 9, d[0], alignLeft)
+		p.text(pageW/2, y, 10, pf.fit(d[1], 10, 260), alignCenter)
+		p.text(right, y, 9, d[2], alignRight)
+	}
+
+	// Lines table
+	tableHeader := func(p *page, y float64) float64 {
+		p.fill(margin, y-30, right-margin, 30, 0.9)
+		for _, c := range columns {
+			p.text(c.x+c.w/2, y-12, 8, c.ar, alignCenter)
+			p.text(c.x+c.w/2, y-24, 7, c.en, alignCenter)
+		}
+		return y - 30
+	}
+	y -= 22
+	y = tableHeader(p, y)
+	for i, l := range t.Lines {
+		if y-18 < footerY+30 {
+			p = newPage()
+			y = tableHeader(p, pageH-margin)
+		}
+		y -= 18
+		cells := []string{fmt.Sprint(i + 1), l.Description, fmt.Sprint(l.Qty), money(l.UnitNet), money(l.Net), money(l.VAT), money(l.LineGross)}
+		for ci, c := range columns {
+			s := cells[ci]
+			if ci == 1 {
+				s = pf.fit(s, 9, c.w-6)
+				p.text(c.x+c.w-3, y+5, 9, s, alignRight)
+				continue
+			}
+			p.text(c.x+c.w/2, y+5, 9, s, alignCenter)
+		}
+		p.line(margin, y, right, y)
+	}
+
+	// Totals with the QR code beside them
+	const qrSize = 120.0
+	if y-qrSize-20 < footerY+20 {
+		p = newPage()
+		y = pageH - margin
+	}
+	y -= 16
+	p.qr(code, margin, y-qrSize, qrSize)
+	vatPct := fmt.Sprintf("%.0f%%", inv.VATRate*100)
+	totals := [][3]string{
+		{"Total excl. VAT", money(t.Net), "الإجمالي غير شامل الضريبة"},
+		{"VAT " + vatPct, money(t.VAT), "ضريبة القيمة المضافة " + vatPct},
+		{"Total incl. VAT", money(t.Gross), "الإجمالي شامل الضريبة"},
+	}
+	for i, row := range totals {
+		y -= 18
+		size := 9.0
+		if i == len(totals)-1 {
+			size = 11
+			p.fill(margin+qrSize+20, y-5, right-margin-qrSize-20, 18, 0.9)
+		}
+		p.text(margin+qrSize+30, y, size, row[0], alignLeft)
+		p.text(margin+qrSize+190, y, size, row[1]+" "+inv.currency(), alignCenter)
+		p.text(right-5, y, size, row[2], alignRight)
+	}
+
+	return writePDF(pf, pages, inv.Number)
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var out []string
+	for _, s := range parts {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return strings.Join(out, sep)
+}
+
+// writePDF serialises the pages, the embedded font and the cross-reference table
+func writePDF(pf *pdfFont, pages []*page, title string) ([]byte, error) {
+	var objs [][]byte
+	add := func(s string) int {
+		objs = append(objs, []byte(s))
+		return len(objs)
+	}
+	stream := func(dict string, data []byte) (int, error) {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		if _, err := w.Write(data); err != nil {
+			return 0, err
+		}
+		if err := w.Close(); err != nil {
+			return 0, err
+		}
+		return add(fmt.Sprintf("<< %s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", dict, z.Len(), z.Bytes())), nil
+	}
+
+	catalog := add("") // filled in once the page tree exists
+	pagesObj := add("")
+
+	fontFile, err := stream(fmt.Sprintf("/Length1 %d", len(pf.raw)), pf.raw)
+	if err != nil {
+		return nil, err
+	}
+	var fb sfnt.Buffer
+	upem := fixed.I(int(pf.upem))
+	bounds, err := pf.f.Bounds(&fb, upem, font.HintingNone)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := pf.f.Metrics(&fb, upem, font.HintingNone)
+	if err != nil {
+		return nil, err
+	}
+	scale := func(v fixed.Int26_6) int { return int(float64(v.Round()) * 1000 / pf.upem) }
+	name := "Font"
+	if n, err := pf.f.Name(&fb, sfnt.NameIDPostScript); err == nil && n != "" {
+		name = strings.ReplaceAll(n, " ", "")
+	}
+	descriptor := add(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, scale(bounds.Min.X), -scale(bounds.Max.Y), scale(bounds.Max.X), -scale(bounds.Min.Y),
+		scale(metrics.Ascent), -scale(metrics.Descent), scale(metrics.Ascent), fontFile))
+
+	gids := make([]int, 0, len(pf.widths))
+	for g := range pf.widths {
+		gids = append(gids, int(g))
+	}
+	sort.Ints(gids)
+	var w, cmap strings.Builder
+	for _, g := range gids {
+		fmt.Fprintf(&w, "%d [%d] ", g, pf.widths[sfnt.GlyphIndex(g)])
+	}
+	cid := add(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap /Identity >>",
+		name, descriptor, w.String()))
+
+	cmap.WriteString("/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n/CMapName /Adobe-Identity-UCS def /CMapType 2 def\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n")
+	for i := 0; i < len(gids); i += 100 {
+		chunk := gids[i:min(i+100, len(gids))]
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", len(chunk))
+		for _, g := range chunk {
+			r := pf.uni[sfnt.GlyphIndex(g)]
+			var u []string
+			for _, c := range utf16Units(r) {
+				u = append(u, fmt.Sprintf("%04X", c))
+			}
+			fmt.Fprintf(&cmap, "<%04X> <%s>\n", g, strings.Join(u, ""))
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap CMapName currentdict /CMap defineresource pop end end")
+	toUnicode, err := stream("", []byte(cmap.String()))
+	if err != nil {
+		return nil, err
+	}
+	font0 := add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", name, cid, toUnicode))
+
+	var kids []string
+	for _, p := range pages {
+		content, err := stream("", p.buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		pg := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesObj, pageW, pageH, font0, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", pg))
+	}
+	objs[pagesObj-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	objs[catalog-1] = []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+	info := add(fmt.Sprintf("<< /Title (%s) /Producer (einvoice) >>", pdfEscape(title)))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xE2\xE3\xCF\xD3\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(o)
+		out.WriteString("\nendobj\n")
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, catalog, info, xref)
+	return out.Bytes(), nil
+}
+
+func utf16Units(r rune) []uint16 {
+	if r < 0x10000 {
+		return []uint16{uint16(r)}
+	}
+	r -= 0x10000
+	return []uint16{uint16(0xD800 + (r >> 10)), uint16(0xDC00 + (r & 0x3FF))}
+}
+
+func pdfEscape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20 || r > 0x7E:
+			sb.WriteByte('?') // Info strings stay ASCII; the number is ASCII anyway
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,49 @@
+package einvoice
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// ZATCA phase 1 QR tags
+const (
+	tagSellerName = 1
+	tagVATNumber  = 2
+	tagTimestamp  = 3
+	tagTotal      = 4
+	tagVATTotal   = 5
+)
+
+// TLV encodes the phase 1 QR fields: seller name, VAT number, timestamp, total with VAT
+// and VAT total. Each field is tag (1 byte), length (1 byte), UTF-8 value.
+func (inv *Invoice) TLV() ([]byte, error) {
+	t := inv.Totals()
+	fields := []struct {
+		tag   byte
+		value string
+	}{
+		{tagSellerName, inv.Seller.Name()},
+		{tagVATNumber, inv.Seller.VATNumber},
+		{tagTimestamp, inv.IssuedAt.UTC().Format("2006-01-02T15:04:05Z")},
+		{tagTotal, fmt.Sprintf("%.2f", t.Gross)},
+		{tagVATTotal, fmt.Sprintf("%.2f", t.VAT)},
+	}
+	var out []byte
+	for _, f := range fields {
+		if len(f.value) > 255 {
+			return nil, fmt.Errorf("einvoice: QR tag %d is longer than 255 bytes", f.tag)
+		}
+		out = append(out, f.tag, byte(len(f.value)))
+		out = append(out, f.value...)
+	}
+	return out, nil
+}
+
+// QRPayload is the base64 TLV string that goes into the QR code and the XML
+func (inv *Invoice) QRPayload() (string, error) {
+	tlv, err := inv.TLV()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(tlv), nil
+}
@@ -0,0 +1,233 @@
+package einvoice
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// UBL 2.1 document following the ZATCA simplified tax invoice profile. encoding/xml has no
+// prefix support, so element names carry their cbc:/cac: prefix literally and the root
+// declares the namespaces.
+
+const (
+	invoiceTypeCode       = "388"     // tax invoice
+	invoiceTypeSimplified = "0200000" // simplified (B2C)
+	profileID             = "reporting:1.0"
+)
+
+type ublAmount struct {
+	Currency string `xml:"currencyID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ublInvoice struct {
+	XMLName   xml.Name `xml:"Invoice"`
+	Xmlns     string   `xml:"xmlns,attr"`
+	XmlnsCac  string   `xml:"xmlns:cac,attr"`
+	XmlnsCbc  string   `xml:"xmlns:cbc,attr"`
+	ProfileID string   `xml:"cbc:ProfileID"`
+	ID        string   `xml:"cbc:ID"`
+	UUID      string   `xml:"cbc:UUID"`
+	IssueDate string   `xml:"cbc:IssueDate"`
+	IssueTime string   `xml:"cbc:IssueTime"`
+	TypeCode  struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"cbc:InvoiceTypeCode"`
+	DocumentCurrencyCode string           `xml:"cbc:DocumentCurrencyCode"`
+	TaxCurrencyCode      string           `xml:"cbc:TaxCurrencyCode"`
+	References           []ublDocRef      `xml:"cac:AdditionalDocumentReference"`
+	Supplier             ublParty         `xml:"cac:AccountingSupplierParty>cac:Party"`
+	Customer             ublParty         `xml:"cac:AccountingCustomerParty>cac:Party"`
+	TaxTotals            []ublTaxTotal    `xml:"cac:TaxTotal"`
+	MonetaryTotal        ublMonetaryTotal `xml:"cac:LegalMonetaryTotal"`
+	Lines                []ublLine        `xml:"cac:InvoiceLine"`
+}
+
+type ublDocRef struct {
+	ID         string         `xml:"cbc:ID"`
+	UUID       string         `xml:"cbc:UUID,omitempty"`
+	Attachment *ublAttachment `xml:"cac:Attachment,omitempty"`
+}
+
+type ublAttachment struct {
+	Object struct {
+		MimeCode string `xml:"mimeCode,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"cbc:EmbeddedDocumentBinaryObject"`
+}
+
+type ublParty struct {
+	Identification *ublPartyID  `xml:"cac:PartyIdentification,omitempty"`
+	Address        *ublAddress  `xml:"cac:PostalAddress,omitempty"`
+	TaxScheme      *ublPartyTax `xml:"cac:PartyTaxScheme,omitempty"`
+	LegalName      string       `xml:"cac:PartyLegalEntity>cbc:RegistrationName"`
+}
+
+type ublPartyID struct {
+	ID struct {
+		SchemeID string `xml:"schemeID,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"cbc:ID"`
+}
+
+type ublAddress struct {
+	Street     string `xml:"cbc:StreetName,omitempty"`
+	City       string `xml:"cbc:CityName,omitempty"`
+	PostalZone string `xml:"cbc:PostalZone,omitempty"`
+	Country    string `xml:"cac:Country>cbc:IdentificationCode"`
+}
+
+type ublPartyTax struct {
+	CompanyID string `xml:"cbc:CompanyID"`
+	Scheme    string `xml:"cac:TaxScheme>cbc:ID"`
+}
+
+type ublTaxCategory struct {
+	ID                  string `xml:"cbc:ID"`
+	Percent             string `xml:"cbc:Percent"`
+	ExemptionReasonCode string `xml:"cbc:TaxExemptionReasonCode,omitempty"`
+	ExemptionReason     string `xml:"cbc:TaxExemptionReason,omitempty"`
+	Scheme              string `xml:"cac:TaxScheme>cbc:ID"`
+}
+
+type ublTaxSubtotal struct {
+	TaxableAmount ublAmount      `xml:"cbc:TaxableAmount"`
+	TaxAmount     ublAmount      `xml:"cbc:TaxAmount"`
+	Category      ublTaxCategory `xml:"cac:TaxCategory"`
+}
+
+type ublTaxTotal struct {
+	TaxAmount ublAmount        `xml:"cbc:TaxAmount"`
+	Subtotals []ublTaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type ublMonetaryTotal struct {
+	LineExtension ublAmount `xml:"cbc:LineExtensionAmount"`
+	TaxExclusive  ublAmount `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusive  ublAmount `xml:"cbc:TaxInclusiveAmount"`
+	Payable       ublAmount `xml:"cbc:PayableAmount"`
+}
+
+type ublLine struct {
+	ID       string `xml:"cbc:ID"`
+	Quantity struct {
+		UnitCode string `xml:"unitCode,attr"`
+		Value    int    `xml:",chardata"`
+	} `xml:"cbc:InvoicedQuantity"`
+	LineExtension ublAmount `xml:"cbc:LineExtensionAmount"`
+	TaxTotal      struct {
+		TaxAmount ublAmount `xml:"cbc:TaxAmount"`
+		Rounding  ublAmount `xml:"cbc:RoundingAmount"`
+	} `xml:"cac:TaxTotal"`
+	Item struct {
+		Name     string         `xml:"cbc:Name"`
+		Category ublTaxCategory `xml:"cac:ClassifiedTaxCategory"`
+	} `xml:"cac:Item"`
+	Price ublAmount `xml:"cac:Price>cbc:PriceAmount"`
+}
+
+// UBL renders the invoice as a UBL 2.1 XML document with the QR payload embedded
+func (inv *Invoice) UBL() ([]byte, error) {
+	if err := inv.Validate(); err != nil {
+		return nil, err
+	}
+	qr, err := inv.QRPayload()
+	if err != nil {
+		return nil, err
+	}
+	cur := inv.currency()
+	amt := func(v float64) ublAmount { return ublAmount{Currency: cur, Value: fmt.Sprintf("%.2f", v)} }
+	cat := inv.taxCategory()
+	t := inv.Totals()
+	issued := inv.localIssueTime()
+
+	doc := ublInvoice{
+		Xmlns:                "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2",
+		XmlnsCac:             "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
+		XmlnsCbc:             "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
+		ProfileID:            profileID,
+		ID:                   inv.Number,
+		UUID:                 inv.uuid(),
+		IssueDate:            issued.Format("2006-01-02"),
+		IssueTime:            issued.Format("15:04:05"),
+		DocumentCurrencyCode: cur,
+		TaxCurrencyCode:      cur,
+	}
+	doc.TypeCode.Name = invoiceTypeSimplified
+	doc.TypeCode.Value = invoiceTypeCode
+
+	qrRef := ublDocRef{ID: "QR", Attachment: &ublAttachment{}}
+	qrRef.Attachment.Object.MimeCode = "text/plain"
+	qrRef.Attachment.Object.Value = qr
+	doc.References = []ublDocRef{{ID: "ICV", UUID: fmt.Sprint(inv.Counter)}, qrRef}
+
+	doc.Supplier = inv.party(inv.Seller, true)
+	doc.Customer = inv.party(inv.Buyer, false)
+
+	doc.TaxTotals = []ublTaxTotal{
+		{TaxAmount: amt(t.VAT), Subtotals: []ublTaxSubtotal{{TaxableAmount: amt(t.Net), TaxAmount: amt(t.VAT), Category: cat}}},
+		{TaxAmount: amt(t.VAT)}, // in tax currency
+	}
+	doc.MonetaryTotal = ublMonetaryTotal{
+		LineExtension: amt(t.Net),
+		TaxExclusive:  amt(t.Net),
+		TaxInclusive:  amt(t.Gross),
+		Payable:       amt(t.Gross),
+	}
+	for i, l := range t.Lines {
+		var ul ublLine
+		ul.ID = fmt.Sprint(i + 1)
+		ul.Quantity.UnitCode = "PCE"
+		ul.Quantity.Value = l.Qty
+		ul.LineExtension = amt(l.Net)
+		ul.TaxTotal.TaxAmount = amt(l.VAT)
+		ul.TaxTotal.Rounding = amt(l.LineGross)
+		ul.Item.Name = l.Description
+		ul.Item.Category = cat
+		ul.Item.Category.ExemptionReasonCode, ul.Item.Category.ExemptionReason = "", ""
+		ul.Price = amt(l.UnitNet)
+		doc.Lines = append(doc.Lines, ul)
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func (inv *Invoice) taxCategory() ublTaxCategory {
+	c := ublTaxCategory{ID: "S", Percent: fmt.Sprintf("%.2f", inv.VATRate*100), Scheme: "VAT"}
+	if inv.VATRate <= 0 {
+		c.ID = "O"
+		c.ExemptionReasonCode = "VATEX-SA-OOS"
+		c.ExemptionReason = "Not subject to VAT"
+	}
+	return c
+}
+
+func (inv *Invoice) party(p Party, seller bool) ublParty {
+	out := ublParty{LegalName: p.Name()}
+	if seller {
+		if p.NameAR != "" && p.NameEN != "" {
+			out.LegalName = p.NameAR + " | " + p.NameEN
+		}
+		if p.CRNumber != "" {
+			out.Identification = &ublPartyID{}
+			out.Identification.ID.SchemeID = "CRN"
+			out.Identification.ID.Value = p.CRNumber
+		}
+	}
+	country := p.Country
+	if country == "" {
+		country = "SA"
+	}
+	if seller || p.Street != "" || p.City != "" {
+		out.Address = &ublAddress{Street: p.Street, City: p.City, PostalZone: p.PostalCode, Country: country}
+	}
+	if p.VATNumber != "" {
+		out.TaxScheme = &ublPartyTax{CompanyID: p.VATNumber, Scheme: "VAT"}
+	}
+	return out
+}
@@ -45,6 +45,7 @@ const (
 
 	// 503 Service Unavailable
 	ServiceUnavailable = "SERVICE_UNAVAILABLE"
+	AppMaintenance     = "APP_MAINTENANCE" // writes paused during maintenance mode or a scheduled window
 
 	// 504 Gateway Timeout
 	DeadlineExceeded = "DEADLINE_EXCEEDED"
@@ -70,6 +71,9 @@ const (
 	AuthForbidden                 = "AUTH_FORBIDDEN"
 	AuthEmailVerifyRequired       = "AUTH_EMAIL_VERIFY_REQUIRED"
 	AuthEmailVerifyRequiredAtCheckout = "AUTH_EMAIL_VERIFY_REQUIRED_AT_CHECKOUT"
+	AuthRegistrationDisabled      = "AUTH_REGISTRATION_DISABLED"
+	AuthAccountLocked             = "AUTH_ACCOUNT_LOCKED" // too many failed sign-ins; see Details.retry_after_seconds
+	AuthInvalidUnlockToken        = "AUTH_INVALID_UNLOCK_TOKEN"
 
 	// Auction/Bidding domain codes
 	BidVerifiedRequired = "BID_VERIFIED_REQUIRED"
@@ -129,7 +133,7 @@ func (e *Error) HTTPStatus() int {
 		return http.StatusConflict
 	case AuthInvalidCredentials, AuthUserNotFound, AuthUserInactive:
 		return http.StatusUnauthorized
-	case AuthWeakPassword, AuthInvalidVerificationCode:
+	case AuthWeakPassword, AuthInvalidVerificationCode, AuthInvalidUnlockToken:
 		return http.StatusBadRequest
 	case AuthVerificationCodeExpired:
 		return http.StatusGone
@@ -139,7 +143,9 @@ func (e *Error) HTTPStatus() int {
 		return http.StatusUnauthorized
 	case AuthRateLimitExceeded:
 		return http.StatusTooManyRequests
-	case AuthForbidden, AuthEmailVerifyRequired:
+	case AuthAccountLocked:
+		return http.StatusLocked
+	case AuthForbidden, AuthEmailVerifyRequired, AuthRegistrationDisabled:
 		return http.StatusForbidden
 
 	// Bidding domain mappings
@@ -190,7 +196,7 @@ func (e *Error) HTTPStatus() int {
 		return http.StatusUnprocessableEntity
 	case TooManyRequests, ResourceExhausted:
 		return http.StatusTooManyRequests
-	case ServiceUnavailable:
+	case ServiceUnavailable, AppMaintenance:
 		return http.StatusServiceUnavailable
 	case Unimplemented:
 		return http.StatusNotImplemented
@@ -0,0 +1,256 @@
+// Package maintenance decides whether the platform is in maintenance and which requests
+// that blocks. The state comes from the app.maintenance_mode / app.registration_enabled
+// system settings and from scheduled maintenance windows.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"encore.app/pkg/errs"
+	"encore.dev/storage/sqldb"
+)
+
+const (
+	// cacheTTL bounds how long a flipped setting takes to reach every instance. It is much
+	// shorter than the config manager reload interval so maintenance can be switched on
+	// quickly during a bad deploy.
+	cacheTTL = 10 * time.Second
+	// DefaultRetryAfter is advertised when maintenance has no scheduled end
+	DefaultRetryAfter = 5 * time.Minute
+	// upcomingLimit is the number of future windows announced on the status endpoint
+	upcomingLimit = 5
+)
+
+// DefaultMessage is shown when no app.maintenance_message or window message is set
+const DefaultMessage = "المنصة في وضع الصيانة حالياً. يمكنك التصفح، وسيتم تفعيل باقي العمليات قريباً"
+
+// Window is a scheduled maintenance period
+type Window struct {
+	ID          int64      `json:"id"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	Message     string     `json:"message,omitempty"`
+	CreatedBy   *int64     `json:"created_by,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Status is the current maintenance state
+type Status struct {
+	Active              bool       `json:"active"`
+	Message             string     `json:"message,omitempty"`
+	EndsAt              *time.Time `json:"ends_at,omitempty"` // end of the running window, if any
+	RegistrationEnabled bool       `json:"registration_enabled"`
+	Upcoming            []Window   `json:"upcoming"`
+}
+
+// RetryAfter returns how long clients should wait before retrying a blocked request
+func (s *Status) RetryAfter(now time.Time) time.Duration {
+	if s.EndsAt == nil {
+		return DefaultRetryAfter
+	}
+	d := s.EndsAt.Sub(now).Round(time.Second)
+	if d < time.Minute {
+		return time.Minute
+	}
+	return d
+}
+
+// Error returns the APP_MAINTENANCE error for a blocked request
+func (s *Status) Error(ctx context.Context) *errs.Error {
+	details := map[string]interface{}{
+		"retry_after_seconds": int(s.RetryAfter(time.Now()).Seconds()),
+	}
+	if s.EndsAt != nil {
+		details["ends_at"] = s.EndsAt.UTC().Format(time.RFC3339)
+	}
+	return errs.EDetails(ctx, errs.AppMaintenance, s.Message, details)
+}
+
+// exemptPaths (and the paths below them) stay writable during maintenance: signing in, so
+// admins can reach the dashboard, and payment provider webhooks for payments already taken
+var exemptPaths = []string{
+	"/auth/login",
+	"/auth/unlock-account",
+	"/auth/refresh",
+	"/auth/logout",
+	"/payments/webhook",
+}
+
+// Guarded reports whether a request is subject to maintenance: external writes. Reads keep
+// working so catalog and auction browsing stay available. Private endpoints without an
+// explicit path are served under /service.Endpoint and are only reachable from other
+// services and cron jobs, so they are not guarded either.
+func Guarded(method, path string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	first := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	if strings.Contains(first, ".") {
+		return false
+	}
+	for _, p := range exemptPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+var cache struct {
+	sync.Mutex
+	status   *Status
+	loadedAt time.Time
+}
+
+// Current returns the maintenance state, cached for a few seconds per instance. If the state
+// cannot be loaded the last known state is returned with the error.
+func Current(ctx context.Context, db *sqldb.Database) (*Status, error) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.status != nil && time.Since(cache.loadedAt) < cacheTTL {
+		return cache.status, nil
+	}
+	st, err := load(ctx, db)
+	if err != nil {
+		if cache.status != nil {
+			return cache.status, err
+		}
+		return &Status{RegistrationEnabled: true, Upcoming: []Window{}}, err
+	}
+	cache.status, cache.loadedAt = st, time.Now()
+	return st, nil
+}
+
+// Invalidate drops the cached state of this instance, e.g. after an admin change
+func Invalidate() {
+	cache.Lock()
+	cache.status = nil
+	cache.Unlock()
+}
+
+func load(ctx context.Context, db *sqldb.Database) (*Status, error) {
+	settings := map[string]string{}
+	rows, err := db.Query(ctx, `
+		SELECT key, COALESCE(value, '')
+		FROM system_settings
+		WHERE key IN ('app.maintenance_mode', 'app.maintenance_message', 'app.registration_enabled')
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("load maintenance settings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		settings[k] = strings.TrimSpace(v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	windows, err := ListWindows(ctx, db, false)
+	if err != nil {
+		return nil, err
+	}
+	return resolve(settings, windows, time.Now().UTC()), nil
+}
+
+// resolve combines the settings and the pending windows into a Status
+func resolve(settings map[string]string, windows []Window, now time.Time) *Status {
+	st := &Status{
+		Active:              strings.EqualFold(settings["app.maintenance_mode"], "true"),
+		Message:             settings["app.maintenance_message"],
+		RegistrationEnabled: !strings.EqualFold(settings["app.registration_enabled"], "false"),
+		Upcoming:            []Window{},
+	}
+	for _, w := range windows {
+		switch {
+		case !now.Before(w.StartsAt) && now.Before(w.EndsAt):
+			if st.EndsAt == nil || w.EndsAt.After(*st.EndsAt) {
+				end := w.EndsAt
+				st.EndsAt = &end
+			}
+			if st.Message == "" {
+				st.Message = w.Message
+			}
+			st.Active = true
+		case now.Before(w.StartsAt) && len(st.Upcoming) < upcomingLimit:
+			st.Upcoming = append(st.Upcoming, w)
+		}
+	}
+	if st.Message == "" {
+		st.Message = DefaultMessage
+	}
+	return st
+}
+
+// ListWindows returns maintenance windows ordered by start time. Unless includePast is set,
+// only windows that have not ended and were not cancelled are returned.
+func ListWindows(ctx context.Context, db *sqldb.Database, includePast bool) ([]Window, error) {
+	query := `
+		SELECT id, starts_at, ends_at, message, created_by, cancelled_at, created_at
+		FROM maintenance_windows
+		WHERE cancelled_at IS NULL AND ends_at > NOW()
+		ORDER BY starts_at`
+	if includePast {
+		query = `
+		SELECT id, starts_at, ends_at, message, created_by, cancelled_at, created_at
+		FROM maintenance_windows
+		ORDER BY starts_at DESC
+		LIMIT 100`
+	}
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list maintenance windows: %w", err)
+	}
+	defer rows.Close()
+
+	windows := []Window{}
+	for rows.Next() {
+		var w Window
+		if err := rows.Scan(&w.ID, &w.StartsAt, &w.EndsAt, &w.Message, &w.CreatedBy, &w.CancelledAt, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+// CreateWindow schedules a maintenance window
+func CreateWindow(ctx context.Context, db *sqldb.Database, startsAt, endsAt time.Time, message string, createdBy *int64) (*Window, error) {
+	w := Window{StartsAt: startsAt.UTC(), EndsAt: endsAt.UTC(), Message: strings.TrimSpace(message), CreatedBy: createdBy}
+	err := db.QueryRow(ctx, `
+		INSERT INTO maintenance_windows (starts_at, ends_at, message, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, w.StartsAt, w.EndsAt, w.Message, createdBy).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("create maintenance window: %w", err)
+	}
+	Invalidate()
+	return &w, nil
+}
+
+// CancelWindow cancels a window that has not ended; it returns false if none matched
+func CancelWindow(ctx context.Context, db *sqldb.Database, id int64) (bool, error) {
+	res, err := db.Exec(ctx, `
+		UPDATE maintenance_windows
+		SET cancelled_at = NOW()
+		WHERE id = $1 AND cancelled_at IS NULL AND ends_at > NOW()
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("cancel maintenance window: %w", err)
+	}
+	Invalidate()
+	return res.RowsAffected() > 0, nil
+}
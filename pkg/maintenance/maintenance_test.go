@@ -0,0 +1,78 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGuarded(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/catalog/products", false},
+		{"HEAD", "/auctions/5", false},
+		{"OPTIONS", "/orders/checkout", false},
+		{"POST", "/auctions/5/bids", true},
+		{"PUT", "/users/me", true},
+		{"DELETE", "/me/sessions/abc", true},
+		{"POST", "/auth/register", true},
+		{"POST", "/auth/login", false},
+		{"POST", "/auth/login/2fa", false},
+		{"POST", "/auth/refresh", false},
+		{"POST", "/auth/logout", false},
+		{"POST", "/auth/loginx", true},
+		{"POST", "/payments/webhook/moyasar", false},
+		{"POST", "/notifications.EnqueueEmail", false},
+	}
+	for _, tt := range tests {
+		if got := Guarded(tt.method, tt.path); got != tt.want {
+			t.Errorf("Guarded(%s %s) = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	running := Window{ID: 1, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(30 * time.Minute), Message: "ترقية قاعدة البيانات"}
+	upcoming := Window{ID: 2, StartsAt: now.Add(24 * time.Hour), EndsAt: now.Add(25 * time.Hour)}
+
+	st := resolve(map[string]string{"app.maintenance_mode": "false"}, []Window{upcoming}, now)
+	if st.Active {
+		t.Error("maintenance should be inactive before the window starts")
+	}
+	if !st.RegistrationEnabled {
+		t.Error("registration should default to enabled")
+	}
+	if len(st.Upcoming) != 1 || st.Upcoming[0].ID != 2 {
+		t.Errorf("expected the upcoming window to be announced, got %+v", st.Upcoming)
+	}
+
+	st = resolve(map[string]string{"app.registration_enabled": "false"}, []Window{running, upcoming}, now)
+	if !st.Active || st.EndsAt == nil || !st.EndsAt.Equal(running.EndsAt) {
+		t.Errorf("expected the running window to activate maintenance until its end, got %+v", st)
+	}
+	if st.Message != running.Message {
+		t.Errorf("message = %q, want the window message", st.Message)
+	}
+	if st.RegistrationEnabled {
+		t.Error("registration should follow app.registration_enabled")
+	}
+	if got := st.RetryAfter(now); got != 30*time.Minute {
+		t.Errorf("RetryAfter = %v, want 30m", got)
+	}
+
+	st = resolve(map[string]string{"app.maintenance_mode": "true"}, nil, now)
+	if !st.Active || st.Message != DefaultMessage || st.RetryAfter(now) != DefaultRetryAfter {
+		t.Errorf("unexpected flag-only status: %+v", st)
+	}
+}
+
+func TestRetryAfterMinimum(t *testing.T) {
+	now := time.Now()
+	end := now.Add(5 * time.Second)
+	st := &Status{Active: true, EndsAt: &end}
+	if got := st.RetryAfter(now); got != time.Minute {
+		t.Errorf("RetryAfter = %v, want 1m minimum", got)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -284,3 +286,117 @@ func RefundPayment(gatewayRef string, amountHalalas int) error {
 	}
 	return nil
 }
+
+// PaymentInfo is the gateway-side state of a payment or invoice (amounts in halalas)
+type PaymentInfo struct {
+	ID          string            `json:"id"`
+	Status      string            `json:"status"`
+	Amount      int64             `json:"amount"`
+	Captured    int64             `json:"captured"`
+	Refunded    int64             `json:"refunded"`
+	Currency    string            `json:"currency"`
+	Description string            `json:"description"`
+	Metadata    map[string]string `json:"metadata"`
+	CreatedAt   string            `json:"created_at"`
+	UpdatedAt   string            `json:"updated_at"`
+	Payments    []PaymentInfo     `json:"payments,omitempty"` // invoices only
+}
+
+// FetchPayment returns the current state of a payment. gatewayRef may be a payment id or,
+// for hosted invoices, an invoice id; for paid invoices the captured/refunded amounts are
+// taken from the invoice's payments.
+func FetchPayment(gatewayRef string) (*PaymentInfo, error) {
+	if strings.TrimSpace(secrets.MoyasarAPIKey) == "" {
+		return nil, fmt.Errorf("moyasar api key not set")
+	}
+	info, status, err := getJSON("https://api.moyasar.com/v1/payments/" + gatewayRef)
+	if status == http.StatusNotFound {
+		info, _, err = getJSON("https://api.moyasar.com/v1/invoices/" + gatewayRef)
+		if err == nil {
+			for _, p := range info.Payments {
+				info.Captured += p.Captured
+				info.Refunded += p.Refunded
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// invoicePage is one page of GET /v1/invoices
+type invoicePage struct {
+	Invoices []PaymentInfo `json:"invoices"`
+	Meta     struct {
+		NextPage *int `json:"next_page"`
+	} `json:"meta"`
+}
+
+// ListInvoices returns one page of the invoices created between from and to (captured and
+// refunded summed from each invoice's payments) and the next page number, 0 after the last.
+func ListInvoices(from, to time.Time, page int) ([]PaymentInfo, int, error) {
+	if strings.TrimSpace(secrets.MoyasarAPIKey) == "" {
+		return nil, 0, fmt.Errorf("moyasar api key not set")
+	}
+	if page < 1 {
+		page = 1
+	}
+	q := url.Values{}
+	q.Set("page", strconv.Itoa(page))
+	q.Set("created[gt]", from.UTC().Format(time.RFC3339))
+	q.Set("created[lt]", to.UTC().Format(time.RFC3339))
+	req, err := http.NewRequest(http.MethodGet, "https://api.moyasar.com/v1/invoices?"+q.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(strings.TrimSpace(secrets.MoyasarAPIKey), "")
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, 0, fmt.Errorf("moyasar list invoices failed: %s", resp.Status)
+	}
+	var out invoicePage
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, 0, err
+	}
+	for i := range out.Invoices {
+		for _, p := range out.Invoices[i].Payments {
+			out.Invoices[i].Captured += p.Captured
+			out.Invoices[i].Refunded += p.Refunded
+		}
+	}
+	next := 0
+	if out.Meta.NextPage != nil && *out.Meta.NextPage > page {
+		next = *out.Meta.NextPage
+	}
+	return out.Invoices, next, nil
+}
+
+func getJSON(url string) (*PaymentInfo, int, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(strings.TrimSpace(secrets.MoyasarAPIKey), "")
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, resp.StatusCode, fmt.Errorf("moyasar fetch failed: %s", resp.Status)
+	}
+	var out PaymentInfo
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, resp.StatusCode, err
+	}
+	return &out, resp.StatusCode, nil
+}
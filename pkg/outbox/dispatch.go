@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"encore.dev/storage/sqldb"
+)
+
+const (
+	// MaxAttempts is how many deliveries are tried before a row is parked as failed
+	MaxAttempts = 8
+	// claimTTL is how long a claimed row stays invisible to other dispatchers; a dispatcher
+	// that crashes mid-batch releases its rows once this expires
+	claimTTL = 2 * time.Minute
+	// maxBackoff caps the delay between retries
+	maxBackoff = time.Hour
+)
+
+// Entry is a claimed outbox row handed to a Handler
+type Entry struct {
+	ID        int64
+	Kind      Kind
+	UserID    int64
+	Target    string
+	Payload   json.RawMessage
+	DedupeKey string
+	Attempts  int
+}
+
+// Handler delivers one entry. Returning an error schedules a retry.
+type Handler func(ctx context.Context, e Entry) error
+
+// Filter selects which rows a dispatcher is responsible for
+type Filter struct {
+	Kinds  []Kind
+	Target string // optional: only rows for this template/topic
+}
+
+// Stats summarises a dispatch run
+type Stats struct {
+	Claimed   int
+	Delivered int
+	Retried   int
+	Failed    int
+}
+
+// Dispatch claims up to limit due rows matching f and hands each to handle. Claiming uses
+// FOR UPDATE SKIP LOCKED so concurrent dispatchers never deliver the same row at once.
+func Dispatch(ctx context.Context, db *sqldb.Database, f Filter, limit int, handle Handler) (Stats, error) {
+	var st Stats
+	if len(f.Kinds) == 0 {
+		return st, nil
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	args := []any{int(claimTTL / time.Second), limit}
+	kinds := make([]string, len(f.Kinds))
+	for i, k := range f.Kinds {
+		args = append(args, string(k))
+		kinds[i] = fmt.Sprintf("$%d", len(args))
+	}
+	where := "kind IN (" + strings.Join(kinds, ",") + ")"
+	if f.Target != "" {
+		args = append(args, f.Target)
+		where += fmt.Sprintf(" AND target = $%d", len(args))
+	}
+
+	rows, err := db.Query(ctx, `
+		UPDATE outbox_events
+		SET status = 'processing', attempts = attempts + 1, locked_until = NOW() + ($1 * INTERVAL '1 second')
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE `+where+`
+			  AND ((status = 'pending' AND next_attempt_at <= NOW())
+			    OR (status = 'processing' AND locked_until < NOW()))
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, COALESCE(user_id, 0), target, payload, dedupe_key, attempts`, args...)
+	if err != nil {
+		return st, fmt.Errorf("outbox claim: %w", err)
+	}
+	var batch []Entry
+	for rows.Next() {
+		var e Entry
+		var kind string
+		if err := rows.Scan(&e.ID, &kind, &e.UserID, &e.Target, &e.Payload, &e.DedupeKey, &e.Attempts); err != nil {
+			rows.Close()
+			return st, fmt.Errorf("outbox scan: %w", err)
+		}
+		e.Kind = Kind(kind)
+		batch = append(batch, e)
+	}
+	rows.Close()
+	st.Claimed = len(batch)
+
+	for _, e := range batch {
+		if err := handle(ctx, e); err != nil {
+			if e.Attempts >= MaxAttempts {
+				st.Failed++
+				_, _ = db.Exec(ctx, `
+					UPDATE outbox_events SET status = 'failed', locked_until = NULL, last_error = $2
+					WHERE id = $1`, e.ID, err.Error())
+				continue
+			}
+			st.Retried++
+			_, _ = db.Exec(ctx, `
+				UPDATE outbox_events
+				SET status = 'pending', locked_until = NULL, last_error = $2,
+				    next_attempt_at = NOW() + ($3 * INTERVAL '1 second')
+				WHERE id = $1`, e.ID, err.Error(), int(Backoff(e.Attempts)/time.Second))
+			continue
+		}
+		st.Delivered++
+		_, _ = db.Exec(ctx, `
+			UPDATE outbox_events
+			SET status = 'delivered', locked_until = NULL, last_error = NULL, delivered_at = NOW()
+			WHERE id = $1`, e.ID)
+	}
+	return st, nil
+}
+
+// Backoff returns the delay before retry number attempt (1-based): 30s, 1m, 2m, ... capped at an hour
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := 30 * time.Second
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,131 @@
+// Package outbox implements a transactional outbox. Side effects such as notifications and
+// Pub/Sub events are written to outbox_events inside the same transaction as the business
+// change, so a rollback discards them and a crash after commit cannot lose them. A dispatcher
+// then delivers each row at least once; the dedupe key makes re-inserting the same logical
+// event a no-op and lets consumers detect redelivery.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"encore.dev/storage/sqldb"
+)
+
+// Kind selects how a message is delivered
+type Kind string
+
+const (
+	KindInternal Kind = "internal" // notifications.EnqueueInternal
+	KindEmail    Kind = "email"    // notifications.EnqueueEmail
+	KindPubSub   Kind = "pubsub"   // publish to a registered topic
+)
+
+// ErrMissingDedupeKey is returned when a message has no dedupe key
+var ErrMissingDedupeKey = errors.New("outbox: message has no dedupe key")
+
+// Message is a side effect to deliver after the surrounding transaction commits
+type Message struct {
+	Kind      Kind
+	UserID    int64  // recipient for notifications; 0 for Pub/Sub
+	Target    string // template ID for notifications, topic name for Pub/Sub
+	Payload   any
+	DedupeKey string
+}
+
+// Internal builds an inbox notification message
+func Internal(userID int64, templateID string, payload any, dedupeKey string) Message {
+	return Message{Kind: KindInternal, UserID: userID, Target: templateID, Payload: payload, DedupeKey: dedupeKey}
+}
+
+// Email builds an email notification message (payload needs "email" and "name")
+func Email(userID int64, templateID string, payload any, dedupeKey string) Message {
+	return Message{Kind: KindEmail, UserID: userID, Target: templateID, Payload: payload, DedupeKey: dedupeKey}
+}
+
+// Event builds a Pub/Sub message for the named topic
+func Event(topic string, payload any, dedupeKey string) Message {
+	return Message{Kind: KindPubSub, Target: topic, Payload: payload, DedupeKey: dedupeKey}
+}
+
+// Key joins parts into a dedupe key, e.g. Key("auction", 12, "ended", 7) = "auction:12:ended:7"
+func Key(parts ...any) string {
+	s := make([]string, len(parts))
+	for i, p := range parts {
+		s[i] = fmt.Sprint(p)
+	}
+	return strings.Join(s, ":")
+}
+
+// Execer is satisfied by *sqldb.Database and *sqldb.Tx
+type Execer interface {
+	Exec(ctx context.Context, query string, args ...interface{}) (sqldb.ExecResult, error)
+}
+
+// Add writes messages through an Encore transaction (or database handle). Messages whose
+// dedupe key already exists are skipped.
+func Add(ctx context.Context, ex Execer, msgs ...Message) error {
+	query, args, err := buildInsert(msgs)
+	if err != nil || query == "" {
+		return err
+	}
+	if _, err := ex.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("outbox insert: %w", err)
+	}
+	return nil
+}
+
+// AddTx is Add for code that runs on a database/sql transaction (db.Stdlib())
+func AddTx(ctx context.Context, tx *sql.Tx, msgs ...Message) error {
+	query, args, err := buildInsert(msgs)
+	if err != nil || query == "" {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("outbox insert: %w", err)
+	}
+	return nil
+}
+
+// buildInsert renders a single multi-row INSERT for msgs
+func buildInsert(msgs []Message) (string, []any, error) {
+	if len(msgs) == 0 {
+		return "", nil, nil
+	}
+	var b strings.Builder
+	b.WriteString(`INSERT INTO outbox_events (dedupe_key, kind, user_id, target, payload) VALUES `)
+	args := make([]any, 0, len(msgs)*5)
+	for i, m := range msgs {
+		if strings.TrimSpace(m.DedupeKey) == "" {
+			return "", nil, ErrMissingDedupeKey
+		}
+		switch m.Kind {
+		case KindInternal, KindEmail, KindPubSub:
+		default:
+			return "", nil, fmt.Errorf("outbox: unknown kind %q", m.Kind)
+		}
+		payload, err := json.Marshal(m.Payload)
+		if err != nil {
+			return "", nil, fmt.Errorf("outbox: marshal payload for %s: %w", m.DedupeKey, err)
+		}
+		if string(payload) == "null" {
+			payload = []byte("{}")
+		}
+		var userID sql.NullInt64
+		if m.UserID > 0 {
+			userID = sql.NullInt64{Int64: m.UserID, Valid: true}
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&b, "($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5)
+		args = append(args, m.DedupeKey, string(m.Kind), userID, m.Target, json.RawMessage(payload))
+	}
+	b.WriteString(` ON CONFLICT (dedupe_key) DO NOTHING`)
+	return b.String(), args, nil
+}
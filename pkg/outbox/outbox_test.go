@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	if got := Key("auction", int64(12), "ended", 7); got != "auction:12:ended:7" {
+		t.Fatalf("Key = %q", got)
+	}
+}
+
+func TestBuildInsert(t *testing.T) {
+	query, args, err := buildInsert([]Message{
+		Internal(5, "order_paid", map[string]any{"order_id": 1}, "order:1:paid:internal"),
+		Event("payment-webhook-events", nil, "payment:abc:paid"),
+	})
+	if err != nil {
+		t.Fatalf("buildInsert: %v", err)
+	}
+	if !strings.Contains(query, "($1, $2, $3, $4, $5), ($6, $7, $8, $9, $10)") {
+		t.Errorf("unexpected placeholders: %s", query)
+	}
+	if !strings.HasSuffix(query, "ON CONFLICT (dedupe_key) DO NOTHING") {
+		t.Errorf("missing conflict clause: %s", query)
+	}
+	if len(args) != 10 {
+		t.Fatalf("got %d args, want 10", len(args))
+	}
+	if string(args[9].(json.RawMessage)) != "{}" {
+		t.Errorf("nil payload should be stored as {}, got %s", args[9])
+	}
+
+	if q, _, err := buildInsert(nil); err != nil || q != "" {
+		t.Errorf("empty batch: got %q, %v", q, err)
+	}
+	if _, _, err := buildInsert([]Message{Email(1, "x", nil, " ")}); !errors.Is(err, ErrMissingDedupeKey) {
+		t.Errorf("blank dedupe key: got %v", err)
+	}
+	if _, _, err := buildInsert([]Message{{Kind: "sms", Target: "x", DedupeKey: "k"}}); err == nil {
+		t.Error("unknown kind should be rejected")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  30 * time.Second,
+		1:  30 * time.Second,
+		2:  time.Minute,
+		4:  4 * time.Minute,
+		20: time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
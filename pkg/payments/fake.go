@@ -0,0 +1,299 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// FakeProviderName is the payments.provider value selecting the fake gateway
+	FakeProviderName = "fake"
+	// FakeSignatureHeader carries the hex HMAC-SHA256 of the webhook body
+	FakeSignatureHeader = "X-Fake-Signature"
+)
+
+// FakeProvider is an in-process gateway for local development and tests. Sessions point to a
+// simulated hosted-checkout page; completing it produces a signed webhook the same way a real
+// gateway would.
+type FakeProvider struct {
+	secret  []byte
+	baseURL string
+
+	mu       sync.Mutex
+	seq      int64
+	sessions map[string]*fakeSession
+}
+
+type fakeSession struct {
+	req       SessionRequest
+	createdAt time.Time
+	status    string
+	captured  int64
+	refunded  int64
+	refunds   int
+	updatedAt time.Time
+}
+
+// fakeWebhook is the JSON body the fake gateway delivers
+type fakeWebhook struct {
+	ID        string            `json:"id"`
+	Status    string            `json:"status"`
+	Amount    int64             `json:"amount"`
+	Captured  int64             `json:"captured"`
+	Currency  string            `json:"currency"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt string            `json:"created_at"`
+}
+
+// NewFakeProvider creates a fake gateway signing webhooks with secret. baseURL is the API base
+// the hosted-checkout page is served from.
+func NewFakeProvider(secret, baseURL string) *FakeProvider {
+	return &FakeProvider{
+		secret:   []byte(secret),
+		baseURL:  baseURL,
+		sessions: make(map[string]*fakeSession),
+	}
+}
+
+func (p *FakeProvider) Name() string { return FakeProviderName }
+
+// CreateSession registers an initiated payment and returns the simulated checkout URL
+func (p *FakeProvider) CreateSession(ctx context.Context, req *SessionRequest) (*Session, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("fake gateway: amount must be positive")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.seq++
+	ref := fmt.Sprintf("fake_%d_%d", time.Now().UTC().Unix(), p.seq)
+	now := time.Now().UTC()
+	p.sessions[ref] = &fakeSession{req: *req, createdAt: now, status: "initiated", updatedAt: now}
+	return &Session{GatewayRef: ref, URL: p.CheckoutURL(ref)}, nil
+}
+
+// CheckoutURL is the simulated hosted-checkout page for a session
+func (p *FakeProvider) CheckoutURL(gatewayRef string) string {
+	return fmt.Sprintf("%s/payments/fake/checkout/%s", p.baseURL, url.PathEscape(gatewayRef))
+}
+
+// Complete simulates the customer finishing checkout with outcome "paid" or "failed" and
+// returns the signed webhook body and signature to deliver, plus the browser redirect URL.
+func (p *FakeProvider) Complete(gatewayRef, outcome string) (body []byte, signature, redirectURL string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.sessions[gatewayRef]
+	if !ok {
+		return nil, "", "", ErrNotFound
+	}
+	if s.status != "initiated" {
+		return nil, "", "", fmt.Errorf("fake gateway: payment %s is already %s", gatewayRef, s.status)
+	}
+	switch outcome {
+	case "paid":
+		s.status = "paid"
+		s.captured = s.req.Amount
+		redirectURL = s.req.SuccessURL
+	case "failed":
+		s.status = "failed"
+		redirectURL = s.req.BackURL
+		if redirectURL == "" {
+			redirectURL = s.req.SuccessURL
+		}
+	default:
+		return nil, "", "", fmt.Errorf("fake gateway: unknown outcome %q", outcome)
+	}
+	s.updatedAt = time.Now().UTC()
+	if redirectURL != "" {
+		if u, perr := url.Parse(redirectURL); perr == nil {
+			q := u.Query()
+			q.Set("id", gatewayRef)
+			q.Set("status", s.status)
+			u.RawQuery = q.Encode()
+			redirectURL = u.String()
+		}
+	}
+
+	body, err = json.Marshal(&fakeWebhook{
+		ID:        gatewayRef,
+		Status:    s.status,
+		Amount:    s.req.Amount,
+		Captured:  s.captured,
+		Currency:  s.req.Currency,
+		Metadata:  s.req.Metadata,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
+	return body, p.Sign(body), redirectURL, nil
+}
+
+// Sign returns the signature the fake gateway attaches to a webhook body
+func (p *FakeProvider) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the HMAC signature header and decodes the payload
+func (p *FakeProvider) VerifyWebhook(ctx context.Context, header http.Header, raw []byte) (*WebhookEvent, error) {
+	sig, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || len(sig) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(raw)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var w fakeWebhook
+	if err := json.Unmarshal(raw, &w); err != nil {
+		return nil, fmt.Errorf("fake gateway: malformed webhook: %w", err)
+	}
+	evt := &WebhookEvent{
+		GatewayRef: w.ID,
+		Status:     w.Status,
+		Amount:     w.Amount,
+		Captured:   w.Captured,
+		Currency:   w.Currency,
+	}
+	if v, err := strconv.ParseInt(w.Metadata["invoice_id"], 10, 64); err == nil {
+		evt.InvoiceID = v
+	}
+	return evt, nil
+}
+
+// Refund records a refund against the captured amount
+func (p *FakeProvider) Refund(ctx context.Context, gatewayRef string, amount int64) (*RefundResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.sessions[gatewayRef]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if amount <= 0 || s.refunded+amount > s.captured {
+		return nil, fmt.Errorf("fake gateway: refund %d exceeds refundable amount %d", amount, s.captured-s.refunded)
+	}
+	s.refunded += amount
+	s.refunds++
+	s.updatedAt = time.Now().UTC()
+	if s.refunded == s.captured {
+		s.status = "refunded"
+	}
+	return &RefundResult{
+		GatewayRefundID: fmt.Sprintf("%s_rf_%d", gatewayRef, s.refunds),
+		Status:          "succeeded",
+	}, nil
+}
+
+// FetchStatus returns the simulated gateway state
+func (p *FakeProvider) FetchStatus(ctx context.Context, gatewayRef string) (*PaymentStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.sessions[gatewayRef]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.paymentStatus(gatewayRef), nil
+}
+
+// fakeListPageSize is the page size of ListPayments
+const fakeListPageSize = 100
+
+// ListPayments pages through the sessions created between from and to in reference order
+func (p *FakeProvider) ListPayments(ctx context.Context, from, to time.Time, page int) (*PaymentList, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var refs []string
+	for ref, s := range p.sessions {
+		if s.createdAt.After(from) && s.createdAt.Before(to) {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+	if page < 1 {
+		page = 1
+	}
+	out := &PaymentList{}
+	start := (page - 1) * fakeListPageSize
+	for i := start; i < len(refs) && i < start+fakeListPageSize; i++ {
+		out.Items = append(out.Items, *p.sessions[refs[i]].paymentStatus(refs[i]))
+	}
+	if start+fakeListPageSize < len(refs) {
+		out.NextPage = page + 1
+	}
+	return out, nil
+}
+
+func (s *fakeSession) paymentStatus(gatewayRef string) *PaymentStatus {
+	st := &PaymentStatus{
+		GatewayRef: gatewayRef,
+		Status:     s.status,
+		Amount:     s.req.Amount,
+		Captured:   s.captured,
+		Refunded:   s.refunded,
+		Currency:   s.req.Currency,
+		UpdatedAt:  s.updatedAt,
+	}
+	if v, err := strconv.ParseInt(s.req.Metadata["invoice_id"], 10, 64); err == nil {
+		st.InvoiceID = v
+	}
+	return st
+}
+
+// CheckoutPage renders the simulated hosted-checkout page for a session
+func (p *FakeProvider) CheckoutPage(gatewayRef string) (string, error) {
+	p.mu.Lock()
+	s, ok := p.sessions[gatewayRef]
+	var req SessionRequest
+	var status string
+	if ok {
+		req, status = s.req, s.status
+	}
+	p.mu.Unlock()
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	action := html.EscapeString(p.CheckoutURL(gatewayRef))
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html dir="rtl" lang="ar">
+<head><meta charset="UTF-8"><title>بوابة دفع تجريبية</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 40px auto;">
+    <h1>بوابة دفع تجريبية</h1>
+    <p>%s</p>
+    <p><strong>المبلغ:</strong> %.2f %s</p>
+    <p><strong>الحالة:</strong> %s</p>
+    <form method="POST" action="%s"><input type="hidden" name="outcome" value="paid"><button type="submit">دفع</button></form>
+    <form method="POST" action="%s"><input type="hidden" name="outcome" value="failed"><button type="submit">فشل الدفع</button></form>
+</body>
+</html>`, html.EscapeString(req.Description), float64(req.Amount)/100.0, html.EscapeString(req.Currency),
+		html.EscapeString(status), action, action), nil
+}
+
+// randomSecret generates a per-process webhook signing key for the fake gateway
+func randomSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,161 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeProviderCheckoutFlow(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakeProvider("test-secret", "http://127.0.0.1:4000")
+
+	session, err := p.CreateSession(ctx, &SessionRequest{
+		Amount:     11500,
+		Currency:   "SAR",
+		SuccessURL: "http://localhost:3000/checkout/callback?invoice_id=7",
+		Metadata:   map[string]string{"invoice_id": "7"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if !strings.HasPrefix(session.URL, "http://127.0.0.1:4000/payments/fake/checkout/") {
+		t.Errorf("unexpected checkout URL %q", session.URL)
+	}
+	if _, err := p.CheckoutPage(session.GatewayRef); err != nil {
+		t.Errorf("CheckoutPage: %v", err)
+	}
+
+	body, sig, redirect, err := p.Complete(session.GatewayRef, "paid")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if !strings.Contains(redirect, "invoice_id=7") || !strings.Contains(redirect, "status=paid") {
+		t.Errorf("unexpected redirect %q", redirect)
+	}
+	if _, _, _, err := p.Complete(session.GatewayRef, "paid"); err == nil {
+		t.Errorf("completing a session twice must fail")
+	}
+
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, sig)
+	evt, err := p.VerifyWebhook(ctx, header, body)
+	if err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+	if evt.GatewayRef != session.GatewayRef || evt.Status != "paid" || evt.Captured != 11500 || evt.InvoiceID != 7 {
+		t.Errorf("unexpected webhook event %+v", evt)
+	}
+
+	st, err := p.FetchStatus(ctx, session.GatewayRef)
+	if err != nil || st.Status != "paid" || st.Captured != 11500 {
+		t.Errorf("unexpected status %+v (err=%v)", st, err)
+	}
+}
+
+func TestFakeProviderRejectsTamperedWebhook(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakeProvider("test-secret", "")
+	session, _ := p.CreateSession(ctx, &SessionRequest{Amount: 500, Currency: "SAR"})
+	body, sig, _, err := p.Complete(session.GatewayRef, "paid")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, sig)
+	tampered := []byte(strings.Replace(string(body), `"captured":500`, `"captured":50000`, 1))
+	if _, err := p.VerifyWebhook(ctx, header, tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for a tampered body, got %v", err)
+	}
+
+	other := NewFakeProvider("other-secret", "")
+	if _, err := other.VerifyWebhook(ctx, header, body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for a different key, got %v", err)
+	}
+	if _, err := p.VerifyWebhook(ctx, http.Header{}, body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature without a signature header, got %v", err)
+	}
+}
+
+func TestFakeProviderRefunds(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakeProvider("test-secret", "")
+	session, _ := p.CreateSession(ctx, &SessionRequest{Amount: 1000, Currency: "SAR"})
+
+	if _, err := p.Refund(ctx, session.GatewayRef, 100); err == nil {
+		t.Errorf("refunding an uncaptured payment must fail")
+	}
+	_, _, _, _ = p.Complete(session.GatewayRef, "paid")
+
+	first, err := p.Refund(ctx, session.GatewayRef, 400)
+	if err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	second, err := p.Refund(ctx, session.GatewayRef, 600)
+	if err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if first.GatewayRefundID == second.GatewayRefundID {
+		t.Errorf("refund ids must be unique")
+	}
+	if _, err := p.Refund(ctx, session.GatewayRef, 1); err == nil {
+		t.Errorf("refunding beyond the captured amount must fail")
+	}
+	if st, _ := p.FetchStatus(ctx, session.GatewayRef); st.Status != "refunded" || st.Refunded != 1000 {
+		t.Errorf("unexpected status after full refund %+v", st)
+	}
+}
+
+func TestFakeProviderListPayments(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakeProvider("test-secret", "")
+	from := time.Now().UTC().Add(-time.Minute)
+	for i := 0; i < fakeListPageSize+5; i++ {
+		_, _ = p.CreateSession(ctx, &SessionRequest{Amount: 1000, Currency: "SAR"})
+	}
+	to := time.Now().UTC().Add(time.Minute)
+
+	seen := map[string]bool{}
+	for page := 1; page != 0; {
+		list, err := p.ListPayments(ctx, from, to, page)
+		if err != nil {
+			t.Fatalf("ListPayments: %v", err)
+		}
+		for _, it := range list.Items {
+			seen[it.GatewayRef] = true
+		}
+		page = list.NextPage
+	}
+	if len(seen) != fakeListPageSize+5 {
+		t.Errorf("listed %d payments, want %d", len(seen), fakeListPageSize+5)
+	}
+	if list, _ := p.ListPayments(ctx, to, to.Add(time.Hour), 1); len(list.Items) != 0 {
+		t.Errorf("payments outside the window were listed: %d", len(list.Items))
+	}
+}
+
+func TestMoyasarWebhookParsing(t *testing.T) {
+	form := parseMoyasarForm(mustParseQuery(t, "data[id]=pay_1&data[status]=paid&data[amount]=2500&data[currency]=sar&description=Order+%2312+-+Invoice+%23INV-2025-000030"))
+	if form.GatewayRef != "pay_1" || form.Status != "paid" || form.Amount != 2500 || form.InvoiceNumber != "INV-2025-000030" {
+		t.Errorf("unexpected form event %+v", form)
+	}
+
+	js := parseMoyasarJSON([]byte(`{"type":"payment_paid","data":{"id":"pay_2","amount":900,"currency":"SAR","metadata":{"invoice_id":"41"}}}`))
+	if js.GatewayRef != "pay_2" || js.Status != "paid" || js.Amount != 900 || js.InvoiceID != 41 {
+		t.Errorf("unexpected JSON event %+v", js)
+	}
+}
+
+func mustParseQuery(t *testing.T, q string) url.Values {
+	t.Helper()
+	v, err := url.ParseQuery(q)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	return v
+}
@@ -0,0 +1,287 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"encore.app/pkg/config"
+	"encore.app/pkg/moyasar"
+)
+
+// moyasarProvider adapts pkg/moyasar to the Provider interface
+type moyasarProvider struct{}
+
+func (moyasarProvider) Name() string { return "moyasar" }
+
+func (moyasarProvider) CreateSession(ctx context.Context, req *SessionRequest) (*Session, error) {
+	ref, sessionURL, err := moyasar.CreateInvoice(int(req.Amount), req.Currency, req.Description,
+		req.SuccessURL, req.BackURL, req.WebhookURL, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{GatewayRef: ref, URL: sessionURL}, nil
+}
+
+func (moyasarProvider) Refund(ctx context.Context, gatewayRef string, amount int64) (*RefundResult, error) {
+	if err := moyasar.RefundPayment(gatewayRef, int(amount)); err != nil {
+		return nil, err
+	}
+	// Moyasar refunds update the payment in place and do not issue a separate refund id
+	return &RefundResult{Status: "succeeded"}, nil
+}
+
+func (moyasarProvider) FetchStatus(ctx context.Context, gatewayRef string) (*PaymentStatus, error) {
+	info, err := moyasar.FetchPayment(gatewayRef)
+	if err != nil {
+		return nil, err
+	}
+	return moyasarStatus(gatewayRef, info), nil
+}
+
+func (moyasarProvider) ListPayments(ctx context.Context, from, to time.Time, page int) (*PaymentList, error) {
+	invoices, next, err := moyasar.ListInvoices(from, to, page)
+	if err != nil {
+		return nil, err
+	}
+	out := &PaymentList{NextPage: next}
+	for i := range invoices {
+		st := moyasarStatus(invoices[i].ID, &invoices[i])
+		for _, p := range invoices[i].Payments {
+			st.OtherRefs = append(st.OtherRefs, p.ID)
+		}
+		out.Items = append(out.Items, *st)
+	}
+	return out, nil
+}
+
+func moyasarStatus(gatewayRef string, info *moyasar.PaymentInfo) *PaymentStatus {
+	st := &PaymentStatus{
+		GatewayRef: gatewayRef,
+		Status:     strings.ToLower(info.Status),
+		Amount:     info.Amount,
+		Captured:   info.Captured,
+		Refunded:   info.Refunded,
+		Currency:   strings.ToUpper(info.Currency),
+	}
+	if v, err := strconv.ParseInt(strings.TrimSpace(info.Metadata["invoice_id"]), 10, 64); err == nil {
+		st.InvoiceID = v
+	}
+	if t, err := time.Parse(time.RFC3339, info.UpdatedAt); err == nil {
+		st.UpdatedAt = t.UTC()
+	}
+	return st
+}
+
+// moyasarSignatureHeaders are the header names Moyasar (and proxies in front of it) have used
+var moyasarSignatureHeaders = []string{
+	"X-Moyasar-Signature",
+	"X-Signature",
+	"Signature",
+	"X-Webhook-Signature",
+	"Moyasar-Signature",
+	"Moyasar-Webhook-Signature",
+}
+
+// VerifyWebhook accepts either a header HMAC or the payload secret_token (per Moyasar docs).
+// Verification is skipped entirely in payments test mode.
+func (moyasarProvider) VerifyWebhook(ctx context.Context, header http.Header, raw []byte) (*WebhookEvent, error) {
+	ct := strings.ToLower(strings.TrimSpace(header.Get("Content-Type")))
+	isForm := strings.Contains(ct, "application/x-www-form-urlencoded")
+	var form url.Values
+	if isForm {
+		form, _ = url.ParseQuery(string(raw))
+	}
+
+	if s := config.GetSettings(); !(s != nil && s.PaymentsTestMode) {
+		sig := ""
+		for _, h := range moyasarSignatureHeaders {
+			if sig = header.Get(h); sig != "" {
+				break
+			}
+		}
+		verified := strings.TrimSpace(sig) != "" && moyasar.VerifySignature(raw, sig)
+		if !verified {
+			var token string
+			if isForm {
+				token = strings.TrimSpace(form.Get("secret_token"))
+				if token == "" {
+					token = strings.TrimSpace(form.Get("webhook[secret_token]"))
+				}
+			} else {
+				var obj map[string]any
+				if err := json.Unmarshal(raw, &obj); err == nil {
+					if v, ok := obj["secret_token"].(string); ok {
+						token = strings.TrimSpace(v)
+					}
+				}
+			}
+			verified = token != "" && moyasar.VerifySecretToken(token)
+		}
+		if !verified {
+			return nil, ErrInvalidSignature
+		}
+	}
+
+	var evt *WebhookEvent
+	if isForm && form != nil {
+		evt = parseMoyasarForm(form)
+	} else {
+		evt = parseMoyasarJSON(raw)
+	}
+	evt.GatewayRef = strings.TrimSpace(evt.GatewayRef)
+	evt.Status = strings.ToLower(strings.TrimSpace(evt.Status))
+	evt.Currency = strings.ToUpper(strings.TrimSpace(evt.Currency))
+	return evt, nil
+}
+
+// parseMoyasarForm reads a form-encoded webhook (flat or data[...] keys, or a JSON 'payload' field)
+func parseMoyasarForm(form url.Values) *WebhookEvent {
+	evt := &WebhookEvent{
+		GatewayRef: firstNonEmpty(form.Get("id"), form.Get("data[id]")),
+		Status:     firstNonEmpty(form.Get("status"), form.Get("data[status]")),
+		Currency:   firstNonEmpty(form.Get("currency"), form.Get("data[currency]")),
+		Amount:     parseInt64(firstNonEmpty(form.Get("amount"), form.Get("data[amount]"))),
+		Captured:   parseInt64(firstNonEmpty(form.Get("captured"), form.Get("data[captured]"))),
+		InvoiceID: parseInt64(firstNonEmpty(form.Get("metadata[invoice_id]"), form.Get("invoice_id"),
+			form.Get("data[metadata][invoice_id]"))),
+	}
+	if evt.InvoiceID == 0 {
+		applyDescription(evt, firstNonEmpty(form.Get("description"), form.Get("data[description]")))
+	}
+	// Some providers wrap JSON in a 'payload' field
+	if p := strings.TrimSpace(form.Get("payload")); p != "" && evt.GatewayRef == "" {
+		var t map[string]any
+		if err := json.Unmarshal([]byte(p), &t); err == nil {
+			if dm, ok := t["data"].(map[string]any); ok {
+				mergeMoyasarData(evt, dm)
+			}
+		}
+	}
+	return evt
+}
+
+// parseMoyasarJSON reads a JSON webhook: either the payment object or an event envelope with data{...}
+func parseMoyasarJSON(raw []byte) *WebhookEvent {
+	var flat struct {
+		ID       string `json:"id"`
+		Status   string `json:"status"`
+		Amount   int64  `json:"amount"`
+		Captured int64  `json:"captured"`
+		Currency string `json:"currency"`
+	}
+	_ = json.Unmarshal(raw, &flat)
+	evt := &WebhookEvent{
+		GatewayRef: flat.ID,
+		Status:     flat.Status,
+		Amount:     flat.Amount,
+		Captured:   flat.Captured,
+		Currency:   flat.Currency,
+	}
+
+	var rawMap map[string]any
+	if err := json.Unmarshal(raw, &rawMap); err != nil {
+		return evt
+	}
+	if dm, ok := rawMap["data"].(map[string]any); ok {
+		mergeMoyasarData(evt, dm)
+	}
+	if m, ok := rawMap["metadata"].(map[string]any); ok {
+		if v := anyInt64(m["invoice_id"]); v != 0 {
+			evt.InvoiceID = v
+		}
+	}
+	if evt.InvoiceID == 0 {
+		evt.InvoiceID = anyInt64(rawMap["invoice_id"])
+	}
+	if evt.InvoiceID == 0 {
+		if d, ok := rawMap["description"].(string); ok {
+			applyDescription(evt, d)
+		}
+	}
+	// If status is still empty, map it from the event type
+	if evt.Status == "" {
+		if typ, ok := rawMap["type"].(string); ok {
+			lt := strings.ToLower(typ)
+			switch {
+			case strings.Contains(lt, "captured"), strings.Contains(lt, "paid"), strings.Contains(lt, "succeeded"):
+				evt.Status = "paid"
+			case strings.Contains(lt, "authorized"):
+				evt.Status = "authorized"
+			case strings.Contains(lt, "failed"), strings.Contains(lt, "void"):
+				evt.Status = "failed"
+			}
+		}
+	}
+	return evt
+}
+
+// mergeMoyasarData fills missing fields from an envelope's data object
+func mergeMoyasarData(evt *WebhookEvent, dm map[string]any) {
+	if v, ok := dm["id"].(string); ok && evt.GatewayRef == "" {
+		evt.GatewayRef = v
+	}
+	if v, ok := dm["status"].(string); ok && evt.Status == "" {
+		evt.Status = v
+	}
+	if v, ok := dm["currency"].(string); ok && evt.Currency == "" {
+		evt.Currency = v
+	}
+	if v, ok := dm["amount"].(float64); ok && evt.Amount == 0 {
+		evt.Amount = int64(v)
+	}
+	if v, ok := dm["captured"].(float64); ok && evt.Captured == 0 {
+		evt.Captured = int64(v)
+	}
+	if m, ok := dm["metadata"].(map[string]any); ok && evt.InvoiceID == 0 {
+		evt.InvoiceID = anyInt64(m["invoice_id"])
+	}
+	if evt.InvoiceID == 0 && evt.InvoiceNumber == "" {
+		if d, ok := dm["description"].(string); ok {
+			applyDescription(evt, d)
+		}
+	}
+}
+
+// applyDescription derives the invoice from "invoice:<id>" or "... Invoice #INV-YYYY-NNNNNN"
+func applyDescription(evt *WebhookEvent, desc string) {
+	desc = strings.TrimSpace(desc)
+	if strings.HasPrefix(strings.ToLower(desc), "invoice:") {
+		if v, err := strconv.ParseInt(strings.TrimSpace(desc[len("invoice:"):]), 10, 64); err == nil {
+			evt.InvoiceID = v
+			return
+		}
+	}
+	if parts := strings.Split(desc, "Invoice #INV-"); len(parts) > 1 {
+		invNum := strings.Split(strings.TrimSpace(parts[1]), " ")[0]
+		evt.InvoiceNumber = "INV-" + invNum
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func parseInt64(s string) int64 {
+	v, _ := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return v
+}
+
+func anyInt64(v any) int64 {
+	switch t := v.(type) {
+	case string:
+		return parseInt64(t)
+	case float64:
+		return int64(t)
+	}
+	return 0
+}
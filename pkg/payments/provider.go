@@ -0,0 +1,153 @@
+// Package payments defines the payment gateway abstraction used by checkout and the
+// payments worker, with a Moyasar implementation and an in-process fake for offline testing.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"encore.dev"
+
+	"encore.app/pkg/config"
+)
+
+var (
+	// ErrInvalidSignature is returned by VerifyWebhook when the payload is not authentic
+	ErrInvalidSignature = errors.New("payments: invalid webhook signature")
+	// ErrDisabled is returned by Active when payments are turned off in system settings
+	ErrDisabled = errors.New("payments: disabled")
+	// ErrNotFound is returned when the gateway does not know the reference
+	ErrNotFound = errors.New("payments: payment not found")
+)
+
+// Provider is a payment gateway. Amounts are in the currency's minor unit (halalas for SAR).
+type Provider interface {
+	// Name is the value stored in payments.gateway and used in the webhook path
+	Name() string
+	// CreateSession starts a hosted checkout and returns the gateway reference and redirect URL
+	CreateSession(ctx context.Context, req *SessionRequest) (*Session, error)
+	// VerifyWebhook authenticates a webhook delivery and normalizes its payload
+	VerifyWebhook(ctx context.Context, header http.Header, raw []byte) (*WebhookEvent, error)
+	// Refund refunds part or all of a captured payment
+	Refund(ctx context.Context, gatewayRef string, amount int64) (*RefundResult, error)
+	// FetchStatus reads the current state of a payment from the gateway
+	FetchStatus(ctx context.Context, gatewayRef string) (*PaymentStatus, error)
+	// ListPayments returns one page (starting at 1) of the payments created at the gateway
+	// between from and to, for reconciliation
+	ListPayments(ctx context.Context, from, to time.Time, page int) (*PaymentList, error)
+}
+
+// SessionRequest describes a hosted checkout session
+type SessionRequest struct {
+	Amount      int64
+	Currency    string
+	Description string
+	SuccessURL  string // browser redirect after payment
+	BackURL     string // browser redirect when the customer goes back
+	WebhookURL  string // server-to-server notification endpoint (optional)
+	Metadata    map[string]string
+}
+
+// Session is a created hosted checkout
+type Session struct {
+	GatewayRef string
+	URL        string
+}
+
+// WebhookEvent is a normalized webhook payload
+type WebhookEvent struct {
+	GatewayRef    string
+	Status        string // lower-case gateway status (paid, authorized, failed, ...)
+	Amount        int64
+	Captured      int64
+	Currency      string
+	InvoiceID     int64  // from metadata, 0 when absent
+	InvoiceNumber string // from the description when metadata is missing
+}
+
+// PaymentStatus is the gateway-side state of a payment
+type PaymentStatus struct {
+	GatewayRef string
+	Status     string
+	Amount     int64
+	Captured   int64
+	Refunded   int64
+	Currency   string
+	InvoiceID  int64
+	UpdatedAt  time.Time // last state change at the gateway, zero when unknown
+	// OtherRefs are further references the gateway knows the payment by (the payment ids of
+	// a Moyasar invoice), which may be what payments.gateway_ref holds
+	OtherRefs []string
+}
+
+// PaymentList is a page of gateway payments
+type PaymentList struct {
+	Items    []PaymentStatus
+	NextPage int // 0 after the last page
+}
+
+// RefundResult is the outcome of a refund request
+type RefundResult struct {
+	GatewayRefundID string // empty when the gateway does not issue refund ids
+	Status          string
+}
+
+var (
+	fakeOnce sync.Once
+	fake     *FakeProvider
+)
+
+// Get returns the provider registered under name
+func Get(name string) (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "moyasar":
+		return moyasarProvider{}, nil
+	case FakeProviderName:
+		if !FakeAllowed() {
+			return nil, fmt.Errorf("payments: fake provider is not available in this environment")
+		}
+		return Fake(), nil
+	}
+	return nil, fmt.Errorf("payments: unknown provider %q", name)
+}
+
+// Configured returns the provider selected in system settings (payments.provider)
+func Configured() (Provider, error) {
+	name := ""
+	if s := config.GetSettings(); s != nil {
+		name = s.PaymentsProvider
+	}
+	return Get(name)
+}
+
+// Active returns the configured provider when payments are enabled
+func Active() (Provider, error) {
+	s := config.GetSettings()
+	if s == nil || !s.PaymentsEnabled {
+		return nil, ErrDisabled
+	}
+	return Configured()
+}
+
+// FakeAllowed reports whether the fake provider may be used (never in production)
+func FakeAllowed() bool {
+	switch encore.Meta().Environment.Type {
+	case encore.EnvLocal, encore.EnvDevelopment, encore.EnvTest:
+		return true
+	}
+	return false
+}
+
+// Fake returns the process-wide fake provider
+func Fake() *FakeProvider {
+	fakeOnce.Do(func() {
+		base := encore.Meta().APIBaseURL
+		fake = NewFakeProvider(randomSecret(), strings.TrimRight(base.String(), "/"))
+	})
+	return fake
+}
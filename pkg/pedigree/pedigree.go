@@ -0,0 +1,502 @@
+// Package pedigree reads and edits pigeon family trees (pedigree_birds). A bird is keyed by
+// its ring number and points to its sire and dam; birds sold in the store are linked to
+// their pigeons row, ancestors bred elsewhere are stored without a product.
+//
+// Functions take a Querier so they work on db.Stdlib() or inside a transaction.
+package pedigree
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultGenerations is the depth shown with a product or auction (bird, parents, grandparents)
+	DefaultGenerations = 3
+	// MaxGenerations bounds the pedigree endpoint
+	MaxGenerations = 5
+	// resultsPerBird is how many race results are attached to each bird in a tree
+	resultsPerBird = 5
+)
+
+var (
+	// ErrInvalidRing is returned for an empty or overlong ring number
+	ErrInvalidRing = errors.New("pedigree: invalid ring number")
+	// ErrCycle is returned when a parent link would make a bird its own ancestor
+	ErrCycle = errors.New("pedigree: parent is a descendant of the bird")
+	// ErrParentSex is returned when a hen is set as sire or a cock as dam
+	ErrParentSex = errors.New("pedigree: parent sex does not match its role")
+	// ErrSameParents is returned when sire and dam are the same bird
+	ErrSameParents = errors.New("pedigree: sire and dam must be different birds")
+	// ErrNotFound is returned when a bird or result does not exist
+	ErrNotFound = errors.New("pedigree: not found")
+)
+
+// Querier is satisfied by *sql.DB and *sql.Tx
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// RaceResult is a bird's placing in a race
+type RaceResult struct {
+	ID         int64    `json:"id"`
+	RaceName   string   `json:"race_name"`
+	RaceDate   *string  `json:"race_date,omitempty"` // YYYY-MM-DD
+	DistanceKM *float64 `json:"distance_km,omitempty"`
+	Position   *int     `json:"position,omitempty"`
+	TotalBirds *int     `json:"total_birds,omitempty"`
+	SpeedMPM   *float64 `json:"speed_mpm,omitempty"` // meters per minute
+	Notes      string   `json:"notes,omitempty"`
+}
+
+// Bird is one pigeon in a pedigree
+type Bird struct {
+	ID         int64        `json:"id"`
+	RingNumber string       `json:"ring_number"`
+	ProductID  *int64       `json:"product_id,omitempty"` // set for birds listed in the store
+	Internal   bool         `json:"internal"`
+	Name       string       `json:"name,omitempty"`
+	Sex        string       `json:"sex"`
+	BirthYear  *int         `json:"birth_year,omitempty"`
+	Color      string       `json:"color,omitempty"`
+	Breeder    string       `json:"breeder,omitempty"`
+	Notes      string       `json:"notes,omitempty"`
+	Results    []RaceResult `json:"results,omitempty"`
+}
+
+// Node is a bird with its ancestors
+type Node struct {
+	Bird
+	Sire *Node `json:"sire,omitempty"`
+	Dam  *Node `json:"dam,omitempty"`
+}
+
+// Depth returns the number of generations in the tree
+func (n *Node) Depth() int {
+	if n == nil {
+		return 0
+	}
+	s, d := n.Sire.Depth(), n.Dam.Depth()
+	if d > s {
+		s = d
+	}
+	return s + 1
+}
+
+// Entry is a bird placed in a flattened pedigree
+type Entry struct {
+	Position   int  `json:"position"`   // Ahnentafel number: 1 is the bird, 2n the sire and 2n+1 the dam of n
+	Generation int  `json:"generation"` // 1 for the bird, 2 for its parents...
+	Bird       Bird `json:"bird"`
+}
+
+// Entries flattens the tree in Ahnentafel order (API responses carry this flat form)
+func (n *Node) Entries() []Entry {
+	var out []Entry
+	var walk func(n *Node, pos, gen int)
+	walk = func(n *Node, pos, gen int) {
+		if n == nil {
+			return
+		}
+		out = append(out, Entry{Position: pos, Generation: gen, Bird: n.Bird})
+		walk(n.Sire, 2*pos, gen+1)
+		walk(n.Dam, 2*pos+1, gen+1)
+	}
+	walk(n, 1, 1)
+	sort.Slice(out, func(i, j int) bool { return out[i].Position < out[j].Position })
+	return out
+}
+
+// NormalizeRing trims and upper-cases a ring number (rings are matched case-insensitively)
+func NormalizeRing(ring string) (string, error) {
+	ring = strings.ToUpper(strings.Join(strings.Fields(ring), " "))
+	if ring == "" || len(ring) > 40 {
+		return "", ErrInvalidRing
+	}
+	return ring, nil
+}
+
+// ClampGenerations applies the default and the maximum depth
+func ClampGenerations(n int) int {
+	if n <= 0 {
+		return DefaultGenerations
+	}
+	if n > MaxGenerations {
+		return MaxGenerations
+	}
+	return n
+}
+
+// links holds the parent ids of a loaded bird
+type links struct{ sire, dam int64 }
+
+// build assembles the tree rooted at id from the loaded birds, down to gens generations.
+// A bird that appears twice (line breeding) is repeated in every position it holds.
+func build(birds map[int64]*Bird, parents map[int64]links, id int64, gens int) *Node {
+	b, ok := birds[id]
+	if !ok || gens <= 0 {
+		return nil
+	}
+	n := &Node{Bird: *b}
+	if p := parents[id]; gens > 1 {
+		n.Sire = build(birds, parents, p.sire, gens-1)
+		n.Dam = build(birds, parents, p.dam, gens-1)
+	}
+	return n
+}
+
+// ancestorsCTE selects the bird $1 and its ancestors up to $2 generations as "tree"
+const ancestorsCTE = `
+	WITH RECURSIVE tree AS (
+		SELECT b.id, b.sire_id, b.dam_id, 1 AS gen FROM pedigree_birds b WHERE b.id = $1
+		UNION ALL
+		SELECT b.id, b.sire_id, b.dam_id, t.gen + 1
+		FROM tree t JOIN pedigree_birds b ON b.id = t.sire_id OR b.id = t.dam_id
+		WHERE t.gen < $2
+	)`
+
+// Load returns the pedigree of bird id with the given number of generations
+func Load(ctx context.Context, q Querier, id int64, generations int) (*Node, error) {
+	generations = ClampGenerations(generations)
+	rows, err := q.QueryContext(ctx, ancestorsCTE+`
+		SELECT b.id, b.ring_number, b.product_id, COALESCE(b.name, ''), b.sex::text, b.birth_year,
+		       COALESCE(b.color, ''), COALESCE(b.breeder, ''), COALESCE(b.notes, ''),
+		       COALESCE(b.sire_id, 0), COALESCE(b.dam_id, 0)
+		FROM pedigree_birds b
+		WHERE b.id IN (SELECT id FROM tree)
+	`, id, generations)
+	if err != nil {
+		return nil, fmt.Errorf("pedigree: load birds: %w", err)
+	}
+	defer rows.Close()
+
+	birds := map[int64]*Bird{}
+	parents := map[int64]links{}
+	for rows.Next() {
+		var b Bird
+		var productID sql.NullInt64
+		var birthYear sql.NullInt64
+		var l links
+		if err := rows.Scan(&b.ID, &b.RingNumber, &productID, &b.Name, &b.Sex, &birthYear,
+			&b.Color, &b.Breeder, &b.Notes, &l.sire, &l.dam); err != nil {
+			return nil, fmt.Errorf("pedigree: scan bird: %w", err)
+		}
+		if productID.Valid {
+			b.ProductID = &productID.Int64
+			b.Internal = true
+		}
+		if birthYear.Valid {
+			y := int(birthYear.Int64)
+			b.BirthYear = &y
+		}
+		birds[b.ID] = &b
+		parents[b.ID] = l
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pedigree: load birds: %w", err)
+	}
+	if _, ok := birds[id]; !ok {
+		return nil, ErrNotFound
+	}
+	if err := loadResults(ctx, q, id, generations, birds); err != nil {
+		return nil, err
+	}
+	return build(birds, parents, id, generations), nil
+}
+
+// loadResults attaches each bird's best recent race results
+func loadResults(ctx context.Context, q Querier, id int64, generations int, birds map[int64]*Bird) error {
+	rows, err := q.QueryContext(ctx, ancestorsCTE+`
+		SELECT bird_id, id, race_name, to_char(race_date, 'YYYY-MM-DD'), distance_km, position, total_birds, speed_mpm, COALESCE(notes, '')
+		FROM (
+			SELECT r.*, ROW_NUMBER() OVER (PARTITION BY r.bird_id ORDER BY r.position ASC NULLS LAST, r.race_date DESC NULLS LAST, r.id DESC) AS rn
+			FROM pedigree_race_results r
+			WHERE r.bird_id IN (SELECT id FROM tree)
+		) ranked
+		WHERE rn <= $3
+		ORDER BY bird_id, rn
+	`, id, generations, resultsPerBird)
+	if err != nil {
+		return fmt.Errorf("pedigree: load results: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var birdID int64
+		r, err := scanResult(rows, &birdID)
+		if err != nil {
+			return err
+		}
+		if b := birds[birdID]; b != nil {
+			b.Results = append(b.Results, *r)
+		}
+	}
+	return rows.Err()
+}
+
+func scanResult(rows *sql.Rows, birdID *int64) (*RaceResult, error) {
+	var r RaceResult
+	var date sql.NullString
+	var dist, speed sql.NullFloat64
+	var pos, total sql.NullInt64
+	if err := rows.Scan(birdID, &r.ID, &r.RaceName, &date, &dist, &pos, &total, &speed, &r.Notes); err != nil {
+		return nil, fmt.Errorf("pedigree: scan result: %w", err)
+	}
+	if date.Valid {
+		r.RaceDate = &date.String
+	}
+	if dist.Valid {
+		r.DistanceKM = &dist.Float64
+	}
+	if speed.Valid {
+		r.SpeedMPM = &speed.Float64
+	}
+	if pos.Valid {
+		p := int(pos.Int64)
+		r.Position = &p
+	}
+	if total.Valid {
+		t := int(total.Int64)
+		r.TotalBirds = &t
+	}
+	return &r, nil
+}
+
+// LoadByRing returns the pedigree of the bird with the given ring number
+func LoadByRing(ctx context.Context, q Querier, ring string, generations int) (*Node, error) {
+	ring, err := NormalizeRing(ring)
+	if err != nil {
+		return nil, err
+	}
+	var id int64
+	err = q.QueryRowContext(ctx, `SELECT id FROM pedigree_birds WHERE upper(ring_number) = $1`, ring).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pedigree: find bird: %w", err)
+	}
+	return Load(ctx, q, id, generations)
+}
+
+// LoadForProduct returns the pedigree of a pigeon product. A pigeon without pedigree data is
+// returned as a single node built from its product details; ErrNotFound means no pigeon.
+func LoadForProduct(ctx context.Context, q Querier, productID int64, generations int) (*Node, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, `SELECT id FROM pedigree_birds WHERE product_id = $1`, productID).Scan(&id)
+	if err == nil {
+		return Load(ctx, q, id, generations)
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("pedigree: find bird: %w", err)
+	}
+
+	n := &Node{Bird: Bird{ProductID: &productID, Internal: true}}
+	var birthDate sql.NullTime
+	err = q.QueryRowContext(ctx, `
+		SELECT pg.ring_number, p.title, pg.sex::text, pg.birth_date
+		FROM pigeons pg JOIN products p ON p.id = pg.product_id
+		WHERE pg.product_id = $1
+	`, productID).Scan(&n.RingNumber, &n.Name, &n.Sex, &birthDate)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pedigree: read pigeon: %w", err)
+	}
+	if birthDate.Valid {
+		y := birthDate.Time.Year()
+		n.BirthYear = &y
+	}
+	return n, nil
+}
+
+// BirdInput creates or updates a bird. Nil fields are left unchanged; an empty SireRing or
+// DamRing clears that parent. Unknown parent rings are added as external birds.
+type BirdInput struct {
+	RingNumber string  `json:"ring_number"`
+	Name       *string `json:"name,omitempty"`
+	Sex        *string `json:"sex,omitempty"` // male | female | unknown
+	BirthYear  *int    `json:"birth_year,omitempty"`
+	Color      *string `json:"color,omitempty"`
+	Breeder    *string `json:"breeder,omitempty"`
+	Notes      *string `json:"notes,omitempty"`
+	SireRing   *string `json:"sire_ring,omitempty"`
+	DamRing    *string `json:"dam_ring,omitempty"`
+}
+
+// Validate checks the input fields that do not need the database
+func (in *BirdInput) Validate() error {
+	if _, err := NormalizeRing(in.RingNumber); err != nil {
+		return err
+	}
+	if in.Sex != nil && *in.Sex != "male" && *in.Sex != "female" && *in.Sex != "unknown" {
+		return fmt.Errorf("pedigree: invalid sex %q", *in.Sex)
+	}
+	if in.BirthYear != nil && (*in.BirthYear < 1900 || *in.BirthYear > time.Now().Year()+1) {
+		return fmt.Errorf("pedigree: invalid birth year %d", *in.BirthYear)
+	}
+	if in.SireRing != nil && in.DamRing != nil && *in.SireRing != "" {
+		s, _ := NormalizeRing(*in.SireRing)
+		d, _ := NormalizeRing(*in.DamRing)
+		if s == d {
+			return ErrSameParents
+		}
+	}
+	return nil
+}
+
+// Upsert creates or updates a bird and its parent links; run it in a transaction
+func Upsert(ctx context.Context, tx *sql.Tx, in BirdInput) (int64, error) {
+	if err := in.Validate(); err != nil {
+		return 0, err
+	}
+	ring, _ := NormalizeRing(in.RingNumber)
+	id, err := ensureBird(ctx, tx, ring)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE pedigree_birds SET
+			name = COALESCE($2, name),
+			sex = COALESCE($3::pigeon_sex, sex),
+			birth_year = COALESCE($4, birth_year),
+			color = COALESCE($5, color),
+			breeder = COALESCE($6, breeder),
+			notes = COALESCE($7, notes)
+		WHERE id = $1
+	`, id, trimmed(in.Name), in.Sex, in.BirthYear, trimmed(in.Color), trimmed(in.Breeder), trimmed(in.Notes)); err != nil {
+		return 0, fmt.Errorf("pedigree: update bird: %w", err)
+	}
+	if in.SireRing != nil {
+		if err := setParent(ctx, tx, id, "sire_id", "male", *in.SireRing); err != nil {
+			return 0, err
+		}
+	}
+	if in.DamRing != nil {
+		if err := setParent(ctx, tx, id, "dam_id", "female", *in.DamRing); err != nil {
+			return 0, err
+		}
+	}
+	var sire, dam sql.NullInt64
+	if err := tx.QueryRowContext(ctx, `SELECT sire_id, dam_id FROM pedigree_birds WHERE id = $1`, id).Scan(&sire, &dam); err != nil {
+		return 0, fmt.Errorf("pedigree: read parents: %w", err)
+	}
+	if sire.Valid && dam.Valid && sire.Int64 == dam.Int64 {
+		return 0, ErrSameParents
+	}
+	return id, nil
+}
+
+func trimmed(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	t := strings.TrimSpace(*s)
+	return &t
+}
+
+// ensureBird returns the id of the bird with ring, adding it (linked to the pigeon with the
+// same ring, if any) when missing
+func ensureBird(ctx context.Context, q Querier, ring string) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, `
+		INSERT INTO pedigree_birds (ring_number, product_id, sex, birth_year)
+		SELECT $1::text, pg.product_id, COALESCE(pg.sex, 'unknown'), EXTRACT(YEAR FROM pg.birth_date)::int
+		FROM (SELECT 1) one
+		LEFT JOIN pigeons pg ON upper(pg.ring_number) = $1
+		ON CONFLICT ((upper(ring_number))) DO UPDATE SET ring_number = pedigree_birds.ring_number
+		RETURNING id
+	`, ring).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("pedigree: ensure bird: %w", err)
+	}
+	return id, nil
+}
+
+// setParent links (or with an empty ring, unlinks) the sire_id/dam_id of bird id
+func setParent(ctx context.Context, tx *sql.Tx, id int64, column, sex, ring string) error {
+	if strings.TrimSpace(ring) == "" {
+		_, err := tx.ExecContext(ctx, `UPDATE pedigree_birds SET `+column+` = NULL WHERE id = $1`, id)
+		return err
+	}
+	ring, err := NormalizeRing(ring)
+	if err != nil {
+		return err
+	}
+	parentID, err := ensureBird(ctx, tx, ring)
+	if err != nil {
+		return err
+	}
+	// The parent must not be the bird itself or one of its descendants
+	var cycle bool
+	if err := tx.QueryRowContext(ctx, `
+		WITH RECURSIVE descendants AS (
+			SELECT id FROM pedigree_birds WHERE id = $1
+			UNION
+			SELECT b.id FROM pedigree_birds b JOIN descendants d ON b.sire_id = d.id OR b.dam_id = d.id
+		)
+		SELECT EXISTS (SELECT 1 FROM descendants WHERE id = $2)
+	`, id, parentID).Scan(&cycle); err != nil {
+		return fmt.Errorf("pedigree: check ancestry: %w", err)
+	}
+	if cycle {
+		return ErrCycle
+	}
+	// An unknown sex is inferred from the role; a conflicting one is rejected
+	res, err := tx.ExecContext(ctx, `
+		UPDATE pedigree_birds SET sex = $2::pigeon_sex WHERE id = $1 AND sex IN ('unknown', $2::pigeon_sex)
+	`, parentID, sex)
+	if err != nil {
+		return fmt.Errorf("pedigree: set parent sex: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrParentSex
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE pedigree_birds SET `+column+` = $2 WHERE id = $1`, id, parentID); err != nil {
+		return fmt.Errorf("pedigree: set parent: %w", err)
+	}
+	return nil
+}
+
+// AddResult records a race result for the bird with the given ring (added if missing)
+func AddResult(ctx context.Context, q Querier, ring string, r RaceResult) (*RaceResult, error) {
+	ring, err := NormalizeRing(ring)
+	if err != nil {
+		return nil, err
+	}
+	r.RaceName = strings.TrimSpace(r.RaceName)
+	if r.RaceName == "" {
+		return nil, errors.New("pedigree: race name is required")
+	}
+	birdID, err := ensureBird(ctx, q, ring)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.QueryRowContext(ctx, `
+		INSERT INTO pedigree_race_results (bird_id, race_name, race_date, distance_km, position, total_birds, speed_mpm, notes)
+		VALUES ($1, $2, $3::date, $4, $5, $6, $7, NULLIF($8, ''))
+		RETURNING id
+	`, birdID, r.RaceName, r.RaceDate, r.DistanceKM, r.Position, r.TotalBirds, r.SpeedMPM, strings.TrimSpace(r.Notes)).Scan(&r.ID); err != nil {
+		return nil, fmt.Errorf("pedigree: add result: %w", err)
+	}
+	return &r, nil
+}
+
+// DeleteResult removes a race result
+func DeleteResult(ctx context.Context, q Querier, id int64) error {
+	res, err := q.ExecContext(ctx, `DELETE FROM pedigree_race_results WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("pedigree: delete result: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package pedigree
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNormalizeRing(t *testing.T) {
+	got, err := NormalizeRing("  sa-2024  12345 ")
+	if err != nil || got != "SA-2024 12345" {
+		t.Fatalf("NormalizeRing = %q, %v", got, err)
+	}
+	if _, err := NormalizeRing("   "); err != ErrInvalidRing {
+		t.Fatalf("empty ring err = %v", err)
+	}
+}
+
+func TestClampGenerations(t *testing.T) {
+	for in, want := range map[int]int{0: DefaultGenerations, -1: DefaultGenerations, 2: 2, 9: MaxGenerations} {
+		if got := ClampGenerations(in); got != want {
+			t.Errorf("ClampGenerations(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	birds := map[int64]*Bird{
+		1: {ID: 1, RingNumber: "A"},
+		2: {ID: 2, RingNumber: "S"},
+		3: {ID: 3, RingNumber: "D"},
+		4: {ID: 4, RingNumber: "SS"},
+	}
+	parents := map[int64]links{1: {sire: 2, dam: 3}, 2: {sire: 4}, 3: {sire: 4}}
+
+	root := build(birds, parents, 1, 3)
+	if root.Depth() != 3 || root.Sire.RingNumber != "S" || root.Dam.RingNumber != "D" {
+		t.Fatalf("unexpected tree %+v", root)
+	}
+	// Line breeding: the same grandsire appears on both sides
+	if root.Sire.Sire.RingNumber != "SS" || root.Dam.Sire.RingNumber != "SS" || root.Sire.Dam != nil {
+		t.Fatalf("grandparents = %+v / %+v", root.Sire, root.Dam)
+	}
+	var positions []int
+	for _, e := range root.Entries() {
+		positions = append(positions, e.Position)
+	}
+	if fmt.Sprint(positions) != "[1 2 3 4 6]" {
+		t.Fatalf("entry positions = %v", positions)
+	}
+	if got := build(birds, parents, 1, 2); got.Depth() != 2 {
+		t.Fatalf("depth = %d, want 2", got.Depth())
+	}
+	if build(birds, parents, 99, 3) != nil {
+		t.Fatal("unknown bird should build nil")
+	}
+}
+
+func TestBirdInputValidate(t *testing.T) {
+	same := " x1 "
+	other := "X1"
+	in := BirdInput{RingNumber: "A1", SireRing: &same, DamRing: &other}
+	if err := in.Validate(); err != ErrSameParents {
+		t.Fatalf("same parents err = %v", err)
+	}
+	sex := "hen"
+	if err := (&BirdInput{RingNumber: "A1", Sex: &sex}).Validate(); err == nil {
+		t.Fatal("invalid sex accepted")
+	}
+	if err := (&BirdInput{RingNumber: ""}).Validate(); err != ErrInvalidRing {
+		t.Fatalf("empty ring err = %v", err)
+	}
+}
@@ -0,0 +1,464 @@
+// Package qrcode encodes short payloads (such as the ZATCA invoice QR) as QR Code symbols
+// per ISO/IEC 18004. Only byte mode and error-correction level M are supported, which is
+// all the invoice documents need; callers draw the returned module grid themselves.
+package qrcode
+
+import (
+	"errors"
+)
+
+// ErrTooLong is returned when the payload does not fit in a version 40 symbol
+var ErrTooLong = errors.New("qrcode: payload too long")
+
+// Code is an encoded symbol. Module (0,0) is the top-left corner.
+type Code struct {
+	Version int
+	Size    int
+	modules []bool
+}
+
+// Dark reports whether the module at column x, row y is dark
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y*c.Size+x]
+}
+
+// Error correction level M, indexed by version (index 0 unused)
+var (
+	eccCodewordsPerBlock = [41]int{-1,
+		10, 16, 26, 18, 24, 16, 18, 22, 22, 26,
+		30, 22, 22, 24, 24, 28, 28, 26, 26, 26,
+		26, 28, 28, 28, 28, 28, 28, 28, 28, 28,
+		28, 28, 28, 28, 28, 28, 28, 28, 28, 28}
+	numErrorCorrectionBlocks = [41]int{-1,
+		1, 1, 1, 2, 2, 4, 4, 4, 5, 5,
+		5, 8, 9, 9, 10, 10, 11, 13, 14, 16,
+		17, 17, 18, 20, 21, 23, 25, 26, 28, 29,
+		31, 33, 35, 37, 38, 40, 43, 45, 47, 49}
+)
+
+// formatBitsM is the two-bit format indicator for level M
+const formatBitsM = 0
+
+// Encode builds the smallest symbol that holds data in byte mode
+func Encode(data []byte) (*Code, error) {
+	version := 0
+	for v := 1; v <= 40; v++ {
+		ccBits := 8
+		if v > 9 {
+			ccBits = 16
+		}
+		if len(data) < 1<<ccBits && 4+ccBits+8*len(data) <= numDataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	var bb bitBuffer
+	bb.append(0x4, 4) // byte mode
+	if version > 9 {
+		bb.append(uint32(len(data)), 16)
+	} else {
+		bb.append(uint32(len(data)), 8)
+	}
+	for _, b := range data {
+		bb.append(uint32(b), 8)
+	}
+	capacity := numDataCodewords(version) * 8
+	bb.append(0, min(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := uint32(0xEC); len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	codewords := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+
+	c := newCode(version)
+	c.drawFunctionPatterns()
+	c.drawCodewords(addECCAndInterleave(version, codewords))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // XOR again to undo
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	c.function = nil
+	return &c.Code, nil
+}
+
+type builder struct {
+	Code
+	function []bool
+}
+
+func newCode(version int) *builder {
+	size := version*4 + 17
+	return &builder{
+		Code:     Code{Version: version, Size: size, modules: make([]bool, size*size)},
+		function: make([]bool, size*size),
+	}
+}
+
+func (c *builder) set(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+	c.function[y*c.Size+x] = true
+}
+
+func (c *builder) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	pos := alignmentPositions(c.Version)
+	n := len(pos)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue // overlaps a finder pattern
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(pos[i]+dx, pos[j]+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	c.drawFormatBits(0) // reserve the area; real bits are drawn after masking
+	c.drawVersion()
+}
+
+func (c *builder) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			c.set(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+// formatBits returns the 15-bit BCH-protected format word for level M and mask
+func formatBits(mask int) uint32 {
+	data := uint32(formatBitsM<<3 | mask)
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *builder) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>uint(i))&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true) // always-dark module
+}
+
+// versionBits returns the 18-bit BCH-protected version word (versions 7+)
+func versionBits(version int) uint32 {
+	rem := uint32(version)
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return uint32(version)<<12 | rem
+}
+
+func (c *builder) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionBits(c.Version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 != 0
+		a, b := c.Size-11+i%3, i/3
+		c.set(a, b, dark)
+		c.set(b, a, dark)
+	}
+}
+
+// drawCodewords places data in the zigzag pattern, two columns at a time from the right
+func (c *builder) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert // upward column
+				}
+				if c.function[y*c.Size+x] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[y*c.Size+x] = (data[i>>3]>>(7-uint(i&7)))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func (c *builder) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.function[y*c.Size+x] {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol with the four rules from the spec; lower is easier to scan
+func (c *builder) penalty() int {
+	n := c.Size
+	score := 0
+	dark := 0
+	line := make([]bool, n)
+	for pass := 0; pass < 2; pass++ {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if pass == 0 {
+					line[j] = c.Dark(j, i)
+				} else {
+					line[j] = c.Dark(i, j)
+				}
+			}
+			// Rule 1: runs of five or more same-coloured modules
+			run := 1
+			for j := 1; j <= n; j++ {
+				if j < n && line[j] == line[j-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += 3 + run - 5
+				}
+				run = 1
+			}
+			// Rule 3: finder-like 1:1:3:1:1 patterns with four light modules on either side
+			for j := 0; j+11 <= n; j++ {
+				if matches(line[j:j+11], finderLeft) || matches(line[j:j+11], finderRight) {
+					score += 40
+				}
+			}
+		}
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			d := c.Dark(x, y)
+			if d {
+				dark++
+			}
+			// Rule 2: 2x2 blocks of one colour
+			if x+1 < n && y+1 < n && d == c.Dark(x+1, y) && d == c.Dark(x, y+1) && d == c.Dark(x+1, y+1) {
+				score += 3
+			}
+		}
+	}
+	// Rule 4: balance of dark and light modules
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	if k > 0 {
+		score += k * 10
+	}
+	return score
+}
+
+var (
+	finderLeft  = []bool{true, false, true, true, true, false, true, false, false, false, false}
+	finderRight = []bool{false, false, false, false, true, false, true, true, true, false, true}
+)
+
+func matches(line, pattern []bool) bool {
+	for i := range pattern {
+		if line[i] != pattern[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// alignmentPositions returns the centre coordinates of alignment patterns for a version
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	size := version*4 + 17
+	out := make([]int, n)
+	out[0] = 6
+	for i, pos := n-1, size-7; i >= 1; i, pos = i-1, pos-step {
+		out[i] = pos
+	}
+	return out
+}
+
+// numRawDataModules is the number of modules available for data and ECC
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		n := version/7 + 2
+		result -= (25*n-10)*n - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func numDataCodewords(version int) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[version]*numErrorCorrectionBlocks[version]
+}
+
+// addECCAndInterleave splits data into blocks, appends Reed-Solomon ECC to each and
+// interleaves the result
+func addECCAndInterleave(version int, data []byte) []byte {
+	numBlocks := numErrorCorrectionBlocks[version]
+	eccLen := eccCodewordsPerBlock[version]
+	raw := numRawDataModules(version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < numBlocks; i++ {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		dat := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(dat, divisor)
+		if i < numShort {
+			dat = append(dat, 0) // padding so all blocks line up; skipped below
+		}
+		blocks[i] = append(dat, ecc...)
+	}
+
+	out := make([]byte, 0, raw)
+	for i := range blocks[0] {
+		for j, b := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				out = append(out, b[i])
+			}
+		}
+	}
+	return out
+}
+
+// rsDivisor returns the generator polynomial of the given degree, highest term omitted
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(val uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (val>>uint(i))&1 != 0)
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,188 @@
+package qrcode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// Version 1-M "HELLO WORLD" example from the specification walkthrough
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Fatalf("ecc = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	if got := formatBits(0); got != 0b101010000010010 {
+		t.Errorf("formatBits(0) = %015b", got)
+	}
+	if got := formatBits(5); got != 0b100000011001110 {
+		t.Errorf("formatBits(5) = %015b", got)
+	}
+	if got := versionBits(7); got != 0x07C94 {
+		t.Errorf("versionBits(7) = %#x", got)
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	cases := map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		7:  {6, 22, 38},
+		32: {6, 34, 60, 86, 112, 138},
+	}
+	for v, want := range cases {
+		got := alignmentPositions(v)
+		if len(got) != len(want) {
+			t.Fatalf("v%d: got %v, want %v", v, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("v%d: got %v, want %v", v, got, want)
+			}
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, payload := range [][]byte{
+		[]byte("hello"),
+		bytes.Repeat([]byte("AQ1 فاتورة "), 12), // roughly the size of a ZATCA TLV payload
+	} {
+		c, err := Encode(payload)
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if c.Size != c.Version*4+17 {
+			t.Fatalf("size %d for version %d", c.Size, c.Version)
+		}
+		if got := readBack(t, c); !bytes.Equal(got, payload) {
+			t.Fatalf("read back %q, want %q", got, payload)
+		}
+	}
+	if _, err := Encode(make([]byte, 3000)); err != ErrTooLong {
+		t.Fatalf("oversized payload: got %v", err)
+	}
+}
+
+// readBack decodes a symbol produced by Encode: it finds the mask from the format bits,
+// unmasks, reads the zigzag, de-interleaves, checks every block's ECC and parses byte mode.
+func readBack(t *testing.T, c *Code) []byte {
+	t.Helper()
+	var word uint32
+	for i := 0; i <= 5; i++ {
+		word |= b2u(c.Dark(8, i)) << uint(i)
+	}
+	word |= b2u(c.Dark(8, 7))<<6 | b2u(c.Dark(8, 8))<<7 | b2u(c.Dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		word |= b2u(c.Dark(14-i, 8)) << uint(i)
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == word {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format word %015b matches no mask", word)
+	}
+
+	b := newCode(c.Version)
+	b.drawFunctionPatterns()
+	copy(b.modules, c.modules)
+	b.applyMask(mask)
+
+	raw := numRawDataModules(c.Version) / 8
+	stream := make([]byte, 0, raw)
+	var cur byte
+	bits := 0
+	for right := b.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < b.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = b.Size - 1 - vert
+				}
+				if b.function[y*b.Size+x] || len(stream) == raw {
+					continue
+				}
+				cur = cur<<1 | byte(b2u(b.modules[y*b.Size+x]))
+				if bits++; bits == 8 {
+					stream, cur, bits = append(stream, cur), 0, 0
+				}
+			}
+		}
+	}
+
+	numBlocks := numErrorCorrectionBlocks[c.Version]
+	eccLen := eccCodewordsPerBlock[c.Version]
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < shortLen+1; i++ {
+		for j := 0; j < numBlocks; j++ {
+			if i == shortLen-eccLen && j < numShort {
+				blocks[j] = append(blocks[j], 0)
+				continue
+			}
+			blocks[j] = append(blocks[j], stream[k])
+			k++
+		}
+	}
+	var data []byte
+	for j, blk := range blocks {
+		n := shortLen - eccLen
+		if j >= numShort {
+			n++
+		}
+		dat := blk[:n]
+		var ecc []byte
+		if j < numShort {
+			ecc = blk[n+1:]
+		} else {
+			ecc = blk[n:]
+		}
+		if !bytes.Equal(rsRemainder(dat, rsDivisor(eccLen)), ecc) {
+			t.Fatalf("block %d: ECC mismatch", j)
+		}
+		data = append(data, dat...)
+	}
+
+	if data[0]>>4 != 0x4 {
+		t.Fatalf("mode indicator %x", data[0]>>4)
+	}
+	var bb bitBuffer
+	for _, d := range data {
+		bb.append(uint32(d), 8)
+	}
+	read := func(off, n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | int(b2u(bb[off+i]))
+		}
+		return v
+	}
+	ccBits := 8
+	if c.Version > 9 {
+		ccBits = 16
+	}
+	length := read(4, ccBits)
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = byte(read(4+ccBits+8*i, 8))
+	}
+	return out
+}
+
+func b2u(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
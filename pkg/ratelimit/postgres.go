@@ -0,0 +1,254 @@
+// Package ratelimit provides rate limiting functionality for authentication endpoints
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"encore.app/pkg/config"
+	"encore.dev/storage/sqldb"
+)
+
+// PostgresStorage keeps rate limit counters in the rate_limit_counters table so limits are
+// shared by all instances and survive deploys. Attempts are counted with a sliding window
+// counter: the attempts of the current fixed window plus those of the previous window,
+// weighted by how much of it the sliding window still covers.
+type PostgresStorage struct {
+	db *sqldb.Database
+}
+
+// NewPostgresStorage creates a storage backend on the given database
+func NewPostgresStorage(db *sqldb.Database) *PostgresStorage {
+	return &PostgresStorage{db: db}
+}
+
+// slidingWindow is the counter state of one key
+type slidingWindow struct {
+	Start     time.Time // start of the current fixed window
+	Window    time.Duration
+	Current   int
+	Previous  int
+	LastSeen  time.Time
+	BlockedAt *time.Time
+}
+
+// advance moves the counter to the fixed window containing now
+func (w slidingWindow) advance(window time.Duration, now time.Time) slidingWindow {
+	start := now.Truncate(window)
+	switch {
+	case w.Window == window && w.Start.Equal(start):
+	case w.Window == window && w.Start.Equal(start.Add(-window)):
+		w.Previous, w.Current = w.Current, 0
+	default:
+		w.Previous, w.Current = 0, 0
+	}
+	w.Start, w.Window = start, window
+	return w
+}
+
+// estimate returns the number of attempts in the sliding window ending at now
+func (w slidingWindow) estimate(now time.Time) float64 {
+	if w.Window <= 0 {
+		return float64(w.Current)
+	}
+	overlap := 1 - float64(now.Sub(w.Start))/float64(w.Window)
+	if overlap < 0 {
+		overlap = 0
+	}
+	return float64(w.Previous)*overlap + float64(w.Current)
+}
+
+// hit counts an attempt at now under cfg and returns the new state and whether it is allowed.
+// Rejected attempts are not counted; exceeding the limit starts cfg.BlockTime if set.
+func (w slidingWindow) hit(cfg RateLimitConfig, now time.Time) (slidingWindow, bool) {
+	if cfg.Window <= 0 {
+		return w, true
+	}
+	w = w.advance(cfg.Window, now)
+
+	if w.BlockedAt != nil {
+		if cfg.BlockTime > 0 && now.Sub(*w.BlockedAt) < cfg.BlockTime {
+			return w, false
+		}
+		// Block expired: start counting afresh
+		w.BlockedAt = nil
+		w.Previous, w.Current = 0, 0
+	}
+
+	if w.estimate(now) >= float64(cfg.MaxAttempts) {
+		if cfg.BlockTime > 0 {
+			blockedAt := now
+			w.BlockedAt = &blockedAt
+		}
+		return w, false
+	}
+	w.Current++
+	w.LastSeen = now
+	return w, true
+}
+
+// expiresAt is when the state no longer affects any decision and can be deleted
+func (w slidingWindow) expiresAt(blockTime time.Duration) time.Time {
+	exp := w.Start.Add(2 * w.Window)
+	if w.BlockedAt != nil && w.BlockedAt.Add(blockTime).After(exp) {
+		exp = w.BlockedAt.Add(blockTime)
+	}
+	if w.LastSeen.After(exp) {
+		exp = w.LastSeen
+	}
+	return exp
+}
+
+// record converts the state to the AttemptRecord used by RateLimiter's read methods
+func (w slidingWindow) record(key string, now time.Time) *AttemptRecord {
+	r := &AttemptRecord{
+		Key:       key,
+		Count:     int(math.Ceil(w.estimate(now))),
+		FirstSeen: w.Start,
+		LastSeen:  w.LastSeen,
+	}
+	if w.BlockedAt != nil {
+		blockedAt := *w.BlockedAt
+		r.BlockedAt = &blockedAt
+	}
+	return r
+}
+
+// Hit counts an attempt atomically: the key's row is locked for the read-modify-write
+func (ps *PostgresStorage) Hit(ctx context.Context, key string, cfg RateLimitConfig, now time.Time) (bool, error) {
+	tx, err := ps.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin rate limit hit: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Create the row first so concurrent first attempts serialize on its lock
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO rate_limit_counters (key, window_start, last_seen, expires_at)
+		VALUES ($1, 'epoch', $2, $2)
+		ON CONFLICT (key) DO NOTHING
+	`, key, now); err != nil {
+		return false, fmt.Errorf("create rate limit counter: %w", err)
+	}
+
+	var w slidingWindow
+	var windowMS int64
+	if err := tx.QueryRow(ctx, `
+		SELECT window_start, window_ms, curr_count, prev_count, last_seen, blocked_at
+		FROM rate_limit_counters
+		WHERE key = $1
+		FOR UPDATE
+	`, key).Scan(&w.Start, &windowMS, &w.Current, &w.Previous, &w.LastSeen, &w.BlockedAt); err != nil {
+		return false, fmt.Errorf("load rate limit counter: %w", err)
+	}
+	w.Window = time.Duration(windowMS) * time.Millisecond
+
+	next, allowed := w.hit(cfg, now)
+	if _, err := tx.Exec(ctx, `
+		UPDATE rate_limit_counters
+		SET window_start = $2, window_ms = $3, curr_count = $4, prev_count = $5, last_seen = $6,
+		    blocked_at = $7, expires_at = $8
+		WHERE key = $1
+	`, key, next.Start, next.Window.Milliseconds(), next.Current, next.Previous, next.LastSeen,
+		next.BlockedAt, next.expiresAt(cfg.BlockTime)); err != nil {
+		return false, fmt.Errorf("update rate limit counter: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit rate limit hit: %w", err)
+	}
+	return allowed, nil
+}
+
+// GetRecord retrieves an attempt record for the given key
+func (ps *PostgresStorage) GetRecord(ctx context.Context, key string) (*AttemptRecord, error) {
+	var w slidingWindow
+	var windowMS int64
+	err := ps.db.QueryRow(ctx, `
+		SELECT window_start, window_ms, curr_count, prev_count, last_seen, blocked_at
+		FROM rate_limit_counters
+		WHERE key = $1
+	`, key).Scan(&w.Start, &windowMS, &w.Current, &w.Previous, &w.LastSeen, &w.BlockedAt)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.Window = time.Duration(windowMS) * time.Millisecond
+	return w.record(key, time.Now().UTC()), nil
+}
+
+// SetRecord stores an attempt record for the given key as the count of the current window.
+// RateLimiter only calls it for storages without Hit; it is kept for the Storage interface.
+func (ps *PostgresStorage) SetRecord(ctx context.Context, key string, record *AttemptRecord) error {
+	expiresAt := record.LastSeen.Add(24 * time.Hour)
+	_, err := ps.db.Exec(ctx, `
+		INSERT INTO rate_limit_counters (key, window_start, curr_count, prev_count, last_seen, blocked_at, expires_at)
+		VALUES ($1, $2, $3, 0, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE
+		SET window_start = EXCLUDED.window_start, curr_count = EXCLUDED.curr_count, prev_count = 0,
+		    last_seen = EXCLUDED.last_seen, blocked_at = EXCLUDED.blocked_at, expires_at = EXCLUDED.expires_at
+	`, key, record.FirstSeen, record.Count, record.LastSeen, record.BlockedAt, expiresAt)
+	return err
+}
+
+// DeleteRecord removes an attempt record for the given key
+func (ps *PostgresStorage) DeleteRecord(ctx context.Context, key string) error {
+	_, err := ps.db.Exec(ctx, `DELETE FROM rate_limit_counters WHERE key = $1`, key)
+	return err
+}
+
+// CleanupExpired removes counters that no longer affect any decision. Each row carries its
+// own expiry because limiters with different windows share the table, so window is ignored.
+func (ps *PostgresStorage) CleanupExpired(ctx context.Context, window time.Duration) error {
+	_, err := ps.db.Exec(ctx, `DELETE FROM rate_limit_counters WHERE expires_at < NOW()`)
+	return err
+}
+
+// GetStats returns storage statistics
+func (ps *PostgresStorage) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	var total, blocked int
+	if err := ps.db.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE blocked_at IS NOT NULL)
+		FROM rate_limit_counters
+	`).Scan(&total, &blocked); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"type":          "postgres",
+		"total_records": total,
+		"blocked_count": blocked,
+		"active_count":  total - blocked,
+		"timestamp":     time.Now().UTC(),
+	}, nil
+}
+
+// Close is a no-op; the database is owned by the service
+func (ps *PostgresStorage) Close() error {
+	return nil
+}
+
+// SettingsProvider reads limits from the ratelimit.<action>.* system settings of db (see
+// config.RateLimitSetting); unset values keep the limiter defaults
+func SettingsProvider(db *sqldb.Database) ConfigProvider {
+	return func(action string, defaults RateLimitConfig) RateLimitConfig {
+		return applySetting(defaults, config.Initialize(db, 5*time.Minute).GetSettings().RateLimits[action])
+	}
+}
+
+// applySetting overrides the non-zero fields of a setting onto cfg
+func applySetting(cfg RateLimitConfig, s config.RateLimitSetting) RateLimitConfig {
+	if s.MaxAttempts > 0 {
+		cfg.MaxAttempts = s.MaxAttempts
+	}
+	if s.WindowSeconds > 0 {
+		cfg.Window = time.Duration(s.WindowSeconds) * time.Second
+	}
+	if s.BlockSeconds > 0 {
+		cfg.BlockTime = time.Duration(s.BlockSeconds) * time.Second
+	}
+	return cfg
+}
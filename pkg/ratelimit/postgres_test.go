@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"encore.app/pkg/config"
+)
+
+func TestSlidingWindowHit(t *testing.T) {
+	cfg := RateLimitConfig{MaxAttempts: 3, Window: time.Minute}
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var w slidingWindow
+	var allowed bool
+	for i := 0; i < 3; i++ {
+		if w, allowed = w.hit(cfg, start.Add(time.Duration(i)*time.Second)); !allowed {
+			t.Fatalf("attempt %d should be allowed", i+1)
+		}
+	}
+	if _, allowed = w.hit(cfg, start.Add(10*time.Second)); allowed {
+		t.Fatal("fourth attempt in the window should be rejected")
+	}
+
+	// 15s into the next window three quarters of the previous one still count: 3*0.75 = 2.25
+	next := start.Add(75 * time.Second)
+	if w, allowed = w.hit(cfg, next); !allowed {
+		t.Fatal("attempt should be allowed once the estimate drops below the limit")
+	}
+	if _, allowed = w.hit(cfg, next); allowed {
+		t.Fatal("estimate 3.25 must be rejected")
+	}
+
+	// Two windows later nothing counts
+	if w, allowed = w.hit(cfg, start.Add(3*time.Minute)); !allowed || w.Current != 1 || w.Previous != 0 {
+		t.Fatalf("counter should restart, got %+v allowed=%v", w, allowed)
+	}
+}
+
+func TestSlidingWindowBlock(t *testing.T) {
+	cfg := RateLimitConfig{MaxAttempts: 1, Window: time.Minute, BlockTime: 5 * time.Minute}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	w, _ := slidingWindow{}.hit(cfg, now)
+	w, allowed := w.hit(cfg, now.Add(time.Second))
+	if allowed || w.BlockedAt == nil {
+		t.Fatalf("exceeding the limit should block, got %+v", w)
+	}
+	if _, allowed = w.hit(cfg, now.Add(4*time.Minute)); allowed {
+		t.Error("attempts during the block must be rejected")
+	}
+	w, allowed = w.hit(cfg, now.Add(6*time.Minute))
+	if !allowed || w.BlockedAt != nil {
+		t.Errorf("the block should expire, got %+v allowed=%v", w, allowed)
+	}
+	if exp := w.expiresAt(cfg.BlockTime); !exp.Equal(w.Start.Add(2 * time.Minute)) {
+		t.Errorf("unexpected expiry %v", exp)
+	}
+}
+
+// atomicMemoryStorage counts Hit calls on top of MemoryStorage
+type atomicMemoryStorage struct {
+	*MemoryStorage
+	w    map[string]slidingWindow
+	hits int
+}
+
+func (s *atomicMemoryStorage) Hit(ctx context.Context, key string, cfg RateLimitConfig, now time.Time) (bool, error) {
+	s.hits++
+	next, allowed := s.w[key].hit(cfg, now)
+	s.w[key] = next
+	return allowed, nil
+}
+
+func TestSharedRateLimiterUsesProviderAndHit(t *testing.T) {
+	storage := &atomicMemoryStorage{MemoryStorage: NewMemoryStorage(), w: map[string]slidingWindow{}}
+	var gotAction string
+	provider := func(action string, defaults RateLimitConfig) RateLimitConfig {
+		gotAction = action
+		return applySetting(defaults, config.RateLimitSetting{MaxAttempts: 2})
+	}
+	rl := NewSharedRateLimiter("login", RateLimitConfig{MaxAttempts: 10, Window: time.Hour}, storage, provider)
+
+	if cfg := rl.Config(); cfg.MaxAttempts != 2 || cfg.Window != time.Hour || gotAction != "login" {
+		t.Fatalf("unexpected config %+v for action %q", cfg, gotAction)
+	}
+	for i := 0; i < 2; i++ {
+		if err := rl.RecordAttempt("k"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	if err := rl.RecordAttempt("k"); err != ErrRateLimitExceeded {
+		t.Errorf("expected the configured limit of 2, got %v", err)
+	}
+	if err := rl.RecordAttemptWithLimit("k", 5); err != nil {
+		t.Errorf("a per-key limit should raise the limit: %v", err)
+	}
+	if storage.hits != 4 {
+		t.Errorf("attempts should go through Hit, got %d calls", storage.hits)
+	}
+}
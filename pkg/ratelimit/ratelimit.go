@@ -35,9 +35,15 @@ type AttemptRecord struct {
 	BlockedAt *time.Time `json:"blocked_at,omitempty"`
 }
 
+// ConfigProvider returns the limits to enforce for an action given its built-in defaults, so
+// limits can be tuned at runtime (see SettingsProvider)
+type ConfigProvider func(action string, defaults RateLimitConfig) RateLimitConfig
+
 // RateLimiter provides rate limiting functionality
 type RateLimiter struct {
 	config         RateLimitConfig
+	action         string
+	provider       ConfigProvider
 	storage        Storage
 	cleanupTicker  *time.Ticker
 	cleanupStop    chan struct{}
@@ -61,6 +67,24 @@ func NewRateLimiterWithStorage(config RateLimitConfig, storage Storage) *RateLim
 	return rl
 }
 
+// NewSharedRateLimiter creates a rate limiter for a named action. Limits come from provider
+// (nil keeps defaults) and counters live in storage; with PostgresStorage they are shared by
+// every instance and survive restarts.
+func NewSharedRateLimiter(action string, defaults RateLimitConfig, storage Storage, provider ConfigProvider) *RateLimiter {
+	rl := NewRateLimiterWithStorage(defaults, storage)
+	rl.action = action
+	rl.provider = provider
+	return rl
+}
+
+// Config returns the limits currently enforced
+func (rl *RateLimiter) Config() RateLimitConfig {
+	if rl.provider == nil {
+		return rl.config
+	}
+	return rl.provider(rl.action, rl.config)
+}
+
 // EnableAutoCleanup starts automatic cleanup of expired records
 func (rl *RateLimiter) EnableAutoCleanup(interval time.Duration) {
 	if rl.cleanupEnabled {
@@ -75,7 +99,7 @@ func (rl *RateLimiter) EnableAutoCleanup(interval time.Duration) {
 			select {
 			case <-rl.cleanupTicker.C:
 				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-				if err := rl.storage.CleanupExpired(ctx, rl.config.Window); err != nil {
+				if err := rl.storage.CleanupExpired(ctx, rl.Config().Window); err != nil {
 					log.Printf("RateLimiter storage error in CleanupExpired: %v", err)
 				}
 				cancel()
@@ -117,6 +141,11 @@ func (rl *RateLimiter) DisableAutoCleanup() {
 
 // IsAllowed checks if the request is allowed based on the rate limit
 func (rl *RateLimiter) IsAllowed(key string) bool {
+	return rl.allow(key, rl.Config())
+}
+
+// allow records an attempt for key under cfg and reports whether it is allowed
+func (rl *RateLimiter) allow(key string, cfg RateLimitConfig) bool {
 	if key == "" {
 		return false
 	}
@@ -125,6 +154,16 @@ func (rl *RateLimiter) IsAllowed(key string) bool {
 	defer cancel()
 
 	now := time.Now().UTC()
+	if atomic, ok := rl.storage.(AtomicStorage); ok {
+		allowed, err := atomic.Hit(ctx, key, cfg, now)
+		if err != nil {
+			// Same policy as below: a storage outage must not lock everyone out
+			log.Printf("RateLimiter storage error in Hit for key hash %s: %v", hashSensitiveKey(key), err)
+			return true
+		}
+		return allowed
+	}
+
 	record, err := rl.storage.GetRecord(ctx, key)
 	if err != nil {
 		// Log storage error for monitoring and debugging
@@ -149,8 +188,8 @@ func (rl *RateLimiter) IsAllowed(key string) bool {
 	}
 
 	// Check if blocked and block time hasn't expired
-	if record.BlockedAt != nil && rl.config.BlockTime > 0 {
-		if now.Sub(*record.BlockedAt) < rl.config.BlockTime {
+	if record.BlockedAt != nil && cfg.BlockTime > 0 {
+		if now.Sub(*record.BlockedAt) < cfg.BlockTime {
 			return false
 		}
 		// Block time expired, reset the record
@@ -160,7 +199,7 @@ func (rl *RateLimiter) IsAllowed(key string) bool {
 	}
 
 	// Check if window has expired
-	if now.Sub(record.FirstSeen) >= rl.config.Window {
+	if now.Sub(record.FirstSeen) >= cfg.Window {
 		// Reset the window
 		record.Count = 1
 		record.FirstSeen = now
@@ -172,9 +211,9 @@ func (rl *RateLimiter) IsAllowed(key string) bool {
 	}
 
 	// Within the window, check if limit exceeded
-	if record.Count >= rl.config.MaxAttempts {
+	if record.Count >= cfg.MaxAttempts {
 		// Block the key if block time is configured
-		if rl.config.BlockTime > 0 && record.BlockedAt == nil {
+		if cfg.BlockTime > 0 && record.BlockedAt == nil {
 			record.BlockedAt = &now
 		}
 		if err := rl.storage.SetRecord(ctx, key, record); err != nil {
@@ -200,8 +239,22 @@ func (rl *RateLimiter) RecordAttempt(key string) error {
 	return nil
 }
 
+// RecordAttemptWithLimit is RecordAttempt with a different attempt limit for this key, e.g. a
+// per-user override; maxAttempts <= 0 keeps the configured limit
+func (rl *RateLimiter) RecordAttemptWithLimit(key string, maxAttempts int) error {
+	cfg := rl.Config()
+	if maxAttempts > 0 {
+		cfg.MaxAttempts = maxAttempts
+	}
+	if !rl.allow(key, cfg) {
+		return ErrRateLimitExceeded
+	}
+	return nil
+}
+
 // GetRemainingAttempts returns the number of remaining attempts for the key
 func (rl *RateLimiter) GetRemainingAttempts(key string) int {
+	cfg := rl.Config()
 	if key == "" {
 		return 0
 	}
@@ -211,24 +264,24 @@ func (rl *RateLimiter) GetRemainingAttempts(key string) int {
 
 	record, err := rl.storage.GetRecord(ctx, key)
 	if err != nil || record == nil {
-		return rl.config.MaxAttempts
+		return cfg.MaxAttempts
 	}
 
 	now := time.Now().UTC()
 
 	// Check if blocked
-	if record.BlockedAt != nil && rl.config.BlockTime > 0 {
-		if now.Sub(*record.BlockedAt) < rl.config.BlockTime {
+	if record.BlockedAt != nil && cfg.BlockTime > 0 {
+		if now.Sub(*record.BlockedAt) < cfg.BlockTime {
 			return 0
 		}
 	}
 
 	// Check if window expired
-	if now.Sub(record.FirstSeen) >= rl.config.Window {
-		return rl.config.MaxAttempts
+	if now.Sub(record.FirstSeen) >= cfg.Window {
+		return cfg.MaxAttempts
 	}
 
-	remaining := rl.config.MaxAttempts - record.Count
+	remaining := cfg.MaxAttempts - record.Count
 	if remaining < 0 {
 		return 0
 	}
@@ -237,6 +290,7 @@ func (rl *RateLimiter) GetRemainingAttempts(key string) int {
 
 // GetTimeUntilReset returns the time until the rate limit resets for the key
 func (rl *RateLimiter) GetTimeUntilReset(key string) time.Duration {
+	cfg := rl.Config()
 	if key == "" {
 		return 0
 	}
@@ -252,15 +306,15 @@ func (rl *RateLimiter) GetTimeUntilReset(key string) time.Duration {
 	now := time.Now().UTC()
 
 	// If blocked, return time until block expires
-	if record.BlockedAt != nil && rl.config.BlockTime > 0 {
-		blockExpiry := record.BlockedAt.Add(rl.config.BlockTime)
+	if record.BlockedAt != nil && cfg.BlockTime > 0 {
+		blockExpiry := record.BlockedAt.Add(cfg.BlockTime)
 		if now.Before(blockExpiry) {
 			return blockExpiry.Sub(now)
 		}
 	}
 
 	// Return time until window expires
-	windowExpiry := record.FirstSeen.Add(rl.config.Window)
+	windowExpiry := record.FirstSeen.Add(cfg.Window)
 	if now.Before(windowExpiry) {
 		return windowExpiry.Sub(now)
 	}
@@ -270,6 +324,7 @@ func (rl *RateLimiter) GetTimeUntilReset(key string) time.Duration {
 
 // IsBlocked checks if the key is currently blocked
 func (rl *RateLimiter) IsBlocked(key string) bool {
+	cfg := rl.Config()
 	if key == "" {
 		return false
 	}
@@ -282,16 +337,17 @@ func (rl *RateLimiter) IsBlocked(key string) bool {
 		return false
 	}
 
-	if record.BlockedAt == nil || rl.config.BlockTime == 0 {
+	if record.BlockedAt == nil || cfg.BlockTime == 0 {
 		return false
 	}
 
 	now := time.Now().UTC()
-	return now.Sub(*record.BlockedAt) < rl.config.BlockTime
+	return now.Sub(*record.BlockedAt) < cfg.BlockTime
 }
 
 // GetBlockTimeRemaining returns the remaining block time for the key
 func (rl *RateLimiter) GetBlockTimeRemaining(key string) time.Duration {
+	cfg := rl.Config()
 	if key == "" {
 		return 0
 	}
@@ -300,12 +356,12 @@ func (rl *RateLimiter) GetBlockTimeRemaining(key string) time.Duration {
 	defer cancel()
 
 	record, err := rl.storage.GetRecord(ctx, key)
-	if err != nil || record == nil || record.BlockedAt == nil || rl.config.BlockTime == 0 {
+	if err != nil || record == nil || record.BlockedAt == nil || cfg.BlockTime == 0 {
 		return 0
 	}
 
 	now := time.Now().UTC()
-	blockExpiry := record.BlockedAt.Add(rl.config.BlockTime)
+	blockExpiry := record.BlockedAt.Add(cfg.BlockTime)
 
 	if now.Before(blockExpiry) {
 		return blockExpiry.Sub(now)
@@ -360,11 +416,12 @@ func (rl *RateLimiter) GetAttemptInfo(key string) (*AttemptRecord, bool) {
 
 // CleanupExpiredRecords removes expired records to prevent memory leaks
 func (rl *RateLimiter) CleanupExpiredRecords() int {
+	cfg := rl.Config()
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	// Use storage's cleanup method
-	err := rl.storage.CleanupExpired(ctx, rl.config.Window)
+	err := rl.storage.CleanupExpired(ctx, cfg.Window)
 	if err != nil {
 		return 0
 	}
@@ -385,6 +442,7 @@ func (rl *RateLimiter) CleanupExpiredRecords() int {
 
 // GetStats returns statistics about the rate limiter
 func (rl *RateLimiter) GetStats() map[string]interface{} {
+	cfg := rl.Config()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -392,17 +450,17 @@ func (rl *RateLimiter) GetStats() map[string]interface{} {
 	if err != nil {
 		// Return basic config stats on error
 		return map[string]interface{}{
-			"max_attempts": rl.config.MaxAttempts,
-			"window":       rl.config.Window.String(),
-			"block_time":   rl.config.BlockTime.String(),
+			"max_attempts": cfg.MaxAttempts,
+			"window":       cfg.Window.String(),
+			"block_time":   cfg.BlockTime.String(),
 			"error":        err.Error(),
 		}
 	}
 
 	// Add configuration to storage stats
-	storageStats["max_attempts"] = rl.config.MaxAttempts
-	storageStats["window"] = rl.config.Window.String()
-	storageStats["block_time"] = rl.config.BlockTime.String()
+	storageStats["max_attempts"] = cfg.MaxAttempts
+	storageStats["window"] = cfg.Window.String()
+	storageStats["block_time"] = cfg.BlockTime.String()
 	storageStats["cleanup_enabled"] = rl.cleanupEnabled
 
 	return storageStats
@@ -28,6 +28,16 @@ type Storage interface {
 	Close() error
 }
 
+// AtomicStorage is implemented by storage backends that count an attempt in one atomic step.
+// RateLimiter uses Hit instead of GetRecord/SetRecord, so concurrent requests on different
+// instances cannot both slip under the limit.
+type AtomicStorage interface {
+	Storage
+
+	// Hit records an attempt for key under cfg and reports whether it is allowed
+	Hit(ctx context.Context, key string, cfg RateLimitConfig, now time.Time) (bool, error)
+}
+
 // MemoryStorage implements in-memory storage for rate limiting
 type MemoryStorage struct {
 	attempts map[string]*AttemptRecord
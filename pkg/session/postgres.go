@@ -0,0 +1,163 @@
+// Package session provides secure session management with HttpOnly cookies
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.dev/storage/sqldb"
+)
+
+// PostgresStorage stores sessions in the user_sessions table so they are shared by all
+// instances and survive deploys. Access and refresh tokens are not persisted: they are
+// secrets, and refresh tokens are tracked by the auth service's refresh_tokens table.
+type PostgresStorage struct {
+	db *sqldb.Database
+}
+
+// NewPostgresStorage creates a storage backend on the given database
+func NewPostgresStorage(db *sqldb.Database) *PostgresStorage {
+	return &PostgresStorage{db: db}
+}
+
+const sessionColumns = `id, user_id, role, email, ip_address, user_agent, ip_changes, last_ip_change,
+	metadata, created_at, last_used_at, expires_at`
+
+// scanSession reads a row selected with sessionColumns
+func scanSession(scan func(dest ...any) error) (*SessionData, error) {
+	var s SessionData
+	var lastIPChange *time.Time
+	var metadata []byte
+	if err := scan(&s.ID, &s.UserID, &s.Role, &s.Email, &s.IPAddress, &s.UserAgent, &s.IPChanges, &lastIPChange,
+		&metadata, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+		return nil, err
+	}
+	if lastIPChange != nil {
+		s.LastIPChange = *lastIPChange
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &s.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid session metadata: %w", err)
+		}
+	}
+	return &s, nil
+}
+
+// GetSession retrieves a session by ID
+func (ps *PostgresStorage) GetSession(ctx context.Context, sessionID string) (*SessionData, error) {
+	row := ps.db.QueryRow(ctx, `SELECT `+sessionColumns+` FROM user_sessions WHERE id = $1`, sessionID)
+	s, err := scanSession(row.Scan)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().UTC().After(s.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+	return s, nil
+}
+
+// SetSession stores a session
+func (ps *PostgresStorage) SetSession(ctx context.Context, sessionID string, data *SessionData) error {
+	metadata, err := json.Marshal(data.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode session metadata: %w", err)
+	}
+	var lastIPChange *time.Time
+	if !data.LastIPChange.IsZero() {
+		lastIPChange = &data.LastIPChange
+	}
+	_, err = ps.db.Exec(ctx, `
+		INSERT INTO user_sessions (id, user_id, role, email, ip_address, user_agent, ip_changes, last_ip_change,
+		                           metadata, created_at, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE SET
+			role = EXCLUDED.role, email = EXCLUDED.email,
+			ip_address = EXCLUDED.ip_address, user_agent = EXCLUDED.user_agent,
+			ip_changes = EXCLUDED.ip_changes, last_ip_change = EXCLUDED.last_ip_change,
+			metadata = EXCLUDED.metadata, last_used_at = EXCLUDED.last_used_at, expires_at = EXCLUDED.expires_at
+	`, sessionID, data.UserID, data.Role, data.Email, data.IPAddress, data.UserAgent, data.IPChanges, lastIPChange,
+		metadata, data.CreatedAt, data.LastUsedAt, data.ExpiresAt)
+	return err
+}
+
+// DeleteSession removes a session
+func (ps *PostgresStorage) DeleteSession(ctx context.Context, sessionID string) error {
+	res, err := ps.db.Exec(ctx, `DELETE FROM user_sessions WHERE id = $1`, sessionID)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// DeleteUserSessions removes all sessions for a user
+func (ps *PostgresStorage) DeleteUserSessions(ctx context.Context, userID int64) (int, error) {
+	res, err := ps.db.Exec(ctx, `DELETE FROM user_sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return int(res.RowsAffected()), nil
+}
+
+// ListUserSessions returns the active sessions of a user, most recently used first
+func (ps *PostgresStorage) ListUserSessions(ctx context.Context, userID int64) ([]*SessionData, error) {
+	rows, err := ps.db.Query(ctx, `
+		SELECT `+sessionColumns+`
+		FROM user_sessions
+		WHERE user_id = $1 AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*SessionData
+	for rows.Next() {
+		s, err := scanSession(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// CleanupExpired removes expired sessions
+func (ps *PostgresStorage) CleanupExpired(ctx context.Context) (int, error) {
+	res, err := ps.db.Exec(ctx, `DELETE FROM user_sessions WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return int(res.RowsAffected()), nil
+}
+
+// GetStats returns storage statistics
+func (ps *PostgresStorage) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	var total, active int
+	if err := ps.db.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE expires_at > NOW()) FROM user_sessions
+	`).Scan(&total, &active); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"type":           "postgres",
+		"total_sessions": total,
+		"active_count":   active,
+		"expired_count":  total - active,
+		"timestamp":      time.Now().UTC(),
+	}, nil
+}
+
+// Close is a no-op: the database is owned by the service
+func (ps *PostgresStorage) Close() error {
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"time"
 )
 
@@ -21,6 +22,7 @@ var (
 
 // SessionData represents the data stored in a session
 type SessionData struct {
+	ID           string                 `json:"id,omitempty"` // session ID, filled by the storage
 	UserID       int64                  `json:"user_id"`
 	Role         string                 `json:"role"`
 	Email        string                 `json:"email"`
@@ -331,6 +333,29 @@ func (sm *SessionManager) ExtendSession(sessionID string, duration time.Duration
 	return sm.storage.SetSession(ctx, sessionID, sessionData)
 }
 
+// RenewSession moves the session's expiry forward to expiresAt (never back), e.g. to the
+// expiry of the refresh token just issued for it
+func (sm *SessionManager) RenewSession(sessionID string, expiresAt time.Time) error {
+	if sessionID == "" {
+		return ErrInvalidSessionID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionData, err := sm.storage.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if expiresAt.After(sessionData.ExpiresAt) {
+		sessionData.ExpiresAt = expiresAt.UTC()
+	}
+	sessionData.LastUsedAt = time.Now().UTC()
+
+	return sm.storage.SetSession(ctx, sessionID, sessionData)
+}
+
 // SetSessionCookie sets the session cookie in the HTTP response
 func (sm *SessionManager) SetSessionCookie(w http.ResponseWriter, sessionID string) {
 	cookie := &http.Cookie{
@@ -373,38 +398,19 @@ func (sm *SessionManager) GetSessionFromRequest(r *http.Request) (string, error)
 	return cookie.Value, nil
 }
 
-// GetUserSessions returns all active sessions for a user
-// Note: This is a simplified implementation for memory storage
-// For distributed storage, you would need to implement a more efficient query mechanism
+// GetUserSessions returns all active sessions for a user, most recently used first
 func (sm *SessionManager) GetUserSessions(userID int64) []*SessionData {
-	// For memory storage, we can access the underlying storage
-	if memStorage, ok := sm.storage.(*MemoryStorage); ok {
-		memStorage.mutex.RLock()
-		defer memStorage.mutex.RUnlock()
-
-		var userSessions []*SessionData
-		now := time.Now().UTC()
-
-		for _, sessionData := range memStorage.sessions {
-			if sessionData.UserID == userID && now.Before(sessionData.ExpiresAt) {
-				// Return a copy to prevent external modification
-				sessionCopy := *sessionData
-				if sessionData.Metadata != nil {
-					sessionCopy.Metadata = make(map[string]interface{})
-					for k, v := range sessionData.Metadata {
-						sessionCopy.Metadata[k] = v
-					}
-				}
-				userSessions = append(userSessions, &sessionCopy)
-			}
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-		return userSessions
+	sessions, err := sm.storage.ListUserSessions(ctx, userID)
+	if err != nil {
+		return []*SessionData{}
 	}
-
-	// For other storage types, this would need to be implemented differently
-	// For now, return empty slice
-	return []*SessionData{}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions
 }
 
 // CleanupExpiredSessions removes expired sessions
@@ -314,6 +314,38 @@ func TestExtendSession(t *testing.T) {
 	}
 }
 
+func TestRenewSession(t *testing.T) {
+	config := DefaultSessionConfig
+	config.CleanupInterval = 0
+	sm := NewSessionManager(config)
+
+	sessionID, originalData, err := sm.CreateSession(123, "verified", "test@example.com", "access_token", "refresh_token", "", "")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// A rotated refresh token outliving the session moves its expiry forward
+	renewed := originalData.ExpiresAt.Add(30 * 24 * time.Hour)
+	if err := sm.RenewSession(sessionID, renewed); err != nil {
+		t.Fatalf("RenewSession failed: %v", err)
+	}
+	sessionData, err := sm.GetSession(sessionID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if !sessionData.ExpiresAt.Equal(renewed) {
+		t.Errorf("Session not renewed: got %v, want %v", sessionData.ExpiresAt, renewed)
+	}
+
+	// An earlier expiry never shortens the session
+	if err := sm.RenewSession(sessionID, originalData.ExpiresAt); err != nil {
+		t.Fatalf("RenewSession failed: %v", err)
+	}
+	if sessionData, _ = sm.GetSession(sessionID); !sessionData.ExpiresAt.Equal(renewed) {
+		t.Errorf("Session expiry moved back: got %v, want %v", sessionData.ExpiresAt, renewed)
+	}
+}
+
 func TestSetSessionCookie(t *testing.T) {
 	config := DefaultSessionConfig
 	config.CleanupInterval = 0
@@ -439,6 +471,15 @@ func TestGetUserSessions(t *testing.T) {
 			t.Errorf("Session belongs to wrong user: got %d, want %d", session.UserID, userID)
 		}
 	}
+
+	// Listed sessions carry their ID, most recently used first
+	if _, err := sm.GetSession(sessions[1].ID); err != nil {
+		t.Fatalf("GetSession(%q) failed: %v", sessions[1].ID, err)
+	}
+	sessions = sm.GetUserSessions(userID)
+	if sessions[0].ID == "" || sessions[0].LastUsedAt.Before(sessions[1].LastUsedAt) {
+		t.Errorf("Sessions not ordered by last use: %+v", sessions)
+	}
 }
 
 func TestCleanupExpiredSessions(t *testing.T) {
@@ -21,6 +21,9 @@ type Storage interface {
 	// DeleteUserSessions removes all sessions for a user
 	DeleteUserSessions(ctx context.Context, userID int64) (int, error)
 
+	// ListUserSessions returns the active (unexpired) sessions of a user
+	ListUserSessions(ctx context.Context, userID int64) ([]*SessionData, error)
+
 	// CleanupExpired removes expired sessions
 	CleanupExpired(ctx context.Context) (int, error)
 
@@ -60,15 +63,7 @@ func (ms *MemoryStorage) GetSession(ctx context.Context, sessionID string) (*Ses
 	}
 
 	// Return a copy to prevent external modification
-	sessionCopy := *session
-	if session.Metadata != nil {
-		sessionCopy.Metadata = make(map[string]interface{})
-		for k, v := range session.Metadata {
-			sessionCopy.Metadata[k] = v
-		}
-	}
-
-	return &sessionCopy, nil
+	return copySession(sessionID, session), nil
 }
 
 // SetSession stores a session
@@ -77,16 +72,21 @@ func (ms *MemoryStorage) SetSession(ctx context.Context, sessionID string, data
 	defer ms.mutex.Unlock()
 
 	// Store a copy to prevent external modification
+	ms.sessions[sessionID] = copySession(sessionID, data)
+	return nil
+}
+
+// copySession returns a copy of a session with its ID set
+func copySession(sessionID string, data *SessionData) *SessionData {
 	sessionCopy := *data
+	sessionCopy.ID = sessionID
 	if data.Metadata != nil {
 		sessionCopy.Metadata = make(map[string]interface{})
 		for k, v := range data.Metadata {
 			sessionCopy.Metadata[k] = v
 		}
 	}
-
-	ms.sessions[sessionID] = &sessionCopy
-	return nil
+	return &sessionCopy
 }
 
 // DeleteSession removes a session
@@ -118,6 +118,21 @@ func (ms *MemoryStorage) DeleteUserSessions(ctx context.Context, userID int64) (
 	return deleted, nil
 }
 
+// ListUserSessions returns the active sessions of a user
+func (ms *MemoryStorage) ListUserSessions(ctx context.Context, userID int64) ([]*SessionData, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	now := time.Now().UTC()
+	var sessions []*SessionData
+	for sessionID, session := range ms.sessions {
+		if session.UserID == userID && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, copySession(sessionID, session))
+		}
+	}
+	return sessions, nil
+}
+
 // CleanupExpired removes expired sessions
 func (ms *MemoryStorage) CleanupExpired(ctx context.Context) (int, error) {
 	ms.mutex.Lock()
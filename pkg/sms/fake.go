@@ -0,0 +1,47 @@
+package sms
+
+import (
+	"context"
+	"sync"
+)
+
+// Sender sends a plain text SMS; TwilioClient and FakeSender implement it
+type Sender interface {
+	SendSMS(ctx context.Context, to, message string) error
+}
+
+var _ Sender = (*TwilioClient)(nil)
+
+// Message is an SMS captured by FakeSender
+type Message struct {
+	To   string
+	Body string
+}
+
+// FakeSender records messages instead of sending them, for tests and local runs. Set Err to
+// make every send fail.
+type FakeSender struct {
+	mu       sync.Mutex
+	messages []Message
+	Err      error
+}
+
+// SendSMS records the message, or returns Err when set
+func (f *FakeSender) SendSMS(_ context.Context, to, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.messages = append(f.messages, Message{To: to, Body: message})
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (f *FakeSender) Messages() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Message, len(f.messages))
+	copy(out, f.messages)
+	return out
+}
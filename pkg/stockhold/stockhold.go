@@ -0,0 +1,256 @@
+// Package stockhold reserves stock while a checkout is being paid. Holding a pigeon moves it
+// to 'reserved'; holding a supply decrements supplies.stock_qty straight away, so listings and
+// later checkouts see the reduced stock. Each hold is a stock_reservations row that is either
+// consumed by the paid webhook or released (status/stock restored) when the payment session
+// expires or fails.
+//
+// All functions run on the caller's database/sql transaction (db.Stdlib()).
+package stockhold
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/pkg/config"
+)
+
+// Release reasons stored in stock_reservations.release_reason
+const (
+	ReasonExpired       = "expired"
+	ReasonPaymentFailed = "payment_failed"
+	ReasonSuperseded    = "superseded" // the same user checked out the product again
+)
+
+var (
+	// ErrUnavailable is returned when an item can no longer be held (sold, held by someone else or short on stock)
+	ErrUnavailable = errors.New("stockhold: item is no longer available")
+	// ErrTooManyHolds is returned when the user already has the maximum number of active checkouts
+	ErrTooManyHolds = errors.New("stockhold: too many active holds")
+)
+
+// TTL is how long holds last per product type
+type TTL struct {
+	Pigeon time.Duration
+	Supply time.Duration
+}
+
+// Limits are the hold settings applied at checkout
+type Limits struct {
+	TTL
+	MaxActivePerUser int // active checkouts (orders) holding stock; 0 means unlimited
+	// Session is the payment session TTL; holds are extended to cover it (ExtendForSession)
+	Session time.Duration
+}
+
+// LimitsFromSettings reads the stock.* settings, falling back to the documented defaults
+func LimitsFromSettings(s *config.SystemSettings) Limits {
+	l := Limits{TTL: TTL{Pigeon: 10 * time.Minute, Supply: 15 * time.Minute}, MaxActivePerUser: 5, Session: 30 * time.Minute}
+	if s == nil {
+		return l
+	}
+	if s.PaymentsSessionTTL > 0 {
+		l.Session = time.Duration(s.PaymentsSessionTTL) * time.Minute
+	}
+	if s.StockCheckoutHoldMinutes > 0 {
+		l.Pigeon = time.Duration(s.StockCheckoutHoldMinutes) * time.Minute
+	}
+	if s.StockSuppliesHoldMinutes > 0 {
+		l.Supply = time.Duration(s.StockSuppliesHoldMinutes) * time.Minute
+	}
+	if s.StockMaxActiveHoldsPerUser >= 0 {
+		l.MaxActivePerUser = s.StockMaxActiveHoldsPerUser
+	}
+	return l
+}
+
+// Prepare locks the products in the user's cart, releases the user's own earlier holds on
+// them (a retried checkout replaces the abandoned one) and enforces the per-user limit. Call
+// it inside the checkout transaction before checking availability.
+func Prepare(ctx context.Context, tx *sql.Tx, userID int64, maxActive int) error {
+	// Lock in id order so concurrent checkouts of overlapping carts queue instead of deadlocking
+	if _, err := tx.ExecContext(ctx, `
+		SELECT p.id FROM products p
+		WHERE p.id IN (SELECT product_id FROM cart_items WHERE user_id=$1)
+		ORDER BY p.id
+		FOR UPDATE
+	`, userID); err != nil {
+		return fmt.Errorf("stockhold: lock cart products: %w", err)
+	}
+	if _, err := release(ctx, tx, ReasonSuperseded, `
+		user_id=$2 AND product_id IN (SELECT product_id FROM cart_items WHERE user_id=$2)
+	`, userID); err != nil {
+		return err
+	}
+	if maxActive <= 0 {
+		return nil
+	}
+	var active int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT order_id) FROM stock_reservations WHERE user_id=$1 AND status='active'
+	`, userID).Scan(&active); err != nil {
+		return fmt.Errorf("stockhold: count active holds: %w", err)
+	}
+	if active >= maxActive {
+		return ErrTooManyHolds
+	}
+	return nil
+}
+
+// Hold reserves every item of the order. It returns ErrUnavailable (and the caller must roll
+// back) when a pigeon is not 'available' or a supply does not have enough stock.
+func Hold(ctx context.Context, tx *sql.Tx, userID, orderID, invoiceID int64, ttl TTL, now time.Time) error {
+	var pigeons, supplies int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE p.type='pigeon'), COUNT(*) FILTER (WHERE p.type='supply')
+		FROM order_items oi JOIN products p ON p.id=oi.product_id
+		WHERE oi.order_id=$1
+	`, orderID).Scan(&pigeons, &supplies); err != nil {
+		return fmt.Errorf("stockhold: count order items: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE products p
+		SET status='reserved', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		FROM order_items oi
+		WHERE oi.order_id=$1 AND oi.product_id=p.id AND p.type='pigeon' AND p.status='available'
+	`, orderID)
+	if err != nil {
+		return fmt.Errorf("stockhold: reserve pigeons: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n < pigeons {
+		return ErrUnavailable
+	}
+
+	res, err = tx.ExecContext(ctx, `
+		UPDATE supplies s
+		SET stock_qty = s.stock_qty - oi.qty
+		FROM order_items oi
+		JOIN products p ON p.id=oi.product_id AND p.type='supply'
+		WHERE oi.order_id=$1 AND s.product_id=oi.product_id AND oi.qty > 0 AND s.stock_qty >= oi.qty
+	`, orderID)
+	if err != nil {
+		return fmt.Errorf("stockhold: decrement supplies: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n < supplies {
+		return ErrUnavailable
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO stock_reservations (order_id, invoice_id, product_id, user_id, qty, expires_at)
+		SELECT oi.order_id, $2, oi.product_id, $3, oi.qty,
+		       $4::timestamptz + CASE WHEN p.type='pigeon' THEN make_interval(secs => $5) ELSE make_interval(secs => $6) END
+		FROM order_items oi JOIN products p ON p.id=oi.product_id
+		WHERE oi.order_id=$1
+	`, orderID, invoiceID, userID, now.UTC(), ttl.Pigeon.Seconds(), ttl.Supply.Seconds()); err != nil {
+		return fmt.Errorf("stockhold: insert reservations: %w", err)
+	}
+	return nil
+}
+
+// Consume marks the order's active holds as consumed and sells its reserved pigeons (supply
+// stock was already taken at hold time). It returns how many pigeon and supply lines were
+// covered by a hold; lines whose hold was released must be fulfilled the old way.
+func Consume(ctx context.Context, tx *sql.Tx, orderID int64) (pigeons, supplies int64, err error) {
+	err = tx.QueryRowContext(ctx, `
+		WITH r AS (
+			UPDATE stock_reservations
+			SET status='consumed', consumed_at=NOW()
+			WHERE order_id=$1 AND status='active'
+			RETURNING product_id
+		), sold AS (
+			UPDATE products p
+			SET status='sold', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+			FROM r
+			WHERE p.id=r.product_id AND p.type='pigeon' AND p.status='reserved'
+			RETURNING p.id
+		)
+		SELECT (SELECT COUNT(*) FROM sold),
+		       (SELECT COUNT(*) FROM r JOIN products p ON p.id=r.product_id WHERE p.type='supply')
+	`, orderID).Scan(&pigeons, &supplies)
+	if err != nil {
+		return 0, 0, fmt.Errorf("stockhold: consume: %w", err)
+	}
+	return pigeons, supplies, nil
+}
+
+// ReleaseOrder releases the order's active holds, e.g. when its payment failed
+func ReleaseOrder(ctx context.Context, tx *sql.Tx, orderID int64, reason string) (int, error) {
+	return release(ctx, tx, reason, `order_id=$2`, orderID)
+}
+
+// ExtendForSession keeps the order's active holds until the payment session opened at
+// startedAt expires, so stock cannot be sold to someone else while the session is payable
+func ExtendForSession(ctx context.Context, tx *sql.Tx, orderID int64, startedAt time.Time, session time.Duration) (int, error) {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE stock_reservations
+		SET expires_at = GREATEST(expires_at, $2::timestamptz)
+		WHERE order_id=$1 AND status='active'
+	`, orderID, SessionExpiry(startedAt, session))
+	if err != nil {
+		return 0, fmt.Errorf("stockhold: extend for session: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// SessionExpiry is when a payment session opened at startedAt stops being payable
+func SessionExpiry(startedAt time.Time, session time.Duration) time.Time {
+	return startedAt.UTC().Add(session)
+}
+
+// ReleaseExpired releases every active hold past its expiry. Holds of an invoice with a live
+// payment session are left alone: they are released by ReleaseFailed once the session expires.
+func ReleaseExpired(ctx context.Context, tx *sql.Tx, now time.Time) (int, error) {
+	return release(ctx, tx, ReasonExpired, `
+		expires_at <= $2
+		AND NOT EXISTS (
+			SELECT 1 FROM invoices i WHERE i.id=stock_reservations.invoice_id AND i.status='payment_in_progress'
+		)
+	`, now.UTC())
+}
+
+// ReleaseFailed releases active holds whose invoice failed or was cancelled before expiry
+// (e.g. the payment session cleaner marked it failed)
+func ReleaseFailed(ctx context.Context, tx *sql.Tx) (int, error) {
+	return release(ctx, tx, ReasonPaymentFailed, `
+		invoice_id IN (SELECT id FROM invoices WHERE status IN ('failed','cancelled','void'))
+	`)
+}
+
+// release flips the active holds matching where to released and puts their stock back. where
+// refers to args as $2, $3...; rows locked by a concurrent consume/release are left for the next run.
+func release(ctx context.Context, tx *sql.Tx, reason, where string, args ...any) (int, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `
+		WITH r AS (
+			UPDATE stock_reservations
+			SET status='released', released_at=NOW(), release_reason=$1
+			WHERE id IN (
+				SELECT id FROM stock_reservations
+				WHERE status='active' AND `+where+`
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING product_id, qty
+		), restocked AS (
+			UPDATE supplies s
+			SET stock_qty = s.stock_qty + x.qty
+			FROM (SELECT product_id, SUM(qty) AS qty FROM r GROUP BY product_id) x
+			WHERE s.product_id=x.product_id
+			RETURNING s.product_id
+		), freed AS (
+			UPDATE products p
+			SET status='available', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+			FROM r
+			WHERE p.id=r.product_id AND p.type='pigeon' AND p.status='reserved'
+			RETURNING p.id
+		)
+		SELECT COUNT(*) FROM r
+	`, append([]any{reason}, args...)...).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("stockhold: release (%s): %w", reason, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,141 @@
+package stockhold
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"encore.app/pkg/config"
+)
+
+func TestLimitsFromSettings(t *testing.T) {
+	def := LimitsFromSettings(nil)
+	if def.Pigeon != 10*time.Minute || def.Supply != 15*time.Minute || def.MaxActivePerUser != 5 {
+		t.Fatalf("defaults = %+v", def)
+	}
+
+	l := LimitsFromSettings(&config.SystemSettings{
+		StockCheckoutHoldMinutes:   20,
+		StockSuppliesHoldMinutes:   0, // unset keeps the default
+		StockMaxActiveHoldsPerUser: 2,
+	})
+	if l.Pigeon != 20*time.Minute || l.Supply != 15*time.Minute || l.MaxActivePerUser != 2 {
+		t.Fatalf("limits = %+v", l)
+	}
+}
+
+// recordingDriver is a database/sql driver that records every statement and its arguments;
+// updates report one row and queries return a single 1
+type recordingDriver struct {
+	queries []string
+	args    [][]driver.Value
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{c.d, query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return recordingTx{}, nil }
+
+type recordingTx struct{}
+
+func (recordingTx) Commit() error   { return nil }
+func (recordingTx) Rollback() error { return nil }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.queries, s.d.args = append(s.d.queries, s.query), append(s.d.args, args)
+	return driver.RowsAffected(1), nil
+}
+func (s recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.queries, s.d.args = append(s.d.queries, s.query), append(s.d.args, args)
+	return &oneRow{}, nil
+}
+
+type oneRow struct{ done bool }
+
+func (r *oneRow) Columns() []string { return []string{"n"} }
+func (r *oneRow) Close() error      { return nil }
+func (r *oneRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+var driverSeq int
+
+// recordingTxFor opens a transaction on a fresh recording driver
+func recordingTxFor(t *testing.T) (*sql.Tx, *recordingDriver) {
+	t.Helper()
+	d := &recordingDriver{}
+	driverSeq++
+	name := fmt.Sprintf("stockhold-recording-%d", driverSeq)
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx, d
+}
+
+func TestExtendForSessionHoldsUntilSessionExpiry(t *testing.T) {
+	l := LimitsFromSettings(&config.SystemSettings{StockCheckoutHoldMinutes: 10, PaymentsSessionTTL: 30})
+	if l.Session != 30*time.Minute || LimitsFromSettings(nil).Session != 30*time.Minute {
+		t.Fatalf("session TTL = %v, want 30m (also by default)", l.Session)
+	}
+
+	tx, d := recordingTxFor(t)
+	started := time.Date(2025, 3, 1, 12, 2, 0, 0, time.FixedZone("AST", 3*3600))
+	n, err := ExtendForSession(context.Background(), tx, 42, started, l.Session)
+	if err != nil || n != 1 {
+		t.Fatalf("ExtendForSession = %d, %v", n, err)
+	}
+	if len(d.queries) != 1 || !strings.Contains(d.queries[0], "GREATEST(expires_at,") || !strings.Contains(d.queries[0], "status='active'") {
+		t.Fatalf("holds must only ever be extended, and only active ones: %v", d.queries)
+	}
+	want := started.UTC().Add(30 * time.Minute)
+	if got, ok := d.args[0][1].(time.Time); d.args[0][0] != int64(42) || !ok || !got.Equal(want) {
+		t.Errorf("args = %v, want order 42 held until %v", d.args[0], want)
+	}
+}
+
+func TestReleaseExpiredSkipsLivePaymentSessions(t *testing.T) {
+	tx, d := recordingTxFor(t)
+	now := time.Date(2025, 3, 1, 12, 20, 0, 0, time.UTC)
+	if _, err := ReleaseExpired(context.Background(), tx, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.queries) != 1 {
+		t.Fatalf("expected one statement, got %d", len(d.queries))
+	}
+	q := d.queries[0]
+	if !strings.Contains(q, "expires_at <= $2") || !strings.Contains(q, "NOT EXISTS") || !strings.Contains(q, "i.status='payment_in_progress'") {
+		t.Errorf("expired holds of an invoice being paid must be kept: %s", q)
+	}
+	if d.args[0][0] != ReasonExpired || !d.args[0][1].(time.Time).Equal(now) {
+		t.Errorf("args = %v", d.args[0])
+	}
+}
@@ -0,0 +1,191 @@
+// Package storagegcs - Pluggable object storage backends (GCS, local disk, S3-compatible)
+package storagegcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// Storage is what services use: uploads with media processing, deletes and URLs. *Client
+// implements it on top of whichever Backend is configured.
+type Storage interface {
+	Upload(ctx context.Context, reader io.Reader, fileName string, config UploadConfig) (*UploadResult, error)
+	UploadOriginal(ctx context.Context, reader io.Reader, fileName string) (*UploadResult, error)
+	Put(ctx context.Context, path string, r io.Reader) (int64, error)
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	Delete(ctx context.Context, path string) error
+	GetSignedURL(ctx context.Context, path string, expiration time.Duration) (string, error)
+	GetPublicURL(path string) string
+	GetSecureURL(ctx context.Context, path string, expiration time.Duration) (string, error)
+}
+
+// Backend is an object store driver. Paths are bucket-relative keys ("media/2025/...").
+type Backend interface {
+	// Put stores the object and returns the number of bytes written
+	Put(ctx context.Context, path string, r io.Reader, contentType string) (int64, error)
+	// Open returns a reader for the object content; the caller closes it
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	Delete(ctx context.Context, path string) error
+	// SignedURL returns a time-limited download URL
+	SignedURL(ctx context.Context, path string, expiration time.Duration) (string, error)
+	// PublicURL returns the direct URL of an object in a public bucket
+	PublicURL(path string) string
+	// URI returns the internal reference of an object (gs://bucket/key, s3://bucket/key...)
+	URI(path string) string
+	Close() error
+}
+
+// Storage providers accepted in media.storage_provider
+const (
+	ProviderGCS   = "gcs"
+	ProviderLocal = "local"
+	ProviderS3    = "s3"
+)
+
+// NewClientWithBackend creates a client that stores objects through backend
+func NewClientWithBackend(backend Backend, isPublic bool) *Client {
+	return &Client{backend: backend, isPublic: isPublic}
+}
+
+// ---------------------------
+// Google Cloud Storage
+// ---------------------------
+
+type gcsBackend struct {
+	client     *storage.Client
+	bucketName string
+}
+
+func newGCSBackend(ctx context.Context, config Config) (*gcsBackend, error) {
+	var opts []option.ClientOption
+	if config.CredentialsKey != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(config.CredentialsKey)))
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	return &gcsBackend{client: client, bucketName: config.BucketName}, nil
+}
+
+func (b *gcsBackend) Put(ctx context.Context, path string, r io.Reader, contentType string) (int64, error) {
+	w := b.client.Bucket(b.bucketName).Object(path).NewWriter(ctx)
+	w.ContentType = contentType
+	size, err := io.Copy(w, r)
+	if err != nil {
+		_ = w.Close()
+		return 0, fmt.Errorf("failed to write to GCS: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finalize GCS object: %w", err)
+	}
+	return size, nil
+}
+
+func (b *gcsBackend) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	r, err := b.client.Bucket(b.bucketName).Object(path).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (b *gcsBackend) Delete(ctx context.Context, path string) error {
+	if err := b.client.Bucket(b.bucketName).Object(path).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", path, err)
+	}
+	return nil
+}
+
+func (b *gcsBackend) SignedURL(ctx context.Context, path string, expiration time.Duration) (string, error) {
+	opts := &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  "GET",
+		Expires: time.Now().UTC().Add(expiration),
+	}
+	url, err := b.client.Bucket(b.bucketName).SignedURL(path, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate signed URL for %s: %w", path, err)
+	}
+	return url, nil
+}
+
+func (b *gcsBackend) PublicURL(path string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", b.bucketName, path)
+}
+
+func (b *gcsBackend) URI(path string) string {
+	return fmt.Sprintf("gs://%s/%s", b.bucketName, path)
+}
+
+func (b *gcsBackend) Close() error {
+	return b.client.Close()
+}
+
+// cleanObjectPath rejects keys that could escape the bucket/root directory
+func cleanObjectPath(path string) (string, error) {
+	path = strings.TrimLeft(path, "/")
+	if path == "" || strings.Contains(path, "\\") || strings.ContainsRune(path, 0) {
+		return "", fmt.Errorf("invalid object path %q", path)
+	}
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", fmt.Errorf("invalid object path %q", path)
+		}
+	}
+	return path, nil
+}
+
+// Options selects and configures a backend; see OptionsFromSettings
+type Options struct {
+	Provider string // gcs | local | s3
+	IsPublic bool
+	GCS      Config
+	Local    LocalConfig
+	S3       S3Config
+	// DevFallback stores on local disk when the selected provider has no credentials
+	// (development and tests only, so uploads and thumbnails keep working)
+	DevFallback bool
+}
+
+// Open returns a client for the configured provider
+func Open(ctx context.Context, opts Options) (*Client, error) {
+	provider := strings.ToLower(strings.TrimSpace(opts.Provider))
+	if opts.DevFallback && !opts.hasCredentials(provider) {
+		provider = ProviderLocal
+	}
+
+	var backend Backend
+	var err error
+	switch provider {
+	case ProviderGCS, "":
+		backend, err = newGCSBackend(ctx, opts.GCS)
+	case ProviderLocal:
+		backend, err = NewLocalBackend(opts.Local)
+	case ProviderS3:
+		backend, err = NewS3Backend(opts.S3)
+	default:
+		return nil, fmt.Errorf("unsupported storage provider %q", opts.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithBackend(backend, opts.IsPublic), nil
+}
+
+func (o Options) hasCredentials(provider string) bool {
+	switch provider {
+	case ProviderGCS, "":
+		return o.GCS.BucketName != "" && o.GCS.CredentialsKey != ""
+	case ProviderS3:
+		return o.S3.Bucket != "" && o.S3.AccessKeyID != "" && o.S3.SecretAccessKey != ""
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+// Package storagegcs - Local filesystem backend with HMAC-signed download URLs
+package storagegcs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalRoute is the path prefix of the endpoint that serves local objects
+const LocalRoute = "/storage/local/"
+
+// LocalConfig configures the local filesystem backend
+type LocalConfig struct {
+	Dir        string // root directory for objects
+	BaseURL    string // API base URL the signed links point to (e.g. http://localhost:4000)
+	SigningKey string // HMAC key for download links; must be the same on every instance
+}
+
+// LocalBackend stores objects under a directory and serves them through signed URLs of the
+// form {BaseURL}/storage/local/{path}?expires={unix}&sig={hmac}
+type LocalBackend struct {
+	dir     string
+	baseURL string
+	key     []byte
+	now     func() time.Time
+}
+
+// NewLocalBackend creates the directory if needed and returns the backend
+func NewLocalBackend(config LocalConfig) (*LocalBackend, error) {
+	if config.Dir == "" {
+		return nil, errors.New("local storage: directory is required")
+	}
+	if config.SigningKey == "" {
+		return nil, errors.New("local storage: signing key is required")
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("local storage: create %s: %w", config.Dir, err)
+	}
+	return &LocalBackend{
+		dir:     config.Dir,
+		baseURL: strings.TrimRight(config.BaseURL, "/"),
+		key:     []byte(config.SigningKey),
+		now:     time.Now,
+	}, nil
+}
+
+func (b *LocalBackend) file(path string) (string, error) {
+	clean, err := cleanObjectPath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(b.dir, filepath.FromSlash(clean)), nil
+}
+
+// Put writes the object through a temporary file so readers never see partial content
+func (b *LocalBackend) Put(ctx context.Context, path string, r io.Reader, contentType string) (int64, error) {
+	dst, err := b.file(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, fmt.Errorf("local storage: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("local storage: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("local storage: write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return 0, fmt.Errorf("local storage: %w", err)
+	}
+	return size, nil
+}
+
+func (b *LocalBackend) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	f, err := b.file(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := os.Open(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (b *LocalBackend) Delete(ctx context.Context, path string) error {
+	f, err := b.file(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(f); err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", path, err)
+	}
+	return nil
+}
+
+func (b *LocalBackend) SignedURL(ctx context.Context, path string, expiration time.Duration) (string, error) {
+	clean, err := cleanObjectPath(path)
+	if err != nil {
+		return "", err
+	}
+	expires := b.now().Add(expiration).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", b.sign(clean, expires))
+	return b.baseURL + LocalRoute + escapePath(clean) + "?" + q.Encode(), nil
+}
+
+// PublicURL is not available for local storage; objects are only served with a signature
+func (b *LocalBackend) PublicURL(path string) string {
+	return b.URI(path)
+}
+
+func (b *LocalBackend) URI(path string) string {
+	return "local://" + strings.TrimLeft(path, "/")
+}
+
+func (b *LocalBackend) Close() error { return nil }
+
+func (b *LocalBackend) sign(path string, expires int64) string {
+	mac := hmac.New(sha256.New, b.key)
+	fmt.Fprintf(mac, "%s\n%d", path, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeObject writes the object at path if the request carries a valid, unexpired signature
+func (b *LocalBackend) ServeObject(w http.ResponseWriter, req *http.Request, path string) {
+	clean, err := cleanObjectPath(path)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	q := req.URL.Query()
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(q.Get("sig")), []byte(b.sign(clean, expires))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if b.now().Unix() > expires {
+		http.Error(w, "link expired", http.StatusGone)
+		return
+	}
+	f, err := os.Open(filepath.Join(b.dir, filepath.FromSlash(clean)))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil || st.IsDir() {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeFor(clean))
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(expires-b.now().Unix(), 0), 10))
+	http.ServeContent(w, req, "", st.ModTime(), f)
+}
+
+// escapePath URL-encodes each segment of an object key
+func escapePath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,306 @@
+// Package storagegcs - S3-compatible backend (AWS S3, MinIO, Cloudflare R2...) signed with SigV4
+package storagegcs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config configures the S3-compatible backend
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.eu-central-1.amazonaws.com or https://<account>.r2.cloudflarestorage.com
+	Region          string // e.g. us-east-1 ("auto" for R2)
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses objects as {endpoint}/{bucket}/{key} (MinIO, R2) instead of
+	// {bucket}.{endpoint host}/{key}
+	PathStyle bool
+}
+
+// s3MaxPresign is the longest expiry SigV4 presigned URLs accept (7 days)
+const s3MaxPresign = 7 * 24 * time.Hour
+
+type s3Backend struct {
+	cfg      S3Config
+	endpoint *url.URL
+	http     *http.Client
+	now      func() time.Time
+}
+
+// NewS3Backend validates the configuration and returns the backend
+func NewS3Backend(config S3Config) (Backend, error) {
+	if config.Bucket == "" || config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("s3 storage: bucket and credentials are required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	ep, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || ep.Host == "" {
+		return nil, fmt.Errorf("s3 storage: invalid endpoint %q", config.Endpoint)
+	}
+	return &s3Backend{cfg: config, endpoint: ep, http: &http.Client{Timeout: 10 * time.Minute}, now: time.Now}, nil
+}
+
+// objectURL returns the URL of key (unsigned)
+func (b *s3Backend) objectURL(key string) *url.URL {
+	u := *b.endpoint
+	if b.cfg.PathStyle {
+		u.Path = b.endpoint.Path + "/" + b.cfg.Bucket + "/" + key
+	} else {
+		u.Host = b.cfg.Bucket + "." + b.endpoint.Host
+		u.Path = b.endpoint.Path + "/" + key
+	}
+	u.RawPath = ""
+	return &u
+}
+
+func (b *s3Backend) Put(ctx context.Context, path string, r io.Reader, contentType string) (int64, error) {
+	key, err := cleanObjectPath(path)
+	if err != nil {
+		return 0, err
+	}
+	// S3 needs Content-Length; spool unsized streams (video uploads) to a temp file
+	body, size, cleanup, err := sizedBody(r)
+	if err != nil {
+		return 0, fmt.Errorf("s3 storage: %w", err)
+	}
+	defer cleanup()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.objectURL(key).String(), body)
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	if err := b.do(req, "UNSIGNED-PAYLOAD"); err != nil {
+		return 0, fmt.Errorf("s3 storage: put %s: %w", key, err)
+	}
+	return size, nil
+}
+
+func (b *s3Backend) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	key, err := cleanObjectPath(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	b.signRequest(req, emptyPayloadHash, b.now())
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", path, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("failed to read object %s: status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+func (b *s3Backend) Delete(ctx context.Context, path string) error {
+	key, err := cleanObjectPath(path)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, b.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	if err := b.do(req, emptyPayloadHash); err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", path, err)
+	}
+	return nil
+}
+
+func (b *s3Backend) do(req *http.Request, payloadHash string) error {
+	b.signRequest(req, payloadHash, b.now())
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (b *s3Backend) SignedURL(ctx context.Context, path string, expiration time.Duration) (string, error) {
+	key, err := cleanObjectPath(path)
+	if err != nil {
+		return "", err
+	}
+	if expiration <= 0 || expiration > s3MaxPresign {
+		expiration = s3MaxPresign
+	}
+	return b.presign(http.MethodGet, key, expiration, b.now()), nil
+}
+
+func (b *s3Backend) PublicURL(path string) string {
+	return b.objectURL(strings.TrimLeft(path, "/")).String()
+}
+
+func (b *s3Backend) URI(path string) string {
+	return fmt.Sprintf("s3://%s/%s", b.cfg.Bucket, strings.TrimLeft(path, "/"))
+}
+
+func (b *s3Backend) Close() error {
+	b.http.CloseIdleConnections()
+	return nil
+}
+
+// ---------------------------
+// AWS Signature Version 4
+// ---------------------------
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (b *s3Backend) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + b.cfg.Region + "/s3/aws4_request"
+}
+
+func (b *s3Backend) signature(t time.Time, canonicalRequest string) string {
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + b.scope(t) + "\n" + hex.EncodeToString(sum[:])
+	k := hmacSHA256([]byte("AWS4"+b.cfg.SecretAccessKey), t.Format("20060102"))
+	k = hmacSHA256(k, b.cfg.Region)
+	k = hmacSHA256(k, "s3")
+	k = hmacSHA256(k, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(k, stringToSign))
+}
+
+// signRequest adds the Authorization header (host, x-amz-content-sha256, x-amz-date signed)
+func (b *s3Backend) signRequest(req *http.Request, payloadHash string, now time.Time) {
+	t := now.UTC()
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + t.Format("20060102T150405Z") + "\n",
+		signed,
+		payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.cfg.AccessKeyID, b.scope(t), signed, b.signature(t, canonical)))
+}
+
+// presign returns a query-signed URL for key valid for expiration
+func (b *s3Backend) presign(method, key string, expiration time.Duration, now time.Time) string {
+	t := now.UTC()
+	u := b.objectURL(key)
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", b.cfg.AccessKeyID+"/"+b.scope(t))
+	q.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expiration.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	canonical := strings.Join([]string{
+		method,
+		s3EscapePath(u.Path),
+		canonicalQuery(q),
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	return u.Scheme + "://" + u.Host + s3EscapePath(u.Path) + "?" + canonicalQuery(q) + "&X-Amz-Signature=" + b.signature(t, canonical)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape is the SigV4 URI encoding: everything but unreserved characters is %XX-encoded
+func s3Escape(s string, keepSlash bool) string {
+	var sb strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', keepSlash && c == '/':
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func s3EscapePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return s3Escape(p, true)
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// sizedBody returns r with a known length, spooling it to a temporary file if necessary
+func sizedBody(r io.Reader) (io.Reader, int64, func(), error) {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return r, int64(v.Len()), func() {}, nil
+	case *os.File:
+		if st, err := v.Stat(); err == nil && st.Mode().IsRegular() {
+			if pos, err := v.Seek(0, io.SeekCurrent); err == nil {
+				return r, st.Size() - pos, func() {}, nil
+			}
+		}
+	}
+	tmp, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	return tmp, size, cleanup, nil
+}
@@ -0,0 +1,63 @@
+// Package storagegcs - Backend selection from system settings and Encore secrets
+package storagegcs
+
+import (
+	"encore.dev"
+
+	"encore.app/pkg/config"
+)
+
+// Secrets are the storage credentials a service reads from its Encore secrets
+type Secrets struct {
+	GCSProjectID       string
+	GCSBucketName      string
+	GCSCredentialsJSON string
+	S3AccessKeyID      string
+	S3SecretAccessKey  string
+	MediaSigningKey    string // HMAC key for local storage links
+}
+
+// devSigningKey signs local links when running on a developer machine without
+// MediaSigningKey; every other environment must set the secret or local storage stays off
+const devSigningKey = "dev-only-media-signing-key"
+
+// OptionsFromSettings builds backend options from media.storage_provider (and the
+// media.local.* / media.s3.* settings). Outside production a provider without credentials
+// falls back to local disk.
+func OptionsFromSettings(s *config.SystemSettings, sec Secrets) Options {
+	if s == nil {
+		s = &config.SystemSettings{}
+	}
+	production := encore.Meta().Environment.Type == encore.EnvProduction
+	signingKey := sec.MediaSigningKey
+	if signingKey == "" && encore.Meta().Environment.Cloud == encore.CloudLocal {
+		signingKey = devSigningKey
+	}
+	localDir := s.MediaLocalDir
+	if localDir == "" {
+		localDir = "data/media"
+	}
+	baseURL := encore.Meta().APIBaseURL
+	return Options{
+		Provider: s.MediaStorageProvider,
+		GCS: Config{
+			ProjectID:      sec.GCSProjectID,
+			BucketName:     sec.GCSBucketName,
+			CredentialsKey: sec.GCSCredentialsJSON,
+		},
+		Local: LocalConfig{
+			Dir:        localDir,
+			BaseURL:    baseURL.String(),
+			SigningKey: signingKey,
+		},
+		S3: S3Config{
+			Endpoint:        s.MediaS3Endpoint,
+			Region:          s.MediaS3Region,
+			Bucket:          s.MediaS3Bucket,
+			AccessKeyID:     sec.S3AccessKeyID,
+			SecretAccessKey: sec.S3SecretAccessKey,
+			PathStyle:       s.MediaS3PathStyle,
+		},
+		DevFallback: !production,
+	}
+}
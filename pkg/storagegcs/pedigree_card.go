@@ -0,0 +1,250 @@
+// Package storagegcs - Printable pedigree card rendered with the watermark font helpers
+package storagegcs
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/font"
+
+	"encore.app/pkg/arabic"
+	"encore.app/pkg/pedigree"
+)
+
+// Card size: A5 landscape at 150 DPI
+const (
+	pedigreeCardW       = 1754
+	pedigreeCardH       = 1240
+	pedigreeCardMargin  = 48
+	pedigreeCardHeaderH = 110
+	pedigreeCardGens    = 4 // bird, parents, grandparents, great-grandparents
+)
+
+var (
+	cardBackground = color.RGBA{R: 253, G: 251, B: 246, A: 255}
+	cardInk        = color.RGBA{R: 33, G: 37, B: 41, A: 255}
+	cardMuted      = color.RGBA{R: 108, G: 117, B: 125, A: 255}
+	cardLine       = color.RGBA{R: 173, G: 181, B: 189, A: 255}
+	cardSire       = color.RGBA{R: 37, G: 99, B: 235, A: 255}
+	cardDam        = color.RGBA{R: 219, G: 39, B: 119, A: 255}
+	cardSubject    = color.RGBA{R: 21, G: 128, B: 61, A: 255}
+	cardBoxFill    = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	cardEmptyFill  = color.RGBA{R: 246, G: 244, B: 238, A: 255}
+)
+
+// font size per generation column (subject first)
+var pedigreeCardFontSizes = [pedigreeCardGens]float64{30, 26, 22, 18}
+
+// RenderPedigreeCard draws the pedigree as a PNG card: the bird on the right and its
+// ancestors to the left (RTL), up to four generations
+func RenderPedigreeCard(root *pedigree.Node, title string) ([]byte, error) {
+	if root == nil {
+		return nil, fmt.Errorf("pedigree card: empty pedigree")
+	}
+	img := image.NewRGBA(image.Rect(0, 0, pedigreeCardW, pedigreeCardH))
+	draw.Draw(img, img.Bounds(), image.NewUniform(cardBackground), image.Point{}, draw.Src)
+
+	faces := make([]font.Face, pedigreeCardGens)
+	for i, size := range pedigreeCardFontSizes {
+		faces[i] = newArabicFace(size)
+	}
+	defer func() {
+		for _, f := range faces {
+			if closer, ok := f.(interface{ Close() error }); ok {
+				_ = closer.Close()
+			}
+		}
+	}()
+	headerFace := newArabicFace(40)
+	defer func() {
+		if closer, ok := headerFace.(interface{ Close() error }); ok {
+			_ = closer.Close()
+		}
+	}()
+
+	// Header: title on the right, ring number on the left
+	right := pedigreeCardW - pedigreeCardMargin
+	if strings.TrimSpace(title) == "" {
+		title = "شجرة النسب"
+	}
+	drawCardLine(img, headerFace, title, right, pedigreeCardMargin, right-pedigreeCardMargin-400, cardInk)
+	ringW, _, _ := measure(headerFace, root.RingNumber)
+	drawCardLine(img, headerFace, root.RingNumber, pedigreeCardMargin+ringW, pedigreeCardMargin, 400, cardMuted)
+	fillRect(img, image.Rect(pedigreeCardMargin, pedigreeCardHeaderH, right, pedigreeCardHeaderH+3), cardSubject)
+
+	top := pedigreeCardHeaderH + 30
+	height := pedigreeCardH - pedigreeCardMargin - top
+	colW := (pedigreeCardW - 2*pedigreeCardMargin) / pedigreeCardGens
+	gap := 40
+
+	var drawNode func(n *pedigree.Node, gen, slot int, accent color.RGBA) image.Rectangle
+	drawNode = func(n *pedigree.Node, gen, slot int, accent color.RGBA) image.Rectangle {
+		slotH := height >> gen
+		boxH := slotH - 16
+		if boxH > 260 {
+			boxH = 260
+		}
+		x1 := right - gen*colW
+		x0 := x1 - colW + gap
+		y0 := top + slot*slotH + (slotH-boxH)/2
+		box := image.Rect(x0, y0, x1, y0+boxH)
+
+		if n == nil {
+			fillRect(img, box, cardEmptyFill)
+			strokeRect(img, box, cardLine, 1)
+			return box
+		}
+		fillRect(img, box, cardBoxFill)
+		strokeRect(img, box, accent, 2)
+		fillRect(img, image.Rect(x1-6, y0, x1, y0+boxH), accent)
+		drawBirdText(img, faces[gen], &n.Bird, box, gen)
+
+		if gen+1 < pedigreeCardGens && (n.Sire != nil || n.Dam != nil || gen == 0) {
+			sire := drawNode(n.Sire, gen+1, slot*2, cardSire)
+			dam := drawNode(n.Dam, gen+1, slot*2+1, cardDam)
+			connect(img, box, sire)
+			connect(img, box, dam)
+		}
+		return box
+	}
+	drawNode(root, 0, 0, cardSubject)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("pedigree card: encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawBirdText writes the bird details right-aligned inside box, as many lines as fit
+func drawBirdText(img *image.RGBA, face font.Face, b *pedigree.Bird, box image.Rectangle, gen int) {
+	pad := 14
+	_, lineH, _ := measure(face, "Ag")
+	lineH += 4
+	type line struct {
+		text string
+		col  color.RGBA
+	}
+	lines := []line{{b.RingNumber, cardInk}}
+	if b.Name != "" {
+		lines = append(lines, line{b.Name, cardInk})
+	}
+	var meta []string
+	if s := cardSexLabel(b.Sex); s != "" {
+		meta = append(meta, s)
+	}
+	if b.BirthYear != nil {
+		meta = append(meta, fmt.Sprintf("%d", *b.BirthYear))
+	}
+	if b.Color != "" {
+		meta = append(meta, b.Color)
+	}
+	details := []string{strings.Join(meta, " - ")}
+	if b.Breeder != "" {
+		details = append(details, "المربي: "+b.Breeder)
+	}
+	for i, r := range b.Results {
+		if i == 2 || (gen > 1 && i == 1) {
+			break
+		}
+		details = append(details, cardResultLine(r))
+	}
+	for _, d := range details {
+		if d != "" {
+			lines = append(lines, line{d, cardMuted})
+		}
+	}
+
+	y := box.Min.Y + pad
+	for _, l := range lines {
+		if y+lineH > box.Max.Y-pad/2 {
+			break
+		}
+		drawCardLine(img, face, l.text, box.Max.X-pad-6, y, box.Dx()-2*pad-6, l.col)
+		y += lineH
+	}
+}
+
+func cardSexLabel(sex string) string {
+	switch sex {
+	case "male":
+		return "ذكر"
+	case "female":
+		return "أنثى"
+	}
+	return ""
+}
+
+// cardResultLine formats a race result, e.g. "المركز 3/450 - سباق الرياض 300 كم"
+func cardResultLine(r pedigree.RaceResult) string {
+	var parts []string
+	if r.Position != nil {
+		p := fmt.Sprintf("المركز %d", *r.Position)
+		if r.TotalBirds != nil {
+			p += fmt.Sprintf("/%d", *r.TotalBirds)
+		}
+		parts = append(parts, p)
+	}
+	race := r.RaceName
+	if r.DistanceKM != nil {
+		race += fmt.Sprintf(" %.0f كم", *r.DistanceKM)
+	}
+	return strings.Join(append(parts, race), " - ")
+}
+
+// drawCardLine draws one line of text right-aligned at x=right, shortened with an ellipsis to
+// fit maxW. Arabic is shaped and reordered with pkg/arabic; presentation forms missing from
+// the font fall back to the base letter.
+func drawCardLine(img *image.RGBA, face font.Face, s string, right, top, maxW int, col color.RGBA) {
+	runes := []rune(strings.TrimSpace(s))
+	visual := cardVisual(face, string(runes))
+	w, _, ascent := measure(face, visual)
+	for w > maxW && len(runes) > 1 {
+		runes = runes[:len(runes)-1]
+		visual = cardVisual(face, strings.TrimSpace(string(runes))+"…")
+		w, _, _ = measure(face, visual)
+	}
+	_ = drawTextWithFont(img, visual, right-w, top, ascent, w, face, col, 100, false)
+}
+
+func cardVisual(face font.Face, s string) string {
+	v := arabic.Visual(s)
+	for i, r := range v {
+		if _, ok := face.GlyphAdvance(r); !ok {
+			if b := arabic.Base(r); b != 0 {
+				v[i] = b
+			}
+		}
+	}
+	return string(v)
+}
+
+// connect draws an elbow line from the left edge of child to the right edge of parent
+func connect(img *image.RGBA, child, parent image.Rectangle) {
+	cy := (child.Min.Y + child.Max.Y) / 2
+	py := (parent.Min.Y + parent.Max.Y) / 2
+	mx := (child.Min.X + parent.Max.X) / 2
+	fillRect(img, image.Rect(mx, cy-1, child.Min.X, cy+1), cardLine)
+	y0, y1 := cy, py
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	fillRect(img, image.Rect(mx-1, y0-1, mx+1, y1+1), cardLine)
+	fillRect(img, image.Rect(parent.Max.X, py-1, mx, py+1), cardLine)
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func strokeRect(img *image.RGBA, r image.Rectangle, c color.RGBA, w int) {
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+w), c)
+	fillRect(img, image.Rect(r.Min.X, r.Max.Y-w, r.Max.X, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+w, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Max.X-w, r.Min.Y, r.Max.X, r.Max.Y), c)
+}
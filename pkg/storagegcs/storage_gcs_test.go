@@ -1,10 +1,14 @@
 package storagegcs
 
 import (
+	"bytes"
 	"image"
 	"image/color"
+	"image/png"
 	"strings"
 	"testing"
+
+	"encore.app/pkg/pedigree"
 )
 
 func TestGetMediaKind(t *testing.T) {
@@ -152,7 +156,7 @@ func TestNewClient(t *testing.T) {
 }
 
 func TestGetPublicURL(t *testing.T) {
-	client := &Client{bucketName: "test-bucket", isPublic: true}
+	client := &Client{backend: &gcsBackend{bucketName: "test-bucket"}, isPublic: true}
 
 	gcsPath := "media/2025/01/01/12-00-00/test.jpg"
 	expected := "https://storage.googleapis.com/test-bucket/media/2025/01/01/12-00-00/test.jpg"
@@ -289,3 +293,32 @@ func TestLoadMediaSettingsFromDB(t *testing.T) {
 		t.Errorf("Expected WatermarkOpacity 0.7, got %f", settings.WatermarkOpacity)
 	}
 }
+
+func TestRenderPedigreeCard(t *testing.T) {
+	year := 2022
+	pos, total := 3, 450
+	dist := 300.0
+	root := &pedigree.Node{
+		Bird: pedigree.Bird{RingNumber: "SA-2024-1001", Name: "الصقر", Sex: "male", BirthYear: &year,
+			Results: []pedigree.RaceResult{{RaceName: "سباق الرياض", Position: &pos, TotalBirds: &total, DistanceKM: &dist}}},
+		Sire: &pedigree.Node{
+			Bird: pedigree.Bird{RingNumber: "BE-2019-55", Sex: "male"},
+			Sire: &pedigree.Node{Bird: pedigree.Bird{RingNumber: "BE-2015-7"}},
+		},
+	}
+
+	out, err := RenderPedigreeCard(root, "شجرة نسب الصقر")
+	if err != nil {
+		t.Fatalf("RenderPedigreeCard: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode card: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != pedigreeCardW || b.Dy() != pedigreeCardH {
+		t.Fatalf("card size = %v", b)
+	}
+	if _, err := RenderPedigreeCard(nil, ""); err == nil {
+		t.Fatal("nil pedigree should fail")
+	}
+}
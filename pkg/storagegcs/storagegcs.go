@@ -18,17 +18,16 @@ import (
 	"strings"
 	"time"
 
-	"cloud.google.com/go/storage"
 	xdraw "golang.org/x/image/draw" // High-quality scaling
-	"google.golang.org/api/option"
+	_ "golang.org/x/image/webp"     // WebP decoding for validation and variants
+
+	"encore.app/pkg/video"
 )
 
-// Client wraps Google Cloud Storage operations
+// Client uploads and processes media on top of a storage Backend (GCS by default)
 type Client struct {
-	client     *storage.Client
-	bucketName string
-	projectID  string
-	isPublic   bool
+	backend  Backend
+	isPublic bool
 }
 
 // Config holds configuration for GCS client
@@ -79,22 +78,11 @@ type UploadResult struct {
 
 // NewClient creates a new GCS client
 func NewClient(ctx context.Context, config Config) (*Client, error) {
-	var opts []option.ClientOption
-	if config.CredentialsKey != "" {
-		opts = append(opts, option.WithCredentialsJSON([]byte(config.CredentialsKey)))
-	}
-
-	client, err := storage.NewClient(ctx, opts...)
+	backend, err := newGCSBackend(ctx, config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create storage client: %w", err)
+		return nil, err
 	}
-
-	return &Client{
-		client:     client,
-		bucketName: config.BucketName,
-		projectID:  config.ProjectID,
-		isPublic:   config.IsPublic,
-	}, nil
+	return NewClientWithBackend(backend, config.IsPublic), nil
 }
 
 // Upload uploads a file to GCS with optional thumbnail generation and watermarking
@@ -153,29 +141,66 @@ func (c *Client) Upload(ctx context.Context, reader io.Reader, fileName string,
 	return result, nil
 }
 
-// Delete removes a file from GCS
-func (c *Client) Delete(ctx context.Context, gcsPath string) error {
-	obj := c.client.Bucket(c.bucketName).Object(gcsPath)
-	if err := obj.Delete(ctx); err != nil {
-		return fmt.Errorf("failed to delete object %s: %w", gcsPath, err)
+// UploadOriginal stores the file as received, without watermark or thumbnails. Images are
+// validated and kept under originals/ for the media worker, which publishes the processed
+// variants; videos too (container sniffed here, probed and transcoded by the worker). Other
+// files are stored under media/ as usual.
+func (c *Client) UploadOriginal(ctx context.Context, reader io.Reader, fileName string) (*UploadResult, error) {
+	secureFileName, err := generateSecureFileName(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secure filename: %w", err)
 	}
-	return nil
-}
 
-// GetSignedURL generates a signed URL for downloading a file
-func (c *Client) GetSignedURL(ctx context.Context, gcsPath string, expiration time.Duration) (string, error) {
-	opts := &storage.SignedURLOptions{
-		Scheme:  storage.SigningSchemeV4,
-		Method:  "GET",
-		Expires: time.Now().UTC().Add(expiration),
+	kind := c.getMediaKind(secureFileName)
+	originalPath := OriginalsPrefix + strings.TrimPrefix(c.generatePath(secureFileName), "media/")
+	switch kind {
+	case MediaKindVideo:
+		// Reject non-video content early; codecs and duration are checked by the worker
+		head := make([]byte, 16)
+		n, err := io.ReadFull(reader, head)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("failed to read content: %w", err)
+		}
+		if video.SniffContainer(head[:n]) == "" {
+			return nil, fmt.Errorf("file validation failed: not a supported video container")
+		}
+		return c.uploadStream(ctx, io.MultiReader(bytes.NewReader(head[:n]), reader), originalPath, kind, fileName)
+	case MediaKindFile:
+		return c.uploadStream(ctx, reader, c.generatePath(secureFileName), kind, fileName)
 	}
 
-	url, err := c.client.Bucket(c.bucketName).SignedURL(gcsPath, opts)
+	content, err := io.ReadAll(reader)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate signed URL for %s: %w", gcsPath, err)
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	if err := c.validateFileContent(content, fileName); err != nil {
+		return nil, fmt.Errorf("file validation failed: %w", err)
 	}
 
-	return url, nil
+	if err := c.uploadFile(ctx, content, originalPath); err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+	return &UploadResult{GCSPath: originalPath, Kind: kind, Size: int64(len(content))}, nil
+}
+
+// Put stores content at path as-is and returns its size
+func (c *Client) Put(ctx context.Context, path string, r io.Reader) (int64, error) {
+	return c.backend.Put(ctx, path, r, c.getContentType(path))
+}
+
+// Open returns a reader for a stored file
+func (c *Client) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	return c.backend.Open(ctx, path)
+}
+
+// Delete removes a file from storage
+func (c *Client) Delete(ctx context.Context, gcsPath string) error {
+	return c.backend.Delete(ctx, gcsPath)
+}
+
+// GetSignedURL generates a signed URL for downloading a file
+func (c *Client) GetSignedURL(ctx context.Context, gcsPath string, expiration time.Duration) (string, error) {
+	return c.backend.SignedURL(ctx, gcsPath, expiration)
 }
 
 // CreateUploadConfigFromSettings creates UploadConfig from MediaSettings
@@ -340,17 +365,15 @@ func LoadMediaSettingsFromDB(db interface{}) (*MediaSettings, error) {
 }
 
 // GetPublicURL returns the public URL for a file (if bucket is public)
-// GetPublicURL returns the public URL for a GCS object
 // For private buckets, this should be replaced with GetSignedURL
 func (c *Client) GetPublicURL(gcsPath string) string {
 	if c.isPublic {
 		// For public buckets, return direct public URL
-		return fmt.Sprintf("https://storage.googleapis.com/%s/%s", c.bucketName, gcsPath)
-	} else {
-		// For private buckets, return a placeholder that indicates signed URL is needed
-		// This should not be used directly - use GetSecureURL instead
-		return fmt.Sprintf("gs://%s/%s", c.bucketName, gcsPath)
+		return c.backend.PublicURL(gcsPath)
 	}
+	// For private buckets, return a placeholder that indicates signed URL is needed
+	// This should not be used directly - use GetSecureURL instead
+	return c.backend.URI(gcsPath)
 }
 
 // GetSecureURL returns a signed URL for private bucket access
@@ -365,9 +388,9 @@ func (c *Client) GetSecureURL(ctx context.Context, gcsPath string, expiration ti
 	return c.GetSignedURL(ctx, gcsPath, expiration)
 }
 
-// Close closes the GCS client
+// Close closes the storage backend
 func (c *Client) Close() error {
-	return c.client.Close()
+	return c.backend.Close()
 }
 
 // getMediaKind determines media kind from file extension
@@ -377,7 +400,7 @@ func (c *Client) getMediaKind(fileName string) MediaKind {
 	switch ext {
 	case ".jpg", ".jpeg", ".png", ".webp":
 		return MediaKindImage
-	case ".mp4":
+	case ".mp4", ".m4v", ".mov", ".webm":
 		return MediaKindVideo
 	case ".pdf":
 		return MediaKindFile
@@ -397,16 +420,11 @@ func (c *Client) generatePath(fileName string) string {
 	return fmt.Sprintf("media/%s/%s%s", timestamp, name, ext)
 }
 
-// uploadStream uploads content directly from reader to GCS (for large files)
+// uploadStream uploads content directly from reader to storage (for large files)
 func (c *Client) uploadStream(ctx context.Context, reader io.Reader, gcsPath string, kind MediaKind, originalFileName string) (*UploadResult, error) {
-	obj := c.client.Bucket(c.bucketName).Object(gcsPath)
-	w := obj.NewWriter(ctx)
-	w.ContentType = c.getContentType(gcsPath)
-	defer w.Close()
-
-	size, err := io.Copy(w, reader)
+	size, err := c.backend.Put(ctx, gcsPath, reader, c.getContentType(gcsPath))
 	if err != nil {
-		return nil, fmt.Errorf("failed to stream to GCS: %w", err)
+		return nil, fmt.Errorf("failed to stream to storage: %w", err)
 	}
 
 	return &UploadResult{
@@ -419,22 +437,18 @@ func (c *Client) uploadStream(ctx context.Context, reader io.Reader, gcsPath str
 	}, nil
 }
 
-// uploadFile uploads content to GCS
+// uploadFile uploads content to storage
 func (c *Client) uploadFile(ctx context.Context, content []byte, gcsPath string) error {
-	obj := c.client.Bucket(c.bucketName).Object(gcsPath)
-	w := obj.NewWriter(ctx)
-	w.ContentType = c.getContentType(gcsPath)
-	defer w.Close()
-
-	if _, err := w.Write(content); err != nil {
-		return fmt.Errorf("failed to write to GCS: %w", err)
-	}
-
-	return nil
+	_, err := c.backend.Put(ctx, gcsPath, bytes.NewReader(content), c.getContentType(gcsPath))
+	return err
 }
 
 // getContentType determines content type from file extension
 func (c *Client) getContentType(fileName string) string {
+	return contentTypeFor(fileName)
+}
+
+func contentTypeFor(fileName string) string {
 	ext := strings.ToLower(filepath.Ext(fileName))
 
 	switch ext {
@@ -444,8 +458,14 @@ func (c *Client) getContentType(fileName string) string {
 		return "image/png"
 	case ".webp":
 		return "image/webp"
-	case ".mp4":
+	case ".avif":
+		return "image/avif"
+	case ".mp4", ".m4v":
 		return "video/mp4"
+	case ".mov":
+		return "video/quicktime"
+	case ".webm":
+		return "video/webm"
 	case ".pdf":
 		return "application/pdf"
 	default:
@@ -0,0 +1,407 @@
+// Package storagegcs - Responsive image variants: resized, watermarked, metadata-free re-encodes
+package storagegcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Object prefixes of the media pipeline
+const (
+	OriginalsPrefix = "originals/"      // uploads as received (never served publicly)
+	VariantsPrefix  = "media/variants/" // processed renditions
+)
+
+// VariantFormat is an output encoding of the media pipeline
+type VariantFormat string
+
+const (
+	FormatAVIF VariantFormat = "avif"
+	FormatWebP VariantFormat = "webp"
+	FormatJPEG VariantFormat = "jpeg"
+)
+
+// Ext returns the file extension of the format
+func (f VariantFormat) Ext() string {
+	if f == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// Defaults used when media.variants.* settings are empty
+var (
+	DefaultVariantWidths  = []int{320, 640, 1024, 1600}
+	DefaultVariantFormats = []VariantFormat{FormatAVIF, FormatWebP, FormatJPEG}
+)
+
+// VariantConfig describes the renditions built for an image
+type VariantConfig struct {
+	Widths            []int
+	Formats           []VariantFormat
+	ApplyWatermark    bool
+	WatermarkOpacity  int // 0-100
+	WatermarkPosition string
+	Quality           int // 1-100 (default 80)
+}
+
+func (cfg VariantConfig) quality() int {
+	if cfg.Quality <= 0 || cfg.Quality > 100 {
+		return 80
+	}
+	return cfg.Quality
+}
+
+// formats returns the configured formats, always including JPEG (the universal fallback
+// stored in media.gcs_path / thumb_path)
+func (cfg VariantConfig) formats() []VariantFormat {
+	src := cfg.Formats
+	if len(src) == 0 {
+		src = DefaultVariantFormats
+	}
+	var out []VariantFormat
+	seen := map[VariantFormat]bool{}
+	for _, f := range append(append([]VariantFormat(nil), src...), FormatJPEG) {
+		if (f == FormatAVIF || f == FormatWebP || f == FormatJPEG) && !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Signature identifies the settings variants were built with; media processed with another
+// signature are stale (e.g. after a watermark change) and can be re-processed
+func (cfg VariantConfig) Signature() string {
+	widths := cfg.Widths
+	if len(widths) == 0 {
+		widths = DefaultVariantWidths
+	}
+	sorted := append([]int(nil), widths...)
+	sort.Ints(sorted)
+	wm := "off"
+	if cfg.ApplyWatermark {
+		wm = fmt.Sprintf("%d/%s", cfg.WatermarkOpacity, cfg.WatermarkPosition)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("w=%v f=%v wm=%s q=%d", sorted, cfg.formats(), wm, cfg.quality())))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Variant is one encoded rendition
+type Variant struct {
+	Format  VariantFormat
+	Width   int
+	Height  int
+	Content []byte
+}
+
+// ContentType returns the MIME type of the variant
+func (v Variant) ContentType() string {
+	return "image/" + string(v.Format)
+}
+
+// ProcessedImage is the result of BuildVariants
+type ProcessedImage struct {
+	Width            int // after EXIF orientation
+	Height           int
+	WatermarkApplied bool
+	Variants         []Variant
+	// Unavailable lists configured formats skipped because no encoder is installed
+	Unavailable []VariantFormat
+}
+
+// ErrUnsupportedImage means the original cannot be decoded; retrying will not help
+var ErrUnsupportedImage = errors.New("unsupported or corrupt image")
+
+// BuildVariants decodes the original, applies its EXIF orientation and the watermark, then
+// encodes every width × format. Variants are re-encoded from pixels, so EXIF, GPS and other
+// metadata of the original are never carried over.
+func BuildVariants(ctx context.Context, original []byte, cfg VariantConfig) (*ProcessedImage, error) {
+	c := &Client{} // image helpers do not touch the backend
+	if err := c.validateImageContent(original); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	img = applyOrientation(img, exifOrientation(original))
+
+	out := &ProcessedImage{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if cfg.ApplyWatermark {
+		watermarked, err := c.ApplyImageWatermark(img, ImageWatermarkConfig{
+			LogoPath: "assets/logo.png",
+			Opacity:  cfg.WatermarkOpacity,
+			Position: cfg.WatermarkPosition,
+			Scale:    0.15,
+		})
+		if err == nil {
+			img = watermarked
+			out.WatermarkApplied = true
+		} else {
+			fmt.Printf("Warning: Failed to apply watermark: %v\n", err)
+		}
+	}
+
+	formats := cfg.formats()
+	encoders := make(map[VariantFormat]encodeFunc, len(formats))
+	for _, f := range formats {
+		if enc, ok := encoderFor(f); ok {
+			encoders[f] = enc
+		} else {
+			out.Unavailable = append(out.Unavailable, f)
+		}
+	}
+
+	for _, w := range variantWidths(cfg.Widths, out.Width) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		resized := c.generateThumbnail(img, w)
+		for _, f := range formats {
+			enc, ok := encoders[f]
+			if !ok {
+				continue
+			}
+			content, err := enc(ctx, resized, cfg.quality())
+			if err != nil {
+				return nil, fmt.Errorf("encode %s %dpx: %w", f, w, err)
+			}
+			out.Variants = append(out.Variants, Variant{
+				Format:  f,
+				Width:   resized.Bounds().Dx(),
+				Height:  resized.Bounds().Dy(),
+				Content: content,
+			})
+		}
+	}
+	return out, nil
+}
+
+// variantWidths clamps the configured widths to the source width (no upscaling) and dedupes them
+func variantWidths(widths []int, srcWidth int) []int {
+	if len(widths) == 0 {
+		widths = DefaultVariantWidths
+	}
+	seen := map[int]bool{}
+	var out []int
+	for _, w := range widths {
+		if w <= 0 {
+			continue
+		}
+		if w > srcWidth {
+			w = srcWidth
+		}
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	if len(out) == 0 {
+		out = []int{srcWidth}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// ---------------------------
+// Encoders
+// ---------------------------
+
+type encodeFunc func(ctx context.Context, img image.Image, quality int) ([]byte, error)
+
+// externalEncoders are the CLI tools used for formats the Go standard library cannot write
+// (install libwebp-tools / libavif-apps in the runtime image to enable them)
+var externalEncoders = map[VariantFormat]struct {
+	bin  string
+	args func(in, out string, quality int) []string
+}{
+	FormatWebP: {"cwebp", func(in, out string, q int) []string {
+		return []string{"-quiet", "-metadata", "none", "-q", strconv.Itoa(q), in, "-o", out}
+	}},
+	FormatAVIF: {"avifenc", func(in, out string, q int) []string {
+		return []string{"--speed", "6", "-q", strconv.Itoa(q), in, out}
+	}},
+}
+
+var lookPath = exec.LookPath
+
+func encoderFor(f VariantFormat) (encodeFunc, bool) {
+	if f == FormatJPEG {
+		return encodeJPEG, true
+	}
+	ext, ok := externalEncoders[f]
+	if !ok {
+		return nil, false
+	}
+	bin, err := lookPath(ext.bin)
+	if err != nil {
+		return nil, false
+	}
+	return func(ctx context.Context, img image.Image, quality int) ([]byte, error) {
+		return runEncoder(ctx, bin, ext.args, img, quality, f.Ext())
+	}, true
+}
+
+// encodeJPEG flattens transparency onto white (JPEG has no alpha channel)
+func encodeJPEG(_ context.Context, img image.Image, quality int) ([]byte, error) {
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode as JPEG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// runEncoder hands the pixels to an external encoder through a lossless PNG
+func runEncoder(ctx context.Context, bin string, args func(in, out string, q int) []string, img image.Image, quality int, ext string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "media-variant-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.png")
+	out := filepath.Join(dir, "out"+ext)
+	f, err := os.Create(in)
+	if err != nil {
+		return nil, err
+	}
+	err = (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if msg, err := exec.CommandContext(ctx, bin, args(in, out, quality)...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", filepath.Base(bin), err, bytes.TrimSpace(msg))
+	}
+	return os.ReadFile(out)
+}
+
+// ---------------------------
+// EXIF orientation
+// ---------------------------
+
+// exifOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 when there is none
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // fill byte
+			i++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) { // markers without length
+			i += 2
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // image data starts: no metadata after this
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		if seg := data[i+4 : i+2+size]; marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// tiffOrientation reads tag 0x0112 from IFD0 of a TIFF header
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(t[4:]))
+	if ifd < 8 || ifd+2 > len(t) {
+		return 1
+	}
+	for k, n := 0, int(order.Uint16(t[ifd:])); k < n; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(t) {
+			return 1
+		}
+		if order.Uint16(t[e:]) == 0x0112 {
+			if v := int(order.Uint16(t[e+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation rotates/flips img so it displays upright once the EXIF tag is gone
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirror horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirror vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 CW
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 CCW
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
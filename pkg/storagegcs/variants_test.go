@@ -0,0 +1,118 @@
+package storagegcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// jpegWithOrientation encodes a w×h JPEG (left half red, right half blue) and inserts an
+// EXIF APP1 segment carrying the orientation and a GPS IFD pointer
+func jpegWithOrientation(t *testing.T, w, h, orientation int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= w/2 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 2)
+	tiff = append(tiff, 0x01, 0x12, 0x00, 0x03, 0, 0, 0, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = append(tiff, 0, 0)
+	tiff = append(tiff, 0x88, 0x25, 0x00, 0x04, 0, 0, 0, 1, 0, 0, 0, 0) // GPSInfo
+	tiff = append(tiff, 0, 0, 0, 0)
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(app1)+2))
+	seg = append(seg, app1...)
+
+	src := buf.Bytes()
+	return append(append(append([]byte(nil), src[:2]...), seg...), src[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	for _, o := range []int{1, 3, 6, 8} {
+		if got := exifOrientation(jpegWithOrientation(t, 20, 10, o)); got != o {
+			t.Errorf("orientation %d read as %d", o, got)
+		}
+	}
+	if got := exifOrientation([]byte("\x89PNG\r\n")); got != 1 {
+		t.Errorf("non-JPEG orientation = %d", got)
+	}
+}
+
+func TestBuildVariants(t *testing.T) {
+	saved := lookPath
+	lookPath = func(string) (string, error) { return "", errors.New("not installed") }
+	defer func() { lookPath = saved }()
+
+	// 800×400 stored sideways (orientation 6): displays as 400×800
+	original := jpegWithOrientation(t, 800, 400, 6)
+	res, err := BuildVariants(context.Background(), original, VariantConfig{
+		Widths:  []int{640, 200, 1600, 200},
+		Formats: []VariantFormat{FormatWebP},
+	})
+	if err != nil {
+		t.Fatalf("BuildVariants: %v", err)
+	}
+	if res.Width != 400 || res.Height != 800 {
+		t.Fatalf("oriented size = %dx%d, want 400x800", res.Width, res.Height)
+	}
+	if len(res.Unavailable) != 1 || res.Unavailable[0] != FormatWebP {
+		t.Fatalf("unavailable = %v", res.Unavailable)
+	}
+	// widths clamp to the source (no upscaling) and JPEG is always produced
+	wantWidths := []int{200, 400}
+	if len(res.Variants) != len(wantWidths) {
+		t.Fatalf("variants = %d, want %d", len(res.Variants), len(wantWidths))
+	}
+	for i, v := range res.Variants {
+		if v.Format != FormatJPEG || v.Width != wantWidths[i] || v.Height != 2*wantWidths[i] {
+			t.Errorf("variant %d = %s %dx%d", i, v.Format, v.Width, v.Height)
+		}
+		if exifOrientation(v.Content) != 1 || bytes.Contains(v.Content, []byte("Exif\x00\x00")) {
+			t.Errorf("variant %d still carries EXIF", i)
+		}
+	}
+
+	// rotated 90° clockwise: the red (left) half of the stored image is now on top
+	img, err := jpeg.Decode(bytes.NewReader(res.Variants[1].Content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, b, _ := img.At(200, 100).RGBA(); r < b {
+		t.Errorf("top of the rotated image is not red")
+	}
+
+	if _, err := BuildVariants(context.Background(), []byte("not an image"), VariantConfig{}); !errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("corrupt image error = %v", err)
+	}
+}
+
+func TestVariantSignature(t *testing.T) {
+	a := VariantConfig{Widths: []int{640, 320}, ApplyWatermark: true, WatermarkOpacity: 30, WatermarkPosition: "center"}
+	b := VariantConfig{Widths: []int{320, 640}, ApplyWatermark: true, WatermarkOpacity: 30, WatermarkPosition: "center"}
+	if a.Signature() != b.Signature() {
+		t.Fatal("width order changed the signature")
+	}
+	b.WatermarkPosition = "bottom-right"
+	if a.Signature() == b.Signature() {
+		t.Fatal("watermark change kept the signature")
+	}
+}
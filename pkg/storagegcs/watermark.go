@@ -7,6 +7,7 @@ import (
 	"image/draw"
 	"math"
 	"os"
+	"sync"
 
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/basicfont"
@@ -15,8 +16,8 @@ import (
 )
 
 // ملاحظة: تمت إزالة go:embed لتجنب فشل البناء عند غياب الملف.
-// سنحاول قراءة الخط وقت التشغيل من المسار "assets/fonts/Cairo-Regular.ttf"،
-// وإن لم يتوفر، سنعتمد على basicfont كحل احتياطي.
+// نقرأ الخط وقت التشغيل من arabicFontCandidates (Cairo ثم Tajawal)،
+// وإن لم يتوفر خط صالح، نعتمد على basicfont كحل احتياطي.
 var arabicFontBytes []byte
 
 // WatermarkConfig holds configuration for watermarking
@@ -173,40 +174,54 @@ func (c *Client) blendColors(existing, overlay color.RGBA) color.RGBA {
 // أدوات الخط والرسم بالنص
 // ---------------------------
 
-func prepareFaceAndMeasure(cfg WatermarkConfig) (face font.Face, ascent, textW, textH int, err error) {
-	// محاولة تحميل الخط من الملف إذا لم يكن مضمنًا
-	if len(arabicFontBytes) == 0 {
-		// Try Cairo font first (better Arabic support)
-		if b, readErr := os.ReadFile("assets/fonts/Cairo-Regular.ttf"); readErr == nil {
-			arabicFontBytes = b
-		} else if b, readErr := os.ReadFile("assets/fonts/Tajawal-Regular.ttf"); readErr == nil {
-			// Fallback to Tajawal if Cairo not found
-			arabicFontBytes = b
-		}
-	}
+// arabicFontCandidates are tried in order; the first file that parses as a font is used
+var arabicFontCandidates = []string{"assets/fonts/Cairo-Regular.ttf", "assets/fonts/Tajawal-Regular.ttf"}
 
-	if len(arabicFontBytes) > 0 {
-		ft, pErr := opentype.Parse(arabicFontBytes)
-		if pErr != nil {
-			// في حال فشل التحليل لأي سبب، نستخدم basicfont
-			face = basicfont.Face7x13
-		} else {
-			var nErr error
-			face, nErr = opentype.NewFace(ft, &opentype.FaceOptions{
-				Size:    float64(cfg.FontSize),
-				DPI:     96,
-				Hinting: font.HintingFull,
-			})
-			if nErr != nil {
-				// fallback
-				face = basicfont.Face7x13
+var (
+	arabicFontOnce sync.Once
+	arabicFont     *opentype.Font
+)
+
+// loadArabicFont يقرأ أول خط صالح من arabicFontCandidates (nil إن لم يتوفر أي خط)
+func loadArabicFont() *opentype.Font {
+	arabicFontOnce.Do(func() {
+		if len(arabicFontBytes) > 0 {
+			if ft, err := opentype.Parse(arabicFontBytes); err == nil {
+				arabicFont = ft
+				return
 			}
 		}
-	} else {
-		// fallback: basicfont عند عدم توفر ملف الخط
-		face = basicfont.Face7x13
+		for _, path := range arabicFontCandidates {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			if ft, err := opentype.Parse(b); err == nil {
+				arabicFontBytes, arabicFont = b, ft
+				return
+			}
+		}
+	})
+	return arabicFont
+}
+
+// newArabicFace returns a face of the Arabic font at size pixels, or basicfont as fallback
+func newArabicFace(size float64) font.Face {
+	if ft := loadArabicFont(); ft != nil {
+		face, err := opentype.NewFace(ft, &opentype.FaceOptions{
+			Size:    size,
+			DPI:     96,
+			Hinting: font.HintingFull,
+		})
+		if err == nil {
+			return face
+		}
 	}
+	return basicfont.Face7x13
+}
 
+func prepareFaceAndMeasure(cfg WatermarkConfig) (face font.Face, ascent, textW, textH int, err error) {
+	face = newArabicFace(float64(cfg.FontSize))
 	textW, textH, ascent = measure(face, cfg.Text)
 	return face, ascent, textW, textH, nil
 }
@@ -0,0 +1,89 @@
+package templates
+
+// Category groups notification templates for user preferences. Mandatory categories are
+// transactional and always delivered regardless of what the user has muted.
+type Category struct {
+	ID        string
+	NameAR    string
+	NameEN    string
+	Mandatory bool
+	Templates []string
+}
+
+// CategoryOther holds templates that are not listed in any category
+const CategoryOther = "other"
+
+var categories = []Category{
+	{
+		ID:        "account",
+		NameAR:    "الحساب والأمان",
+		NameEN:    "Account & security",
+		Mandatory: true,
+		Templates: []string{"email_verification", "password_reset", "verification_approved", "welcome", "notification_digest", "new_device_login", "account_locked"},
+	},
+	{
+		ID:        "orders",
+		NameAR:    "الطلبات والمدفوعات",
+		NameEN:    "Orders & payments",
+		Mandatory: true,
+		Templates: []string{"order_confirmation", "order_paid", "refund_issued", "auction_ended_winner", "auction_won", "auction_winner_unpaid", "second_chance_accepted"},
+	},
+	{
+		ID:        "bidding",
+		NameAR:    "المزايدات",
+		NameEN:    "Bidding",
+		Templates: []string{"bid_placed", "bid_outbid", "bid_removed", "auction_price_changed", "auction_ended_lost", "auction_ended_reserve_not_met", "auction_cancelled", "second_chance_offer"},
+	},
+	{
+		ID:        "watchlist",
+		NameAR:    "قائمة المتابعة",
+		NameEN:    "Watchlist",
+		Templates: []string{"auction_watch_starting_soon", "auction_watch_ending_soon", "auction_watch_price_changed", "auction_watch_ended"},
+	},
+	{
+		ID:        "admin",
+		NameAR:    "تنبيهات الإدارة",
+		NameEN:    "Admin alerts",
+		Templates: []string{"new_order_admin", "order_paid_admin", "verification_requested_admin", "user_registered", "payment_reconciliation_report", "auction_audit_event"},
+	},
+	{
+		ID:     CategoryOther,
+		NameAR: "أخرى",
+		NameEN: "Other",
+	},
+}
+
+var categoryByTemplate = func() map[string]*Category {
+	m := map[string]*Category{}
+	for i := range categories {
+		for _, id := range categories[i].Templates {
+			m[id] = &categories[i]
+		}
+	}
+	return m
+}()
+
+// Categories returns all preference categories in display order
+func Categories() []Category {
+	out := make([]Category, len(categories))
+	copy(out, categories)
+	return out
+}
+
+// CategoryOf returns the category a template belongs to (CategoryOther when unlisted)
+func CategoryOf(templateID string) Category {
+	if c, ok := categoryByTemplate[templateID]; ok {
+		return *c
+	}
+	return categories[len(categories)-1]
+}
+
+// GetCategory looks up a category by ID
+func GetCategory(id string) (Category, bool) {
+	for _, c := range categories {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
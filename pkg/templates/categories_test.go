@@ -0,0 +1,57 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCategoryOf(t *testing.T) {
+	cases := map[string]struct {
+		category  string
+		mandatory bool
+	}{
+		"password_reset":      {"account", true},
+		"email_verification":  {"account", true},
+		"new_device_login":    {"account", true},
+		"account_locked":      {"account", true},
+		"refund_issued":       {"orders", true},
+		"bid_outbid":          {"bidding", false},
+		"auction_watch_ended": {"watchlist", false},
+		"something_new":       {CategoryOther, false},
+	}
+	for tpl, want := range cases {
+		got := CategoryOf(tpl)
+		if got.ID != want.category || got.Mandatory != want.mandatory {
+			t.Errorf("CategoryOf(%q) = %s (mandatory=%v), want %s (mandatory=%v)", tpl, got.ID, got.Mandatory, want.category, want.mandatory)
+		}
+	}
+}
+
+func TestCategoriesAreDisjoint(t *testing.T) {
+	seen := map[string]string{}
+	for _, c := range Categories() {
+		for _, tpl := range c.Templates {
+			if prev, ok := seen[tpl]; ok {
+				t.Errorf("template %q is in both %s and %s", tpl, prev, c.ID)
+			}
+			seen[tpl] = c.ID
+		}
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	subject, _, text, err := RenderTemplate("notification_digest", "ar", TemplateData{
+		"name":  "سالم",
+		"count": 2,
+		"items": []interface{}{map[string]interface{}{"subject": "أ"}, map[string]interface{}{"subject": "ب"}},
+	})
+	if err != nil {
+		t.Fatalf("RenderTemplate: %v", err)
+	}
+	if subject != "ملخص إشعاراتك اليومي (2)" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	if want := "\n- أ\n- ب"; !strings.Contains(text, want) {
+		t.Errorf("text body %q does not list items", text)
+	}
+}
@@ -0,0 +1,121 @@
+package templates
+
+import (
+	"bytes"
+	"text/template"
+
+	"encore.app/pkg/errs"
+)
+
+// PushTemplate is the short browser notification sent for a template on the push channel.
+// Only templates listed here are pushed; URL is the site path opened when it is clicked.
+type PushTemplate struct {
+	Title map[string]string
+	Body  map[string]string
+	URL   string
+	// Urgent asks push services to wake the device right away (bids, auction endings)
+	Urgent bool
+}
+
+var pushTemplates = map[string]*PushTemplate{
+	"bid_outbid": {
+		Title:  map[string]string{"ar": "تم تجاوز عرضك", "en": "You've been outbid"},
+		Body:   map[string]string{"ar": "{{.product_title}}: السعر الحالي {{.new_price}} ر.س", "en": "{{.product_title}}: current price SAR {{.new_price}}"},
+		URL:    "/auctions/{{.auction_id}}",
+		Urgent: true,
+	},
+	"auction_ended_winner": {
+		Title:  map[string]string{"ar": "مبروك! فزت بالمزاد", "en": "Congratulations, you won!"},
+		Body:   map[string]string{"ar": "{{.product_title}} - أكمل الدفع لتأكيد طلبك", "en": "{{.product_title}} - complete payment to confirm your order"},
+		URL:    "/auctions/{{.auction_id}}",
+		Urgent: true,
+	},
+	"auction_ended_reserve_not_met": {
+		Title: map[string]string{"ar": "انتهى المزاد دون بلوغ الحد الأدنى", "en": "Auction ended, reserve not met"},
+		Body:  map[string]string{"ar": "{{.product_title}}: أعلى عرض {{.highest_bid}} ر.س", "en": "{{.product_title}}: highest bid SAR {{.highest_bid}}"},
+		URL:   "/auctions/{{.auction_id}}",
+	},
+	"auction_cancelled": {
+		Title: map[string]string{"ar": "تم إلغاء المزاد", "en": "Auction cancelled"},
+		Body:  map[string]string{"ar": "{{.product_title}}", "en": "{{.product_title}}"},
+		URL:   "/auctions/{{.auction_id}}",
+	},
+	"auction_winner_unpaid": {
+		Title: map[string]string{"ar": "انتهت مهلة الدفع", "en": "Payment deadline passed"},
+		Body:  map[string]string{"ar": "{{.product_title}} أعيد للبيع", "en": "{{.product_title}} has been relisted"},
+		URL:   "/auctions/{{.auction_id}}",
+	},
+	"second_chance_offer": {
+		Title:  map[string]string{"ar": "فرصة ثانية للفوز", "en": "Second chance offer"},
+		Body:   map[string]string{"ar": "{{.product_title}} بسعر {{.amount}} ر.س حتى {{.expires_at}}", "en": "{{.product_title}} for SAR {{.amount}} until {{.expires_at}}"},
+		URL:    "/me/second-chance-offers",
+		Urgent: true,
+	},
+	"second_chance_accepted": {
+		Title: map[string]string{"ar": "تم قبول عرض الفرصة الثانية", "en": "Second chance offer accepted"},
+		Body:  map[string]string{"ar": "أكمل دفع {{.amount}} ر.س لتأكيد طلبك", "en": "Pay SAR {{.amount}} to confirm your order"},
+		URL:   "/checkout/{{.order_id}}",
+	},
+	"auction_watch_starting_soon": {
+		Title: map[string]string{"ar": "مزاد تتابعه يبدأ قريباً", "en": "A watched auction starts soon"},
+		Body:  map[string]string{"ar": "{{.product_title}} خلال {{.minutes}} دقيقة", "en": "{{.product_title}} in {{.minutes}} minutes"},
+		URL:   "/auctions/{{.auction_id}}",
+	},
+	"auction_watch_ending_soon": {
+		Title:  map[string]string{"ar": "مزاد تتابعه ينتهي قريباً", "en": "A watched auction ends soon"},
+		Body:   map[string]string{"ar": "{{.product_title}} خلال {{.minutes}} دقيقة", "en": "{{.product_title}} in {{.minutes}} minutes"},
+		URL:    "/auctions/{{.auction_id}}",
+		Urgent: true,
+	},
+}
+
+// HasPush reports whether templateID has a push notification
+func HasPush(templateID string) bool {
+	_, ok := pushTemplates[templateID]
+	return ok
+}
+
+// RenderPush renders the push notification of templateID (Arabic when lang is missing)
+func RenderPush(templateID, lang string, data TemplateData) (title, body, url string, err error) {
+	tmpl, ok := pushTemplates[templateID]
+	if !ok {
+		return "", "", "", &errs.Error{Code: errs.NotFound, Message: "لا يوجد إشعار فوري لهذا القالب"}
+	}
+	if title, err = renderText(pick(tmpl.Title, lang), data); err != nil {
+		return "", "", "", err
+	}
+	if body, err = renderText(pick(tmpl.Body, lang), data); err != nil {
+		return "", "", "", err
+	}
+	if url, err = renderText(tmpl.URL, data); err != nil {
+		return "", "", "", err
+	}
+	return title, body, url, nil
+}
+
+// IsUrgentPush reports whether templateID's push should be delivered with high urgency
+func IsUrgentPush(templateID string) bool {
+	tmpl, ok := pushTemplates[templateID]
+	return ok && tmpl.Urgent
+}
+
+// pick returns the text for lang, falling back to Arabic
+func pick(texts map[string]string, lang string) string {
+	if s, ok := texts[lang]; ok {
+		return s
+	}
+	return texts["ar"]
+}
+
+// renderText executes a plain text template (push and SMS bodies)
+func renderText(text string, data TemplateData) (string, error) {
+	t, err := template.New("push").Parse(text)
+	if err != nil {
+		return "", &errs.Error{Code: errs.Internal, Message: "فشل تحليل قالب الإشعار"}
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", &errs.Error{Code: errs.Internal, Message: "فشل تنفيذ قالب الإشعار"}
+	}
+	return buf.String(), nil
+}
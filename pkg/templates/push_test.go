@@ -0,0 +1,31 @@
+package templates
+
+import "testing"
+
+func TestRenderPush(t *testing.T) {
+	data := TemplateData{"product_title": "حمامة زاجل", "new_price": "1500.00", "auction_id": "42"}
+	title, body, url, err := RenderPush("bid_outbid", "", data)
+	if err != nil {
+		t.Fatalf("RenderPush: %v", err)
+	}
+	if title != "تم تجاوز عرضك" || body != "حمامة زاجل: السعر الحالي 1500.00 ر.س" || url != "/auctions/42" {
+		t.Errorf("unexpected push %q / %q / %q", title, body, url)
+	}
+	if _, body, _, _ := RenderPush("bid_outbid", "en", data); body != "حمامة زاجل: current price SAR 1500.00" {
+		t.Errorf("unexpected english body %q", body)
+	}
+	if _, _, _, err := RenderPush("password_reset", "ar", data); err == nil || HasPush("password_reset") {
+		t.Error("templates without a push message must not be pushed")
+	}
+}
+
+func TestPushTemplatesHaveArabic(t *testing.T) {
+	for id, tmpl := range pushTemplates {
+		if tmpl.Title["ar"] == "" || tmpl.Body["ar"] == "" || tmpl.URL == "" {
+			t.Errorf("%s: missing Arabic title, body or URL", id)
+		}
+		if CategoryOf(id).ID == CategoryOther {
+			t.Errorf("%s: pushed templates must belong to a preference category", id)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package templates
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"encore.app/pkg/errs"
+)
+
+// MaxSMSLength caps an SMS body in characters: Arabic text is sent as UCS-2, so this is three
+// 67-character concatenated segments
+const MaxSMSLength = 201
+
+// smsTemplates are the short text variants sent on the SMS channel. Only templates listed
+// here are sent by SMS; keep them to what a bidder must act on.
+var smsTemplates = map[string]map[string]string{
+	"auction_ended_winner": {
+		"ar": "لوفت الدغيري: مبروك! فزت بمزاد {{.product_title}} بمبلغ {{.winning_amount}} ر.س. يرجى الدفع خلال 48 ساعة{{if .payment_url}}: {{.payment_url}}{{end}}",
+		"en": "Loft Dughairi: You won {{.product_title}} for SAR {{.winning_amount}}. Please pay within 48 hours{{if .payment_url}}: {{.payment_url}}{{end}}",
+	},
+	"auction_winner_unpaid": {
+		"ar": "لوفت الدغيري: انتهت مهلة دفع مزاد {{.product_title}} وأعيد المنتج للبيع.",
+		"en": "Loft Dughairi: The payment deadline for {{.product_title}} passed and it has been relisted.",
+	},
+	"second_chance_offer": {
+		"ar": "لوفت الدغيري: فرصة ثانية لشراء {{.product_title}} بمبلغ {{.amount}} ر.س حتى {{.expires_at}}. {{.OfferURL}}",
+		"en": "Loft Dughairi: Second chance to buy {{.product_title}} for SAR {{.amount}} until {{.expires_at}}. {{.OfferURL}}",
+	},
+	"second_chance_accepted": {
+		"ar": "لوفت الدغيري: تم قبول عرضك. يرجى دفع {{.amount}} ر.س لتأكيد الطلب: {{.payment_url}}",
+		"en": "Loft Dughairi: Offer accepted. Please pay SAR {{.amount}} to confirm your order: {{.payment_url}}",
+	},
+	"bid_outbid": {
+		"ar": "لوفت الدغيري: تم تجاوز عرضك على {{.product_title}}. السعر الحالي {{.new_price}} ر.س",
+		"en": "Loft Dughairi: You've been outbid on {{.product_title}}. Current price SAR {{.new_price}}",
+	},
+	"auction_watch_ending_soon": {
+		"ar": "لوفت الدغيري: مزاد {{.product_title}} ينتهي خلال {{.minutes}} دقيقة",
+		"en": "Loft Dughairi: {{.product_title}} ends in {{.minutes}} minutes",
+	},
+}
+
+// smsCoalesce limits chatty templates to one SMS per user and value of a payload field
+// within a window: while one is still queued, or was sent less than Window ago, newer events
+// are dropped (a bidding war would otherwise text the user on every bid)
+var smsCoalesce = map[string]struct {
+	Field  string
+	Window time.Duration
+}{
+	"bid_outbid": {Field: "auction_id", Window: 10 * time.Minute},
+}
+
+// SMSCoalesce returns the payload field and window that templateID's SMS are coalesced by,
+// or a zero window when every event is sent
+func SMSCoalesce(templateID string) (field string, window time.Duration) {
+	c, ok := smsCoalesce[templateID]
+	if !ok {
+		return "", 0
+	}
+	return c.Field, c.Window
+}
+
+// HasSMS reports whether templateID has a short SMS variant
+func HasSMS(templateID string) bool {
+	_, ok := smsTemplates[templateID]
+	return ok
+}
+
+// RenderSMS renders the SMS text of templateID (Arabic when lang is missing) on one line. Texts
+// over MaxSMSLength characters are brought under it by shortening the product title; the rest,
+// links included, is never cut.
+func RenderSMS(templateID, lang string, data TemplateData) (string, error) {
+	texts, ok := smsTemplates[templateID]
+	if !ok {
+		return "", &errs.Error{Code: errs.NotFound, Message: "لا توجد رسالة نصية لهذا القالب"}
+	}
+	text, err := renderSMSLine(pick(texts, lang), data)
+	if err != nil {
+		return "", err
+	}
+	over := utf8.RuneCountInString(text) - MaxSMSLength
+	title, ok := data["product_title"].(string)
+	if over <= 0 || !ok {
+		return text, nil
+	}
+	short := make(TemplateData, len(data))
+	for k, v := range data {
+		short[k] = v
+	}
+	runes := []rune(strings.Join(strings.Fields(title), " "))
+	if keep := len(runes) - over - 1; keep > 0 {
+		short["product_title"] = strings.TrimSpace(string(runes[:keep])) + "…"
+	} else {
+		short["product_title"] = "…"
+	}
+	return renderSMSLine(pick(texts, lang), short)
+}
+
+// renderSMSLine renders an SMS text with its whitespace collapsed to single spaces
+func renderSMSLine(text string, data TemplateData) (string, error) {
+	out, err := renderText(text, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(out), " "), nil
+}
@@ -0,0 +1,69 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenderSMS(t *testing.T) {
+	data := TemplateData{"product_title": "حمامة زاجل", "winning_amount": "2500.00", "payment_url": "https://dughairiloft.com/checkout/7"}
+	got, err := RenderSMS("auction_ended_winner", "", data)
+	if err != nil {
+		t.Fatalf("RenderSMS: %v", err)
+	}
+	want := "لوفت الدغيري: مبروك! فزت بمزاد حمامة زاجل بمبلغ 2500.00 ر.س. يرجى الدفع خلال 48 ساعة: https://dughairiloft.com/checkout/7"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The payment link is optional
+	delete(data, "payment_url")
+	if got, _ := RenderSMS("auction_ended_winner", "ar", data); !strings.HasSuffix(got, "خلال 48 ساعة") {
+		t.Errorf("without payment link: %q", got)
+	}
+
+	if _, err := RenderSMS("welcome", "ar", data); err == nil || HasSMS("welcome") {
+		t.Error("templates without an SMS variant must not be sent by SMS")
+	}
+}
+
+func TestRenderSMSTruncates(t *testing.T) {
+	got, err := RenderSMS("bid_outbid", "ar", TemplateData{"product_title": strings.Repeat("حمام ", 100), "new_price": "10"})
+	if err != nil {
+		t.Fatalf("RenderSMS: %v", err)
+	}
+	if n := utf8.RuneCountInString(got); n > MaxSMSLength || !strings.Contains(got, "…") || !strings.HasSuffix(got, "السعر الحالي 10 ر.س") {
+		t.Errorf("expected at most %d characters with a shortened title, got %d: %q", MaxSMSLength, n, got)
+	}
+
+	// Links stay whole; only the title gives way
+	url := "https://dughairiloft.com/checkout/12345"
+	got, err = RenderSMS("auction_ended_winner", "ar", TemplateData{"product_title": strings.Repeat("حمام ", 100), "winning_amount": "2500.00", "payment_url": url})
+	if err != nil {
+		t.Fatalf("RenderSMS: %v", err)
+	}
+	if n := utf8.RuneCountInString(got); n > MaxSMSLength || !strings.HasSuffix(got, ": "+url) {
+		t.Errorf("expected at most %d characters ending with the payment link, got %d: %q", MaxSMSLength, n, got)
+	}
+}
+
+func TestSMSTemplatesHaveArabic(t *testing.T) {
+	for id, texts := range smsTemplates {
+		if texts["ar"] == "" {
+			t.Errorf("%s: missing Arabic text", id)
+		}
+		if CategoryOf(id).ID == CategoryOther {
+			t.Errorf("%s: SMS templates must belong to a preference category", id)
+		}
+	}
+}
+
+func TestSMSCoalesce(t *testing.T) {
+	if field, window := SMSCoalesce("bid_outbid"); field != "auction_id" || window <= 0 {
+		t.Errorf("bid_outbid: got %q %v, want coalescing per auction", field, window)
+	}
+	if _, window := SMSCoalesce("auction_ended_winner"); window != 0 {
+		t.Errorf("auction_ended_winner must not be coalesced, got %v", window)
+	}
+}
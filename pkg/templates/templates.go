@@ -2014,6 +2014,435 @@ Auction ID: #{{.auction_id}}
 We look forward to seeing you in future auctions!`,
 		},
 	},
+	"auction_watch_starting_soon": {
+		ID:          "auction_watch_starting_soon",
+		Description: "تذكير ببدء مزاد في قائمة المتابعة",
+		Subject: map[string]string{
+			"ar": "المزاد يبدأ قريباً - {{.product_title}}",
+			"en": "Auction Starting Soon - {{.product_title}}",
+		},
+		HTMLBody: map[string]string{
+			"ar": `<!DOCTYPE html>
+<html dir="rtl" lang="ar">
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: 'Tajawal', sans-serif; line-height: 1.6; direction: rtl; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6B7B8C; color: white; padding: 20px; text-align: center; }
+        .content { background: white; padding: 30px; border: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>المزاد يبدأ قريباً</h1>
+        </div>
+        <div class="content">
+            <p>عزيزي {{.name}},</p>
+            <p>المزاد الذي تتابعه سيبدأ خلال {{.minutes}} دقيقة: {{.product_title}}</p>
+            <p><strong>رقم المزاد:</strong> #{{.auction_id}}</p>
+            <p><a href="{{.AuctionURL}}">عرض المزاد</a></p>
+        </div>
+    </div>
+</body>
+</html>`,
+			"en": `An auction on your watchlist starts in {{.minutes}} minutes.`,
+		},
+		TextBody: map[string]string{
+			"ar": `عزيزي {{.name}},
+
+المزاد الذي تتابعه سيبدأ خلال {{.minutes}} دقيقة: {{.product_title}}
+
+رقم المزاد: #{{.auction_id}}
+{{.AuctionURL}}`,
+			"en": `Dear {{.name}},
+
+An auction on your watchlist starts in {{.minutes}} minutes: {{.product_title}}
+
+Auction ID: #{{.auction_id}}
+{{.AuctionURL}}`,
+		},
+	},
+	"auction_watch_ending_soon": {
+		ID:          "auction_watch_ending_soon",
+		Description: "تذكير بقرب انتهاء مزاد في قائمة المتابعة",
+		Subject: map[string]string{
+			"ar": "المزاد ينتهي خلال {{.minutes}} دقيقة - {{.product_title}}",
+			"en": "Auction Ending in {{.minutes}} Minutes - {{.product_title}}",
+		},
+		HTMLBody: map[string]string{
+			"ar": `<!DOCTYPE html>
+<html dir="rtl" lang="ar">
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: 'Tajawal', sans-serif; line-height: 1.6; direction: rtl; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6B7B8C; color: white; padding: 20px; text-align: center; }
+        .content { background: white; padding: 30px; border: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>المزاد ينتهي قريباً</h1>
+        </div>
+        <div class="content">
+            <p>عزيزي {{.name}},</p>
+            <p>المزاد الذي تتابعه سينتهي خلال {{.minutes}} دقيقة: {{.product_title}}</p>
+            <p><strong>رقم المزاد:</strong> #{{.auction_id}}</p>
+            <p><a href="{{.AuctionURL}}">شارك في المزايدة</a></p>
+        </div>
+    </div>
+</body>
+</html>`,
+			"en": `An auction on your watchlist ends in {{.minutes}} minutes.`,
+		},
+		TextBody: map[string]string{
+			"ar": `عزيزي {{.name}},
+
+المزاد الذي تتابعه سينتهي خلال {{.minutes}} دقيقة: {{.product_title}}
+
+رقم المزاد: #{{.auction_id}}
+{{.AuctionURL}}`,
+			"en": `Dear {{.name}},
+
+An auction on your watchlist ends in {{.minutes}} minutes: {{.product_title}}
+
+Auction ID: #{{.auction_id}}
+{{.AuctionURL}}`,
+		},
+	},
+	"second_chance_offer": {
+		ID:          "second_chance_offer",
+		Description: "عرض فرصة ثانية لشراء منتج مزاد لم يكتمل",
+		Subject: map[string]string{
+			"ar": "فرصة ثانية لشراء {{.product_title}}",
+			"en": "Second Chance Offer - {{.product_title}}",
+		},
+		HTMLBody: map[string]string{
+			"ar": `<!DOCTYPE html>
+<html dir="rtl" lang="ar">
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: 'Tajawal', sans-serif; line-height: 1.6; direction: rtl; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6B7B8C; color: white; padding: 20px; text-align: center; }
+        .content { background: white; padding: 30px; border: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>فرصة ثانية</h1>
+        </div>
+        <div class="content">
+            <p>عزيزي {{.name}},</p>
+            <p>لم يكتمل المزاد على {{.product_title}}، ويمكنك شراؤه الآن بمبلغ آخر مزايدة لك: <strong>{{.amount}} ر.س</strong></p>
+            <p><strong>رقم المزاد:</strong> #{{.auction_id}}</p>
+            <p><strong>آخر موعد للقبول:</strong> {{.expires_at}} (UTC)</p>
+            <p><a href="{{.OfferURL}}">عرض الفرصة</a></p>
+        </div>
+    </div>
+</body>
+</html>`,
+			"en": `You have a second chance to buy {{.product_title}} for {{.amount}} SAR until {{.expires_at}} (UTC).`,
+		},
+		TextBody: map[string]string{
+			"ar": `عزيزي {{.name}},
+
+لم يكتمل المزاد على {{.product_title}}، ويمكنك شراؤه الآن بمبلغ آخر مزايدة لك: {{.amount}} ر.س
+
+رقم المزاد: #{{.auction_id}}
+آخر موعد للقبول: {{.expires_at}} (UTC)
+{{.OfferURL}}`,
+			"en": `Dear {{.name}},
+
+The auction for {{.product_title}} did not complete. You can buy it now at your last bid: {{.amount}} SAR
+
+Auction ID: #{{.auction_id}}
+Accept by: {{.expires_at}} (UTC)
+{{.OfferURL}}`,
+		},
+	},
+	"auction_watch_ended": {
+		ID:          "auction_watch_ended",
+		Description: "نتيجة مزاد في قائمة المتابعة",
+		Subject: map[string]string{
+			"ar": "انتهى المزاد - {{.product_title}}",
+			"en": "Auction Ended - {{.product_title}}",
+		},
+		HTMLBody: map[string]string{
+			"ar": `<!DOCTYPE html>
+<html dir="rtl" lang="ar">
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: 'Tajawal', sans-serif; line-height: 1.6; direction: rtl; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6B7B8C; color: white; padding: 20px; text-align: center; }
+        .content { background: white; padding: 30px; border: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>انتهى المزاد</h1>
+        </div>
+        <div class="content">
+            <p>عزيزي {{.name}},</p>
+            <p>انتهى المزاد الذي تتابعه: {{.product_title}}</p>
+            <p><strong>السعر النهائي:</strong> {{.final_price}} ريال</p>
+            <p><strong>رقم المزاد:</strong> #{{.auction_id}}</p>
+        </div>
+    </div>
+</body>
+</html>`,
+			"en": `An auction on your watchlist has ended. Final price: {{.final_price}} SAR.`,
+		},
+		TextBody: map[string]string{
+			"ar": `عزيزي {{.name}},
+
+انتهى المزاد الذي تتابعه: {{.product_title}}
+
+- السعر النهائي: {{.final_price}} ريال
+- رقم المزاد: #{{.auction_id}}`,
+			"en": `Dear {{.name}},
+
+An auction on your watchlist has ended: {{.product_title}}
+
+- Final Price: {{.final_price}} SAR
+- Auction ID: #{{.auction_id}}`,
+		},
+	},
+	"refund_issued": {
+		ID:          "refund_issued",
+		Description: "إشعار دائن عند استرداد مبلغ من فاتورة",
+		Subject: map[string]string{
+			"ar": "إشعار دائن {{.credit_note_number}} للفاتورة {{.invoice_number}}",
+			"en": "Credit Note {{.credit_note_number}} for Invoice {{.invoice_number}}",
+		},
+		HTMLBody: map[string]string{
+			"ar": `<!DOCTYPE html>
+<html dir="rtl" lang="ar">
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: 'Tajawal', sans-serif; line-height: 1.6; direction: rtl; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #4A9B8E; color: white; padding: 20px; text-align: center; }
+        .content { background: white; padding: 30px; border: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>تم استرداد مبلغ</h1>
+        </div>
+        <div class="content">
+            <p>عزيزي {{.name}},</p>
+            <p>تم استرداد <strong>{{.amount}} ر.س</strong> من الفاتورة {{.invoice_number}}.</p>
+            <p><strong>رقم إشعار الدائن:</strong> {{.credit_note_number}}</p>
+            {{if .reason}}<p><strong>السبب:</strong> {{.reason}}</p>{{end}}
+            <p>قد يستغرق ظهور المبلغ في حسابك عدة أيام عمل حسب البنك المصدر للبطاقة.</p>
+        </div>
+    </div>
+</body>
+</html>`,
+			"en": `{{.amount}} SAR was refunded from invoice {{.invoice_number}}. Credit note: {{.credit_note_number}}.`,
+		},
+		TextBody: map[string]string{
+			"ar": `عزيزي {{.name}},
+
+تم استرداد {{.amount}} ر.س من الفاتورة {{.invoice_number}}.
+
+- رقم إشعار الدائن: {{.credit_note_number}}{{if .reason}}
+- السبب: {{.reason}}{{end}}
+
+قد يستغرق ظهور المبلغ في حسابك عدة أيام عمل حسب البنك المصدر للبطاقة.`,
+			"en": `Dear {{.name}},
+
+{{.amount}} SAR was refunded from invoice {{.invoice_number}}.
+
+- Credit Note: {{.credit_note_number}}{{if .reason}}
+- Reason: {{.reason}}{{end}}
+
+It may take a few business days for the amount to appear on your statement.`,
+		},
+	},
+	"notification_digest": {
+		ID:          "notification_digest",
+		Description: "ملخص يومي للإشعارات المؤجلة حسب تفضيلات المستخدم",
+		Subject: map[string]string{
+			"ar": "ملخص إشعاراتك اليومي ({{.count}})",
+			"en": "Your daily notification digest ({{.count}})",
+		},
+		HTMLBody: map[string]string{
+			"ar": `<!DOCTYPE html>
+<html dir="rtl" lang="ar">
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: 'Tajawal', sans-serif; line-height: 1.6; direction: rtl; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6B7B8C; color: white; padding: 20px; text-align: center; }
+        .content { background: white; padding: 30px; border: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>ملخص إشعاراتك</h1>
+        </div>
+        <div class="content">
+            <p>عزيزي {{.name}},</p>
+            <p>إليك ما فاتك خلال آخر 24 ساعة:</p>
+            <ul>{{range .items}}
+                <li>{{.subject}}</li>{{end}}
+            </ul>
+            <p>يمكنك تعديل تفضيلات الإشعارات من صفحة حسابك.</p>
+        </div>
+    </div>
+</body>
+</html>`,
+			"en": `Your daily digest: {{.count}} notifications.`,
+		},
+		TextBody: map[string]string{
+			"ar": `عزيزي {{.name}},
+
+إليك ما فاتك خلال آخر 24 ساعة:
+{{range .items}}
+- {{.subject}}{{end}}
+
+يمكنك تعديل تفضيلات الإشعارات من صفحة حسابك.`,
+			"en": `Dear {{.name}},
+
+Here is what you missed in the last 24 hours:
+{{range .items}}
+- {{.subject}}{{end}}
+
+You can change your notification preferences from your account page.`,
+		},
+	},
+	"new_device_login": {
+		ID:          "new_device_login",
+		Description: "تنبيه أمني عند تسجيل الدخول من جهاز جديد",
+		Subject: map[string]string{
+			"ar": "تسجيل دخول جديد إلى حسابك - لوفت الدغيري",
+			"en": "New sign-in to your account - Al-Dughairi Loft",
+		},
+		HTMLBody: map[string]string{
+			"ar": `<!DOCTYPE html>
+<html dir="rtl" lang="ar">
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: 'Tajawal', sans-serif; line-height: 1.6; direction: rtl; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6B7B8C; color: white; padding: 20px; text-align: center; }
+        .content { background: white; padding: 30px; border: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>تسجيل دخول من جهاز جديد</h1>
+        </div>
+        <div class="content">
+            <p>عزيزي {{.name}},</p>
+            <p>تم تسجيل الدخول إلى حسابك من جهاز لم يُستخدم من قبل:</p>
+            <p><strong>الجهاز:</strong> {{.device}}<br>
+            <strong>عنوان IP:</strong> {{.ip_address}}<br>
+            <strong>الوقت:</strong> {{.login_time}}</p>
+            <p>إذا لم تكن أنت، قم بتسجيل الخروج من هذا الجهاز من صفحة الأجهزة في حسابك وغيّر كلمة المرور فوراً.</p>
+        </div>
+    </div>
+</body>
+</html>`,
+			"en": `New sign-in to your account from {{.device}} ({{.ip_address}}) at {{.login_time}}.`,
+		},
+		TextBody: map[string]string{
+			"ar": `عزيزي {{.name}},
+
+تم تسجيل الدخول إلى حسابك من جهاز لم يُستخدم من قبل:
+
+- الجهاز: {{.device}}
+- عنوان IP: {{.ip_address}}
+- الوقت: {{.login_time}}
+
+إذا لم تكن أنت، قم بتسجيل الخروج من هذا الجهاز من صفحة الأجهزة في حسابك وغيّر كلمة المرور فوراً.`,
+			"en": `Dear {{.name}},
+
+Your account was signed in from a new device:
+
+- Device: {{.device}}
+- IP address: {{.ip_address}}
+- Time: {{.login_time}}
+
+If this wasn't you, sign that device out from the devices page of your account and change your password right away.`,
+		},
+	},
+	"account_locked": {
+		ID:          "account_locked",
+		Description: "تنبيه أمني عند قفل الحساب مؤقتاً بعد محاولات دخول فاشلة",
+		Subject: map[string]string{
+			"ar": "تم قفل حسابك مؤقتاً - لوفت الدغيري",
+			"en": "Your account was temporarily locked - Al-Dughairi Loft",
+		},
+		HTMLBody: map[string]string{
+			"ar": `<!DOCTYPE html>
+<html dir="rtl" lang="ar">
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: 'Tajawal', sans-serif; line-height: 1.6; direction: rtl; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6B7B8C; color: white; padding: 20px; text-align: center; }
+        .content { background: white; padding: 30px; border: 1px solid #ddd; }
+        .button { display: inline-block; background: #4A6B82; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>تم قفل حسابك مؤقتاً</h1>
+        </div>
+        <div class="content">
+            <p>عزيزي {{.name}},</p>
+            <p>رصدنا {{.failed_attempts}} محاولات دخول فاشلة إلى حسابك، آخرها من عنوان IP: {{.ip_address}}.</p>
+            <p>لحماية حسابك تم إيقاف تسجيل الدخول لمدة {{.lockout_minutes}} دقيقة.</p>
+            <p>إذا كنت أنت، يمكنك فتح الحساب الآن:</p>
+            <p style="text-align: center;"><a href="{{.unlock_url}}" class="button">فتح الحساب</a></p>
+            <p>إذا لم تكن أنت، ننصحك بتغيير كلمة المرور فور فتح الحساب.</p>
+        </div>
+    </div>
+</body>
+</html>`,
+			"en": `Your account was locked for {{.lockout_minutes}} minutes after {{.failed_attempts}} failed sign-in attempts. Unlock it: {{.unlock_url}}`,
+		},
+		TextBody: map[string]string{
+			"ar": `عزيزي {{.name}},
+
+رصدنا {{.failed_attempts}} محاولات دخول فاشلة إلى حسابك، آخرها من عنوان IP: {{.ip_address}}.
+لحماية حسابك تم إيقاف تسجيل الدخول لمدة {{.lockout_minutes}} دقيقة.
+
+إذا كنت أنت، يمكنك فتح الحساب الآن:
+{{.unlock_url}}
+
+إذا لم تكن أنت، ننصحك بتغيير كلمة المرور فور فتح الحساب.`,
+			"en": `Dear {{.name}},
+
+We detected {{.failed_attempts}} failed sign-in attempts on your account, the last one from IP address {{.ip_address}}.
+To protect your account, sign-in is paused for {{.lockout_minutes}} minutes.
+
+If this was you, unlock your account now:
+{{.unlock_url}}
+
+If it wasn't you, change your password as soon as the account is unlocked.`,
+		},
+	},
 }
 
 // GetTemplate يجلب قالب البريد الإلكتروني
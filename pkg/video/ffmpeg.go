@@ -0,0 +1,176 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local is the transcoder running ffmpeg/ffprobe on this machine. Without them it still
+// probes MP4/MOV files in pure Go, and reports ErrUnavailable for posters and transcodes.
+type Local struct {
+	FFmpeg  string // path to ffmpeg ("" when not installed)
+	FFprobe string // path to ffprobe ("" when not installed)
+}
+
+// NewLocal looks ffmpeg and ffprobe up on PATH
+func NewLocal() *Local {
+	l := &Local{}
+	if p, err := exec.LookPath("ffmpeg"); err == nil {
+		l.FFmpeg = p
+	}
+	if p, err := exec.LookPath("ffprobe"); err == nil {
+		l.FFprobe = p
+	}
+	return l
+}
+
+// CanTranscode reports whether posters and transcodes are available
+func (l *Local) CanTranscode() bool {
+	return l.FFmpeg != ""
+}
+
+func (l *Local) Probe(ctx context.Context, path string) (*Info, error) {
+	if l.FFprobe == "" {
+		return probeFile(path)
+	}
+	out, err := exec.CommandContext(ctx, l.FFprobe,
+		"-v", "error", "-print_format", "json", "-show_format", "-show_streams", path,
+	).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: ffprobe: %v", ErrUnsupported, err)
+	}
+	return parseFFprobe(out)
+}
+
+// probeFile sniffs the container and probes MP4/MOV files in pure Go
+func probeFile(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 16)
+	n, _ := f.ReadAt(head, 0)
+	switch SniffContainer(head[:n]) {
+	case ContainerMP4, ContainerMOV:
+		return ProbeMP4(f, st.Size())
+	case ContainerWebM:
+		return nil, fmt.Errorf("%w: WebM needs ffprobe to be installed", ErrUnavailable)
+	}
+	return nil, fmt.Errorf("%w: unknown container", ErrUnsupported)
+}
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+	} `json:"format"`
+}
+
+func parseFFprobe(out []byte) (*Info, error) {
+	var p ffprobeOutput
+	if err := json.Unmarshal(out, &p); err != nil {
+		return nil, fmt.Errorf("%w: ffprobe output: %v", ErrUnsupported, err)
+	}
+	info := &Info{}
+	switch name := p.Format.FormatName; {
+	case strings.Contains(name, "webm") || strings.Contains(name, "matroska"):
+		info.Container = ContainerWebM
+	case strings.Contains(name, "mp4") || strings.Contains(name, "mov"):
+		info.Container = ContainerMP4
+	default:
+		info.Container = name
+	}
+	if secs, err := strconv.ParseFloat(p.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(secs * float64(time.Second))
+	}
+	for _, s := range p.Streams {
+		switch {
+		case s.CodecType == "video" && info.VideoCodec == "" && s.CodecName != "mjpeg" && s.CodecName != "png":
+			info.VideoCodec = codecName(s.CodecName)
+			info.Width, info.Height = s.Width, s.Height
+		case s.CodecType == "audio" && info.AudioCodec == "":
+			info.AudioCodec = codecName(s.CodecName)
+		}
+	}
+	return info, nil
+}
+
+func (l *Local) Poster(ctx context.Context, path string, at time.Duration) ([]byte, error) {
+	if l.FFmpeg == "" {
+		return nil, ErrUnavailable
+	}
+	dir, err := os.MkdirTemp("", "video-poster-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "poster.jpg")
+	if err := l.run(ctx,
+		"-ss", strconv.FormatFloat(at.Seconds(), 'f', 3, 64), "-i", path,
+		"-frames:v", "1", "-map_metadata", "-1", "-q:v", "2", out,
+	); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(out)
+}
+
+func (l *Local) Transcode(ctx context.Context, in, out string, opts TranscodeOptions) error {
+	if l.FFmpeg == "" {
+		return ErrUnavailable
+	}
+	args := []string{"-i", in, "-map", "0:v:0", "-map", "0:a:0?", "-map_metadata", "-1", "-map_chapters", "-1"}
+	if opts.Remux {
+		args = append(args, "-c", "copy")
+	} else {
+		maxWidth := opts.MaxWidth
+		if maxWidth <= 0 {
+			maxWidth = 1920
+		}
+		args = append(args,
+			"-vf", fmt.Sprintf("scale='min(%d,iw)':-2", maxWidth),
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-profile:v", "high", "-pix_fmt", "yuv420p",
+			"-c:a", "aac", "-b:a", "128k",
+		)
+	}
+	args = append(args, "-movflags", "+faststart", "-f", "mp4", out)
+	return l.run(ctx, args...)
+}
+
+func (l *Local) run(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, l.FFmpeg, append([]string{"-v", "error", "-nostdin", "-y"}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 500 {
+			msg = msg[len(msg)-500:]
+		}
+		return fmt.Errorf("ffmpeg: %w: %s", err, msg)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package video
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ProbeMP4 reads the container, codecs, duration and size of an MP4/MOV file from its boxes,
+// without external tools. The moov box may be at the start (faststart) or at the end.
+func ProbeMP4(r io.ReaderAt, size int64) (*Info, error) {
+	root := box{start: 0, size: size}
+	ftyp, ok, err := child(r, root, "ftyp")
+	if err != nil || !ok {
+		return nil, fmt.Errorf("%w: not an MP4/MOV file", ErrUnsupported)
+	}
+	info := &Info{Container: ContainerMP4}
+	if brand, err := readAt(r, ftyp.start, 4); err == nil && string(brand) == "qt  " {
+		info.Container = ContainerMOV
+	}
+
+	moov, ok, err := child(r, root, "moov")
+	if err != nil || !ok {
+		return nil, fmt.Errorf("%w: missing movie header", ErrUnsupported)
+	}
+	err = walk(r, moov, func(b box) error {
+		switch b.typ {
+		case "mvhd":
+			return parseMvhd(r, b, info)
+		case "trak":
+			return parseTrak(r, b, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	return info, nil
+}
+
+// box is an ISO BMFF box; start/size cover its content (after the header)
+type box struct {
+	typ   string
+	start int64
+	size  int64
+}
+
+var errMalformed = errors.New("malformed box")
+
+// walk calls fn for each child box of parent
+func walk(r io.ReaderAt, parent box, fn func(box) error) error {
+	end := parent.start + parent.size
+	for off := parent.start; off+8 <= end; {
+		hdr, err := readAt(r, off, 8)
+		if err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(hdr))
+		headerLen := int64(8)
+		switch size {
+		case 0: // extends to the end of the parent
+			size = end - off
+		case 1: // 64-bit size follows the type
+			ext, err := readAt(r, off+8, 8)
+			if err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(ext))
+			headerLen = 16
+		}
+		if size < headerLen || off+size > end {
+			return errMalformed
+		}
+		if err := fn(box{typ: string(hdr[4:8]), start: off + headerLen, size: size - headerLen}); err != nil {
+			return err
+		}
+		off += size
+	}
+	return nil
+}
+
+var errFound = errors.New("found")
+
+// child returns the first child of parent with the given type
+func child(r io.ReaderAt, parent box, typ string) (box, bool, error) {
+	var found box
+	err := walk(r, parent, func(b box) error {
+		if b.typ == typ {
+			found = b
+			return errFound
+		}
+		return nil
+	})
+	if errors.Is(err, errFound) {
+		return found, true, nil
+	}
+	return box{}, false, err
+}
+
+func readAt(r io.ReaderAt, off, n int64) ([]byte, error) {
+	buf := make([]byte, n)
+	if read, err := r.ReadAt(buf, off); read < len(buf) {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// parseMvhd reads the movie duration
+func parseMvhd(r io.ReaderAt, b box, info *Info) error {
+	buf, err := readAt(r, b.start, min(b.size, 32))
+	if err != nil || len(buf) < 20 {
+		return errMalformed
+	}
+	var timescale uint32
+	var duration uint64
+	if buf[0] == 1 {
+		if len(buf) < 32 {
+			return errMalformed
+		}
+		timescale = binary.BigEndian.Uint32(buf[20:])
+		duration = binary.BigEndian.Uint64(buf[24:])
+	} else {
+		timescale = binary.BigEndian.Uint32(buf[12:])
+		duration = uint64(binary.BigEndian.Uint32(buf[16:]))
+	}
+	if timescale > 0 {
+		info.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+	}
+	return nil
+}
+
+// parseTrak reads the codec of a track, and the display size of the first video track
+func parseTrak(r io.ReaderAt, trak box, info *Info) error {
+	mdia, ok, err := child(r, trak, "mdia")
+	if err != nil || !ok {
+		return err
+	}
+	hdlr, ok, err := child(r, mdia, "hdlr")
+	if err != nil || !ok {
+		return err
+	}
+	h, err := readAt(r, hdlr.start, 12)
+	if err != nil {
+		return err
+	}
+	handler := string(h[8:12])
+	if handler != "vide" && handler != "soun" {
+		return nil
+	}
+
+	codec := ""
+	if minf, ok, err := child(r, mdia, "minf"); err == nil && ok {
+		if stbl, ok, err := child(r, minf, "stbl"); err == nil && ok {
+			if stsd, ok, err := child(r, stbl, "stsd"); err == nil && ok && stsd.size >= 16 {
+				if entry, err := readAt(r, stsd.start, 16); err == nil {
+					codec = codecName(string(entry[12:16]))
+				}
+			}
+		}
+	}
+
+	switch {
+	case handler == "vide" && info.VideoCodec == "":
+		info.VideoCodec = codec
+		if tkhd, ok, err := child(r, trak, "tkhd"); err == nil && ok {
+			buf, err := readAt(r, tkhd.start, min(tkhd.size, 96))
+			if err == nil && len(buf) > 0 {
+				off := 76
+				if buf[0] == 1 {
+					off = 88
+				}
+				if len(buf) >= off+8 {
+					info.Width = int(binary.BigEndian.Uint32(buf[off:]) >> 16)
+					info.Height = int(binary.BigEndian.Uint32(buf[off+4:]) >> 16)
+				}
+			}
+		}
+	case handler == "soun" && info.AudioCodec == "":
+		info.AudioCodec = codec
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Package video validates uploaded videos and turns them into web-safe media: it reads the
+// container, codecs and duration, extracts a poster frame and transcodes to H.264/AAC MP4.
+//
+// The heavy lifting goes through a Transcoder. Local runs ffmpeg/ffprobe from PATH; when they
+// are missing, MP4/MOV files are still probed in pure Go (ProbeMP4) and stored as uploaded.
+package video
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Containers recognised by SniffContainer / Probe
+const (
+	ContainerMP4  = "mp4"
+	ContainerMOV  = "mov"
+	ContainerWebM = "webm"
+)
+
+var (
+	// ErrUnsupported means the file is not a readable video; retrying will not help
+	ErrUnsupported = errors.New("unsupported or corrupt video")
+	// ErrUnavailable means the transcoder cannot perform the operation (e.g. no ffmpeg installed)
+	ErrUnavailable = errors.New("video transcoder unavailable")
+)
+
+// Info describes a probed video
+type Info struct {
+	Container  string // mp4 | mov | webm
+	VideoCodec string // h264, hevc, vp8, vp9, av1, mpeg4...
+	AudioCodec string // aac, opus, mp3... ("" without audio track)
+	Duration   time.Duration
+	Width      int
+	Height     int
+}
+
+// WebSafe reports whether browsers can play the file as is (H.264 + AAC in MP4)
+func (i Info) WebSafe() bool {
+	return (i.Container == ContainerMP4 || i.Container == ContainerMOV) &&
+		i.VideoCodec == "h264" && (i.AudioCodec == "" || i.AudioCodec == "aac")
+}
+
+// Limits are the upload rules checked by Validate
+type Limits struct {
+	MaxDuration   time.Duration
+	AllowedCodecs []string // video codecs; empty allows any
+}
+
+// Validate checks a probed video against the limits
+func Validate(info *Info, limits Limits) error {
+	switch info.Container {
+	case ContainerMP4, ContainerMOV, ContainerWebM:
+	default:
+		return fmt.Errorf("%w: container %q is not allowed", ErrUnsupported, info.Container)
+	}
+	if info.VideoCodec == "" {
+		return fmt.Errorf("%w: no video track", ErrUnsupported)
+	}
+	if len(limits.AllowedCodecs) > 0 && !contains(limits.AllowedCodecs, info.VideoCodec) {
+		return fmt.Errorf("%w: codec %s is not allowed (allowed: %s)", ErrUnsupported, info.VideoCodec, strings.Join(limits.AllowedCodecs, ", "))
+	}
+	if info.Duration <= 0 {
+		return fmt.Errorf("%w: unknown duration", ErrUnsupported)
+	}
+	if limits.MaxDuration > 0 && info.Duration > limits.MaxDuration {
+		return fmt.Errorf("%w: duration %s exceeds %s", ErrUnsupported, info.Duration.Round(time.Second), limits.MaxDuration)
+	}
+	return nil
+}
+
+// TranscodeOptions controls Transcoder.Transcode
+type TranscodeOptions struct {
+	// Remux copies the streams instead of re-encoding (input is already web-safe); metadata is
+	// still stripped and the index moved to the front for progressive playback
+	Remux    bool
+	MaxWidth int // downscale wider videos (re-encode only); 0 = 1920
+}
+
+// Transcoder is the pluggable video backend
+type Transcoder interface {
+	// Probe reads the container, codecs, duration and size of the file at path
+	Probe(ctx context.Context, path string) (*Info, error)
+	// Poster returns a JPEG frame taken at the given offset
+	Poster(ctx context.Context, path string, at time.Duration) ([]byte, error)
+	// Transcode writes a web-safe MP4 (H.264/AAC, faststart, no metadata) to out
+	Transcode(ctx context.Context, in, out string, opts TranscodeOptions) error
+}
+
+// PosterOffset picks the poster frame: one second in, or the middle of very short clips
+func PosterOffset(d time.Duration) time.Duration {
+	if d > 2*time.Second {
+		return time.Second
+	}
+	return d / 2
+}
+
+// SniffContainer identifies a video container from the first bytes of a file
+func SniffContainer(head []byte) string {
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		if string(head[8:12]) == "qt  " {
+			return ContainerMOV
+		}
+		return ContainerMP4
+	}
+	if len(head) >= 4 && head[0] == 0x1A && head[1] == 0x45 && head[2] == 0xDF && head[3] == 0xA3 {
+		return ContainerWebM // EBML (WebM / Matroska)
+	}
+	return ""
+}
+
+// codecName maps MP4 sample entry types and ffprobe names to the names used in Limits
+func codecName(fourcc string) string {
+	switch strings.TrimSpace(strings.ToLower(fourcc)) {
+	case "avc1", "avc3", "h264":
+		return "h264"
+	case "hvc1", "hev1", "hevc", "h265":
+		return "hevc"
+	case "vp08", "vp8":
+		return "vp8"
+	case "vp09", "vp9":
+		return "vp9"
+	case "av01", "av1":
+		return "av1"
+	case "mp4v", "mpeg4":
+		return "mpeg4"
+	case "mp4a", "aac":
+		return "aac"
+	case "opus":
+		return "opus"
+	case ".mp3", "mp3":
+		return "mp3"
+	}
+	return strings.TrimSpace(strings.ToLower(fourcc))
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(strings.TrimSpace(s), v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(b, typ...), body...)
+}
+
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+// testTrack builds a trak with a handler, sample entry and (for video) display size
+func testTrack(handler, fourcc string, w, h uint32) []byte {
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], w<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], h<<16)
+	hdlr := append(append(make([]byte, 8), handler...), make([]byte, 13)...)
+	stsd := append(append(append(u32(0), u32(1)...), u32(16)...), fourcc...)
+	stsd = append(stsd, make([]byte, 8)...)
+	return mp4Box("trak",
+		mp4Box("tkhd", tkhd),
+		mp4Box("mdia", mp4Box("hdlr", hdlr), mp4Box("minf", mp4Box("stbl", mp4Box("stsd", stsd)))),
+	)
+}
+
+// testMP4 builds a 90 s file with the moov box after mdat (as phones record)
+func testMP4(brand, videoFourcc string) []byte {
+	mvhd := make([]byte, 20)
+	binary.BigEndian.PutUint32(mvhd[12:], 600)    // timescale
+	binary.BigEndian.PutUint32(mvhd[16:], 54_000) // 90 s
+	return bytes.Join([][]byte{
+		mp4Box("ftyp", []byte(brand), u32(0)),
+		mp4Box("mdat", make([]byte, 64)),
+		mp4Box("moov", mp4Box("mvhd", mvhd), testTrack("vide", videoFourcc, 1920, 1080), testTrack("soun", "mp4a", 0, 0)),
+	}, nil)
+}
+
+func TestProbeMP4(t *testing.T) {
+	data := testMP4("isom", "avc1")
+	info, err := ProbeMP4(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("ProbeMP4: %v", err)
+	}
+	want := Info{Container: ContainerMP4, VideoCodec: "h264", AudioCodec: "aac", Duration: 90 * time.Second, Width: 1920, Height: 1080}
+	if *info != want {
+		t.Fatalf("info = %+v, want %+v", *info, want)
+	}
+	if !info.WebSafe() {
+		t.Error("H.264/AAC MP4 should be web-safe")
+	}
+
+	mov := testMP4("qt  ", "hvc1")
+	info, err = ProbeMP4(bytes.NewReader(mov), int64(len(mov)))
+	if err != nil || info.Container != ContainerMOV || info.VideoCodec != "hevc" || info.WebSafe() {
+		t.Fatalf("mov = %+v, %v", info, err)
+	}
+
+	truncated := data[:len(data)-20]
+	if _, err := ProbeMP4(bytes.NewReader(truncated), int64(len(truncated))); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("truncated file error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	limits := Limits{MaxDuration: 2 * time.Minute, AllowedCodecs: []string{"h264", "hevc"}}
+	ok := &Info{Container: ContainerMP4, VideoCodec: "h264", Duration: 90 * time.Second}
+	if err := Validate(ok, limits); err != nil {
+		t.Fatalf("valid video: %v", err)
+	}
+	for _, bad := range []*Info{
+		{Container: ContainerMP4, VideoCodec: "h264", Duration: 3 * time.Minute},
+		{Container: ContainerWebM, VideoCodec: "vp9", Duration: time.Minute},
+		{Container: "avi", VideoCodec: "h264", Duration: time.Minute},
+		{Container: ContainerMP4, Duration: time.Minute},
+		{Container: ContainerMP4, VideoCodec: "h264"},
+	} {
+		if err := Validate(bad, limits); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%+v: error = %v", bad, err)
+		}
+	}
+}
+
+func TestLocalWithoutFFmpeg(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flight.mp4")
+	if err := os.WriteFile(path, testMP4("mp42", "avc1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l := &Local{}
+	info, err := l.Probe(t.Context(), path)
+	if err != nil || info.Duration != 90*time.Second {
+		t.Fatalf("Probe = %+v, %v", info, err)
+	}
+	if _, err := l.Poster(t.Context(), path, time.Second); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Poster error = %v", err)
+	}
+	if err := l.Transcode(t.Context(), path, path+".out", TranscodeOptions{}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Transcode error = %v", err)
+	}
+}
+
+func TestParseFFprobe(t *testing.T) {
+	out := []byte(`{"streams":[{"codec_type":"video","codec_name":"vp9","width":1280,"height":720},
+		{"codec_type":"audio","codec_name":"opus"}],"format":{"format_name":"matroska,webm","duration":"12.500000"}}`)
+	info, err := parseFFprobe(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Info{Container: ContainerWebM, VideoCodec: "vp9", AudioCodec: "opus", Duration: 12500 * time.Millisecond, Width: 1280, Height: 720}
+	if *info != want {
+		t.Fatalf("info = %+v", *info)
+	}
+}
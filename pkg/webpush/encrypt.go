@@ -0,0 +1,98 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// recordSize is the aes128gcm record size announced in the header; one record carries
+	// the whole message
+	recordSize = 4096
+	saltLen    = 16
+	// headerLen is salt || rs (uint32) || idlen (uint8) || keyid (uncompressed P-256 point)
+	headerLen = saltLen + 4 + 1 + 65
+	// MaxPayload is the largest plaintext that fits in one record: 16 bytes go to the GCM tag
+	// and one to the padding delimiter
+	MaxPayload = recordSize - headerLen - 16 - 1
+)
+
+// ErrPayloadTooLarge is returned for messages longer than MaxPayload
+var ErrPayloadTooLarge = errors.New("webpush: payload too large")
+
+// Encrypt encrypts plaintext for sub with the aes128gcm content coding of RFC 8291, using a
+// fresh ephemeral key and salt. The result is the complete request body.
+func Encrypt(sub Subscription, plaintext []byte) ([]byte, error) {
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: generate ephemeral key: %w", err)
+	}
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("webpush: generate salt: %w", err)
+	}
+	return encrypt(sub, plaintext, asKey, salt)
+}
+
+// encrypt is Encrypt with the application server key and salt supplied, so the RFC test
+// vector can be reproduced
+func encrypt(sub Subscription, plaintext []byte, asKey *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	if len(plaintext) > MaxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+	uaPublic, authSecret, err := sub.keys()
+	if err != nil {
+		return nil, err
+	}
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid p256dh key: %w", err)
+	}
+	ecdhSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: key agreement: %w", err)
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	// IKM = HKDF(auth_secret, ecdh_secret, "WebPush: info" || 0x00 || ua_public || as_public, 32)
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, err := hkdf.Key(sha256.New, ecdhSecret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, 0, headerLen+len(plaintext)+1+gcm.Overhead())
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(asPublic)))
+	body = append(body, asPublic...)
+
+	// The last (and only) record ends with the 0x02 padding delimiter
+	record := append(append([]byte{}, plaintext...), 0x02)
+	return gcm.Seal(body, nonce, record, nil), nil
+}
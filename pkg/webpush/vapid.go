@@ -0,0 +1,91 @@
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// vapidTokenTTL is the lifetime of the signed JWT; RFC 8292 caps it at 24 hours
+const vapidTokenTTL = 12 * time.Hour
+
+// VAPIDKeys is the application server key pair of RFC 8292, both halves base64url encoded:
+// the public key as an uncompressed P-256 point (the applicationServerKey browsers subscribe
+// with) and the private key as the raw 32-byte scalar
+type VAPIDKeys struct {
+	PublicKey  string
+	PrivateKey string
+}
+
+// GenerateVAPIDKeys creates a new application server key pair
+func GenerateVAPIDKeys() (VAPIDKeys, error) {
+	k, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return VAPIDKeys{}, err
+	}
+	return VAPIDKeys{
+		PublicKey:  base64.RawURLEncoding.EncodeToString(k.PublicKey().Bytes()),
+		PrivateKey: base64.RawURLEncoding.EncodeToString(k.Bytes()),
+	}, nil
+}
+
+// signingKey parses the key pair and checks that both halves belong together
+func (k VAPIDKeys) signingKey() (*ecdsa.PrivateKey, error) {
+	if k.PublicKey == "" || k.PrivateKey == "" {
+		return nil, errors.New("webpush: VAPID keys are not configured")
+	}
+	d, err := decodeBase64(k.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: decode VAPID private key: %w", err)
+	}
+	priv, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid VAPID private key: %w", err)
+	}
+	pub := priv.PublicKey().Bytes()
+	if k.PublicKey != base64.RawURLEncoding.EncodeToString(pub) {
+		return nil, errors.New("webpush: VAPID public key does not match the private key")
+	}
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:65]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}, nil
+}
+
+// authorization builds the "vapid" Authorization header for a push to endpoint. The token
+// audience is the origin of the push service; subject is a mailto: or https: contact URL.
+func (k VAPIDKeys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	key, err := k.signingKey()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("webpush: invalid endpoint %q", endpoint)
+	}
+	claims := jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTokenTTL).Unix(),
+	}
+	if subject != "" {
+		claims["sub"] = subject
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("webpush: sign VAPID token: %w", err)
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", token, k.PublicKey), nil
+}
@@ -0,0 +1,199 @@
+// Package webpush sends Web Push messages to browser push subscriptions: payloads are
+// encrypted with the aes128gcm content coding (RFC 8291) and requests are authenticated with
+// VAPID (RFC 8292), so no push-service specific credentials are needed.
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Subscription is a browser PushSubscription as returned by pushManager.subscribe(); the keys
+// are base64url encoded as browsers serialize them
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	P256dh   string `json:"p256dh"`
+	Auth     string `json:"auth"`
+}
+
+// keys decodes and checks the subscription's public key and auth secret
+func (s Subscription) keys() (uaPublic, authSecret []byte, err error) {
+	uaPublic, err = decodeBase64(s.P256dh)
+	if err != nil || len(uaPublic) != 65 || uaPublic[0] != 0x04 {
+		return nil, nil, errors.New("webpush: p256dh must be an uncompressed P-256 public key")
+	}
+	authSecret, err = decodeBase64(s.Auth)
+	if err != nil || len(authSecret) != 16 {
+		return nil, nil, errors.New("webpush: auth must be a 16-byte secret")
+	}
+	return uaPublic, authSecret, nil
+}
+
+// ErrUnknownPushService is returned for endpoints that are not on a known browser push
+// service; subscriptions are user input, so pushing anywhere else would let users make the
+// server post to arbitrary (internal) hosts
+var ErrUnknownPushService = errors.New("webpush: endpoint is not a known push service")
+
+// pushServiceHosts are the push services of the supported browsers; entries starting with a
+// dot match any subdomain
+var pushServiceHosts = []string{
+	"fcm.googleapis.com",         // Chrome, Edge (Chromium), Opera, Samsung Internet
+	".push.services.mozilla.com", // Firefox
+	".notify.windows.com",        // legacy Edge
+	"web.push.apple.com",         // Safari
+}
+
+// KnownPushService reports whether endpoint is an https URL on the default port of one of
+// the browser push services
+func KnownPushService(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || (u.Port() != "" && u.Port() != "443") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range pushServiceHosts {
+		if host == h || (strings.HasPrefix(h, ".") && strings.HasSuffix(host, h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the subscription can be pushed to
+func (s Subscription) Validate() error {
+	if !KnownPushService(s.Endpoint) {
+		return ErrUnknownPushService
+	}
+	_, _, err := s.keys()
+	return err
+}
+
+// Urgency hints how soon the push service should deliver (RFC 8030 section 5.3)
+type Urgency string
+
+const (
+	UrgencyVeryLow Urgency = "very-low"
+	UrgencyLow     Urgency = "low"
+	UrgencyNormal  Urgency = "normal"
+	UrgencyHigh    Urgency = "high"
+)
+
+// Options control how the push service handles a message
+type Options struct {
+	// TTL is how long the push service keeps the message for an offline device; zero means
+	// deliver now or drop
+	TTL time.Duration
+	// Urgency defaults to normal
+	Urgency Urgency
+	// Topic replaces a pending message with the same topic (max 32 base64url characters)
+	Topic string
+}
+
+// StatusError is a push service response other than success
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webpush: push service returned %d: %s", e.StatusCode, e.Body)
+}
+
+// IsGone reports whether err means the subscription has expired or been revoked and must not
+// be used again (404 Not Found or 410 Gone)
+func IsGone(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && (se.StatusCode == http.StatusNotFound || se.StatusCode == http.StatusGone)
+}
+
+// Client sends push messages signed with one VAPID key pair
+type Client struct {
+	keys       VAPIDKeys
+	subject    string
+	httpClient *http.Client
+	// allowEndpoint guards every send, not just subscribe: stored rows may predate a check
+	allowEndpoint func(endpoint string) bool
+}
+
+// NewClient creates a client; subject is the contact URL (mailto: or https:) push services
+// can use to reach the operator
+func NewClient(keys VAPIDKeys, subject string) *Client {
+	return &Client{
+		keys:    keys,
+		subject: subject,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+			// Push services answer directly; a redirect could point anywhere
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		allowEndpoint: KnownPushService,
+	}
+}
+
+// PublicKey returns the applicationServerKey browsers must subscribe with
+func (c *Client) PublicKey() string {
+	return c.keys.PublicKey
+}
+
+// Send encrypts payload for sub and posts it to the subscription's push service
+func (c *Client) Send(ctx context.Context, sub Subscription, payload []byte, opts Options) error {
+	if !c.allowEndpoint(sub.Endpoint) {
+		return ErrUnknownPushService
+	}
+	body, err := Encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+	authz, err := c.keys.authorization(sub.Endpoint, c.subject, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webpush: create request: %w", err)
+	}
+	req.Header.Set("Authorization", authz)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(opts.TTL/time.Second)))
+	if opts.Urgency != "" {
+		req.Header.Set("Urgency", string(opts.Urgency))
+	}
+	if opts.Topic != "" {
+		req.Header.Set("Topic", opts.Topic)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webpush: send: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+}
+
+// decodeBase64 accepts base64url with or without padding, and standard base64 for keys
+// copied from other tools
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if strings.ContainsAny(s, "+/") {
+		return base64.RawStdEncoding.DecodeString(s)
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
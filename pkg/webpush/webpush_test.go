@@ -0,0 +1,228 @@
+package webpush
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func b64(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return b
+}
+
+// RFC 8291 Appendix A
+func TestEncryptRFC8291Vector(t *testing.T) {
+	asKey, err := ecdh.P256().NewPrivateKey(b64(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := Subscription{
+		Endpoint: "https://push.example.net/push/JzLQ3raZJfFBR0aqvOMsLrt54w4rJUsV",
+		P256dh:   "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+		Auth:     "BTBZMqHH6r4Tts7J_aSIgg",
+	}
+	got, err := encrypt(sub, []byte("When I grow up, I want to be a watermelon"), asKey, b64(t, "DGv6ra1nlYgDCS1FRnbzlw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if enc := base64.RawURLEncoding.EncodeToString(got); enc != want {
+		t.Errorf("ciphertext mismatch\n got %s\nwant %s", enc, want)
+	}
+}
+
+// decrypt plays the user agent: it derives the keys from its private key and opens the record
+func decrypt(t *testing.T, uaKey *ecdh.PrivateKey, authSecret, body []byte) []byte {
+	t.Helper()
+	salt, idLen := body[:saltLen], int(body[20])
+	asPublic := body[21 : 21+idLen]
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := uaKey.ECDH(asKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := append([]byte("WebPush: info\x00"), uaKey.PublicKey().Bytes()...)
+	info = append(info, asPublic...)
+	ikm, _ := hkdf.Key(sha256.New, secret, authSecret, string(info), 32)
+	cek, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	if err != nil {
+		t.Fatalf("open record: %v", err)
+	}
+	if plain[len(plain)-1] != 0x02 {
+		t.Fatalf("missing padding delimiter")
+	}
+	return plain[:len(plain)-1]
+}
+
+func newSubscription(t *testing.T, endpoint string) (Subscription, *ecdh.PrivateKey, []byte) {
+	t.Helper()
+	uaKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := []byte("0123456789abcdef")
+	return Subscription{
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(uaKey.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(auth),
+	}, uaKey, auth
+}
+
+func TestEncryptRoundTripAndLimit(t *testing.T) {
+	sub, uaKey, auth := newSubscription(t, "https://push.example.net/x")
+	msg := []byte(`{"title":"تمت المزايدة عليك"}`)
+	body, err := Encrypt(sub, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decrypt(t, uaKey, auth, body); string(got) != string(msg) {
+		t.Errorf("round trip: got %q", got)
+	}
+
+	if _, err := Encrypt(sub, make([]byte, MaxPayload)); err != nil {
+		t.Errorf("max payload rejected: %v", err)
+	}
+	if _, err := Encrypt(sub, make([]byte, MaxPayload+1)); err != ErrPayloadTooLarge {
+		t.Errorf("oversized payload: got %v", err)
+	}
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	sub, _, _ := newSubscription(t, "https://fcm.googleapis.com/fcm/send/x")
+	if err := sub.Validate(); err != nil {
+		t.Fatalf("valid subscription rejected: %v", err)
+	}
+	insecure := sub
+	insecure.Endpoint = "http://fcm.googleapis.com/fcm/send/x"
+	shortAuth := sub
+	shortAuth.Auth = "AAAA"
+	badKey := sub
+	badKey.P256dh = sub.Auth
+	for name, s := range map[string]Subscription{"http": insecure, "auth": shortAuth, "p256dh": badKey} {
+		if s.Validate() == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestKnownPushService(t *testing.T) {
+	for endpoint, want := range map[string]bool{
+		"https://fcm.googleapis.com/fcm/send/abc":                true,
+		"https://updates.push.services.mozilla.com/wpush/v2/abc": true,
+		"https://wns2-par02p.notify.windows.com/w/?token=abc":    true,
+		"https://web.push.apple.com/QGuQyavXutnMH":               true,
+		"https://FCM.googleapis.com:443/fcm/send/abc":            true,
+		"https://push.services.mozilla.com.evil.test/x":          false,
+		"https://fcm.googleapis.com.evil.test/x":                 false,
+		"https://evilpush.services.mozilla.com/x":                false,
+		"https://fcm.googleapis.com:8443/fcm/send/abc":           false,
+		"https://user@fcm.googleapis.com/fcm/send/abc":           false,
+		"https://169.254.169.254/latest/meta-data":               false,
+		"https://127.0.0.1/push":                                 false,
+		"http://fcm.googleapis.com/fcm/send/abc":                 false,
+	} {
+		if got := KnownPushService(endpoint); got != want {
+			t.Errorf("%s: got %v, want %v", endpoint, got, want)
+		}
+	}
+}
+
+func TestSendSignsAndReportsGone(t *testing.T) {
+	keys, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := http.StatusCreated
+	var gotReq *http.Request
+	var gotBody []byte
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReq = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c := NewClient(keys, "mailto:ops@example.com")
+	sub, uaKey, auth := newSubscription(t, srv.URL+"/push/abc")
+	if err := c.Send(context.Background(), sub, []byte("hi"), Options{}); err != ErrUnknownPushService || gotReq != nil {
+		t.Fatalf("unknown push service: got %v", err)
+	}
+	c.httpClient = srv.Client()
+	c.allowEndpoint = func(endpoint string) bool { return strings.HasPrefix(endpoint, srv.URL+"/") }
+
+	if err := c.Send(context.Background(), sub, []byte("hi"), Options{TTL: time.Hour, Urgency: UrgencyHigh, Topic: "outbid"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got := decrypt(t, uaKey, auth, gotBody); string(got) != "hi" {
+		t.Errorf("body: got %q", got)
+	}
+	if gotReq.Header.Get("Content-Encoding") != "aes128gcm" || gotReq.Header.Get("TTL") != "3600" ||
+		gotReq.Header.Get("Urgency") != "high" || gotReq.Header.Get("Topic") != "outbid" {
+		t.Errorf("unexpected headers: %v", gotReq.Header)
+	}
+
+	// The VAPID token must verify with the public key and name the push service origin
+	authz := gotReq.Header.Get("Authorization")
+	parts := strings.SplitN(strings.TrimPrefix(authz, "vapid t="), ", k=", 2)
+	if len(parts) != 2 || parts[1] != keys.PublicKey {
+		t.Fatalf("authorization header: %q", authz)
+	}
+	signer, err := keys.signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(parts[0], claims, func(*jwt.Token) (any, error) {
+		return &signer.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"})); err != nil {
+		t.Fatalf("verify token: %v", err)
+	}
+	if claims["aud"] != srv.URL || claims["sub"] != "mailto:ops@example.com" {
+		t.Errorf("claims: %v", claims)
+	}
+
+	status = http.StatusGone
+	if err := c.Send(context.Background(), sub, []byte("hi"), Options{}); !IsGone(err) {
+		t.Errorf("410: expected gone, got %v", err)
+	}
+	status = http.StatusTooManyRequests
+	if err := c.Send(context.Background(), sub, []byte("hi"), Options{}); err == nil || IsGone(err) {
+		t.Errorf("429: expected transient error, got %v", err)
+	}
+}
+
+func TestVAPIDKeysMustMatch(t *testing.T) {
+	a, _ := GenerateVAPIDKeys()
+	b, _ := GenerateVAPIDKeys()
+	if _, err := (VAPIDKeys{PublicKey: a.PublicKey, PrivateKey: b.PrivateKey}).signingKey(); err == nil {
+		t.Error("mismatched key pair accepted")
+	}
+	if _, err := (VAPIDKeys{}).signingKey(); err == nil {
+		t.Error("empty key pair accepted")
+	}
+}
@@ -12,8 +12,11 @@ import (
 
 	"encore.app/pkg/audit"
 	"encore.app/pkg/config"
+	"encore.app/pkg/einvoice"
 	"encore.app/pkg/errs"
 	"encore.app/pkg/logger"
+	"encore.app/pkg/maintenance"
+	authsvc "encore.app/svc/auth"
 	"encore.app/svc/users"
 	"encore.dev/beta/auth"
 	"encore.dev/storage/sqldb"
@@ -351,6 +354,10 @@ func (s *Service) UpdateSettings(ctx context.Context, req *UpdateSettingsRequest
 			resp.Errors = append(resp.Errors, UpdateError{Key: it.Key, Code: errs.Internal, Message: "فشل تحديث الإعداد"})
 			continue
 		}
+		// Maintenance and registration flags are read by every service through a short cache
+		if strings.HasPrefix(it.Key, "app.") {
+			maintenance.Invalidate()
+		}
 
 		// Audit logging (non-blocking): سجّل الفرق بين القديم والجديد
 		meta := map[string]interface{}{
@@ -550,6 +557,11 @@ func validateKeyValue(key, value string) *errs.Error {
 			return errs.New(errs.ValidationFailed, "قيمة رقمية غير صالحة")
 		}
 		return nil
+	case "company.vat_number":
+		if !einvoice.ValidVATNumber(value) {
+			return errs.New(errs.ValidationFailed, "الرقم الضريبي يجب أن يتكون من 15 رقماً ويبدأ وينتهي بالرقم 3")
+		}
+		return nil
 	case "media.watermark.opacity":
 		i, err := strconv.Atoi(value)
 		if err != nil {
@@ -985,6 +997,13 @@ func (s *Service) UpdateUserState(ctx context.Context, id int64, req *UpdateUser
 		return nil, errs.New(errs.Internal, "فشل تحديث الحالة")
 	}
 
+	// A deactivated user must not keep minting access tokens from existing refresh tokens
+	if state == "inactive" {
+		if _, err := authsvc.RevokeUserTokens(ctx, &authsvc.RevokeUserTokensRequest{UserID: id, Reason: "user_inactive"}); err != nil {
+			return nil, errs.New(errs.Internal, "فشل إنهاء جلسات المستخدم")
+		}
+	}
+
 	// Audit
 	meta := map[string]interface{}{"user_id": id, "new_state": state}
 	if req.Reason != nil {
@@ -0,0 +1,125 @@
+package adminsettings
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"encore.app/pkg/audit"
+	"encore.app/pkg/errs"
+	"encore.app/pkg/maintenance"
+	"encore.dev/beta/auth"
+)
+
+// maxMaintenanceWindow caps a scheduled window; longer outages use app.maintenance_mode
+const maxMaintenanceWindow = 24 * time.Hour
+
+type ListMaintenanceWindowsRequest struct {
+	IncludePast bool `query:"include_past"`
+}
+
+type ListMaintenanceWindowsResponse struct {
+	Items []maintenance.Window `json:"items"`
+}
+
+type CreateMaintenanceWindowRequest struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Message  string    `json:"message"` // shown to users on the status endpoint and in APP_MAINTENANCE errors
+}
+
+type CancelMaintenanceWindowResponse struct {
+	Success bool `json:"success"`
+}
+
+// ListMaintenanceWindows lists scheduled maintenance windows (upcoming and running by default)
+//
+//encore:api auth method=GET path=/admin/maintenance/windows
+func (s *Service) ListMaintenanceWindows(ctx context.Context, req *ListMaintenanceWindowsRequest) (*ListMaintenanceWindowsResponse, error) {
+	if _, ok := auth.UserID(); !ok {
+		return nil, errs.New(errs.Unauthenticated, "مطلوب تسجيل الدخول")
+	}
+	if !isAdmin() {
+		return nil, errs.New(errs.Forbidden, "يتطلب صلاحيات مدير")
+	}
+	items, err := maintenance.ListWindows(ctx, db, req != nil && req.IncludePast)
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل جلب نوافذ الصيانة")
+	}
+	return &ListMaintenanceWindowsResponse{Items: items}, nil
+}
+
+// CreateMaintenanceWindow schedules a maintenance window; it is announced on GET /status
+// and blocks non-admin writes while it runs
+//
+//encore:api auth method=POST path=/admin/maintenance/windows
+func (s *Service) CreateMaintenanceWindow(ctx context.Context, req *CreateMaintenanceWindowRequest) (*maintenance.Window, error) {
+	uidStr, ok := auth.UserID()
+	if !ok {
+		return nil, errs.New(errs.Unauthenticated, "مطلوب تسجيل الدخول")
+	}
+	var actorID *int64
+	if id64, err := strconv.ParseInt(string(uidStr), 10, 64); err == nil {
+		actorID = &id64
+	}
+	if !isAdmin() {
+		return nil, errs.New(errs.Forbidden, "يتطلب صلاحيات مدير")
+	}
+	if req == nil || req.StartsAt.IsZero() || req.EndsAt.IsZero() {
+		return nil, errs.New(errs.InvalidArgument, "وقت البداية والنهاية مطلوبان")
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		return nil, errs.New(errs.ValidationFailed, "يجب أن يكون وقت النهاية بعد وقت البداية")
+	}
+	if !req.EndsAt.After(time.Now()) {
+		return nil, errs.New(errs.ValidationFailed, "لا يمكن جدولة نافذة صيانة منتهية")
+	}
+	if req.EndsAt.Sub(req.StartsAt) > maxMaintenanceWindow {
+		return nil, errs.New(errs.ValidationFailed, "مدة نافذة الصيانة لا يمكن أن تتجاوز 24 ساعة")
+	}
+
+	w, err := maintenance.CreateWindow(ctx, db, req.StartsAt, req.EndsAt, req.Message, actorID)
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل جدولة نافذة الصيانة")
+	}
+
+	_, _ = audit.Log(ctx, db, audit.Entry{
+		ActorUserID: actorID,
+		Action:      "admin.maintenance_window.create",
+		EntityType:  "maintenance_window",
+		EntityID:    fmt.Sprintf("%d", w.ID),
+		Meta: map[string]interface{}{
+			"starts_at": w.StartsAt,
+			"ends_at":   w.EndsAt,
+			"message":   w.Message,
+		},
+	})
+	return w, nil
+}
+
+// CancelMaintenanceWindow cancels an upcoming or running maintenance window
+//
+//encore:api auth method=DELETE path=/admin/maintenance/windows/:id
+func (s *Service) CancelMaintenanceWindow(ctx context.Context, id int64) (*CancelMaintenanceWindowResponse, error) {
+	if _, ok := auth.UserID(); !ok {
+		return nil, errs.New(errs.Unauthenticated, "مطلوب تسجيل الدخول")
+	}
+	if !isAdmin() {
+		return nil, errs.New(errs.Forbidden, "يتطلب صلاحيات مدير")
+	}
+	cancelled, err := maintenance.CancelWindow(ctx, db, id)
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل إلغاء نافذة الصيانة")
+	}
+	if !cancelled {
+		return nil, errs.New(errs.NotFound, "نافذة الصيانة غير موجودة أو انتهت")
+	}
+
+	_, _ = audit.Log(ctx, db, audit.Entry{
+		Action:     "admin.maintenance_window.cancel",
+		EntityType: "maintenance_window",
+		EntityID:   fmt.Sprintf("%d", id),
+	})
+	return &CancelMaintenanceWindowResponse{Success: true}, nil
+}
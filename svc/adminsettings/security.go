@@ -0,0 +1,136 @@
+package adminsettings
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"encore.app/pkg/audit"
+	"encore.app/pkg/errs"
+	authsvc "encore.app/svc/auth"
+	"encore.dev/beta/auth"
+)
+
+type ListLoginLockoutsRequest struct {
+	Scope      string `query:"scope"`       // account | ip; empty for both
+	LockedOnly bool   `query:"locked_only"` // only keys locked right now
+	Limit      int    `query:"limit"`
+}
+
+type UnlockUserRequest struct {
+	Reason *string `json:"reason,omitempty"`
+}
+
+type ClearIPLockoutRequest struct {
+	IP     string  `json:"ip"`
+	Reason *string `json:"reason,omitempty"`
+}
+
+type UnlockResponse struct {
+	Success bool `json:"success"`
+	Cleared bool `json:"cleared"` // false when there were no failed sign-ins to clear
+}
+
+// ListLoginLockouts lists locked accounts and IPs and recent failed sign-ins, locked first
+//
+//encore:api auth method=GET path=/admin/security/lockouts
+func (s *Service) ListLoginLockouts(ctx context.Context, req *ListLoginLockoutsRequest) (*authsvc.ListLoginLockoutsResponse, error) {
+	if _, ok := auth.UserID(); !ok {
+		return nil, errs.New(errs.Unauthenticated, "مطلوب تسجيل الدخول")
+	}
+	if !isAdmin() {
+		return nil, errs.New(errs.Forbidden, "يتطلب صلاحيات مدير")
+	}
+	q := &authsvc.ListLoginLockoutsRequest{}
+	if req != nil {
+		q.Scope = strings.ToLower(strings.TrimSpace(req.Scope))
+		q.LockedOnly = req.LockedOnly
+		q.Limit = req.Limit
+	}
+	if q.Scope != "" && q.Scope != "account" && q.Scope != "ip" {
+		return nil, errs.New(errs.ValidationFailed, "النطاق يجب أن يكون account أو ip")
+	}
+	resp, err := authsvc.ListLoginLockouts(ctx, q)
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل جلب محاولات الدخول الفاشلة")
+	}
+	return resp, nil
+}
+
+// UnlockUser lifts a login lockout of a user's account before it runs out
+//
+//encore:api auth method=POST path=/admin/users/:id/unlock
+func (s *Service) UnlockUser(ctx context.Context, id int64, req *UnlockUserRequest) (*UnlockResponse, error) {
+	uidStr, ok := auth.UserID()
+	if !ok {
+		return nil, errs.New(errs.Unauthenticated, "مطلوب تسجيل الدخول")
+	}
+	if !isAdmin() {
+		return nil, errs.New(errs.Forbidden, "يتطلب صلاحيات مدير")
+	}
+	resp, err := authsvc.ClearLoginLockout(ctx, &authsvc.ClearLoginLockoutRequest{UserID: id})
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل فتح الحساب")
+	}
+
+	meta := map[string]interface{}{"user_id": id, "cleared": resp.Cleared}
+	if req != nil && req.Reason != nil {
+		meta["reason"] = *req.Reason
+	}
+	_, _ = audit.Log(ctx, db, audit.Entry{
+		ActorUserID: actorUserID(uidStr),
+		Action:      "admin.user.unlock",
+		EntityType:  "user",
+		EntityID:    fmt.Sprintf("%d", id),
+		Meta:        meta,
+	})
+	return &UnlockResponse{Success: true, Cleared: resp.Cleared}, nil
+}
+
+// ClearIPLockout forgets the failed sign-ins of a client IP, lifting its lock
+//
+//encore:api auth method=POST path=/admin/security/lockouts/clear-ip
+func (s *Service) ClearIPLockout(ctx context.Context, req *ClearIPLockoutRequest) (*UnlockResponse, error) {
+	uidStr, ok := auth.UserID()
+	if !ok {
+		return nil, errs.New(errs.Unauthenticated, "مطلوب تسجيل الدخول")
+	}
+	if !isAdmin() {
+		return nil, errs.New(errs.Forbidden, "يتطلب صلاحيات مدير")
+	}
+	if req == nil || strings.TrimSpace(req.IP) == "" {
+		return nil, errs.New(errs.InvalidArgument, "عنوان IP مطلوب")
+	}
+	ip := strings.TrimSpace(req.IP)
+	if net.ParseIP(ip) == nil {
+		return nil, errs.New(errs.ValidationFailed, "عنوان IP غير صالح")
+	}
+	resp, err := authsvc.ClearLoginLockout(ctx, &authsvc.ClearLoginLockoutRequest{IP: ip})
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل فتح عنوان IP")
+	}
+
+	meta := map[string]interface{}{"ip": ip, "cleared": resp.Cleared}
+	if req.Reason != nil {
+		meta["reason"] = *req.Reason
+	}
+	_, _ = audit.Log(ctx, db, audit.Entry{
+		ActorUserID: actorUserID(uidStr),
+		Action:      "admin.login_lockout.clear_ip",
+		EntityType:  "ip",
+		EntityID:    ip,
+		Meta:        meta,
+	})
+	return &UnlockResponse{Success: true, Cleared: resp.Cleared}, nil
+}
+
+// actorUserID parses the caller's user ID for audit entries
+func actorUserID(uid auth.UID) *int64 {
+	id, err := strconv.ParseInt(string(uid), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"encore.app/pkg/errs"
+	"encore.app/pkg/pedigree"
 	"encore.app/pkg/ratelimit"
 	authsvc "encore.app/svc/auth"
 	"encore.dev/beta/auth"
@@ -242,6 +243,7 @@ func GetAuction(ctx context.Context, id string) (*AuctionDetailResponse, error)
 			Amount:             bid.Amount,
 			BidderNameSnapshot: bid.BidderNameSnapshot,
 			BidderCityName:     bid.BidderCityName,
+			IsAuto:             bid.IsAuto,
 			CreatedAt:          bid.CreatedAt,
 		}
 	}
@@ -260,11 +262,20 @@ func GetAuction(ctx context.Context, id string) (*AuctionDetailResponse, error)
 		}
 	}
 
+	// Pedigree of the pigeon (don't fail the auction page if it can't be loaded)
+	var pedigreeEntries []pedigree.Entry
+	if root, err := pedigree.LoadForProduct(ctx, service.db.Stdlib(), auction.ProductID, pedigree.DefaultGenerations); err == nil {
+		if root.Depth() > 1 || len(root.Results) > 0 {
+			pedigreeEntries = root.Entries()
+		}
+	}
+
 	return &AuctionDetailResponse{
 		Auction:       ToRichAuctionResponse(auction),
 		Bids:          bidResponses,
 		BidCount:      auction.BidsCount, // Use the count from AuctionWithDetails
 		ReserveStatus: reserveStatus,
+		Pedigree:      pedigreeEntries,
 	}, nil
 }
 
@@ -312,12 +323,7 @@ func PlaceBid(ctx context.Context, id string, req *PlaceBidDTO) (*BidResponse, e
 		return nil, errs.E(ctx, errs.BidVerifiedRequired, "المزايدة تتطلب دور مفعّل (verified)")
 	}
 
-	// Rate limit: protect bid spamming based on system settings
-	if svc := GetService(); svc != nil && svc.rateLimitService != nil {
-		if err := svc.rateLimitService.CheckBidRateLimit(ctx, userIDInt); err != nil {
-			return nil, err
-		}
-	}
+	// Bid rate limiting happens in BidService.placeBid, which counts each attempt once
 
 	// Parse auction ID from path
 	auctionID, err := strconv.ParseInt(id, 10, 64)
@@ -328,6 +334,22 @@ func PlaceBid(ctx context.Context, id string, req *PlaceBidDTO) (*BidResponse, e
 	service := GetService()
 	bidService := NewBidService(service.db)
 
+	// Proxy (automatic max) bid: register the ceiling and let the platform counter-bid
+	if req.MaxAmount != nil {
+		bid, err := bidService.PlaceProxyBid(ctx, auctionID, userIDInt, req.Amount, *req.MaxAmount)
+		if err != nil {
+			return nil, err
+		}
+
+		response := ToSimpleBidResponse(bid)
+		response.MaxAmount = req.MaxAmount
+		if leader, err := bidService.repo.GetHighestBid(ctx, auctionID); err == nil && leader != nil {
+			isHighest := leader.UserID == userIDInt
+			response.IsHighest = &isHighest
+		}
+		return response, nil
+	}
+
 	bid, err := bidService.PlaceBid(ctx, auctionID, userIDInt, req.Amount)
 	if err != nil {
 		return nil, err
@@ -336,6 +358,198 @@ func PlaceBid(ctx context.Context, id string, req *PlaceBidDTO) (*BidResponse, e
 	return ToSimpleBidResponse(bid), nil
 }
 
+// GetMyProxyBid returns the caller's proxy (automatic max) bid on an auction
+//
+//encore:api auth method=GET path=/auctions/:id/proxy-bid
+func GetMyProxyBid(ctx context.Context, id string) (*ProxyBidResponse, error) {
+	userID, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "مطلوب تسجيل الدخول",
+		}
+	}
+	userIDInt, err := strconv.ParseInt(string(userID), 10, 64)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.Internal,
+			Message: "خطأ في معرف المستخدم",
+		}
+	}
+
+	auctionID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرف المزاد غير صحيح"}
+	}
+
+	bidService := NewBidService(GetService().db)
+	proxy, err := bidService.GetProxyBid(ctx, auctionID, userIDInt)
+	if err != nil {
+		return nil, err
+	}
+
+	isHighest := false
+	if leader, err := bidService.repo.GetHighestBid(ctx, auctionID); err == nil && leader != nil {
+		isHighest = leader.UserID == userIDInt
+	}
+
+	return &ProxyBidResponse{
+		AuctionID: proxy.AuctionID,
+		MaxAmount: proxy.MaxAmount,
+		Status:    string(proxy.Status),
+		IsHighest: isHighest,
+		UpdatedAt: proxy.UpdatedAt,
+	}, nil
+}
+
+// CancelMyProxyBid stops automatic bidding for the caller on an auction
+//
+//encore:api auth method=DELETE path=/auctions/:id/proxy-bid
+func CancelMyProxyBid(ctx context.Context, id string) (*MessageResponse, error) {
+	userID, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "مطلوب تسجيل الدخول",
+		}
+	}
+	userIDInt, err := strconv.ParseInt(string(userID), 10, 64)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.Internal,
+			Message: "خطأ في معرف المستخدم",
+		}
+	}
+
+	auctionID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرف المزاد غير صحيح"}
+	}
+
+	bidService := NewBidService(GetService().db)
+	if err := bidService.CancelProxyBid(ctx, auctionID, userIDInt); err != nil {
+		return nil, err
+	}
+
+	return &MessageResponse{
+		Success: true,
+		Message: "تم إيقاف المزايدة التلقائية",
+	}, nil
+}
+
+// WatchAuction adds an auction to the caller's watchlist
+//
+//encore:api auth method=POST path=/auctions/:id/watch
+func WatchAuction(ctx context.Context, id string) (*MessageResponse, error) {
+	userID, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "مطلوب تسجيل الدخول",
+		}
+	}
+	userIDInt, err := strconv.ParseInt(string(userID), 10, 64)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.Internal,
+			Message: "خطأ في معرف المستخدم",
+		}
+	}
+
+	auctionID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرف المزاد غير صحيح"}
+	}
+
+	if err := GetService().watchlistService.Watch(ctx, auctionID, userIDInt); err != nil {
+		return nil, err
+	}
+
+	return &MessageResponse{
+		Success: true,
+		Message: "تمت إضافة المزاد إلى قائمة المتابعة",
+	}, nil
+}
+
+// UnwatchAuction removes an auction from the caller's watchlist
+//
+//encore:api auth method=DELETE path=/auctions/:id/watch
+func UnwatchAuction(ctx context.Context, id string) (*MessageResponse, error) {
+	userID, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "مطلوب تسجيل الدخول",
+		}
+	}
+	userIDInt, err := strconv.ParseInt(string(userID), 10, 64)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.Internal,
+			Message: "خطأ في معرف المستخدم",
+		}
+	}
+
+	auctionID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرف المزاد غير صحيح"}
+	}
+
+	if err := GetService().watchlistService.Unwatch(ctx, auctionID, userIDInt); err != nil {
+		return nil, err
+	}
+
+	return &MessageResponse{
+		Success: true,
+		Message: "تمت إزالة المزاد من قائمة المتابعة",
+	}, nil
+}
+
+// GetMyWatchlist returns the auctions the caller is watching
+//
+//encore:api auth method=GET path=/me/watchlist
+func GetMyWatchlist(ctx context.Context) (*WatchlistResponse, error) {
+	userID, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "مطلوب تسجيل الدخول",
+		}
+	}
+	userIDInt, err := strconv.ParseInt(string(userID), 10, 64)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.Internal,
+			Message: "خطأ في معرف المستخدم",
+		}
+	}
+
+	service := GetService()
+	auctionIDs, err := service.watchlistService.ListWatchedAuctionIDs(ctx, userIDInt)
+	if err != nil {
+		return nil, err
+	}
+
+	auctions := make([]*AuctionResponse, 0, len(auctionIDs))
+	for _, auctionID := range auctionIDs {
+		auction, err := service.GetAuction(ctx, auctionID)
+		if err != nil {
+			continue
+		}
+		if service.storage != nil {
+			if thumbnailURL, err := service.getProductThumbnail(ctx, auction.ProductID); err == nil && thumbnailURL != nil {
+				auction.ThumbnailURL = thumbnailURL
+			}
+		}
+		auctions = append(auctions, ToRichAuctionResponse(auction))
+	}
+
+	return &WatchlistResponse{
+		Auctions: auctions,
+		Total:    len(auctions),
+	}, nil
+}
+
 // RemoveBid removes a bid (Admin only)
 //
 //encore:api auth method=POST path=/bids/:id/remove
@@ -419,6 +633,129 @@ func GetRateLimitStatus(ctx context.Context) (*RateLimitStatus, error) {
 	return status, nil
 }
 
+// GetFailurePolicy returns what happens when the auction fails (Admin only)
+//
+//encore:api auth method=GET path=/auctions/:id/failure-policy
+func GetFailurePolicy(ctx context.Context, id string) (*FailurePolicy, error) {
+	if err := checkAdminAuth(); err != nil {
+		return nil, err
+	}
+
+	auctionID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرف المزاد غير صحيح"}
+	}
+
+	return GetService().failurePolicy.GetPolicy(ctx, auctionID)
+}
+
+// SetFailurePolicy sets the relist / second-chance policy for an auction (Admin only)
+//
+//encore:api auth method=PUT path=/auctions/:id/failure-policy
+func SetFailurePolicy(ctx context.Context, id string, req *FailurePolicyDTO) (*FailurePolicy, error) {
+	if err := checkAdminAuth(); err != nil {
+		return nil, err
+	}
+
+	auctionID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرف المزاد غير صحيح"}
+	}
+
+	return GetService().failurePolicy.SetPolicy(ctx, auctionID, req)
+}
+
+// GetMySecondChanceOffers lists second-chance offers made to the caller
+//
+//encore:api auth method=GET path=/me/second-chance-offers
+func GetMySecondChanceOffers(ctx context.Context) (*SecondChanceOffersResponse, error) {
+	userID, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "مطلوب تسجيل الدخول",
+		}
+	}
+	userIDInt, err := strconv.ParseInt(string(userID), 10, 64)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.Internal,
+			Message: "خطأ في معرف المستخدم",
+		}
+	}
+
+	offers, err := GetService().failurePolicy.ListUserOffers(ctx, userIDInt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecondChanceOffersResponse{
+		Offers: offers,
+		Total:  len(offers),
+	}, nil
+}
+
+// AcceptSecondChanceOffer accepts an offer and creates the order at the offered amount
+//
+//encore:api auth method=POST path=/second-chance-offers/:id/accept
+func AcceptSecondChanceOffer(ctx context.Context, id string) (*SecondChanceOffer, error) {
+	userID, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "مطلوب تسجيل الدخول",
+		}
+	}
+	userIDInt, err := strconv.ParseInt(string(userID), 10, 64)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.Internal,
+			Message: "خطأ في معرف المستخدم",
+		}
+	}
+
+	offerID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرف العرض غير صحيح"}
+	}
+
+	return GetService().failurePolicy.AcceptOffer(ctx, offerID, userIDInt)
+}
+
+// DeclineSecondChanceOffer declines an offer; the item is offered to the next bidder
+//
+//encore:api auth method=POST path=/second-chance-offers/:id/decline
+func DeclineSecondChanceOffer(ctx context.Context, id string) (*MessageResponse, error) {
+	userID, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "مطلوب تسجيل الدخول",
+		}
+	}
+	userIDInt, err := strconv.ParseInt(string(userID), 10, 64)
+	if err != nil {
+		return nil, &errs.Error{
+			Code:    errs.Internal,
+			Message: "خطأ في معرف المستخدم",
+		}
+	}
+
+	offerID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرف العرض غير صحيح"}
+	}
+
+	if err := GetService().failurePolicy.DeclineOffer(ctx, offerID, userIDInt); err != nil {
+		return nil, err
+	}
+
+	return &MessageResponse{
+		Success: true,
+		Message: "تم رفض العرض",
+	}, nil
+}
+
 // GetReserveStatus gets reserve price status for an auction
 //
 //encore:api public method=GET path=/auctions/:id/reserve-status
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"encore.app/pkg/errs"
+	"encore.app/pkg/outbox"
 	"encore.app/svc/notifications"
 	"encore.dev/storage/sqldb"
 )
@@ -108,13 +109,16 @@ func (s *BidManagementService) RemoveBid(ctx context.Context, bidID int64, reaso
 		return nil, fmt.Errorf("failed to create audit entry: %w", err)
 	}
 
+	// Queue notifications with the removal so they are neither lost nor sent for a rolled-back removal
+	if err := outbox.Add(ctx, tx, s.postRemovalMessages(ctx, bid, auction, reason, removedBy, newCurrentPrice)...); err != nil {
+		return nil, fmt.Errorf("failed to queue removal notifications: %w", err)
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-
-	// Send notifications and broadcasts (after commit)
-	go s.sendPostRemovalNotifications(ctx, bid, auction, reason, removedBy, newCurrentPrice)
+	notifications.KickOutbox()
 
 	// Broadcast events
 	if s.realtimeService != nil {
@@ -494,59 +498,55 @@ func (s *BidManagementService) countAffectedBidders(ctx context.Context, auction
 	return count, err
 }
 
-func (s *BidManagementService) sendPostRemovalNotifications(ctx context.Context, bid *Bid, auction *Auction, reason, removedBy string, newPrice float64) {
+// postRemovalMessages builds the notifications for a removed bid. Recipients are read outside the
+// removal transaction, which only deletes the removed bid itself.
+func (s *BidManagementService) postRemovalMessages(ctx context.Context, bid *Bid, auction *Auction, reason, removedBy string, newPrice float64) []outbox.Message {
+	eventKey := outbox.Key("bid", bid.ID, "removed")
+
 	// 1. Notify the bidder whose bid was removed
-	s.notifyBidderRemoved(ctx, bid, auction, reason, removedBy)
-	
+	msgs := s.bidderRemovedMessages(ctx, bid, auction, reason, removedBy, eventKey)
+
 	// 2. Notify other affected bidders (those who bid after the removed bid)
-	s.notifyAffectedBidders(ctx, bid, auction, newPrice)
-	
+	msgs = append(msgs, s.affectedBidderMessages(ctx, bid, auction, newPrice, eventKey)...)
+
 	// 3. Notify auction watchers about price change
-	s.notifyAuctionWatchers(ctx, auction, newPrice, reason)
+	return append(msgs, NewWatchlistService(s.db).priceChangedMessages(ctx, auction, newPrice, reason, 0, eventKey)...)
 }
 
-// notifyBidderRemoved sends notification to the bidder whose bid was removed
-func (s *BidManagementService) notifyBidderRemoved(ctx context.Context, bid *Bid, auction *Auction, reason, removedBy string) {
-    // Get product title for context
-    var productTitle string
-    if err := s.db.QueryRow(ctx, "SELECT title FROM products WHERE id = $1", auction.ProductID).Scan(&productTitle); err != nil {
-        productTitle = fmt.Sprintf("المزاد #%d", auction.ID)
-    }
-
-    payload := map[string]interface{}{
-        "bidder_name":  bid.BidderNameSnapshot,
-        "auction_id":   fmt.Sprint(auction.ID),
-        "product_title": productTitle,
-        "bid_amount":   fmt.Sprintf("%.2f", bid.Amount),
-        "reason":       reason,
-        "removed_by":   removedBy,
-        "language":     "ar",
-    }
-	
-	// Send internal notification
-	if _, err := notifications.EnqueueInternal(ctx, bid.UserID, "bid_removed", payload); err != nil {
-		fmt.Printf("Failed to send internal notification to bidder %d: %v\n", bid.UserID, err)
-	}
-	
+// bidderRemovedMessages notifies the bidder whose bid was removed
+func (s *BidManagementService) bidderRemovedMessages(ctx context.Context, bid *Bid, auction *Auction, reason, removedBy, eventKey string) []outbox.Message {
+	// Get product title for context
+	var productTitle string
+	if err := s.db.QueryRow(ctx, "SELECT title FROM products WHERE id = $1", auction.ProductID).Scan(&productTitle); err != nil {
+		productTitle = fmt.Sprintf("المزاد #%d", auction.ID)
+	}
+
+	payload := map[string]interface{}{
+		"bidder_name":   bid.BidderNameSnapshot,
+		"auction_id":    fmt.Sprint(auction.ID),
+		"product_title": productTitle,
+		"bid_amount":    fmt.Sprintf("%.2f", bid.Amount),
+		"reason":        reason,
+		"removed_by":    removedBy,
+		"language":      "ar",
+	}
+
 	// Get user email for email notification
 	var userEmail, userName string
 	if err := s.db.QueryRow(ctx, "SELECT email, name FROM users WHERE id = $1", bid.UserID).Scan(&userEmail, &userName); err == nil {
 		payload["email"] = userEmail
 		payload["name"] = userName
-		
-		if _, err := notifications.EnqueueEmail(ctx, bid.UserID, "bid_removed", payload); err != nil {
-			fmt.Printf("Failed to send email notification to bidder %d: %v\n", bid.UserID, err)
-		}
 	}
+	return recipientMessages(bid.UserID, "bid_removed", payload, eventKey)
 }
 
-// notifyAffectedBidders sends notifications to bidders who bid after the removed bid
-func (s *BidManagementService) notifyAffectedBidders(ctx context.Context, removedBid *Bid, auction *Auction, newPrice float64) {
-    // Get product title for context
-    var productTitle string
-    if err := s.db.QueryRow(ctx, "SELECT title FROM products WHERE id = $1", auction.ProductID).Scan(&productTitle); err != nil {
-        productTitle = fmt.Sprintf("المزاد #%d", auction.ID)
-    }
+// affectedBidderMessages notifies bidders who bid after the removed bid
+func (s *BidManagementService) affectedBidderMessages(ctx context.Context, removedBid *Bid, auction *Auction, newPrice float64, eventKey string) []outbox.Message {
+	// Get product title for context
+	var productTitle string
+	if err := s.db.QueryRow(ctx, "SELECT title FROM products WHERE id = $1", auction.ProductID).Scan(&productTitle); err != nil {
+		productTitle = fmt.Sprintf("المزاد #%d", auction.ID)
+	}
 
 	// Get affected bidders (those who bid after the removed bid)
 	query := `
@@ -554,93 +554,35 @@ func (s *BidManagementService) notifyAffectedBidders(ctx context.Context, remove
 		FROM bids b
 		JOIN users u ON b.user_id = u.id
 		WHERE b.auction_id = $1 AND b.created_at > $2 AND b.user_id != $3`
-	
+
 	rows, err := s.db.Query(ctx, query, auction.ID, removedBid.CreatedAt, removedBid.UserID)
 	if err != nil {
 		fmt.Printf("Failed to query affected bidders: %v\n", err)
-		return
+		return nil
 	}
 	defer rows.Close()
-	
-	for rows.Next() {
-		var userID int64
-		var email, name, bidderName string
-		
-		if err := rows.Scan(&userID, &email, &name, &bidderName); err != nil {
-			continue
-		}
-		
-		payload := map[string]interface{}{
-			"bidder_name":  bidderName,
-			"auction_id":   fmt.Sprint(auction.ID),
-			"product_title": productTitle,
-			"new_price":    fmt.Sprintf("%.2f", newPrice),
-			"email":        email,
-			"name":         name,
-			"language":     "ar",
-		}
-		
-		// Send internal notification
-		if _, err := notifications.EnqueueInternal(ctx, userID, "auction_price_changed", payload); err != nil {
-			fmt.Printf("Failed to send internal notification to affected bidder %d: %v\n", userID, err)
-		}
-		
-		// Send email notification
-		if _, err := notifications.EnqueueEmail(ctx, userID, "auction_price_changed", payload); err != nil {
-			fmt.Printf("Failed to send email notification to affected bidder %d: %v\n", userID, err)
-		}
-	}
-}
 
-// notifyAuctionWatchers sends notifications to users watching the auction
-func (s *BidManagementService) notifyAuctionWatchers(ctx context.Context, auction *Auction, newPrice float64, reason string) {
-    // Get product title for context
-    var productTitle string
-    if err := s.db.QueryRow(ctx, "SELECT title FROM products WHERE id = $1", auction.ProductID).Scan(&productTitle); err != nil {
-        productTitle = fmt.Sprintf("المزاد #%d", auction.ID)
-    }
-
-	// Note: In a real implementation, you would have a watchers/followers table
-	// For now, we'll notify recent bidders as they are likely watching
-	query := `
-		SELECT DISTINCT b.user_id, u.email, u.name, b.bidder_name_snapshot
-		FROM bids b
-		JOIN users u ON b.user_id = u.id
-		WHERE b.auction_id = $1 AND b.created_at > NOW() - INTERVAL '24 hours'
-		ORDER BY b.created_at DESC
-		LIMIT 50`
-	
-	rows, err := s.db.Query(ctx, query, auction.ID)
-	if err != nil {
-		fmt.Printf("Failed to query auction watchers: %v\n", err)
-		return
-	}
-	defer rows.Close()
-	
+	var msgs []outbox.Message
 	for rows.Next() {
 		var userID int64
 		var email, name, bidderName string
-		
+
 		if err := rows.Scan(&userID, &email, &name, &bidderName); err != nil {
 			continue
 		}
-		
+
 		payload := map[string]interface{}{
-			"bidder_name":  bidderName,
-			"auction_id":   fmt.Sprint(auction.ID),
+			"bidder_name":   bidderName,
+			"auction_id":    fmt.Sprint(auction.ID),
 			"product_title": productTitle,
-			"new_price":    fmt.Sprintf("%.2f", newPrice),
-			"reason":       reason,
-			"email":        email,
-			"name":         name,
-			"language":     "ar",
-		}
-		
-		// Send internal notification only (avoid spam emails)
-		if _, err := notifications.EnqueueInternal(ctx, userID, "auction_admin_action", payload); err != nil {
-			fmt.Printf("Failed to send internal notification to watcher %d: %v\n", userID, err)
+			"new_price":     fmt.Sprintf("%.2f", newPrice),
+			"email":         email,
+			"name":          name,
+			"language":      "ar",
 		}
+		msgs = append(msgs, recipientMessages(userID, "auction_price_changed", payload, eventKey)...)
 	}
+	return msgs
 }
 
 // Supporting types
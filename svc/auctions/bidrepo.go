@@ -22,8 +22,8 @@ func NewBidRepository(db *sqldb.Database) *BidRepository {
 // CreateBid creates a new bid in the database
 func (r *BidRepository) CreateBid(ctx context.Context, tx *sqldb.Tx, bid *Bid) (*Bid, error) {
 	query := `
-		INSERT INTO bids (auction_id, user_id, amount, bidder_name_snapshot, bidder_city_id_snapshot)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO bids (auction_id, user_id, amount, bidder_name_snapshot, bidder_city_id_snapshot, is_auto)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at`
 
 	err := tx.QueryRow(ctx, query,
@@ -32,6 +32,7 @@ func (r *BidRepository) CreateBid(ctx context.Context, tx *sqldb.Tx, bid *Bid) (
 		bid.Amount,
 		bid.BidderNameSnapshot,
 		bid.BidderCityIDSnapshot,
+		bid.IsAuto,
 	).Scan(&bid.ID, &bid.CreatedAt)
 
 	if err != nil {
@@ -82,7 +83,7 @@ func (r *BidRepository) GetAuctionBidsWithDetails(ctx context.Context, auctionID
 	query := `
 		SELECT 
 			b.id, b.auction_id, b.user_id, b.amount, 
-			b.bidder_name_snapshot, b.bidder_city_id_snapshot, b.created_at,
+			b.bidder_name_snapshot, b.bidder_city_id_snapshot, b.is_auto, b.created_at,
 			c.name_ar as bidder_city_name
 		FROM bids b
 		LEFT JOIN cities c ON c.id = b.bidder_city_id_snapshot
@@ -108,6 +109,7 @@ func (r *BidRepository) GetAuctionBidsWithDetails(ctx context.Context, auctionID
 			&bid.Amount,
 			&bid.BidderNameSnapshot,
 			&bid.BidderCityIDSnapshot,
+			&bid.IsAuto,
 			&bid.CreatedAt,
 			&bidderCityName,
 		)
@@ -373,3 +375,128 @@ func (r *BidRepository) GetWinningBid(ctx context.Context, auctionID int64) (*Bi
 
 	return bid, nil
 }
+
+// GetHighestBidTx retrieves the highest bid for an auction using the provided transaction
+func (r *BidRepository) GetHighestBidTx(ctx context.Context, tx *sqldb.Tx, auctionID int64) (*Bid, error) {
+	query := `
+		SELECT id, auction_id, user_id, amount, bidder_name_snapshot,
+			   bidder_city_id_snapshot, is_auto, created_at
+		FROM bids
+		WHERE auction_id = $1
+		ORDER BY amount DESC, created_at DESC
+		LIMIT 1`
+
+	bid := &Bid{}
+	err := tx.QueryRow(ctx, query, auctionID).Scan(
+		&bid.ID,
+		&bid.AuctionID,
+		&bid.UserID,
+		&bid.Amount,
+		&bid.BidderNameSnapshot,
+		&bid.BidderCityIDSnapshot,
+		&bid.IsAuto,
+		&bid.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // No bids found
+		}
+		return nil, err
+	}
+
+	return bid, nil
+}
+
+// proxyBidColumns lists the auction_proxy_bids columns in scan order
+const proxyBidColumns = `p.id, p.auction_id, p.user_id, p.max_amount, p.status, p.created_at, p.updated_at`
+
+// proxyBidEligibleJoin restricts proxy bids to owners that are still allowed to bid,
+// so an auto-bid never trips the verified-account check in validate_bid_step.
+const proxyBidEligibleJoin = `
+		JOIN users u ON u.id = p.user_id
+		 AND u.state = 'active'
+		 AND u.role IN ('verified','admin')
+		 AND u.email_verified_at IS NOT NULL`
+
+func scanProxyBid(row interface{ Scan(dest ...any) error }) (*ProxyBid, error) {
+	proxy := &ProxyBid{}
+	err := row.Scan(
+		&proxy.ID,
+		&proxy.AuctionID,
+		&proxy.UserID,
+		&proxy.MaxAmount,
+		&proxy.Status,
+		&proxy.CreatedAt,
+		&proxy.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// UpsertProxyBid sets (or raises/lowers) the user's proxy ceiling and re-activates it
+func (r *BidRepository) UpsertProxyBid(ctx context.Context, tx *sqldb.Tx, auctionID, userID int64, maxAmount float64) (*ProxyBid, error) {
+	query := `
+		INSERT INTO auction_proxy_bids AS p (auction_id, user_id, max_amount, status)
+		VALUES ($1, $2, $3, 'active')
+		ON CONFLICT (auction_id, user_id)
+		DO UPDATE SET max_amount = EXCLUDED.max_amount, status = 'active'
+		RETURNING ` + proxyBidColumns
+
+	proxy, err := scanProxyBid(tx.QueryRow(ctx, query, auctionID, userID, maxAmount))
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert proxy bid: %w", err)
+	}
+	return proxy, nil
+}
+
+// GetProxyBid retrieves a user's proxy bid for an auction (any status)
+func (r *BidRepository) GetProxyBid(ctx context.Context, auctionID, userID int64) (*ProxyBid, error) {
+	query := `SELECT ` + proxyBidColumns + ` FROM auction_proxy_bids p WHERE p.auction_id = $1 AND p.user_id = $2`
+	return scanProxyBid(r.db.QueryRow(ctx, query, auctionID, userID))
+}
+
+// GetActiveProxyBidTx retrieves a user's active proxy bid inside the bidding transaction
+func (r *BidRepository) GetActiveProxyBidTx(ctx context.Context, tx *sqldb.Tx, auctionID, userID int64) (*ProxyBid, error) {
+	query := `
+		SELECT ` + proxyBidColumns + `
+		FROM auction_proxy_bids p` + proxyBidEligibleJoin + `
+		WHERE p.auction_id = $1 AND p.user_id = $2 AND p.status = 'active'`
+	return scanProxyBid(tx.QueryRow(ctx, query, auctionID, userID))
+}
+
+// GetStrongestCompetingProxyTx retrieves the highest active proxy ceiling of any user other than
+// excludeUserID that can still reach minAmount. Ties go to the ceiling that was set first.
+func (r *BidRepository) GetStrongestCompetingProxyTx(ctx context.Context, tx *sqldb.Tx, auctionID, excludeUserID int64, minAmount float64) (*ProxyBid, error) {
+	query := `
+		SELECT ` + proxyBidColumns + `
+		FROM auction_proxy_bids p` + proxyBidEligibleJoin + `
+		WHERE p.auction_id = $1 AND p.user_id <> $2 AND p.status = 'active' AND p.max_amount >= $3
+		ORDER BY p.max_amount DESC, p.updated_at ASC
+		LIMIT 1`
+	return scanProxyBid(tx.QueryRow(ctx, query, auctionID, excludeUserID, minAmount))
+}
+
+// UpdateProxyBidStatus updates the status of a proxy bid
+func (r *BidRepository) UpdateProxyBidStatus(ctx context.Context, tx *sqldb.Tx, proxyID int64, status ProxyBidStatus) error {
+	query := `UPDATE auction_proxy_bids SET status = $1 WHERE id = $2`
+	_, err := tx.Exec(ctx, query, status, proxyID)
+	return err
+}
+
+// CancelProxyBid cancels a user's active proxy bid; returns false if none was active
+func (r *BidRepository) CancelProxyBid(ctx context.Context, auctionID, userID int64) (bool, error) {
+	query := `
+		UPDATE auction_proxy_bids SET status = 'cancelled'
+		WHERE auction_id = $1 AND user_id = $2 AND status = 'active'`
+	result, err := r.db.Exec(ctx, query, auctionID, userID)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"encore.app/pkg/audit"
 	"encore.app/pkg/errs"
+	"encore.app/pkg/outbox"
 	"encore.app/svc/notifications"
 	"encore.dev/storage/sqldb"
 )
@@ -30,71 +31,116 @@ func NewBidService(db *sqldb.Database) *BidService {
 
 // PlaceBid places a bid on an auction (Verified users only)
 func (s *BidService) PlaceBid(ctx context.Context, auctionID int64, userID int64, amount float64) (*Bid, error) {
+	return s.placeBid(ctx, auctionID, userID, amount, nil)
+}
+
+// PlaceProxyBid registers (or updates) the user's maximum amount and lets the platform
+// counter-bid on their behalf in bid_step increments up to that ceiling.
+// If amount is zero the opening bid is placed at the minimum required amount.
+func (s *BidService) PlaceProxyBid(ctx context.Context, auctionID int64, userID int64, amount float64, maxAmount float64) (*Bid, error) {
+	return s.placeBid(ctx, auctionID, userID, amount, &maxAmount)
+}
+
+// placeBid places an explicit bid and/or registers a proxy ceiling, then resolves
+// competing proxy ceilings inside the same transaction.
+func (s *BidService) placeBid(ctx context.Context, auctionID int64, userID int64, amount float64, maxAmount *float64) (*Bid, error) {
 	// Validate user permissions (must be verified and email verified)
 	if err := s.validateBidderPermissions(ctx, userID); err != nil {
 		return nil, err
 	}
 
-    // Check rate limiting via RateLimitService (early reject before locking)
-    rl := NewRateLimitService(s.db)
-    if err := rl.CheckBidRateLimit(ctx, userID); err != nil {
-        return nil, err
-    }
-
-    // Start transaction for bid placement and lock auction row to prevent races
-    tx, err := s.db.Begin(ctx)
-    if err != nil {
-        return nil, fmt.Errorf("failed to start transaction: %w", err)
-    }
-    defer tx.Rollback()
+	if amount <= 0 && maxAmount == nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: "مبلغ المزايدة مطلوب",
+		}
+	}
 
-    // Get auction details with row lock inside the transaction
-    auction, err := s.getAuctionForBiddingTx(ctx, tx, auctionID)
-    if err != nil {
-        return nil, err
-    }
+	// Check rate limiting via RateLimitService (early reject before locking)
+	rl := NewRateLimitService(s.db)
+	if err := rl.CheckBidRateLimit(ctx, userID); err != nil {
+		return nil, err
+	}
 
-    // Validate auction state
-    if err := s.validateAuctionForBidding(auction); err != nil {
-        return nil, err
-    }
+	// Start transaction for bid placement and lock auction row to prevent races
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-    // Get current highest bid inside the same transaction
-    currentPrice, err := s.getCurrentPriceTx(ctx, tx, auctionID, auction.StartPrice)
-    if err != nil {
-        return nil, err
-    }
+	// Get auction details with row lock inside the transaction
+	auction, err := s.getAuctionForBiddingTx(ctx, tx, auctionID)
+	if err != nil {
+		return nil, err
+	}
 
-    // Validate bid amount
-    if err := s.validateBidAmount(amount, currentPrice, auction.BidStep); err != nil {
-        return nil, err
-    }
+	// Validate auction state
+	if err := s.validateAuctionForBidding(auction); err != nil {
+		return nil, err
+	}
 
-	// Get user snapshot data
-	bidderName, bidderCityID, err := s.getUserSnapshotData(ctx, userID)
+	// Get current highest bid inside the same transaction
+	currentPrice, err := s.getCurrentPriceTx(ctx, tx, auctionID, auction.StartPrice)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user data: %w", err)
+		return nil, err
+	}
+
+	var placedBids []*Bid
+	extended := false
+
+	if amount > 0 {
+		// Validate bid amount
+		if err := s.validateBidAmount(amount, currentPrice, auction.BidStep); err != nil {
+			return nil, err
+		}
+
+		// Get user snapshot data
+		bidderName, bidderCityID, err := s.getUserSnapshotData(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user data: %w", err)
+		}
+
+		// Create bid
+		bid := &Bid{
+			AuctionID:            auctionID,
+			UserID:               userID,
+			Amount:               amount,
+			BidderNameSnapshot:   bidderName,
+			BidderCityIDSnapshot: bidderCityID,
+		}
+
+		// Insert bid
+		createdBid, err := s.repo.CreateBid(ctx, tx, bid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bid: %w", err)
+		}
+		placedBids = append(placedBids, createdBid)
+
+		// Handle anti-sniping if needed
+		extended, err = s.handleAntiSniping(ctx, tx, auction, createdBid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle anti-sniping: %w", err)
+		}
 	}
 
-	// Create bid
-	bid := &Bid{
-		AuctionID:            auctionID,
-		UserID:               userID,
-		Amount:               amount,
-		BidderNameSnapshot:   bidderName,
-		BidderCityIDSnapshot: bidderCityID,
+	if maxAmount != nil {
+		if err := s.registerProxyBid(ctx, tx, auction, userID, amount, *maxAmount); err != nil {
+			return nil, err
+		}
 	}
 
-	// Insert bid
-	createdBid, err := s.repo.CreateBid(ctx, tx, bid)
+	// Let competing proxy ceilings counter-bid until no one can top the price
+	autoBids, err := s.resolveProxyBids(ctx, tx, auction)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create bid: %w", err)
+		return nil, err
 	}
+	placedBids = append(placedBids, autoBids...)
 
-	// Handle anti-sniping if needed
-	extended, err := s.handleAntiSniping(ctx, tx, auction, createdBid)
-	if err != nil {
-		return nil, fmt.Errorf("failed to handle anti-sniping: %w", err)
+	// Auto-bids go through the handle_anti_sniping trigger as well; detect any extension
+	var endAtAfter time.Time
+	if err := tx.QueryRow(ctx, `SELECT end_at FROM auctions WHERE id = $1`, auctionID).Scan(&endAtAfter); err == nil && endAtAfter.After(auction.EndAt) {
+		extended = true
 	}
 
 	// Commit transaction
@@ -102,9 +148,33 @@ func (s *BidService) PlaceBid(ctx context.Context, auctionID int64, userID int64
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	// Determine the bid to return to the caller: their latest bid placed in this call,
+	// or their standing leading bid when only the ceiling was raised
+	var userBid *Bid
+	for _, b := range placedBids {
+		if b.UserID == userID {
+			userBid = b
+		}
+	}
+	if userBid == nil {
+		leader, err := s.repo.GetHighestBid(ctx, auctionID)
+		if err != nil || leader == nil {
+			return nil, &errs.Error{
+				Code:    errs.Internal,
+				Message: "تعذر تحديد المزايدة الحالية",
+			}
+		}
+		userBid = leader
+	}
+
+	if len(placedBids) == 0 {
+		return userBid, nil
+	}
+
 	// Determine current price and outbid users
-	currentPrice = createdBid.Amount
-	outbidUsers, err := s.repo.GetOutbidUsers(ctx, auctionID, createdBid.Amount, userID)
+	finalBid := placedBids[len(placedBids)-1]
+	currentPrice = finalBid.Amount
+	outbidUsers, err := s.repo.GetOutbidUsers(ctx, auctionID, finalBid.Amount, finalBid.UserID)
 	if err == nil && len(outbidUsers) > 0 {
 		// Enqueue internal notifications for outbid users so they appear in inbox regardless of realtime
 		var productTitle string
@@ -152,17 +222,23 @@ func (s *BidService) PlaceBid(ctx context.Context, auctionID int64, userID int64
 		}
 	}
 
+	// Notify watchers who are not bidding about the new price
+	NewWatchlistService(s.db).NotifyPriceChanged(ctx, auction, currentPrice, "bid_placed", finalBid.UserID, outbox.Key("bid", finalBid.ID, "placed"))
+
 	// Broadcast bid_placed event
 	realtimeService := GetRealtimeService()
 	if realtimeService != nil {
-		// Convert Bid to BidWithDetails for broadcasting
-		bidWithDetails := &BidWithDetails{
-			Bid: *createdBid,
-		}
+		// Broadcast every bid placed in this call (explicit and automatic) in order
+		for _, b := range placedBids {
+			// Convert Bid to BidWithDetails for broadcasting
+			bidWithDetails := &BidWithDetails{
+				Bid: *b,
+			}
 
-		if err := realtimeService.BroadcastBidPlaced(ctx, auctionID, bidWithDetails, currentPrice); err != nil {
-			// Log error but don't fail the bid
-			fmt.Printf("Failed to broadcast bid_placed event: %v\n", err)
+			if err := realtimeService.BroadcastBidPlaced(ctx, auctionID, bidWithDetails, b.Amount); err != nil {
+				// Log error but don't fail the bid
+				fmt.Printf("Failed to broadcast bid_placed event: %v\n", err)
+			}
 		}
 
 		// Send outbid realtime events to previous bidders (if any)
@@ -186,10 +262,12 @@ func (s *BidService) PlaceBid(ctx context.Context, auctionID int64, userID int64
 	}
 
 	// Send audit notification for bid placement
-	fmt.Printf("[AUDIT] BID.PLACED - Auction %d: Bid %d by User %d for %.2f\n",
-		auctionID, createdBid.ID, userID, amount)
+	for _, b := range placedBids {
+		fmt.Printf("[AUDIT] BID.PLACED - Auction %d: Bid %d by User %d for %.2f (auto=%t)\n",
+			auctionID, b.ID, b.UserID, b.Amount, b.IsAuto)
+	}
 
-	return createdBid, nil
+	return userBid, nil
 }
 
 // GetAuctionBids retrieves bids for an auction with pagination
@@ -257,10 +335,16 @@ func (s *BidService) RemoveBid(ctx context.Context, bidID int64, reason string,
 		return fmt.Errorf("failed to recalculate end time: %w", err)
 	}
 
+	// Queue the bidder's notification with the removal
+	if err := outbox.Add(ctx, tx, s.bidRemovedMessages(ctx, bid, auction, reason, removedBy)...); err != nil {
+		return fmt.Errorf("failed to queue removal notification: %w", err)
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	notifications.KickOutbox()
 
 	// Broadcast bid_removed event
 	realtimeService := GetRealtimeService()
@@ -300,19 +384,16 @@ func (s *BidService) RemoveBid(ctx context.Context, bidID int64, reason string,
 		fmt.Printf("Failed to log audit entry for bid removal: %v\n", err)
 	}
 
-	// Send notification to bidder about removal
-	go s.sendBidRemovedNotification(ctx, bid, auction, reason, removedBy)
-
 	return nil
 }
 
-// sendBidRemovedNotification sends notifications when a bid is removed
-func (s *BidService) sendBidRemovedNotification(ctx context.Context, bid *Bid, auction *Auction, reason string, removedBy int64) {
+// bidRemovedMessages builds the notifications sent when a bid is removed
+func (s *BidService) bidRemovedMessages(ctx context.Context, bid *Bid, auction *Auction, reason string, removedBy int64) []outbox.Message {
 	// Get user info for notification
 	var userEmail, userName string
 	if err := s.db.QueryRow(ctx, "SELECT email, name FROM users WHERE id = $1", bid.UserID).Scan(&userEmail, &userName); err != nil {
 		fmt.Printf("Failed to get user info for notification: %v\n", err)
-		return
+		return nil
 	}
 
 	// Get product title for context
@@ -332,16 +413,7 @@ func (s *BidService) sendBidRemovedNotification(ctx context.Context, bid *Bid, a
 		"name":          userName,
 		"language":      "ar",
 	}
-
-	// Send internal notification
-	if _, err := notifications.EnqueueInternal(ctx, bid.UserID, "bid_removed", payload); err != nil {
-		fmt.Printf("Failed to send internal notification: %v\n", err)
-	}
-
-	// Send email notification
-	if _, err := notifications.EnqueueEmail(ctx, bid.UserID, "bid_removed", payload); err != nil {
-		fmt.Printf("Failed to send email notification: %v\n", err)
-	}
+	return recipientMessages(bid.UserID, "bid_removed", payload, outbox.Key("bid", bid.ID, "removed"))
 }
 
 // validateBidderPermissions validates that user can place bids
@@ -2,6 +2,8 @@ package auctions
 
 import (
 	"time"
+
+	"encore.app/pkg/pedigree"
 )
 
 // CreateAuctionDTO represents the data transfer object for creating an auction
@@ -16,9 +18,12 @@ type CreateAuctionDTO struct {
 	MaxExtensionsOverride *int      `json:"max_extensions_override,omitempty" validate:"omitempty,gte=0"`
 }
 
-// PlaceBidDTO represents the data transfer object for placing a bid
+// PlaceBidDTO represents the data transfer object for placing a bid.
+// When MaxAmount is set the platform counter-bids on the user's behalf up to that ceiling;
+// Amount may then be omitted to open at the minimum required bid.
 type PlaceBidDTO struct {
-	Amount float64 `json:"amount" validate:"required,gt=0"`
+	Amount    float64  `json:"amount" validate:"omitempty,gte=0"`
+	MaxAmount *float64 `json:"max_amount,omitempty" validate:"omitempty,gt=0"`
 }
 
 // CancelAuctionDTO represents the data transfer object for canceling an auction
@@ -81,6 +86,7 @@ type BidResponseDTO struct {
 	Amount             float64   `json:"amount"`
 	BidderNameSnapshot string    `json:"bidder_name"`
 	BidderCityName     *string   `json:"bidder_city,omitempty"`
+	IsAuto             bool      `json:"is_auto"`
 	CreatedAt          time.Time `json:"created_at"`
 }
 
@@ -231,6 +237,7 @@ func ToBidResponse(bid *BidWithDetails) *BidResponseDTO {
 		Amount:             bid.Amount,
 		BidderNameSnapshot: bid.BidderNameSnapshot,
 		BidderCityName:     bid.BidderCityName,
+		IsAuto:             bid.IsAuto,
 		CreatedAt:          bid.CreatedAt,
 	}
 }
@@ -273,7 +280,8 @@ func ToCreateAuctionRequest(dto *CreateAuctionDTO) *CreateAuctionRequest {
 // ToPlaceBidRequest converts PlaceBidDTO to PlaceBidRequest
 func ToPlaceBidRequest(dto *PlaceBidDTO) *PlaceBidRequest {
 	return &PlaceBidRequest{
-		Amount: dto.Amount,
+		Amount:    dto.Amount,
+		MaxAmount: dto.MaxAmount,
 	}
 }
 
@@ -394,12 +402,20 @@ type AuctionListResponse struct {
 	Limit    int                `json:"limit"`
 }
 
+// WatchlistResponse represents the auctions a user is watching
+type WatchlistResponse struct {
+	Auctions []*AuctionResponse `json:"auctions"`
+	Total    int                `json:"total"`
+}
+
 // AuctionDetailResponse represents detailed auction with bids
 type AuctionDetailResponse struct {
 	Auction       *AuctionResponse       `json:"auction"`
 	Bids          []*BidResponse         `json:"bids"`
 	BidCount      int                    `json:"bid_count"`
 	ReserveStatus *ReserveStatusResponse `json:"reserve_status,omitempty"`
+	// Pedigree of the auctioned pigeon (bird, parents, grandparents) when one is recorded
+	Pedigree []pedigree.Entry `json:"pedigree,omitempty"`
 }
 
 // BidResponse represents bid data in API responses
@@ -410,9 +426,39 @@ type BidResponse struct {
 	Amount             float64   `json:"amount"`
 	BidderNameSnapshot string    `json:"bidder_name"`
 	BidderCityName     *string   `json:"bidder_city,omitempty"`
+	IsAuto             bool      `json:"is_auto"`
+	IsHighest          *bool     `json:"is_highest,omitempty"`
+	MaxAmount          *float64  `json:"max_amount,omitempty"`
 	CreatedAt          time.Time `json:"created_at"`
 }
 
+// ProxyBidResponse represents the caller's proxy (automatic max) bid on an auction
+type ProxyBidResponse struct {
+	AuctionID int64     `json:"auction_id"`
+	MaxAmount float64   `json:"max_amount"`
+	Status    string    `json:"status"`
+	IsHighest bool      `json:"is_highest"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FailurePolicyDTO represents request to set an auction's failure policy (Admin only)
+type FailurePolicyDTO struct {
+	Action                string   `json:"action" validate:"required,oneof=none relist second_chance"`
+	RelistDelayMinutes    *int     `json:"relist_delay_minutes,omitempty" validate:"omitempty,gte=0"`
+	RelistDurationMinutes *int     `json:"relist_duration_minutes,omitempty" validate:"omitempty,gt=0"`
+	RelistStartPrice      *float64 `json:"relist_start_price,omitempty" validate:"omitempty,gt=0"`
+	RelistReservePrice    *float64 `json:"relist_reserve_price,omitempty" validate:"omitempty,gt=0"`
+	RelistClearReserve    bool     `json:"relist_clear_reserve"`
+	MaxRelists            *int     `json:"max_relists,omitempty" validate:"omitempty,gte=0"`
+	OfferWindowHours      *int     `json:"offer_window_hours,omitempty" validate:"omitempty,gt=0"`
+}
+
+// SecondChanceOffersResponse lists the caller's second-chance offers
+type SecondChanceOffersResponse struct {
+	Offers []*SecondChanceOffer `json:"offers"`
+	Total  int                  `json:"total"`
+}
+
 // MarkWinnerUnpaidDTO represents request to mark winner as unpaid
 type MarkWinnerUnpaidDTO struct {
 	Reason string `json:"reason" validate:"required,min=5,max=500"`
@@ -497,6 +543,7 @@ func ToSimpleBidResponse(bid *Bid) *BidResponse {
 		Amount:             bid.Amount,
 		BidderNameSnapshot: bid.BidderNameSnapshot,
 		BidderCityName:     nil, // Will be populated from BidWithDetails if available
+		IsAuto:             bid.IsAuto,
 		CreatedAt:          bid.CreatedAt,
 	}
 }
@@ -0,0 +1,147 @@
+package auctions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"encore.dev/storage/sqldb"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// auctionEventsChannel is the Postgres NOTIFY channel used to fan events out across instances
+const auctionEventsChannel = "auction_events"
+
+// AuctionEventMessage is an auction event as it travels between instances
+type AuctionEventMessage struct {
+	Origin    string        `json:"origin"` // hub instance that published the event
+	AuctionID int64         `json:"auction_id"`
+	UserIDs   []int64       `json:"user_ids,omitempty"` // restrict delivery to these users (e.g. outbid)
+	Event     *AuctionEvent `json:"event"`
+}
+
+// AuctionEventBus delivers auction events to the hubs of every running instance
+type AuctionEventBus interface {
+	Publish(ctx context.Context, msg *AuctionEventMessage) error
+	Subscribe(handler func(msg *AuctionEventMessage))
+}
+
+// LocalEventBus is an in-process bus; every subscribed hub receives every published message.
+// Used by tests and when no database is available.
+type LocalEventBus struct {
+	mu       sync.RWMutex
+	handlers []func(msg *AuctionEventMessage)
+}
+
+// NewLocalEventBus creates an in-process event bus
+func NewLocalEventBus() *LocalEventBus {
+	return &LocalEventBus{}
+}
+
+// Publish delivers the message synchronously to all subscribers
+func (b *LocalEventBus) Publish(ctx context.Context, msg *AuctionEventMessage) error {
+	b.mu.RLock()
+	handlers := append([]func(msg *AuctionEventMessage){}, b.handlers...)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(msg)
+	}
+	return nil
+}
+
+// Subscribe registers a handler for published messages
+func (b *LocalEventBus) Subscribe(handler func(msg *AuctionEventMessage)) {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+}
+
+// PostgresEventBus fans events out with LISTEN/NOTIFY. Unlike a Pub/Sub subscription (one
+// consumer per message) every instance holding a LISTEN connection receives every event.
+type PostgresEventBus struct {
+	db       *sqldb.Database
+	mu       sync.RWMutex
+	handlers []func(msg *AuctionEventMessage)
+	once     sync.Once
+}
+
+// NewPostgresEventBus creates an event bus on top of the given database
+func NewPostgresEventBus(db *sqldb.Database) *PostgresEventBus {
+	return &PostgresEventBus{db: db}
+}
+
+// Publish sends the message to all listening instances
+func (b *PostgresEventBus) Publish(ctx context.Context, msg *AuctionEventMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal auction event: %w", err)
+	}
+	// NOTIFY payloads are limited to 8000 bytes; events are small JSON objects
+	if _, err := b.db.Exec(ctx, `SELECT pg_notify($1, $2)`, auctionEventsChannel, string(payload)); err != nil {
+		return fmt.Errorf("failed to publish auction event: %w", err)
+	}
+	return nil
+}
+
+// Subscribe registers a handler and starts the listener on first use
+func (b *PostgresEventBus) Subscribe(handler func(msg *AuctionEventMessage)) {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+
+	b.once.Do(func() {
+		go b.listen()
+	})
+}
+
+// listen holds a dedicated connection on the channel and reconnects on failure
+func (b *PostgresEventBus) listen() {
+	backoff := time.Second
+	for {
+		if err := b.listenOnce(context.Background()); err != nil {
+			log.Printf("[REALTIME] event bus listener error: %v (retrying in %s)", err, backoff)
+		}
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (b *PostgresEventBus) listenOnce(ctx context.Context) error {
+	pool := sqldb.Driver[*pgxpool.Pool](b.db)
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire listener connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+auctionEventsChannel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", auctionEventsChannel, err)
+	}
+	log.Printf("[REALTIME] listening on channel %s", auctionEventsChannel)
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for notification: %w", err)
+		}
+
+		var msg AuctionEventMessage
+		if err := json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+			log.Printf("[REALTIME] dropping malformed auction event: %v", err)
+			continue
+		}
+
+		b.mu.RLock()
+		handlers := append([]func(msg *AuctionEventMessage){}, b.handlers...)
+		b.mu.RUnlock()
+		for _, h := range handlers {
+			h(&msg)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package auctions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"encore.dev/storage/sqldb"
+)
+
+const (
+	// auctionEventLogSize is how many recent events are kept per auction for replay
+	auctionEventLogSize = 500
+	// auctionEventPruneEvery controls how often (in events) old entries are pruned
+	auctionEventPruneEvery = 50
+)
+
+// AuctionEventLog assigns per-auction sequence numbers to events and keeps a bounded
+// history so reconnecting clients can replay what they missed.
+type AuctionEventLog interface {
+	// Append stores the event and returns its sequence number within the auction
+	Append(ctx context.Context, auctionID int64, userIDs []int64, event *AuctionEvent) (int64, error)
+	// Since returns events after afterID visible to userID (nil = anonymous). truncated is true
+	// when events after afterID were already pruned and the client must refetch state.
+	Since(ctx context.Context, auctionID, afterID int64, userID *int64) (events []*AuctionEvent, truncated bool, err error)
+}
+
+// PostgresEventLog stores the event history in auction_event_log so every instance shares
+// the same sequence.
+type PostgresEventLog struct {
+	db *sqldb.Database
+}
+
+// NewPostgresEventLog creates an event log backed by the given database
+func NewPostgresEventLog(db *sqldb.Database) *PostgresEventLog {
+	return &PostgresEventLog{db: db}
+}
+
+// Append stores the event under the next sequence number for the auction
+func (l *PostgresEventLog) Append(ctx context.Context, auctionID int64, userIDs []int64, event *AuctionEvent) (int64, error) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	var seq int64
+	err = l.db.QueryRow(ctx, `
+		WITH next AS (
+			INSERT INTO auction_event_sequences (auction_id, last_seq)
+			VALUES ($1, 1)
+			ON CONFLICT (auction_id) DO UPDATE SET last_seq = auction_event_sequences.last_seq + 1
+			RETURNING last_seq
+		)
+		INSERT INTO auction_event_log (auction_id, seq, event_type, data, user_ids)
+		SELECT $1, last_seq, $2, $3, $4 FROM next
+		RETURNING seq`, auctionID, string(event.EventType), data, userIDs).Scan(&seq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to append auction event: %w", err)
+	}
+
+	if seq%auctionEventPruneEvery == 0 {
+		if _, err := l.db.Exec(ctx, `DELETE FROM auction_event_log WHERE auction_id = $1 AND seq <= $2`,
+			auctionID, seq-auctionEventLogSize); err != nil {
+			fmt.Printf("Failed to prune auction event log for auction %d: %v\n", auctionID, err)
+		}
+	}
+
+	return seq, nil
+}
+
+// Since returns the retained events after afterID in sequence order
+func (l *PostgresEventLog) Since(ctx context.Context, auctionID, afterID int64, userID *int64) ([]*AuctionEvent, bool, error) {
+	var oldest *int64
+	if err := l.db.QueryRow(ctx, `SELECT MIN(seq) FROM auction_event_log WHERE auction_id = $1`, auctionID).Scan(&oldest); err != nil {
+		return nil, false, fmt.Errorf("failed to read auction event log: %w", err)
+	}
+	truncated := oldest != nil && afterID < *oldest-1
+
+	rows, err := l.db.Query(ctx, `
+		SELECT seq, event_type, data
+		FROM auction_event_log
+		WHERE auction_id = $1
+		  AND seq > $2
+		  AND (user_ids IS NULL OR $3::BIGINT = ANY(user_ids))
+		ORDER BY seq ASC
+		LIMIT $4`, auctionID, afterID, userID, auctionEventLogSize)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query auction event log: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*AuctionEvent
+	for rows.Next() {
+		var (
+			seq       int64
+			eventType string
+			data      []byte
+		)
+		if err := rows.Scan(&seq, &eventType, &data); err != nil {
+			return nil, false, fmt.Errorf("failed to scan auction event: %w", err)
+		}
+		events = append(events, &AuctionEvent{
+			ID:        seq,
+			EventType: EventType(eventType),
+			Data:      json.RawMessage(data),
+		})
+	}
+	return events, truncated, nil
+}
+
+// MemoryEventLog is an in-process event log used by tests and single-instance setups
+type MemoryEventLog struct {
+	mu      sync.Mutex
+	size    int
+	lastSeq map[int64]int64
+	entries map[int64][]memoryLogEntry
+}
+
+type memoryLogEntry struct {
+	userIDs []int64
+	event   *AuctionEvent
+}
+
+// NewMemoryEventLog creates an in-process log keeping at most size events per auction
+func NewMemoryEventLog(size int) *MemoryEventLog {
+	if size <= 0 {
+		size = auctionEventLogSize
+	}
+	return &MemoryEventLog{
+		size:    size,
+		lastSeq: make(map[int64]int64),
+		entries: make(map[int64][]memoryLogEntry),
+	}
+}
+
+// Append stores the event under the next sequence number for the auction
+func (l *MemoryEventLog) Append(ctx context.Context, auctionID int64, userIDs []int64, event *AuctionEvent) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastSeq[auctionID]++
+	seq := l.lastSeq[auctionID]
+
+	stored := *event
+	stored.ID = seq
+	entries := append(l.entries[auctionID], memoryLogEntry{userIDs: userIDs, event: &stored})
+	if len(entries) > l.size {
+		entries = entries[len(entries)-l.size:]
+	}
+	l.entries[auctionID] = entries
+
+	return seq, nil
+}
+
+// Since returns the retained events after afterID in sequence order
+func (l *MemoryEventLog) Since(ctx context.Context, auctionID, afterID int64, userID *int64) ([]*AuctionEvent, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := l.entries[auctionID]
+	truncated := len(entries) > 0 && afterID < entries[0].event.ID-1
+
+	var events []*AuctionEvent
+	for _, e := range entries {
+		if e.event.ID <= afterID || !eventVisibleTo(e.userIDs, userID) {
+			continue
+		}
+		events = append(events, e.event)
+	}
+	return events, truncated, nil
+}
+
+// eventVisibleTo reports whether a (possibly user-targeted) event may be shown to userID
+func eventVisibleTo(userIDs []int64, userID *int64) bool {
+	if userIDs == nil {
+		return true
+	}
+	if userID == nil {
+		return false
+	}
+	for _, id := range userIDs {
+		if id == *userID {
+			return true
+		}
+	}
+	return false
+}
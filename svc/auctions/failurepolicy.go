@@ -0,0 +1,599 @@
+package auctions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/pkg/audit"
+	"encore.app/pkg/errs"
+	"encore.app/svc/notifications"
+	"encore.app/svc/orders/order_mgmt"
+	"encore.dev/storage/sqldb"
+)
+
+// Failure reasons that trigger the auction's failure policy
+const (
+	FailureReasonReserveNotMet = "reserve_not_met"
+	FailureReasonWinnerUnpaid  = "winner_unpaid"
+)
+
+// FailurePolicyService applies per-auction policies when an auction fails: relist the item
+// on a new schedule, or offer it to runner-up bidders one at a time.
+type FailurePolicyService struct {
+	db   *sqldb.Database
+	repo *Repository
+}
+
+// NewFailurePolicyService creates a new failure policy service
+func NewFailurePolicyService(db *sqldb.Database) *FailurePolicyService {
+	return &FailurePolicyService{
+		db:   db,
+		repo: NewRepository(db),
+	}
+}
+
+// defaultFailurePolicy is used for auctions without a stored policy
+func defaultFailurePolicy(auctionID int64) *FailurePolicy {
+	return &FailurePolicy{
+		AuctionID:             auctionID,
+		Action:                FailureActionNone,
+		RelistDelayMinutes:    60,
+		RelistDurationMinutes: 1440,
+		MaxRelists:            1,
+		OfferWindowHours:      24,
+	}
+}
+
+// GetPolicy returns the auction's failure policy (action "none" when not configured)
+func (s *FailurePolicyService) GetPolicy(ctx context.Context, auctionID int64) (*FailurePolicy, error) {
+	p := &FailurePolicy{}
+	err := s.db.QueryRow(ctx, `
+		SELECT auction_id, action, relist_delay_minutes, relist_duration_minutes,
+		       relist_start_price, relist_reserve_price, relist_clear_reserve,
+		       max_relists, offer_window_hours, created_at, updated_at
+		FROM auction_failure_policies
+		WHERE auction_id = $1`, auctionID).Scan(
+		&p.AuctionID, &p.Action, &p.RelistDelayMinutes, &p.RelistDurationMinutes,
+		&p.RelistStartPrice, &p.RelistReservePrice, &p.RelistClearReserve,
+		&p.MaxRelists, &p.OfferWindowHours, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return defaultFailurePolicy(auctionID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failure policy: %w", err)
+	}
+	return p, nil
+}
+
+// SetPolicy creates or replaces the auction's failure policy
+func (s *FailurePolicyService) SetPolicy(ctx context.Context, auctionID int64, req *FailurePolicyDTO) (*FailurePolicy, error) {
+	auction, err := s.repo.GetAuction(ctx, auctionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &errs.Error{Code: errs.NotFound, Message: "المزاد غير موجود"}
+		}
+		return nil, fmt.Errorf("failed to get auction: %w", err)
+	}
+	if auction.Status == AuctionStatusCancelled {
+		return nil, &errs.Error{Code: errs.FailedPrecondition, Message: "لا يمكن تعديل سياسة مزاد ملغى"}
+	}
+
+	p := defaultFailurePolicy(auctionID)
+	switch FailureAction(req.Action) {
+	case FailureActionNone, FailureActionRelist, FailureActionSecondChance:
+		p.Action = FailureAction(req.Action)
+	default:
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "إجراء السياسة غير صحيح"}
+	}
+	if req.RelistDelayMinutes != nil {
+		p.RelistDelayMinutes = *req.RelistDelayMinutes
+	}
+	if req.RelistDurationMinutes != nil {
+		p.RelistDurationMinutes = *req.RelistDurationMinutes
+	}
+	if req.MaxRelists != nil {
+		p.MaxRelists = *req.MaxRelists
+	}
+	if req.OfferWindowHours != nil {
+		p.OfferWindowHours = *req.OfferWindowHours
+	}
+	p.RelistStartPrice = req.RelistStartPrice
+	p.RelistReservePrice = req.RelistReservePrice
+	p.RelistClearReserve = req.RelistClearReserve
+
+	if p.RelistDelayMinutes < 0 || p.RelistDurationMinutes <= 0 || p.MaxRelists < 0 || p.OfferWindowHours <= 0 {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "قيم جدول إعادة الطرح أو نافذة القبول غير صحيحة"}
+	}
+	if p.RelistClearReserve && p.RelistReservePrice != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "لا يمكن تحديد سعر احتياطي مع إلغاء الاحتياطي"}
+	}
+
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO auction_failure_policies (
+			auction_id, action, relist_delay_minutes, relist_duration_minutes,
+			relist_start_price, relist_reserve_price, relist_clear_reserve,
+			max_relists, offer_window_hours
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (auction_id) DO UPDATE SET
+			action = EXCLUDED.action,
+			relist_delay_minutes = EXCLUDED.relist_delay_minutes,
+			relist_duration_minutes = EXCLUDED.relist_duration_minutes,
+			relist_start_price = EXCLUDED.relist_start_price,
+			relist_reserve_price = EXCLUDED.relist_reserve_price,
+			relist_clear_reserve = EXCLUDED.relist_clear_reserve,
+			max_relists = EXCLUDED.max_relists,
+			offer_window_hours = EXCLUDED.offer_window_hours
+		RETURNING created_at, updated_at`,
+		auctionID, p.Action, p.RelistDelayMinutes, p.RelistDurationMinutes,
+		p.RelistStartPrice, p.RelistReservePrice, p.RelistClearReserve,
+		p.MaxRelists, p.OfferWindowHours,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save failure policy: %w", err)
+	}
+
+	_, _ = audit.LogAction(ctx, s.db, "AUC.FAILURE_POLICY_SET", "auction", fmt.Sprint(auctionID), map[string]interface{}{
+		"action":      p.Action,
+		"max_relists": p.MaxRelists,
+	}, audit.InferActorFromAuth())
+
+	return p, nil
+}
+
+// HandleFailedAuction applies the auction's policy after it ended with reserve not met or an
+// unpaid winner. Safe to call more than once for the same auction.
+func (s *FailurePolicyService) HandleFailedAuction(ctx context.Context, auctionID int64, reason string) error {
+	policy, err := s.GetPolicy(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	switch policy.Action {
+	case FailureActionRelist:
+		return s.relist(ctx, auctionID, policy, reason)
+	case FailureActionSecondChance:
+		return s.offerNext(ctx, auctionID, policy, reason)
+	}
+	return nil
+}
+
+// relist creates a new auction for the same product using the policy's schedule and pricing
+func (s *FailurePolicyService) relist(ctx context.Context, auctionID int64, policy *FailurePolicy, reason string) error {
+	auction, err := s.repo.GetAuction(ctx, auctionID)
+	if err != nil {
+		return fmt.Errorf("failed to get auction: %w", err)
+	}
+
+	// Lock the source auction until the new one is linked so concurrent failure handlers
+	// (tick and unpaid-winner sweep) cannot both relist it
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var relistCount int
+	var alreadyRelisted bool
+	if err := tx.QueryRow(ctx, `
+		SELECT relist_count, EXISTS (SELECT 1 FROM auctions WHERE relisted_from_auction_id = $1)
+		FROM auctions WHERE id = $1
+		FOR UPDATE`, auctionID).Scan(&relistCount, &alreadyRelisted); err != nil {
+		return fmt.Errorf("failed to read relist state: %w", err)
+	}
+	if alreadyRelisted {
+		return nil
+	}
+	if relistCount >= policy.MaxRelists {
+		fmt.Printf("[AUCTION_RELIST] auction %d reached max relists (%d), skipping\n", auctionID, policy.MaxRelists)
+		return nil
+	}
+
+	startPrice := auction.StartPrice
+	if policy.RelistStartPrice != nil {
+		startPrice = *policy.RelistStartPrice
+	}
+	reservePrice := auction.ReservePrice
+	if policy.RelistClearReserve {
+		reservePrice = nil
+	} else if policy.RelistReservePrice != nil {
+		reservePrice = policy.RelistReservePrice
+	}
+	if reservePrice != nil && *reservePrice < startPrice {
+		// A lowered start price must not leave the old reserve below it
+		reservePrice = nil
+	}
+
+	startAt := time.Now().UTC().Add(time.Duration(policy.RelistDelayMinutes) * time.Minute)
+	antiSniping := auction.AntiSnipingMinutes
+	relisted, err := GetService().CreateAuction(ctx, &CreateAuctionRequest{
+		ProductID:             auction.ProductID,
+		StartPrice:            startPrice,
+		BidStep:               auction.BidStep,
+		ReservePrice:          reservePrice,
+		StartAt:               startAt,
+		EndAt:                 startAt.Add(time.Duration(policy.RelistDurationMinutes) * time.Minute),
+		AntiSnipingMinutes:    &antiSniping,
+		MaxExtensionsOverride: auction.MaxExtensionsOverride,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to relist auction %d: %w", auctionID, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE auctions SET relisted_from_auction_id = $2, relist_count = $3
+		WHERE id = $1`, relisted.ID, auctionID, relistCount+1)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		// Unlinked, the new auction would let the next run relist again: withdraw it
+		if _, cerr := s.db.Exec(ctx, `UPDATE auctions SET status = 'cancelled' WHERE id = $1`, relisted.ID); cerr != nil {
+			fmt.Printf("Failed to cancel unlinked relisted auction %d: %v\n", relisted.ID, cerr)
+		}
+		return fmt.Errorf("failed to link relisted auction: %w", err)
+	}
+	// The new auction inherits the policy so it can be relisted again up to max_relists
+	if _, err := s.db.Exec(ctx, `
+		INSERT INTO auction_failure_policies (
+			auction_id, action, relist_delay_minutes, relist_duration_minutes,
+			relist_start_price, relist_reserve_price, relist_clear_reserve,
+			max_relists, offer_window_hours
+		)
+		SELECT $2, action, relist_delay_minutes, relist_duration_minutes,
+		       relist_start_price, relist_reserve_price, relist_clear_reserve,
+		       max_relists, offer_window_hours
+		FROM auction_failure_policies WHERE auction_id = $1
+		ON CONFLICT (auction_id) DO NOTHING`, auctionID, relisted.ID); err != nil {
+		fmt.Printf("Failed to copy failure policy to relisted auction %d: %v\n", relisted.ID, err)
+	}
+
+	_, _ = audit.LogAction(ctx, s.db, "AUC.RELISTED", "auction", fmt.Sprint(auctionID), map[string]interface{}{
+		"reason":         reason,
+		"product_id":     auction.ProductID,
+		"new_auction_id": relisted.ID,
+		"relist_count":   relistCount + 1,
+		"start_price":    startPrice,
+		"reserve_price":  reservePrice,
+		"start_at":       relisted.StartAt,
+		"end_at":         relisted.EndAt,
+	})
+	fmt.Printf("[AUDIT] AUC.RELISTED - Auction %d relisted as %d (%s)\n", auctionID, relisted.ID, reason)
+
+	return nil
+}
+
+// offerNext offers the item to the highest remaining bidder who has not had an offer yet.
+// When no candidate is left the product is returned to stock.
+func (s *FailurePolicyService) offerNext(ctx context.Context, auctionID int64, policy *FailurePolicy, reason string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var productID int64
+	var status AuctionStatus
+	if err := tx.QueryRow(ctx, `SELECT product_id, status FROM auctions WHERE id = $1 FOR UPDATE`, auctionID).Scan(&productID, &status); err != nil {
+		return fmt.Errorf("failed to lock auction: %w", err)
+	}
+
+	var open bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM second_chance_offers
+			WHERE auction_id = $1 AND status IN ('pending', 'accepted')
+		)`, auctionID).Scan(&open); err != nil {
+		return fmt.Errorf("failed to check open offers: %w", err)
+	}
+	if open {
+		return nil
+	}
+
+	// The unpaid winner is never offered the item again
+	var excludeUserID int64
+	if status == AuctionStatusWinnerUnpaid {
+		_ = tx.QueryRow(ctx, `SELECT user_id FROM bids WHERE auction_id = $1 ORDER BY amount DESC, created_at DESC LIMIT 1`, auctionID).Scan(&excludeUserID)
+	}
+
+	var candidateID int64
+	var amount float64
+	err = tx.QueryRow(ctx, `
+		SELECT b.user_id, MAX(b.amount)
+		FROM bids b
+		WHERE b.auction_id = $1
+		  AND b.user_id <> $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM second_chance_offers o
+			WHERE o.auction_id = b.auction_id AND o.user_id = b.user_id
+		  )
+		GROUP BY b.user_id
+		ORDER BY MAX(b.amount) DESC, MIN(b.created_at) ASC
+		LIMIT 1`, auctionID, excludeUserID).Scan(&candidateID, &amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Nobody left to offer: put the product back on sale
+		if _, err := tx.Exec(ctx, `
+			UPDATE products SET status = 'available', updated_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+			WHERE id = $1 AND status = 'auction_hold'`, productID); err != nil {
+			return fmt.Errorf("failed to release product: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		_, _ = audit.LogAction(ctx, s.db, "AUC.SECOND_CHANCE_EXHAUSTED", "auction", fmt.Sprint(auctionID), map[string]interface{}{
+			"reason":     reason,
+			"product_id": productID,
+		})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find second-chance candidate: %w", err)
+	}
+
+	offer := &SecondChanceOffer{
+		AuctionID: auctionID,
+		UserID:    candidateID,
+		Amount:    amount,
+		Status:    SecondChanceOfferPending,
+		ExpiresAt: time.Now().UTC().Add(time.Duration(policy.OfferWindowHours) * time.Hour),
+	}
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO second_chance_offers (auction_id, user_id, amount, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`,
+		auctionID, candidateID, amount, offer.ExpiresAt).Scan(&offer.ID, &offer.CreatedAt, &offer.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to create second-chance offer: %w", err)
+	}
+
+	// Hold the product so it is not sold elsewhere while the offer is open
+	if _, err := tx.Exec(ctx, `
+		UPDATE products SET status = 'auction_hold', updated_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		WHERE id = $1 AND status = 'available'`, productID); err != nil {
+		return fmt.Errorf("failed to hold product: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	_, _ = audit.LogAction(ctx, s.db, "AUC.SECOND_CHANCE_OFFERED", "auction", fmt.Sprint(auctionID), map[string]interface{}{
+		"reason":     reason,
+		"offer_id":   offer.ID,
+		"user_id":    candidateID,
+		"amount":     amount,
+		"expires_at": offer.ExpiresAt,
+	})
+
+	s.sendOfferNotification(ctx, offer, productID)
+	return nil
+}
+
+// AcceptOffer accepts a pending offer and creates the order at the offered amount
+func (s *FailurePolicyService) AcceptOffer(ctx context.Context, offerID, userID int64) (*SecondChanceOffer, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	offer, err := s.getOfferForUpdate(ctx, tx, offerID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if offer.Status != SecondChanceOfferPending {
+		return nil, &errs.Error{Code: errs.FailedPrecondition, Message: "العرض لم يعد متاحاً"}
+	}
+	if !offer.ExpiresAt.After(time.Now().UTC()) {
+		return nil, &errs.Error{Code: errs.FailedPrecondition, Message: "انتهت مهلة قبول العرض"}
+	}
+
+	var productID int64
+	if err := tx.QueryRow(ctx, `SELECT product_id FROM auctions WHERE id = $1`, offer.AuctionID).Scan(&productID); err != nil {
+		return nil, fmt.Errorf("failed to get auction: %w", err)
+	}
+
+	// The offer row stays locked while the order is created; the order is unique per auction and
+	// user, so a retry after a failed commit picks up the same order instead of creating another.
+	orderResp, err := order_mgmt.CreateAuctionWinnerOrder(ctx, &order_mgmt.CreateAuctionWinnerParams{
+		AuctionID:          offer.AuctionID,
+		ProductID:          productID,
+		WinnerUserID:       offer.UserID,
+		WinningAmountGross: offer.Amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(ctx, `
+		UPDATE second_chance_offers
+		SET status = 'accepted', responded_at = $2, order_id = $3, invoice_id = $4
+		WHERE id = $1`, offer.ID, now, orderResp.OrderID, orderResp.InvoiceID); err != nil {
+		return nil, fmt.Errorf("failed to accept offer: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	offer.Status = SecondChanceOfferAccepted
+	offer.RespondedAt = &now
+	offer.OrderID = &orderResp.OrderID
+	offer.InvoiceID = &orderResp.InvoiceID
+
+	_, _ = audit.LogAction(ctx, s.db, "AUC.SECOND_CHANCE_ACCEPTED", "auction", fmt.Sprint(offer.AuctionID), map[string]interface{}{
+		"offer_id":   offer.ID,
+		"user_id":    offer.UserID,
+		"amount":     offer.Amount,
+		"order_id":   orderResp.OrderID,
+		"invoice_id": orderResp.InvoiceID,
+	}, audit.WithActor(userID))
+
+	_, _ = notifications.EnqueueInternal(ctx, offer.UserID, "second_chance_accepted", map[string]interface{}{
+		"auction_id":  fmt.Sprint(offer.AuctionID),
+		"order_id":    fmt.Sprint(orderResp.OrderID),
+		"invoice_id":  fmt.Sprint(orderResp.InvoiceID),
+		"amount":      fmt.Sprintf("%.2f", offer.Amount),
+		"payment_url": fmt.Sprintf("https://dughairiloft.com/checkout/%d", orderResp.OrderID),
+		"language":    "ar",
+	})
+
+	return offer, nil
+}
+
+// DeclineOffer declines a pending offer and moves on to the next bidder
+func (s *FailurePolicyService) DeclineOffer(ctx context.Context, offerID, userID int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	offer, err := s.getOfferForUpdate(ctx, tx, offerID, userID)
+	if err != nil {
+		return err
+	}
+	if offer.Status != SecondChanceOfferPending {
+		return &errs.Error{Code: errs.FailedPrecondition, Message: "العرض لم يعد متاحاً"}
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE second_chance_offers SET status = 'declined', responded_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		WHERE id = $1`, offer.ID); err != nil {
+		return fmt.Errorf("failed to decline offer: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	_, _ = audit.LogAction(ctx, s.db, "AUC.SECOND_CHANCE_DECLINED", "auction", fmt.Sprint(offer.AuctionID), map[string]interface{}{
+		"offer_id": offer.ID,
+		"user_id":  offer.UserID,
+		"amount":   offer.Amount,
+	}, audit.WithActor(userID))
+
+	policy, err := s.GetPolicy(ctx, offer.AuctionID)
+	if err != nil {
+		return err
+	}
+	if err := s.offerNext(ctx, offer.AuctionID, policy, "offer_declined"); err != nil {
+		fmt.Printf("Failed to offer auction %d to next bidder: %v\n", offer.AuctionID, err)
+	}
+	return nil
+}
+
+// ExpireOffers expires offers whose acceptance window has passed and cascades to the next bidder
+func (s *FailurePolicyService) ExpireOffers(ctx context.Context) error {
+	rows, err := s.db.Query(ctx, `
+		UPDATE second_chance_offers
+		SET status = 'expired'
+		WHERE status = 'pending' AND expires_at <= (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		RETURNING id, auction_id, user_id, amount`)
+	if err != nil {
+		return fmt.Errorf("failed to expire second-chance offers: %w", err)
+	}
+	var expired []*SecondChanceOffer
+	for rows.Next() {
+		o := &SecondChanceOffer{Status: SecondChanceOfferExpired}
+		if err := rows.Scan(&o.ID, &o.AuctionID, &o.UserID, &o.Amount); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan expired offer: %w", err)
+		}
+		expired = append(expired, o)
+	}
+	rows.Close()
+
+	for _, o := range expired {
+		_, _ = audit.LogAction(ctx, s.db, "AUC.SECOND_CHANCE_EXPIRED", "auction", fmt.Sprint(o.AuctionID), map[string]interface{}{
+			"offer_id": o.ID,
+			"user_id":  o.UserID,
+			"amount":   o.Amount,
+		})
+		policy, err := s.GetPolicy(ctx, o.AuctionID)
+		if err != nil {
+			fmt.Printf("Failed to load failure policy for auction %d: %v\n", o.AuctionID, err)
+			continue
+		}
+		if err := s.offerNext(ctx, o.AuctionID, policy, "offer_expired"); err != nil {
+			fmt.Printf("Failed to offer auction %d to next bidder: %v\n", o.AuctionID, err)
+		}
+	}
+	return nil
+}
+
+// ListUserOffers returns the user's second-chance offers, newest first
+func (s *FailurePolicyService) ListUserOffers(ctx context.Context, userID int64) ([]*SecondChanceOffer, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, auction_id, user_id, amount, status, expires_at, responded_at,
+		       order_id, invoice_id, created_at, updated_at
+		FROM second_chance_offers
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 100`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query second-chance offers: %w", err)
+	}
+	defer rows.Close()
+
+	offers := []*SecondChanceOffer{}
+	for rows.Next() {
+		o := &SecondChanceOffer{}
+		if err := rows.Scan(&o.ID, &o.AuctionID, &o.UserID, &o.Amount, &o.Status, &o.ExpiresAt, &o.RespondedAt,
+			&o.OrderID, &o.InvoiceID, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan second-chance offer: %w", err)
+		}
+		offers = append(offers, o)
+	}
+	return offers, nil
+}
+
+// getOfferForUpdate locks the offer and checks that it belongs to the user
+func (s *FailurePolicyService) getOfferForUpdate(ctx context.Context, tx *sqldb.Tx, offerID, userID int64) (*SecondChanceOffer, error) {
+	o := &SecondChanceOffer{}
+	err := tx.QueryRow(ctx, `
+		SELECT id, auction_id, user_id, amount, status, expires_at, created_at, updated_at
+		FROM second_chance_offers
+		WHERE id = $1
+		FOR UPDATE`, offerID).Scan(&o.ID, &o.AuctionID, &o.UserID, &o.Amount, &o.Status, &o.ExpiresAt, &o.CreatedAt, &o.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && o.UserID != userID) {
+		return nil, &errs.Error{Code: errs.NotFound, Message: "العرض غير موجود"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offer: %w", err)
+	}
+	return o, nil
+}
+
+// sendOfferNotification tells the bidder about the offer and its deadline
+func (s *FailurePolicyService) sendOfferNotification(ctx context.Context, offer *SecondChanceOffer, productID int64) {
+	var productTitle string
+	if err := s.db.QueryRow(ctx, `SELECT title FROM products WHERE id = $1`, productID).Scan(&productTitle); err != nil || productTitle == "" {
+		productTitle = fmt.Sprintf("المزاد #%d", offer.AuctionID)
+	}
+
+	payload := map[string]interface{}{
+		"auction_id":    fmt.Sprint(offer.AuctionID),
+		"offer_id":      fmt.Sprint(offer.ID),
+		"product_title": productTitle,
+		"amount":        fmt.Sprintf("%.2f", offer.Amount),
+		"expires_at":    offer.ExpiresAt.Format("2006-01-02 15:04"),
+		"OfferURL":      "https://dughairiloft.com/me/second-chance-offers",
+		"language":      "ar",
+	}
+
+	var email, name string
+	_ = s.db.QueryRow(ctx, `SELECT COALESCE(email, ''), COALESCE(name, '') FROM users WHERE id = $1`, offer.UserID).Scan(&email, &name)
+	if name != "" {
+		payload["name"] = name
+	}
+
+	if _, err := notifications.EnqueueInternal(ctx, offer.UserID, "second_chance_offer", payload); err != nil {
+		fmt.Printf("Failed to send second_chance_offer notification to user %d: %v\n", offer.UserID, err)
+	}
+	if email != "" {
+		payload["email"] = email
+		if _, err := notifications.EnqueueEmail(ctx, offer.UserID, "second_chance_offer", payload); err != nil {
+			fmt.Printf("Failed to send second_chance_offer email to user %d: %v\n", offer.UserID, err)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package auctions
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"encore.app/pkg/config"
 	"encore.app/pkg/storagegcs"
 	"encore.dev/storage/sqldb"
 )
@@ -11,29 +13,34 @@ import (
 // Database connection
 var db = sqldb.Named("coredb")
 
-// Encore secrets for GCS configuration
+// Encore secrets for media storage (same keys as the catalog service)
 var secrets struct {
 	GCSProjectID       string //encore:secret
 	GCSBucketName      string //encore:secret
 	GCSCredentialsJSON string //encore:secret
+	S3AccessKeyID      string //encore:secret
+	S3SecretAccessKey  string //encore:secret
+	MediaSigningKey    string //encore:secret
 }
 
 // Initialize the auction service
 func init() {
-	// Initialize storage client using Encore secrets
-	storageConfig := storagegcs.Config{
-		ProjectID:      secrets.GCSProjectID,
-		BucketName:     secrets.GCSBucketName,
-		CredentialsKey: secrets.GCSCredentialsJSON,
-		IsPublic:       false,
-	}
-
-	storageClient, err := storagegcs.NewClient(context.Background(), storageConfig)
+	// Storage backend is selected by media.storage_provider (gcs | local | s3)
+	settings := config.Initialize(db, 5*time.Minute).GetSettings()
+	var storageClient storagegcs.Storage
+	client, err := storagegcs.Open(context.Background(), storagegcs.OptionsFromSettings(settings, storagegcs.Secrets{
+		GCSProjectID:       secrets.GCSProjectID,
+		GCSBucketName:      secrets.GCSBucketName,
+		GCSCredentialsJSON: secrets.GCSCredentialsJSON,
+		S3AccessKeyID:      secrets.S3AccessKeyID,
+		S3SecretAccessKey:  secrets.S3SecretAccessKey,
+		MediaSigningKey:    secrets.MediaSigningKey,
+	}))
 	if err != nil {
-		// In local/dev environments it's common to not have GCS credentials configured.
-		// Continue without storage so read-only endpoints work.
-		fmt.Printf("[auctions] storage init failed, continuing without GCS (thumbnails disabled): %v\n", err)
-		storageClient = nil
+		// Continue without storage so read-only endpoints work (thumbnails disabled).
+		fmt.Printf("[auctions] storage init failed, continuing without storage (thumbnails disabled): %v\n", err)
+	} else {
+		storageClient = client
 	}
 
 	// Create and initialize the service
@@ -45,9 +45,30 @@ type Bid struct {
 	Amount               float64   `json:"amount"`
 	BidderNameSnapshot   string    `json:"bidder_name_snapshot"`
 	BidderCityIDSnapshot *int64    `json:"bidder_city_id_snapshot,omitempty"`
+	IsAuto               bool      `json:"is_auto"`
 	CreatedAt            time.Time `json:"created_at"`
 }
 
+// ProxyBidStatus represents the lifecycle state of a proxy (automatic max) bid
+type ProxyBidStatus string
+
+const (
+	ProxyBidStatusActive    ProxyBidStatus = "active"
+	ProxyBidStatusExhausted ProxyBidStatus = "exhausted"
+	ProxyBidStatusCancelled ProxyBidStatus = "cancelled"
+)
+
+// ProxyBid represents a bidder's maximum amount the platform may bid up to on their behalf
+type ProxyBid struct {
+	ID        int64          `json:"id"`
+	AuctionID int64          `json:"auction_id"`
+	UserID    int64          `json:"user_id"`
+	MaxAmount float64        `json:"max_amount"`
+	Status    ProxyBidStatus `json:"status"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
 // AuctionExtension represents an extension of auction end time due to anti-sniping
 type AuctionExtension struct {
 	ID              int64     `json:"id"`
@@ -58,6 +79,55 @@ type AuctionExtension struct {
 	CreatedAt       time.Time `json:"created_at"`
 }
 
+// FailureAction is what happens to the item when an auction fails (reserve not met / winner unpaid)
+type FailureAction string
+
+const (
+	FailureActionNone         FailureAction = "none"
+	FailureActionRelist       FailureAction = "relist"
+	FailureActionSecondChance FailureAction = "second_chance"
+)
+
+// FailurePolicy is the per-auction policy applied when the auction fails
+type FailurePolicy struct {
+	AuctionID             int64         `json:"auction_id"`
+	Action                FailureAction `json:"action"`
+	RelistDelayMinutes    int           `json:"relist_delay_minutes"`
+	RelistDurationMinutes int           `json:"relist_duration_minutes"`
+	RelistStartPrice      *float64      `json:"relist_start_price,omitempty"`   // nil keeps the original start price
+	RelistReservePrice    *float64      `json:"relist_reserve_price,omitempty"` // nil keeps the original reserve
+	RelistClearReserve    bool          `json:"relist_clear_reserve"`
+	MaxRelists            int           `json:"max_relists"`
+	OfferWindowHours      int           `json:"offer_window_hours"`
+	CreatedAt             time.Time     `json:"created_at"`
+	UpdatedAt             time.Time     `json:"updated_at"`
+}
+
+// SecondChanceOfferStatus represents the lifecycle state of a second-chance offer
+type SecondChanceOfferStatus string
+
+const (
+	SecondChanceOfferPending  SecondChanceOfferStatus = "pending"
+	SecondChanceOfferAccepted SecondChanceOfferStatus = "accepted"
+	SecondChanceOfferDeclined SecondChanceOfferStatus = "declined"
+	SecondChanceOfferExpired  SecondChanceOfferStatus = "expired"
+)
+
+// SecondChanceOffer offers a failed auction's item to a runner-up bidder at their last bid
+type SecondChanceOffer struct {
+	ID          int64                   `json:"id"`
+	AuctionID   int64                   `json:"auction_id"`
+	UserID      int64                   `json:"user_id"`
+	Amount      float64                 `json:"amount"`
+	Status      SecondChanceOfferStatus `json:"status"`
+	ExpiresAt   time.Time               `json:"expires_at"`
+	RespondedAt *time.Time              `json:"responded_at,omitempty"`
+	OrderID     *int64                  `json:"order_id,omitempty"`
+	InvoiceID   *int64                  `json:"invoice_id,omitempty"`
+	CreatedAt   time.Time               `json:"created_at"`
+	UpdatedAt   time.Time               `json:"updated_at"`
+}
+
 // AuctionWithDetails represents an auction with additional details
 type AuctionWithDetails struct {
 	Auction
@@ -92,7 +162,8 @@ type CreateAuctionRequest struct {
 
 // PlaceBidRequest represents the request to place a bid
 type PlaceBidRequest struct {
-	Amount float64 `json:"amount"`
+	Amount    float64  `json:"amount"`
+	MaxAmount *float64 `json:"max_amount,omitempty"`
 }
 
 // CancelAuctionRequest represents the request to cancel an auction
@@ -0,0 +1,35 @@
+package auctions
+
+import (
+	"context"
+	"fmt"
+
+	"encore.app/pkg/outbox"
+	"encore.app/svc/notifications"
+	"encore.dev/storage/sqldb"
+)
+
+// recipientMessages returns the inbox message for one recipient and, when the payload carries an
+// email address, the matching email. eventKey identifies the logical event (e.g. "auction:12:ended")
+// so retries of the same event collapse onto the same outbox rows.
+func recipientMessages(userID int64, templateID string, payload map[string]interface{}, eventKey string) []outbox.Message {
+	msgs := []outbox.Message{
+		outbox.Internal(userID, templateID, payload, outbox.Key(eventKey, templateID, outbox.KindInternal, userID)),
+	}
+	if email, _ := payload["email"].(string); email != "" {
+		msgs = append(msgs, outbox.Email(userID, templateID, payload, outbox.Key(eventKey, templateID, outbox.KindEmail, userID)))
+	}
+	return msgs
+}
+
+// queueNotifications writes msgs to the outbox for callers without a surrounding transaction
+func queueNotifications(ctx context.Context, db *sqldb.Database, msgs []outbox.Message) {
+	if len(msgs) == 0 {
+		return
+	}
+	if err := outbox.Add(ctx, db, msgs...); err != nil {
+		fmt.Printf("Failed to queue notifications: %v\n", err)
+		return
+	}
+	notifications.KickOutbox()
+}
@@ -0,0 +1,219 @@
+package auctions
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"encore.app/pkg/errs"
+	"encore.dev/storage/sqldb"
+)
+
+// maxProxyRounds bounds proxy resolution; every round exhausts at least one ceiling,
+// so this is only a guard against unexpected data.
+const maxProxyRounds = 100
+
+// registerProxyBid validates and stores the user's ceiling inside the bidding transaction
+func (s *BidService) registerProxyBid(ctx context.Context, tx *sqldb.Tx, auction *Auction, userID int64, amount, maxAmount float64) error {
+	if amount > 0 && maxAmount < amount {
+		return &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: "الحد الأقصى للمزايدة التلقائية يجب أن يكون أكبر من أو يساوي مبلغ المزايدة",
+		}
+	}
+
+	leader, err := s.repo.GetHighestBidTx(ctx, tx, auction.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get highest bid: %w", err)
+	}
+
+	// A ceiling only makes sense if it can reach the next valid bid (unless the user already leads)
+	if leader == nil || leader.UserID != userID {
+		currentPrice := auction.StartPrice
+		if leader != nil {
+			currentPrice = leader.Amount
+		}
+		requiredMin := currentPrice + float64(auction.BidStep)
+		if maxAmount < requiredMin {
+			return &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: fmt.Sprintf("الحد الأقصى للمزايدة التلقائية يجب أن يكون على الأقل %.2f ر.س", requiredMin),
+			}
+		}
+	} else if maxAmount <= leader.Amount {
+		return &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: fmt.Sprintf("الحد الأقصى للمزايدة التلقائية يجب أن يتجاوز مزايدتك الحالية %.2f ر.س", leader.Amount),
+		}
+	}
+
+	if _, err := s.repo.UpsertProxyBid(ctx, tx, auction.ID, userID, maxAmount); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// resolveProxyBids lets active proxy ceilings counter-bid against the current leader until no
+// competing ceiling can top the price. Contests are resolved in a compressed form: the losing
+// side bids its last reachable step and the winner answers one step above (capped at its own
+// ceiling), so each contest costs at most two inserts and at most two anti-sniping extensions.
+// Equal ceilings go to whichever was set first. Runs inside the bidding transaction so the
+// validate_bid_step and handle_anti_sniping triggers apply to every auto-bid.
+func (s *BidService) resolveProxyBids(ctx context.Context, tx *sqldb.Tx, auction *Auction) ([]*Bid, error) {
+	step := float64(auction.BidStep)
+	var placed []*Bid
+
+	for round := 0; round < maxProxyRounds; round++ {
+		leader, err := s.repo.GetHighestBidTx(ctx, tx, auction.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get highest bid: %w", err)
+		}
+
+		price := auction.StartPrice
+		var leaderUserID int64
+		if leader != nil {
+			price = leader.Amount
+			leaderUserID = leader.UserID
+		}
+
+		challenger, err := s.repo.GetStrongestCompetingProxyTx(ctx, tx, auction.ID, leaderUserID, price+step)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get competing proxy bid: %w", err)
+		}
+		if challenger == nil {
+			return placed, nil
+		}
+
+		// No bids yet: the strongest ceiling opens at the minimum and becomes the leader
+		if leader == nil {
+			bid, err := s.placeAutoBid(ctx, tx, auction.ID, challenger.UserID, price+step)
+			if err != nil {
+				return nil, err
+			}
+			placed = append(placed, bid)
+			continue
+		}
+
+		leaderProxy, err := s.repo.GetActiveProxyBidTx(ctx, tx, auction.ID, leaderUserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get leader proxy bid: %w", err)
+		}
+
+		leaderLevel := 0
+		if leaderProxy != nil {
+			leaderLevel = proxyLevel(leaderProxy.MaxAmount, price, step)
+		}
+		challengerLevel := proxyLevel(challenger.MaxAmount, price, step)
+
+		leaderWins := leaderLevel > challengerLevel ||
+			(leaderLevel == challengerLevel && leaderProxy != nil && !leaderProxy.UpdatedAt.After(challenger.UpdatedAt))
+
+		winnerUserID, loserUserID := challenger.UserID, leaderUserID
+		winnerLevel, loserLevel := challengerLevel, leaderLevel
+		loserProxy := leaderProxy
+		if leaderWins {
+			winnerUserID, loserUserID = leaderUserID, challenger.UserID
+			winnerLevel, loserLevel = leaderLevel, challengerLevel
+			loserProxy = challenger
+		}
+
+		finalLevel := loserLevel + 1
+		if winnerLevel < finalLevel {
+			finalLevel = winnerLevel
+		}
+
+		// The losing ceiling defends up to its last step before the winner answers
+		if finalLevel-1 >= 1 {
+			bid, err := s.placeAutoBid(ctx, tx, auction.ID, loserUserID, price+float64(finalLevel-1)*step)
+			if err != nil {
+				return nil, err
+			}
+			placed = append(placed, bid)
+		}
+
+		bid, err := s.placeAutoBid(ctx, tx, auction.ID, winnerUserID, price+float64(finalLevel)*step)
+		if err != nil {
+			return nil, err
+		}
+		placed = append(placed, bid)
+
+		if loserProxy != nil {
+			if err := s.repo.UpdateProxyBidStatus(ctx, tx, loserProxy.ID, ProxyBidStatusExhausted); err != nil {
+				return nil, fmt.Errorf("failed to exhaust proxy bid: %w", err)
+			}
+		}
+	}
+
+	return placed, nil
+}
+
+// placeAutoBid inserts a bid on behalf of a proxy ceiling
+func (s *BidService) placeAutoBid(ctx context.Context, tx *sqldb.Tx, auctionID, userID int64, amount float64) (*Bid, error) {
+	bidderName, bidderCityID, err := s.getUserSnapshotData(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user data: %w", err)
+	}
+
+	bid := &Bid{
+		AuctionID:            auctionID,
+		UserID:               userID,
+		Amount:               amount,
+		BidderNameSnapshot:   bidderName,
+		BidderCityIDSnapshot: bidderCityID,
+		IsAuto:               true,
+	}
+
+	createdBid, err := s.repo.CreateBid(ctx, tx, bid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create auto bid: %w", err)
+	}
+
+	return createdBid, nil
+}
+
+// proxyLevel returns how many bid steps above price a ceiling can reach
+func proxyLevel(maxAmount, price, step float64) int {
+	if maxAmount <= price {
+		return 0
+	}
+	// Tolerate NUMERIC→float rounding just below a whole step
+	return int(math.Floor((maxAmount-price)/step + 1e-9))
+}
+
+// GetProxyBid returns the user's proxy bid for an auction
+func (s *BidService) GetProxyBid(ctx context.Context, auctionID, userID int64) (*ProxyBid, error) {
+	if err := s.verifyAuctionExists(ctx, auctionID); err != nil {
+		return nil, err
+	}
+
+	proxy, err := s.repo.GetProxyBid(ctx, auctionID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy bid: %w", err)
+	}
+	if proxy == nil {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: "لا توجد مزايدة تلقائية لهذا المزاد",
+		}
+	}
+
+	return proxy, nil
+}
+
+// CancelProxyBid stops automatic bidding for the user; bids already placed remain valid
+func (s *BidService) CancelProxyBid(ctx context.Context, auctionID, userID int64) error {
+	cancelled, err := s.repo.CancelProxyBid(ctx, auctionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel proxy bid: %w", err)
+	}
+	if !cancelled {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: "لا توجد مزايدة تلقائية نشطة لهذا المزاد",
+		}
+	}
+
+	fmt.Printf("[AUDIT] BID.PROXY_CANCELLED - Auction %d: User %d\n", auctionID, userID)
+	return nil
+}
@@ -2,27 +2,21 @@ package auctions
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"encore.app/pkg/errs"
+	"encore.app/pkg/ratelimit"
 	"encore.dev/storage/sqldb"
 )
 
-// RateLimitService handles rate limiting for auction operations
+// RateLimitService handles rate limiting for auction operations. Bid and WebSocket connection
+// counters live in the shared rate_limit_counters table, so limits hold across instances.
 type RateLimitService struct {
-	db    *sqldb.Database
-	cache map[string]*UserRateLimit
-	mu    sync.RWMutex
-}
-
-// UserRateLimit tracks rate limiting for a user
-type UserRateLimit struct {
-	UserID    int64
-	BidCount  int
-	LastReset time.Time
-	mu        sync.Mutex
+	db        *sqldb.Database
+	bids      *ratelimit.RateLimiter
+	wsConnect *ratelimit.RateLimiter
 }
 
 // RateLimitConfig holds rate limiting configuration
@@ -35,26 +29,40 @@ type RateLimitConfig struct {
 
 // NewRateLimitService creates a new rate limit service
 func NewRateLimitService(db *sqldb.Database) *RateLimitService {
-	service := &RateLimitService{
-		db:    db,
-		cache: make(map[string]*UserRateLimit),
+	storage := ratelimit.NewPostgresStorage(db)
+	settings := ratelimit.SettingsProvider(db)
+	return &RateLimitService{
+		db: db,
+		// bids.rate_limit_per_minute / ratelimit.bids.*; user_auction_rate_limits overrides per user
+		bids: ratelimit.NewSharedRateLimiter("bids",
+			ratelimit.RateLimitConfig{MaxAttempts: 60, Window: time.Minute}, storage, settings),
+		// ratelimit.ws_connect.*: connection attempts per IP, not concurrent connections
+		// (ws.max_connections_per_host)
+		wsConnect: ratelimit.NewSharedRateLimiter("ws_connect",
+			ratelimit.RateLimitConfig{MaxAttempts: 120, Window: time.Minute}, storage, settings),
 	}
-
-	// Start cleanup goroutine
-	go service.cleanupExpiredEntries()
-
-	return service
 }
 
-// CheckBidRateLimit checks if user can place a bid
+// CheckBidRateLimit checks if user can place a bid and counts the attempt
 func (s *RateLimitService) CheckBidRateLimit(ctx context.Context, userID int64) error {
-	config, err := s.getRateLimitConfig(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get rate limit config: %w", err)
+	// Check for user-specific rate limit override
+	var userLimit int
+	err := s.db.QueryRow(ctx, `
+		SELECT bids_per_minute 
+		FROM user_auction_rate_limits 
+		WHERE user_id = $1
+	`, userID).Scan(&userLimit)
+	if err != nil && !errors.Is(err, sqldb.ErrNoRows) {
+		return fmt.Errorf("failed to get user rate limit: %w", err)
 	}
 
-	// Use database-based rate limiting for accuracy
-	return s.checkDatabaseRateLimit(ctx, userID, config.BidsPerMinute, "bids")
+	if err := s.bids.RecordAttemptWithLimit(ratelimit.GenerateUserKey("bids", userID), userLimit); err != nil {
+		return &errs.Error{
+			Code:    errs.TooManyRequests,
+			Message: "تجاوزت الحد المسموح للطلبات",
+		}
+	}
+	return nil
 }
 
 // CheckPaymentInitRateLimit checks payment initialization rate limit
@@ -88,46 +96,12 @@ func (s *RateLimitService) CheckPaymentInitRateLimit(ctx context.Context, userID
 
 // CheckWebSocketRateLimit checks WebSocket connection rate limit
 func (s *RateLimitService) CheckWebSocketRateLimit(ctx context.Context, clientIP string) error {
-	config, err := s.getRateLimitConfig(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get rate limit config: %w", err)
-	}
-
-	s.mu.RLock()
-	key := fmt.Sprintf("ws_conn_%s", clientIP)
-	userLimit, exists := s.cache[key]
-	s.mu.RUnlock()
-
-	if !exists {
-		s.mu.Lock()
-		userLimit = &UserRateLimit{
-			UserID:    0, // IP-based, not user-based
-			BidCount:  1,
-			LastReset: time.Now(),
-		}
-		s.cache[key] = userLimit
-		s.mu.Unlock()
-		return nil
-	}
-
-	userLimit.mu.Lock()
-	defer userLimit.mu.Unlock()
-
-	// Reset counter if more than 1 minute has passed
-	if time.Since(userLimit.LastReset) > time.Minute {
-		userLimit.BidCount = 1
-		userLimit.LastReset = time.Now()
-		return nil
-	}
-
-	if userLimit.BidCount >= config.WSConnectionsPerHost {
+	if err := s.wsConnect.RecordAttempt(ratelimit.GenerateIPKey("ws_connect", clientIP)); err != nil {
 		return &errs.Error{
 			Code:    errs.TooManyRequests,
-			Message: "تجاوزت الحد المسموح للاتصالات المتزامنة",
+			Message: "تجاوزت الحد المسموح لمحاولات الاتصال",
 		}
 	}
-
-	userLimit.BidCount++
 	return nil
 }
 
@@ -145,17 +119,9 @@ func (s *RateLimitService) GetRateLimitStatus(ctx context.Context, userID int64)
 		return nil, fmt.Errorf("failed to get rate limit config: %w", err)
 	}
 
-	// Get current bid count in last minute
-	var bidCount int
-	query := `
-		SELECT COUNT(*) 
-		FROM bids 
-		WHERE user_id = $1 AND created_at > NOW() - INTERVAL '1 minute'`
-
-	err = s.db.QueryRow(ctx, query, userID).Scan(&bidCount)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get bid count: %w", err)
-	}
+	// Bids counted by the shared limiter in the current sliding window
+	bidLimit := s.bids.Config().MaxAttempts
+	bidCount := bidLimit - s.bids.GetRemainingAttempts(ratelimit.GenerateUserKey("bids", userID))
 
 	// Get payment init count in last 5 minutes
 	var paymentCount int
@@ -172,9 +138,9 @@ func (s *RateLimitService) GetRateLimitStatus(ctx context.Context, userID int64)
 
 	status := &RateLimitStatus{
 		UserID:              userID,
-		BidsPerMinute:       config.BidsPerMinute,
+		BidsPerMinute:       bidLimit,
 		CurrentBidCount:     bidCount,
-		BidsRemaining:       max(0, config.BidsPerMinute-bidCount),
+		BidsRemaining:       max(0, bidLimit-bidCount),
 		PaymentInitPer5Min:  config.PaymentInitPer5Min,
 		CurrentPaymentCount: paymentCount,
 		PaymentRemaining:    max(0, config.PaymentInitPer5Min-paymentCount),
@@ -217,69 +183,6 @@ func (s *RateLimitService) getRateLimitConfig(ctx context.Context) (*RateLimitCo
 	return config, nil
 }
 
-func (s *RateLimitService) checkDatabaseRateLimit(ctx context.Context, userID int64, limit int, operation string) error {
-	// Check for user-specific rate limit override
-	if operation == "bids" {
-		var userLimit int
-		err := s.db.QueryRow(ctx, `
-			SELECT bids_per_minute 
-			FROM user_auction_rate_limits 
-			WHERE user_id = $1
-		`, userID).Scan(&userLimit)
-		if err == nil && userLimit > 0 {
-			limit = userLimit
-		}
-	}
-
-	var count int
-	var query string
-
-	switch operation {
-	case "bids":
-		query = `
-			SELECT COUNT(*) 
-			FROM bids 
-			WHERE user_id = $1 AND created_at > NOW() - INTERVAL '1 minute'`
-	default:
-		return fmt.Errorf("unknown operation: %s", operation)
-	}
-
-	err := s.db.QueryRow(ctx, query, userID).Scan(&count)
-	if err != nil {
-		return fmt.Errorf("failed to check rate limit: %w", err)
-	}
-
-	if count >= limit {
-		return &errs.Error{
-			Code:    errs.TooManyRequests,
-			Message: "تجاوزت الحد المسموح للطلبات",
-		}
-	}
-
-	return nil
-}
-
-func (s *RateLimitService) cleanupExpiredEntries() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			s.mu.Lock()
-			now := time.Now()
-			for key, userLimit := range s.cache {
-				userLimit.mu.Lock()
-				if now.Sub(userLimit.LastReset) > 10*time.Minute {
-					delete(s.cache, key)
-				}
-				userLimit.mu.Unlock()
-			}
-			s.mu.Unlock()
-		}
-	}
-}
-
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -27,10 +27,14 @@ const (
 	EventBidRemoved      EventType = "bid_removed"
 	EventPriceRecomputed EventType = "price_recomputed"
 	EventHeartbeat       EventType = "heartbeat"
+	// EventResync tells a resuming client that missed events are no longer available
+	// and it should refetch the auction state
+	EventResync EventType = "resync"
 )
 
 // AuctionEvent represents a real-time auction event
 type AuctionEvent struct {
+	ID        int64       `json:"id,omitempty"` // per-auction sequence number (0 for heartbeats)
 	EventType EventType   `json:"event"`
 	Data      interface{} `json:"data"`
 }
@@ -47,6 +51,11 @@ type Client struct {
 	IsWS      bool
 	Done      chan bool
 	mu        sync.Mutex
+
+	// Replay state: while replaying, live events are buffered and flushed afterwards
+	lastEventID int64
+	replaying   bool
+	pending     []*AuctionEvent
 }
 
 // Hub manages all client connections and broadcasts
@@ -61,13 +70,21 @@ type Hub struct {
 
 // RealtimeService handles real-time auction events
 type RealtimeService struct {
-	hub *Hub
-	db  *sqldb.Database
-	mu  sync.RWMutex // Protects service state
+	hub        *Hub
+	db         *sqldb.Database
+	bus        AuctionEventBus // fans events out to hubs on other instances
+	eventLog   AuctionEventLog // sequences events and keeps history for replay
+	instanceID string
+	mu         sync.RWMutex // Protects service state
 }
 
-// NewRealtimeService creates a new realtime service
+// NewRealtimeService creates a new realtime service that shares events with other
+// instances through Postgres LISTEN/NOTIFY and keeps a replayable event history
 func NewRealtimeService(db *sqldb.Database) *RealtimeService {
+	return newRealtimeService(db, NewPostgresEventBus(db), NewPostgresEventLog(db))
+}
+
+func newRealtimeService(db *sqldb.Database, bus AuctionEventBus, eventLog AuctionEventLog) *RealtimeService {
 	hub := &Hub{
 		clients:    make(map[string]*Client),
 		register:   make(chan *Client),
@@ -77,13 +94,21 @@ func NewRealtimeService(db *sqldb.Database) *RealtimeService {
 	}
 
 	service := &RealtimeService{
-		hub: hub,
-		db:  db,
+		hub:        hub,
+		db:         db,
+		bus:        bus,
+		eventLog:   eventLog,
+		instanceID: fmt.Sprintf("hub_%d", time.Now().UTC().UnixNano()),
 	}
 
 	// Start the hub
 	go hub.run()
 
+	// Receive events published by other instances
+	if bus != nil {
+		bus.Subscribe(service.handleBusMessage)
+	}
+
 	// Start heartbeat
 	go service.startHeartbeat()
 
@@ -129,6 +154,12 @@ func HandleSSE(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	// Resume point: EventSource sends Last-Event-ID on reconnect; a query param allows fresh connections to resume
+	lastEventID := parseLastEventID(req.Header.Get("Last-Event-ID"))
+	if lastEventID == 0 {
+		lastEventID = parseLastEventID(req.URL.Query().Get("last_event_id"))
+	}
+
 	// Create client
 	client := &Client{
 		ID:        generateClientID(),
@@ -138,6 +169,8 @@ func HandleSSE(w http.ResponseWriter, req *http.Request) {
 		LastSeen:  time.Now().UTC(),
 		IsSSE:     true,
 		Done:      make(chan bool),
+		// Buffer live events until missed ones are replayed
+		replaying: lastEventID > 0,
 	}
 
 	// Get realtime service instance - ensure it's initialized
@@ -163,6 +196,11 @@ func HandleSSE(w http.ResponseWriter, req *http.Request) {
 		Data:      map[string]interface{}{"timestamp": time.Now().UTC().Unix()},
 	})
 
+	// Replay events missed since Last-Event-ID before live delivery resumes
+	if lastEventID > 0 {
+		service.replay(req.Context(), client, lastEventID)
+	}
+
 	// Wait for client disconnect
 	select {
 	case <-req.Context().Done():
@@ -339,24 +377,160 @@ func (h *Hub) run() {
 	}
 }
 
+// broadcastToAuction delivers the event to local clients and publishes it for other instances
 func (s *RealtimeService) broadcastToAuction(auctionID int64, event *AuctionEvent) error {
+	s.sequence(auctionID, nil, event)
+	s.deliverToAuction(auctionID, event)
+	return s.publish(&AuctionEventMessage{AuctionID: auctionID, Event: event})
+}
+
+// broadcastToUsers delivers the event to the given users' local clients and publishes it for other instances
+func (s *RealtimeService) broadcastToUsers(auctionID int64, userIDs []int64, event *AuctionEvent) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	s.sequence(auctionID, userIDs, event)
+	s.deliverToUsers(auctionID, userIDs, event)
+	return s.publish(&AuctionEventMessage{AuctionID: auctionID, UserIDs: userIDs, Event: event})
+}
+
+// sequence records the event in the replay log and stamps it with its sequence number.
+// Failures are logged; the event is still delivered live, just without an ID.
+func (s *RealtimeService) sequence(auctionID int64, userIDs []int64, event *AuctionEvent) {
+	if s.eventLog == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	seq, err := s.eventLog.Append(ctx, auctionID, userIDs, event)
+	if err != nil {
+		log.Printf("Failed to record auction event: %v", err)
+		return
+	}
+	event.ID = seq
+}
+
+// replay sends events after afterID to the client, then flushes live events buffered meanwhile
+func (s *RealtimeService) replay(ctx context.Context, client *Client, afterID int64) {
+	client.mu.Lock()
+	client.replaying = true
+	if afterID > client.lastEventID {
+		client.lastEventID = afterID
+	}
+	client.mu.Unlock()
+
+	if s.eventLog != nil {
+		events, truncated, err := s.eventLog.Since(ctx, client.AuctionID, afterID, client.UserID)
+		if err != nil {
+			log.Printf("Failed to load missed events for client %s: %v", client.ID, err)
+			truncated = true
+		}
+		if truncated {
+			s.sendEvent(client, &AuctionEvent{
+				EventType: EventResync,
+				Data: map[string]interface{}{
+					"auction_id": client.AuctionID,
+					"timestamp":  time.Now().UTC().Unix(),
+				},
+			})
+		}
+		// Sequence-checked like live events, so a replayed event is never sent twice
+		for _, event := range events {
+			s.sendIfNew(client, event)
+		}
+	}
+
+	// Flush live events that arrived during replay, skipping ones already replayed
+	for {
+		client.mu.Lock()
+		pending := client.pending
+		client.pending = nil
+		if len(pending) == 0 {
+			client.replaying = false
+			client.mu.Unlock()
+			return
+		}
+		client.mu.Unlock()
+
+		for _, event := range pending {
+			s.sendIfNew(client, event)
+		}
+	}
+}
+
+// deliver sends a live event to a client, buffering it while the client is replaying
+func (s *RealtimeService) deliver(client *Client, event *AuctionEvent) {
+	client.mu.Lock()
+	if client.replaying {
+		client.pending = append(client.pending, event)
+		client.mu.Unlock()
+		return
+	}
+	client.mu.Unlock()
+
+	s.sendIfNew(client, event)
+}
+
+// sendIfNew sends the event unless the client already received it (e.g. through replay). The
+// sequence number is claimed under the client's lock before writing, so concurrent replay and
+// live fan-out cannot both send it.
+func (s *RealtimeService) sendIfNew(client *Client, event *AuctionEvent) {
+	client.mu.Lock()
+	duplicate := event.ID > 0 && event.ID <= client.lastEventID
+	if !duplicate && event.ID > 0 {
+		client.lastEventID = event.ID
+	}
+	client.mu.Unlock()
+
+	if !duplicate {
+		s.sendEvent(client, event)
+	}
+}
+
+// sendEvent writes an event using the client's transport
+func (s *RealtimeService) sendEvent(client *Client, event *AuctionEvent) {
+	if client.IsSSE {
+		s.sendSSEEvent(client, event)
+	} else if client.IsWS {
+		s.sendWSEvent(client, event)
+	}
+}
+
+// publish sends the event to the bus; local clients were already served
+func (s *RealtimeService) publish(msg *AuctionEventMessage) error {
+	if s.bus == nil {
+		return nil
+	}
+	msg.Origin = s.instanceID
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.bus.Publish(ctx, msg)
+}
+
+// handleBusMessage rebroadcasts events published by other instances to local clients
+func (s *RealtimeService) handleBusMessage(msg *AuctionEventMessage) {
+	if msg == nil || msg.Event == nil || msg.Origin == s.instanceID {
+		return
+	}
+	if msg.UserIDs != nil {
+		s.deliverToUsers(msg.AuctionID, msg.UserIDs, msg.Event)
+		return
+	}
+	s.deliverToAuction(msg.AuctionID, msg.Event)
+}
+
+func (s *RealtimeService) deliverToAuction(auctionID int64, event *AuctionEvent) {
 	s.hub.mu.RLock()
 	defer s.hub.mu.RUnlock()
 
 	for _, client := range s.hub.clients {
 		if client.AuctionID == auctionID {
-			if client.IsSSE {
-				s.sendSSEEvent(client, event)
-			} else if client.IsWS {
-				s.sendWSEvent(client, event)
-			}
+			s.deliver(client, event)
 		}
 	}
-
-	return nil
 }
 
-func (s *RealtimeService) broadcastToUsers(auctionID int64, userIDs []int64, event *AuctionEvent) error {
+func (s *RealtimeService) deliverToUsers(auctionID int64, userIDs []int64, event *AuctionEvent) {
 	s.hub.mu.RLock()
 	defer s.hub.mu.RUnlock()
 
@@ -367,15 +541,9 @@ func (s *RealtimeService) broadcastToUsers(auctionID int64, userIDs []int64, eve
 
 	for _, client := range s.hub.clients {
 		if client.AuctionID == auctionID && client.UserID != nil && userIDMap[*client.UserID] {
-			if client.IsSSE {
-				s.sendSSEEvent(client, event)
-			} else if client.IsWS {
-				s.sendWSEvent(client, event)
-			}
+			s.deliver(client, event)
 		}
 	}
-
-	return nil
 }
 
 func (s *RealtimeService) sendSSEEvent(client *Client, event *AuctionEvent) {
@@ -388,7 +556,13 @@ func (s *RealtimeService) sendSSEEvent(client *Client, event *AuctionEvent) {
 		return
 	}
 
-	// Send SSE formatted message
+	// Send SSE formatted message; the id line lets EventSource resume with Last-Event-ID
+	if event.ID > 0 {
+		fmt.Fprintf(client.SSEWriter, "id: %d\n", event.ID)
+		if event.ID > client.lastEventID {
+			client.lastEventID = event.ID
+		}
+	}
 	fmt.Fprintf(client.SSEWriter, "event: %s\n", event.EventType)
 	fmt.Fprintf(client.SSEWriter, "data: %s\n\n", data)
 
@@ -441,6 +615,15 @@ func (s *RealtimeService) sendHeartbeat() {
 	}
 }
 
+// parseLastEventID parses a Last-Event-ID value; invalid values mean "no resume"
+func parseLastEventID(v string) int64 {
+	id, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil || id < 0 {
+		return 0
+	}
+	return id
+}
+
 func generateClientID() string {
 	return fmt.Sprintf("client_%d", time.Now().UTC().UnixNano())
 }
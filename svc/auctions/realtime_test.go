@@ -0,0 +1,199 @@
+package auctions
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSSEClient registers an SSE client directly on the hub
+func newTestSSEClient(t *testing.T, s *RealtimeService, id string, auctionID int64, userID *int64) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	client := &Client{
+		ID:        id,
+		AuctionID: auctionID,
+		UserID:    userID,
+		SSEWriter: rec,
+		LastSeen:  time.Now().UTC(),
+		IsSSE:     true,
+		Done:      make(chan bool),
+	}
+	s.hub.mu.Lock()
+	s.hub.clients[client.ID] = client
+	s.hub.mu.Unlock()
+	return rec
+}
+
+func TestRealtimeFanOutAcrossHubs(t *testing.T) {
+	ctx := context.Background()
+	bus := NewLocalEventBus()
+	eventLog := NewMemoryEventLog(0)
+
+	// Two instances sharing one bus and one event log (the database in production)
+	hubA := newRealtimeService(nil, bus, eventLog)
+	hubB := newRealtimeService(nil, bus, eventLog)
+
+	const auctionID int64 = 42
+	bidderID := int64(7)
+	watcherID := int64(8)
+
+	localClient := newTestSSEClient(t, hubA, "a-1", auctionID, &bidderID)
+	remoteClient := newTestSSEClient(t, hubB, "b-1", auctionID, &watcherID)
+	otherAuction := newTestSSEClient(t, hubB, "b-2", auctionID+1, nil)
+
+	bid := &BidWithDetails{Bid: Bid{ID: 1, AuctionID: auctionID, UserID: bidderID, Amount: 1100}}
+	if err := hubA.BroadcastBidPlaced(ctx, auctionID, bid, 1100); err != nil {
+		t.Fatalf("BroadcastBidPlaced: %v", err)
+	}
+
+	if got := strings.Count(remoteClient.Body.String(), "event: bid_placed"); got != 1 {
+		t.Errorf("expected remote client to receive bid_placed once, got %d", got)
+	}
+	// The publishing hub must not deliver its own event twice
+	if got := strings.Count(localClient.Body.String(), "event: bid_placed"); got != 1 {
+		t.Errorf("expected local client to receive bid_placed once, got %d", got)
+	}
+	if strings.Contains(otherAuction.Body.String(), "bid_placed") {
+		t.Errorf("client of another auction must not receive the event")
+	}
+
+	// Targeted events only reach the listed users, wherever they are connected
+	if err := hubA.BroadcastOutbid(ctx, auctionID, []int64{watcherID}, 1200); err != nil {
+		t.Fatalf("BroadcastOutbid: %v", err)
+	}
+	if !strings.Contains(remoteClient.Body.String(), "event: outbid") {
+		t.Errorf("expected remote user to receive outbid")
+	}
+	if strings.Contains(localClient.Body.String(), "event: outbid") {
+		t.Errorf("outbid must not reach users outside the target list")
+	}
+}
+
+func TestRealtimeWithoutBusStaysLocal(t *testing.T) {
+	hub := newRealtimeService(nil, nil, nil)
+	rec := newTestSSEClient(t, hub, "solo", 1, nil)
+
+	if err := hub.BroadcastPriceRecomputed(context.Background(), 1, 900, 0, "bid_removed"); err != nil {
+		t.Fatalf("BroadcastPriceRecomputed: %v", err)
+	}
+	if !strings.Contains(rec.Body.String(), "event: price_recomputed") {
+		t.Errorf("expected local client to receive price_recomputed")
+	}
+}
+
+func TestRealtimeReplayAfterLastEventID(t *testing.T) {
+	ctx := context.Background()
+	hub := newRealtimeService(nil, nil, NewMemoryEventLog(0))
+
+	const auctionID int64 = 5
+	bid := &BidWithDetails{Bid: Bid{ID: 1, AuctionID: auctionID, UserID: 3, Amount: 500}}
+	_ = hub.BroadcastBidPlaced(ctx, auctionID, bid, 500)                                  // id 1
+	_ = hub.BroadcastOutbid(ctx, auctionID, []int64{3}, 600)                              // id 2, only for user 3
+	_ = hub.BroadcastExtended(ctx, auctionID, time.Now(), time.Now().Add(time.Minute), 1) // id 3
+
+	// An anonymous client that saw event 1 reconnects
+	rec := newTestSSEClient(t, hub, "resume", auctionID, nil)
+	hub.hub.mu.RLock()
+	client := hub.hub.clients["resume"]
+	hub.hub.mu.RUnlock()
+	hub.replay(ctx, client, 1)
+
+	body := rec.Body.String()
+	if strings.Contains(body, "id: 1\n") {
+		t.Errorf("event 1 was already seen and must not be replayed")
+	}
+	if strings.Contains(body, "event: outbid") {
+		t.Errorf("user-targeted events must not be replayed to other clients")
+	}
+	if !strings.Contains(body, "id: 3\nevent: extended") {
+		t.Errorf("expected extended event to be replayed with its id, got %q", body)
+	}
+
+	// A second resume from the same point must not resend what the client already has
+	hub.replay(ctx, client, 1)
+	if got := strings.Count(rec.Body.String(), "id: 3\n"); got != 1 {
+		t.Errorf("expected event 3 exactly once after a repeated replay, got %d", got)
+	}
+}
+
+func TestRealtimeReplayBuffersLiveEvents(t *testing.T) {
+	ctx := context.Background()
+	hub := newRealtimeService(nil, nil, NewMemoryEventLog(0))
+
+	const auctionID int64 = 6
+	_ = hub.BroadcastPriceRecomputed(ctx, auctionID, 100, 0, "bid_removed") // id 1
+
+	rec := newTestSSEClient(t, hub, "buffered", auctionID, nil)
+	hub.hub.mu.RLock()
+	client := hub.hub.clients["buffered"]
+	hub.hub.mu.RUnlock()
+	client.replaying = true
+
+	// Arrives while the client is still replaying: buffered, not written
+	_ = hub.BroadcastPriceRecomputed(ctx, auctionID, 200, 0, "bid_removed") // id 2
+	if rec.Body.Len() != 0 {
+		t.Fatalf("live events must be buffered during replay")
+	}
+
+	hub.replay(ctx, client, 1)
+	if got := strings.Count(rec.Body.String(), "id: 2\n"); got != 1 {
+		t.Errorf("expected event 2 exactly once after replay, got %d", got)
+	}
+
+	// Live delivery resumes afterwards
+	_ = hub.BroadcastPriceRecomputed(ctx, auctionID, 300, 0, "bid_removed") // id 3
+	if !strings.Contains(rec.Body.String(), "id: 3\n") {
+		t.Errorf("expected live delivery after replay")
+	}
+}
+
+func TestRealtimeReplayTruncatedHistory(t *testing.T) {
+	ctx := context.Background()
+	hub := newRealtimeService(nil, nil, NewMemoryEventLog(2))
+
+	const auctionID int64 = 7
+	for i := 0; i < 4; i++ {
+		_ = hub.BroadcastPriceRecomputed(ctx, auctionID, float64(i), 0, "bid_removed")
+	}
+
+	rec := newTestSSEClient(t, hub, "stale", auctionID, nil)
+	hub.hub.mu.RLock()
+	client := hub.hub.clients["stale"]
+	hub.hub.mu.RUnlock()
+	hub.replay(ctx, client, 1)
+
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "event: resync") {
+		t.Errorf("expected resync before replaying a truncated history, got %q", body)
+	}
+	if !strings.Contains(body, "id: 3\n") || !strings.Contains(body, "id: 4\n") {
+		t.Errorf("expected retained events 3 and 4 to be replayed")
+	}
+}
+
+func TestRealtimeSendIfNewConcurrent(t *testing.T) {
+	hub := newRealtimeService(nil, nil, NewMemoryEventLog(0))
+	rec := newTestSSEClient(t, hub, "racing", 8, nil)
+	hub.hub.mu.RLock()
+	client := hub.hub.clients["racing"]
+	hub.hub.mu.RUnlock()
+
+	// Replay and live fan-out racing on the same event must deliver it once
+	event := &AuctionEvent{ID: 1, EventType: EventResync, Data: map[string]interface{}{"auction_id": 8}}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hub.sendIfNew(client, event)
+		}()
+	}
+	wg.Wait()
+	if got := strings.Count(rec.Body.String(), "id: 1\n"); got != 1 {
+		t.Errorf("expected event 1 exactly once, got %d", got)
+	}
+}
@@ -106,7 +106,7 @@ func (r *Repository) GetAuctionWithDetails(ctx context.Context, auctionID int64)
 			p.title as product_title, p.slug as product_slug,
 			(SELECT m.gcs_path
 			 FROM media m
-			 WHERE m.product_id = p.id AND m.kind = 'image' AND m.archived_at IS NULL
+			 WHERE m.product_id = p.id AND m.kind = 'image' AND m.archived_at IS NULL AND m.processing_status = 'ready'
 			 ORDER BY m.created_at ASC
 			 LIMIT 1) as thumbnail_url,
 			COALESCE(MAX(b.amount), a.start_price) as current_price,
@@ -239,7 +239,7 @@ func (r *Repository) ListAuctionsWithDetails(ctx context.Context, filters *Aucti
 			p.title as product_title, p.slug as product_slug,
 			(SELECT m.gcs_path
 			 FROM media m
-			 WHERE m.product_id = p.id AND m.kind = 'image' AND m.archived_at IS NULL
+			 WHERE m.product_id = p.id AND m.kind = 'image' AND m.archived_at IS NULL AND m.processing_status = 'ready'
 			 ORDER BY m.created_at ASC
 			 LIMIT 1) as thumbnail_url,
 			COALESCE(MAX(b.amount), a.start_price) as current_price,
@@ -8,6 +8,7 @@ import (
 
 	"encore.app/pkg/audit"
 	"encore.app/pkg/errs"
+	"encore.app/pkg/outbox"
 	"encore.app/svc/notifications"
 	"encore.dev/storage/sqldb"
 )
@@ -145,10 +146,16 @@ func (s *ReserveService) ProcessAuctionEnd(ctx context.Context, auctionID int64)
 		}
 	}
 
+	// Queue outcome notifications in the same transaction so a rollback never notifies
+	if err := outbox.Add(ctx, tx, s.auctionEndMessages(ctx, auction, result)...); err != nil {
+		return nil, fmt.Errorf("failed to queue auction end notifications: %w", err)
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	notifications.KickOutbox()
 
 	// Broadcast auction ended event
 	realtimeService := GetRealtimeService()
@@ -186,8 +193,12 @@ func (s *ReserveService) ProcessAuctionEnd(ctx context.Context, auctionID int64)
 		fmt.Printf("Failed to log audit entry for auction end: %v\n", err)
 	}
 
-	// Send notifications based on outcome
-	go s.sendAuctionEndNotifications(ctx, auction, result)
+	// Relist or offer to the runner-up according to the auction's policy
+	if result.Outcome == AuctionOutcomeReserveNotMet {
+		if err := NewFailurePolicyService(s.db).HandleFailedAuction(ctx, auctionID, FailureReasonReserveNotMet); err != nil {
+			fmt.Printf("Failed to apply failure policy for auction %d: %v\n", auctionID, err)
+		}
+	}
 
 	return result, nil
 }
@@ -494,8 +505,8 @@ func (s *ReserveService) getShippingFee(ctx context.Context, userID int64) (floa
 	return shippingFeeNet * (1 + vatRate), nil
 }
 
-// sendAuctionEndNotifications sends notifications when an auction ends
-func (s *ReserveService) sendAuctionEndNotifications(ctx context.Context, auction *Auction, result *AuctionEndResult) {
+// auctionEndMessages builds the outcome notifications for winner, bidders and watchers
+func (s *ReserveService) auctionEndMessages(ctx context.Context, auction *Auction, result *AuctionEndResult) []outbox.Message {
 	// Get product title for context
 	var productTitle string
 	if err := s.db.QueryRow(ctx, "SELECT title FROM products WHERE id = $1", auction.ProductID).Scan(&productTitle); err != nil {
@@ -509,7 +520,9 @@ func (s *ReserveService) sendAuctionEndNotifications(ctx context.Context, auctio
 		"message":       result.Message,
 		"language":      "ar",
 	}
+	eventKey := outbox.Key("auction", auction.ID, "ended")
 
+	var msgs []outbox.Message
 	switch result.Outcome {
 	case AuctionOutcomeWinner:
 		if result.WinnerBid != nil {
@@ -526,18 +539,14 @@ func (s *ReserveService) sendAuctionEndNotifications(ctx context.Context, auctio
 			_ = s.db.QueryRow(ctx, "SELECT email, name FROM users WHERE id = $1", result.WinnerBid.UserID).Scan(&winnerEmail, &winnerName)
 			if winnerEmail != "" {
 				winnerPayload["email"] = winnerEmail
-				if winnerName != "" { winnerPayload["name"] = winnerName }
-			}
-			
-			if _, err := notifications.EnqueueInternal(ctx, result.WinnerBid.UserID, "auction_ended_winner", winnerPayload); err != nil {
-				fmt.Printf("Failed to send winner internal notification: %v\n", err)
-			}
-			if _, err := notifications.EnqueueEmail(ctx, result.WinnerBid.UserID, "auction_ended_winner", winnerPayload); err != nil {
-				fmt.Printf("Failed to send winner email notification: %v\n", err)
+				if winnerName != "" {
+					winnerPayload["name"] = winnerName
+				}
 			}
+			msgs = append(msgs, recipientMessages(result.WinnerBid.UserID, "auction_ended_winner", winnerPayload, eventKey)...)
 
 			// Notify other bidders they lost
-			s.notifyOtherBidders(ctx, auction.ID, result.WinnerBid.UserID, basePayload)
+			msgs = append(msgs, s.otherBidderMessages(ctx, auction.ID, result.WinnerBid.UserID, basePayload, eventKey)...)
 		}
 
 	case AuctionOutcomeReserveNotMet:
@@ -556,18 +565,14 @@ func (s *ReserveService) sendAuctionEndNotifications(ctx context.Context, auctio
 			_ = s.db.QueryRow(ctx, "SELECT email, name FROM users WHERE id = $1", result.HighestBid.UserID).Scan(&hbEmail, &hbName)
 			if hbEmail != "" {
 				bidderPayload["email"] = hbEmail
-				if hbName != "" { bidderPayload["name"] = hbName }
-			}
-			
-			if _, err := notifications.EnqueueInternal(ctx, result.HighestBid.UserID, "auction_ended_reserve_not_met", bidderPayload); err != nil {
-				fmt.Printf("Failed to send reserve not met internal notification: %v\n", err)
-			}
-			if _, err := notifications.EnqueueEmail(ctx, result.HighestBid.UserID, "auction_ended_reserve_not_met", bidderPayload); err != nil {
-				fmt.Printf("Failed to send reserve not met email notification: %v\n", err)
+				if hbName != "" {
+					bidderPayload["name"] = hbName
+				}
 			}
+			msgs = append(msgs, recipientMessages(result.HighestBid.UserID, "auction_ended_reserve_not_met", bidderPayload, eventKey)...)
 
 			// Notify other bidders
-			s.notifyOtherBidders(ctx, auction.ID, result.HighestBid.UserID, basePayload)
+			msgs = append(msgs, s.otherBidderMessages(ctx, auction.ID, result.HighestBid.UserID, basePayload, eventKey)...)
 		}
 
 	case AuctionOutcomeNoBids:
@@ -575,53 +580,57 @@ func (s *ReserveService) sendAuctionEndNotifications(ctx context.Context, auctio
 		fmt.Printf("Auction %d ended with no bids\n", auction.ID)
 	}
 
-	// Notify auction watchers (users who viewed/watched but didn't bid)
-	s.notifyAuctionWatchers(ctx, auction.ID, basePayload)
+	// Notify auction watchers who didn't bid (bidders were notified above)
+	return append(msgs, s.watcherMessages(ctx, auction, result)...)
 }
 
-// notifyOtherBidders notifies all bidders except the winner/highest bidder
-func (s *ReserveService) notifyOtherBidders(ctx context.Context, auctionID, excludeUserID int64, basePayload map[string]interface{}) {
-    query := `
-        SELECT DISTINCT b.user_id, u.email, u.name
-        FROM bids b
-        JOIN users u ON u.id = b.user_id
-        WHERE b.auction_id = $1 AND b.user_id != $2`
-    
-    rows, err := s.db.Query(ctx, query, auctionID, excludeUserID)
-    if err != nil {
-        fmt.Printf("Failed to get other bidders: %v\n", err)
-        return
-    }
-    defer rows.Close()
-
-    for rows.Next() {
-        var userID int64
-        var email, name string
-        if err := rows.Scan(&userID, &email, &name); err != nil {
-            continue
-        }
-
-        // Clone payload and add recipient email/name
-        payload := make(map[string]interface{})
-        for k, v := range basePayload { payload[k] = v }
-        if email != "" { payload["email"] = email }
-        if name != "" { payload["name"] = name }
-
-        if _, err := notifications.EnqueueInternal(ctx, userID, "auction_ended_lost", payload); err != nil {
-            fmt.Printf("Failed to send lost internal notification to user %d: %v\n", userID, err)
-        }
-        if email != "" {
-            if _, err := notifications.EnqueueEmail(ctx, userID, "auction_ended_lost", payload); err != nil {
-                fmt.Printf("Failed to send lost email notification to user %d: %v\n", userID, err)
-            }
-        }
-    }
+// otherBidderMessages notifies all bidders except the winner/highest bidder
+func (s *ReserveService) otherBidderMessages(ctx context.Context, auctionID, excludeUserID int64, basePayload map[string]interface{}, eventKey string) []outbox.Message {
+	query := `
+		SELECT DISTINCT b.user_id, u.email, u.name
+		FROM bids b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.auction_id = $1 AND b.user_id != $2`
+
+	rows, err := s.db.Query(ctx, query, auctionID, excludeUserID)
+	if err != nil {
+		fmt.Printf("Failed to get other bidders: %v\n", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var msgs []outbox.Message
+	for rows.Next() {
+		var userID int64
+		var email, name string
+		if err := rows.Scan(&userID, &email, &name); err != nil {
+			continue
+		}
+
+		// Clone payload and add recipient email/name
+		payload := make(map[string]interface{})
+		for k, v := range basePayload {
+			payload[k] = v
+		}
+		if email != "" {
+			payload["email"] = email
+		}
+		if name != "" {
+			payload["name"] = name
+		}
+		msgs = append(msgs, recipientMessages(userID, "auction_ended_lost", payload, eventKey)...)
+	}
+	return msgs
 }
 
-// notifyAuctionWatchers notifies users who might be interested in the auction outcome
-func (s *ReserveService) notifyAuctionWatchers(ctx context.Context, auctionID int64, basePayload map[string]interface{}) {
-	// For now, this is a placeholder - in a full implementation, you might track
-	// users who viewed the auction page, added to watchlist, etc.
-	// We'll skip this for now to avoid complexity
-	fmt.Printf("Auction %d ended - watchers notification placeholder\n", auctionID)
+// watcherMessages notifies users following the auction about its outcome
+func (s *ReserveService) watcherMessages(ctx context.Context, auction *Auction, result *AuctionEndResult) []outbox.Message {
+	var finalPrice float64
+	switch {
+	case result.WinnerBid != nil:
+		finalPrice = result.WinnerBid.Amount
+	case result.HighestBid != nil:
+		finalPrice = result.HighestBid.Amount
+	}
+	return NewWatchlistService(s.db).auctionEndedMessages(ctx, auction, string(result.Outcome), finalPrice, true, 0)
 }
@@ -24,11 +24,13 @@ type Service struct {
 	reserveService   *ReserveService
 	rateLimitService *RateLimitService
 	bidMgmtService   *BidManagementService
-	storage          *storagegcs.Client
+	watchlistService *WatchlistService
+	failurePolicy    *FailurePolicyService
+	storage          storagegcs.Storage
 }
 
 // NewService creates a new auction service
-func NewService(db *sqldb.Database, storage *storagegcs.Client) *Service {
+func NewService(db *sqldb.Database, storage storagegcs.Storage) *Service {
 	// Initialize realtime service
 	InitRealtimeService(db)
 
@@ -38,6 +40,8 @@ func NewService(db *sqldb.Database, storage *storagegcs.Client) *Service {
 		reserveService:   NewReserveService(db),
 		rateLimitService: NewRateLimitService(db),
 		bidMgmtService:   NewBidManagementService(db),
+		watchlistService: NewWatchlistService(db),
+		failurePolicy:    NewFailurePolicyService(db),
 		storage:          storage,
 	}
 
@@ -359,6 +363,11 @@ func (s *Service) MarkWinnerUnpaid(ctx context.Context, auctionID int64) error {
 		}
 	}
 
+	// Relist or offer to the runner-up according to the auction's policy
+	if err := s.failurePolicy.HandleFailedAuction(ctx, auctionID, FailureReasonWinnerUnpaid); err != nil {
+		fmt.Printf("Failed to apply failure policy for auction %d: %v\n", auctionID, err)
+	}
+
 	return nil
 }
 
@@ -478,6 +487,8 @@ func TickAuctions(ctx context.Context) error {
 						_, _ = notifications.EnqueueEmail(ctx, winnerUserID, "auction_ended_winner", payload)
 					}
 				}
+				// Let watchers know the result
+				s.watchlistService.NotifyAuctionEnded(ctx, &det.Auction, string(AuctionOutcomeWinner), det.CurrentPrice, false, winnerUserID)
 			} else {
 				// No winner: end auction (not cancelled) and return product to available
 				reason := "reserve_not_met"
@@ -518,8 +529,8 @@ func TickAuctions(ctx context.Context) error {
 				})
 
 				// If there were bids but reserve not met, notify highest bidder
+				var hbUserID int64
 				if winnerExists && !reserveOk {
-					var hbUserID int64
 					var hbAmount float64
 					_ = s.db.QueryRow(ctx, `SELECT user_id, amount FROM bids WHERE auction_id=$1 ORDER BY amount DESC, created_at DESC LIMIT 1`, det.ID).Scan(&hbUserID, &hbAmount)
 					if hbUserID != 0 {
@@ -550,6 +561,16 @@ func TickAuctions(ctx context.Context) error {
 						}
 					}
 				}
+
+				// Let watchers know the result
+				s.watchlistService.NotifyAuctionEnded(ctx, &det.Auction, reason, det.CurrentPrice, false, hbUserID)
+
+				// Relist or offer to the runner-up according to the auction's policy
+				if reason == FailureReasonReserveNotMet {
+					if err := s.failurePolicy.HandleFailedAuction(ctx, a.ID, reason); err != nil {
+						fmt.Printf("[AUCTION_TICK] failure policy for auction %d failed: %v\n", a.ID, err)
+					}
+				}
 			}
 		}
 	}
@@ -580,6 +601,16 @@ func TickAuctions(ctx context.Context) error {
 		}
 	}
 
+	// Expire unanswered second-chance offers and move on to the next bidder
+	if err := s.failurePolicy.ExpireOffers(ctx); err != nil {
+		fmt.Printf("[AUCTION_TICK] second-chance expiry failed: %v\n", err)
+	}
+
+	// Watchlist reminders (starting soon / ending soon)
+	if err := s.watchlistService.ProcessReminders(ctx); err != nil {
+		fmt.Printf("[AUCTION_TICK] watchlist reminders failed: %v\n", err)
+	}
+
 	return nil
 }
 
@@ -829,7 +860,7 @@ func (s *Service) getProductThumbnail(ctx context.Context, productID int64) (*st
 	query := `
 		SELECT COALESCE(thumb_path, gcs_path) as path
 		FROM media
-		WHERE product_id = $1 AND kind = 'image' AND archived_at IS NULL
+		WHERE product_id = $1 AND kind = 'image' AND archived_at IS NULL AND processing_status = 'ready'
 		ORDER BY created_at ASC
 		LIMIT 1
 	`
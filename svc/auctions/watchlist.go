@@ -0,0 +1,336 @@
+package auctions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/outbox"
+	"encore.app/svc/notifications"
+	"encore.dev/storage/sqldb"
+)
+
+const (
+	// watchStartingSoonWindow is how long before start_at watchers get the "starting soon" reminder
+	watchStartingSoonWindow = 30 * time.Minute
+	// watchEndingSoonMinutes is how many minutes before end_at watchers get the "ending soon" reminder
+	watchEndingSoonMinutes = 15
+	// maxWatchlistSize caps how many auctions a single user can follow
+	maxWatchlistSize = 200
+)
+
+// WatchlistService manages auction watchers and the notifications they receive
+type WatchlistService struct {
+	db   *sqldb.Database
+	repo *Repository
+}
+
+// NewWatchlistService creates a new watchlist service
+func NewWatchlistService(db *sqldb.Database) *WatchlistService {
+	return &WatchlistService{
+		db:   db,
+		repo: NewRepository(db),
+	}
+}
+
+// auctionWatcher is a notification recipient from the watchers table
+type auctionWatcher struct {
+	UserID int64
+	Email  string
+	Name   string
+}
+
+// Watch adds the auction to the user's watchlist (idempotent)
+func (s *WatchlistService) Watch(ctx context.Context, auctionID, userID int64) error {
+	auction, err := s.repo.GetAuction(ctx, auctionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &errs.Error{Code: errs.NotFound, Message: "المزاد غير موجود"}
+		}
+		return fmt.Errorf("failed to get auction: %w", err)
+	}
+	if auction.Status != AuctionStatusScheduled && auction.Status != AuctionStatusLive {
+		return &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: "لا يمكن متابعة مزاد منتهٍ أو ملغى",
+		}
+	}
+
+	var count int
+	if err := s.db.QueryRow(ctx, `SELECT COUNT(*) FROM auction_watchers WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count watchlist: %w", err)
+	}
+	if count >= maxWatchlistSize {
+		return &errs.Error{
+			Code:    errs.ResourceExhausted,
+			Message: fmt.Sprintf("لا يمكن متابعة أكثر من %d مزاد", maxWatchlistSize),
+		}
+	}
+
+	if _, err := s.db.Exec(ctx, `
+		INSERT INTO auction_watchers (auction_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (auction_id, user_id) DO NOTHING`, auctionID, userID); err != nil {
+		return fmt.Errorf("failed to watch auction: %w", err)
+	}
+
+	return nil
+}
+
+// Unwatch removes the auction from the user's watchlist
+func (s *WatchlistService) Unwatch(ctx context.Context, auctionID, userID int64) error {
+	res, err := s.db.Exec(ctx, `DELETE FROM auction_watchers WHERE auction_id = $1 AND user_id = $2`, auctionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unwatch auction: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: "المزاد غير موجود في قائمة المتابعة",
+		}
+	}
+	return nil
+}
+
+// ListWatchedAuctionIDs returns the user's watched auctions, most recently watched first
+func (s *WatchlistService) ListWatchedAuctionIDs(ctx context.Context, userID int64) ([]int64, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT auction_id
+		FROM auction_watchers
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`, userID, maxWatchlistSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlist: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan watchlist: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// getWatchers returns active watchers of an auction. Bidders can be skipped because they
+// already receive bid/outcome notifications of their own.
+func (s *WatchlistService) getWatchers(ctx context.Context, auctionID int64, skipBidders bool, excludeUserID int64) ([]auctionWatcher, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT w.user_id, COALESCE(u.email, ''), COALESCE(u.name, '')
+		FROM auction_watchers w
+		JOIN users u ON u.id = w.user_id
+		WHERE w.auction_id = $1
+		  AND u.state = 'active'
+		  AND w.user_id <> $2
+		  AND (NOT $3 OR NOT EXISTS (
+			SELECT 1 FROM bids b WHERE b.auction_id = w.auction_id AND b.user_id = w.user_id
+		  ))`, auctionID, excludeUserID, skipBidders)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query auction watchers: %w", err)
+	}
+	defer rows.Close()
+
+	var watchers []auctionWatcher
+	for rows.Next() {
+		var w auctionWatcher
+		if err := rows.Scan(&w.UserID, &w.Email, &w.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan auction watcher: %w", err)
+		}
+		watchers = append(watchers, w)
+	}
+	return watchers, nil
+}
+
+// NotifyPriceChanged tells non-bidding watchers that the current price moved (inbox only, to avoid email spam)
+func (s *WatchlistService) NotifyPriceChanged(ctx context.Context, auction *Auction, newPrice float64, reason string, excludeUserID int64, eventKey string) {
+	queueNotifications(ctx, s.db, s.priceChangedMessages(ctx, auction, newPrice, reason, excludeUserID, eventKey))
+}
+
+// priceChangedMessages builds the watcher price-change messages for the outbox
+func (s *WatchlistService) priceChangedMessages(ctx context.Context, auction *Auction, newPrice float64, reason string, excludeUserID int64, eventKey string) []outbox.Message {
+	watchers, err := s.getWatchers(ctx, auction.ID, true, excludeUserID)
+	if err != nil {
+		fmt.Printf("Failed to get auction watchers: %v\n", err)
+		return nil
+	}
+	if len(watchers) == 0 {
+		return nil
+	}
+
+	productTitle := s.productTitle(ctx, auction)
+	var msgs []outbox.Message
+	for _, w := range watchers {
+		payload := map[string]interface{}{
+			"auction_id":    fmt.Sprint(auction.ID),
+			"product_title": productTitle,
+			"new_price":     fmt.Sprintf("%.2f", newPrice),
+			"reason":        reason,
+			"email":         w.Email,
+			"name":          w.Name,
+			"language":      "ar",
+		}
+		msgs = append(msgs, outbox.Internal(w.UserID, "auction_watch_price_changed", payload,
+			outbox.Key(eventKey, "auction_watch_price_changed", outbox.KindInternal, w.UserID)))
+	}
+	return msgs
+}
+
+// NotifyAuctionEnded sends the auction result to watchers (inbox + email)
+func (s *WatchlistService) NotifyAuctionEnded(ctx context.Context, auction *Auction, outcome string, finalPrice float64, skipBidders bool, excludeUserID int64) {
+	queueNotifications(ctx, s.db, s.auctionEndedMessages(ctx, auction, outcome, finalPrice, skipBidders, excludeUserID))
+}
+
+// auctionEndedMessages builds the watcher result messages for the outbox
+func (s *WatchlistService) auctionEndedMessages(ctx context.Context, auction *Auction, outcome string, finalPrice float64, skipBidders bool, excludeUserID int64) []outbox.Message {
+	watchers, err := s.getWatchers(ctx, auction.ID, skipBidders, excludeUserID)
+	if err != nil {
+		fmt.Printf("Failed to get auction watchers: %v\n", err)
+		return nil
+	}
+	if len(watchers) == 0 {
+		return nil
+	}
+
+	productTitle := s.productTitle(ctx, auction)
+	eventKey := outbox.Key("auction", auction.ID, "ended")
+	var msgs []outbox.Message
+	for _, w := range watchers {
+		payload := map[string]interface{}{
+			"auction_id":    fmt.Sprint(auction.ID),
+			"product_title": productTitle,
+			"outcome":       outcome,
+			"final_price":   fmt.Sprintf("%.2f", finalPrice),
+			"language":      "ar",
+			"name":          w.Name,
+		}
+		if w.Email != "" {
+			payload["email"] = w.Email
+		}
+		msgs = append(msgs, recipientMessages(w.UserID, "auction_watch_ended", payload, eventKey)...)
+	}
+	return msgs
+}
+
+// ProcessReminders sends "starting soon" and "ending in N minutes" reminders. Each reminder is
+// claimed with an UPDATE ... RETURNING so it is sent at most once per watcher.
+func (s *WatchlistService) ProcessReminders(ctx context.Context) error {
+	startingRows, err := s.db.Query(ctx, `
+		UPDATE auction_watchers w
+		SET starting_soon_notified_at = NOW()
+		FROM auctions a
+		WHERE a.id = w.auction_id
+		  AND w.starting_soon_notified_at IS NULL
+		  AND a.status = 'scheduled'
+		  AND a.start_at > NOW()
+		  AND a.start_at <= NOW() + make_interval(secs => $1)
+		RETURNING w.user_id, a.id, a.product_id, a.start_at`, watchStartingSoonWindow.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to claim starting soon reminders: %w", err)
+	}
+	starting, err := scanWatchReminders(startingRows)
+	if err != nil {
+		return err
+	}
+	for _, r := range starting {
+		s.sendReminder(ctx, r, "auction_watch_starting_soon", map[string]interface{}{
+			"start_at": r.At.UTC().Format(time.RFC3339),
+			"minutes":  fmt.Sprint(int(time.Until(r.At).Minutes() + 0.5)),
+		})
+	}
+
+	endingRows, err := s.db.Query(ctx, `
+		UPDATE auction_watchers w
+		SET ending_soon_notified_at = NOW()
+		FROM auctions a
+		WHERE a.id = w.auction_id
+		  AND w.ending_soon_notified_at IS NULL
+		  AND a.status = 'live'
+		  AND a.end_at > NOW()
+		  AND a.end_at <= NOW() + make_interval(mins => $1)
+		RETURNING w.user_id, a.id, a.product_id, a.end_at`, watchEndingSoonMinutes)
+	if err != nil {
+		return fmt.Errorf("failed to claim ending soon reminders: %w", err)
+	}
+	ending, err := scanWatchReminders(endingRows)
+	if err != nil {
+		return err
+	}
+	for _, r := range ending {
+		s.sendReminder(ctx, r, "auction_watch_ending_soon", map[string]interface{}{
+			"end_at":  r.At.UTC().Format(time.RFC3339),
+			"minutes": fmt.Sprint(int(time.Until(r.At).Minutes() + 0.5)),
+		})
+	}
+
+	if len(starting) > 0 || len(ending) > 0 {
+		fmt.Printf("[WATCHLIST] reminders sent: starting_soon=%d ending_soon=%d\n", len(starting), len(ending))
+	}
+	return nil
+}
+
+// watchReminder is a claimed reminder row
+type watchReminder struct {
+	UserID    int64
+	AuctionID int64
+	ProductID int64
+	At        time.Time
+}
+
+func scanWatchReminders(rows *sqldb.Rows) ([]watchReminder, error) {
+	defer rows.Close()
+	var out []watchReminder
+	for rows.Next() {
+		var r watchReminder
+		if err := rows.Scan(&r.UserID, &r.AuctionID, &r.ProductID, &r.At); err != nil {
+			return nil, fmt.Errorf("failed to scan watch reminder: %w", err)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// sendReminder enqueues a reminder notification (inbox + email) for one watcher
+func (s *WatchlistService) sendReminder(ctx context.Context, r watchReminder, templateID string, extra map[string]interface{}) {
+	productTitle := s.productTitle(ctx, &Auction{ID: r.AuctionID, ProductID: r.ProductID})
+
+	payload := map[string]interface{}{
+		"auction_id":    fmt.Sprint(r.AuctionID),
+		"product_title": productTitle,
+		"language":      "ar",
+		"AuctionURL":    fmt.Sprintf("https://dughairiloft.com/auctions/%d", r.AuctionID),
+	}
+	for k, v := range extra {
+		payload[k] = v
+	}
+
+	var email, name string
+	_ = s.db.QueryRow(ctx, `SELECT COALESCE(email, ''), COALESCE(name, '') FROM users WHERE id = $1`, r.UserID).Scan(&email, &name)
+	if name != "" {
+		payload["name"] = name
+	}
+
+	if _, err := notifications.EnqueueInternal(ctx, r.UserID, templateID, payload); err != nil {
+		fmt.Printf("Failed to send %s notification to watcher %d: %v\n", templateID, r.UserID, err)
+	}
+	if email != "" {
+		payload["email"] = email
+		if _, err := notifications.EnqueueEmail(ctx, r.UserID, templateID, payload); err != nil {
+			fmt.Printf("Failed to send %s email to watcher %d: %v\n", templateID, r.UserID, err)
+		}
+	}
+}
+
+func (s *WatchlistService) productTitle(ctx context.Context, auction *Auction) string {
+	var productTitle string
+	if err := s.db.QueryRow(ctx, "SELECT title FROM products WHERE id = $1", auction.ProductID).Scan(&productTitle); err != nil || productTitle == "" {
+		productTitle = fmt.Sprintf("المزاد #%d", auction.ID)
+	}
+	return productTitle
+}
@@ -1,6 +1,7 @@
 package auctions
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"encore.app/pkg/httpx"
 	"github.com/gorilla/websocket"
 	"encore.dev/beta/auth"
 )
@@ -45,6 +47,14 @@ func HandleWebSocket(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Limit new connections per client IP across instances before upgrading
+	if svc := GetService(); svc != nil && svc.rateLimitService != nil {
+		if err := svc.rateLimitService.CheckWebSocketRateLimit(req.Context(), httpx.GetClientIP(req)); err != nil {
+			http.Error(w, "too many connections", http.StatusTooManyRequests)
+			return
+		}
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(w, req, nil)
 	if err != nil {
@@ -164,6 +174,18 @@ func handleClientMessage(client *Client, msg map[string]interface{}, service *Re
 	case "subscribe":
 		// Handle subscription updates if needed
 		log.Printf("Client %s subscribed to additional events", client.ID)
+	case "resume":
+		// Replay events missed since last_event_id before live delivery continues
+		var lastEventID int64
+		switch v := msg["last_event_id"].(type) {
+		case float64:
+			lastEventID = int64(v)
+		case string:
+			lastEventID = parseLastEventID(v)
+		}
+		if lastEventID > 0 {
+			service.replay(context.Background(), client, lastEventID)
+		}
 	}
 }
 
@@ -181,6 +203,10 @@ func (s *RealtimeService) sendWSEvent(client *Client, event *AuctionEvent) {
 	// Set write deadline
 	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 	
+	if event.ID > client.lastEventID {
+		client.lastEventID = event.ID
+	}
+
 	// Send JSON message
 	if err := conn.WriteJSON(event); err != nil {
 		log.Printf("Error sending WebSocket message to client %s: %v", client.ID, err)
@@ -47,10 +47,19 @@ func (s *Service) LoginRaw(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := s.LoginUser(ctx, &req)
 	if err != nil {
+		if writeLoginThrottled(w, r, err) {
+			return
+		}
 		writeError(w, r, http.StatusUnauthorized, "unauthenticated", err.Error())
 		return
 	}
 
+	// Second factor pending: no tokens yet, the client completes /auth/login/2fa
+	if resp.TwoFactorRequired {
+		writeJSON(w, r, resp)
+		return
+	}
+
 	// Set refresh token as HttpOnly cookie
 	setRefreshCookie(w, resp.RefreshToken)
 
@@ -65,6 +74,29 @@ func (s *Service) LoginRaw(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, r, resp)
 }
 
+// LoginTwoFactor completes a login challenge with a TOTP, SMS or recovery code; sets the
+// HttpOnly refresh cookie like Login
+//
+//encore:api public raw method=POST path=/auth/login/2fa
+func (s *Service) LoginTwoFactorRaw(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req VerifyLoginChallengeRequest
+	if err := decodeJSON(r, &req); err != nil || req.ChallengeID == "" || req.Code == "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_argument", "invalid request body")
+		return
+	}
+
+	resp, err := s.completeLoginChallenge(ctx, &req)
+	if err != nil {
+		writeError(w, r, http.StatusUnauthorized, "unauthenticated", err.Error())
+		return
+	}
+
+	setRefreshCookie(w, resp.RefreshToken)
+	resp.RefreshToken = ""
+	writeJSON(w, r, resp)
+}
+
 // VerifyEmail verifies a user's email address
 //
 //encore:api public method=POST path=/auth/verify-email
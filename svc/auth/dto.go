@@ -28,13 +28,20 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
-// LoginResponse represents the user login response
+// LoginResponse represents the user login response. When the account uses two-factor
+// authentication no tokens are returned: TwoFactorRequired is set and the client completes
+// the challenge with /auth/login/2fa.
 type LoginResponse struct {
-	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
+	AccessToken  string    `json:"access_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
 	ExpiresAt    time.Time `json:"expires_at"`
-	TokenType    string    `json:"token_type"`
+	TokenType    string    `json:"token_type,omitempty"`
 	User         UserInfo  `json:"user"`
+
+	TwoFactorRequired      bool     `json:"two_factor_required,omitempty"`
+	ChallengeID            string   `json:"challenge_id,omitempty"`
+	TwoFactorMethods       []string `json:"two_factor_methods,omitempty"`        // totp, sms, recovery_code
+	TwoFactorSetupRequired bool     `json:"two_factor_setup_required,omitempty"` // admin must enrol TOTP after signing in
 }
 
 // UserInfo represents user information
@@ -15,11 +15,19 @@ const (
 	AuthVerificationCodeUsed    = "AUTH_VERIFICATION_CODE_USED"
 	AuthEmailAlreadyVerified    = "AUTH_EMAIL_ALREADY_VERIFIED"
 	AuthInvalidRefreshToken     = "AUTH_INVALID_REFRESH_TOKEN"
+	AuthRefreshTokenReused      = "AUTH_REFRESH_TOKEN_REUSED"
 	AuthRateLimitExceeded       = "AUTH_RATE_LIMIT_EXCEEDED"
 	AuthTokenExpired            = "AUTH_TOKEN_EXPIRED"
 	AuthUnauthenticated         = "AUTH_UNAUTHENTICATED"
 	AuthForbidden               = "AUTH_FORBIDDEN"
 	AuthEmailVerifyRequired     = "AUTH_EMAIL_VERIFY_REQUIRED"
+	AuthTwoFactorInvalidCode    = "AUTH_2FA_INVALID_CODE"
+	AuthTwoFactorChallenge      = "AUTH_2FA_CHALLENGE_INVALID"
+	AuthTwoFactorRequired       = "AUTH_2FA_REQUIRED"
+	AuthTwoFactorUnavailable    = "AUTH_2FA_UNAVAILABLE"
+	AuthRegistrationDisabled    = "AUTH_REGISTRATION_DISABLED"
+	AuthAccountLocked           = "AUTH_ACCOUNT_LOCKED"
+	AuthInvalidUnlockToken      = "AUTH_INVALID_UNLOCK_TOKEN"
 )
 
 // Authentication error messages
@@ -84,6 +92,50 @@ var (
 		Message: "رمز التحديث غير صالح أو منتهي الصلاحية",
 	}
 
+	// ErrRefreshTokenReused indicates that an already rotated refresh token was presented again;
+	// every token of that login was revoked
+	ErrRefreshTokenReused = &errs.Error{
+		Code:    AuthRefreshTokenReused,
+		Message: "تم استخدام رمز التحديث مسبقاً. لأمان حسابك تم إنهاء الجلسة، يرجى تسجيل الدخول مجدداً",
+	}
+
+	// ErrTwoFactorInvalidCode indicates a wrong TOTP, SMS or recovery code
+	ErrTwoFactorInvalidCode = &errs.Error{
+		Code:    AuthTwoFactorInvalidCode,
+		Message: "رمز التحقق بخطوتين غير صحيح",
+	}
+
+	// ErrLoginChallengeInvalid indicates an unknown, expired, completed or exhausted login challenge
+	ErrLoginChallengeInvalid = &errs.Error{
+		Code:    AuthTwoFactorChallenge,
+		Message: "انتهت صلاحية طلب التحقق. يرجى تسجيل الدخول مجدداً",
+	}
+
+	// ErrTwoFactorRequired indicates that the account cannot sign in without a second factor
+	ErrTwoFactorRequired = &errs.Error{
+		Code:    AuthTwoFactorRequired,
+		Message: "التحقق بخطوتين إلزامي لهذا الحساب ولا توجد وسيلة تحقق متاحة. يرجى التواصل مع الدعم",
+	}
+
+	// ErrTwoFactorUnavailable indicates that authenticator apps cannot be enrolled because
+	// TwoFactorEncryptionKey is not configured
+	ErrTwoFactorUnavailable = &errs.Error{
+		Code:    AuthTwoFactorUnavailable,
+		Message: "التحقق بتطبيق المصادقة غير متاح حالياً. يرجى المحاولة لاحقاً",
+	}
+
+	// ErrRegistrationDisabled indicates that new sign-ups are switched off (app.registration_enabled)
+	ErrRegistrationDisabled = &errs.Error{
+		Code:    AuthRegistrationDisabled,
+		Message: "التسجيل الجديد متوقف مؤقتاً. يرجى المحاولة لاحقاً",
+	}
+
+	// ErrInvalidUnlockToken indicates an unknown, used or expired account unlock link
+	ErrInvalidUnlockToken = &errs.Error{
+		Code:    AuthInvalidUnlockToken,
+		Message: "رابط فتح الحساب غير صالح أو منتهي الصلاحية",
+	}
+
 	// ErrRateLimitExceeded indicates that rate limit has been exceeded
 	ErrRateLimitExceeded = &errs.Error{
 		Code:    AuthRateLimitExceeded,
@@ -12,9 +12,18 @@ import (
 
 // AuthData represents the authentication data passed to authenticated endpoints
 type AuthData struct {
-	UserID int64  `json:"user_id"`
-	Role   string `json:"role"`
-	Email  string `json:"email"`
+	UserID    int64  `json:"user_id"`
+	Role      string `json:"role"`
+	Email     string `json:"email"`
+	SessionID string `json:"session_id,omitempty"` // login session (device) of the access token
+}
+
+// LoginSessionID implements authn.SessionBound
+func (d *AuthData) LoginSessionID() string {
+	if d == nil {
+		return ""
+	}
+	return d.SessionID
 }
 
 // AuthHandler validates JWT tokens and returns user authentication data
@@ -41,9 +50,10 @@ func (s *Service) AuthHandler(ctx context.Context, token string) (auth.UID, *Aut
 
 	// Return authentication data
 	authData := &AuthData{
-		UserID: claims.UserID,
-		Role:   claims.Role,
-		Email:  claims.Email,
+		UserID:    claims.UserID,
+		Role:      claims.Role,
+		Email:     claims.Email,
+		SessionID: claims.SessionID,
 	}
 
 	return auth.UID(userIDStr), authData, nil
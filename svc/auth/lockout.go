@@ -0,0 +1,353 @@
+// Package auth provides authentication and authorization services
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"encore.app/pkg/authn"
+	"encore.app/pkg/config"
+	"encore.app/pkg/errs"
+	"encore.app/pkg/logger"
+	"encore.app/pkg/ratelimit"
+	"encore.app/svc/notifications"
+	"encore.dev/storage/sqldb"
+)
+
+// Login lockout settings; attempts and lock duration come from security.max_login_attempts and
+// security.lockout_duration
+const (
+	// ipLockoutFactor scales the attempt limit for client IPs, which are shared by every
+	// account behind the same NAT
+	ipLockoutFactor = 4
+	// accountUnlockTokenTTL bounds the unlock link emailed when an account locks
+	accountUnlockTokenTTL = time.Hour
+	// loginFailureRetention keeps unlocked failure records for admin visibility
+	loginFailureRetention = 7 * 24 * time.Hour
+	// lockoutListWindow is how far back the admin list shows keys that failed without locking
+	lockoutListWindow   = 24 * time.Hour
+	maxLockoutListLimit = 200
+)
+
+// UnlockAccountRequest carries the token from an account unlock link
+type UnlockAccountRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// UnlockAccountResponse confirms the account was unlocked
+type UnlockAccountResponse struct {
+	Message string `json:"message"`
+	Success bool   `json:"success"`
+}
+
+// ListLoginLockoutsRequest filters the failed sign-in records shown to admins
+type ListLoginLockoutsRequest struct {
+	Scope      string `json:"scope"`       // "account", "ip" or empty for both
+	LockedOnly bool   `json:"locked_only"` // only keys that are locked right now
+	Limit      int    `json:"limit"`
+}
+
+// LoginLockoutItem is the failed sign-in record of an account or client IP
+type LoginLockoutItem struct {
+	Scope         string     `json:"scope"`
+	Key           string     `json:"key"` // email or IP
+	UserID        *int64     `json:"user_id,omitempty"`
+	Failures      int        `json:"failures"`
+	FirstFailedAt time.Time  `json:"first_failed_at"`
+	LastFailedAt  time.Time  `json:"last_failed_at"`
+	Locked        bool       `json:"locked"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	LockCount     int        `json:"lock_count"`
+	LastIP        string     `json:"last_ip,omitempty"`
+	LastUserAgent string     `json:"last_user_agent,omitempty"`
+}
+
+// ListLoginLockoutsResponse lists failed sign-in records, locked ones first
+type ListLoginLockoutsResponse struct {
+	Items []LoginLockoutItem `json:"items"`
+}
+
+// ClearLoginLockoutRequest names the account or client IP to unlock
+type ClearLoginLockoutRequest struct {
+	UserID int64  `json:"user_id"`
+	IP     string `json:"ip"`
+}
+
+// ClearLoginLockoutResponse reports whether there were failures to clear
+type ClearLoginLockoutResponse struct {
+	Cleared bool `json:"cleared"`
+}
+
+// UnlockAccount lifts a lockout using the link emailed when the account was locked
+//
+//encore:api public method=POST path=/auth/unlock-account
+func (s *Service) UnlockAccount(ctx context.Context, req *UnlockAccountRequest) (*UnlockAccountResponse, error) {
+	if err := s.verifyRateLimit.RecordAttempt(ratelimit.GenerateIPKey("unlock", getClientIP(ctx))); err != nil {
+		return nil, ErrRateLimitExceeded
+	}
+	token := strings.TrimSpace(req.Token)
+	if token == "" {
+		return nil, ErrInvalidUnlockToken
+	}
+
+	userID, err := s.repo.UseAccountUnlockToken(ctx, hashUnlockToken(token))
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, ErrInvalidUnlockToken
+	}
+	if err != nil {
+		return nil, NewInternalError("فشل التحقق من رابط فتح الحساب")
+	}
+	if _, err := s.repo.ClearAccountLockout(ctx, userID); err != nil {
+		return nil, NewInternalError("فشل فتح الحساب")
+	}
+
+	logger.Info(ctx, "Account unlocked via email link", logger.Fields{"user_id": userID})
+	return &UnlockAccountResponse{
+		Message: "تم فتح حسابك. يمكنك تسجيل الدخول الآن، وننصح بتغيير كلمة المرور إن لم تكن أنت من حاول الدخول",
+		Success: true,
+	}, nil
+}
+
+// ListLoginLockouts returns locked accounts and IPs and recent failed sign-ins.
+// Used by the admin dashboard (svc/adminsettings).
+//
+//encore:api private
+func ListLoginLockouts(ctx context.Context, req *ListLoginLockoutsRequest) (*ListLoginLockoutsResponse, error) {
+	scope, lockedOnly, limit := "", false, 50
+	if req != nil {
+		scope, lockedOnly = strings.TrimSpace(req.Scope), req.LockedOnly
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+	if scope != "" && scope != loginScopeAccount && scope != loginScopeIP {
+		return nil, NewValidationError("النطاق يجب أن يكون account أو ip")
+	}
+	if limit > maxLockoutListLimit {
+		limit = maxLockoutListLimit
+	}
+
+	failures, err := NewRepository().ListLoginFailures(ctx, scope, time.Now().UTC().Add(-lockoutListWindow), lockedOnly, limit)
+	if err != nil {
+		return nil, NewInternalError("فشل جلب محاولات الدخول الفاشلة")
+	}
+	now := time.Now()
+	items := make([]LoginLockoutItem, 0, len(failures))
+	for _, f := range failures {
+		item := LoginLockoutItem{
+			Scope:         f.Scope,
+			Key:           f.Key,
+			UserID:        f.UserID,
+			Failures:      f.State.Failures,
+			FirstFailedAt: f.State.FirstFailedAt,
+			LastFailedAt:  f.State.LastFailedAt,
+			LockCount:     f.LockCount,
+			LastIP:        f.LastIP,
+			LastUserAgent: f.LastUserAgent,
+		}
+		if now.Before(f.State.LockedUntil) {
+			lockedUntil := f.State.LockedUntil
+			item.Locked, item.LockedUntil = true, &lockedUntil
+		}
+		items = append(items, item)
+	}
+	return &ListLoginLockoutsResponse{Items: items}, nil
+}
+
+// ClearLoginLockout lifts the lock and forgets the failures of an account or a client IP.
+// Used by the admin dashboard (svc/adminsettings).
+//
+//encore:api private
+func ClearLoginLockout(ctx context.Context, req *ClearLoginLockoutRequest) (*ClearLoginLockoutResponse, error) {
+	if req == nil || (req.UserID <= 0) == (strings.TrimSpace(req.IP) == "") {
+		return nil, NewValidationError("يجب تحديد المستخدم أو عنوان IP")
+	}
+	repo := NewRepository()
+	var cleared bool
+	var err error
+	if req.UserID > 0 {
+		cleared, err = repo.ClearAccountLockout(ctx, req.UserID)
+	} else {
+		cleared, err = repo.ClearLoginFailures(ctx, loginScopeIP, strings.TrimSpace(req.IP))
+	}
+	if err != nil {
+		return nil, NewInternalError("فشل فتح الحساب")
+	}
+	return &ClearLoginLockoutResponse{Cleared: cleared}, nil
+}
+
+// loginLockoutPolicies returns the account and client IP lockout policies from the security settings
+func loginLockoutPolicies() (account, ip authn.LockoutPolicy) {
+	account = authn.DefaultLockoutPolicy
+	settings := config.Initialize(db, 5*time.Minute).GetSettings()
+	if settings.SecurityMaxLoginAttempts > 0 {
+		account.MaxAttempts = settings.SecurityMaxLoginAttempts
+	}
+	if settings.SecurityLockoutDuration > 0 {
+		account.LockoutDuration = time.Duration(settings.SecurityLockoutDuration) * time.Second
+	}
+
+	ip = account
+	ip.MaxAttempts = account.MaxAttempts * ipLockoutFactor
+	ip.BaseDelay = 0 // a per-IP delay would slow down every account behind a shared address
+	return account, ip
+}
+
+// checkLoginLockout rejects a sign-in while the client IP or the account is locked or inside
+// its progressive delay. Lookup errors are logged and let the attempt through; the in-memory
+// login rate limit still applies.
+func (s *Service) checkLoginLockout(ctx context.Context, email, ip string, accountPolicy, ipPolicy authn.LockoutPolicy) error {
+	now := time.Now().UTC()
+	if ip != "" {
+		f, err := s.repo.GetLoginFailure(ctx, loginScopeIP, ip)
+		if err != nil {
+			logger.LogError(ctx, err, "Failed to load IP login failures", logger.Fields{"ip_address": ip})
+		} else if wait, locked := ipPolicy.Check(f.State, now); wait > 0 {
+			return loginThrottledError(ctx, loginScopeIP, wait, locked)
+		}
+	}
+
+	f, err := s.repo.GetLoginFailure(ctx, loginScopeAccount, email)
+	if err != nil {
+		logger.LogError(ctx, err, "Failed to load account login failures", nil)
+		return nil
+	}
+	if wait, locked := accountPolicy.Check(f.State, now); wait > 0 {
+		return loginThrottledError(ctx, loginScopeAccount, wait, locked)
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed sign-in against the account and the client IP and returns
+// the error for the caller: invalid credentials, or the lockout if this attempt caused it.
+// user is nil when no active account has the email.
+func (s *Service) recordLoginFailure(ctx context.Context, email, ip string, user *User, accountPolicy, ipPolicy authn.LockoutPolicy) error {
+	userAgent := getUserAgent(ctx)
+	if ip != "" {
+		if _, err := s.repo.RecordLoginFailure(ctx, loginScopeIP, ip, nil, ip, userAgent, ipPolicy); err != nil {
+			logger.LogError(ctx, err, "Failed to record IP login failure", logger.Fields{"ip_address": ip})
+		}
+	}
+
+	var userID *int64
+	if user != nil {
+		userID = &user.ID
+	}
+	state, err := s.repo.RecordLoginFailure(ctx, loginScopeAccount, email, userID, ip, userAgent, accountPolicy)
+	if err != nil {
+		logger.LogError(ctx, err, "Failed to record account login failure", logger.Fields{"user_id": userID})
+		return ErrInvalidCredentials
+	}
+	if !state.LockedNow {
+		return ErrInvalidCredentials
+	}
+
+	logger.Warn(ctx, "Account locked after failed sign-ins", logger.Fields{
+		"user_id":    userID,
+		"failures":   state.Failures,
+		"ip_address": ip,
+	})
+	if user != nil {
+		go s.sendAccountLockedEmail(ctx, user.ID, user.Email, user.Name, ip, state.Failures, accountPolicy.LockoutDuration)
+	}
+	return loginThrottledError(ctx, loginScopeAccount, state.LockedUntil.Sub(time.Now().UTC()), true)
+}
+
+// clearAccountLoginFailures forgets the account's failures after a completed sign-in. The
+// client IP keeps its count so one valid account cannot be used to reset a spraying IP.
+func (s *Service) clearAccountLoginFailures(ctx context.Context, email string) {
+	if _, err := s.repo.ClearLoginFailures(ctx, loginScopeAccount, email); err != nil {
+		logger.LogError(ctx, err, "Failed to clear account login failures", nil)
+	}
+}
+
+// loginThrottledError returns the lockout or progressive delay error of a sign-in
+func loginThrottledError(ctx context.Context, scope string, wait time.Duration, locked bool) error {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	minutes := (seconds + 59) / 60
+	details := map[string]interface{}{"retry_after_seconds": seconds}
+
+	switch {
+	case locked && scope == loginScopeAccount:
+		return errs.EDetails(ctx, AuthAccountLocked, fmt.Sprintf(
+			"تم قفل الحساب مؤقتاً بسبب محاولات دخول فاشلة متكررة. استخدم رابط فتح الحساب المرسل إلى بريدك الإلكتروني أو حاول بعد %d دقيقة", minutes), details)
+	case locked:
+		return errs.EDetails(ctx, AuthRateLimitExceeded, fmt.Sprintf(
+			"محاولات دخول فاشلة كثيرة من هذا العنوان. يرجى المحاولة بعد %d دقيقة", minutes), details)
+	default:
+		return errs.EDetails(ctx, AuthRateLimitExceeded, fmt.Sprintf(
+			"يرجى الانتظار %d ثانية قبل المحاولة مرة أخرى", seconds), details)
+	}
+}
+
+// writeLoginThrottled writes a lockout or rate limit error of LoginUser with its own status and
+// a Retry-After header; it returns false for any other error
+func writeLoginThrottled(w http.ResponseWriter, r *http.Request, err error) bool {
+	var e *errs.Error
+	if !errors.As(err, &e) || (e.Code != AuthAccountLocked && e.Code != AuthRateLimitExceeded) {
+		return false
+	}
+	if details, ok := e.Details.(map[string]interface{}); ok {
+		if seconds, ok := details["retry_after_seconds"].(int); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		}
+	}
+	writeError(w, r, e.HTTPStatus(), e.Code, e.Message)
+	return true
+}
+
+// sendAccountLockedEmail emails the owner of a locked account with a single-use unlock link
+func (s *Service) sendAccountLockedEmail(ctx context.Context, userID int64, email, name, ip string, failures int, lockout time.Duration) {
+	// Detach from request-scoped context to avoid cancellation after response returns
+	corrID := errs.CorrelationIDFromContext(ctx)
+	base := logger.WithRequestID(context.Background(), corrID)
+	bctx, cancel := context.WithTimeout(base, 10*time.Second)
+	defer cancel()
+
+	token, err := generateRandomToken(32)
+	if err != nil {
+		logger.LogError(bctx, err, "Failed to generate unlock token", logger.Fields{"user_id": userID})
+		return
+	}
+	if err := s.repo.CreateAccountUnlockToken(bctx, userID, hashUnlockToken(token), time.Now().UTC().Add(accountUnlockTokenTTL)); err != nil {
+		logger.LogError(bctx, err, "Failed to store unlock token", logger.Fields{"user_id": userID})
+		return
+	}
+
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+	unlockURL := fmt.Sprintf("%s/auth/loft/unlock-account?token=%s", frontendURL, token)
+
+	payload := map[string]interface{}{
+		"email":           email,
+		"name":            name,
+		"user_name":       name,
+		"failed_attempts": failures,
+		"ip_address":      ip,
+		"lockout_minutes": int(lockout.Minutes()),
+		"unlock_url":      unlockURL,
+		"language":        "ar",
+	}
+	if _, err := notifications.EnqueueEmail(bctx, userID, "account_locked", payload); err != nil {
+		logger.LogError(bctx, err, "Failed to send account locked email", logger.Fields{"user_id": userID})
+	}
+}
+
+// hashUnlockToken returns the SHA-256 hex digest stored for an unlock token
+func hashUnlockToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
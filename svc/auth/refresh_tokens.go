@@ -0,0 +1,99 @@
+// Package auth provides authentication and authorization services
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"encore.app/pkg/logger"
+	"encore.app/pkg/ratelimit"
+	"encore.dev/cron"
+)
+
+// RevokeUserTokensRequest identifies the user whose refresh tokens are revoked
+type RevokeUserTokensRequest struct {
+	UserID int64  `json:"user_id"`
+	Reason string `json:"reason"` // e.g. user_inactive
+}
+
+// RevokeUserTokensResponse reports how many refresh tokens were revoked
+type RevokeUserTokensResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// RevokeUserTokens revokes every refresh token and session of a user, forcing a new login on
+// all devices.
+// Used by other services, e.g. when an admin deactivates an account.
+//
+//encore:api private
+func RevokeUserTokens(ctx context.Context, req *RevokeUserTokensRequest) (*RevokeUserTokensResponse, error) {
+	if req == nil || req.UserID <= 0 {
+		return nil, NewValidationError("معرّف المستخدم مطلوب")
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = "revoked"
+	}
+	revoked, err := NewRepository().InvalidateAllRefreshTokens(ctx, req.UserID, reason)
+	if err != nil {
+		return nil, NewInternalError("فشل إلغاء رموز التحديث")
+	}
+	if _, err := sessionStorage().DeleteUserSessions(ctx, req.UserID); err != nil {
+		return nil, NewInternalError("فشل إنهاء جلسات المستخدم")
+	}
+	logger.Info(ctx, "Refresh tokens revoked", logger.Fields{
+		"user_id": req.UserID,
+		"reason":  reason,
+		"revoked": revoked,
+	})
+	return &RevokeUserTokensResponse{Revoked: revoked}, nil
+}
+
+// CleanupRefreshTokensResponse reports how many expired refresh tokens were deleted
+type CleanupRefreshTokensResponse struct {
+	Deleted              int64 `json:"deleted"`
+	ChallengesDeleted    int64 `json:"challenges_deleted"`
+	LoginFailuresDeleted int64 `json:"login_failures_deleted"`
+}
+
+// CleanupRefreshTokens deletes expired refresh tokens (every refresh adds a row), expired
+// two-factor login challenges and stale failed sign-in records
+//
+//encore:api private
+func CleanupRefreshTokens(ctx context.Context) (*CleanupRefreshTokensResponse, error) {
+	deleted, err := NewRepository().DeleteExpiredRefreshTokens(ctx)
+	if err != nil {
+		return nil, NewInternalError("فشل حذف رموز التحديث المنتهية")
+	}
+	challenges, err := NewRepository().DeleteExpiredLoginChallenges(ctx)
+	if err != nil {
+		return nil, NewInternalError("فشل حذف طلبات التحقق المنتهية")
+	}
+	failures, err := NewRepository().DeleteStaleLoginFailures(ctx, loginFailureRetention)
+	if err != nil {
+		return nil, NewInternalError("فشل حذف سجلات محاولات الدخول القديمة")
+	}
+	return &CleanupRefreshTokensResponse{Deleted: deleted, ChallengesDeleted: challenges, LoginFailuresDeleted: failures}, nil
+}
+
+// CleanupRateLimits deletes expired shared rate limit counters of every service
+//
+//encore:api private
+func CleanupRateLimits(ctx context.Context) error {
+	if err := ratelimit.NewPostgresStorage(db).CleanupExpired(ctx, 0); err != nil {
+		return NewInternalError("فشل حذف عدادات حدود الطلبات المنتهية")
+	}
+	return nil
+}
+
+var _ = cron.NewJob("rate-limit-cleanup", cron.JobConfig{
+	Title:    "Delete expired rate limit counters",
+	Every:    cron.Hour,
+	Endpoint: CleanupRateLimits,
+})
+
+var _ = cron.NewJob("auth-refresh-token-cleanup", cron.JobConfig{
+	Title:    "Delete expired refresh tokens",
+	Every:    24 * cron.Hour,
+	Endpoint: CleanupRefreshTokens,
+})
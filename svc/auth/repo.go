@@ -3,8 +3,8 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"encore.app/pkg/authn"
@@ -355,20 +355,601 @@ func (r *Repository) UpdateUserPassword(ctx context.Context, userID int64, passw
 	return err
 }
 
-// InvalidateAllRefreshTokens invalidates all refresh tokens for a user (for security after password reset)
-func (r *Repository) InvalidateAllRefreshTokens(ctx context.Context, userID int64) error {
-	// This assumes you have a refresh_tokens table
-	// If not, this is a no-op for now
-	_, err := db.Exec(ctx, `
+// InvalidateAllRefreshTokens revokes all refresh tokens of a user (password reset, deactivation, logout)
+func (r *Repository) InvalidateAllRefreshTokens(ctx context.Context, userID int64, reason string) (int64, error) {
+	res, err := db.Exec(ctx, `
 		UPDATE refresh_tokens
-		SET revoked_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		SET revoked_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), revoked_reason = $2
 		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID, reason)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+// Refresh token store errors
+var (
+	// errRefreshTokenUnknown means the token was never issued, expired or was revoked
+	errRefreshTokenUnknown = errors.New("refresh token not found or revoked")
+	// errRefreshTokenReused means an already rotated token was presented again
+	errRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// refreshReuseGrace tolerates a client retrying a refresh (e.g. two tabs racing) right after
+// rotation: such a reuse is rejected without revoking the family
+const refreshReuseGrace = 10 * time.Second
+
+// RefreshTokenRecord is an issued refresh token
+type RefreshTokenRecord struct {
+	TokenID   string
+	FamilyID  string
+	UserID    int64
+	SessionID string // login session (device); "" for tokens issued before sessions were stored
+	ExpiresAt time.Time
+	IPAddress string
+	UserAgent string
+}
+
+// CreateRefreshToken stores a newly issued refresh token
+func (r *Repository) CreateRefreshToken(ctx context.Context, rec RefreshTokenRecord) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO refresh_tokens (token_id, family_id, user_id, session_id, expires_at, ip_address, user_agent)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), NULLIF($7, ''))
+	`, rec.TokenID, rec.FamilyID, rec.UserID, rec.SessionID, rec.ExpiresAt, rec.IPAddress, rec.UserAgent)
+	return err
+}
+
+// RotateRefreshToken marks the presented token used and stores its replacement in the same
+// family. A token presented after rotation revokes the whole family (errRefreshTokenReused).
+// next.FamilyID and next.UserID are filled from the presented token.
+func (r *Repository) RotateRefreshToken(ctx context.Context, tokenID string, next *RefreshTokenRecord) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var familyID string
+	var userID int64
+	var expiresAt time.Time
+	var usedAt, revokedAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT family_id::text, user_id, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_id = $1
+		FOR UPDATE
+	`, tokenID).Scan(&familyID, &userID, &expiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return errRefreshTokenUnknown
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if revokedAt != nil || now.After(expiresAt) {
+		return errRefreshTokenUnknown
+	}
+	if usedAt != nil {
+		if now.Sub(*usedAt) < refreshReuseGrace {
+			return errRefreshTokenUnknown
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE refresh_tokens
+			SET revoked_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), revoked_reason = 'reuse'
+			WHERE family_id = $1 AND revoked_at IS NULL
+		`, familyID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		next.FamilyID, next.UserID = familyID, userID
+		return errRefreshTokenReused
+	}
+
+	next.FamilyID, next.UserID = familyID, userID
+	if _, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET used_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), replaced_by = $2
+		WHERE token_id = $1
+	`, tokenID, next.TokenID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (token_id, family_id, user_id, session_id, expires_at, ip_address, user_agent)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), NULLIF($7, ''))
+	`, next.TokenID, familyID, userID, next.SessionID, next.ExpiresAt, next.IPAddress, next.UserAgent); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeSessionRefreshTokens revokes the refresh tokens of one login session (device)
+func (r *Repository) RevokeSessionRefreshTokens(ctx context.Context, sessionID, reason string) error {
+	_, err := db.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'), revoked_reason = $2
+		WHERE session_id = $1 AND revoked_at IS NULL
+	`, sessionID, reason)
+	return err
+}
+
+// IsNewDevice reports whether a login comes from a device the user never signed in from
+// before. Devices are compared by their browser and OS (describeDevice), not the exact user
+// agent, so browser updates do not count as new devices. A user's very first login is not a
+// new device.
+func (r *Repository) IsNewDevice(ctx context.Context, userID int64, userAgent string) (bool, error) {
+	rows, err := db.Query(ctx, `
+		SELECT DISTINCT COALESCE(user_agent, '')
+		FROM refresh_tokens
+		WHERE user_id = $1
 	`, userID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	device := describeDevice(userAgent)
+	seen := false
+	for rows.Next() {
+		var ua string
+		if err := rows.Scan(&ua); err != nil {
+			return false, err
+		}
+		if describeDevice(ua) == device {
+			return false, nil
+		}
+		seen = true
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return seen, nil
+}
 
-	// Ignore error if table doesn't exist
-	if err != nil && strings.Contains(err.Error(), "does not exist") {
-		return nil
+// DeleteExpiredRefreshTokens removes tokens that expired more than a day ago
+func (r *Repository) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	res, err := db.Exec(ctx, `
+		DELETE FROM refresh_tokens
+		WHERE expires_at < (CURRENT_TIMESTAMP AT TIME ZONE 'UTC') - INTERVAL '1 day'
+	`)
+	if err != nil {
+		return 0, err
 	}
+	return res.RowsAffected(), nil
+}
+
+// TwoFactorSettings is a user's second-factor enrolment; the zero value means none
+type TwoFactorSettings struct {
+	TOTPSecret        string // sealed with authn.SealSecret
+	TOTPPendingSecret string
+	TOTPEnabledAt     *time.Time
+	TOTPLastStep      int64
+	SMSEnabledAt      *time.Time
+}
 
+// GetTwoFactor returns the user's two-factor settings
+func (r *Repository) GetTwoFactor(ctx context.Context, userID int64) (*TwoFactorSettings, error) {
+	var tf TwoFactorSettings
+	err := db.QueryRow(ctx, `
+		SELECT COALESCE(totp_secret, ''), COALESCE(totp_pending_secret, ''), totp_enabled_at, totp_last_step, sms_enabled_at
+		FROM user_two_factor
+		WHERE user_id = $1
+	`, userID).Scan(&tf.TOTPSecret, &tf.TOTPPendingSecret, &tf.TOTPEnabledAt, &tf.TOTPLastStep, &tf.SMSEnabledAt)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &TwoFactorSettings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tf, nil
+}
+
+// SetPendingTOTPSecret stores a TOTP secret awaiting confirmation with a first code
+func (r *Repository) SetPendingTOTPSecret(ctx context.Context, userID int64, sealed string) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO user_two_factor (user_id, totp_pending_secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET totp_pending_secret = EXCLUDED.totp_pending_secret, updated_at = NOW()
+	`, userID, sealed)
 	return err
 }
+
+// EnableTOTP promotes the pending secret and replaces the user's recovery codes
+func (r *Repository) EnableTOTP(ctx context.Context, userID, step int64, recoveryCodeHashes []string) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE user_two_factor
+		SET totp_secret = totp_pending_secret, totp_pending_secret = NULL,
+		    totp_enabled_at = NOW(), totp_last_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND totp_pending_secret IS NOT NULL
+	`, userID, step); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkTOTPStepUsed records an accepted TOTP time step. It returns false when the step is not
+// newer than the last accepted one, i.e. the code was already used.
+func (r *Repository) MarkTOTPStepUsed(ctx context.Context, userID, step int64) (bool, error) {
+	res, err := db.Exec(ctx, `
+		UPDATE user_two_factor
+		SET totp_last_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND totp_last_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// EnableSMSTwoFactor turns on SMS one-time codes as a second factor
+func (r *Repository) EnableSMSTwoFactor(ctx context.Context, userID int64) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO user_two_factor (user_id, sms_enabled_at)
+		VALUES ($1, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET sms_enabled_at = COALESCE(user_two_factor.sms_enabled_at, NOW()), updated_at = NOW()
+	`, userID)
+	return err
+}
+
+// DisableTwoFactor removes every second factor and recovery code of the user
+func (r *Repository) DisableTwoFactor(ctx context.Context, userID int64) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_two_factor WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes invalidates the user's recovery codes and stores new ones
+func (r *Repository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sqldb.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)
+		`, userID, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes an unused recovery code; it returns false when none matches
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	res, err := db.Exec(ctx, `
+		UPDATE user_recovery_codes
+		SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM user_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+		)
+	`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes of the user
+func (r *Repository) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	var n int
+	err := db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL
+	`, userID).Scan(&n)
+	return n, err
+}
+
+// LoginChallenge is a pending second-factor step of a login
+type LoginChallenge struct {
+	ID          string
+	UserID      int64
+	SMSCodeHash string
+	SMSSentAt   *time.Time
+	Attempts    int
+	IPAddress   string
+	UserAgent   string
+	ExpiresAt   time.Time
+	CompletedAt *time.Time
+}
+
+// CreateLoginChallenge stores a new login challenge
+func (r *Repository) CreateLoginChallenge(ctx context.Context, ch LoginChallenge) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO login_challenges (id, user_id, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, ch.ID, ch.UserID, ch.IPAddress, ch.UserAgent, ch.ExpiresAt)
+	return err
+}
+
+// GetLoginChallenge returns a login challenge by ID
+func (r *Repository) GetLoginChallenge(ctx context.Context, id string) (*LoginChallenge, error) {
+	var ch LoginChallenge
+	err := db.QueryRow(ctx, `
+		SELECT id::text, user_id, COALESCE(sms_code_hash, ''), sms_sent_at, attempts, ip_address, user_agent,
+		       expires_at, completed_at
+		FROM login_challenges
+		WHERE id = $1
+	`, id).Scan(&ch.ID, &ch.UserID, &ch.SMSCodeHash, &ch.SMSSentAt, &ch.Attempts, &ch.IPAddress, &ch.UserAgent,
+		&ch.ExpiresAt, &ch.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// SetLoginChallengeSMS records that an SMS code was sent; codeHash is empty when Twilio
+// Verify generated and checks the code
+func (r *Repository) SetLoginChallengeSMS(ctx context.Context, id, codeHash string) error {
+	_, err := db.Exec(ctx, `
+		UPDATE login_challenges SET sms_code_hash = NULLIF($2, ''), sms_sent_at = NOW() WHERE id = $1
+	`, id, codeHash)
+	return err
+}
+
+// RecordLoginChallengeAttempt counts a verification attempt and returns the new total
+func (r *Repository) RecordLoginChallengeAttempt(ctx context.Context, id string) (int, error) {
+	var attempts int
+	err := db.QueryRow(ctx, `
+		UPDATE login_challenges SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts
+	`, id).Scan(&attempts)
+	return attempts, err
+}
+
+// CompleteLoginChallenge marks a challenge completed; it returns false when the challenge was
+// already completed or expired, so a challenge yields tokens only once
+func (r *Repository) CompleteLoginChallenge(ctx context.Context, id string) (bool, error) {
+	res, err := db.Exec(ctx, `
+		UPDATE login_challenges
+		SET completed_at = NOW()
+		WHERE id = $1 AND completed_at IS NULL AND expires_at > NOW()
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// DeleteExpiredLoginChallenges removes challenges that expired more than a day ago
+func (r *Repository) DeleteExpiredLoginChallenges(ctx context.Context) (int64, error) {
+	res, err := db.Exec(ctx, `
+		DELETE FROM login_challenges WHERE expires_at < NOW() - INTERVAL '1 day'
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+// Login failure scopes
+const (
+	loginScopeAccount = "account" // keyed by the lower-cased email, whether or not the account exists
+	loginScopeIP      = "ip"
+)
+
+// LoginFailure is the failed sign-in record of an account or client IP
+type LoginFailure struct {
+	Scope         string
+	Key           string
+	UserID        *int64
+	State         authn.FailureState
+	LockCount     int
+	LastIP        string
+	LastUserAgent string
+}
+
+// GetLoginFailure returns the failure record of a key; a key without failures yields an empty record
+func (r *Repository) GetLoginFailure(ctx context.Context, scope, key string) (*LoginFailure, error) {
+	f := LoginFailure{Scope: scope, Key: key}
+	var nextAttemptAt, lockedUntil *time.Time
+	err := db.QueryRow(ctx, `
+		SELECT user_id, failures, first_failed_at, last_failed_at, next_attempt_at, locked_until,
+		       lock_count, last_ip, last_user_agent
+		FROM login_failures
+		WHERE scope = $1 AND key = $2
+	`, scope, key).Scan(&f.UserID, &f.State.Failures, &f.State.FirstFailedAt, &f.State.LastFailedAt,
+		&nextAttemptAt, &lockedUntil, &f.LockCount, &f.LastIP, &f.LastUserAgent)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if nextAttemptAt != nil {
+		f.State.NextAttemptAt = *nextAttemptAt
+	}
+	if lockedUntil != nil {
+		f.State.LockedUntil = *lockedUntil
+	}
+	return &f, nil
+}
+
+// RecordLoginFailure counts a failed sign-in against a key under the given policy and returns
+// the new state. The row is locked for the update so concurrent attempts are all counted.
+func (r *Repository) RecordLoginFailure(ctx context.Context, scope, key string, userID *int64, ip, userAgent string, policy authn.LockoutPolicy) (authn.FailureState, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return authn.FailureState{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO login_failures (scope, key) VALUES ($1, $2) ON CONFLICT (scope, key) DO NOTHING
+	`, scope, key); err != nil {
+		return authn.FailureState{}, err
+	}
+
+	var prev authn.FailureState
+	var nextAttemptAt, lockedUntil *time.Time
+	if err := tx.QueryRow(ctx, `
+		SELECT failures, first_failed_at, last_failed_at, next_attempt_at, locked_until
+		FROM login_failures
+		WHERE scope = $1 AND key = $2
+		FOR UPDATE
+	`, scope, key).Scan(&prev.Failures, &prev.FirstFailedAt, &prev.LastFailedAt, &nextAttemptAt, &lockedUntil); err != nil {
+		return authn.FailureState{}, err
+	}
+	if nextAttemptAt != nil {
+		prev.NextAttemptAt = *nextAttemptAt
+	}
+	if lockedUntil != nil {
+		prev.LockedUntil = *lockedUntil
+	}
+
+	next := policy.RecordFailure(prev, time.Now().UTC())
+	var nextLock *time.Time
+	if !next.LockedUntil.IsZero() {
+		nextLock = &next.LockedUntil
+	}
+	lockIncrement := 0
+	if next.LockedNow {
+		lockIncrement = 1
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE login_failures
+		SET user_id = COALESCE($3, user_id), failures = $4, first_failed_at = $5, last_failed_at = $6,
+		    next_attempt_at = $7, locked_until = $8, lock_count = lock_count + $9,
+		    last_ip = $10, last_user_agent = $11
+		WHERE scope = $1 AND key = $2
+	`, scope, key, userID, next.Failures, next.FirstFailedAt, next.LastFailedAt,
+		next.NextAttemptAt, nextLock, lockIncrement, ip, userAgent); err != nil {
+		return authn.FailureState{}, err
+	}
+	return next, tx.Commit()
+}
+
+// ClearLoginFailures forgets the failures of a key, lifting any delay or lock
+func (r *Repository) ClearLoginFailures(ctx context.Context, scope, key string) (bool, error) {
+	res, err := db.Exec(ctx, `DELETE FROM login_failures WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// ClearAccountLockout lifts the lock of a user's account and invalidates its pending unlock links
+func (r *Repository) ClearAccountLockout(ctx context.Context, userID int64) (bool, error) {
+	res, err := db.Exec(ctx, `
+		DELETE FROM login_failures
+		WHERE scope = 'account'
+		  AND (user_id = $1 OR key = (SELECT LOWER(email) FROM users WHERE id = $1))
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+	if _, err := db.Exec(ctx, `
+		UPDATE account_unlock_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL
+	`, userID); err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// ListLoginFailures returns locked keys and, unless lockedOnly is set, keys that failed since
+// the given time; locked ones first. An empty scope lists both scopes.
+func (r *Repository) ListLoginFailures(ctx context.Context, scope string, since time.Time, lockedOnly bool, limit int) ([]LoginFailure, error) {
+	rows, err := db.Query(ctx, `
+		SELECT scope, key, user_id, failures, first_failed_at, last_failed_at, next_attempt_at,
+		       locked_until, lock_count, last_ip, last_user_agent
+		FROM login_failures
+		WHERE ($1 = '' OR scope = $1)
+		  AND (locked_until > NOW() OR (NOT $3 AND last_failed_at >= $2))
+		ORDER BY (locked_until > NOW()) IS TRUE DESC, last_failed_at DESC
+		LIMIT $4
+	`, scope, since, lockedOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []LoginFailure{}
+	for rows.Next() {
+		var f LoginFailure
+		var nextAttemptAt, lockedUntil *time.Time
+		if err := rows.Scan(&f.Scope, &f.Key, &f.UserID, &f.State.Failures, &f.State.FirstFailedAt,
+			&f.State.LastFailedAt, &nextAttemptAt, &lockedUntil, &f.LockCount, &f.LastIP, &f.LastUserAgent); err != nil {
+			return nil, err
+		}
+		if nextAttemptAt != nil {
+			f.State.NextAttemptAt = *nextAttemptAt
+		}
+		if lockedUntil != nil {
+			f.State.LockedUntil = *lockedUntil
+		}
+		items = append(items, f)
+	}
+	return items, rows.Err()
+}
+
+// CreateAccountUnlockToken stores the hash of an emailed unlock link
+func (r *Repository) CreateAccountUnlockToken(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO account_unlock_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)
+	`, userID, tokenHash, expiresAt)
+	return err
+}
+
+// UseAccountUnlockToken consumes an unlock token and returns its user
+func (r *Repository) UseAccountUnlockToken(ctx context.Context, tokenHash string) (int64, error) {
+	var userID int64
+	err := db.QueryRow(ctx, `
+		UPDATE account_unlock_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`, tokenHash).Scan(&userID)
+	return userID, err
+}
+
+// DeleteStaleLoginFailures removes unlocked failure records older than maxAge and expired
+// unlock tokens
+func (r *Repository) DeleteStaleLoginFailures(ctx context.Context, maxAge time.Duration) (int64, error) {
+	cutoff := time.Now().UTC().Add(-maxAge)
+	res, err := db.Exec(ctx, `
+		DELETE FROM login_failures
+		WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < NOW())
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := db.Exec(ctx, `
+		DELETE FROM account_unlock_tokens WHERE expires_at < NOW() - INTERVAL '1 day'
+	`); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
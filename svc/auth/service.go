@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"math/big"
 	"os"
@@ -15,10 +16,12 @@ import (
 	"encore.app/pkg/errs"
 	"encore.app/pkg/httpx"
 	"encore.app/pkg/logger"
+	"encore.app/pkg/maintenance"
 	"encore.app/pkg/ratelimit"
 	"encore.app/pkg/session"
 	"encore.app/pkg/sms"
 	"encore.app/svc/notifications"
+	"github.com/google/uuid"
 )
 
 // Secrets configuration
@@ -31,6 +34,8 @@ var secrets struct {
 	TwilioAuthToken     string
 	TwilioFromNumber    string // Legacy: for direct SMS (optional)
 	TwilioVerifyService string // Twilio Verify Service SID (recommended)
+
+	TwoFactorEncryptionKey string // encrypts TOTP secrets at rest
 }
 
 // Login is a wrapper to support tests calling service.Login; delegates to LoginUser
@@ -65,17 +70,20 @@ func initService() (*Service, error) {
 	// Initialize JWT manager with secure secrets from Encore secrets
 	jwtManager := authn.NewJWTManager(secrets.JWTAccessSecret, secrets.JWTRefreshSecret)
 
-	// Initialize session manager
+	// Initialize session manager; sessions are shared by all instances through Postgres
 	sessionConfig := session.ProductionSessionConfig
-	sessionManager := session.NewSessionManager(sessionConfig)
+	sessionManager := session.NewSessionManagerWithStorage(sessionConfig, session.NewPostgresStorage(db))
 
 	// Initialize verification manager
 	verificationManager := authn.NewVerificationManager()
 
-	// Initialize rate limiters
-	loginRateLimit := ratelimit.NewRateLimiter(ratelimit.LoginRateLimit)
-	registerRateLimit := ratelimit.NewRateLimiter(ratelimit.RegistrationRateLimit)
-	verifyRateLimit := ratelimit.NewRateLimiter(ratelimit.EmailVerificationRateLimit)
+	// Initialize rate limiters; counters are shared by all instances through Postgres and
+	// limits can be tuned with the ratelimit.<action>.* settings
+	rlStorage := ratelimit.NewPostgresStorage(db)
+	rlSettings := ratelimit.SettingsProvider(db)
+	loginRateLimit := ratelimit.NewSharedRateLimiter("login", ratelimit.LoginRateLimit, rlStorage, rlSettings)
+	registerRateLimit := ratelimit.NewSharedRateLimiter("register", ratelimit.RegistrationRateLimit, rlStorage, rlSettings)
+	verifyRateLimit := ratelimit.NewSharedRateLimiter("verify", ratelimit.EmailVerificationRateLimit, rlStorage, rlSettings)
 
 	// Initialize Twilio SMS client
 	// Dev mode only if secrets are not configured
@@ -111,6 +119,10 @@ func NewService() (*Service, error) { // exported for tests
 
 // RegisterUser handles user registration business logic
 func (s *Service) RegisterUser(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
+	if err := checkRegistrationEnabled(ctx); err != nil {
+		return nil, err
+	}
+
 	// Rate limiting by IP
 	clientIP := getClientIP(ctx)
 	rateLimitKey := ratelimit.GenerateIPKey("register", clientIP)
@@ -226,6 +238,10 @@ func (s *Service) RegisterUser(ctx context.Context, req *RegisterRequest) (*Regi
 
 // StartPhoneRegistration handles initiating phone verification by generating a 4-digit OTP
 func (s *Service) StartPhoneRegistration(ctx context.Context, req *StartPhoneRequest) (*StartPhoneResponse, error) {
+	if err := checkRegistrationEnabled(ctx); err != nil {
+		return nil, err
+	}
+
 	// Rate limiting by IP and phone
 	clientIP := getClientIP(ctx)
 	ipKey := ratelimit.GenerateIPKey("phone_start", clientIP)
@@ -422,31 +438,85 @@ func (s *Service) LoginUser(ctx context.Context, req *LoginRequest) (*LoginRespo
 		return nil, NewRateLimitError("Too many login attempts for this email. Please try again later.")
 	}
 
+	// Persistent lockout and progressive delay, shared by all instances
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	accountPolicy, ipPolicy := loginLockoutPolicies()
+	if err := s.checkLoginLockout(ctx, email, clientIP, accountPolicy, ipPolicy); err != nil {
+		return nil, err
+	}
+
 	// Get user from database
 	user, err := s.repo.GetUserByEmail(ctx, req.Email)
 	if err != nil {
-		return nil, ErrInvalidCredentials
+		return nil, s.recordLoginFailure(ctx, email, clientIP, nil, accountPolicy, ipPolicy)
 	}
 
 	// Verify password
 	if err := authn.VerifyPassword(req.Password, user.PasswordHash); err != nil {
-		return nil, ErrInvalidCredentials
+		return nil, s.recordLoginFailure(ctx, email, clientIP, user, accountPolicy, ipPolicy)
+	}
+
+	// Accounts with two-factor authentication (mandatory for admins) get a challenge instead
+	// of tokens; completeLoginChallenge issues them once the second factor is verified. The
+	// account's failures are kept until then, so wrong codes add to the same lockout.
+	challenge, err := s.startLoginChallenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	resp, err := s.issueLogin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.clearAccountLoginFailures(ctx, email)
+	return resp, nil
+}
+
+// issueLogin creates the session and tokens of a user who passed every login factor
+func (s *Service) issueLogin(ctx context.Context, user *User) (*LoginResponse, error) {
+	clientIP := getClientIP(ctx)
+	userAgent := getUserAgent(ctx)
+
+	// Create session (one per device); tokens carry its ID and are not stored with it
+	sessionID, _, err := s.sessionManager.CreateSession(user.ID, user.Role, user.Email, "", "", clientIP, userAgent)
+	if err != nil {
+		return nil, NewInternalError("Failed to create session.")
 	}
 
 	// Generate JWT tokens
-	tokenPair, err := s.jwtManager.GenerateTokens(user.ID, user.Role, user.Email)
+	tokenPair, err := s.jwtManager.GenerateTokensForSession(user.ID, user.Role, user.Email, sessionID)
 	if err != nil {
+		s.sessionManager.DeleteSession(sessionID)
 		return nil, NewInternalError("Failed to generate authentication tokens.")
 	}
 
-	// Create session
-	userAgent := getUserAgent(ctx)
-	sessionID, _, err := s.sessionManager.CreateSession(
-		user.ID, user.Role, user.Email, tokenPair.AccessToken, tokenPair.RefreshToken, clientIP, userAgent)
+	// Checked before the refresh token below is recorded for this device
+	newDevice, err := s.repo.IsNewDevice(ctx, user.ID, userAgent)
 	if err != nil {
+		logger.LogError(ctx, err, "Failed to check login device", logger.Fields{"user_id": user.ID})
+	}
+
+	// Track the refresh token server-side; each login starts a new token family
+	if err := s.repo.CreateRefreshToken(ctx, RefreshTokenRecord{
+		TokenID:   tokenPair.RefreshTokenID,
+		FamilyID:  uuid.NewString(),
+		UserID:    user.ID,
+		SessionID: sessionID,
+		ExpiresAt: tokenPair.RefreshExpiresAt,
+		IPAddress: clientIP,
+		UserAgent: userAgent,
+	}); err != nil {
+		s.sessionManager.DeleteSession(sessionID)
 		return nil, NewInternalError("Failed to create session.")
 	}
 
+	if newDevice {
+		go s.sendNewDeviceNotification(ctx, user.ID, user.Email, user.Name, clientIP, userAgent)
+	}
+
 	// Update last login
 	if err := s.repo.UpdateUserLastLogin(ctx, user.ID); err != nil {
 		// Log error but don't fail the request
@@ -455,9 +525,6 @@ func (s *Service) LoginUser(ctx context.Context, req *LoginRequest) (*LoginRespo
 		})
 	}
 
-	// Set session cookie (this would be done in HTTP middleware in a real implementation)
-	_ = sessionID
-
 	return &LoginResponse{
 		AccessToken:  tokenPair.AccessToken,
 		RefreshToken: tokenPair.RefreshToken,
@@ -518,11 +585,12 @@ func (s *Service) VerifyUserEmail(ctx context.Context, req *VerifyEmailRequest)
 	}, nil
 }
 
-// RefreshUserToken handles token refresh business logic
+// RefreshUserToken handles token refresh business logic. The presented refresh token is
+// rotated: it must be known to the store and unused; a reused token revokes its whole family.
 func (s *Service) RefreshUserToken(ctx context.Context, req *RefreshTokenRequest) (*RefreshTokenResponse, error) {
 	// Validate refresh token
 	claims, err := s.jwtManager.ValidateRefreshToken(req.RefreshToken)
-	if err != nil {
+	if err != nil || claims.ID == "" {
 		return nil, ErrInvalidRefreshToken
 	}
 
@@ -532,12 +600,52 @@ func (s *Service) RefreshUserToken(ctx context.Context, req *RefreshTokenRequest
 		return nil, ErrUserInactive
 	}
 
+	// The session (device) must still exist: deleting it logs the device out. This also
+	// records the session as used.
+	if claims.SessionID != "" {
+		sess, err := s.sessionManager.GetSession(claims.SessionID)
+		if err != nil || sess.UserID != user.ID {
+			if rerr := s.repo.RevokeSessionRefreshTokens(ctx, claims.SessionID, "session_ended"); rerr != nil {
+				logger.LogError(ctx, rerr, "Failed to revoke refresh tokens of ended session", logger.Fields{"user_id": user.ID})
+			}
+			return nil, ErrInvalidRefreshToken
+		}
+	}
+
 	// Generate new token pair with up-to-date role/email
-	newTokenPair, err := s.jwtManager.GenerateTokens(user.ID, user.Role, user.Email)
+	newTokenPair, err := s.jwtManager.GenerateTokensForSession(user.ID, user.Role, user.Email, claims.SessionID)
 	if err != nil {
 		return nil, NewInternalError("Failed to generate new tokens.")
 	}
 
+	next := RefreshTokenRecord{
+		SessionID: claims.SessionID,
+		TokenID:   newTokenPair.RefreshTokenID,
+		ExpiresAt: newTokenPair.RefreshExpiresAt,
+		IPAddress: getClientIP(ctx),
+		UserAgent: getUserAgent(ctx),
+	}
+	switch err := s.repo.RotateRefreshToken(ctx, claims.ID, &next); {
+	case errors.Is(err, errRefreshTokenReused):
+		logger.Warn(ctx, "Refresh token reuse detected, token family revoked", logger.Fields{
+			"user_id":   user.ID,
+			"family_id": next.FamilyID,
+		})
+		s.sessionManager.DeleteSession(claims.SessionID)
+		return nil, ErrRefreshTokenReused
+	case errors.Is(err, errRefreshTokenUnknown):
+		return nil, ErrInvalidRefreshToken
+	case err != nil:
+		return nil, NewInternalError("Failed to rotate refresh token.")
+	}
+
+	// Keep the session (device) alive as long as its newest refresh token
+	if claims.SessionID != "" {
+		if err := s.sessionManager.RenewSession(claims.SessionID, newTokenPair.RefreshExpiresAt); err != nil {
+			logger.LogError(ctx, err, "Failed to renew session expiry", logger.Fields{"user_id": user.ID})
+		}
+	}
+
 	return &RefreshTokenResponse{
 		AccessToken:  newTokenPair.AccessToken,
 		RefreshToken: newTokenPair.RefreshToken,
@@ -560,12 +668,16 @@ func (s *Service) LogoutUser(ctx context.Context, userID int64) (*LogoutResponse
 	// Delete all user sessions
 	deletedSessions := s.sessionManager.DeleteUserSessions(userID)
 
-	// Proper role checking implementation using database lookup
-	// This integrates with the existing user management system
+	// Revoke refresh tokens so the cookie cannot mint new access tokens
+	revokedTokens, err := s.repo.InvalidateAllRefreshTokens(ctx, userID, "logout")
+	if err != nil {
+		return nil, NewInternalError("Failed to revoke refresh tokens.")
+	}
 
 	logger.Info(ctx, "User logged out successfully", logger.Fields{
 		"user_id":          userID,
 		"deleted_sessions": deletedSessions,
+		"revoked_tokens":   revokedTokens,
 	})
 
 	return &LogoutResponse{
@@ -688,10 +800,11 @@ func (s *Service) ResetUserPassword(ctx context.Context, req *ResetPasswordReque
 		logger.Error(ctx, "Failed to invalidate reset token", logger.Fields{"error": err.Error()})
 	}
 
-	// Invalidate all existing refresh tokens for security
-	if err := s.repo.InvalidateAllRefreshTokens(ctx, userID); err != nil {
+	// Invalidate all existing refresh tokens and sessions for security
+	if _, err := s.repo.InvalidateAllRefreshTokens(ctx, userID, "password_reset"); err != nil {
 		logger.Error(ctx, "Failed to invalidate refresh tokens", logger.Fields{"error": err.Error()})
 	}
+	s.sessionManager.DeleteUserSessions(userID)
 
 	return &ResetPasswordResponse{
 		Message: "تم تغيير كلمة المرور بنجاح. يمكنك الآن تسجيل الدخول",
@@ -701,6 +814,18 @@ func (s *Service) ResetUserPassword(ctx context.Context, req *ResetPasswordReque
 
 // Helper functions
 
+// checkRegistrationEnabled enforces the app.registration_enabled kill-switch
+func checkRegistrationEnabled(ctx context.Context) error {
+	st, err := maintenance.Current(ctx, db)
+	if err != nil {
+		logger.LogError(ctx, err, "Failed to load registration flag", nil)
+	}
+	if !st.RegistrationEnabled {
+		return ErrRegistrationDisabled
+	}
+	return nil
+}
+
 // getClientIP extracts the client IP address from the request context
 func getClientIP(ctx context.Context) string {
 	// Use httpx utility for consistent IP extraction
@@ -0,0 +1,224 @@
+// Package auth provides authentication and authorization services
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/logger"
+	"encore.app/pkg/session"
+	"encore.app/svc/notifications"
+	"encore.dev/beta/auth"
+)
+
+// SessionInfo describes a login session (device) for the sessions screens
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"` // e.g. "Safari - iOS"
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // the session making the request
+}
+
+// ListSessionsResponse lists the active sessions of a user
+type ListSessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// RevokeSessionResponse confirms a session was ended
+type RevokeSessionResponse struct {
+	Message string `json:"message"`
+	Success bool   `json:"success"`
+}
+
+// ListMySessions lists the devices the current user is signed in on
+//
+//encore:api auth method=GET path=/me/sessions
+func (s *Service) ListMySessions(ctx context.Context) (*ListSessionsResponse, error) {
+	data, err := currentAuthData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.listSessions(data.UserID, data.SessionID), nil
+}
+
+// RevokeMySession signs the current user out of one device ("log out other devices")
+//
+//encore:api auth method=DELETE path=/me/sessions/:id
+func (s *Service) RevokeMySession(ctx context.Context, id string) (*RevokeSessionResponse, error) {
+	data, err := currentAuthData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.revokeSession(ctx, data.UserID, id, "user_revoked"); err != nil {
+		return nil, err
+	}
+	return &RevokeSessionResponse{Message: "تم تسجيل الخروج من الجهاز", Success: true}, nil
+}
+
+// AdminListUserSessions lists the devices a user is signed in on
+//
+//encore:api auth method=GET path=/admin/users/:id/sessions
+func (s *Service) AdminListUserSessions(ctx context.Context, id int64) (*ListSessionsResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return s.listSessions(id, ""), nil
+}
+
+// AdminRevokeUserSession signs a user out of one device
+//
+//encore:api auth method=DELETE path=/admin/users/:id/sessions/:sid
+func (s *Service) AdminRevokeUserSession(ctx context.Context, id int64, sid string) (*RevokeSessionResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.revokeSession(ctx, id, sid, "admin_revoked"); err != nil {
+		return nil, err
+	}
+	return &RevokeSessionResponse{Message: "تم إنهاء جلسة المستخدم", Success: true}, nil
+}
+
+// AdminRevokeAllUserSessions signs a user out of every device
+//
+//encore:api auth method=DELETE path=/admin/users/:id/sessions
+func (s *Service) AdminRevokeAllUserSessions(ctx context.Context, id int64) (*RevokeSessionResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	s.sessionManager.DeleteUserSessions(id)
+	if _, err := s.repo.InvalidateAllRefreshTokens(ctx, id, "admin_revoked"); err != nil {
+		return nil, NewInternalError("فشل إنهاء جلسات المستخدم")
+	}
+	return &RevokeSessionResponse{Message: "تم إنهاء جميع جلسات المستخدم", Success: true}, nil
+}
+
+func (s *Service) listSessions(userID int64, currentID string) *ListSessionsResponse {
+	sessions := s.sessionManager.GetUserSessions(userID)
+	resp := &ListSessionsResponse{Sessions: make([]SessionInfo, 0, len(sessions))}
+	for _, sd := range sessions {
+		resp.Sessions = append(resp.Sessions, SessionInfo{
+			ID:         sd.ID,
+			Device:     describeDevice(sd.UserAgent),
+			IPAddress:  sd.IPAddress,
+			UserAgent:  sd.UserAgent,
+			CreatedAt:  sd.CreatedAt,
+			LastUsedAt: sd.LastUsedAt,
+			ExpiresAt:  sd.ExpiresAt,
+			Current:    currentID != "" && sd.ID == currentID,
+		})
+	}
+	return resp
+}
+
+// revokeSession deletes a session of the user and revokes its refresh tokens. Access tokens
+// already issued to the device stay valid until they expire (authn.AccessTokenDuration).
+func (s *Service) revokeSession(ctx context.Context, userID int64, sessionID, reason string) error {
+	sd, err := s.sessionManager.GetSession(sessionID)
+	if err != nil || sd.UserID != userID {
+		return &errs.Error{Code: errs.NotFound, Message: "الجلسة غير موجودة"}
+	}
+	s.sessionManager.DeleteSession(sessionID)
+	if err := s.repo.RevokeSessionRefreshTokens(ctx, sessionID, reason); err != nil {
+		return NewInternalError("فشل إنهاء الجلسة")
+	}
+	logger.Info(ctx, "Session revoked", logger.Fields{"user_id": userID, "reason": reason})
+	return nil
+}
+
+// currentAuthData returns the authenticated caller
+func currentAuthData(ctx context.Context) (*AuthData, error) {
+	data, ok := auth.Data().(*AuthData)
+	if !ok || data == nil {
+		return nil, errs.E(ctx, "AUTH_UNAUTHENTICATED", "المستخدم غير مصادق.")
+	}
+	return data, nil
+}
+
+func requireAdmin(ctx context.Context) error {
+	data, err := currentAuthData(ctx)
+	if err != nil {
+		return err
+	}
+	if data.Role != "admin" {
+		return &errs.Error{Code: AuthForbidden, Message: "يتطلب صلاحيات مدير"}
+	}
+	return nil
+}
+
+// describeDevice turns a user agent into a short "browser - OS" label
+func describeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "جهاز غير معروف"
+	}
+	browser := "متصفح"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "samsungbrowser"):
+		browser = "Samsung Internet"
+	case strings.Contains(ua, "firefox/") || strings.Contains(ua, "fxios"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "okhttp") || strings.Contains(ua, "dart") || strings.Contains(ua, "cfnetwork"):
+		browser = "التطبيق"
+	}
+	platform := ""
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ios"):
+		platform = "iOS"
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os") || strings.Contains(ua, "macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+	if platform == "" {
+		return browser
+	}
+	return browser + " - " + platform
+}
+
+// sendNewDeviceNotification tells the user about a sign-in from a device not seen before
+func (s *Service) sendNewDeviceNotification(ctx context.Context, userID int64, email, name, ip, userAgent string) {
+	// Detach from request-scoped context to avoid cancellation after response returns
+	corrID := errs.CorrelationIDFromContext(ctx)
+	base := logger.WithRequestID(context.Background(), corrID)
+	bctx, cancel := context.WithTimeout(base, 10*time.Second)
+	defer cancel()
+
+	payload := map[string]interface{}{
+		"email":      email,
+		"name":       name,
+		"user_name":  name,
+		"device":     describeDevice(userAgent),
+		"ip_address": ip,
+		"login_time": time.Now().UTC().Format("2006-01-02 15:04 UTC"),
+		"language":   "ar",
+	}
+	if _, err := notifications.EnqueueEmail(bctx, userID, "new_device_login", payload); err != nil {
+		logger.LogError(bctx, err, "Failed to send new device email", logger.Fields{"user_id": userID})
+	}
+	if _, err := notifications.EnqueueInternal(bctx, userID, "new_device_login", payload); err != nil {
+		logger.LogError(bctx, err, "Failed to send new device notification", logger.Fields{"user_id": userID})
+	}
+}
+
+// sessionStorage is used by package-level endpoints, which have no Service instance
+func sessionStorage() session.Storage {
+	return session.NewPostgresStorage(db)
+}
@@ -0,0 +1,562 @@
+// Package auth provides authentication and authorization services
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"encore.app/pkg/authn"
+	"encore.app/pkg/errs"
+	"encore.app/pkg/logger"
+	"encore.app/pkg/ratelimit"
+	encore "encore.dev"
+	"encore.dev/storage/sqldb"
+	"github.com/google/uuid"
+)
+
+// Two-factor authentication settings
+const (
+	loginChallengeTTL         = 5 * time.Minute
+	maxLoginChallengeAttempts = 5
+	recoveryCodeCount         = 10
+	totpIssuer                = "Loft Dughairi"
+)
+
+// Second-factor methods offered by a login challenge
+const (
+	twoFactorMethodTOTP     = "totp"
+	twoFactorMethodSMS      = "sms"
+	twoFactorMethodRecovery = "recovery_code"
+)
+
+// SendLoginSMSRequest asks for an SMS code for a pending login
+type SendLoginSMSRequest struct {
+	ChallengeID string `json:"challenge_id" validate:"required"`
+}
+
+// SendLoginSMSResponse confirms the SMS code was sent
+type SendLoginSMSResponse struct {
+	Message string `json:"message"`
+	Success bool   `json:"success"`
+	DevMode bool   `json:"dev_mode,omitempty"`
+	Code    string `json:"code,omitempty"` // OTP code (only in dev mode)
+}
+
+// VerifyLoginChallengeRequest completes a login with a second factor
+type VerifyLoginChallengeRequest struct {
+	ChallengeID string `json:"challenge_id" validate:"required"`
+	Method      string `json:"method" validate:"required,oneof=totp sms recovery_code"`
+	Code        string `json:"code" validate:"required"`
+}
+
+// TwoFactorStatusResponse describes the caller's second factors
+type TwoFactorStatusResponse struct {
+	Required               bool `json:"required"` // admins cannot turn two-factor authentication off
+	TOTPEnabled            bool `json:"totp_enabled"`
+	SMSEnabled             bool `json:"sms_enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// SetupTOTPResponse carries the secret to add to an authenticator app
+type SetupTOTPResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"` // rendered as a QR code
+}
+
+// TOTPCodeRequest carries a code from the authenticator app
+type TOTPCodeRequest struct {
+	Code string `json:"code" validate:"required,len=6"`
+}
+
+// RecoveryCodesResponse returns recovery codes; they are shown once and stored hashed
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Message       string   `json:"message"`
+}
+
+// DisableTwoFactorRequest confirms turning two-factor authentication off
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// TwoFactorResponse is a generic confirmation
+type TwoFactorResponse struct {
+	Message string `json:"message"`
+	Success bool   `json:"success"`
+}
+
+// GetTwoFactorStatus returns the caller's two-factor settings
+//
+//encore:api auth method=GET path=/auth/2fa
+func (s *Service) GetTwoFactorStatus(ctx context.Context) (*TwoFactorStatusResponse, error) {
+	data, err := currentAuthData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tf, err := s.repo.GetTwoFactor(ctx, data.UserID)
+	if err != nil {
+		return nil, NewInternalError("فشل تحميل إعدادات التحقق بخطوتين")
+	}
+	remaining, err := s.repo.CountRecoveryCodes(ctx, data.UserID)
+	if err != nil {
+		return nil, NewInternalError("فشل تحميل إعدادات التحقق بخطوتين")
+	}
+	return &TwoFactorStatusResponse{
+		Required:               data.Role == "admin",
+		TOTPEnabled:            tf.TOTPEnabledAt != nil,
+		SMSEnabled:             tf.SMSEnabledAt != nil,
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+// SetupTOTP starts authenticator app enrolment; the secret becomes active once confirmed
+//
+//encore:api auth method=POST path=/auth/2fa/totp/setup
+func (s *Service) SetupTOTP(ctx context.Context) (*SetupTOTPResponse, error) {
+	data, err := currentAuthData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := twoFactorKey()
+	if err != nil {
+		logger.LogError(ctx, err, "Authenticator enrolment refused", logger.Fields{"user_id": data.UserID})
+		return nil, ErrTwoFactorUnavailable
+	}
+	secret, err := authn.GenerateTOTPSecret()
+	if err != nil {
+		return nil, NewInternalError("فشل إنشاء مفتاح التحقق")
+	}
+	sealed, err := authn.SealSecret(key, secret)
+	if err != nil {
+		return nil, NewInternalError("فشل إنشاء مفتاح التحقق")
+	}
+	if err := s.repo.SetPendingTOTPSecret(ctx, data.UserID, sealed); err != nil {
+		return nil, NewInternalError("فشل إنشاء مفتاح التحقق")
+	}
+	return &SetupTOTPResponse{
+		Secret:     secret,
+		OTPAuthURL: authn.TOTPProvisioningURI(totpIssuer, data.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP activates the authenticator app with a first code and returns recovery codes
+//
+//encore:api auth method=POST path=/auth/2fa/totp/confirm
+func (s *Service) ConfirmTOTP(ctx context.Context, req *TOTPCodeRequest) (*RecoveryCodesResponse, error) {
+	data, err := currentAuthData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyRateLimit.RecordAttempt(ratelimit.GenerateUserKey("2fa_confirm", data.UserID)); err != nil {
+		return nil, NewRateLimitError("محاولات كثيرة جداً. يرجى المحاولة لاحقاً")
+	}
+	tf, err := s.repo.GetTwoFactor(ctx, data.UserID)
+	if err != nil {
+		return nil, NewInternalError("فشل تفعيل التحقق بخطوتين")
+	}
+	if tf.TOTPPendingSecret == "" {
+		return nil, NewValidationError("يرجى بدء إعداد تطبيق المصادقة أولاً")
+	}
+	key, err := twoFactorKey()
+	if err != nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+	secret, err := authn.OpenSecret(key, tf.TOTPPendingSecret)
+	if err != nil {
+		return nil, NewInternalError("فشل تفعيل التحقق بخطوتين")
+	}
+	step, err := authn.ValidateTOTP(secret, req.Code, time.Now())
+	if err != nil {
+		return nil, ErrTwoFactorInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, NewInternalError("فشل إنشاء رموز الاسترداد")
+	}
+	if err := s.repo.EnableTOTP(ctx, data.UserID, step, hashes); err != nil {
+		return nil, NewInternalError("فشل تفعيل التحقق بخطوتين")
+	}
+	logger.Info(ctx, "TOTP two-factor enabled", logger.Fields{"user_id": data.UserID})
+	return &RecoveryCodesResponse{
+		RecoveryCodes: codes,
+		Message:       "تم تفعيل التحقق بخطوتين. احفظ رموز الاسترداد في مكان آمن، لن تظهر مرة أخرى",
+	}, nil
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes
+//
+//encore:api auth method=POST path=/auth/2fa/recovery-codes
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, req *TOTPCodeRequest) (*RecoveryCodesResponse, error) {
+	data, err := currentAuthData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyRateLimit.RecordAttempt(ratelimit.GenerateUserKey("2fa_recovery", data.UserID)); err != nil {
+		return nil, NewRateLimitError("محاولات كثيرة جداً. يرجى المحاولة لاحقاً")
+	}
+	tf, err := s.repo.GetTwoFactor(ctx, data.UserID)
+	if err != nil {
+		return nil, NewInternalError("فشل إنشاء رموز الاسترداد")
+	}
+	if tf.TOTPEnabledAt == nil {
+		return nil, NewValidationError("تطبيق المصادقة غير مفعّل")
+	}
+	if err := s.verifyTOTP(ctx, data.UserID, tf, req.Code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, NewInternalError("فشل إنشاء رموز الاسترداد")
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, data.UserID, hashes); err != nil {
+		return nil, NewInternalError("فشل إنشاء رموز الاسترداد")
+	}
+	return &RecoveryCodesResponse{
+		RecoveryCodes: codes,
+		Message:       "تم إنشاء رموز استرداد جديدة وإلغاء الرموز السابقة",
+	}, nil
+}
+
+// EnableSMSTwoFactor turns on SMS codes to the registered phone as a second factor
+//
+//encore:api auth method=POST path=/auth/2fa/sms/enable
+func (s *Service) EnableSMSTwoFactor(ctx context.Context) (*TwoFactorResponse, error) {
+	data, err := currentAuthData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetUserByID(ctx, data.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Phone == "" {
+		return nil, NewValidationError("لا يوجد رقم جوال مسجل في الحساب")
+	}
+	if err := s.repo.EnableSMSTwoFactor(ctx, user.ID); err != nil {
+		return nil, NewInternalError("فشل تفعيل التحقق عبر الجوال")
+	}
+	logger.Info(ctx, "SMS two-factor enabled", logger.Fields{"user_id": user.ID})
+	return &TwoFactorResponse{Message: "تم تفعيل التحقق بخطوتين عبر الجوال", Success: true}, nil
+}
+
+// DisableTwoFactor turns every second factor off; not allowed for admins
+//
+//encore:api auth method=POST path=/auth/2fa/disable
+func (s *Service) DisableTwoFactor(ctx context.Context, req *DisableTwoFactorRequest) (*TwoFactorResponse, error) {
+	data, err := currentAuthData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if data.Role == "admin" {
+		return nil, &errs.Error{Code: AuthForbidden, Message: "التحقق بخطوتين إلزامي لحسابات المدراء"}
+	}
+	user, err := s.repo.GetUserByID(ctx, data.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := authn.VerifyPassword(req.Password, user.PasswordHash); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := s.repo.DisableTwoFactor(ctx, user.ID); err != nil {
+		return nil, NewInternalError("فشل إيقاف التحقق بخطوتين")
+	}
+	logger.Info(ctx, "Two-factor disabled", logger.Fields{"user_id": user.ID})
+	return &TwoFactorResponse{Message: "تم إيقاف التحقق بخطوتين", Success: true}, nil
+}
+
+// SendLoginSMS sends an SMS code for a pending login challenge
+//
+//encore:api public method=POST path=/auth/login/2fa/sms
+func (s *Service) SendLoginSMS(ctx context.Context, req *SendLoginSMSRequest) (*SendLoginSMSResponse, error) {
+	// Limited per client and per account, so a fresh challenge does not reset the budget
+	if err := s.verifyRateLimit.RecordAttempt(ratelimit.GenerateIPKey("2fa_sms", getClientIP(ctx))); err != nil {
+		return nil, NewRateLimitError("محاولات كثيرة جداً. يرجى المحاولة لاحقاً")
+	}
+	ch, user, tf, err := s.loadLoginChallenge(ctx, req.ChallengeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyRateLimit.RecordAttempt(ratelimit.GenerateUserKey("2fa_sms", user.ID)); err != nil {
+		return nil, NewRateLimitError("محاولات كثيرة جداً. يرجى المحاولة لاحقاً")
+	}
+	methods, _ := twoFactorMethods(user, tf)
+	if !containsMethod(methods, twoFactorMethodSMS) {
+		return nil, NewValidationError("التحقق عبر الجوال غير متاح لهذا الحساب")
+	}
+
+	// Twilio Verify generates and checks the code itself
+	if !s.smsClient.IsDevMode() && s.smsClient.HasVerifyService() {
+		if err := s.smsClient.SendOTP(ctx, user.Phone, ""); err != nil {
+			logger.Error(ctx, "Failed to send Twilio Verify OTP", logger.Fields{"error": err.Error()})
+			return nil, NewInternalError("فشل إرسال رمز التحقق. يرجى المحاولة مرة أخرى.")
+		}
+		if err := s.repo.SetLoginChallengeSMS(ctx, ch.ID, ""); err != nil {
+			return nil, NewInternalError("فشل إرسال رمز التحقق. يرجى المحاولة مرة أخرى.")
+		}
+		return &SendLoginSMSResponse{Message: "تم إرسال رمز التحقق إلى جوالك", Success: true}, nil
+	}
+
+	// Without an SMS gateway the code is shown in the response, which is only acceptable on a
+	// developer's machine; elsewhere it would let a password alone pass the second factor
+	localDev := encore.Meta().Environment.Type == encore.EnvDevelopment && encore.Meta().Environment.Cloud == encore.CloudLocal
+	if s.smsClient.IsDevMode() && !localDev {
+		logger.Error(ctx, "Login SMS requested but no SMS gateway is configured", logger.Fields{"user_id": user.ID})
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	code, err := authn.GenerateVerificationCode()
+	if err != nil {
+		return nil, NewInternalError("Failed to generate verification code.")
+	}
+	if err := s.repo.SetLoginChallengeSMS(ctx, ch.ID, authn.HashRecoveryCode(code)); err != nil {
+		return nil, NewInternalError("فشل إرسال رمز التحقق. يرجى المحاولة مرة أخرى.")
+	}
+
+	if s.smsClient.IsDevMode() {
+		return &SendLoginSMSResponse{
+			Message: fmt.Sprintf("🔧 [DEV MODE] رمز التحقق: %s", code),
+			Success: true,
+			DevMode: true,
+			Code:    code,
+		}, nil
+	}
+
+	if err := s.smsClient.SendOTP(ctx, user.Phone, code); err != nil {
+		logger.Error(ctx, "Failed to send SMS OTP", logger.Fields{"error": err.Error()})
+		return nil, NewInternalError("فشل إرسال رمز التحقق. يرجى المحاولة مرة أخرى.")
+	}
+	return &SendLoginSMSResponse{Message: "تم إرسال رمز التحقق إلى جوالك", Success: true}, nil
+}
+
+// startLoginChallenge returns a challenge response when the user must pass a second factor,
+// or nil when tokens can be issued right away
+func (s *Service) startLoginChallenge(ctx context.Context, user *User) (*LoginResponse, error) {
+	tf, err := s.repo.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		return nil, NewInternalError("Failed to load two-factor settings.")
+	}
+	if user.Role != "admin" && tf.TOTPEnabledAt == nil && tf.SMSEnabledAt == nil {
+		return nil, nil
+	}
+
+	methods, setupRequired := twoFactorMethods(user, tf)
+	if len(methods) == 0 {
+		logger.Warn(ctx, "Admin login blocked: no second factor available", logger.Fields{"user_id": user.ID})
+		return nil, ErrTwoFactorRequired
+	}
+
+	ch := LoginChallenge{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		IPAddress: getClientIP(ctx),
+		UserAgent: getUserAgent(ctx),
+		ExpiresAt: time.Now().UTC().Add(loginChallengeTTL),
+	}
+	if err := s.repo.CreateLoginChallenge(ctx, ch); err != nil {
+		return nil, NewInternalError("Failed to start two-factor verification.")
+	}
+	return &LoginResponse{
+		ExpiresAt:              ch.ExpiresAt,
+		TwoFactorRequired:      true,
+		ChallengeID:            ch.ID,
+		TwoFactorMethods:       methods,
+		TwoFactorSetupRequired: setupRequired,
+	}, nil
+}
+
+// completeLoginChallenge verifies the second factor of a pending login and issues tokens
+func (s *Service) completeLoginChallenge(ctx context.Context, req *VerifyLoginChallengeRequest) (*LoginResponse, error) {
+	ch, user, tf, err := s.loadLoginChallenge(ctx, req.ChallengeID)
+	if err != nil {
+		return nil, err
+	}
+	// Wrong codes count against the account like wrong passwords
+	email := strings.ToLower(strings.TrimSpace(user.Email))
+	clientIP := getClientIP(ctx)
+	accountPolicy, ipPolicy := loginLockoutPolicies()
+	if err := s.checkLoginLockout(ctx, email, clientIP, accountPolicy, ipPolicy); err != nil {
+		return nil, err
+	}
+	wrongCode := func() error {
+		if err := s.recordLoginFailure(ctx, email, clientIP, user, accountPolicy, ipPolicy); !errors.Is(err, ErrInvalidCredentials) {
+			return err
+		}
+		return ErrTwoFactorInvalidCode
+	}
+
+	attempts, err := s.repo.RecordLoginChallengeAttempt(ctx, ch.ID)
+	if err != nil {
+		return nil, NewInternalError("Failed to verify two-factor code.")
+	}
+	if attempts > maxLoginChallengeAttempts {
+		return nil, ErrLoginChallengeInvalid
+	}
+
+	methods, _ := twoFactorMethods(user, tf)
+	if !containsMethod(methods, req.Method) {
+		return nil, NewValidationError("وسيلة التحقق غير متاحة لهذا الحساب")
+	}
+
+	switch req.Method {
+	case twoFactorMethodTOTP:
+		if err := s.verifyTOTP(ctx, user.ID, tf, req.Code); errors.Is(err, ErrTwoFactorInvalidCode) {
+			return nil, wrongCode()
+		} else if err != nil {
+			return nil, err
+		}
+	case twoFactorMethodRecovery:
+		ok, err := s.repo.UseRecoveryCode(ctx, user.ID, authn.HashRecoveryCode(req.Code))
+		if err != nil {
+			return nil, NewInternalError("Failed to verify two-factor code.")
+		}
+		if !ok {
+			return nil, wrongCode()
+		}
+		logger.Warn(ctx, "Login completed with a recovery code", logger.Fields{"user_id": user.ID})
+	case twoFactorMethodSMS:
+		if err := s.verifyLoginSMS(ctx, ch, user, req.Code); errors.Is(err, ErrTwoFactorInvalidCode) {
+			return nil, wrongCode()
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	// A challenge yields tokens once, even if two requests race with valid codes
+	completed, err := s.repo.CompleteLoginChallenge(ctx, ch.ID)
+	if err != nil {
+		return nil, NewInternalError("Failed to verify two-factor code.")
+	}
+	if !completed {
+		return nil, ErrLoginChallengeInvalid
+	}
+	resp, err := s.issueLogin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.clearAccountLoginFailures(ctx, email)
+	return resp, nil
+}
+
+// loadLoginChallenge returns a pending challenge with its user and two-factor settings
+func (s *Service) loadLoginChallenge(ctx context.Context, id string) (*LoginChallenge, *User, *TwoFactorSettings, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil, nil, ErrLoginChallengeInvalid
+	}
+	ch, err := s.repo.GetLoginChallenge(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, nil, nil, ErrLoginChallengeInvalid
+	}
+	if err != nil {
+		return nil, nil, nil, NewInternalError("Failed to load two-factor challenge.")
+	}
+	if ch.CompletedAt != nil || time.Now().UTC().After(ch.ExpiresAt) || ch.Attempts >= maxLoginChallengeAttempts {
+		return nil, nil, nil, ErrLoginChallengeInvalid
+	}
+	user, err := s.repo.GetUserByID(ctx, ch.UserID)
+	if err != nil {
+		return nil, nil, nil, ErrLoginChallengeInvalid
+	}
+	tf, err := s.repo.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		return nil, nil, nil, NewInternalError("Failed to load two-factor settings.")
+	}
+	return ch, user, tf, nil
+}
+
+// verifyTOTP checks an authenticator code and rejects codes that were already used
+func (s *Service) verifyTOTP(ctx context.Context, userID int64, tf *TwoFactorSettings, code string) error {
+	key, err := twoFactorKey()
+	if err != nil {
+		logger.LogError(ctx, err, "Failed to open TOTP secret", logger.Fields{"user_id": userID})
+		return NewInternalError("Failed to verify two-factor code.")
+	}
+	secret, err := authn.OpenSecret(key, tf.TOTPSecret)
+	if err != nil {
+		logger.LogError(ctx, err, "Failed to open TOTP secret", logger.Fields{"user_id": userID})
+		return NewInternalError("Failed to verify two-factor code.")
+	}
+	step, err := authn.ValidateTOTP(secret, code, time.Now())
+	if err != nil {
+		return ErrTwoFactorInvalidCode
+	}
+	fresh, err := s.repo.MarkTOTPStepUsed(ctx, userID, step)
+	if err != nil {
+		return NewInternalError("Failed to verify two-factor code.")
+	}
+	if !fresh {
+		return ErrTwoFactorInvalidCode
+	}
+	return nil
+}
+
+// verifyLoginSMS checks the SMS code sent for a challenge
+func (s *Service) verifyLoginSMS(ctx context.Context, ch *LoginChallenge, user *User, code string) error {
+	if ch.SMSSentAt == nil {
+		return NewValidationError("يرجى طلب رمز التحقق أولاً")
+	}
+	if ch.SMSCodeHash == "" {
+		if err := s.smsClient.VerifyOTP(ctx, user.Phone, strings.TrimSpace(code)); err != nil {
+			logger.Error(ctx, "Twilio Verify failed", logger.Fields{"error": err.Error()})
+			return ErrTwoFactorInvalidCode
+		}
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(authn.HashRecoveryCode(code)), []byte(ch.SMSCodeHash)) != 1 {
+		return ErrTwoFactorInvalidCode
+	}
+	return nil
+}
+
+// twoFactorMethods lists the second factors a user can sign in with. Admins who have not
+// enrolled any factor yet fall back to SMS on their registered phone and are asked to set up
+// an authenticator app.
+func twoFactorMethods(user *User, tf *TwoFactorSettings) (methods []string, setupRequired bool) {
+	if tf.TOTPEnabledAt != nil {
+		methods = append(methods, twoFactorMethodTOTP, twoFactorMethodRecovery)
+	}
+	enrolled := tf.TOTPEnabledAt != nil || tf.SMSEnabledAt != nil
+	if user.Phone != "" && (tf.SMSEnabledAt != nil || (user.Role == "admin" && !enrolled)) {
+		methods = append(methods, twoFactorMethodSMS)
+	}
+	return methods, user.Role == "admin" && !enrolled
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// newRecoveryCodes returns fresh recovery codes and the hashes to store
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes, err = authn.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes = make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = authn.HashRecoveryCode(c)
+	}
+	return codes, hashes, nil
+}
+
+// errNoTwoFactorKey is returned by twoFactorKey when TwoFactorEncryptionKey is not set
+var errNoTwoFactorKey = errors.New("auth: TwoFactorEncryptionKey is not configured")
+
+// twoFactorKey returns the key TOTP secrets are sealed with. It is a dedicated secret so that
+// rotating the JWT secrets never invalidates enrolments; without it enrolment is refused.
+func twoFactorKey() (string, error) {
+	if secrets.TwoFactorEncryptionKey == "" {
+		return "", errNoTwoFactorKey
+	}
+	return secrets.TwoFactorEncryptionKey, nil
+}
@@ -24,15 +24,31 @@ var db = sqldb.Named("coredb")
 // Service instance
 var catalogService *Service
 
-// Encore secrets for GCS configuration
+// Encore secrets for media storage (GCS, S3-compatible or local disk)
 var secrets struct {
 	GCSProjectID       string //encore:secret
 	GCSBucketName      string //encore:secret
 	GCSCredentialsJSON string //encore:secret
+	S3AccessKeyID      string //encore:secret
+	S3SecretAccessKey  string //encore:secret
+	MediaSigningKey    string //encore:secret
+}
+
+// storageSecrets passes the storage credentials to pkg/storagegcs
+func storageSecrets() storagegcs.Secrets {
+	return storagegcs.Secrets{
+		GCSProjectID:       secrets.GCSProjectID,
+		GCSBucketName:      secrets.GCSBucketName,
+		GCSCredentialsJSON: secrets.GCSCredentialsJSON,
+		S3AccessKeyID:      secrets.S3AccessKeyID,
+		S3SecretAccessKey:  secrets.S3SecretAccessKey,
+		MediaSigningKey:    secrets.MediaSigningKey,
+	}
 }
 
 // UploadMediaDraft allows uploading draft media before a product exists.
-// It stores files in GCS and returns their paths without creating DB rows.
+// It stores the originals and returns their paths without creating DB rows; images are
+// processed once attached to a product.
 //
 //encore:api auth raw method=POST path=/media-drafts/:session_id
 func UploadMediaDraft(w http.ResponseWriter, req *http.Request) {
@@ -74,26 +90,12 @@ func UploadMediaDraft(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Parse multipart form
-	if err := req.ParseMultipartForm(100 << 20); err != nil { // 100MB
+	// Parse multipart form (parts beyond 32MB are spooled to temporary files)
+	if err := req.ParseMultipartForm(32 << 20); err != nil {
 		writeErrorResponse(w, errs.E(ctx, "CAT_PARSE_MULTIPART_FAILED", "تعذر قراءة الطلب متعدد الأجزاء"))
 		return
 	}
 
-	// Prepare upload config from settings
-	mediaSettings, _ := catalogService.getMediaSettings(ctx)
-	// Map service media settings to storagegcs settings type
-	sgcsSettings := storagegcs.MediaSettings{
-		WatermarkEnabled:  mediaSettings.WatermarkEnabled,
-		WatermarkPosition: mediaSettings.WatermarkPosition,
-		WatermarkOpacity:  mediaSettings.WatermarkOpacity,
-		ThumbnailsEnabled: mediaSettings.ThumbnailsEnabled,
-		ThumbnailSizes:    mediaSettings.ThumbnailSizes,
-		MaxFileSize:       mediaSettings.MaxFileSize,
-		AllowedTypes:      mediaSettings.AllowedTypes,
-	}
-	uploadCfg := storagegcs.CreateUploadConfigFromSettings(sgcsSettings)
-
 	type DraftFile struct {
 		Kind    string `json:"kind"`
 		GCSPath string `json:"gcs_path"`
@@ -130,8 +132,8 @@ func UploadMediaDraft(w http.ResponseWriter, req *http.Request) {
 			}
 			defer f.Close()
 
-			// Upload via storage client (no DB record)
-			res, err := catalogService.storage.Upload(ctx, f, fh.Filename, uploadCfg)
+			// Store the original (no DB record, no processing yet)
+			res, err := catalogService.storage.UploadOriginal(ctx, f, fh.Filename)
 			if err != nil {
 				writeErrorResponse(w, errs.E(ctx, "CAT_MEDIA_UPLOAD_FAILED", "فشل رفع الملف إلى التخزين"))
 				return
@@ -250,9 +252,13 @@ func FinalizeDraftMedia(ctx context.Context, id string, req *FinalizeMediaReques
 			MimeType:         mimePtr,
 			OriginalFilename: namePtr,
 		}
+		queueForProcessing(m)
 		if err := catalogService.repo.CreateMedia(ctx, m); err != nil {
 			return nil, errs.E(ctx, "CAT_MEDIA_SAVE_FAILED", "فشل حفظ سجل الوسائط")
 		}
+		if m.ProcessingStatus == MediaProcessingPending {
+			catalogService.enqueueMediaProcessing(ctx, m.ID)
+		}
 		created++
 	}
 
@@ -305,33 +311,25 @@ func InitService() error {
 	// Initialize slugifier
 	slugifier := slugify.NewSlugifier(db.Stdlib())
 
-	// Initialize storage client using Encore secrets
-	// GCS secrets configured via Encore secrets management:
-	// Production: encore secret set --prod GCS_PROJECT_ID <project-id>
-	// Production: encore secret set --prod GCS_BUCKET_NAME <bucket-name>
-	// Production: encore secret set --prod GCS_CREDENTIALS_JSON <base64-credentials>
-	// Development: encore secret set --dev GCS_PROJECT_ID <dev-project-id>
-	// Development: encore secret set --dev GCS_BUCKET_NAME <dev-bucket-name>
-	// Development: encore secret set --dev GCS_CREDENTIALS_JSON <dev-base64-credentials>
-	storageConfig := storagegcs.Config{
-		ProjectID:      secrets.GCSProjectID,       // From Encore secrets
-		BucketName:     secrets.GCSBucketName,      // From Encore secrets
-		CredentialsKey: secrets.GCSCredentialsJSON, // From Encore secrets
-		IsPublic:       false,                      // Private bucket by default
-	}
-
-	storageClient, err := storagegcs.NewClient(context.Background(), storageConfig)
+	// Initialize config manager (global, hot-reload every 5 minutes)
+	configMgr := config.Initialize(db, 5*time.Minute)
+
+	// Storage backend is selected by media.storage_provider (gcs | local | s3); credentials
+	// come from Encore secrets:
+	// encore secret set --prod GCSProjectID / GCSBucketName / GCSCredentialsJSON
+	// encore secret set --prod S3AccessKeyID / S3SecretAccessKey
+	// encore secret set --prod MediaSigningKey (local disk links)
+	// Outside production a provider without credentials falls back to local disk.
+	var storageClient storagegcs.Storage
+	client, err := storagegcs.Open(context.Background(), storagegcs.OptionsFromSettings(configMgr.GetSettings(), storageSecrets()))
 	if err != nil {
-		// In local/dev environments it's common to not have GCS credentials configured.
 		// Do not fail service initialization – continue without storage so read-only endpoints work.
 		// Media uploads will be disabled until storage is configured.
-		fmt.Printf("[catalog] storage init failed, continuing without GCS (thumbnails disabled): %v\n", err)
-		storageClient = nil
+		fmt.Printf("[catalog] storage init failed, continuing without storage (uploads disabled): %v\n", err)
+	} else {
+		storageClient = client
 	}
 
-	// Initialize config manager (global, hot-reload every 5 minutes)
-	configMgr := config.Initialize(db, 5*time.Minute)
-
 	// Initialize service
 	catalogService = NewService(db, slugifier, storageClient, configMgr)
 
@@ -484,8 +482,8 @@ func UploadProductMedia(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Parse multipart form
-	err = req.ParseMultipartForm(100 << 20) // 100MB limit
+	// Parse multipart form (parts beyond 32MB are spooled to temporary files)
+	err = req.ParseMultipartForm(32 << 20)
 	if err != nil {
 		writeErrorResponse(w, errs.E(ctx, "CAT_PARSE_MULTIPART_FAILED", "تعذر قراءة الطلب متعدد الأجزاء"))
 		return
@@ -600,28 +598,16 @@ func GetProductMediaList(ctx context.Context, id string) (*ProductMediaListRespo
 		return nil, errs.E(ctx, "CAT_MEDIA_READ_FAILED", "فشل جلب الوسائط")
 	}
 
-	// Generate signed URLs for media (valid for 1 hour)
-	mediaWithURLs := make([]MediaWithURL, len(mediaList))
-	for i, media := range mediaList {
-		mediaWithURLs[i] = MediaWithURL{
-			Media: media,
-		}
-
-		// Generate signed URL for main image
-		if catalogService.storage != nil {
-			signedURL, err := catalogService.storage.GetSecureURL(ctx, media.GCSPath, 1*time.Hour)
-			if err == nil {
-				mediaWithURLs[i].SignedURL = &signedURL
-			}
-
-			// Generate signed URL for thumbnail if exists
-			if media.ThumbPath != nil && *media.ThumbPath != "" {
-				thumbURL, err := catalogService.storage.GetSecureURL(ctx, *media.ThumbPath, 1*time.Hour)
-				if err == nil {
-					mediaWithURLs[i].ThumbSignedURL = &thumbURL
-				}
-			}
+	// Generate signed URLs for media (valid for 1 hour); images still being processed are skipped
+	mediaWithURLs := make([]MediaWithURL, 0, len(mediaList))
+	for _, media := range mediaList {
+		if !media.IsReady() {
+			continue
 		}
+		item := MediaWithURL{}
+		item.SignedURL, item.ThumbSignedURL = catalogService.signMediaURLs(ctx, &media)
+		item.Media = media
+		mediaWithURLs = append(mediaWithURLs, item)
 	}
 
 	return &ProductMediaListResponse{
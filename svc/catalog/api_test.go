@@ -1,6 +1,7 @@
 package catalog
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -82,7 +83,7 @@ func TestProductsListRequestValidation(t *testing.T) {
 				Limit:   20,
 			},
 			expectError: true,
-			errorMsg:    "INVALID_ARGUMENT: sort يجب أن يكون: newest, oldest, price_asc, price_desc",
+			errorMsg:    "INVALID_ARGUMENT: sort يجب أن يكون: relevance, newest, oldest, price_asc, price_desc",
 		},
 		{
 			name: "Auto-correct page and limit",
@@ -208,3 +209,123 @@ func TestProductsListRequestEmptyValues(t *testing.T) {
 		t.Error("GetSort() should return nil for empty string")
 	}
 }
+
+func TestProductsListRequestPigeonFilters(t *testing.T) {
+	req := ProductsListRequest{SexStr: "female", BirthYear: 2023, SortStr: "relevance"}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sex := req.GetSex(); sex == nil || *sex != PigeonSexFemale {
+		t.Errorf("GetSex() = %v", sex)
+	}
+	if year := req.GetBirthYear(); year == nil || *year != 2023 {
+		t.Errorf("GetBirthYear() = %v", year)
+	}
+
+	for _, bad := range []ProductsListRequest{{SexStr: "cock"}, {BirthYear: 1800}, {BirthYear: 3000}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestSearchTSQuery(t *testing.T) {
+	tests := map[string]string{
+		"":                  "",
+		"  !! ":             "",
+		"حمام زاجل":         "حمام:* & زاجل:*",
+		"SA-2023-1234":      "SA:* & 2023:* & 1234:*",
+		"fast & (pigeon):*": "fast:* & pigeon:*",
+		"مُدَرَّب":          "مدرب:*", // harakat are dropped like normalize_ar does
+		"حمـــام":           "حمام:*",
+		"ـ":                 "",       // tatweel only
+		"ً":                 "",       // a haraka only
+		"ـ ً زاجل":          "زاجل:*", // empty words are skipped, the rest still searched
+		"ٰ":                 "",       // superscript alef
+	}
+	for in, want := range tests {
+		if got := searchTSQuery(in); got != want {
+			t.Errorf("searchTSQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPriceBucketCase(t *testing.T) {
+	want := "CASE WHEN price_net < 500 THEN '0' WHEN price_net < 1000 THEN '1' WHEN price_net < 2500 THEN '2'" +
+		" WHEN price_net < 5000 THEN '3' ELSE '4' END"
+	if got := priceBucketCase(); got != want {
+		t.Errorf("priceBucketCase() = %s", got)
+	}
+}
+
+func TestProductFacetsQueryLeavesOwnFilterOut(t *testing.T) {
+	pigeon, male, q := ProductTypePigeon, PigeonSexMale, "زاجل"
+	query, args := productFacetsQuery(ProductsFilter{Type: &pigeon, Sex: &male, Search: &q})
+	if len(args) != 3 {
+		t.Fatalf("expected 3 args, got %d", len(args))
+	}
+	// The search narrows every facet
+	if !strings.Contains(query, "WHERE p.search_vector @@") {
+		t.Errorf("search must apply to the facet base: %s", query)
+	}
+	for _, want := range []string{
+		"SELECT 'type', type, COUNT(*) FROM base WHERE m_sex GROUP BY type",
+		"FROM base WHERE sex IS NOT NULL AND m_type GROUP BY sex",
+		"FROM base WHERE birth_year IS NOT NULL AND m_type AND m_sex GROUP BY birth_year",
+		"SELECT 'status', status, COUNT(*) FROM base WHERE m_type AND m_sex GROUP BY status",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("facet query missing %q:\n%s", want, query)
+		}
+	}
+}
+
+func TestAddRaceResultRequestValidation(t *testing.T) {
+	date, badDate := "2024-03-15", "15/03/2024"
+	pos, total, zero := 3, 450, 0.0
+	ok := AddRaceResultRequest{RingNumber: "SA-2023-1", RaceName: "سباق الرياض", RaceDate: &date, Position: &pos, TotalBirds: &total}
+	if err := ok.Validate(); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+	bad := []AddRaceResultRequest{
+		{RingNumber: " ", RaceName: "x"},
+		{RingNumber: "SA-1", RaceName: "  "},
+		{RingNumber: "SA-1", RaceName: "x", RaceDate: &badDate},
+		{RingNumber: "SA-1", RaceName: "x", DistanceKM: &zero},
+		{RingNumber: "SA-1", RaceName: "x", Position: &total, TotalBirds: &pos},
+	}
+	for _, r := range bad {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected error for %+v", r)
+		}
+	}
+}
+
+func TestQueueForProcessing(t *testing.T) {
+	thumb := "media/old_thumb.jpg"
+	img := &Media{Kind: MediaKindImage, GCSPath: "originals/2025/01/02/03-04-05/a_1.jpg", ThumbPath: &thumb, WatermarkApplied: true}
+	queueForProcessing(img)
+	if img.ProcessingStatus != MediaProcessingPending || img.OriginalPath == nil || *img.OriginalPath != img.GCSPath {
+		t.Fatalf("image not queued: %+v", img)
+	}
+	if img.ThumbPath != nil || img.WatermarkApplied || img.IsReady() {
+		t.Fatalf("queued image keeps processed fields: %+v", img)
+	}
+
+	vid := &Media{Kind: MediaKindVideo, GCSPath: "originals/2025/01/02/03-04-05/flight_1.mov"}
+	queueForProcessing(vid)
+	if vid.ProcessingStatus != MediaProcessingPending || vid.IsReady() {
+		t.Fatalf("video not queued: %+v", vid)
+	}
+
+	for _, m := range []*Media{
+		{Kind: MediaKindImage, GCSPath: "media/2024/01/01/legacy.jpg"},
+		{Kind: MediaKindImage, GCSPath: "https://example.com/a.jpg"},
+		{Kind: MediaKindFile, GCSPath: "originals/x.pdf"},
+	} {
+		queueForProcessing(m)
+		if m.ProcessingStatus != "" || !m.IsReady() {
+			t.Errorf("%s queued", m.GCSPath)
+		}
+	}
+}
@@ -5,18 +5,22 @@ import (
 	"time"
 
 	"encore.app/pkg/errs"
+	"encore.app/pkg/pedigree"
 )
 
 // ProductsListRequest represents the request to list products with filters
 type ProductsListRequest struct {
-	TypeStr   string  `query:"type"`      // Filter by product type (pigeon/supply)
-	StatusStr string  `query:"status"`    // Filter by product status
-	Q         string  `query:"q"`         // Search query (title, description)
-	PriceMin  float64 `query:"price_min"` // Minimum price filter (0 for no filter)
-	PriceMax  float64 `query:"price_max"` // Maximum price filter (0 for no filter)
-	Page      int     `query:"page"`      // Page number (default: 1)
-	Limit     int     `query:"limit"`     // Items per page (default: 20, max: 100)
-	SortStr   string  `query:"sort"`      // Sort order: "newest", "oldest", "price_asc", "price_desc"
+	TypeStr   string  `query:"type"`       // Filter by product type (pigeon/supply)
+	StatusStr string  `query:"status"`     // Filter by product status
+	Q         string  `query:"q"`          // Full-text search (title, description, ring number, lineage)
+	PriceMin  float64 `query:"price_min"`  // Minimum price filter (0 for no filter)
+	PriceMax  float64 `query:"price_max"`  // Maximum price filter (0 for no filter)
+	SexStr    string  `query:"sex"`        // Filter pigeons by sex (male/female/unknown)
+	BirthYear int     `query:"birth_year"` // Filter pigeons by birth year (0 for no filter)
+	Page      int     `query:"page"`       // Page number (default: 1)
+	Limit     int     `query:"limit"`      // Items per page (default: 20, max: 100)
+	SortStr   string  `query:"sort"`       // Sort order: "relevance", "newest", "oldest", "price_asc", "price_desc"
+	Facets    bool    `query:"facets"`     // Include facet counts (extra query; for filter sidebars)
 }
 
 // Validate validates the products list request and converts string parameters
@@ -53,6 +57,14 @@ func (req *ProductsListRequest) Validate() error {
 		}
 	}
 
+	// Validate pigeon filters
+	if req.SexStr != "" && req.SexStr != "male" && req.SexStr != "female" && req.SexStr != "unknown" {
+		return errs.New(errs.InvalidArgument, "sex يجب أن يكون 'male' أو 'female' أو 'unknown'")
+	}
+	if req.BirthYear != 0 && (req.BirthYear < 1900 || req.BirthYear > time.Now().Year()+1) {
+		return errs.New(errs.InvalidArgument, "birth_year غير صالح")
+	}
+
 	// Validate price range
 	if req.PriceMin < 0 {
 		return errs.New(errs.InvalidArgument, "price_min يجب ألا يكون سالبًا")
@@ -68,7 +80,7 @@ func (req *ProductsListRequest) Validate() error {
 
 	// Validate sort parameter
 	if req.SortStr != "" {
-		validSorts := []string{"newest", "oldest", "price_asc", "price_desc"}
+		validSorts := []string{"relevance", "newest", "oldest", "price_asc", "price_desc"}
 		valid := false
 		for _, sort := range validSorts {
 			if req.SortStr == sort {
@@ -77,7 +89,7 @@ func (req *ProductsListRequest) Validate() error {
 			}
 		}
 		if !valid {
-			return errs.New(errs.InvalidArgument, "sort يجب أن يكون: newest, oldest, price_asc, price_desc")
+			return errs.New(errs.InvalidArgument, "sort يجب أن يكون: relevance, newest, oldest, price_asc, price_desc")
 		}
 	}
 
@@ -118,6 +130,23 @@ func (req *ProductsListRequest) GetPriceMax() *float64 {
 	return &req.PriceMax
 }
 
+// GetSex returns the PigeonSex filter, or nil if empty
+func (req *ProductsListRequest) GetSex() *PigeonSex {
+	if req.SexStr == "" {
+		return nil
+	}
+	sex := PigeonSex(req.SexStr)
+	return &sex
+}
+
+// GetBirthYear returns pointer to BirthYear, or nil if zero
+func (req *ProductsListRequest) GetBirthYear() *int {
+	if req.BirthYear <= 0 {
+		return nil
+	}
+	return &req.BirthYear
+}
+
 // GetSort returns pointer to Sort, or nil if empty
 func (req *ProductsListRequest) GetSort() *string {
 	if req.SortStr == "" {
@@ -138,6 +167,30 @@ func (req *ProductsListRequest) GetQ() *string {
 type ProductsListResponse struct {
 	Products   []ProductSummary `json:"products"`
 	Pagination PaginationMeta   `json:"pagination"`
+	Facets     *ProductFacets   `json:"facets,omitempty"` // only when requested (facets=true)
+}
+
+// ProductFacets holds counts of the matching products (all pages) per filter value
+type ProductFacets struct {
+	Type      []FacetCount       `json:"type"`
+	Status    []FacetCount       `json:"status"`
+	Sex       []FacetCount       `json:"sex"`        // pigeons only
+	BirthYear []FacetCount       `json:"birth_year"` // pigeons with a birth date
+	Price     []PriceBucketCount `json:"price"`
+}
+
+// FacetCount is the number of products with a given value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// PriceBucketCount is the number of products with price_net in [Min, Max); Max 0 means no upper bound
+type PriceBucketCount struct {
+	Label string  `json:"label"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max,omitempty"`
+	Count int64   `json:"count"`
 }
 
 // ProductSummary represents a product summary for listings
@@ -357,7 +410,7 @@ func DefaultMediaUploadConfig() MediaUploadConfig {
 		MaxFileSizeVideo: 100 * 1024 * 1024, // 100MB
 		MaxFileSizeDoc:   10 * 1024 * 1024,  // 10MB
 		AllowedImageExt:  []string{".jpg", ".jpeg", ".png", ".webp"},
-		AllowedVideoExt:  []string{".mp4"},
+		AllowedVideoExt:  []string{".mp4", ".m4v", ".mov", ".webm"},
 		// Allow common document types: PDF and spreadsheets
 		AllowedDocExt: []string{".pdf", ".xlsx", ".xls", ".csv"},
 	}
@@ -433,3 +486,63 @@ type AuctionQuestionsResponse struct {
 type MessageResponse struct {
 	Message string `json:"message"`
 }
+
+// ========================= Pedigree DTOs =========================
+
+// PedigreeRequest selects how many generations to return
+type PedigreeRequest struct {
+	Generations int `query:"generations"` // default 3, max 5
+}
+
+// PedigreeResponse is a bird's pedigree in Ahnentafel order (see pkg/pedigree.Entry)
+type PedigreeResponse struct {
+	ProductID   int64            `json:"product_id,omitempty"`
+	Generations int              `json:"generations"`
+	Birds       []pedigree.Entry `json:"birds"`
+	CardURL     string           `json:"card_url,omitempty"`
+}
+
+// AdminPedigreeLookupRequest finds a bird by ring number
+type AdminPedigreeLookupRequest struct {
+	Ring        string `query:"ring"`
+	Generations int    `query:"generations"`
+}
+
+// AddRaceResultRequest records a race result for a bird (added as external if unknown)
+type AddRaceResultRequest struct {
+	RingNumber string   `json:"ring_number"`
+	RaceName   string   `json:"race_name"`
+	RaceDate   *string  `json:"race_date,omitempty"` // YYYY-MM-DD
+	DistanceKM *float64 `json:"distance_km,omitempty"`
+	Position   *int     `json:"position,omitempty"`
+	TotalBirds *int     `json:"total_birds,omitempty"`
+	SpeedMPM   *float64 `json:"speed_mpm,omitempty"`
+	Notes      string   `json:"notes,omitempty"`
+}
+
+// Validate validates AddRaceResultRequest
+func (r *AddRaceResultRequest) Validate() error {
+	if _, err := pedigree.NormalizeRing(r.RingNumber); err != nil {
+		return errs.New(errs.InvalidArgument, "رقم الحلقة غير صالح")
+	}
+	if strings.TrimSpace(r.RaceName) == "" {
+		return errs.New(errs.InvalidArgument, "اسم السباق مطلوب")
+	}
+	if r.RaceDate != nil {
+		if _, err := time.Parse("2006-01-02", *r.RaceDate); err != nil {
+			return errs.New(errs.InvalidArgument, "تاريخ السباق يجب أن يكون بصيغة YYYY-MM-DD")
+		}
+	}
+	if (r.DistanceKM != nil && *r.DistanceKM <= 0) || (r.SpeedMPM != nil && *r.SpeedMPM <= 0) {
+		return errs.New(errs.InvalidArgument, "المسافة والسرعة يجب أن تكون أكبر من صفر")
+	}
+	if r.Position != nil && (*r.Position <= 0 || (r.TotalBirds != nil && *r.Position > *r.TotalBirds)) {
+		return errs.New(errs.InvalidArgument, "المركز غير صالح")
+	}
+	return nil
+}
+
+// RaceResultResponse wraps a stored race result
+type RaceResultResponse struct {
+	Result pedigree.RaceResult `json:"result"`
+}
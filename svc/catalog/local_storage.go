@@ -0,0 +1,49 @@
+package catalog
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"encore.dev"
+
+	"encore.app/pkg/config"
+	"encore.app/pkg/storagegcs"
+)
+
+// localMedia is the backend ServeLocalMedia serves from; it is built on first use and rebuilt
+// only when the media.local.* settings change
+var localMedia struct {
+	sync.Mutex
+	config  storagegcs.LocalConfig
+	backend *storagegcs.LocalBackend
+}
+
+func localMediaBackend() (*storagegcs.LocalBackend, error) {
+	cfg := storagegcs.OptionsFromSettings(config.Initialize(db, 5*time.Minute).GetSettings(), storageSecrets()).Local
+
+	localMedia.Lock()
+	defer localMedia.Unlock()
+	if localMedia.backend != nil && localMedia.config == cfg {
+		return localMedia.backend, nil
+	}
+	backend, err := storagegcs.NewLocalBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	localMedia.config, localMedia.backend = cfg, backend
+	return backend, nil
+}
+
+// ServeLocalMedia serves files of the local storage backend. Access is granted by the HMAC
+// signature in the link (see storagegcs.LocalBackend), so the endpoint is public.
+//
+//encore:api public raw method=GET path=/storage/local/*path
+func ServeLocalMedia(w http.ResponseWriter, req *http.Request) {
+	backend, err := localMediaBackend()
+	if err != nil {
+		http.Error(w, "local storage is not configured", http.StatusNotFound)
+		return
+	}
+	backend.ServeObject(w, req, encore.CurrentRequest().PathParams.Get("path"))
+}
@@ -0,0 +1,325 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"encore.dev/pubsub"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/storagegcs"
+)
+
+// ========================= Media Processing =========================
+// Uploads store the original as received (originals/...) and return immediately. The media
+// worker below builds the responsive variants (watermarked, metadata stripped) and switches
+// media.gcs_path / thumb_path to them; until then the media is not shown publicly. Videos are
+// validated, transcoded to web-safe MP4 and get poster-frame variants (see media_video.go).
+
+// MediaProcessingEvent asks the media worker to (re)build the variants of an image or video
+type MediaProcessingEvent struct {
+	MediaID int64 `json:"media_id"`
+}
+
+// MediaProcessingTopic carries images waiting for processing (new uploads and admin re-runs)
+var MediaProcessingTopic = pubsub.NewTopic[*MediaProcessingEvent]("media-processing", pubsub.TopicConfig{
+	DeliveryGuarantee: pubsub.AtLeastOnce,
+})
+
+var _ = pubsub.NewSubscription(MediaProcessingTopic, "catalog-media-worker", pubsub.SubscriptionConfig[*MediaProcessingEvent]{
+	Handler:        handleMediaProcessing,
+	MaxConcurrency: 2, // full-size decodes are memory hungry
+	AckDeadline:    5 * time.Minute,
+	RetryPolicy:    &pubsub.RetryPolicy{MinBackoff: 10 * time.Second, MaxBackoff: 10 * time.Minute, MaxRetries: 5},
+})
+
+func handleMediaProcessing(ctx context.Context, evt *MediaProcessingEvent) error {
+	if catalogService == nil {
+		if err := InitService(); err != nil {
+			return err
+		}
+	}
+	return catalogService.processMedia(ctx, evt.MediaID)
+}
+
+// maxOriginalSize bounds how much of an original the worker reads into memory
+const maxOriginalSize = 100 << 20
+
+// mediaProcessingTimeout is how long media may stay 'processing' before they are treated as
+// abandoned (worker crash, or the subscription ran out of retries) and can be queued again
+const mediaProcessingTimeout = 30 * time.Minute
+
+// variantConfig builds the worker configuration from the current media settings
+func (s *Service) variantConfig(ctx context.Context) storagegcs.VariantConfig {
+	ms, _ := s.getMediaSettings(ctx)
+	cfg := storagegcs.VariantConfig{
+		ApplyWatermark:    ms.WatermarkEnabled,
+		WatermarkOpacity:  int(ms.WatermarkOpacity * 100),
+		WatermarkPosition: ms.WatermarkPosition,
+	}
+	if s.config != nil {
+		st := s.config.GetSettings()
+		cfg.Widths = st.MediaVariantWidths
+		cfg.Quality = st.MediaVariantQuality
+		for _, f := range st.MediaVariantFormats {
+			cfg.Formats = append(cfg.Formats, storagegcs.VariantFormat(strings.ToLower(f)))
+		}
+	}
+	return cfg
+}
+
+// processMedia builds and stores the variants of one image, or hands videos to processVideo.
+// Storage errors are returned so Pub/Sub retries; files that cannot be decoded are marked
+// failed without retry.
+func (s *Service) processMedia(ctx context.Context, mediaID int64) error {
+	if s.storage == nil {
+		return errors.New("media storage is not configured")
+	}
+	job, err := s.repo.ClaimMediaForProcessing(ctx, mediaID)
+	if err != nil || job == nil {
+		return err
+	}
+
+	fail := func(err error) error {
+		if ferr := s.repo.FailMediaProcessing(ctx, job.ID, err.Error()); ferr != nil {
+			fmt.Printf("[catalog] media %d: failed to record processing error: %v\n", job.ID, ferr)
+		}
+		return err
+	}
+
+	cfg := s.variantConfig(ctx)
+	signature := cfg.Signature()
+	if job.SourceWatermarked {
+		// legacy upload processed inline: the logo is already on the original
+		cfg.ApplyWatermark = false
+	}
+
+	if job.Kind == MediaKindVideo {
+		return s.processVideo(ctx, job, cfg, signature, fail)
+	}
+
+	original, err := s.readObject(ctx, job.Source)
+	if err != nil {
+		return fail(err)
+	}
+	res, err := storagegcs.BuildVariants(ctx, original, cfg)
+	if errors.Is(err, storagegcs.ErrUnsupportedImage) {
+		_ = fail(err)
+		return nil
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	out, err := s.uploadVariants(ctx, job.ID, res)
+	if err != nil {
+		return fail(err)
+	}
+	out.Watermarked = res.WatermarkApplied || job.SourceWatermarked
+	out.Signature = signature
+
+	old, err := s.repo.CompleteMediaProcessing(ctx, job.ID, out)
+	if err != nil {
+		s.deleteObjects(ctx, variantPaths(out.Variants))
+		return fail(err)
+	}
+	s.deleteObjects(ctx, old)
+	return nil
+}
+
+// uploadVariants stores built variants under a fresh prefix. The largest JPEG becomes the main
+// path and the smallest the thumbnail; on error the variants stored so far are removed.
+func (s *Service) uploadVariants(ctx context.Context, mediaID int64, res *storagegcs.ProcessedImage) (processedMedia, error) {
+	if len(res.Unavailable) > 0 {
+		fmt.Printf("[catalog] media %d: no encoder installed for %v, skipped\n", mediaID, res.Unavailable)
+	}
+
+	prefix := fmt.Sprintf("%s%d/%s/", storagegcs.VariantsPrefix, mediaID, time.Now().UTC().Format("20060102T150405.000"))
+	out := processedMedia{Variants: make([]MediaVariant, 0, len(res.Variants))}
+	for _, v := range res.Variants {
+		path := fmt.Sprintf("%sw%d%s", prefix, v.Width, v.Format.Ext())
+		if _, err := s.storage.Put(ctx, path, bytes.NewReader(v.Content)); err != nil {
+			s.deleteObjects(ctx, variantPaths(out.Variants))
+			return processedMedia{}, fmt.Errorf("upload variant %s: %w", path, err)
+		}
+		out.Variants = append(out.Variants, MediaVariant{
+			MediaID:   mediaID,
+			Format:    string(v.Format),
+			Width:     v.Width,
+			Height:    v.Height,
+			Path:      path,
+			SizeBytes: int64(len(v.Content)),
+		})
+		// variants come ordered by width: first JPEG is the thumbnail, last the main image
+		if v.Format == storagegcs.FormatJPEG {
+			if out.ThumbPath == "" {
+				out.ThumbPath = path
+			}
+			out.MainPath = path
+		}
+	}
+	return out, nil
+}
+
+func (s *Service) readObject(ctx context.Context, path string) ([]byte, error) {
+	r, err := s.storage.Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(io.LimitReader(r, maxOriginalSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(content) > maxOriginalSize {
+		return nil, fmt.Errorf("%w: original exceeds %d bytes", storagegcs.ErrUnsupportedImage, maxOriginalSize)
+	}
+	return content, nil
+}
+
+// deleteObjects removes stored files, best effort
+func (s *Service) deleteObjects(ctx context.Context, paths []string) {
+	for _, p := range paths {
+		if err := s.storage.Delete(ctx, p); err != nil {
+			fmt.Printf("[catalog] failed to delete %s: %v\n", p, err)
+		}
+	}
+}
+
+func variantPaths(variants []MediaVariant) []string {
+	paths := make([]string, len(variants))
+	for i, v := range variants {
+		paths[i] = v.Path
+	}
+	return paths
+}
+
+// queueForProcessing marks media stored by UploadOriginal for the worker. Call before
+// CreateMedia, then publish with enqueueMediaProcessing once the row exists.
+func queueForProcessing(m *Media) {
+	if (m.Kind != MediaKindImage && m.Kind != MediaKindVideo) || !strings.HasPrefix(m.GCSPath, storagegcs.OriginalsPrefix) {
+		return
+	}
+	original := m.GCSPath
+	m.OriginalPath = &original
+	m.ThumbPath = nil
+	m.WatermarkApplied = false
+	m.ProcessingStatus = MediaProcessingPending
+}
+
+// enqueueMediaProcessing publishes processing jobs. A failed publish leaves the media pending;
+// admins can re-run it from AdminReprocessMedia.
+func (s *Service) enqueueMediaProcessing(ctx context.Context, mediaIDs ...int64) {
+	for _, id := range mediaIDs {
+		if _, err := MediaProcessingTopic.Publish(ctx, &MediaProcessingEvent{MediaID: id}); err != nil {
+			fmt.Printf("[catalog] failed to queue media %d for processing: %v\n", id, err)
+		}
+	}
+}
+
+// enqueuePendingMedia publishes jobs for every pending image of a product
+func (s *Service) enqueuePendingMedia(ctx context.Context, productID int64) {
+	ids, err := s.repo.PendingMediaIDs(ctx, productID)
+	if err != nil {
+		fmt.Printf("[catalog] failed to list pending media of product %d: %v\n", productID, err)
+		return
+	}
+	s.enqueueMediaProcessing(ctx, ids...)
+}
+
+// signMediaURLs replaces storage paths with signed URLs for clients. Media that are not
+// processed yet get no URLs: their only file is the original, which may carry EXIF/GPS.
+func (s *Service) signMediaURLs(ctx context.Context, m *Media) (main, thumb *string) {
+	if s.storage == nil || !m.IsReady() {
+		return nil, nil
+	}
+	if u, err := s.storage.GetSecureURL(ctx, m.GCSPath, 1*time.Hour); err == nil {
+		main = &u
+	}
+	if m.ThumbPath != nil && *m.ThumbPath != "" {
+		if u, err := s.storage.GetSecureURL(ctx, *m.ThumbPath, 1*time.Hour); err == nil {
+			thumb = &u
+		}
+	}
+	for i := range m.Variants {
+		if u, err := s.storage.GetSecureURL(ctx, m.Variants[i].Path, 1*time.Hour); err == nil {
+			m.Variants[i].URL = &u
+		}
+	}
+	return main, thumb
+}
+
+// ReprocessMediaRequest selects images whose variants should be rebuilt
+type ReprocessMediaRequest struct {
+	ProductID *int64  `json:"product_id,omitempty"`
+	MediaIDs  []int64 `json:"media_ids,omitempty"`
+	// StaleOnly limits the run to images built with other settings (e.g. before a watermark
+	// change), never built, or failed
+	StaleOnly bool `json:"stale_only"`
+}
+
+// ReprocessMediaResponse reports how many images were queued
+type ReprocessMediaResponse struct {
+	Queued    int    `json:"queued"`
+	Signature string `json:"signature"` // current settings signature
+}
+
+// AdminReprocessMedia re-runs the media worker, e.g. after changing the watermark settings
+//
+//encore:api auth method=POST path=/catalog/admin/media/reprocess
+func AdminReprocessMedia(ctx context.Context, req *ReprocessMediaRequest) (*ReprocessMediaResponse, error) {
+	if catalogService == nil {
+		if err := InitService(); err != nil {
+			return nil, errs.E(ctx, "CAT_INIT_FAILED", "فشل تهيئة خدمة الكتالوج")
+		}
+	}
+	if err := checkAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if req == nil {
+		req = &ReprocessMediaRequest{}
+	}
+	if len(req.MediaIDs) > 1000 {
+		return nil, errs.New(errs.InvalidArgument, "عدد الوسائط كبير جدًا (الحد ١٠٠٠)")
+	}
+
+	signature := catalogService.variantConfig(ctx).Signature()
+	ids, err := catalogService.repo.MarkMediaForReprocessing(ctx, req.ProductID, req.MediaIDs, req.StaleOnly, signature, mediaProcessingTimeout)
+	if err != nil {
+		return nil, errs.E(ctx, "CAT_MEDIA_UPDATE_FAILED", "فشل تحديث الوسائط")
+	}
+	catalogService.enqueueMediaProcessing(ctx, ids...)
+	return &ReprocessMediaResponse{Queued: len(ids), Signature: signature}, nil
+}
+
+// MediaProcessingOverview summarizes the media pipeline
+type MediaProcessingOverview struct {
+	Counts    map[string]int `json:"counts"` // images per processing status
+	Stale     int            `json:"stale"`  // ready images built with other settings
+	Signature string         `json:"signature"`
+}
+
+// AdminGetMediaProcessing returns processing counts and how many images are stale
+//
+//encore:api auth method=GET path=/catalog/admin/media/processing
+func AdminGetMediaProcessing(ctx context.Context) (*MediaProcessingOverview, error) {
+	if catalogService == nil {
+		if err := InitService(); err != nil {
+			return nil, errs.E(ctx, "CAT_INIT_FAILED", "فشل تهيئة خدمة الكتالوج")
+		}
+	}
+	if err := checkAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	signature := catalogService.variantConfig(ctx).Signature()
+	counts, stale, err := catalogService.repo.MediaProcessingCounts(ctx, signature)
+	if err != nil {
+		return nil, errs.E(ctx, "CAT_MEDIA_READ_FAILED", "فشل جلب الوسائط")
+	}
+	return &MediaProcessingOverview{Counts: counts, Stale: stale, Signature: signature}, nil
+}
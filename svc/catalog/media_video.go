@@ -0,0 +1,169 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"encore.app/pkg/storagegcs"
+	"encore.app/pkg/video"
+)
+
+// ========================= Video Processing =========================
+// Videos are validated (container, codec, duration), remuxed or transcoded to H.264/AAC MP4
+// under media/videos/ and get a poster frame, stored as regular image variants. Without a
+// transcoder the original is published as uploaded once it passes validation.
+
+// mediaVideosPrefix holds the web-safe videos written by the worker
+const mediaVideosPrefix = "media/videos/"
+
+// localTranscoder is shared by workers; ffmpeg is looked up once
+var localTranscoder = sync.OnceValue(video.NewLocal)
+
+// transcoder picks the video backend from media.video.transcoder
+func (s *Service) transcoder() video.Transcoder {
+	if s.config != nil && strings.EqualFold(s.config.GetSettings().MediaVideoTranscoder, "none") {
+		return &video.Local{} // probe only
+	}
+	return localTranscoder()
+}
+
+// videoLimits builds the validation rules from the current settings
+func (s *Service) videoLimits() video.Limits {
+	limits := video.Limits{MaxDuration: 3 * time.Minute}
+	if s.config != nil {
+		st := s.config.GetSettings()
+		if st.MediaVideoMaxDuration > 0 {
+			limits.MaxDuration = time.Duration(st.MediaVideoMaxDuration) * time.Second
+		}
+		limits.AllowedCodecs = st.MediaVideoAllowedCodecs
+	}
+	return limits
+}
+
+// processVideo validates a video, publishes a web-safe copy and builds its poster variants.
+// Videos that fail validation are marked failed without retry.
+func (s *Service) processVideo(ctx context.Context, job *mediaJob, cfg storagegcs.VariantConfig, signature string, fail func(error) error) error {
+	dir, err := os.MkdirTemp("", "media-video-*")
+	if err != nil {
+		return fail(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "original"+filepath.Ext(job.Source))
+	if err := s.downloadObject(ctx, job.Source, src, DefaultMediaUploadConfig().MaxFileSizeVideo); err != nil {
+		if errors.Is(err, video.ErrUnsupported) {
+			_ = fail(err)
+			return nil
+		}
+		return fail(err)
+	}
+
+	tc := s.transcoder()
+	info, err := tc.Probe(ctx, src)
+	if err == nil {
+		err = video.Validate(info, s.videoLimits())
+	}
+	if errors.Is(err, video.ErrUnsupported) || errors.Is(err, video.ErrUnavailable) {
+		_ = fail(err)
+		return nil
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	// web-safe copy: remux when browsers can already play it, re-encode otherwise
+	mainPath := job.Source
+	out := filepath.Join(dir, "web.mp4")
+	err = tc.Transcode(ctx, src, out, video.TranscodeOptions{Remux: info.WebSafe()})
+	switch {
+	case errors.Is(err, video.ErrUnavailable):
+		fmt.Printf("[catalog] media %d: no video transcoder installed, publishing original\n", job.ID)
+	case err != nil:
+		return fail(fmt.Errorf("transcode: %w", err))
+	default:
+		if probed, perr := tc.Probe(ctx, out); perr == nil {
+			info = probed
+		}
+		mainPath = fmt.Sprintf("%s%d/%s.mp4", mediaVideosPrefix, job.ID, time.Now().UTC().Format("20060102T150405.000"))
+		if err := s.uploadFile(ctx, mainPath, out); err != nil {
+			return fail(fmt.Errorf("upload video: %w", err))
+		}
+	}
+
+	// poster frame, served like any image (watermarked, resized, metadata stripped)
+	res := processedMedia{}
+	poster, err := tc.Poster(ctx, src, video.PosterOffset(info.Duration))
+	switch {
+	case errors.Is(err, video.ErrUnavailable):
+	case err != nil:
+		fmt.Printf("[catalog] media %d: poster extraction failed: %v\n", job.ID, err)
+	default:
+		built, berr := storagegcs.BuildVariants(ctx, poster, cfg)
+		if berr != nil {
+			fmt.Printf("[catalog] media %d: poster variants failed: %v\n", job.ID, berr)
+			break
+		}
+		if res, err = s.uploadVariants(ctx, job.ID, built); err != nil {
+			s.deleteNewVideo(ctx, job, mainPath)
+			return fail(err)
+		}
+	}
+
+	res.MainPath = mainPath
+	res.Signature = signature
+	res.Video = info
+	old, err := s.repo.CompleteMediaProcessing(ctx, job.ID, res)
+	if err != nil {
+		s.deleteObjects(ctx, variantPaths(res.Variants))
+		s.deleteNewVideo(ctx, job, mainPath)
+		return fail(err)
+	}
+	s.deleteObjects(ctx, old)
+	return nil
+}
+
+// deleteNewVideo removes a transcode written by this run (never the original)
+func (s *Service) deleteNewVideo(ctx context.Context, job *mediaJob, mainPath string) {
+	if mainPath != job.Source {
+		s.deleteObjects(ctx, []string{mainPath})
+	}
+}
+
+// downloadObject copies a stored file to local disk; files over maxSize are unsupported
+func (s *Service) downloadObject(ctx context.Context, path, dst string, maxSize int64) error {
+	r, err := s.storage.Open(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if n > maxSize {
+		return fmt.Errorf("%w: original exceeds %d bytes", video.ErrUnsupported, maxSize)
+	}
+	return f.Close()
+}
+
+func (s *Service) uploadFile(ctx context.Context, path, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = s.storage.Put(ctx, path, f)
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"time"
+
+	"encore.app/pkg/pedigree"
 )
 
 // ProductType represents the type of product
@@ -156,6 +158,36 @@ func (mk *MediaKind) Scan(value interface{}) error {
 	return fmt.Errorf("cannot scan %T into MediaKind", value)
 }
 
+// MediaProcessingStatus tracks the media worker for uploaded images
+type MediaProcessingStatus string
+
+const (
+	MediaProcessingPending    MediaProcessingStatus = "pending"
+	MediaProcessingProcessing MediaProcessingStatus = "processing"
+	MediaProcessingReady      MediaProcessingStatus = "ready"
+	MediaProcessingFailed     MediaProcessingStatus = "failed"
+)
+
+// Value implements driver.Valuer interface for database storage
+func (ps MediaProcessingStatus) Value() (driver.Value, error) {
+	if ps == "" {
+		return string(MediaProcessingReady), nil
+	}
+	return string(ps), nil
+}
+
+// Scan implements sql.Scanner interface for database retrieval
+func (ps *MediaProcessingStatus) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	if str, ok := value.(string); ok {
+		*ps = MediaProcessingStatus(str)
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into MediaProcessingStatus", value)
+}
+
 // Product represents a product in the catalog
 type Product struct {
 	ID           int64         `json:"id" db:"id"`
@@ -218,6 +250,38 @@ type Media struct {
 	ArchivedAt       *time.Time `json:"archived_at" db:"archived_at"`
 	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Processing pipeline (images are processed asynchronously after upload)
+	ProcessingStatus MediaProcessingStatus `json:"processing_status" db:"processing_status"`
+	ProcessingError  *string               `json:"processing_error,omitempty" db:"processing_error"`
+	ProcessedAt      *time.Time            `json:"processed_at,omitempty" db:"processed_at"`
+	OriginalPath     *string               `json:"-" db:"original_path"` // never exposed: may carry EXIF/GPS
+	Variants         []MediaVariant        `json:"variants,omitempty"`
+
+	// Video details (thumb_path holds the poster frame)
+	DurationMS *int    `json:"duration_ms,omitempty" db:"duration_ms"`
+	Width      *int    `json:"width,omitempty" db:"width"`
+	Height     *int    `json:"height,omitempty" db:"height"`
+	VideoCodec *string `json:"video_codec,omitempty" db:"video_codec"`
+	AudioCodec *string `json:"audio_codec,omitempty" db:"audio_codec"`
+}
+
+// IsReady reports whether the media can be shown publicly
+func (m Media) IsReady() bool {
+	return m.ProcessingStatus == "" || m.ProcessingStatus == MediaProcessingReady
+}
+
+// MediaVariant is a processed rendition of an image (responsive width × format)
+type MediaVariant struct {
+	ID        int64  `json:"id" db:"id"`
+	MediaID   int64  `json:"media_id" db:"media_id"`
+	Format    string `json:"format" db:"format"`
+	Width     int    `json:"width" db:"width"`
+	Height    int    `json:"height" db:"height"`
+	Path      string `json:"path" db:"path"`
+	SizeBytes int64  `json:"size_bytes" db:"size_bytes"`
+	// URL is a signed download URL, filled when media are returned to clients
+	URL *string `json:"url,omitempty"`
 }
 
 // IsArchived returns true if the media is archived
@@ -241,6 +305,8 @@ type ProductWithDetails struct {
 	Pigeon       *Pigeon       `json:"pigeon,omitempty"`
 	Supply       *Supply       `json:"supply,omitempty"`
 	Media        []Media       `json:"media"`
+	// Pedigree of a pigeon (bird, parents, grandparents) when one is recorded
+	Pedigree []pedigree.Entry `json:"pedigree,omitempty"`
 }
 
 // GetPriceGross calculates the gross price including VAT
@@ -0,0 +1,238 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"encore.dev"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/pedigree"
+	"encore.app/pkg/storagegcs"
+)
+
+// ========================= Pedigree =========================
+// Structured pedigrees (pkg/pedigree): parent links by ring number, race results and a
+// printable card. pigeons.lineage stays as free-text notes next to it.
+
+// productPedigree returns the pedigree shown on a pigeon's product page, or nil when none is
+// recorded. Failures are not fatal for the product page.
+func (s *Service) productPedigree(ctx context.Context, productID int64) []pedigree.Entry {
+	root, err := pedigree.LoadForProduct(ctx, s.repo.db.Stdlib(), productID, pedigree.DefaultGenerations)
+	if err != nil || (root.Depth() < 2 && len(root.Results) == 0) {
+		return nil
+	}
+	return root.Entries()
+}
+
+// pedigreeError maps pkg/pedigree errors to API errors
+func pedigreeError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, pedigree.ErrNotFound):
+		return errs.New(errs.NotFound, "لا توجد شجرة نسب لهذا الطير")
+	case errors.Is(err, pedigree.ErrInvalidRing):
+		return errs.New(errs.InvalidArgument, "رقم الحلقة غير صالح")
+	case errors.Is(err, pedigree.ErrCycle):
+		return errs.New(errs.InvalidArgument, "لا يمكن أن يكون الأب أو الأم من نسل الطير نفسه")
+	case errors.Is(err, pedigree.ErrParentSex):
+		return errs.New(errs.InvalidArgument, "جنس الأب أو الأم لا يطابق دوره في النسب")
+	case errors.Is(err, pedigree.ErrSameParents):
+		return errs.New(errs.InvalidArgument, "لا يمكن أن يكون الأب والأم نفس الطير")
+	}
+	return errs.E(ctx, "CAT_PEDIGREE_FAILED", "فشل معالجة شجرة النسب")
+}
+
+// GetProductPedigree returns a pigeon's pedigree with up to 5 generations (public)
+//
+//encore:api public method=GET path=/products/:id/pedigree
+func GetProductPedigree(ctx context.Context, id string, req *PedigreeRequest) (*PedigreeResponse, error) {
+	if catalogService == nil {
+		if err := InitService(); err != nil {
+			return nil, errs.E(ctx, "CAT_INIT_FAILED", "فشل تهيئة خدمة الكتالوج")
+		}
+	}
+	productID, err := parseProductID(id)
+	if err != nil {
+		return nil, errs.E(ctx, "CAT_INVALID_PRODUCT_ID", "معرّف المنتج غير صالح")
+	}
+	gens := 0
+	if req != nil {
+		gens = req.Generations
+	}
+	gens = pedigree.ClampGenerations(gens)
+
+	root, err := pedigree.LoadForProduct(ctx, db.Stdlib(), productID, gens)
+	if err != nil {
+		return nil, pedigreeError(ctx, err)
+	}
+	return &PedigreeResponse{
+		ProductID:   productID,
+		Generations: gens,
+		Birds:       root.Entries(),
+		CardURL:     fmt.Sprintf("/products/%d/pedigree/card", productID),
+	}, nil
+}
+
+// GetProductPedigreeCard renders a printable PNG pedigree card (public)
+//
+//encore:api public raw method=GET path=/products/:id/pedigree/card
+func GetProductPedigreeCard(w http.ResponseWriter, req *http.Request) {
+	setCORSHeaders(w, req)
+	ctx := req.Context()
+
+	productID, err := parseProductID(encore.CurrentRequest().PathParams.Get("id"))
+	if err != nil {
+		writeErrorResponse(w, errs.E(ctx, "CAT_INVALID_PRODUCT_ID", "معرّف المنتج غير صالح"))
+		return
+	}
+	root, err := pedigree.LoadForProduct(ctx, db.Stdlib(), productID, pedigree.MaxGenerations)
+	if err != nil {
+		writeErrorResponse(w, pedigreeError(ctx, err))
+		return
+	}
+	title := "شجرة النسب"
+	if root.Name != "" {
+		title += " - " + root.Name
+	}
+	out, err := storagegcs.RenderPedigreeCard(root, title)
+	if err != nil {
+		writeErrorResponse(w, errs.E(ctx, "CAT_PEDIGREE_CARD_FAILED", "فشل إنشاء بطاقة النسب"))
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="pedigree-%d.png"`, productID))
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_, _ = w.Write(out)
+}
+
+// AdminUpsertPedigreeBird creates or updates a bird and its sire/dam links (Admin only)
+//
+//encore:api auth method=PUT path=/catalog/admin/pedigree/birds
+func AdminUpsertPedigreeBird(ctx context.Context, req *pedigree.BirdInput) (*PedigreeResponse, error) {
+	if catalogService == nil {
+		if err := InitService(); err != nil {
+			return nil, errs.E(ctx, "CAT_INIT_FAILED", "فشل تهيئة خدمة الكتالوج")
+		}
+	}
+	if err := checkAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, errs.New(errs.InvalidArgument, "الطلب فارغ")
+	}
+	if err := req.Validate(); err != nil {
+		if errors.Is(err, pedigree.ErrInvalidRing) || errors.Is(err, pedigree.ErrSameParents) {
+			return nil, pedigreeError(ctx, err)
+		}
+		return nil, errs.New(errs.InvalidArgument, "بيانات الطير غير صالحة (الجنس أو سنة الميلاد)")
+	}
+
+	tx, err := db.Stdlib().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errs.E(ctx, "CAT_TX_BEGIN_FAILED", "فشل بدء المعاملة")
+	}
+	defer tx.Rollback()
+	id, err := pedigree.Upsert(ctx, tx, *req)
+	if err != nil {
+		return nil, pedigreeError(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errs.E(ctx, "CAT_TX_COMMIT_FAILED", "فشل تأكيد المعاملة")
+	}
+
+	root, err := pedigree.Load(ctx, db.Stdlib(), id, pedigree.DefaultGenerations)
+	if err != nil {
+		return nil, pedigreeError(ctx, err)
+	}
+	return pedigreeResponse(root, pedigree.DefaultGenerations), nil
+}
+
+// AdminGetPedigreeBird looks up a bird's pedigree by ring number, internal or external (Admin only)
+//
+//encore:api auth method=GET path=/catalog/admin/pedigree/birds
+func AdminGetPedigreeBird(ctx context.Context, req *AdminPedigreeLookupRequest) (*PedigreeResponse, error) {
+	if catalogService == nil {
+		if err := InitService(); err != nil {
+			return nil, errs.E(ctx, "CAT_INIT_FAILED", "فشل تهيئة خدمة الكتالوج")
+		}
+	}
+	if err := checkAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, errs.New(errs.InvalidArgument, "رقم الحلقة مطلوب")
+	}
+	gens := pedigree.ClampGenerations(req.Generations)
+	root, err := pedigree.LoadByRing(ctx, db.Stdlib(), req.Ring, gens)
+	if err != nil {
+		return nil, pedigreeError(ctx, err)
+	}
+	return pedigreeResponse(root, gens), nil
+}
+
+// AdminAddRaceResult records a race result for a bird (Admin only)
+//
+//encore:api auth method=POST path=/catalog/admin/pedigree/results
+func AdminAddRaceResult(ctx context.Context, req *AddRaceResultRequest) (*RaceResultResponse, error) {
+	if catalogService == nil {
+		if err := InitService(); err != nil {
+			return nil, errs.E(ctx, "CAT_INIT_FAILED", "فشل تهيئة خدمة الكتالوج")
+		}
+	}
+	if err := checkAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, errs.New(errs.InvalidArgument, "الطلب فارغ")
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	r, err := pedigree.AddResult(ctx, db.Stdlib(), req.RingNumber, pedigree.RaceResult{
+		RaceName:   req.RaceName,
+		RaceDate:   req.RaceDate,
+		DistanceKM: req.DistanceKM,
+		Position:   req.Position,
+		TotalBirds: req.TotalBirds,
+		SpeedMPM:   req.SpeedMPM,
+		Notes:      req.Notes,
+	})
+	if err != nil {
+		return nil, pedigreeError(ctx, err)
+	}
+	return &RaceResultResponse{Result: *r}, nil
+}
+
+// AdminDeleteRaceResult removes a race result (Admin only)
+//
+//encore:api auth method=DELETE path=/catalog/admin/pedigree/results/:id
+func AdminDeleteRaceResult(ctx context.Context, id int64) (*MessageResponse, error) {
+	if catalogService == nil {
+		if err := InitService(); err != nil {
+			return nil, errs.E(ctx, "CAT_INIT_FAILED", "فشل تهيئة خدمة الكتالوج")
+		}
+	}
+	if err := checkAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if err := pedigree.DeleteResult(ctx, db.Stdlib(), id); err != nil {
+		if errors.Is(err, pedigree.ErrNotFound) {
+			return nil, errs.New(errs.NotFound, "نتيجة السباق غير موجودة")
+		}
+		return nil, pedigreeError(ctx, err)
+	}
+	return &MessageResponse{Message: "تم حذف نتيجة السباق"}, nil
+}
+
+func pedigreeResponse(root *pedigree.Node, gens int) *PedigreeResponse {
+	resp := &PedigreeResponse{Generations: gens, Birds: root.Entries()}
+	if root.ProductID != nil {
+		resp.ProductID = *root.ProductID
+		resp.CardURL = fmt.Sprintf("/products/%d/pedigree/card", *root.ProductID)
+	}
+	return resp
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
 
 	"encore.dev/storage/sqldb"
+
+	"encore.app/pkg/video"
 )
 
 // Repository handles database operations for catalog
@@ -239,69 +244,124 @@ func NewRepository(db *sqldb.Database) *Repository {
 
 // ProductsFilter represents filters for product queries
 type ProductsFilter struct {
-	Type     *ProductType
-	Status   *ProductStatus
-	Search   *string
-	PriceMin *float64
-	PriceMax *float64
+	Type      *ProductType
+	Status    *ProductStatus
+	Search    *string
+	PriceMin  *float64
+	PriceMax  *float64
+	Sex       *PigeonSex
+	BirthYear *int
 }
 
 // ProductsSort represents sorting options for products
 type ProductsSort struct {
-	Field     string // "created_at", "price_net", "title"
+	Field     string // "created_at", "price_net", "title", "relevance" (needs a search)
 	Direction string // "ASC", "DESC"
 }
 
-// GetProducts retrieves products with optional filters, sorting, and pagination
-func (r *Repository) GetProducts(ctx context.Context, filter ProductsFilter, sort ProductsSort, offset, limit int) ([]Product, int64, error) {
-	// Build WHERE clause
-	whereClauses := []string{}
-	args := []interface{}{}
-	argCount := 0
+// searchTSQuery turns free text into a prefix tsquery for the 'simple' config, e.g.
+// "حمام زاجل 2023" → "حمام:* & زاجل:* & 2023:*". Punctuation splits words (ring numbers are
+// indexed as their parts too) and cannot inject tsquery operators. Harakat and tatweel are
+// dropped as normalize_ar does, so a word made only of them is no word. Returns "" for no words.
+func searchTSQuery(q string) string {
+	var words []string
+	for _, w := range strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	}) {
+		w = strings.Map(func(r rune) rune {
+			if (r >= 0x064B && r <= 0x065F) || r == 0x0670 || r == 0x0640 {
+				return -1
+			}
+			return r
+		}, w)
+		if w != "" {
+			words = append(words, w+":*")
+		}
+		if len(words) == 8 {
+			break
+		}
+	}
+	return strings.Join(words, " & ")
+}
 
-	if filter.Type != nil {
-		argCount++
-		whereClauses = append(whereClauses, fmt.Sprintf("p.type = $%d", argCount))
-		args = append(args, *filter.Type)
+// productCond is one condition of a products query. dim names the facet it filters ("" for
+// the search, which narrows every facet).
+type productCond struct {
+	dim string
+	sql string
+}
+
+// facetDims are the filter dimensions reported in ProductFacets
+var facetDims = []string{"type", "status", "sex", "birth_year", "price"}
+
+// productsConds builds the conditions of filter on products p LEFT JOIN pigeons pg. searchArg
+// is the position of the tsquery argument, 0 without search.
+func productsConds(filter ProductsFilter) (conds []productCond, args []interface{}, searchArg int) {
+	add := func(dim, clause string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, productCond{dim: dim, sql: fmt.Sprintf(clause, len(args))})
 	}
 
+	if filter.Type != nil {
+		add("type", "p.type = $%d", *filter.Type)
+	}
 	if filter.Status != nil {
-		argCount++
-		whereClauses = append(whereClauses, fmt.Sprintf("p.status = $%d", argCount))
-		args = append(args, *filter.Status)
+		add("status", "p.status = $%d", *filter.Status)
 	}
-
-	if filter.Search != nil && *filter.Search != "" {
-		argCount++
-		whereClauses = append(whereClauses, fmt.Sprintf("(p.title ILIKE $%d OR p.description ILIKE $%d)", argCount, argCount))
-		args = append(args, "%"+*filter.Search+"%")
+	if filter.Search != nil {
+		if tsq := searchTSQuery(*filter.Search); tsq != "" {
+			add("", "p.search_vector @@ to_tsquery('simple', normalize_ar($%d))", tsq)
+			searchArg = len(args)
+		}
 	}
-
 	if filter.PriceMin != nil {
-		argCount++
-		whereClauses = append(whereClauses, fmt.Sprintf("p.price_net >= $%d", argCount))
-		args = append(args, *filter.PriceMin)
+		add("price", "p.price_net >= $%d", *filter.PriceMin)
 	}
-
 	if filter.PriceMax != nil {
-		argCount++
-		whereClauses = append(whereClauses, fmt.Sprintf("p.price_net <= $%d", argCount))
-		args = append(args, *filter.PriceMax)
+		add("price", "p.price_net <= $%d", *filter.PriceMax)
+	}
+	if filter.Sex != nil {
+		add("sex", "pg.sex = $%d", *filter.Sex)
+	}
+	if filter.BirthYear != nil {
+		add("birth_year", "EXTRACT(YEAR FROM pg.birth_date) = $%d", *filter.BirthYear)
 	}
+	return conds, args, searchArg
+}
 
-	// Build WHERE clause string
-	whereClause := ""
+// productsWhere builds the WHERE clause shared by the list and count queries (products p LEFT
+// JOIN pigeons pg). searchArg is the position of the tsquery argument, 0 without search.
+func productsWhere(filter ProductsFilter) (where string, args []interface{}, searchArg int) {
+	conds, args, searchArg := productsConds(filter)
+	whereClauses := make([]string, len(conds))
+	for i, c := range conds {
+		whereClauses[i] = c.sql
+	}
 	if len(whereClauses) > 0 {
-		whereClause = "WHERE " + strings.Join(whereClauses, " AND ")
+		where = "WHERE " + strings.Join(whereClauses, " AND ")
 	}
+	return where, args, searchArg
+}
+
+// GetProducts retrieves products with optional filters, sorting, and pagination
+func (r *Repository) GetProducts(ctx context.Context, filter ProductsFilter, sort ProductsSort, offset, limit int) ([]Product, int64, error) {
+	whereClause, args, searchArg := productsWhere(filter)
+	argCount := len(args)
 
-	// Build ORDER BY clause
+	// Build ORDER BY clause; relevance falls back to newest without a search
 	orderBy := fmt.Sprintf("ORDER BY p.%s %s", sort.Field, sort.Direction)
+	if sort.Field == "relevance" {
+		orderBy = "ORDER BY p.created_at DESC"
+		if searchArg > 0 {
+			orderBy = fmt.Sprintf("ORDER BY ts_rank_cd(p.search_vector, to_tsquery('simple', normalize_ar($%d))) DESC, p.created_at DESC", searchArg)
+		}
+	}
 
 	// Count total items
 	countQuery := fmt.Sprintf(`
 		SELECT COUNT(*)
 		FROM products p
+		LEFT JOIN pigeons pg ON pg.product_id = p.id
 		%s
 	`, whereClause)
 
@@ -326,9 +386,11 @@ func (r *Repository) GetProducts(ctx context.Context, filter ProductsFilter, sor
 			 WHERE m.product_id = p.id
 			   AND m.kind = 'image'
 			   AND m.archived_at IS NULL
+			   AND m.processing_status = 'ready'
 			 ORDER BY m.created_at ASC
 			 LIMIT 1) as thumbnail_url
 		FROM products p
+		LEFT JOIN pigeons pg ON pg.product_id = p.id
 		%s
 		%s
 		LIMIT $%d OFFSET $%d
@@ -361,6 +423,147 @@ func (r *Repository) GetProducts(ctx context.Context, filter ProductsFilter, sor
 	return products, totalCount, nil
 }
 
+// priceBuckets are the price_net ranges reported in the price facet; Max 0 means open-ended
+var priceBuckets = []PriceBucketCount{
+	{Label: "0-500", Min: 0, Max: 500},
+	{Label: "500-1000", Min: 500, Max: 1000},
+	{Label: "1000-2500", Min: 1000, Max: 2500},
+	{Label: "2500-5000", Min: 2500, Max: 5000},
+	{Label: "5000+", Min: 5000},
+}
+
+// priceBucketCase renders a CASE expression mapping p.price_net to its bucket index
+func priceBucketCase() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i, pb := range priceBuckets {
+		if pb.Max > 0 {
+			fmt.Fprintf(&b, " WHEN price_net < %g THEN '%d'", pb.Max, i)
+		} else {
+			fmt.Fprintf(&b, " ELSE '%d'", i)
+		}
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+// productFacetsQuery builds the facet counts query. Each facet counts the products matching
+// every filter but its own, so picking a value still shows how many the other values have.
+func productFacetsQuery(filter ProductsFilter) (string, []interface{}) {
+	conds, args, _ := productsConds(filter)
+	var where []string
+	byDim := map[string][]string{}
+	for _, c := range conds {
+		if c.dim == "" {
+			where = append(where, c.sql)
+		} else {
+			byDim[c.dim] = append(byDim[c.dim], c.sql)
+		}
+	}
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+	// m_<dim> tells whether the row passes the filter of that dimension
+	columns := []string{"p.type::text AS type", "p.status::text AS status", "pg.sex::text AS sex",
+		"EXTRACT(YEAR FROM pg.birth_date)::int AS birth_year", "p.price_net"}
+	for _, dim := range facetDims {
+		if cs := byDim[dim]; len(cs) > 0 {
+			columns = append(columns, fmt.Sprintf("COALESCE(%s, FALSE) AS m_%s", strings.Join(cs, " AND "), dim))
+		}
+	}
+	others := func(dim string) string {
+		var ms []string
+		for _, d := range facetDims {
+			if d != dim && len(byDim[d]) > 0 {
+				ms = append(ms, "m_"+d)
+			}
+		}
+		if len(ms) == 0 {
+			return "TRUE"
+		}
+		return strings.Join(ms, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+		WITH base AS (
+			SELECT %s
+			FROM products p
+			LEFT JOIN pigeons pg ON pg.product_id = p.id
+			%s
+		)
+		SELECT 'type', type, COUNT(*) FROM base WHERE %s GROUP BY type
+		UNION ALL SELECT 'status', status, COUNT(*) FROM base WHERE %s GROUP BY status
+		UNION ALL SELECT 'sex', sex, COUNT(*) FROM base WHERE sex IS NOT NULL AND %s GROUP BY sex
+		UNION ALL SELECT 'birth_year', birth_year::text, COUNT(*) FROM base WHERE birth_year IS NOT NULL AND %s GROUP BY birth_year
+		UNION ALL SELECT 'price', %s, COUNT(*) FROM base WHERE %s GROUP BY 2
+	`, strings.Join(columns, ", "), whereClause,
+		others("type"), others("status"), others("sex"), others("birth_year"), priceBucketCase(), others("price"))
+	return query, args
+}
+
+// GetProductFacets counts the products matching filter by type, status, pigeon sex, birth
+// year and price bucket, leaving each facet's own filter out of its counts
+func (r *Repository) GetProductFacets(ctx context.Context, filter ProductsFilter) (*ProductFacets, error) {
+	query, args := productFacetsQuery(filter)
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product facets: %w", err)
+	}
+	defer rows.Close()
+
+	facets := &ProductFacets{
+		Type:      []FacetCount{},
+		Status:    []FacetCount{},
+		Sex:       []FacetCount{},
+		BirthYear: []FacetCount{},
+		Price:     make([]PriceBucketCount, len(priceBuckets)),
+	}
+	copy(facets.Price, priceBuckets)
+	for rows.Next() {
+		var facet, value string
+		var count int64
+		if err := rows.Scan(&facet, &value, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan product facet: %w", err)
+		}
+		fc := FacetCount{Value: value, Count: count}
+		switch facet {
+		case "type":
+			facets.Type = append(facets.Type, fc)
+		case "status":
+			facets.Status = append(facets.Status, fc)
+		case "sex":
+			facets.Sex = append(facets.Sex, fc)
+		case "birth_year":
+			facets.BirthYear = append(facets.BirthYear, fc)
+		case "price":
+			var i int
+			if _, err := fmt.Sscan(value, &i); err == nil && i >= 0 && i < len(facets.Price) {
+				facets.Price[i].Count = count
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating product facets: %w", err)
+	}
+
+	byCount := func(fs []FacetCount) {
+		sort.SliceStable(fs, func(i, j int) bool {
+			if fs[i].Count != fs[j].Count {
+				return fs[i].Count > fs[j].Count
+			}
+			return fs[i].Value < fs[j].Value
+		})
+	}
+	byCount(facets.Type)
+	byCount(facets.Status)
+	byCount(facets.Sex)
+	// Newest birth years first
+	sort.Slice(facets.BirthYear, func(i, j int) bool { return facets.BirthYear[i].Value > facets.BirthYear[j].Value })
+
+	return facets, nil
+}
+
 // GetProductByID retrieves a single product by ID
 func (r *Repository) GetProductByID(ctx context.Context, id int64) (*Product, error) {
 	query := `
@@ -593,7 +796,9 @@ func (r *Repository) GetMediaByProductID(ctx context.Context, productID int64, i
 	query := `
 		SELECT
 			id, product_id, kind, gcs_path, thumb_path, watermark_applied,
-			file_size, mime_type, original_filename, description, archived_at, created_at, updated_at
+			file_size, mime_type, original_filename, description, archived_at, created_at, updated_at,
+			processing_status::text, processing_error, processed_at, original_path,
+			duration_ms, width, height, video_codec, audio_codec
 		FROM media
 		WHERE product_id = $1
 	`
@@ -619,6 +824,8 @@ func (r *Repository) GetMediaByProductID(ctx context.Context, productID int64, i
 			&m.ID, &m.ProductID, &m.Kind, &m.GCSPath, &m.ThumbPath, &m.WatermarkApplied,
 			&m.FileSize, &m.MimeType, &m.OriginalFilename, &m.Description, &m.ArchivedAt,
 			&m.CreatedAt, &m.UpdatedAt,
+			&m.ProcessingStatus, &m.ProcessingError, &m.ProcessedAt, &m.OriginalPath,
+			&m.DurationMS, &m.Width, &m.Height, &m.VideoCodec, &m.AudioCodec,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan media: %w", err)
@@ -630,6 +837,10 @@ func (r *Repository) GetMediaByProductID(ctx context.Context, productID int64, i
 		return nil, fmt.Errorf("error iterating media: %w", err)
 	}
 
+	if err := r.attachMediaVariants(ctx, mediaList); err != nil {
+		return nil, err
+	}
+
 	return mediaList, nil
 }
 
@@ -638,16 +849,18 @@ func (r *Repository) CreateMedia(ctx context.Context, media *Media) error {
 	query := `
 		INSERT INTO media (
 			product_id, kind, gcs_path, thumb_path, watermark_applied,
-			file_size, mime_type, original_filename, description
+			file_size, mime_type, original_filename, description,
+			processing_status, original_path
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id, created_at, updated_at
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10::media_processing_status, $11)
+		RETURNING id, created_at, updated_at, processing_status::text
 	`
 
 	err := r.db.QueryRow(ctx, query,
 		media.ProductID, media.Kind, media.GCSPath, media.ThumbPath, media.WatermarkApplied,
 		media.FileSize, media.MimeType, media.OriginalFilename, media.Description,
-	).Scan(&media.ID, &media.CreatedAt, &media.UpdatedAt)
+		media.ProcessingStatus, media.OriginalPath,
+	).Scan(&media.ID, &media.CreatedAt, &media.UpdatedAt, &media.ProcessingStatus)
 
 	if err != nil {
 		return fmt.Errorf("failed to create media: %w", err)
@@ -690,7 +903,9 @@ func (r *Repository) GetMediaByID(ctx context.Context, mediaID int64) (*Media, e
 	query := `
 		SELECT
 			id, product_id, kind, gcs_path, thumb_path, watermark_applied,
-			file_size, mime_type, original_filename, description, archived_at, created_at, updated_at
+			file_size, mime_type, original_filename, description, archived_at, created_at, updated_at,
+			processing_status::text, processing_error, processed_at, original_path,
+			duration_ms, width, height, video_codec, audio_codec
 		FROM media
 		WHERE id = $1
 	`
@@ -700,6 +915,8 @@ func (r *Repository) GetMediaByID(ctx context.Context, mediaID int64) (*Media, e
 		&m.ID, &m.ProductID, &m.Kind, &m.GCSPath, &m.ThumbPath, &m.WatermarkApplied,
 		&m.FileSize, &m.MimeType, &m.OriginalFilename, &m.Description, &m.ArchivedAt,
 		&m.CreatedAt, &m.UpdatedAt,
+		&m.ProcessingStatus, &m.ProcessingError, &m.ProcessedAt, &m.OriginalPath,
+		&m.DurationMS, &m.Width, &m.Height, &m.VideoCodec, &m.AudioCodec,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -801,3 +1018,265 @@ func (r *Repository) UpdateSupply(ctx context.Context, productID int64, updates
 	_, err := r.db.Exec(ctx, query, args...)
 	return err
 }
+
+// ========================= Media Processing Repository =========================
+
+// attachMediaVariants loads the processed variants of the given media
+func (r *Repository) attachMediaVariants(ctx context.Context, mediaList []Media) error {
+	if len(mediaList) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(mediaList))
+	index := make(map[int64]int, len(mediaList))
+	for i, m := range mediaList {
+		ids[i] = m.ID
+		index[m.ID] = i
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, media_id, format, width, height, path, size_bytes
+		FROM media_variants
+		WHERE media_id = ANY($1)
+		ORDER BY media_id, width, format
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to query media variants: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v MediaVariant
+		if err := rows.Scan(&v.ID, &v.MediaID, &v.Format, &v.Width, &v.Height, &v.Path, &v.SizeBytes); err != nil {
+			return fmt.Errorf("failed to scan media variant: %w", err)
+		}
+		if i, ok := index[v.MediaID]; ok {
+			mediaList[i].Variants = append(mediaList[i].Variants, v)
+		}
+	}
+	return rows.Err()
+}
+
+// mediaJob is an image or video claimed by the media worker
+type mediaJob struct {
+	ID                int64
+	Kind              MediaKind
+	Source            string // original_path, or gcs_path for rows created before the pipeline
+	SourceWatermarked bool
+}
+
+// ClaimMediaForProcessing marks an image or video as processing and returns what the worker
+// needs. Returns nil when the media no longer exists, is archived or is a plain file.
+func (r *Repository) ClaimMediaForProcessing(ctx context.Context, mediaID int64) (*mediaJob, error) {
+	var job mediaJob
+	err := r.db.QueryRow(ctx, `
+		UPDATE media
+		SET processing_status = 'processing',
+		    processing_attempts = processing_attempts + 1,
+		    updated_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		WHERE id = $1 AND kind IN ('image', 'video') AND archived_at IS NULL
+		  AND COALESCE(original_path, gcs_path) NOT LIKE 'http%'
+		RETURNING id, kind, COALESCE(original_path, gcs_path), original_watermarked
+	`, mediaID).Scan(&job.ID, &job.Kind, &job.Source, &job.SourceWatermarked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // gone, archived, external link or plain file
+		}
+		return nil, fmt.Errorf("failed to claim media: %w", err)
+	}
+	return &job, nil
+}
+
+// processedMedia is what the worker publishes for a media row
+type processedMedia struct {
+	MainPath    string // gcs_path: largest JPEG variant, or the web-safe video
+	ThumbPath   string // smallest JPEG variant (poster for videos); "" for none
+	Watermarked bool
+	Signature   string
+	Variants    []MediaVariant
+	Video       *video.Info
+}
+
+// CompleteMediaProcessing replaces the variants of a media row and marks it ready. It returns
+// the paths of the objects it superseded (old variants, previous transcode) for deletion.
+func (r *Repository) CompleteMediaProcessing(ctx context.Context, mediaID int64, res processedMedia) ([]string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var old []string
+	var previous string
+	if err := tx.QueryRow(ctx, `SELECT gcs_path FROM media WHERE id = $1 FOR UPDATE`, mediaID).Scan(&previous); err != nil {
+		return nil, fmt.Errorf("failed to lock media: %w", err)
+	}
+	if previous != res.MainPath && strings.HasPrefix(previous, mediaVideosPrefix) {
+		old = append(old, previous)
+	}
+
+	rows, err := tx.Query(ctx, `DELETE FROM media_variants WHERE media_id = $1 RETURNING path`, mediaID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete old variants: %w", err)
+	}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan old variant: %w", err)
+		}
+		old = append(old, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, v := range res.Variants {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO media_variants (media_id, format, width, height, path, size_bytes)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, mediaID, v.Format, v.Width, v.Height, v.Path, v.SizeBytes); err != nil {
+			return nil, fmt.Errorf("failed to insert variant: %w", err)
+		}
+	}
+
+	var durationMS, width, height *int
+	var videoCodec, audioCodec *string
+	if v := res.Video; v != nil {
+		ms, w, h := int(v.Duration.Milliseconds()), v.Width, v.Height
+		durationMS, width, height = &ms, &w, &h
+		videoCodec = &v.VideoCodec
+		if v.AudioCodec != "" {
+			audioCodec = &v.AudioCodec
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE media
+		SET gcs_path = $2, thumb_path = NULLIF($3, ''), watermark_applied = $4, variants_signature = $5,
+		    duration_ms = $6, width = $7, height = $8, video_codec = $9, audio_codec = $10,
+		    processing_status = 'ready', processing_error = NULL,
+		    processed_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'),
+		    updated_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		WHERE id = $1
+	`, mediaID, res.MainPath, res.ThumbPath, res.Watermarked, res.Signature,
+		durationMS, width, height, videoCodec, audioCodec); err != nil {
+		return nil, fmt.Errorf("failed to update media: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit variants: %w", err)
+	}
+	return old, nil
+}
+
+// FailMediaProcessing records a processing error
+func (r *Repository) FailMediaProcessing(ctx context.Context, mediaID int64, message string) error {
+	if len(message) > 1000 {
+		message = message[:1000]
+	}
+	_, err := r.db.Exec(ctx, `
+		UPDATE media
+		SET processing_status = 'failed', processing_error = $2,
+		    updated_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		WHERE id = $1
+	`, mediaID, message)
+	return err
+}
+
+// PendingMediaIDs returns the images of a product waiting for the worker
+func (r *Repository) PendingMediaIDs(ctx context.Context, productID int64) ([]int64, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id FROM media
+		WHERE product_id = $1 AND processing_status = 'pending' AND archived_at IS NULL
+		ORDER BY id
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending media: %w", err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkMediaForReprocessing resets matching images and videos to pending and returns their IDs. Media
+// currently being processed are left alone unless they have been processing for longer than
+// timeout. With staleOnly, only media built with another settings signature (or never built,
+// failed or abandoned) are selected.
+func (r *Repository) MarkMediaForReprocessing(ctx context.Context, productID *int64, mediaIDs []int64, staleOnly bool, signature string, timeout time.Duration) ([]int64, error) {
+	query := `
+		UPDATE media
+		SET processing_status = 'pending', processing_error = NULL,
+		    updated_at = (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+		WHERE kind IN ('image', 'video') AND archived_at IS NULL
+		  AND (processing_status <> 'processing'
+		       OR updated_at < (CURRENT_TIMESTAMP AT TIME ZONE 'UTC') - make_interval(secs => $1))
+		  AND COALESCE(original_path, gcs_path) NOT LIKE 'http%'
+	`
+	args := []interface{}{timeout.Seconds()}
+	if productID != nil {
+		args = append(args, *productID)
+		query += fmt.Sprintf(" AND product_id = $%d", len(args))
+	}
+	if len(mediaIDs) > 0 {
+		args = append(args, mediaIDs)
+		query += fmt.Sprintf(" AND id = ANY($%d)", len(args))
+	}
+	if staleOnly {
+		args = append(args, signature)
+		query += fmt.Sprintf(" AND (variants_signature IS DISTINCT FROM $%d OR processing_status IN ('failed', 'processing'))", len(args))
+	}
+	query += " RETURNING id"
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark media for reprocessing: %w", err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MediaProcessingCounts returns the number of images and videos per processing status, and how many
+// were built with a signature other than the given one
+func (r *Repository) MediaProcessingCounts(ctx context.Context, signature string) (map[string]int, int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT processing_status::text, COUNT(*),
+		       COUNT(*) FILTER (WHERE variants_signature IS DISTINCT FROM $1)
+		FROM media
+		WHERE kind IN ('image', 'video') AND archived_at IS NULL AND gcs_path NOT LIKE 'http%'
+		GROUP BY processing_status
+	`, signature)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count media: %w", err)
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	stale := 0
+	for rows.Next() {
+		var status string
+		var n, s int
+		if err := rows.Scan(&status, &n, &s); err != nil {
+			return nil, 0, err
+		}
+		counts[status] = n
+		if status == string(MediaProcessingReady) {
+			stale = s
+		}
+	}
+	return counts, stale, rows.Err()
+}
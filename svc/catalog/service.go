@@ -21,7 +21,7 @@ import (
 type Service struct {
 	repo      *Repository
 	slugifier *slugify.Slugifier
-	storage   *storagegcs.Client
+	storage   storagegcs.Storage
 	config    *config.ConfigManager
 }
 
@@ -126,7 +126,7 @@ func (s *Service) CreateExternalMedia(
 func NewService(
 	db *sqldb.Database,
 	slugifier *slugify.Slugifier,
-	storage *storagegcs.Client,
+	storage storagegcs.Storage,
 	configMgr *config.ConfigManager,
 ) *Service {
 	return &Service{
@@ -166,11 +166,13 @@ func (s *Service) GetProducts(ctx context.Context, req ProductsListRequest) (*Pr
 
 	// Convert request to filter using helper methods
 	filter := ProductsFilter{
-		Type:     req.GetType(),
-		Status:   req.GetStatus(),
-		Search:   req.GetQ(),
-		PriceMin: req.GetPriceMin(),
-		PriceMax: req.GetPriceMax(),
+		Type:      req.GetType(),
+		Status:    req.GetStatus(),
+		Search:    req.GetQ(),
+		PriceMin:  req.GetPriceMin(),
+		PriceMax:  req.GetPriceMax(),
+		Sex:       req.GetSex(),
+		BirthYear: req.GetBirthYear(),
 	}
 
 	// Convert sort parameter
@@ -179,8 +181,15 @@ func (s *Service) GetProducts(ctx context.Context, req ProductsListRequest) (*Pr
 		Direction: "DESC",
 	}
 
+	// Searches rank by relevance unless another order is asked for
+	if req.GetQ() != nil && req.GetSort() == nil {
+		sort.Field = "relevance"
+	}
+
 	if sortPtr := req.GetSort(); sortPtr != nil {
 		switch *sortPtr {
+		case "relevance":
+			sort.Field = "relevance"
 		case "newest":
 			sort.Field = "created_at"
 			sort.Direction = "DESC"
@@ -204,6 +213,13 @@ func (s *Service) GetProducts(ctx context.Context, req ProductsListRequest) (*Pr
 	if err != nil {
 		return nil, errs.E(ctx, "CAT_PRODUCTS_READ_FAILED", "فشل جلب قائمة المنتجات")
 	}
+	var facets *ProductFacets
+	if req.Facets {
+		facets, err = s.repo.GetProductFacets(ctx, filter)
+		if err != nil {
+			return nil, errs.E(ctx, "CAT_PRODUCTS_READ_FAILED", "فشل جلب قائمة المنتجات")
+		}
+	}
 
 	// Get current VAT settings from config manager
 	var vatSettings *VATSettings
@@ -238,6 +254,7 @@ func (s *Service) GetProducts(ctx context.Context, req ProductsListRequest) (*Pr
 	return &ProductsListResponse{
 		Products:   summaries,
 		Pagination: pagination,
+		Facets:     facets,
 	}, nil
 }
 
@@ -385,6 +402,7 @@ func (s *Service) CreateProduct(ctx context.Context, req CreateProductRequest) (
 					GCSPath:          gcsPath, // GCS path from UploadMediaDraft
 					WatermarkApplied: false,
 				}
+				queueForProcessing(media)
 				if err := s.repo.CreateMedia(ctx, media); err != nil {
 					return err
 				}
@@ -435,6 +453,7 @@ func (s *Service) CreateProduct(ctx context.Context, req CreateProductRequest) (
 	if createErr != nil {
 		return nil, errs.E(ctx, "CAT_PRODUCT_CREATE_FAILED", "فشل إنشاء المنتج: "+createErr.Error())
 	}
+	s.enqueuePendingMedia(ctx, product.ID)
 
 	// Get full product with details (outside transaction)
 	productWithDetails, err := s.getProductWithDetails(ctx, product)
@@ -592,6 +611,7 @@ func (s *Service) UpdateProduct(ctx context.Context, id string, req UpdateProduc
 					GCSPath:          gcsPath, // GCS path from UploadMediaDraft
 					WatermarkApplied: false,
 				}
+				queueForProcessing(media)
 				if err := s.repo.CreateMedia(ctx, media); err != nil {
 					return err
 				}
@@ -638,6 +658,7 @@ func (s *Service) UpdateProduct(ctx context.Context, id string, req UpdateProduc
 	if updateErr != nil {
 		return nil, errs.E(ctx, "CAT_PRODUCT_UPDATE_FAILED", "فشل تحديث المنتج: "+updateErr.Error())
 	}
+	s.enqueuePendingMedia(ctx, productID)
 
 	// Get updated product with details (outside transaction)
 	updatedProduct, err = s.repo.GetProductByID(ctx, productID)
@@ -710,30 +731,8 @@ func (s *Service) UploadMedia(ctx context.Context, productID int64, file multipa
 		return nil, err
 	}
 
-	// Placeholder media settings
-	mediaSettings := struct {
-		ThumbnailsEnabled bool
-		WatermarkEnabled  bool
-		WatermarkOpacity  int
-		WatermarkPosition string
-	}{
-		ThumbnailsEnabled: true,
-		WatermarkEnabled:  true,
-		WatermarkOpacity:  30,
-		WatermarkPosition: "center",
-	}
-
-	// Prepare upload configuration
-	uploadConfig := storagegcs.UploadConfig{
-		GenerateThumbnails: mediaSettings.ThumbnailsEnabled,
-		ApplyWatermark:     mediaSettings.WatermarkEnabled,
-		WatermarkOpacity:   mediaSettings.WatermarkOpacity,
-		WatermarkPosition:  mediaSettings.WatermarkPosition,
-		ThumbnailSizes:     []int{200, 400}, // Standard thumbnail sizes
-	}
-
-	// Upload to GCS
-	uploadResult, err := s.storage.Upload(ctx, file, header.Filename, uploadConfig)
+	// Store the original; images are watermarked and resized by the media worker
+	uploadResult, err := s.storage.UploadOriginal(ctx, file, header.Filename)
 	if err != nil {
 		return nil, errs.E(ctx, "CAT_MEDIA_UPLOAD_FAILED", "فشل رفع الملف إلى التخزين")
 	}
@@ -743,17 +742,19 @@ func (s *Service) UploadMedia(ctx context.Context, productID int64, file multipa
 		ProductID:        productID,
 		Kind:             MediaKind(uploadResult.Kind),
 		GCSPath:          uploadResult.GCSPath,
-		ThumbPath:        &uploadResult.ThumbPath,
-		WatermarkApplied: uploadResult.WatermarkApplied,
 		FileSize:         &uploadResult.Size,
 		MimeType:         func() *string { ct := header.Header.Get("Content-Type"); return &ct }(),
 		OriginalFilename: &header.Filename,
 		Description:      description,
 	}
+	queueForProcessing(media)
 
 	if err := s.repo.CreateMedia(ctx, media); err != nil {
 		return nil, errs.E(ctx, "CAT_MEDIA_SAVE_FAILED", "فشل حفظ سجل الوسائط")
 	}
+	if media.ProcessingStatus == MediaProcessingPending {
+		s.enqueueMediaProcessing(ctx, media.ID)
+	}
 
 	return &UploadMediaResponse{
 		Media: *media,
@@ -816,7 +817,7 @@ func (s *Service) getMediaSettings(ctx context.Context) (*MediaSettings, error)
 		ThumbnailsEnabled: true,
 		ThumbnailSizes:    []int{200, 400},
 		MaxFileSize:       10485760, // 10MB
-		AllowedTypes:      []string{"image/jpeg", "image/png", "image/webp", "video/mp4"},
+		AllowedTypes:      []string{"image/jpeg", "image/png", "image/webp", "video/mp4", "video/quicktime", "video/webm"},
 	}
 	if s.config == nil {
 		return settings, nil
@@ -886,9 +887,9 @@ func (s *Service) convertToSummary(ctx context.Context, product Product, vatSett
 	if err == nil {
 		summary.MediaCount = len(mediaList)
 
-		// Find first image for thumbnail
+		// Find first processed image for thumbnail
 		for _, media := range mediaList {
-			if media.Kind == MediaKindImage {
+			if media.Kind == MediaKindImage && media.IsReady() {
 				// Use thumb_path if available, otherwise use gcs_path
 				pathToUse := media.GCSPath
 				if media.ThumbPath != nil && *media.ThumbPath != "" {
@@ -945,6 +946,7 @@ func (s *Service) getProductWithDetails(ctx context.Context, product *Product) (
 			return nil, errs.E(ctx, "CAT_PIGEON_READ_FAILED", "فشل جلب تفاصيل الحمامة")
 		}
 		details.Pigeon = pigeon
+		details.Pedigree = s.productPedigree(ctx, product.ID)
 
 	case ProductTypeSupply:
 		supply, err := s.repo.GetSupplyByProductID(ctx, product.ID)
@@ -961,22 +963,20 @@ func (s *Service) getProductWithDetails(ctx context.Context, product *Product) (
 	}
 
 	// Generate signed URLs for media (valid for 1 hour)
-	if s.storage != nil {
-		for i := range media {
-			// Generate signed URL for main image
-			signedURL, err := s.storage.GetSecureURL(ctx, media[i].GCSPath, 1*time.Hour)
-			if err == nil {
-				// Store signed URL in GCSPath temporarily for frontend
-				media[i].GCSPath = signedURL
-			}
-
-			// Generate signed URL for thumbnail if exists
-			if media[i].ThumbPath != nil && *media[i].ThumbPath != "" {
-				thumbURL, err := s.storage.GetSecureURL(ctx, *media[i].ThumbPath, 1*time.Hour)
-				if err == nil {
-					media[i].ThumbPath = &thumbURL
-				}
-			}
+	for i := range media {
+		if !media[i].IsReady() {
+			// Still processing: keep the row (admins see its status) but never expose the original
+			media[i].GCSPath = ""
+			media[i].ThumbPath = nil
+			continue
+		}
+		signedURL, thumbURL := s.signMediaURLs(ctx, &media[i])
+		if signedURL != nil {
+			// Store signed URL in GCSPath temporarily for frontend
+			media[i].GCSPath = *signedURL
+		}
+		if thumbURL != nil {
+			media[i].ThumbPath = thumbURL
 		}
 	}
 
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"encore.app/pkg/authn"
+	"encore.app/pkg/config"
 	"encore.app/pkg/errs"
 	"encore.app/pkg/storagegcs"
 	"encore.dev/beta/auth"
@@ -18,11 +19,14 @@ import (
 
 var db = sqldb.Named("coredb")
 
-// GCS secrets; reuse same secret keys used by catalog service
+// Storage secrets; reuse same secret keys used by catalog service
 var secrets struct {
 	GCSProjectID       string //encore:secret
 	GCSBucketName      string //encore:secret
 	GCSCredentialsJSON string //encore:secret
+	S3AccessKeyID      string //encore:secret
+	S3SecretAccessKey  string //encore:secret
+	MediaSigningKey    string //encore:secret
 }
 
 // ======================== User Endpoints (Authenticated) ========================
@@ -189,17 +193,20 @@ func (s *Service) StatusCheck(ctx context.Context, id string, req *StatusCheckRe
 
 //encore:service
 type Service struct{}
-var storage *storagegcs.Client
+var storage storagegcs.Storage
 
 func initService() (*Service, error) {
-    // Initialize storage client (private bucket) once
+    // Initialize storage client (private, backend per media.storage_provider) once
     if storage == nil {
-        c, err := storagegcs.NewClient(context.Background(), storagegcs.Config{
-            ProjectID:      secrets.GCSProjectID,
-            BucketName:     secrets.GCSBucketName,
-            CredentialsKey: secrets.GCSCredentialsJSON,
-            IsPublic:       false,
-        })
+        settings := config.Initialize(db, 5*time.Minute).GetSettings()
+        c, err := storagegcs.Open(context.Background(), storagegcs.OptionsFromSettings(settings, storagegcs.Secrets{
+            GCSProjectID:       secrets.GCSProjectID,
+            GCSBucketName:      secrets.GCSBucketName,
+            GCSCredentialsJSON: secrets.GCSCredentialsJSON,
+            S3AccessKeyID:      secrets.S3AccessKeyID,
+            S3SecretAccessKey:  secrets.S3SecretAccessKey,
+            MediaSigningKey:    secrets.MediaSigningKey,
+        }))
         if err != nil {
             return nil, err
         }
@@ -15,6 +15,18 @@ import (
 
 var db = sqldb.Named("coredb")
 
+// Encore secrets of the notifications service. Generate the VAPID key pair once
+// (webpush.GenerateVAPIDKeys); changing it invalidates every existing browser subscription.
+// The Twilio credentials are shared with the auth service's OTP client.
+var secrets struct {
+	VAPIDPublicKey   string //encore:secret
+	VAPIDPrivateKey  string //encore:secret
+	VAPIDSubject     string //encore:secret
+	TwilioAccountSID string //encore:secret
+	TwilioAuthToken  string //encore:secret
+	TwilioFromNumber string //encore:secret
+}
+
 //encore:service
 type Service struct{}
 
@@ -0,0 +1,206 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"encore.dev/cron"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/outbox"
+)
+
+// outbox dispatcher: delivers internal/email rows written by other services inside their
+// transactions (see pkg/outbox). Delivery is at-least-once; the row's dedupe key is copied
+// into the notification (or digest item) payload as "outbox_key" so a redelivered row is not
+// enqueued twice, on any channel it fans out to.
+
+// DispatchOutboxResponse is the named response type for the private API
+type DispatchOutboxResponse struct {
+	Claimed   int `json:"claimed"`
+	Delivered int `json:"delivered"`
+	Retried   int `json:"retried"`
+	Failed    int `json:"failed"`
+}
+
+//encore:api private
+func DispatchOutbox(ctx context.Context) (*DispatchOutboxResponse, error) {
+	st, err := outbox.Dispatch(ctx, senderDB, outbox.Filter{
+		Kinds: []outbox.Kind{outbox.KindInternal, outbox.KindEmail},
+	}, 100, deliverOutboxEntry)
+	if err != nil {
+		return nil, errs.New(errs.NotifQueueQueryFailed, "فشل معالجة صندوق الصادر")
+	}
+	return &DispatchOutboxResponse{Claimed: st.Claimed, Delivered: st.Delivered, Retried: st.Retried, Failed: st.Failed}, nil
+}
+
+var _ = cron.NewJob("notifications-outbox-dispatcher", cron.JobConfig{
+	Title:    "Deliver transactional outbox notifications",
+	Every:    cron.Minute,
+	Endpoint: DispatchOutbox,
+})
+
+// KickOutbox dispatches in the background right after a commit so messages don't wait for the
+// next cron tick. Anything it misses is picked up by the cron.
+func KickOutbox() {
+	go func() {
+		c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, _ = DispatchOutbox(c)
+	}()
+}
+
+func deliverOutboxEntry(ctx context.Context, e outbox.Entry) error {
+	if e.UserID <= 0 {
+		return fmt.Errorf("outbox row %d has no recipient", e.ID)
+	}
+	payload := map[string]any{}
+	if len(e.Payload) > 0 {
+		if err := json.Unmarshal(e.Payload, &payload); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+	}
+	payload["outbox_key"] = e.DedupeKey
+
+	// A previous attempt may have enqueued the notification, or parked the email for the daily
+	// digest, and crashed before acking the row
+	exists, err := outboxDelivered(ctx, string(e.Kind), e.DedupeKey)
+	if err != nil {
+		return fmt.Errorf("dedupe lookup: %w", err)
+	}
+	if exists {
+		if e.Kind == outbox.KindInternal {
+			// The crash may have come before the push/SMS copies; fanOut skips those already queued
+			fanOut(ctx, e.UserID, e.Target, payload)
+		}
+		return nil
+	}
+
+	switch e.Kind {
+	case outbox.KindInternal:
+		_, err = EnqueueInternal(ctx, e.UserID, e.Target, payload)
+	case outbox.KindEmail:
+		_, err = EnqueueEmail(ctx, e.UserID, e.Target, payload)
+	default:
+		err = fmt.Errorf("unsupported outbox kind %q", e.Kind)
+	}
+	return err
+}
+
+// outboxDelivered reports whether the outbox row with key was already queued on channel (for
+// email, also parked for the digest)
+func outboxDelivered(ctx context.Context, channel, key string) (bool, error) {
+	var exists bool
+	err := senderDB.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM notifications
+			WHERE channel = $1::notification_channel AND payload ? 'outbox_key' AND payload->>'outbox_key' = $2
+		) OR ($1 = 'email' AND EXISTS(
+			SELECT 1 FROM notification_digest_items
+			WHERE payload ? 'outbox_key' AND payload->>'outbox_key' = $2
+		))
+	`, channel, key).Scan(&exists)
+	return exists, err
+}
+
+// outboxKeyOf returns the outbox dedupe key carried in a notification payload, or ""
+func outboxKeyOf(payload any) string {
+	if pl, ok := payload.(map[string]any); ok {
+		key, _ := pl["outbox_key"].(string)
+		return key
+	}
+	return ""
+}
+
+// OutboxEvent is an outbox row as shown to admins
+type OutboxEvent struct {
+	ID            int64      `json:"id"`
+	DedupeKey     string     `json:"dedupe_key"`
+	Kind          string     `json:"kind"`
+	UserID        *int64     `json:"user_id,omitempty"`
+	Target        string     `json:"target"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     *string    `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type ListOutboxQuery struct {
+	Status string `query:"status"`
+	Limit  int    `query:"limit"`
+}
+
+type ListOutboxResponse struct {
+	Items []OutboxEvent `json:"items"`
+}
+
+// ListOutbox lists outbox rows (admin only), newest first; filter by status to find failures
+//
+//encore:api auth method=GET path=/admin/outbox
+func (s *Service) ListOutbox(ctx context.Context, req *ListOutboxQuery) (*ListOutboxResponse, error) {
+	if !isAdmin() {
+		return nil, errs.New(errs.Forbidden, "يتطلب صلاحيات مدير")
+	}
+	limit := 50
+	status := ""
+	if req != nil {
+		if req.Limit > 0 && req.Limit <= 200 {
+			limit = req.Limit
+		}
+		status = strings.TrimSpace(req.Status)
+	}
+	rows, err := senderDB.Query(ctx, `
+		SELECT id, dedupe_key, kind, user_id, target, status, attempts, next_attempt_at, last_error, delivered_at, created_at
+		FROM outbox_events
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2`, status, limit)
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل جلب صندوق الصادر")
+	}
+	defer rows.Close()
+	items := []OutboxEvent{}
+	for rows.Next() {
+		var ev OutboxEvent
+		if err := rows.Scan(&ev.ID, &ev.DedupeKey, &ev.Kind, &ev.UserID, &ev.Target, &ev.Status, &ev.Attempts, &ev.NextAttemptAt, &ev.LastError, &ev.DeliveredAt, &ev.CreatedAt); err != nil {
+			return nil, errs.New(errs.Internal, "فشل قراءة صندوق الصادر")
+		}
+		items = append(items, ev)
+	}
+	return &ListOutboxResponse{Items: items}, nil
+}
+
+type RetryOutboxResponse struct {
+	Requeued bool `json:"requeued"`
+}
+
+// RetryOutboxEvent puts a failed outbox row back in the queue (admin only)
+//
+//encore:api auth method=POST path=/admin/outbox/:id/retry
+func (s *Service) RetryOutboxEvent(ctx context.Context, id string) (*RetryOutboxResponse, error) {
+	if !isAdmin() {
+		return nil, errs.New(errs.Forbidden, "يتطلب صلاحيات مدير")
+	}
+	eventID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || eventID <= 0 {
+		return nil, errs.New(errs.InvalidArgument, "معرّف غير صالح")
+	}
+	res, err := senderDB.Exec(ctx, `
+		UPDATE outbox_events
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), last_error = NULL
+		WHERE id = $1 AND status = 'failed'`, eventID)
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل إعادة الجدولة")
+	}
+	if res.RowsAffected() == 0 {
+		return nil, errs.New(errs.NotifNotFound, "لا يوجد حدث فاشل بهذا المعرّف")
+	}
+	KickOutbox()
+	return &RetryOutboxResponse{Requeued: true}, nil
+}
@@ -0,0 +1,385 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"encore.dev/beta/auth"
+	"encore.dev/cron"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/outbox"
+	"encore.app/pkg/templates"
+)
+
+// Notification channels a preference can control
+const (
+	ChannelInternal = "internal"
+	ChannelEmail    = "email"
+	ChannelSMS      = "sms"
+	ChannelPush     = "push"
+)
+
+// Digest modes
+const (
+	DigestOff   = "off"
+	DigestDaily = "daily"
+)
+
+// preference is a user's setting for one category; rows are optional and default to
+// inbox + email + push on, SMS off (opt-in) and no digest
+type preference struct {
+	Internal bool
+	Email    bool
+	SMS      bool
+	Push     bool
+	Digest   string
+}
+
+var defaultPreference = preference{Internal: true, Email: true, SMS: false, Push: true, Digest: DigestOff}
+
+type delivery int
+
+const (
+	deliverNow delivery = iota
+	deliverDigest
+	deliverMuted
+)
+
+// decide applies a preference to one channel. Mandatory categories always go out immediately on
+// internal and email; SMS stays opt-in everywhere and push can always be muted.
+func decide(p preference, mandatory bool, channel string) delivery {
+	switch channel {
+	case ChannelInternal:
+		if mandatory || p.Internal {
+			return deliverNow
+		}
+	case ChannelEmail:
+		if mandatory {
+			return deliverNow
+		}
+		if p.Email {
+			if p.Digest == DigestDaily {
+				return deliverDigest
+			}
+			return deliverNow
+		}
+	case ChannelSMS:
+		if p.SMS {
+			return deliverNow
+		}
+	case ChannelPush:
+		if p.Push {
+			return deliverNow
+		}
+	default:
+		return deliverNow
+	}
+	return deliverMuted
+}
+
+// loadPreference reads the stored preference; lookup failures fall back to the defaults so a
+// preferences outage never drops notifications
+func loadPreference(ctx context.Context, userID int64, category string) preference {
+	p := defaultPreference
+	err := senderDB.QueryRow(ctx, `
+		SELECT internal_enabled, email_enabled, sms_enabled, push_enabled, digest
+		FROM notification_preferences
+		WHERE user_id = $1 AND category = $2`, userID, category).Scan(&p.Internal, &p.Email, &p.SMS, &p.Push, &p.Digest)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			fmt.Printf("WARNING: failed to load notification preferences for user %d: %v\n", userID, err)
+		}
+		return defaultPreference
+	}
+	return p
+}
+
+func routeByPreference(ctx context.Context, userID int64, templateID, channel string) delivery {
+	c := templates.CategoryOf(templateID)
+	return decide(loadPreference(ctx, userID, c.ID), c.Mandatory, channel)
+}
+
+// ChannelAllowed reports whether templateID may be sent to the user on channel right now
+func ChannelAllowed(ctx context.Context, userID int64, templateID, channel string) bool {
+	return routeByPreference(ctx, userID, templateID, channel) == deliverNow
+}
+
+// addDigestItem parks an email for the user's next daily digest
+func addDigestItem(ctx context.Context, userID int64, templateID string, payload any) error {
+	buf, _ := json.Marshal(payload)
+	if _, err := senderDB.Exec(ctx, `
+		INSERT INTO notification_digest_items (user_id, category, template_id, payload)
+		VALUES ($1, $2, $3, $4)`, userID, templates.CategoryOf(templateID).ID, templateID, json.RawMessage(buf)); err != nil {
+		return errs.New(errs.NotifQueueInsertFailed, "فشل إضافة الإشعار إلى الملخص")
+	}
+	return nil
+}
+
+// CategoryPreference is one row of the preferences screen
+type CategoryPreference struct {
+	Category  string `json:"category"`
+	NameAR    string `json:"name_ar"`
+	NameEN    string `json:"name_en"`
+	Mandatory bool   `json:"mandatory"`
+	Internal  bool   `json:"internal"`
+	Email     bool   `json:"email"`
+	SMS       bool   `json:"sms"`
+	Push      bool   `json:"push"`
+	Digest    string `json:"digest"`
+}
+
+type PreferencesResponse struct {
+	Categories []CategoryPreference `json:"categories"`
+}
+
+// GetMyNotificationPreferences returns the current user's preferences for every category
+//
+//encore:api auth method=GET path=/me/notification-preferences
+func (s *Service) GetMyNotificationPreferences(ctx context.Context) (*PreferencesResponse, error) {
+	uid, err := currentUserID()
+	if err != nil {
+		return nil, err
+	}
+	return preferencesFor(ctx, uid)
+}
+
+// PreferenceUpdate changes one category; omitted fields keep their current value
+type PreferenceUpdate struct {
+	Category string  `json:"category"`
+	Internal *bool   `json:"internal,omitempty"`
+	Email    *bool   `json:"email,omitempty"`
+	SMS      *bool   `json:"sms,omitempty"`
+	Push     *bool   `json:"push,omitempty"`
+	Digest   *string `json:"digest,omitempty"`
+}
+
+type UpdatePreferencesRequest struct {
+	Preferences []PreferenceUpdate `json:"preferences"`
+}
+
+// UpdateMyNotificationPreferences updates the current user's preferences
+//
+//encore:api auth method=PATCH path=/me/notification-preferences
+func (s *Service) UpdateMyNotificationPreferences(ctx context.Context, req *UpdatePreferencesRequest) (*PreferencesResponse, error) {
+	uid, err := currentUserID()
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || len(req.Preferences) == 0 {
+		return nil, errs.New(errs.InvalidArgument, "لا توجد تغييرات")
+	}
+
+	for _, u := range req.Preferences {
+		c, ok := templates.GetCategory(strings.TrimSpace(u.Category))
+		if !ok {
+			return nil, errs.New(errs.InvalidArgument, "فئة إشعارات غير معروفة: "+u.Category)
+		}
+		if c.Mandatory && ((u.Internal != nil && !*u.Internal) || (u.Email != nil && !*u.Email) || (u.Digest != nil && *u.Digest != DigestOff)) {
+			return nil, errs.New(errs.InvalidArgument, "لا يمكن إيقاف أو تأجيل الإشعارات الإلزامية: "+c.NameAR)
+		}
+		if u.Digest != nil && *u.Digest != DigestOff && *u.Digest != DigestDaily {
+			return nil, errs.New(errs.InvalidArgument, "قيمة الملخص غير صالحة")
+		}
+		if u.SMS != nil && *u.SMS {
+			var phone string
+			_ = senderDB.QueryRow(ctx, `SELECT COALESCE(phone, '') FROM users WHERE id = $1`, uid).Scan(&phone)
+			if strings.TrimSpace(phone) == "" {
+				return nil, errs.New(errs.InvalidArgument, "أضف رقم جوال لتفعيل الرسائل النصية")
+			}
+		}
+	}
+
+	tx, err := senderDB.Begin(ctx)
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل حفظ التفضيلات")
+	}
+	defer tx.Rollback()
+	for _, u := range req.Preferences {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO notification_preferences (user_id, category, internal_enabled, email_enabled, sms_enabled, push_enabled, digest)
+			VALUES ($1, $2, COALESCE($3::boolean, TRUE), COALESCE($4::boolean, TRUE), COALESCE($5::boolean, FALSE), COALESCE($6::boolean, TRUE), COALESCE($7::text, 'off'))
+			ON CONFLICT (user_id, category) DO UPDATE SET
+				internal_enabled = COALESCE($3::boolean, notification_preferences.internal_enabled),
+				email_enabled    = COALESCE($4::boolean, notification_preferences.email_enabled),
+				sms_enabled      = COALESCE($5::boolean, notification_preferences.sms_enabled),
+				push_enabled     = COALESCE($6::boolean, notification_preferences.push_enabled),
+				digest           = COALESCE($7::text, notification_preferences.digest)
+		`, uid, strings.TrimSpace(u.Category), u.Internal, u.Email, u.SMS, u.Push, u.Digest); err != nil {
+			return nil, errs.New(errs.Internal, "فشل حفظ التفضيلات")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errs.New(errs.Internal, "فشل حفظ التفضيلات")
+	}
+	return preferencesFor(ctx, uid)
+}
+
+func preferencesFor(ctx context.Context, uid int64) (*PreferencesResponse, error) {
+	stored := map[string]preference{}
+	rows, err := senderDB.Query(ctx, `
+		SELECT category, internal_enabled, email_enabled, sms_enabled, push_enabled, digest
+		FROM notification_preferences WHERE user_id = $1`, uid)
+	if err != nil {
+		return nil, errs.New(errs.Internal, "فشل جلب التفضيلات")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var category string
+		var p preference
+		if err := rows.Scan(&category, &p.Internal, &p.Email, &p.SMS, &p.Push, &p.Digest); err != nil {
+			return nil, errs.New(errs.Internal, "فشل قراءة التفضيلات")
+		}
+		stored[category] = p
+	}
+
+	out := &PreferencesResponse{}
+	for _, c := range templates.Categories() {
+		p, ok := stored[c.ID]
+		if !ok {
+			p = defaultPreference
+		}
+		if c.Mandatory {
+			p.Internal, p.Email, p.Digest = true, true, DigestOff
+		}
+		out.Categories = append(out.Categories, CategoryPreference{
+			Category:  c.ID,
+			NameAR:    c.NameAR,
+			NameEN:    c.NameEN,
+			Mandatory: c.Mandatory,
+			Internal:  p.Internal,
+			Email:     p.Email,
+			SMS:       p.SMS,
+			Push:      p.Push,
+			Digest:    p.Digest,
+		})
+	}
+	return out, nil
+}
+
+func currentUserID() (int64, error) {
+	uidStr, ok := auth.UserID()
+	if !ok {
+		return 0, errs.New(errs.NotifUnauthenticated, "مطلوب تسجيل الدخول")
+	}
+	uid, err := strconv.ParseInt(string(uidStr), 10, 64)
+	if err != nil {
+		return 0, errs.New(errs.InvalidArgument, "معرّف مستخدم غير صالح")
+	}
+	return uid, nil
+}
+
+// SendNotificationDigestsResponse is the named response type for the private API
+type SendNotificationDigestsResponse struct {
+	Users int `json:"users"`
+	Items int `json:"items"`
+}
+
+// SendNotificationDigests bundles each user's parked emails into one "notification_digest" email.
+// Items are claimed and the digest is written to the outbox in the same transaction.
+//
+//encore:api private
+func SendNotificationDigests(ctx context.Context) (*SendNotificationDigestsResponse, error) {
+	rows, err := senderDB.Query(ctx, `SELECT DISTINCT user_id FROM notification_digest_items WHERE digested_at IS NULL`)
+	if err != nil {
+		return nil, errs.New(errs.NotifQueueQueryFailed, "فشل الاستعلام عن الملخصات")
+	}
+	var userIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+
+	resp := &SendNotificationDigestsResponse{}
+	for _, uid := range userIDs {
+		n, err := sendDigest(ctx, uid)
+		if err != nil {
+			fmt.Printf("ERROR: failed to build digest for user %d: %v\n", uid, err)
+			continue
+		}
+		if n > 0 {
+			resp.Users++
+			resp.Items += n
+		}
+	}
+	if resp.Users > 0 {
+		KickOutbox()
+	}
+	return resp, nil
+}
+
+var _ = cron.NewJob("notifications-email-digest", cron.JobConfig{
+	Title:    "Send daily notification digests",
+	Schedule: "0 6 * * *",
+	Endpoint: SendNotificationDigests,
+})
+
+func sendDigest(ctx context.Context, uid int64) (int, error) {
+	var email, name string
+	if err := senderDB.QueryRow(ctx, `SELECT COALESCE(email, ''), COALESCE(name, '') FROM users WHERE id = $1`, uid).Scan(&email, &name); err != nil {
+		return 0, err
+	}
+
+	tx, err := senderDB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(ctx, `
+		UPDATE notification_digest_items SET digested_at = NOW()
+		WHERE user_id = $1 AND digested_at IS NULL
+		RETURNING id, template_id, payload`, uid)
+	if err != nil {
+		return 0, err
+	}
+	var items []map[string]any
+	var firstID int64
+	for rows.Next() {
+		var id int64
+		var templateID string
+		var payload json.RawMessage
+		if err := rows.Scan(&id, &templateID, &payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		var pl map[string]any
+		_ = json.Unmarshal(payload, &pl)
+		lang, _ := pl["language"].(string)
+		subject, _, _, err := templates.RenderTemplate(templateID, lang, templates.TemplateData(pl))
+		if err != nil || subject == "" {
+			subject = templateID
+		}
+		items = append(items, map[string]any{"template_id": templateID, "subject": subject})
+		if firstID == 0 || id < firstID {
+			firstID = id
+		}
+	}
+	rows.Close()
+	if len(items) == 0 || email == "" {
+		return 0, tx.Commit()
+	}
+
+	payload := map[string]any{
+		"email":    email,
+		"name":     name,
+		"count":    len(items),
+		"items":    items,
+		"language": "ar",
+	}
+	if err := outbox.Add(ctx, tx, outbox.Email(uid, "notification_digest", payload, outbox.Key("digest", uid, firstID))); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
@@ -0,0 +1,37 @@
+package notifications
+
+import "testing"
+
+func TestDecide(t *testing.T) {
+	muted := preference{Internal: false, Email: false, SMS: false, Push: false, Digest: DigestOff}
+	digest := preference{Internal: true, Email: true, SMS: false, Push: true, Digest: DigestDaily}
+	cases := []struct {
+		name      string
+		pref      preference
+		mandatory bool
+		channel   string
+		want      delivery
+	}{
+		{"defaults inbox", defaultPreference, false, ChannelInternal, deliverNow},
+		{"defaults email", defaultPreference, false, ChannelEmail, deliverNow},
+		{"sms is opt-in", defaultPreference, false, ChannelSMS, deliverMuted},
+		{"muted inbox", muted, false, ChannelInternal, deliverMuted},
+		{"muted email", muted, false, ChannelEmail, deliverMuted},
+		{"mandatory ignores mute", muted, true, ChannelEmail, deliverNow},
+		{"mandatory inbox ignores mute", muted, true, ChannelInternal, deliverNow},
+		{"mandatory sms still opt-in", muted, true, ChannelSMS, deliverMuted},
+		{"defaults push", defaultPreference, false, ChannelPush, deliverNow},
+		{"muted push", muted, false, ChannelPush, deliverMuted},
+		{"mandatory push can be muted", muted, true, ChannelPush, deliverMuted},
+		{"digest keeps push immediate", digest, false, ChannelPush, deliverNow},
+		{"digest email", digest, false, ChannelEmail, deliverDigest},
+		{"digest keeps inbox immediate", digest, false, ChannelInternal, deliverNow},
+		{"mandatory never digested", digest, true, ChannelEmail, deliverNow},
+		{"email off beats digest", preference{Internal: true, Digest: DigestDaily}, false, ChannelEmail, deliverMuted},
+	}
+	for _, tc := range cases {
+		if got := decide(tc.pref, tc.mandatory, tc.channel); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
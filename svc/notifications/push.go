@@ -0,0 +1,509 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"encore.dev/beta/auth"
+	"encore.dev/cron"
+
+	"encore.app/pkg/authn"
+	"encore.app/pkg/config"
+	"encore.app/pkg/errs"
+	"encore.app/pkg/httpx"
+	"encore.app/pkg/templates"
+	"encore.app/pkg/webpush"
+)
+
+// Web Push channel: browsers register a push subscription per device, push rows are queued in
+// notifications (channel 'push') and ProcessPushQueue encrypts and sends them to every device
+// of the user. Subscriptions the push service reports gone (404/410), that keep failing or
+// that passed their expiration time are deleted.
+
+const (
+	// maxPushFailures is how many consecutive failed deliveries a subscription survives
+	maxPushFailures = 10
+	// maxPushSubscriptionsPerUser caps devices per user; the least recently used go first
+	maxPushSubscriptionsPerUser = 20
+	// pushTTL is how long push services hold a message for an offline device
+	pushTTL = 12 * time.Hour
+)
+
+// pushEnabled reports whether the push channel is switched on and VAPID keys are configured
+func pushEnabled() bool {
+	if secrets.VAPIDPublicKey == "" || secrets.VAPIDPrivateKey == "" {
+		return false
+	}
+	return config.Initialize(senderDB, 5*time.Minute).GetSettings().NotificationsPushEnabled
+}
+
+func pushClient() *webpush.Client {
+	subject := secrets.VAPIDSubject
+	if subject == "" {
+		subject = "mailto:support@dughairiloft.com"
+	}
+	return webpush.NewClient(webpush.VAPIDKeys{
+		PublicKey:  secrets.VAPIDPublicKey,
+		PrivateKey: secrets.VAPIDPrivateKey,
+	}, subject)
+}
+
+// PushConfigResponse tells the frontend whether to offer push and which key to subscribe with
+type PushConfigResponse struct {
+	Enabled   bool   `json:"enabled"`
+	PublicKey string `json:"public_key,omitempty"` // applicationServerKey for pushManager.subscribe()
+}
+
+// GetPushConfig returns the VAPID public key browsers subscribe with
+//
+//encore:api auth method=GET path=/notifications/push/config
+func (s *Service) GetPushConfig(ctx context.Context) (*PushConfigResponse, error) {
+	if !pushEnabled() {
+		return &PushConfigResponse{Enabled: false}, nil
+	}
+	return &PushConfigResponse{Enabled: true, PublicKey: secrets.VAPIDPublicKey}, nil
+}
+
+// PushSubscriptionKeys are the keys of PushSubscription.toJSON()
+type PushSubscriptionKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// SubscribePushRequest is the browser's PushSubscription.toJSON() plus an optional device name
+type SubscribePushRequest struct {
+	Endpoint       string               `json:"endpoint"`
+	ExpirationTime *int64               `json:"expirationTime,omitempty"` // ms since epoch
+	Keys           PushSubscriptionKeys `json:"keys"`
+	DeviceName     string               `json:"device_name,omitempty"`
+}
+
+// PushSubscriptionInfo is a registered device; the endpoint and keys are never returned
+type PushSubscriptionInfo struct {
+	ID            int64      `json:"id"`
+	DeviceName    string     `json:"device_name"`
+	UserAgent     string     `json:"user_agent"`
+	Current       bool       `json:"current"` // registered from the session making the request
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type PushSubscriptionsResponse struct {
+	Items []PushSubscriptionInfo `json:"items"`
+}
+
+// SubscribePush registers (or re-registers) this browser for push notifications. A browser
+// endpoint belongs to one user at a time: signing in as someone else moves it.
+//
+//encore:api auth method=POST path=/notifications/push/subscriptions
+func (s *Service) SubscribePush(ctx context.Context, req *SubscribePushRequest) (*PushSubscriptionInfo, error) {
+	uid, err := currentUserID()
+	if err != nil {
+		return nil, err
+	}
+	if !pushEnabled() {
+		return nil, errs.New(errs.FailedPrecondition, "الإشعارات الفورية غير مفعّلة")
+	}
+	if req == nil {
+		return nil, errs.New(errs.InvalidArgument, "بيانات الاشتراك مطلوبة")
+	}
+	sub := webpush.Subscription{
+		Endpoint: strings.TrimSpace(req.Endpoint),
+		P256dh:   strings.TrimSpace(req.Keys.P256dh),
+		Auth:     strings.TrimSpace(req.Keys.Auth),
+	}
+	if len(sub.Endpoint) > 2048 || sub.Validate() != nil {
+		return nil, errs.New(errs.ValidationFailed, "اشتراك الإشعارات غير صالح")
+	}
+	var expiresAt *time.Time
+	if req.ExpirationTime != nil && *req.ExpirationTime > 0 {
+		t := time.UnixMilli(*req.ExpirationTime).UTC()
+		if !t.After(time.Now()) {
+			return nil, errs.New(errs.ValidationFailed, "اشتراك الإشعارات منتهي الصلاحية")
+		}
+		expiresAt = &t
+	}
+	// Cut by characters: a byte cut can split an Arabic letter and Postgres rejects invalid UTF-8
+	deviceName := strings.TrimSpace(strings.ToValidUTF8(req.DeviceName, ""))
+	if runes := []rune(deviceName); len(runes) > 100 {
+		deviceName = strings.TrimSpace(string(runes[:100]))
+	}
+	sessionID := currentSessionID()
+
+	info := &PushSubscriptionInfo{DeviceName: deviceName, UserAgent: httpx.GetUserAgentFromContext(ctx), Current: sessionID != "", ExpiresAt: expiresAt}
+	if err := senderDB.QueryRow(ctx, `
+		INSERT INTO push_subscriptions (user_id, session_id, endpoint, p256dh, auth, device_name, user_agent, expires_at)
+		VALUES ($1, (SELECT id FROM user_sessions WHERE id = NULLIF($2, '')), $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (endpoint) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			session_id = EXCLUDED.session_id,
+			p256dh = EXCLUDED.p256dh,
+			auth = EXCLUDED.auth,
+			device_name = EXCLUDED.device_name,
+			user_agent = EXCLUDED.user_agent,
+			expires_at = EXCLUDED.expires_at,
+			failure_count = 0,
+			last_error = NULL
+		RETURNING id, last_success_at, created_at
+	`, uid, sessionID, sub.Endpoint, sub.P256dh, sub.Auth, deviceName, info.UserAgent, expiresAt).Scan(&info.ID, &info.LastSuccessAt, &info.CreatedAt); err != nil {
+		return nil, errs.New(errs.NotifUpdateFailed, "فشل حفظ اشتراك الإشعارات")
+	}
+
+	// Keep the newest devices only
+	_, _ = senderDB.Exec(ctx, `
+		DELETE FROM push_subscriptions
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM push_subscriptions
+			WHERE user_id = $1
+			ORDER BY GREATEST(updated_at, COALESCE(last_success_at, updated_at)) DESC
+			LIMIT $2
+		)`, uid, maxPushSubscriptionsPerUser)
+	return info, nil
+}
+
+// ListMyPushSubscriptions lists the devices registered for push
+//
+//encore:api auth method=GET path=/notifications/push/subscriptions
+func (s *Service) ListMyPushSubscriptions(ctx context.Context) (*PushSubscriptionsResponse, error) {
+	uid, err := currentUserID()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := senderDB.Query(ctx, `
+		SELECT id, device_name, user_agent, COALESCE(session_id, ''), last_success_at, expires_at, created_at
+		FROM push_subscriptions
+		WHERE user_id = $1
+		ORDER BY created_at DESC`, uid)
+	if err != nil {
+		return nil, errs.New(errs.NotifListQueryFailed, "فشل جلب الأجهزة")
+	}
+	defer rows.Close()
+	sessionID := currentSessionID()
+	items := []PushSubscriptionInfo{}
+	for rows.Next() {
+		var it PushSubscriptionInfo
+		var sid string
+		if err := rows.Scan(&it.ID, &it.DeviceName, &it.UserAgent, &sid, &it.LastSuccessAt, &it.ExpiresAt, &it.CreatedAt); err != nil {
+			return nil, errs.New(errs.NotifListQueryFailed, "فشل قراءة الأجهزة")
+		}
+		it.Current = sid != "" && sid == sessionID
+		items = append(items, it)
+	}
+	return &PushSubscriptionsResponse{Items: items}, nil
+}
+
+type UnsubscribePushResponse struct {
+	Removed bool `json:"removed"`
+}
+
+// DeletePushSubscription stops push notifications to one of the user's devices
+//
+//encore:api auth method=DELETE path=/notifications/push/subscriptions/:id
+func (s *Service) DeletePushSubscription(ctx context.Context, id int64) (*UnsubscribePushResponse, error) {
+	uid, err := currentUserID()
+	if err != nil {
+		return nil, err
+	}
+	res, err := senderDB.Exec(ctx, `DELETE FROM push_subscriptions WHERE id = $1 AND user_id = $2`, id, uid)
+	if err != nil {
+		return nil, errs.New(errs.NotifUpdateFailed, "فشل حذف الجهاز")
+	}
+	if res.RowsAffected() == 0 {
+		return nil, errs.New(errs.NotifNotFound, "الجهاز غير موجود")
+	}
+	return &UnsubscribePushResponse{Removed: true}, nil
+}
+
+type UnsubscribePushRequest struct {
+	Endpoint string `json:"endpoint"`
+}
+
+// UnsubscribePush removes this browser's subscription, e.g. after pushSubscription.unsubscribe()
+//
+//encore:api auth method=POST path=/notifications/push/unsubscribe
+func (s *Service) UnsubscribePush(ctx context.Context, req *UnsubscribePushRequest) (*UnsubscribePushResponse, error) {
+	uid, err := currentUserID()
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || strings.TrimSpace(req.Endpoint) == "" {
+		return nil, errs.New(errs.InvalidArgument, "عنوان الاشتراك مطلوب")
+	}
+	res, err := senderDB.Exec(ctx, `DELETE FROM push_subscriptions WHERE endpoint = $1 AND user_id = $2`, strings.TrimSpace(req.Endpoint), uid)
+	if err != nil {
+		return nil, errs.New(errs.NotifUpdateFailed, "فشل إلغاء الاشتراك")
+	}
+	return &UnsubscribePushResponse{Removed: res.RowsAffected() > 0}, nil
+}
+
+// currentSessionID returns the login session (device) of the caller's access token
+func currentSessionID() string {
+	return authn.SessionIDOf(auth.Data())
+}
+
+// EnqueuePush queues a push notification for every device of the user. It returns id 0 without
+// queueing when push is off, the template has no push message (see templates.RenderPush), the
+// user muted push for the category or has no device registered.
+func EnqueuePush(ctx context.Context, userID int64, templateID string, payload any) (int64, error) {
+	if !templates.HasPush(templateID) || !pushEnabled() {
+		return 0, nil
+	}
+	if routeByPreference(ctx, userID, templateID, ChannelPush) != deliverNow {
+		return 0, nil
+	}
+	if key := outboxKeyOf(payload); key != "" {
+		if done, err := outboxDelivered(ctx, ChannelPush, key); err != nil || done {
+			return 0, err
+		}
+	}
+	var hasDevice bool
+	if err := senderDB.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM push_subscriptions WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW()))
+	`, userID).Scan(&hasDevice); err != nil || !hasDevice {
+		return 0, nil
+	}
+
+	buf, _ := json.Marshal(payload)
+	var id int64
+	if err := senderDB.QueryRow(ctx, `
+		INSERT INTO notifications (user_id, channel, template_id, payload, status)
+		VALUES ($1,'push',$2,$3,'queued')
+		RETURNING id
+	`, userID, templateID, json.RawMessage(buf)).Scan(&id); err != nil {
+		return 0, errs.EDetails(ctx, errs.NotifQueueInsertFailed, "فشل إدراج الإشعار الفوري", map[string]any{"cause": err.Error()})
+	}
+
+	// Pushes are only useful right away; the cron picks up whatever this misses
+	go func() {
+		c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, _ = ProcessPushQueue(c)
+	}()
+	return id, nil
+}
+
+// pushMessage is the JSON the service worker receives and shows with showNotification()
+type pushMessage struct {
+	NotificationID int64  `json:"notification_id"`
+	TemplateID     string `json:"template_id"`
+	Title          string `json:"title"`
+	Body           string `json:"body"`
+	URL            string `json:"url"`
+	Tag            string `json:"tag"` // replaces an older notification about the same thing
+}
+
+type pushTarget struct {
+	ID  int64
+	Sub webpush.Subscription
+}
+
+// ProcessPushQueueResponse is the named response type for the private API
+type ProcessPushQueueResponse struct {
+	Processed int `json:"processed"`
+	Pruned    int `json:"pruned"`
+}
+
+//encore:api private
+func ProcessPushQueue(ctx context.Context) (*ProcessPushQueueResponse, error) {
+	resp := &ProcessPushQueueResponse{}
+	if !pushEnabled() {
+		return resp, nil
+	}
+	client := pushClient()
+	rows, err := senderDB.Query(ctx, `
+		SELECT id, user_id, template_id, payload
+		FROM notifications
+		WHERE channel='push'
+		  AND (
+			status = 'queued'
+			OR (
+			  status = 'failed' AND next_retry_at IS NOT NULL AND next_retry_at <= NOW()
+			)
+		  )
+		ORDER BY created_at ASC
+		LIMIT 50`)
+	if err != nil {
+		return nil, errs.New(errs.NotifQueueQueryFailed, "فشل الاستعلام عن الطابور")
+	}
+	type queued struct {
+		id         int64
+		userID     int64
+		templateID string
+		payload    json.RawMessage
+	}
+	var batch []queued
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.id, &q.userID, &q.templateID, &q.payload); err != nil {
+			rows.Close()
+			return nil, errs.New(errs.NotifQueueQueryFailed, "فشل القراءة")
+		}
+		batch = append(batch, q)
+	}
+	rows.Close()
+
+	for _, q := range batch {
+		// mark as sending (claim)
+		res, err := senderDB.Exec(ctx, `
+			UPDATE notifications
+			SET status='sending'
+			WHERE id=$1 AND (
+				status='queued' OR (status='failed' AND next_retry_at IS NOT NULL AND next_retry_at <= NOW())
+			)
+		`, q.id)
+		if err != nil || res.RowsAffected() == 0 {
+			continue
+		}
+
+		var pl map[string]any
+		_ = json.Unmarshal(q.payload, &pl)
+		lang, _ := pl["language"].(string)
+		title, body, url, err := templates.RenderPush(q.templateID, lang, templates.TemplateData(pl))
+		if err != nil {
+			finishPush(ctx, q.id, "archived", "render failed: "+err.Error())
+			continue
+		}
+		tag := q.templateID
+		if auctionID, ok := pl["auction_id"].(string); ok && auctionID != "" {
+			tag += ":" + auctionID
+		}
+		msg, _ := json.Marshal(pushMessage{NotificationID: q.id, TemplateID: q.templateID, Title: title, Body: body, URL: url, Tag: tag})
+
+		targets, err := pushTargets(ctx, q.userID)
+		if err != nil {
+			finishPush(ctx, q.id, "failed", err.Error())
+			continue
+		}
+		if len(targets) == 0 {
+			finishPush(ctx, q.id, "archived", "no push subscriptions")
+			continue
+		}
+
+		opts := webpush.Options{TTL: pushTTL, Urgency: webpush.UrgencyNormal}
+		if templates.IsUrgentPush(q.templateID) {
+			opts.Urgency = webpush.UrgencyHigh
+		}
+		delivered, gone := 0, 0
+		var lastErr error
+		for _, t := range targets {
+			err := client.Send(ctx, t.Sub, msg, opts)
+			switch {
+			case err == nil:
+				delivered++
+				_, _ = senderDB.Exec(ctx, `
+					UPDATE push_subscriptions SET failure_count = 0, last_error = NULL, last_success_at = NOW()
+					WHERE id = $1`, t.ID)
+			case webpush.IsGone(err), errors.Is(err, webpush.ErrUnknownPushService):
+				// Expired, revoked, or stored before endpoints were restricted to known push services
+				gone++
+				if _, derr := senderDB.Exec(ctx, `DELETE FROM push_subscriptions WHERE id = $1`, t.ID); derr == nil {
+					resp.Pruned++
+				}
+			default:
+				lastErr = err
+				if pruned := recordPushFailure(ctx, t.ID, err); pruned {
+					resp.Pruned++
+				}
+			}
+		}
+
+		switch {
+		case delivered > 0:
+			finishPush(ctx, q.id, "sent", "")
+			resp.Processed++
+		case gone == len(targets):
+			finishPush(ctx, q.id, "archived", "push subscriptions expired")
+		case errors.Is(lastErr, webpush.ErrPayloadTooLarge):
+			finishPush(ctx, q.id, "archived", lastErr.Error())
+		default:
+			fmt.Printf("ERROR: Failed to send push for notification %d: %v\n", q.id, lastErr)
+			finishPush(ctx, q.id, "failed", fmt.Sprint(lastErr))
+		}
+	}
+	return resp, nil
+}
+
+var _ = cron.NewJob("notifications-push-queue", cron.JobConfig{
+	Title:    "Process push notifications queue",
+	Every:    cron.Minute,
+	Endpoint: ProcessPushQueue,
+})
+
+// pushTargets loads the user's unexpired subscriptions
+func pushTargets(ctx context.Context, userID int64) ([]pushTarget, error) {
+	rows, err := senderDB.Query(ctx, `
+		SELECT id, endpoint, p256dh, auth
+		FROM push_subscriptions
+		WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []pushTarget
+	for rows.Next() {
+		var t pushTarget
+		if err := rows.Scan(&t.ID, &t.Sub.Endpoint, &t.Sub.P256dh, &t.Sub.Auth); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// recordPushFailure counts a failed delivery and deletes the subscription once it has failed
+// maxPushFailures times in a row; it reports whether it was deleted
+func recordPushFailure(ctx context.Context, subID int64, sendErr error) bool {
+	var failures int
+	if err := senderDB.QueryRow(ctx, `
+		UPDATE push_subscriptions
+		SET failure_count = failure_count + 1, last_error = $2, last_failure_at = NOW()
+		WHERE id = $1
+		RETURNING failure_count`, subID, sendErr.Error()).Scan(&failures); err != nil {
+		return false
+	}
+	if failures < maxPushFailures {
+		return false
+	}
+	_, err := senderDB.Exec(ctx, `DELETE FROM push_subscriptions WHERE id = $1`, subID)
+	return err == nil
+}
+
+// finishPush records the outcome of a claimed push row; 'failed' rows are retried by the
+// notifications retry trigger until max_retries
+func finishPush(ctx context.Context, id int64, status, reason string) {
+	var err error
+	switch status {
+	case "sent":
+		_, err = senderDB.Exec(ctx, `UPDATE notifications SET status='sent', sent_at=NOW() WHERE id=$1`, id)
+	case "failed":
+		_, err = senderDB.Exec(ctx, `
+			UPDATE notifications
+			SET
+			  retry_count = retry_count + 1,
+			  status = CASE WHEN retry_count + 1 >= max_retries THEN 'archived'::notification_status ELSE 'failed'::notification_status END,
+			  failed_reason = $2
+			WHERE id=$1`, id, reason)
+	default:
+		_, err = senderDB.Exec(ctx, `UPDATE notifications SET status='archived', failed_reason=$2 WHERE id=$1`, id, reason)
+	}
+	if err != nil {
+		fmt.Printf("ERROR: Failed to update push notification %d to %s: %v\n", id, status, err)
+	}
+}
+
+// prunePushSubscriptions deletes subscriptions past their expiration time or that kept failing
+func prunePushSubscriptions(ctx context.Context) (int, error) {
+	res, err := senderDB.Exec(ctx, `
+		DELETE FROM push_subscriptions
+		WHERE (expires_at IS NOT NULL AND expires_at <= NOW())
+		   OR failure_count >= $1`, maxPushFailures)
+	if err != nil {
+		return 0, err
+	}
+	return int(res.RowsAffected()), nil
+}
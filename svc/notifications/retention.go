@@ -35,8 +35,9 @@ type CleanupNotificationsRequest struct{}
 
 // CleanupNotificationsResponse استجابة تنظيف الإشعارات
 type CleanupNotificationsResponse struct {
-	Deleted  int `json:"deleted"`
-	Archived int `json:"archived"`
+	Deleted                 int `json:"deleted"`
+	Archived                int `json:"archived"`
+	PushSubscriptionsPruned int `json:"push_subscriptions_pruned"`
 }
 
 //encore:api private
@@ -98,9 +99,13 @@ func CleanupNotifications(ctx context.Context) (*CleanupNotificationsResponse, e
 			AND entity_type NOT IN ('payment', 'auction_won', 'user_verification')
 	`)
 	
+	// Drop expired and dead push subscriptions
+	pruned, _ := prunePushSubscriptions(ctx)
+
 	return &CleanupNotificationsResponse{
-		Deleted:  deleted,
-		Archived: archived,
+		Deleted:                 deleted,
+		Archived:                archived,
+		PushSubscriptionsPruned: pruned,
 	}, nil
 }
 
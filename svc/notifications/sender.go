@@ -163,8 +163,16 @@ var _ = cron.NewJob("notifications-email-queue", cron.JobConfig{
 	Endpoint: ProcessEmailQueue,
 })
 
-// Utility to enqueue an email notification
+// Utility to enqueue an email notification. Honours the user's notification preferences:
+// muted categories return id 0, digest categories are parked for the daily digest.
 func EnqueueEmail(ctx context.Context, userID int64, templateID string, payload any) (int64, error) {
+	switch routeByPreference(ctx, userID, templateID, ChannelEmail) {
+	case deliverMuted:
+		return 0, nil
+	case deliverDigest:
+		return 0, addDigestItem(ctx, userID, templateID, payload)
+	}
+
 	// Try to extract verification_code if present for idempotency
 	var verificationCode string
 	switch pl := payload.(type) {
@@ -238,7 +246,7 @@ func EnqueueEmail(ctx context.Context, userID int64, templateID string, payload
 // isImmediateTemplate marks templates that should be sent with minimal delay
 func isImmediateTemplate(tpl string) bool {
 	switch tpl {
-	case "email_verification", "password_reset":
+	case "email_verification", "password_reset", "new_device_login", "account_locked":
 		return true
 	default:
 		return false
@@ -254,8 +262,13 @@ func getNextRetryAt(retryCount, maxRetries int) (sql.NullTime, error) {
 	return sql.NullTime{Time: time.Now().Add(minutes * time.Minute), Valid: true}, nil
 }
 
-// Utility to enqueue an internal (inbox) notification
+// Utility to enqueue an internal (inbox) notification. Returns id 0 when the user muted the category.
+// Templates with a push message or SMS variant are also sent on those channels (see fanOut).
 func EnqueueInternal(ctx context.Context, userID int64, templateID string, payload any) (int64, error) {
+	if routeByPreference(ctx, userID, templateID, ChannelInternal) == deliverMuted {
+		fanOut(ctx, userID, templateID, payload)
+		return 0, nil
+	}
 	buf, _ := json.Marshal(payload)
 	var id int64
 	if err := senderDB.QueryRow(ctx, `
@@ -275,5 +288,18 @@ func EnqueueInternal(ctx context.Context, userID int64, templateID string, paylo
 		}
 		return 0, errs.EDetails(ctx, errs.NotifQueueInsertFailed, "فشل إدراج الإشعار الداخلي", details)
 	}
+	fanOut(ctx, userID, templateID, payload)
 	return id, nil
 }
+
+// fanOut mirrors an inbox notification to the user's devices (EnqueuePush) and phone
+// (EnqueueSMS). Each follows its own preference, so muting the inbox mutes neither, and
+// failures never affect the inbox notification.
+func fanOut(ctx context.Context, userID int64, templateID string, payload any) {
+	if _, err := EnqueuePush(ctx, userID, templateID, payload); err != nil {
+		fmt.Printf("WARNING: failed to queue push %s for user %d: %v\n", templateID, userID, err)
+	}
+	if _, err := EnqueueSMS(ctx, userID, templateID, payload); err != nil {
+		fmt.Printf("WARNING: failed to queue SMS %s for user %d: %v\n", templateID, userID, err)
+	}
+}
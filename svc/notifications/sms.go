@@ -0,0 +1,231 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"encore.dev"
+	"encore.dev/cron"
+
+	"encore.app/pkg/config"
+	"encore.app/pkg/errs"
+	"encore.app/pkg/sms"
+	"encore.app/pkg/templates"
+)
+
+// SMS channel: short text variants (templates.RenderSMS) are queued in notifications (channel
+// 'sms') and sent by ProcessSMSQueue to the phone on the user's account at send time. SMS is
+// opt-in per category and switched on globally by notifications.sms_enabled.
+
+// smsSender is the SMS gateway; without Twilio credentials messages are only logged
+var smsSender = sync.OnceValue(func() sms.Sender {
+	return sms.NewTwilioClient(sms.TwilioConfig{
+		AccountSID: secrets.TwilioAccountSID,
+		AuthToken:  secrets.TwilioAuthToken,
+		FromNumber: secrets.TwilioFromNumber,
+		DevMode:    secrets.TwilioAccountSID == "" || secrets.TwilioAuthToken == "" || secrets.TwilioFromNumber == "",
+	})
+})
+
+func smsEnabled() bool {
+	return config.Initialize(senderDB, 5*time.Minute).GetSettings().NotificationsSMSEnabled
+}
+
+// ProcessSMSQueueResponse is the named response type for the private API
+type ProcessSMSQueueResponse struct {
+	Processed int `json:"processed"`
+}
+
+//encore:api private
+func ProcessSMSQueue(ctx context.Context) (*ProcessSMSQueueResponse, error) {
+	resp := &ProcessSMSQueueResponse{}
+	if !smsEnabled() {
+		return resp, nil
+	}
+	sender := smsSender()
+	// fetch a batch of queued SMS notifications ready to send, with the recipient's current phone
+	rows, err := senderDB.Query(ctx, `
+		SELECT n.id, n.user_id, n.template_id, n.payload, COALESCE(u.phone, '')
+		FROM notifications n
+		JOIN users u ON u.id = n.user_id
+		WHERE n.channel='sms'
+		  AND (
+			n.status = 'queued'
+			OR (
+			  n.status = 'failed' AND n.next_retry_at IS NOT NULL AND n.next_retry_at <= NOW()
+			)
+		  )
+		ORDER BY n.created_at ASC
+		LIMIT 50`)
+	if err != nil {
+		return nil, errs.New(errs.NotifQueueQueryFailed, "فشل الاستعلام عن الطابور")
+	}
+	type queued struct {
+		id         int64
+		userID     int64
+		templateID string
+		payload    json.RawMessage
+		phone      string
+	}
+	var batch []queued
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.id, &q.userID, &q.templateID, &q.payload, &q.phone); err != nil {
+			rows.Close()
+			return nil, errs.New(errs.NotifQueueQueryFailed, "فشل القراءة")
+		}
+		batch = append(batch, q)
+	}
+	rows.Close()
+
+	for _, q := range batch {
+		// mark as sending (claim)
+		res, err := senderDB.Exec(ctx, `
+			UPDATE notifications
+			SET status='sending'
+			WHERE id=$1 AND (
+				status='queued' OR (status='failed' AND next_retry_at IS NOT NULL AND next_retry_at <= NOW())
+			)
+		`, q.id)
+		if err != nil || res.RowsAffected() == 0 {
+			continue
+		}
+
+		// The user may have removed the phone or opted out since the message was queued
+		if strings.TrimSpace(q.phone) == "" {
+			archiveSMS(ctx, q.id, "missing recipient phone")
+			continue
+		}
+		if !ChannelAllowed(ctx, q.userID, q.templateID, ChannelSMS) {
+			archiveSMS(ctx, q.id, "sms disabled by user")
+			continue
+		}
+
+		var pl map[string]any
+		_ = json.Unmarshal(q.payload, &pl)
+		err = sendSMSNotification(ctx, sender, q.phone, q.templateID, pl)
+		if err == nil {
+			if _, updateErr := senderDB.Exec(ctx, `UPDATE notifications SET status='sent', sent_at=NOW() WHERE id=$1`, q.id); updateErr != nil {
+				fmt.Printf("ERROR: Failed to update notification %d to sent: %v\n", q.id, updateErr)
+			} else {
+				resp.Processed++
+			}
+			continue
+		}
+		fmt.Printf("ERROR: Failed to send SMS for notification %d: %v\n", q.id, err)
+		// failure: increment retry_count and set failed_reason; trigger will schedule next_retry_at
+		if _, updateErr := senderDB.Exec(ctx, `
+			UPDATE notifications
+			SET
+			  retry_count = retry_count + 1,
+			  status = CASE WHEN retry_count + 1 >= max_retries THEN 'archived'::notification_status ELSE 'failed'::notification_status END,
+			  failed_reason = $2
+			WHERE id=$1`, q.id, err.Error()); updateErr != nil {
+			fmt.Printf("ERROR: Failed to update notification %d to failed: %v\n", q.id, updateErr)
+		}
+	}
+	return resp, nil
+}
+
+var _ = cron.NewJob("notifications-sms-queue", cron.JobConfig{
+	Title:    "Process SMS notifications queue",
+	Every:    cron.Minute,
+	Endpoint: ProcessSMSQueue,
+})
+
+// sendSMSNotification renders the SMS variant of templateID and sends it to phone
+func sendSMSNotification(ctx context.Context, sender sms.Sender, phone, templateID string, pl map[string]any) error {
+	lang, _ := pl["language"].(string)
+	text, err := templates.RenderSMS(templateID, lang, templates.TemplateData(pl))
+	if err != nil {
+		return err
+	}
+	return sender.SendSMS(ctx, strings.TrimSpace(phone), text)
+}
+
+func archiveSMS(ctx context.Context, id int64, reason string) {
+	if _, err := senderDB.Exec(ctx, `UPDATE notifications SET status='archived', failed_reason=$2 WHERE id=$1`, id, reason); err != nil {
+		fmt.Printf("ERROR: Failed to archive SMS notification %d: %v\n", id, err)
+	}
+}
+
+// EnqueueSMS queues an SMS notification. It returns id 0 without queueing when SMS is off, the
+// template has no SMS variant, the user has not opted in for the category or has no phone, or
+// the template is coalesced (templates.SMSCoalesce) and a recent SMS already covers the event.
+func EnqueueSMS(ctx context.Context, userID int64, templateID string, payload any) (int64, error) {
+	if !templates.HasSMS(templateID) || !smsEnabled() {
+		return 0, nil
+	}
+	if !ChannelAllowed(ctx, userID, templateID, ChannelSMS) {
+		return 0, nil
+	}
+	if key := outboxKeyOf(payload); key != "" {
+		if done, err := outboxDelivered(ctx, ChannelSMS, key); err != nil || done {
+			return 0, err
+		}
+	}
+	var phone string
+	if err := senderDB.QueryRow(ctx, `SELECT COALESCE(phone, '') FROM users WHERE id = $1`, userID).Scan(&phone); err != nil || strings.TrimSpace(phone) == "" {
+		return 0, nil
+	}
+
+	buf, _ := json.Marshal(payload)
+	// Coalesced templates are skipped while an SMS for the same user and key is pending or
+	// was sent within the window
+	field, window := templates.SMSCoalesce(templateID)
+	coalesceKey := ""
+	if window > 0 {
+		var fields map[string]json.RawMessage
+		_ = json.Unmarshal(buf, &fields)
+		coalesceKey = strings.Trim(string(fields[field]), `"`)
+	}
+	tx, err := senderDB.Begin(ctx)
+	if err != nil {
+		return 0, errs.EDetails(ctx, errs.NotifQueueInsertFailed, "فشل إدراج الرسالة النصية", map[string]any{"cause": err.Error()})
+	}
+	defer tx.Rollback()
+	// Concurrent events for the same key queue one after another, so only the first is inserted
+	if coalesceKey != "" {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`,
+			fmt.Sprintf("sms:%d:%s:%s", userID, templateID, coalesceKey)); err != nil {
+			return 0, errs.EDetails(ctx, errs.NotifQueueInsertFailed, "فشل إدراج الرسالة النصية", map[string]any{"cause": err.Error()})
+		}
+	}
+	var id int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO notifications (user_id, channel, template_id, payload, status)
+		SELECT $1, 'sms', $2, $3, 'queued'
+		WHERE $4 = '' OR NOT EXISTS (
+			SELECT 1 FROM notifications
+			WHERE user_id = $1 AND channel = 'sms' AND template_id = $2
+			  AND payload->>$5 = $4
+			  AND (status IN ('queued','sending','failed') OR created_at > NOW() - make_interval(secs => $6))
+		)
+		RETURNING id
+	`, userID, templateID, json.RawMessage(buf), coalesceKey, field, window.Seconds()).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return 0, errs.EDetails(ctx, errs.NotifQueueInsertFailed, "فشل إدراج الرسالة النصية", map[string]any{"cause": err.Error()})
+	}
+
+	// Immediately process in local dev (cron does not run locally)
+	if encore.Meta().Environment.Type == encore.EnvDevelopment && encore.Meta().Environment.Cloud == encore.CloudLocal {
+		go func() {
+			c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_, _ = ProcessSMSQueue(c)
+		}()
+	}
+	return id, nil
+}
@@ -0,0 +1,39 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"encore.app/pkg/sms"
+)
+
+func TestSendSMSNotification(t *testing.T) {
+	fake := &sms.FakeSender{}
+	payload := map[string]any{
+		"product_title":  "حمامة زاجل",
+		"winning_amount": "2500.00",
+		"language":       "ar",
+	}
+	if err := sendSMSNotification(context.Background(), fake, " +966500000000 ", "auction_ended_winner", payload); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	sent := fake.Messages()
+	if len(sent) != 1 || sent[0].To != "+966500000000" || !strings.Contains(sent[0].Body, "حمامة زاجل") {
+		t.Fatalf("unexpected messages %+v", sent)
+	}
+
+	// Templates without an SMS variant are never sent
+	if err := sendSMSNotification(context.Background(), fake, "+966500000000", "welcome", payload); err == nil {
+		t.Error("expected an error for a template without an SMS variant")
+	}
+
+	fake.Err = errors.New("gateway down")
+	if err := sendSMSNotification(context.Background(), fake, "+966500000000", "auction_winner_unpaid", payload); err == nil {
+		t.Error("expected the gateway error to be returned for a retry")
+	}
+	if n := len(fake.Messages()); n != 1 {
+		t.Errorf("failed sends must not be recorded, got %d messages", n)
+	}
+}
@@ -3,6 +3,7 @@ package checkout
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -14,7 +15,9 @@ import (
 
 	"encore.app/pkg/config"
 	"encore.app/pkg/errs"
-	"encore.app/pkg/moyasar"
+	"encore.app/pkg/outbox"
+	"encore.app/pkg/payments"
+	"encore.app/pkg/stockhold"
 	"encore.app/svc/notifications"
 )
 
@@ -92,11 +95,8 @@ func Checkout(ctx context.Context, req *CheckoutRequest) (*CheckoutResponse, err
 	if err != nil {
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء معاملة"}
 	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
+	// Always roll back unless committed: early conflict returns must not keep the product locks
+	defer tx.Rollback()
 
 	// Create order with address_id
 	var orderID int64
@@ -110,6 +110,15 @@ func Checkout(ctx context.Context, req *CheckoutRequest) (*CheckoutResponse, err
 		}
 	}
 
+	// Lock the cart's products and apply the hold limit before checking availability
+	holdLimits := stockhold.LimitsFromSettings(s)
+	if err = stockhold.Prepare(ctx, tx, userID, holdLimits.MaxActivePerUser); err != nil {
+		if errors.Is(err, stockhold.ErrTooManyHolds) {
+			return nil, &errs.Error{Code: errs.FailedPrecondition, Message: "لديك عمليات دفع معلقة كثيرة، أكمل إحداها أو انتظر انتهاءها"}
+		}
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل حجز المخزون"}
+	}
+
 	// Build items from cart_items
 	// Validate availability first
 	var badPigeon sql.NullInt64
@@ -178,14 +187,18 @@ func Checkout(ctx context.Context, req *CheckoutRequest) (*CheckoutResponse, err
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء الفاتورة: " + err.Error()}
 	}
 
-	if err = tx.Commit(); err != nil {
-		return nil, &errs.Error{Code: errs.Internal, Message: "فشل الحفظ"}
+	// Hold the items until the payment session ends (consumed by the paid webhook, released by the cleaner)
+	if err = stockhold.Hold(ctx, tx, userID, orderID, invoiceID, holdLimits.TTL, time.Now()); err != nil {
+		if errors.Is(err, stockhold.ErrUnavailable) {
+			return nil, &errs.Error{Code: errs.Conflict, Message: "العنصر لم يعد متاح"}
+		}
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل حجز المخزون"}
 	}
 
 	// Get order grand total and user info for notifications
 	var totalGross float64
 	var userName, userEmail string
-	if err := db.Stdlib().QueryRowContext(ctx, `
+	if err = tx.QueryRowContext(ctx, `
 		SELECT o.grand_total, u.name, u.email
 		FROM orders o
 		JOIN users u ON u.id = o.user_id
@@ -194,42 +207,15 @@ func Checkout(ctx context.Context, req *CheckoutRequest) (*CheckoutResponse, err
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل قراءة الإجماليات"}
 	}
 
-	// Notify admins about new order (best-effort, don't fail checkout if notification fails)
-	go func(oid, iid int64, invNum, uName, uEmail string, total float64) {
-		bgCtx := context.Background()
-		// Get all admin users
-		rows, err := db.Stdlib().QueryContext(bgCtx, `SELECT id, name, email FROM users WHERE role = 'admin' AND state = 'active'`)
-		if err != nil {
-			fmt.Printf("Failed to get admin users for order notification: %v\n", err)
-			return
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var adminID int64
-			var adminName, adminEmail string
-			if err := rows.Scan(&adminID, &adminName, &adminEmail); err != nil {
-				continue
-			}
-
-			payload := map[string]any{
-				"order_id":       oid,
-				"invoice_id":     iid,
-				"invoice_number": invNum,
-				"user_name":      uName,
-				"user_email":     uEmail,
-				"grand_total":    fmt.Sprintf("%.2f", total),
-				"language":       "ar",
-				"name":           adminName,
-				"email":          adminEmail,
-			}
+	// Notify admins about the new order through the outbox so the notification commits (or rolls back) with the order
+	if err = queueNewOrderAdminNotifications(ctx, tx, orderID, invoiceID, invoiceNumber, userName, userEmail, totalGross); err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل جدولة إشعارات الطلب"}
+	}
 
-			// Send internal notification
-			if _, err := notifications.EnqueueInternal(bgCtx, adminID, "new_order_admin", payload); err != nil {
-				fmt.Printf("Failed to send internal notification to admin %d: %v\n", adminID, err)
-			}
-		}
-	}(orderID, invoiceID, invoiceNumber, userName, userEmail, totalGross)
+	if err = tx.Commit(); err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل الحفظ"}
+	}
+	notifications.KickOutbox()
 
 	// Convert to halalas (smallest currency unit)
 	amountHalalas := int(totalGross * 100)
@@ -293,33 +279,38 @@ func Checkout(ctx context.Context, req *CheckoutRequest) (*CheckoutResponse, err
 	// Server-to-server callback is configured in Moyasar dashboard; leave empty here
 	callbackURL := ""
 
-	// Create payment in Moyasar
+	// Create payment with the configured gateway
 	description := fmt.Sprintf("Order #%d - Invoice #%s", orderID, invoiceNumber)
 	metadata := map[string]string{
 		"order_id":   fmt.Sprintf("%d", orderID),
 		"invoice_id": fmt.Sprintf("%d", invoiceID),
 	}
 
-	gatewayRef, paymentURL, err := moyasar.CreateInvoice(
-		amountHalalas,
-		"SAR",
-		description,
-		successURL,
-		backURL,
-		callbackURL,
-		metadata,
-	)
+	provider, err := payments.Configured()
 	if err != nil {
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء الدفع: " + err.Error()}
 	}
+	session, err := provider.CreateSession(ctx, &payments.SessionRequest{
+		Amount:      int64(amountHalalas),
+		Currency:    "SAR",
+		Description: description,
+		SuccessURL:  successURL,
+		BackURL:     backURL,
+		WebhookURL:  callbackURL,
+		Metadata:    metadata,
+	})
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء الدفع: " + err.Error()}
+	}
+	gatewayRef, paymentURL := session.GatewayRef, session.URL
 
 	// Create payment record in database (critical for webhook processing)
 	var dbPaymentID int64
 	if err := db.Stdlib().QueryRowContext(ctx, `
 		INSERT INTO payments (invoice_id, gateway, gateway_ref, status, currency, amount_authorized, raw_response)
-		VALUES ($1, 'moyasar', $2, 'initiated', 'SAR', $3, '{}')
+		VALUES ($1, $2, $3, 'initiated', 'SAR', $4, '{}')
 		RETURNING id
-	`, invoiceID, gatewayRef, totalGross).Scan(&dbPaymentID); err != nil {
+	`, invoiceID, provider.Name(), gatewayRef, totalGross).Scan(&dbPaymentID); err != nil {
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء سجل الدفع: " + err.Error()}
 	}
 
@@ -359,3 +350,36 @@ func hashKey(s string) uint64 {
 	}
 	return h
 }
+
+// queueNewOrderAdminNotifications writes a "new_order_admin" inbox notification for every active admin to the outbox
+func queueNewOrderAdminNotifications(ctx context.Context, tx *sql.Tx, orderID, invoiceID int64, invoiceNumber, userName, userEmail string, total float64) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, name, email FROM users WHERE role = 'admin' AND state = 'active'`)
+	if err != nil {
+		return err
+	}
+	var msgs []outbox.Message
+	for rows.Next() {
+		var adminID int64
+		var adminName, adminEmail string
+		if err := rows.Scan(&adminID, &adminName, &adminEmail); err != nil {
+			continue
+		}
+		payload := map[string]any{
+			"order_id":       orderID,
+			"invoice_id":     invoiceID,
+			"invoice_number": invoiceNumber,
+			"user_name":      userName,
+			"user_email":     userEmail,
+			"grand_total":    fmt.Sprintf("%.2f", total),
+			"language":       "ar",
+			"name":           adminName,
+			"email":          adminEmail,
+		}
+		msgs = append(msgs, outbox.Internal(adminID, "new_order_admin", payload, outbox.Key("order", orderID, "new_order_admin", adminID)))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return outbox.AddTx(ctx, tx, msgs...)
+}
@@ -0,0 +1,196 @@
+package order_mgmt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"encore.dev"
+	"encore.dev/beta/auth"
+
+	"encore.app/pkg/config"
+	"encore.app/pkg/einvoice"
+	"encore.app/pkg/errs"
+	"encore.app/pkg/logger"
+)
+
+// Tax invoice documents (ZATCA simplified tax invoice): a bilingual PDF and a UBL 2.1 XML,
+// both carrying the TLV QR code. Built on the fly from the invoice, its order items and
+// the seller tax profile in system_settings, so nothing extra is stored per invoice.
+
+// invoiceFontPath is read at runtime like the watermark font; Tajawal maps the Arabic
+// presentation forms the PDF writer relies on
+const invoiceFontPath = "assets/fonts/Tajawal-Regular.ttf"
+
+var (
+	invoiceFontOnce sync.Once
+	invoiceFont     []byte
+	invoiceFontErr  error
+)
+
+func loadInvoiceFont() ([]byte, error) {
+	invoiceFontOnce.Do(func() {
+		invoiceFont, invoiceFontErr = os.ReadFile(invoiceFontPath)
+	})
+	return invoiceFont, invoiceFontErr
+}
+
+// issuedInvoiceStatuses are the statuses for which a tax invoice has been issued (paid at
+// least once); credit notes cover later refunds
+var issuedInvoiceStatuses = map[string]bool{"paid": true, "refund_required": true, "refunded": true}
+
+func writeError(w http.ResponseWriter, err *errs.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.HTTPStatus())
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"code":    err.Code,
+		"message": err.Message,
+		"details": err.Details,
+	})
+}
+
+// GetInvoicePDF downloads the bilingual tax invoice PDF (owner or admin)
+//
+//encore:api auth raw method=GET path=/invoices/:id/pdf
+func GetInvoicePDF(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	inv, e := loadTaxInvoice(ctx, encore.CurrentRequest().PathParams.Get("id"))
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+	font, err := loadInvoiceFont()
+	if err != nil {
+		logger.Error(ctx, "Invoice font unavailable", logger.Fields{"path": invoiceFontPath, "error": err.Error()})
+		writeError(w, &errs.Error{Code: errs.Internal, Message: "تعذر تحميل خط الفاتورة"})
+		return
+	}
+	out, err := inv.PDF(font)
+	if err != nil {
+		logger.Error(ctx, "Failed to render invoice PDF", logger.Fields{"invoice": inv.Number, "error": err.Error()})
+		writeError(w, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء ملف الفاتورة"})
+		return
+	}
+	writeDocument(w, "application/pdf", inv.Number+".pdf", out)
+}
+
+// GetInvoiceXML downloads the UBL 2.1 tax invoice XML (owner or admin)
+//
+//encore:api auth raw method=GET path=/invoices/:id/xml
+func GetInvoiceXML(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	inv, e := loadTaxInvoice(ctx, encore.CurrentRequest().PathParams.Get("id"))
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+	out, err := inv.UBL()
+	if err != nil {
+		logger.Error(ctx, "Failed to render invoice XML", logger.Fields{"invoice": inv.Number, "error": err.Error()})
+		writeError(w, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء ملف الفاتورة"})
+		return
+	}
+	writeDocument(w, "application/xml; charset=utf-8", inv.Number+".xml", out)
+}
+
+func writeDocument(w http.ResponseWriter, contentType, filename string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	_, _ = w.Write(body)
+}
+
+// loadTaxInvoice checks access and assembles the invoice document from the database
+func loadTaxInvoice(ctx context.Context, id string) (*einvoice.Invoice, *errs.Error) {
+	uidStr, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{Code: errs.Unauthenticated, Message: "مطلوب تسجيل الدخول"}
+	}
+	uid, _ := strconv.ParseInt(string(uidStr), 10, 64)
+	iid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || iid <= 0 {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرّف غير صالح"}
+	}
+
+	var (
+		ownerID, orderID int64
+		status, buyer    string
+		vatRate          float64
+		shipping         float64
+		inv              einvoice.Invoice
+	)
+	if err := db.Stdlib().QueryRowContext(ctx, `
+		SELECT i.number, i.status::text, i.vat_rate_snapshot, COALESCE(i.issued_at, i.created_at),
+		       o.id, o.user_id, o.shipping_fee_gross, COALESCE(u.name, '')
+		FROM invoices i
+		JOIN orders o ON o.id=i.order_id
+		JOIN users u ON u.id=o.user_id
+		WHERE i.id=$1`, iid).Scan(&inv.Number, &status, &vatRate, &inv.IssuedAt, &orderID, &ownerID, &shipping, &buyer); err != nil {
+		return nil, &errs.Error{Code: "INV_NOT_FOUND", Message: "الفاتورة غير موجودة"}
+	}
+	if ownerID != uid {
+		var role string
+		_ = db.Stdlib().QueryRowContext(ctx, `SELECT role::text FROM users WHERE id=$1`, uid).Scan(&role)
+		if strings.ToLower(role) != "admin" {
+			return nil, &errs.Error{Code: errs.Forbidden, Message: "غير مصرح"}
+		}
+	}
+	if !issuedInvoiceStatuses[status] {
+		return nil, &errs.Error{Code: errs.FailedPrecondition, Message: "لا تصدر الفاتورة الضريبية قبل سداد الطلب"}
+	}
+
+	s := config.GetSettings()
+	if s == nil || !einvoice.ValidVATNumber(s.CompanyVATNumber) {
+		return nil, &errs.Error{Code: errs.FailedPrecondition, Message: "لم يتم إعداد الرقم الضريبي للبائع بعد"}
+	}
+	inv.Counter = iid
+	inv.VATRate = vatRate
+	inv.ShippingGross = shipping
+	inv.Currency = s.PaymentsCurrency
+	inv.Seller = einvoice.Party{
+		NameAR:     s.CompanyLegalNameAR,
+		NameEN:     s.CompanyLegalNameEN,
+		VATNumber:  s.CompanyVATNumber,
+		CRNumber:   s.CompanyCRNumber,
+		Street:     s.CompanyStreet,
+		City:       s.CompanyCity,
+		PostalCode: s.CompanyPostalCode,
+	}
+	inv.Buyer = einvoice.Party{NameAR: buyer}
+
+	rows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT p.title, oi.qty, oi.unit_price_gross, oi.line_total_gross
+		FROM order_items oi JOIN products p ON p.id=oi.product_id
+		WHERE oi.order_id=$1 ORDER BY oi.id ASC`, orderID)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل قراءة العناصر"}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l einvoice.Line
+		if err := rows.Scan(&l.Description, &l.Qty, &l.UnitGross, &l.LineGross); err != nil {
+			return nil, &errs.Error{Code: errs.Internal, Message: "فشل قراءة عنصر"}
+		}
+		inv.Lines = append(inv.Lines, l)
+	}
+	if len(inv.Lines) == 0 {
+		return nil, &errs.Error{Code: errs.FailedPrecondition, Message: "الفاتورة لا تحتوي على عناصر"}
+	}
+	inv.IssuedAt = inv.IssuedAt.UTC().Truncate(time.Second)
+	return &inv, nil
+}
+
+// documentLinks fills the download links for invoices that have been issued
+func documentLinks(inv *InvoiceSummary) {
+	if issuedInvoiceStatuses[inv.Status] {
+		inv.PDFURL = fmt.Sprintf("/invoices/%d/pdf", inv.ID)
+		inv.XMLURL = fmt.Sprintf("/invoices/%d/xml", inv.ID)
+	}
+}
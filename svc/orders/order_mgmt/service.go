@@ -249,6 +249,9 @@ type InvoiceSummary struct {
 	ID     int64  `json:"id"`
 	Number string `json:"number"`
 	Status string `json:"status"`
+	// Tax invoice downloads, set once the invoice is paid
+	PDFURL string `json:"pdf_url,omitempty"`
+	XMLURL string `json:"xml_url,omitempty"`
 }
 
 type InvoicesResponse struct {
@@ -285,6 +288,7 @@ func GetOrderInvoice(ctx context.Context, id string) (*InvoiceSummary, error) {
 	if err := db.Stdlib().QueryRowContext(ctx, `SELECT id, number, status::text FROM invoices WHERE order_id=$1`, oid).Scan(&res.ID, &res.Number, &res.Status); err != nil {
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل قراءة الفاتورة"}
 	}
+	documentLinks(&res)
 	return &res, nil
 }
 
@@ -317,6 +321,7 @@ func ListMyInvoices(ctx context.Context, q *Paginate) (*InvoicesResponse, error)
 		if err := rows.Scan(&it.ID, &it.Number, &it.Status); err != nil {
 			return nil, &errs.Error{Code: errs.Internal, Message: "فشل القراءة"}
 		}
+		documentLinks(&it)
 		items = append(items, it)
 	}
 	return &InvoicesResponse{Items: items}, nil
@@ -350,9 +355,111 @@ func GetInvoice(ctx context.Context, id string) (*InvoiceSummary, error) {
 	if err := db.Stdlib().QueryRowContext(ctx, `SELECT id, number, status::text FROM invoices WHERE id=$1`, iid).Scan(&res.ID, &res.Number, &res.Status); err != nil {
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل القراءة"}
 	}
+	documentLinks(&res)
 	return &res, nil
 }
 
+// CreditNoteItem is a refunded order line on a credit note
+type CreditNoteItem struct {
+	ProductID int64   `json:"product_id"`
+	Title     string  `json:"title"`
+	Qty       int     `json:"qty"`
+	Amount    float64 `json:"amount"`
+}
+
+// CreditNote is a customer-facing record of a succeeded refund against an invoice
+type CreditNote struct {
+	Number        string           `json:"number"`
+	InvoiceNumber string           `json:"invoice_number"`
+	Amount        float64          `json:"amount"`
+	Currency      string           `json:"currency"`
+	Reason        string           `json:"reason"`
+	IssuedAt      string           `json:"issued_at"`
+	Items         []CreditNoteItem `json:"items"`
+}
+
+type CreditNotesResponse struct {
+	Items []CreditNote `json:"items"`
+}
+
+//encore:api auth method=GET path=/invoices/:id/credit-notes
+func ListInvoiceCreditNotes(ctx context.Context, id string) (*CreditNotesResponse, error) {
+	uidStr, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{Code: errs.Unauthenticated, Message: "مطلوب تسجيل الدخول"}
+	}
+	uid, _ := strconv.ParseInt(string(uidStr), 10, 64)
+	var iid int64
+	if v, err := strconv.ParseInt(id, 10, 64); err == nil {
+		iid = v
+	} else {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "معرّف غير صالح"}
+	}
+	var ownerID int64
+	var invoiceNumber string
+	if err := db.Stdlib().QueryRowContext(ctx, `SELECT o.user_id, i.number FROM invoices i JOIN orders o ON o.id=i.order_id WHERE i.id=$1`, iid).Scan(&ownerID, &invoiceNumber); err != nil {
+		return nil, &errs.Error{Code: "INV_NOT_FOUND", Message: "الفاتورة غير موجودة"}
+	}
+	if ownerID != uid {
+		var role string
+		_ = db.Stdlib().QueryRowContext(ctx, `SELECT role::text FROM users WHERE id=$1`, uid).Scan(&role)
+		if strings.ToLower(role) != "admin" {
+			return nil, &errs.Error{Code: errs.Forbidden, Message: "غير مصرح"}
+		}
+	}
+
+	rows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT r.id, r.credit_note_number, r.amount, r.currency, r.reason, r.updated_at
+		FROM refunds r
+		WHERE r.invoice_id=$1 AND r.status='succeeded' AND r.credit_note_number IS NOT NULL
+		ORDER BY r.created_at ASC, r.id ASC`, iid)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل الاستعلام"}
+	}
+	defer rows.Close()
+	out := &CreditNotesResponse{Items: []CreditNote{}}
+	byRefund := map[int64]int{}
+	for rows.Next() {
+		var refundID int64
+		var cn CreditNote
+		var issuedAt time.Time
+		if err := rows.Scan(&refundID, &cn.Number, &cn.Amount, &cn.Currency, &cn.Reason, &issuedAt); err != nil {
+			return nil, &errs.Error{Code: errs.Internal, Message: "فشل القراءة"}
+		}
+		cn.InvoiceNumber = invoiceNumber
+		cn.Currency = strings.TrimSpace(cn.Currency)
+		cn.IssuedAt = issuedAt.UTC().Format(time.RFC3339)
+		cn.Items = []CreditNoteItem{}
+		byRefund[refundID] = len(out.Items)
+		out.Items = append(out.Items, cn)
+	}
+	rows.Close()
+
+	itemRows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT ri.refund_id, oi.product_id, p.title, ri.qty, ri.amount
+		FROM refund_items ri
+		JOIN refunds r ON r.id=ri.refund_id
+		JOIN order_items oi ON oi.id=ri.order_item_id
+		JOIN products p ON p.id=oi.product_id
+		WHERE r.invoice_id=$1 AND r.status='succeeded'
+		ORDER BY ri.id ASC`, iid)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل الاستعلام"}
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var refundID int64
+		var it CreditNoteItem
+		if err := itemRows.Scan(&refundID, &it.ProductID, &it.Title, &it.Qty, &it.Amount); err != nil {
+			return nil, &errs.Error{Code: errs.Internal, Message: "فشل القراءة"}
+		}
+		if i, ok := byRefund[refundID]; ok {
+			out.Items[i].Items = append(out.Items[i].Items, it)
+		}
+	}
+	return out, nil
+}
+
 type CreateAuctionWinnerParams struct {
 	AuctionID          int64   `json:"auction_id"`
 	ProductID          int64   `json:"product_id"`
@@ -389,16 +496,32 @@ func CreateAuctionWinnerOrder(ctx context.Context, p *CreateAuctionWinnerParams)
 		}
 	}()
 
+	// Create order with source=auction; one order per auction and winner, so a retry returns the first one
+	var orderID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO orders (user_id, source, auction_id) VALUES ($1,'auction',$2)
+		ON CONFLICT (auction_id, user_id) WHERE auction_id IS NOT NULL DO NOTHING
+		RETURNING id`, p.WinnerUserID, p.AuctionID).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		_ = tx.Rollback()
+		var existing CreateAuctionWinnerResponse
+		if err = db.Stdlib().QueryRowContext(ctx, `
+			SELECT o.id, COALESCE(i.id, 0) FROM orders o
+			LEFT JOIN invoices i ON i.order_id=o.id
+			WHERE o.auction_id=$1 AND o.user_id=$2
+			ORDER BY i.id DESC NULLS LAST LIMIT 1`, p.AuctionID, p.WinnerUserID).Scan(&existing.OrderID, &existing.InvoiceID); err != nil {
+			return nil, &errs.Error{Code: errs.Internal, Message: "فشل قراءة طلب المزاد"}
+		}
+		return &existing, nil
+	}
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء الطلب"}
+	}
+
 	// Move product to auction_hold
 	if _, err = tx.ExecContext(ctx, `UPDATE products SET status='auction_hold', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$1`, p.ProductID); err != nil {
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل تحديث حالة المنتج"}
 	}
-
-	// Create order with source=auction
-	var orderID int64
-	if err = tx.QueryRowContext(ctx, `INSERT INTO orders (user_id, source) VALUES ($1,'auction') RETURNING id`, p.WinnerUserID).Scan(&orderID); err != nil {
-		return nil, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء الطلب"}
-	}
 	// Add single item (qty=1) at winning gross
 	if _, err = tx.ExecContext(ctx, `INSERT INTO order_items (order_id, product_id, qty, unit_price_gross, line_total_gross) VALUES ($1,$2,1,$3,$3)`, orderID, p.ProductID, p.WinningAmountGross); err != nil {
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل إدراج عنصر الطلب"}
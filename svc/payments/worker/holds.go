@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"encore.app/pkg/errs"
+)
+
+// Admin view of checkout stock holds (see pkg/stockhold). Holds are created by checkout,
+// consumed by the paid webhook and released by CleanupExpiredPaymentSessions.
+
+type ListStockHoldsParams struct {
+	ProductID int64 `query:"product_id"`
+	UserID    int64 `query:"user_id"`
+	Limit     int   `query:"limit"`
+}
+
+type StockHold struct {
+	ID            int64  `json:"id"`
+	OrderID       int64  `json:"order_id"`
+	InvoiceID     int64  `json:"invoice_id"`
+	InvoiceNumber string `json:"invoice_number"`
+	InvoiceStatus string `json:"invoice_status"`
+	ProductID     int64  `json:"product_id"`
+	ProductTitle  string `json:"product_title"`
+	ProductType   string `json:"product_type"`
+	UserID        int64  `json:"user_id"`
+	UserName      string `json:"user_name"`
+	Qty           int    `json:"qty"`
+	ExpiresAt     string `json:"expires_at"`
+	Expired       bool   `json:"expired"` // past expiry, waiting for the next cleaner run
+	CreatedAt     string `json:"created_at"`
+}
+
+type ListStockHoldsResponse struct {
+	Holds []StockHold `json:"holds"`
+}
+
+// AdminListStockHolds lists active checkout holds, soonest to expire first
+//
+//encore:api auth method=GET path=/admin/stock/holds
+func AdminListStockHolds(ctx context.Context, params *ListStockHoldsParams) (*ListStockHoldsResponse, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	limit := 100
+	var productID, userID int64
+	if params != nil {
+		if params.Limit > 0 && params.Limit <= 500 {
+			limit = params.Limit
+		}
+		productID, userID = params.ProductID, params.UserID
+	}
+	rows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT sr.id, sr.order_id, COALESCE(sr.invoice_id, 0), COALESCE(i.number, ''), COALESCE(i.status::text, ''),
+		       sr.product_id, p.title, p.type::text, sr.user_id, COALESCE(u.name, ''), sr.qty,
+		       sr.expires_at, sr.created_at
+		FROM stock_reservations sr
+		JOIN products p ON p.id=sr.product_id
+		JOIN users u ON u.id=sr.user_id
+		LEFT JOIN invoices i ON i.id=sr.invoice_id
+		WHERE sr.status='active'
+		  AND ($1::bigint = 0 OR sr.product_id=$1)
+		  AND ($2::bigint = 0 OR sr.user_id=$2)
+		ORDER BY sr.expires_at ASC, sr.id ASC
+		LIMIT $3
+	`, productID, userID, limit)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة حجوزات المخزون"}
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	out := &ListStockHoldsResponse{Holds: []StockHold{}}
+	for rows.Next() {
+		var h StockHold
+		var expiresAt, createdAt time.Time
+		if err := rows.Scan(&h.ID, &h.OrderID, &h.InvoiceID, &h.InvoiceNumber, &h.InvoiceStatus,
+			&h.ProductID, &h.ProductTitle, &h.ProductType, &h.UserID, &h.UserName, &h.Qty,
+			&expiresAt, &createdAt); err != nil {
+			return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة حجوزات المخزون"}
+		}
+		h.Expired = !expiresAt.After(now)
+		h.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+		h.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		out.Holds = append(out.Holds, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة حجوزات المخزون"}
+	}
+	return out, nil
+}
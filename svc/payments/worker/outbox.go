@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/outbox"
+)
+
+// paymentEventsTopic is the name of PaymentWebhookEvents as stored in outbox_events.target
+const paymentEventsTopic = "payment-webhook-events"
+
+// DispatchPaymentEventsResponse is the named response type for the private API
+type DispatchPaymentEventsResponse struct {
+	Claimed   int `json:"claimed"`
+	Delivered int `json:"delivered"`
+	Retried   int `json:"retried"`
+	Failed    int `json:"failed"`
+}
+
+// DispatchPaymentEvents publishes verified webhooks parked in the outbox to PaymentWebhookEvents.
+// Webhooks are acknowledged to the gateway only after they are durably stored, so a crash between
+// the ack and the publish no longer loses the event.
+//
+//encore:api private
+func DispatchPaymentEvents(ctx context.Context) (*DispatchPaymentEventsResponse, error) {
+	st, err := outbox.Dispatch(ctx, db, outbox.Filter{
+		Kinds:  []outbox.Kind{outbox.KindPubSub},
+		Target: paymentEventsTopic,
+	}, 100, publishPaymentEvent)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "فشل نشر أحداث الدفع"}
+	}
+	return &DispatchPaymentEventsResponse{Claimed: st.Claimed, Delivered: st.Delivered, Retried: st.Retried, Failed: st.Failed}, nil
+}
+
+func publishPaymentEvent(ctx context.Context, e outbox.Entry) error {
+	var evt PaymentEvent
+	if err := json.Unmarshal(e.Payload, &evt); err != nil {
+		return fmt.Errorf("decode payment event: %w", err)
+	}
+	_, err := PaymentWebhookEvents.Publish(ctx, &evt)
+	return err
+}
+
+// kickPaymentEvents publishes in the background right away; the cron retries anything left behind
+func kickPaymentEvents() {
+	go func() {
+		c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, _ = DispatchPaymentEvents(c)
+	}()
+}
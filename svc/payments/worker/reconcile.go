@@ -0,0 +1,652 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"encore.dev/beta/auth"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/logger"
+	"encore.app/pkg/payments"
+	"encore.app/svc/notifications"
+)
+
+// reconcileBatchSize caps gateway lookups per run so a backlog cannot stall the job
+const reconcileBatchSize = 200
+
+// ReconcileResponse summarizes a reconciliation run over in-flight payments
+type ReconcileResponse struct {
+	Checked   int `json:"checked"`
+	Updated   int `json:"updated"`
+	InFlight  int `json:"in_flight"`
+	Errors    int `json:"errors"`
+	Unchanged int `json:"unchanged"`
+}
+
+// ReconcilePayments fetches every in-flight payment from its gateway and feeds terminal
+// states through processWebhook, recovering payments whose webhook was lost.
+//
+//encore:api private
+func ReconcilePayments(ctx context.Context) (*ReconcileResponse, error) {
+	// Leave very fresh sessions alone: the customer is likely still on the checkout page
+	return reconcileInFlight(ctx, `p.created_at < (CURRENT_TIMESTAMP AT TIME ZONE 'UTC') - INTERVAL '2 minutes'`)
+}
+
+// reconcileInFlight reconciles initiated/pending payments matching the extra SQL condition
+// (over payments p JOIN invoices i)
+func reconcileInFlight(ctx context.Context, where string, args ...any) (*ReconcileResponse, error) {
+	rows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT p.id, p.invoice_id, p.gateway, p.gateway_ref, p.status::text
+		FROM payments p
+		JOIN invoices i ON i.id = p.invoice_id
+		WHERE p.status IN ('initiated','pending')
+		  AND p.gateway_ref <> ''
+		  AND `+where+`
+		ORDER BY p.created_at ASC
+		LIMIT `+strconv.Itoa(reconcileBatchSize), args...)
+	if err != nil {
+		logger.LogError(ctx, err, "reconcile: list in-flight payments failed", nil)
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة المدفوعات الجارية"}
+	}
+	type inFlight struct {
+		paymentID, invoiceID      int64
+		gateway, gatewayRef, stat string
+	}
+	var pending []inFlight
+	for rows.Next() {
+		var f inFlight
+		if err := rows.Scan(&f.paymentID, &f.invoiceID, &f.gateway, &f.gatewayRef, &f.stat); err != nil {
+			continue
+		}
+		pending = append(pending, f)
+	}
+	rows.Close()
+
+	out := &ReconcileResponse{}
+	for _, f := range pending {
+		out.Checked++
+		provider, err := payments.Get(f.gateway)
+		if err != nil {
+			out.Errors++
+			continue
+		}
+		st, err := provider.FetchStatus(ctx, f.gatewayRef)
+		if err != nil {
+			out.Errors++
+			logger.LogError(ctx, err, "reconcile: fetch gateway status failed", logger.Fields{
+				"payment_id":  f.paymentID,
+				"gateway":     f.gateway,
+				"gateway_ref": f.gatewayRef,
+			})
+			continue
+		}
+		status := strings.ToLower(st.Status)
+		switch status {
+		case "", "initiated", "pending":
+			out.InFlight++
+			continue
+		case "authorized":
+			if f.stat == "pending" {
+				out.Unchanged++
+				continue
+			}
+		}
+
+		// Judge session expiry by when the gateway changed state, not by when we noticed
+		at := st.UpdatedAt
+		if at.IsZero() {
+			at = time.Now().UTC()
+		}
+		currency := st.Currency
+		if currency == "" {
+			currency = "SAR"
+		}
+		if err := processWebhook(ctx, f.gateway, f.gatewayRef, f.invoiceID, status, st.Amount, st.Captured, currency, at); err != nil {
+			out.Errors++
+			continue
+		}
+		out.Updated++
+		logger.Info(ctx, "reconcile: payment state recovered from gateway", logger.Fields{
+			"payment_id":  f.paymentID,
+			"invoice_id":  f.invoiceID,
+			"gateway":     f.gateway,
+			"gateway_ref": f.gatewayRef,
+			"status":      status,
+		})
+	}
+	return out, nil
+}
+
+// PaymentDiscrepancy is a payment whose recorded state disagrees with the gateway. PaymentID
+// is 0 for gateway transactions with no payment row (missing_in_db), and InvoiceID too when
+// the gateway metadata names no known invoice.
+type PaymentDiscrepancy struct {
+	ID               int64   `json:"id"`
+	PaymentID        int64   `json:"payment_id"`
+	InvoiceID        int64   `json:"invoice_id"`
+	Kind             string  `json:"kind"`
+	Gateway          string  `json:"gateway"`
+	GatewayRef       string  `json:"gateway_ref"`
+	RecordedStatus   string  `json:"recorded_status"`
+	GatewayStatus    string  `json:"gateway_status"`
+	RecordedCaptured float64 `json:"recorded_captured"`
+	GatewayCaptured  float64 `json:"gateway_captured"`
+	RecordedRefunded float64 `json:"recorded_refunded"`
+	GatewayRefunded  float64 `json:"gateway_refunded"`
+}
+
+// ReconciliationReport is a daily comparison of recorded payments against the gateway
+type ReconciliationReport struct {
+	ID                 int64                `json:"id"`
+	ReportDate         string               `json:"report_date"`
+	WindowStart        string               `json:"window_start"`
+	WindowEnd          string               `json:"window_end"`
+	PaymentsChecked    int                  `json:"payments_checked"`
+	FetchErrors        int                  `json:"fetch_errors"`
+	DiscrepanciesCount int                  `json:"discrepancies_count"`
+	RecordedCaptured   float64              `json:"recorded_captured"`
+	GatewayCaptured    float64              `json:"gateway_captured"`
+	CreatedAt          string               `json:"created_at"`
+	Discrepancies      []PaymentDiscrepancy `json:"discrepancies,omitempty"`
+}
+
+// GeneratePaymentDiscrepancyReport compares payments touched in the last 24 hours (and any
+// still in flight) with the gateway's transaction list for the same period, in both
+// directions, and stores the day's report, replacing an earlier run for the same date.
+// Admins are notified when discrepancies are found.
+//
+//encore:api private
+func GeneratePaymentDiscrepancyReport(ctx context.Context) (*ReconciliationReport, error) {
+	windowEnd := time.Now().UTC()
+	windowStart := windowEnd.Add(-24 * time.Hour)
+
+	rows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT id, invoice_id, gateway, gateway_ref, status::text,
+		       COALESCE(amount_captured,0), COALESCE(amount_refunded,0), created_at
+		FROM payments
+		WHERE gateway_ref <> ''
+		  AND (updated_at >= $1 OR created_at >= $1 OR status IN ('initiated','pending'))
+		ORDER BY id ASC
+	`, windowStart)
+	if err != nil {
+		logger.LogError(ctx, err, "reconcile report: list payments failed", nil)
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة المدفوعات"}
+	}
+	type recorded struct {
+		paymentID, invoiceID        int64
+		gateway, gatewayRef, status string
+		captured, refunded          float64
+		createdAt                   time.Time
+	}
+	var recs []recorded
+	for rows.Next() {
+		var r recorded
+		if err := rows.Scan(&r.paymentID, &r.invoiceID, &r.gateway, &r.gatewayRef, &r.status, &r.captured, &r.refunded, &r.createdAt); err != nil {
+			continue
+		}
+		recs = append(recs, r)
+	}
+	rows.Close()
+
+	report := &ReconciliationReport{
+		ReportDate:  windowEnd.Format("2006-01-02"),
+		WindowStart: windowStart.Format(time.RFC3339),
+		WindowEnd:   windowEnd.Format(time.RFC3339),
+	}
+
+	// List each gateway's transactions once, far enough back to cover the oldest payment
+	// being checked, instead of fetching payments one by one
+	listFrom := windowStart
+	gatewayNames := map[string]bool{}
+	if p, err := payments.Configured(); err == nil {
+		gatewayNames[p.Name()] = true
+	}
+	for _, r := range recs {
+		if r.createdAt.Before(listFrom) {
+			listFrom = r.createdAt
+		}
+		if p, err := payments.Get(r.gateway); err == nil {
+			gatewayNames[p.Name()] = true
+		}
+	}
+	listFrom = listFrom.Add(-reconcileListSkew)
+	listed := map[string]*gatewayListing{}
+	for name := range gatewayNames {
+		provider, err := payments.Get(name)
+		if err != nil {
+			continue
+		}
+		gl, err := listGatewayPayments(ctx, provider, listFrom, windowEnd)
+		if err != nil {
+			report.FetchErrors++
+			logger.LogError(ctx, err, "reconcile report: list gateway payments failed", logger.Fields{"gateway": name})
+			continue
+		}
+		listed[name] = gl
+	}
+
+	// Recorded side: every payment must match a gateway transaction
+	for _, r := range recs {
+		report.PaymentsChecked++
+		report.RecordedCaptured += r.captured
+		d := PaymentDiscrepancy{
+			PaymentID:        r.paymentID,
+			InvoiceID:        r.invoiceID,
+			Gateway:          r.gateway,
+			GatewayRef:       r.gatewayRef,
+			RecordedStatus:   r.status,
+			RecordedCaptured: r.captured,
+			RecordedRefunded: r.refunded,
+		}
+		provider, err := payments.Get(r.gateway)
+		if err != nil {
+			report.FetchErrors++
+			continue
+		}
+		gl, ok := listed[provider.Name()]
+		if !ok {
+			// Listing failed (already counted): do not report the payment as missing
+			continue
+		}
+		if st := gl.byRef[r.gatewayRef]; st != nil {
+			gl.matched[st] = true
+			d.GatewayStatus = strings.ToLower(st.Status)
+			d.GatewayCaptured = float64(st.Captured) / 100.0
+			d.GatewayRefunded = float64(st.Refunded) / 100.0
+			report.GatewayCaptured += d.GatewayCaptured
+		}
+		for _, kind := range classifyDiscrepancies(r.status, d.GatewayStatus, r.captured, d.GatewayCaptured, r.refunded, d.GatewayRefunded) {
+			d.Kind = kind
+			report.Discrepancies = append(report.Discrepancies, d)
+		}
+	}
+
+	// Gateway side: transactions no payment row points at
+	names := make([]string, 0, len(listed))
+	for name := range listed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		gl := listed[name]
+		unmatched, err := gl.unmatchedInDB(ctx)
+		if err != nil {
+			report.FetchErrors++
+			logger.LogError(ctx, err, "reconcile report: match gateway payments failed", logger.Fields{"gateway": name})
+			continue
+		}
+		for _, st := range gl.items {
+			if !unmatched[st] {
+				continue
+			}
+			d := PaymentDiscrepancy{
+				InvoiceID:       st.InvoiceID,
+				Gateway:         name,
+				GatewayRef:      st.GatewayRef,
+				GatewayStatus:   strings.ToLower(st.Status),
+				GatewayCaptured: float64(st.Captured) / 100.0,
+				GatewayRefunded: float64(st.Refunded) / 100.0,
+			}
+			report.GatewayCaptured += d.GatewayCaptured
+			for _, kind := range classifyDiscrepancies("", d.GatewayStatus, 0, d.GatewayCaptured, 0, d.GatewayRefunded) {
+				d.Kind = kind
+				report.Discrepancies = append(report.Discrepancies, d)
+			}
+		}
+	}
+	report.DiscrepanciesCount = len(report.Discrepancies)
+
+	err = withTx(ctx, func(tx *sql.Tx) error {
+		var createdAt time.Time
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO payment_reconciliation_reports
+				(report_date, window_start, window_end, payments_checked, fetch_errors, discrepancies_count, recorded_captured, gateway_captured)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+			ON CONFLICT (report_date) DO UPDATE SET
+				window_start=EXCLUDED.window_start,
+				window_end=EXCLUDED.window_end,
+				payments_checked=EXCLUDED.payments_checked,
+				fetch_errors=EXCLUDED.fetch_errors,
+				discrepancies_count=EXCLUDED.discrepancies_count,
+				recorded_captured=EXCLUDED.recorded_captured,
+				gateway_captured=EXCLUDED.gateway_captured
+			RETURNING id, created_at
+		`, report.ReportDate, windowStart, windowEnd, report.PaymentsChecked, report.FetchErrors, report.DiscrepanciesCount,
+			roundSAR(report.RecordedCaptured), roundSAR(report.GatewayCaptured)).Scan(&report.ID, &createdAt); err != nil {
+			return err
+		}
+		report.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		if _, err := tx.ExecContext(ctx, `DELETE FROM payment_discrepancies WHERE report_id=$1`, report.ID); err != nil {
+			return err
+		}
+		for i := range report.Discrepancies {
+			d := &report.Discrepancies[i]
+			if err := tx.QueryRowContext(ctx, `
+				INSERT INTO payment_discrepancies
+					(report_id, payment_id, invoice_id, kind, gateway, gateway_ref, recorded_status, gateway_status,
+					 recorded_captured, gateway_captured, recorded_refunded, gateway_refunded)
+				VALUES ($1,NULLIF($2,0),(SELECT id FROM invoices WHERE id=NULLIF($3,0)),$4::payment_discrepancy_kind,$5,$6,$7,$8,$9,$10,$11,$12)
+				RETURNING id
+			`, report.ID, d.PaymentID, d.InvoiceID, d.Kind, d.Gateway, d.GatewayRef, d.RecordedStatus, d.GatewayStatus,
+				d.RecordedCaptured, d.GatewayCaptured, d.RecordedRefunded, d.GatewayRefunded).Scan(&d.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.LogError(ctx, err, "reconcile report: save failed", logger.Fields{"report_date": report.ReportDate})
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر حفظ تقرير المطابقة"}
+	}
+
+	report.RecordedCaptured = roundSAR(report.RecordedCaptured)
+	report.GatewayCaptured = roundSAR(report.GatewayCaptured)
+	if report.DiscrepanciesCount > 0 {
+		notifyAdminsOfDiscrepancies(ctx, report)
+	}
+	return report, nil
+}
+
+// classifyDiscrepancies compares a recorded payment with the gateway's view (amounts in SAR).
+// An empty recordedStatus means the gateway transaction has no payment row; an empty
+// gatewayStatus means the gateway's listing has no transaction for the payment.
+func classifyDiscrepancies(recordedStatus, gatewayStatus string, recordedCaptured, gatewayCaptured, recordedRefunded, gatewayRefunded float64) []string {
+	gatewayCharged := gatewayCaptured > 0 || gatewayStatus == "paid" || gatewayStatus == "captured" || gatewayStatus == "refunded"
+	recordedCharged := recordedStatus == "paid" || recordedStatus == "refunded"
+
+	switch {
+	case recordedStatus == "":
+		if gatewayCharged {
+			return []string{"missing_in_db"}
+		}
+		return nil
+	case gatewayStatus == "":
+		if recordedCharged {
+			return []string{"missing_at_gateway"}
+		}
+		return nil
+	}
+
+	var kinds []string
+	switch {
+	case gatewayCharged && !recordedCharged:
+		// Customer was charged but we never recorded the payment as paid
+		kinds = append(kinds, "orphaned_gateway_payment")
+	case recordedCharged && !gatewayCharged:
+		kinds = append(kinds, "status_mismatch")
+	case recordedCharged && math.Abs(recordedCaptured-gatewayCaptured) >= 0.01:
+		kinds = append(kinds, "amount_mismatch")
+	}
+	if math.Abs(recordedRefunded-gatewayRefunded) >= 0.01 {
+		kinds = append(kinds, "refund_mismatch")
+	}
+	return kinds
+}
+
+func roundSAR(v float64) float64 { return math.Round(v*100) / 100 }
+
+const (
+	// reconcileListSkew widens the gateway listing for clock differences with the gateway
+	reconcileListSkew = time.Hour
+	// reconcileMaxListPages bounds one gateway listing so a runaway pager cannot stall the job
+	reconcileMaxListPages = 500
+)
+
+// gatewayListing is one gateway's transactions for the report period
+type gatewayListing struct {
+	items   []*payments.PaymentStatus
+	byRef   map[string]*payments.PaymentStatus // every reference a transaction is known by
+	matched map[*payments.PaymentStatus]bool   // transactions a checked payment points at
+}
+
+// listGatewayPayments pages through the gateway's transactions created between from and to
+func listGatewayPayments(ctx context.Context, provider payments.Provider, from, to time.Time) (*gatewayListing, error) {
+	gl := &gatewayListing{
+		byRef:   map[string]*payments.PaymentStatus{},
+		matched: map[*payments.PaymentStatus]bool{},
+	}
+	for page, n := 1, 0; page != 0; n++ {
+		if n == reconcileMaxListPages {
+			return nil, fmt.Errorf("gateway listing exceeded %d pages", reconcileMaxListPages)
+		}
+		list, err := provider.ListPayments(ctx, from, to, page)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			st := &list.Items[i]
+			gl.items = append(gl.items, st)
+			gl.byRef[st.GatewayRef] = st
+			for _, ref := range st.OtherRefs {
+				gl.byRef[ref] = st
+			}
+		}
+		page = list.NextPage
+	}
+	return gl, nil
+}
+
+// unmatchedInDB returns the listed transactions that no payment row references. Those not
+// matched by a checked payment are looked up in one query, since the listing reaches back
+// past the report window.
+func (gl *gatewayListing) unmatchedInDB(ctx context.Context) (map[*payments.PaymentStatus]bool, error) {
+	var refs []string
+	for _, st := range gl.items {
+		if !gl.matched[st] {
+			refs = append(refs, st.GatewayRef)
+			refs = append(refs, st.OtherRefs...)
+		}
+	}
+	out := map[*payments.PaymentStatus]bool{}
+	if len(refs) == 0 {
+		return out, nil
+	}
+	rows, err := db.Stdlib().QueryContext(ctx, `SELECT gateway_ref FROM payments WHERE gateway_ref = ANY($1::text[])`, refs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	known := map[*payments.PaymentStatus]bool{}
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, err
+		}
+		if st := gl.byRef[ref]; st != nil {
+			known[st] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, st := range gl.items {
+		if !gl.matched[st] && !known[st] {
+			out[st] = true
+		}
+	}
+	return out, nil
+}
+
+// notifyAdminsOfDiscrepancies sends an internal notification with the report summary to all admins
+func notifyAdminsOfDiscrepancies(ctx context.Context, report *ReconciliationReport) {
+	rows, err := db.Stdlib().QueryContext(ctx, `SELECT id FROM users WHERE role = 'admin' AND state = 'active'`)
+	if err != nil {
+		logger.LogError(ctx, err, "reconcile report: list admins failed", nil)
+		return
+	}
+	defer rows.Close()
+
+	payload := map[string]any{
+		"report_id":           report.ID,
+		"report_date":         report.ReportDate,
+		"payments_checked":    report.PaymentsChecked,
+		"discrepancies_count": report.DiscrepanciesCount,
+		"recorded_captured":   report.RecordedCaptured,
+		"gateway_captured":    report.GatewayCaptured,
+		"language":            "ar",
+	}
+	for rows.Next() {
+		var adminID int64
+		if err := rows.Scan(&adminID); err != nil {
+			continue
+		}
+		if _, err := notifications.EnqueueInternal(ctx, adminID, "payment_reconciliation_report", payload); err != nil {
+			logger.LogError(ctx, err, "reconcile report: notify admin failed", logger.Fields{"admin_id": adminID})
+		}
+	}
+}
+
+// ===== Admin endpoints =====
+
+// requireAdmin checks that the caller is an authenticated admin user and returns their id
+func requireAdmin(ctx context.Context) (int64, error) {
+	uidStr, ok := auth.UserID()
+	if !ok {
+		return 0, &errs.Error{Code: errs.Unauthenticated, Message: "مطلوب تسجيل الدخول"}
+	}
+	uid, _ := strconv.ParseInt(string(uidStr), 10, 64)
+	var role string
+	_ = db.Stdlib().QueryRowContext(ctx, `SELECT role::text FROM users WHERE id=$1`, uid).Scan(&role)
+	if strings.ToLower(role) != "admin" {
+		return 0, &errs.Error{Code: errs.Forbidden, Message: "يتطلب صلاحيات مدير"}
+	}
+	return uid, nil
+}
+
+// AdminReconcilePayments runs payment reconciliation immediately
+//
+//encore:api auth method=POST path=/admin/payments/reconcile
+func AdminReconcilePayments(ctx context.Context) (*ReconcileResponse, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return ReconcilePayments(ctx)
+}
+
+type ListReconciliationReportsParams struct {
+	Limit int `query:"limit"`
+}
+
+type ListReconciliationReportsResponse struct {
+	Reports []ReconciliationReport `json:"reports"`
+}
+
+// AdminListReconciliationReports returns the most recent daily reports (without items)
+//
+//encore:api auth method=GET path=/admin/payments/reconciliation/reports
+func AdminListReconciliationReports(ctx context.Context, params *ListReconciliationReportsParams) (*ListReconciliationReportsResponse, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	limit := 30
+	if params != nil && params.Limit > 0 && params.Limit <= 365 {
+		limit = params.Limit
+	}
+	rows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT id, to_char(report_date, 'YYYY-MM-DD'), window_start, window_end, payments_checked, fetch_errors,
+		       discrepancies_count, recorded_captured, gateway_captured, created_at
+		FROM payment_reconciliation_reports
+		ORDER BY report_date DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة تقارير المطابقة"}
+	}
+	defer rows.Close()
+
+	out := &ListReconciliationReportsResponse{Reports: []ReconciliationReport{}}
+	for rows.Next() {
+		r, err := scanReconciliationReport(rows)
+		if err != nil {
+			return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة تقارير المطابقة"}
+		}
+		out.Reports = append(out.Reports, *r)
+	}
+	return out, nil
+}
+
+// AdminGetReconciliationReport returns a daily report with its discrepancies
+//
+//encore:api auth method=GET path=/admin/payments/reconciliation/reports/:id
+func AdminGetReconciliationReport(ctx context.Context, id int64) (*ReconciliationReport, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	report, err := scanReconciliationReport(db.Stdlib().QueryRowContext(ctx, `
+		SELECT id, to_char(report_date, 'YYYY-MM-DD'), window_start, window_end, payments_checked, fetch_errors,
+		       discrepancies_count, recorded_captured, gateway_captured, created_at
+		FROM payment_reconciliation_reports
+		WHERE id=$1
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, &errs.Error{Code: errs.NotFound, Message: "التقرير غير موجود"}
+	}
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة تقرير المطابقة"}
+	}
+
+	rows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT id, payment_id, invoice_id, kind::text, gateway, gateway_ref, recorded_status, gateway_status,
+		       recorded_captured, gateway_captured, recorded_refunded, gateway_refunded
+		FROM payment_discrepancies
+		WHERE report_id=$1
+		ORDER BY id ASC
+	`, id)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة الفروقات"}
+	}
+	defer rows.Close()
+	report.Discrepancies = []PaymentDiscrepancy{}
+	for rows.Next() {
+		var d PaymentDiscrepancy
+		var paymentID, invoiceID sql.NullInt64
+		if err := rows.Scan(&d.ID, &paymentID, &invoiceID, &d.Kind, &d.Gateway, &d.GatewayRef, &d.RecordedStatus, &d.GatewayStatus,
+			&d.RecordedCaptured, &d.GatewayCaptured, &d.RecordedRefunded, &d.GatewayRefunded); err != nil {
+			return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة الفروقات"}
+		}
+		d.PaymentID, d.InvoiceID = paymentID.Int64, invoiceID.Int64
+		report.Discrepancies = append(report.Discrepancies, d)
+	}
+	return report, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReconciliationReport(row rowScanner) (*ReconciliationReport, error) {
+	var r ReconciliationReport
+	var windowStart, windowEnd, createdAt time.Time
+	if err := row.Scan(&r.ID, &r.ReportDate, &windowStart, &windowEnd, &r.PaymentsChecked, &r.FetchErrors,
+		&r.DiscrepanciesCount, &r.RecordedCaptured, &r.GatewayCaptured, &createdAt); err != nil {
+		return nil, err
+	}
+	r.WindowStart = windowStart.UTC().Format(time.RFC3339)
+	r.WindowEnd = windowEnd.UTC().Format(time.RFC3339)
+	r.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	return &r, nil
+}
+
+// reconcileBeforeExpiry gives sessions that are about to be failed one last gateway check so
+// a charged customer is not left with a failed invoice
+func reconcileBeforeExpiry(ctx context.Context, ttlMinutes int) {
+	res, err := reconcileInFlight(ctx, `
+		i.status='payment_in_progress'
+		AND (i.totals->>'pay_started_at') ~ '^[0-9]{4}-[0-9]{2}-[0-9]{2}T'
+		AND (CURRENT_TIMESTAMP AT TIME ZONE 'UTC') > ((i.totals->>'pay_started_at')::timestamptz + make_interval(mins => $1))`, ttlMinutes)
+	if err != nil || res.Checked == 0 {
+		return
+	}
+	logger.Info(ctx, "reconciled expiring payment sessions", logger.Fields{
+		"checked": res.Checked,
+		"updated": res.Updated,
+		"errors":  res.Errors,
+	})
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyDiscrepancies(t *testing.T) {
+	cases := []struct {
+		name                     string
+		recordedStatus, gwStatus string
+		recCaptured, gwCaptured  float64
+		recRefunded, gwRefunded  float64
+		want                     []string
+	}{
+		{"in sync", "paid", "paid", 115, 115, 0, 0, nil},
+		{"lost webhook", "failed", "paid", 0, 115, 0, 0, []string{"orphaned_gateway_payment"}},
+		{"still initiated but charged", "initiated", "captured", 0, 50, 0, 0, []string{"orphaned_gateway_payment"}},
+		{"recorded paid, gateway failed", "paid", "failed", 115, 0, 0, 0, []string{"status_mismatch"}},
+		{"partial capture", "paid", "paid", 115, 100, 0, 0, []string{"amount_mismatch"}},
+		{"refund not recorded", "paid", "refunded", 115, 115, 0, 115, []string{"refund_mismatch"}},
+		{"rounding noise", "paid", "paid", 115.001, 115, 0, 0, nil},
+		{"both unpaid", "failed", "failed", 0, 0, 0, 0, nil},
+		{"charged at gateway, no payment row", "", "paid", 0, 115, 0, 0, []string{"missing_in_db"}},
+		{"abandoned gateway session, no payment row", "", "initiated", 0, 0, 0, 0, nil},
+		{"recorded paid, not in gateway listing", "paid", "", 115, 0, 0, 0, []string{"missing_at_gateway"}},
+		{"in flight, not in gateway listing", "initiated", "", 0, 0, 0, 0, nil},
+	}
+	for _, tc := range cases {
+		got := classifyDiscrepancies(tc.recordedStatus, tc.gwStatus, tc.recCaptured, tc.gwCaptured, tc.recRefunded, tc.gwRefunded)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
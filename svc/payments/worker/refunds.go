@@ -0,0 +1,390 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/logger"
+	"encore.app/pkg/payments"
+	"encore.app/svc/notifications"
+)
+
+// RefundItemRequest refunds qty units of an order item
+type RefundItemRequest struct {
+	OrderItemID int64 `json:"order_item_id"`
+	Qty         int   `json:"qty"`
+}
+
+type RefundRequest struct {
+	// Amount in SAR; may be omitted when Items are given (defaults to the items' value)
+	Amount  float64             `json:"amount"`
+	Reason  string              `json:"reason"`
+	Items   []RefundItemRequest `json:"items,omitempty"`
+	Restock bool                `json:"restock"` // return refunded supply quantities to stock
+}
+
+type RefundResponse struct {
+	RefundID         int64   `json:"refund_id"`
+	PaymentID        int64   `json:"payment_id"`
+	InvoiceID        int64   `json:"invoice_id"`
+	Refunded         float64 `json:"refunded"`
+	TotalRefunded    float64 `json:"total_refunded"`
+	Captured         float64 `json:"captured"`
+	RefundPartial    bool    `json:"refund_partial"`
+	PaymentStatus    string  `json:"payment_status"`
+	InvoiceStatus    string  `json:"invoice_status"`
+	GatewayRefundID  string  `json:"gateway_refund_id,omitempty"`
+	CreditNoteNumber string  `json:"credit_note_number"`
+}
+
+// refundLine is a validated item line of a refund
+type refundLine struct {
+	orderItemID, productID int64
+	productType            string
+	qty                    int
+	amount                 float64
+}
+
+//encore:api auth method=POST path=/admin/payments/:id/refund
+func AdminRefundPayment(ctx context.Context, id int64, req *RefundRequest) (*RefundResponse, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || req.Amount < 0 || (req.Amount == 0 && len(req.Items) == 0) {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "قيمة الاسترداد غير صالحة"}
+	}
+	seen := make(map[int64]bool, len(req.Items))
+	for _, it := range req.Items {
+		if it.OrderItemID <= 0 || it.Qty <= 0 || seen[it.OrderItemID] {
+			return nil, &errs.Error{Code: errs.InvalidArgument, Message: "عناصر الاسترداد غير صالحة"}
+		}
+		seen[it.OrderItemID] = true
+	}
+	reason := strings.TrimSpace(req.Reason)
+
+	// The refund is recorded in three steps so money never leaves without a ledger entry: the
+	// pending refund is committed first, the gateway is called outside any transaction, and
+	// the outcome is written in a second transaction. A crash in between leaves the refund
+	// pending (and its amount reserved) for an admin to reconcile.
+	var refundID, invoiceID, buyerID int64
+	var amount float64
+	var gateway, gatewayRef string
+	var lines []refundLine
+	var invoiceNumber, buyerName, buyerEmail string
+	err = withTx(ctx, func(tx *sql.Tx) error {
+		var amountCaptured, amountRefunded, amountPending float64
+		var orderID int64
+		var currency string
+		if err := tx.QueryRowContext(ctx, `SELECT invoice_id, amount_captured, amount_refunded, gateway, gateway_ref, currency FROM payments WHERE id=$1 FOR UPDATE`, id).Scan(&invoiceID, &amountCaptured, &amountRefunded, &gateway, &gatewayRef, &currency); err != nil {
+			if err == sql.ErrNoRows {
+				return &errs.Error{Code: "PAY_NOT_FOUND", Message: "الدفع غير موجود"}
+			}
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, `
+			SELECT i.number, i.order_id, o.user_id, COALESCE(u.name,''), COALESCE(u.email,'')
+			FROM invoices i JOIN orders o ON o.id=i.order_id JOIN users u ON u.id=o.user_id
+			WHERE i.id=$1
+		`, invoiceID).Scan(&invoiceNumber, &orderID, &buyerID, &buyerName, &buyerEmail); err != nil {
+			return err
+		}
+		// Refunds still pending at the gateway are not in amount_refunded yet
+		if err := tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount),0) FROM refunds WHERE payment_id=$1 AND status='pending'`, id).Scan(&amountPending); err != nil {
+			return err
+		}
+
+		// Validate item lines against the order and what was already refunded
+		var itemsTotal float64
+		for _, it := range req.Items {
+			var l refundLine
+			var ordered, alreadyRefunded int
+			var unit float64
+			err := tx.QueryRowContext(ctx, `
+				SELECT oi.product_id, p.type::text, oi.qty, oi.unit_price_gross,
+				       COALESCE((SELECT SUM(ri.qty) FROM refund_items ri JOIN refunds r ON r.id=ri.refund_id
+				                 WHERE ri.order_item_id=oi.id AND r.status<>'failed'), 0)
+				FROM order_items oi JOIN products p ON p.id=oi.product_id
+				WHERE oi.id=$1 AND oi.order_id=$2
+			`, it.OrderItemID, orderID).Scan(&l.productID, &l.productType, &ordered, &unit, &alreadyRefunded)
+			if err == sql.ErrNoRows {
+				return &errs.Error{Code: errs.InvalidArgument, Message: "عنصر الاسترداد لا ينتمي لهذا الطلب"}
+			}
+			if err != nil {
+				return err
+			}
+			if it.Qty > ordered-alreadyRefunded {
+				return &errs.Error{Code: errs.InvalidArgument, Message: "الكمية تتجاوز الكمية القابلة للاسترداد"}
+			}
+			l.orderItemID, l.qty = it.OrderItemID, it.Qty
+			l.amount = roundSAR(unit * float64(it.Qty))
+			itemsTotal += l.amount
+			lines = append(lines, l)
+		}
+
+		amount = req.Amount
+		if len(lines) > 0 {
+			// The credit note and refund_items must add up to what is refunded
+			if amount != 0 && math.Abs(amount-roundSAR(itemsTotal)) >= 0.005 {
+				return &errs.Error{Code: errs.InvalidArgument, Message: "المبلغ لا يطابق قيمة العناصر المستردة"}
+			}
+			amount = roundSAR(itemsTotal)
+		}
+		remaining := amountCaptured - amountRefunded - amountPending
+		if remaining <= 0 {
+			return &errs.Error{Code: errs.Conflict, Message: "لا يوجد مبلغ متبقٍ للاسترداد"}
+		}
+		if amount <= 0 {
+			return &errs.Error{Code: errs.InvalidArgument, Message: "قيمة الاسترداد غير صالحة"}
+		}
+		if amount > remaining+1e-9 {
+			return &errs.Error{Code: errs.InvalidArgument, Message: "المبلغ يتجاوز المبلغ القابل للاسترداد"}
+		}
+
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO refunds (payment_id, invoice_id, amount, currency, reason, actor_user_id, gateway, status, restock)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,'pending',$8)
+			RETURNING id
+		`, id, invoiceID, amount, currency, reason, actorID, gateway, req.Restock).Scan(&refundID); err != nil {
+			return err
+		}
+		for _, l := range lines {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO refund_items (refund_id, order_item_id, qty, amount) VALUES ($1,$2,$3,$4)`, refundID, l.orderItemID, l.qty, l.amount); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok {
+			return nil, e
+		}
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر تنفيذ الاسترداد"}
+	}
+
+	// Refund through the gateway that captured the payment (amount in halalas)
+	failRefund := func(cause error) {
+		if _, err := db.Stdlib().ExecContext(ctx, `UPDATE refunds SET status='failed', failure_reason=$1 WHERE id=$2`, cause.Error(), refundID); err != nil {
+			logger.LogError(ctx, err, "failed to mark refund as failed", logger.Fields{"refund_id": refundID})
+		}
+	}
+	provider, err := payments.Get(gateway)
+	if err != nil {
+		failRefund(err)
+		return nil, &errs.Error{Code: errs.ServiceUnavailable, Message: "مزود الدفع غير متاح"}
+	}
+	result, err := provider.Refund(ctx, gatewayRef, int64(amount*100.0+0.5))
+	if err != nil {
+		logger.LogError(ctx, err, "payment refund failed", logger.Fields{
+			"payment_id": id,
+			"refund_id":  refundID,
+			"gateway":    gateway,
+		})
+		failRefund(err)
+		return nil, &errs.Error{Code: errs.ServiceUnavailable, Message: "تعذر تنفيذ الاسترداد مع مزود الدفع"}
+	}
+
+	var resp RefundResponse
+	err = withTx(ctx, func(tx *sql.Tx) error {
+		var amountCaptured, amountRefunded float64
+		var payStatus string
+		if err := tx.QueryRowContext(ctx, `SELECT amount_captured, amount_refunded, status::text FROM payments WHERE id=$1 FOR UPDATE`, id).Scan(&amountCaptured, &amountRefunded, &payStatus); err != nil {
+			return err
+		}
+
+		// Credit note numbered against the original invoice: INV-YYYY-NNNNNN-CN-01, -02, ...
+		var issued int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM refunds WHERE invoice_id=$1 AND credit_note_number IS NOT NULL`, invoiceID).Scan(&issued); err != nil {
+			return err
+		}
+		creditNote := fmt.Sprintf("%s-CN-%02d", invoiceNumber, issued+1)
+		if _, err := tx.ExecContext(ctx, `UPDATE refunds SET status='succeeded', gateway_refund_id=NULLIF($1,''), credit_note_number=$2 WHERE id=$3`, result.GatewayRefundID, creditNote, refundID); err != nil {
+			return err
+		}
+
+		if req.Restock {
+			for _, l := range lines {
+				if l.productType != "supply" {
+					continue
+				}
+				if _, err := tx.ExecContext(ctx, `UPDATE supplies SET stock_qty = stock_qty + $1, updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE product_id=$2`, l.qty, l.productID); err != nil {
+					return err
+				}
+				if _, err := tx.ExecContext(ctx, `UPDATE refund_items SET restocked=TRUE WHERE refund_id=$1 AND order_item_id=$2`, refundID, l.orderItemID); err != nil {
+					return err
+				}
+			}
+		}
+
+		newTotal := amountRefunded + amount
+		refundPartial := newTotal < amountCaptured-1e-9
+		newPayStatus := payStatus
+		if !refundPartial {
+			newPayStatus = "refunded"
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE payments SET amount_refunded=$1, refund_partial=$2, status=CASE WHEN $2 THEN status ELSE 'refunded' END, updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$3`, newTotal, refundPartial, id); err != nil {
+			return err
+		}
+
+		var invStatus string
+		if refundPartial {
+			if _, err := tx.ExecContext(ctx, `UPDATE invoices SET status='refund_required', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$1`, invoiceID); err != nil {
+				return err
+			}
+			invStatus = "refund_required"
+		} else {
+			if _, err := tx.ExecContext(ctx, `UPDATE invoices SET status='refunded', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$1`, invoiceID); err != nil {
+				return err
+			}
+			invStatus = "refunded"
+		}
+
+		resp = RefundResponse{
+			RefundID:         refundID,
+			PaymentID:        id,
+			InvoiceID:        invoiceID,
+			Refunded:         amount,
+			TotalRefunded:    newTotal,
+			Captured:         amountCaptured,
+			RefundPartial:    refundPartial,
+			PaymentStatus:    newPayStatus,
+			InvoiceStatus:    invStatus,
+			GatewayRefundID:  result.GatewayRefundID,
+			CreditNoteNumber: creditNote,
+		}
+		return nil
+	})
+	if err != nil {
+		// The gateway has paid out: the refund stays pending in the ledger for reconciliation
+		logger.LogError(ctx, err, "failed to record a completed refund", logger.Fields{
+			"payment_id":        id,
+			"refund_id":         refundID,
+			"gateway_refund_id": result.GatewayRefundID,
+		})
+		return nil, &errs.Error{Code: errs.Internal, Message: "تم الاسترداد لدى مزود الدفع وتعذر تسجيله، يرجى مراجعة سجل الاسترداد"}
+	}
+
+	// Let the customer know a credit note was issued (best-effort)
+	payload := map[string]any{
+		"invoice_id":         resp.InvoiceID,
+		"invoice_number":     invoiceNumber,
+		"credit_note_number": resp.CreditNoteNumber,
+		"amount":             fmt.Sprintf("%.2f", resp.Refunded),
+		"reason":             reason,
+		"name":               buyerName,
+		"email":              buyerEmail,
+		"language":           "ar",
+	}
+	if _, err := notifications.EnqueueInternal(ctx, buyerID, "refund_issued", payload); err != nil {
+		logger.LogError(ctx, err, "refund notification failed", logger.Fields{"refund_id": resp.RefundID})
+	}
+	if _, err := notifications.EnqueueEmail(ctx, buyerID, "refund_issued", payload); err != nil {
+		logger.LogError(ctx, err, "refund email failed", logger.Fields{"refund_id": resp.RefundID})
+	}
+	return &resp, nil
+}
+
+// RefundItemDTO is an item line of a refund
+type RefundItemDTO struct {
+	OrderItemID int64   `json:"order_item_id"`
+	ProductID   int64   `json:"product_id"`
+	Title       string  `json:"title"`
+	Qty         int     `json:"qty"`
+	Amount      float64 `json:"amount"`
+	Restocked   bool    `json:"restocked"`
+}
+
+// RefundDTO is a refund ledger entry
+type RefundDTO struct {
+	ID               int64           `json:"id"`
+	PaymentID        int64           `json:"payment_id"`
+	InvoiceID        int64           `json:"invoice_id"`
+	Amount           float64         `json:"amount"`
+	Currency         string          `json:"currency"`
+	Reason           string          `json:"reason"`
+	ActorUserID      *int64          `json:"actor_user_id,omitempty"`
+	Gateway          string          `json:"gateway"`
+	GatewayRefundID  string          `json:"gateway_refund_id,omitempty"`
+	Status           string          `json:"status"`
+	FailureReason    string          `json:"failure_reason,omitempty"`
+	Restock          bool            `json:"restock"`
+	CreditNoteNumber string          `json:"credit_note_number,omitempty"`
+	CreatedAt        string          `json:"created_at"`
+	Items            []RefundItemDTO `json:"items"`
+}
+
+type ListRefundsResponse struct {
+	Items []RefundDTO `json:"items"`
+}
+
+// AdminListPaymentRefunds returns the refund ledger of a payment, newest first
+//
+//encore:api auth method=GET path=/admin/payments/:id/refunds
+func AdminListPaymentRefunds(ctx context.Context, id int64) (*ListRefundsResponse, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT id, payment_id, invoice_id, amount, currency, reason, actor_user_id, gateway,
+		       COALESCE(gateway_refund_id,''), status::text, COALESCE(failure_reason,''), restock,
+		       COALESCE(credit_note_number,''), created_at
+		FROM refunds
+		WHERE payment_id=$1
+		ORDER BY created_at DESC, id DESC
+	`, id)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة سجل الاسترداد"}
+	}
+	defer rows.Close()
+
+	out := &ListRefundsResponse{Items: []RefundDTO{}}
+	byID := map[int64]int{}
+	for rows.Next() {
+		var r RefundDTO
+		var actor sql.NullInt64
+		var createdAt time.Time
+		if err := rows.Scan(&r.ID, &r.PaymentID, &r.InvoiceID, &r.Amount, &r.Currency, &r.Reason, &actor, &r.Gateway,
+			&r.GatewayRefundID, &r.Status, &r.FailureReason, &r.Restock, &r.CreditNoteNumber, &createdAt); err != nil {
+			return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة سجل الاسترداد"}
+		}
+		if actor.Valid {
+			r.ActorUserID = &actor.Int64
+		}
+		r.Currency = strings.TrimSpace(r.Currency)
+		r.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		r.Items = []RefundItemDTO{}
+		byID[r.ID] = len(out.Items)
+		out.Items = append(out.Items, r)
+	}
+	rows.Close()
+
+	itemRows, err := db.Stdlib().QueryContext(ctx, `
+		SELECT ri.refund_id, ri.order_item_id, oi.product_id, p.title, ri.qty, ri.amount, ri.restocked
+		FROM refund_items ri
+		JOIN refunds r ON r.id=ri.refund_id
+		JOIN order_items oi ON oi.id=ri.order_item_id
+		JOIN products p ON p.id=oi.product_id
+		WHERE r.payment_id=$1
+		ORDER BY ri.id ASC
+	`, id)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة عناصر الاسترداد"}
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var refundID int64
+		var it RefundItemDTO
+		if err := itemRows.Scan(&refundID, &it.OrderItemID, &it.ProductID, &it.Title, &it.Qty, &it.Amount, &it.Restocked); err != nil {
+			return nil, &errs.Error{Code: errs.Internal, Message: "تعذر قراءة عناصر الاسترداد"}
+		}
+		if i, ok := byID[refundID]; ok {
+			out.Items[i].Items = append(out.Items[i].Items, it)
+		}
+	}
+	return out, nil
+}
@@ -1,10 +1,9 @@
 package worker
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -20,11 +19,11 @@ import (
 	"encore.app/pkg/config"
 	"encore.app/pkg/errs"
 	"encore.app/pkg/logger"
-	"encore.app/pkg/mailer"
 	"encore.app/pkg/metrics"
-	"encore.app/pkg/moyasar"
+	"encore.app/pkg/outbox"
+	"encore.app/pkg/payments"
 	"encore.app/pkg/ratelimit"
-	"encore.app/pkg/templates"
+	"encore.app/pkg/stockhold"
 	"encore.app/svc/notifications"
 )
 
@@ -102,7 +101,10 @@ func initService() (*Service, error) {
 	return &Service{}, nil
 }
 
-var paymentsRL = ratelimit.NewRateLimiter(ratelimit.RateLimitConfig{MaxAttempts: 5, Window: time.Minute})
+// paymentsRL limits payment initialisation per user (ratelimit.payments_init.* settings)
+var paymentsRL = ratelimit.NewSharedRateLimiter("payments_init",
+	ratelimit.RateLimitConfig{MaxAttempts: 5, Window: time.Minute},
+	ratelimit.NewPostgresStorage(db), ratelimit.SettingsProvider(db))
 
 var allowedMethods = map[string]bool{
 	"mada":        true,
@@ -144,7 +146,7 @@ func InitPayment(ctx context.Context, req *InitRequest) (*InitResponse, error) {
 		return nil, &errs.Error{Code: errs.PayMethodDisabled, Message: "طريقة الدفع غير مفعّلة"}
 	}
 
-	// Rate limit 5/min per user
+	// Rate limit per user (5/min by default)
 	uid, _ := strconv.ParseInt(string(uidStr), 10, 64)
 	if err := paymentsRL.RecordAttempt(ratelimit.GenerateUserKey("payments_init", uid)); err != nil {
 		return nil, &errs.Error{Code: errs.TooManyRequests, Message: "تجاوزت حد المحاولات. حاول لاحقاً"}
@@ -234,8 +236,8 @@ func InitPayment(ctx context.Context, req *InitRequest) (*InitResponse, error) {
 		}
 	}
 	// Config guards
-	cfg := config.GetSettings()
-	if cfg == nil || !cfg.PaymentsEnabled || strings.ToLower(cfg.PaymentsProvider) != "moyasar" {
+	provider, err := payments.Active()
+	if err != nil {
 		return nil, &errs.Error{Code: errs.Conflict, Message: "الدفع غير مفعّل"}
 	}
 
@@ -315,38 +317,38 @@ func InitPayment(ctx context.Context, req *InitRequest) (*InitResponse, error) {
 	// webhookURL: محلي فقط (الإنتاج مضبوط من لوحة مزوّد الدفع)
 	webhookURL := ""
 	if encore.Meta().Environment.Type == encore.EnvLocal {
-		webhookURL = "http://127.0.0.1:4000/payments/webhook/moyasar"
+		webhookURL = "http://127.0.0.1:4000/payments/webhook/" + provider.Name()
 	}
 
-	gatewayRef, sessionURL, err := moyasar.CreateInvoice(
-		halalas, currency, fmt.Sprintf("invoice:%d", req.InvoiceID),
-		callbackURL, returnURL, webhookURL,
-		map[string]string{"invoice_id": fmt.Sprint(req.InvoiceID)},
-	)
+	session, err := provider.CreateSession(ctx, &payments.SessionRequest{
+		Amount:      int64(halalas),
+		Currency:    currency,
+		Description: fmt.Sprintf("invoice:%d", req.InvoiceID),
+		SuccessURL:  callbackURL,
+		BackURL:     returnURL,
+		WebhookURL:  webhookURL,
+		Metadata:    map[string]string{"invoice_id": fmt.Sprint(req.InvoiceID)},
+	})
 	if err != nil {
-		logger.LogError(ctx, err, "moyasar create invoice failed", logger.Fields{
-			"invoice_id":       req.InvoiceID,
-			"amount_halalas":   halalas,
-			"currency":         currency,
-			"payments_enabled": config.GetSettings() != nil && config.GetSettings().PaymentsEnabled,
-			"provider": func() string {
-				if s := config.GetSettings(); s != nil {
-					return s.PaymentsProvider
-				}
-				return ""
-			}(),
+		logger.LogError(ctx, err, "payment session create failed", logger.Fields{
+			"invoice_id":     req.InvoiceID,
+			"amount_halalas": halalas,
+			"currency":       currency,
+			"provider":       provider.Name(),
 		})
 		return nil, &errs.Error{Code: errs.ServiceUnavailable, Message: "تعذر إنشاء جلسة الدفع"}
 	}
+	gatewayRef, sessionURL := session.GatewayRef, session.URL
 
-	// Create payment row (gateway=moyasar)
+	// Create payment row (gateway = provider name)
 	var paymentID int64
-	if err := db.Stdlib().QueryRowContext(ctx, `INSERT INTO payments (invoice_id, gateway, gateway_ref, status, currency, amount_authorized, raw_response) VALUES ($1,'moyasar',$2,'initiated',$3,$4,'{}') RETURNING id`, req.InvoiceID, gatewayRef, currency, float64(halalas)/100.0).Scan(&paymentID); err != nil {
+	if err := db.Stdlib().QueryRowContext(ctx, `INSERT INTO payments (invoice_id, gateway, gateway_ref, status, currency, amount_authorized, raw_response) VALUES ($1,$2,$3,'initiated',$4,$5,'{}') RETURNING id`, req.InvoiceID, provider.Name(), gatewayRef, currency, float64(halalas)/100.0).Scan(&paymentID); err != nil {
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل إنشاء سجل الدفع"}
 	}
 
 	// Update invoice to payment_in_progress and store session metadata (incl. start time)
-	nowUTC := time.Now().UTC().Format(time.RFC3339)
+	startedAt := time.Now().UTC()
+	nowUTC := startedAt.Format(time.RFC3339)
 	if _, err := db.Stdlib().ExecContext(ctx, `
 		UPDATE invoices
 		SET status='payment_in_progress',
@@ -365,20 +367,20 @@ func InitPayment(ctx context.Context, req *InitRequest) (*InitResponse, error) {
 		return nil, &errs.Error{Code: errs.Internal, Message: "فشل تحديث الفاتورة: " + err.Error()}
 	}
 
-	return &InitResponse{Status: "pending", InvoiceID: req.InvoiceID, PaymentID: paymentID, SessionURL: sessionURL}, nil
-}
+	// Keep the checkout holds for as long as the session can still be paid
+	if err := withTx(ctx, func(tx *sql.Tx) error {
+		_, err := stockhold.ExtendForSession(ctx, tx, orderID, startedAt, time.Duration(ttlMin)*time.Minute)
+		return err
+	}); err != nil {
+		logger.LogError(ctx, err, "extend stock holds for payment session failed", logger.Fields{"invoice_id": req.InvoiceID, "order_id": orderID})
+	}
 
-// Moyasar webhook payload (minimal fields we need)
-type moyasarEvent struct {
-	ID       string `json:"id"`
-	Status   string `json:"status"`
-	Amount   int64  `json:"amount"`
-	Captured int64  `json:"captured"`
-	Currency string `json:"currency"`
+	return &InitResponse{Status: "pending", InvoiceID: req.InvoiceID, PaymentID: paymentID, SessionURL: sessionURL}, nil
 }
 
 // Payment webhook event for internal processing
 type PaymentEvent struct {
+	Gateway    string `json:"gateway,omitempty"` // provider name; empty means moyasar
 	GatewayRef string `json:"gateway_ref"`
 	Status     string `json:"status"`
 	Amount     int64  `json:"amount"`
@@ -403,7 +405,7 @@ func handlePaymentEvent(ctx context.Context, evt *PaymentEvent) error {
 		"captured":    evt.Captured,
 	})
 	receivedAt, _ := time.Parse(time.RFC3339, evt.ReceivedAt)
-	err := processWebhook(ctx, evt.GatewayRef, evt.InvoiceID, strings.ToLower(evt.Status), evt.Amount, evt.Captured, evt.Currency, receivedAt)
+	err := processWebhook(ctx, evt.Gateway, evt.GatewayRef, evt.InvoiceID, strings.ToLower(evt.Status), evt.Amount, evt.Captured, evt.Currency, receivedAt)
 	if err != nil {
 		logger.LogError(ctx, err, "processWebhook failed", logger.Fields{
 			"gateway_ref": evt.GatewayRef,
@@ -418,387 +420,169 @@ func handlePaymentEvent(ctx context.Context, evt *PaymentEvent) error {
 	return err
 }
 
+// MoyasarWebhook receives Moyasar webhook deliveries
+//
 //encore:api public raw method=POST path=/payments/webhook/moyasar
 func MoyasarWebhook(w http.ResponseWriter, r *http.Request) {
-	raw, _ := io.ReadAll(r.Body)
-	// Reset body so we can ParseForm() after reading raw
-	r.Body = io.NopCloser(bytes.NewReader(raw))
-	// Accept multiple possible signature header names
-	sig := r.Header.Get("X-Moyasar-Signature")
-	if sig == "" {
-		sig = r.Header.Get("X-Signature")
-	}
-	if sig == "" {
-		sig = r.Header.Get("Signature")
-	}
-	if sig == "" {
-		sig = r.Header.Get("X-Webhook-Signature")
-	}
-	if sig == "" {
-		sig = r.Header.Get("Moyasar-Signature")
-	}
-	if sig == "" {
-		sig = r.Header.Get("Moyasar-Webhook-Signature")
-	}
-	// In test mode, bypass verification entirely (useful for local dev and sandbox).
-	// Otherwise, accept either header-based HMAC OR payload `secret_token` (per Moyasar docs).
-	if s := config.GetSettings(); !(s != nil && s.PaymentsTestMode) {
-		verified := false
-		// Try header-based HMAC first
-		if strings.TrimSpace(sig) != "" && moyasar.VerifySignature(raw, sig) {
-			verified = true
-		}
-		// If no valid signature header, try payload secret_token
-		if !verified {
-			ct := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Type")))
-			var token string
-			if strings.Contains(ct, "application/x-www-form-urlencoded") {
-				if err := r.ParseForm(); err == nil {
-					token = strings.TrimSpace(r.FormValue("secret_token"))
-					if token == "" {
-						token = strings.TrimSpace(r.FormValue("webhook[secret_token]"))
-					}
-				}
-			} else {
-				var obj map[string]any
-				if err := json.Unmarshal(raw, &obj); err == nil {
-					if v, ok := obj["secret_token"].(string); ok {
-						token = strings.TrimSpace(v)
-					}
-				}
-			}
-			if token != "" && moyasar.VerifySecretToken(token) {
-				verified = true
-			}
-		}
-		if !verified {
-			logger.Info(r.Context(), "moyasar webhook invalid signature", logger.Fields{
-				"sig_present": sig != "",
-				"sig_len":     len(sig),
-				"content_len": len(raw),
-			})
-			w.WriteHeader(http.StatusUnauthorized)
-			_, _ = w.Write([]byte(`{"code":"PAY_WEBHOOK_INVALID_SIGNATURE","message":"invalid signature"}`))
-			return
-		}
+	serveProviderWebhook(w, r, "moyasar")
+}
+
+// FakePaymentWebhook receives signed webhooks from the in-process fake gateway (non-production only)
+//
+//encore:api public raw method=POST path=/payments/webhook/fake
+func FakePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	serveProviderWebhook(w, r, payments.FakeProviderName)
+}
+
+// FakeCheckoutPage renders the fake gateway's simulated hosted-checkout page
+//
+//encore:api public raw method=GET path=/payments/fake/checkout/:ref
+func FakeCheckoutPage(w http.ResponseWriter, r *http.Request) {
+	if !payments.FakeAllowed() {
+		http.NotFound(w, r)
+		return
+	}
+	page, err := payments.Fake().CheckoutPage(encore.CurrentRequest().PathParams.Get("ref"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(page))
+}
 
-	// Parse payload: support both JSON and application/x-www-form-urlencoded
-	var evt moyasarEvent
-	ct := r.Header.Get("Content-Type")
-	parsed := false
-	var invoiceIDFromMeta int64
-	if strings.Contains(strings.ToLower(ct), "application/x-www-form-urlencoded") {
-		// Form-encoded payload (common from Moyasar webhooks)
-		if err := r.ParseForm(); err == nil {
-			evt.ID = r.FormValue("id")
-			evt.Status = r.FormValue("status")
-			evt.Currency = r.FormValue("currency")
-			if a := strings.TrimSpace(r.FormValue("amount")); a != "" {
-				if v, err := strconv.ParseInt(a, 10, 64); err == nil {
-					evt.Amount = v
-				}
-			}
-			if c := strings.TrimSpace(r.FormValue("captured")); c != "" {
-				if v, err := strconv.ParseInt(c, 10, 64); err == nil {
-					evt.Captured = v
-				}
-			}
-			// Try nested data[...] keys
-			if evt.ID == "" {
-				evt.ID = r.FormValue("data[id]")
-			}
-			if evt.Status == "" {
-				evt.Status = r.FormValue("data[status]")
-			}
-			if evt.Currency == "" {
-				evt.Currency = r.FormValue("data[currency]")
-			}
-			if evt.Amount == 0 {
-				if a := strings.TrimSpace(r.FormValue("data[amount]")); a != "" {
-					if v, err := strconv.ParseInt(a, 10, 64); err == nil {
-						evt.Amount = v
-					}
-				}
-			}
-			if evt.Captured == 0 {
-				if c := strings.TrimSpace(r.FormValue("data[captured]")); c != "" {
-					if v, err := strconv.ParseInt(c, 10, 64); err == nil {
-						evt.Captured = v
-					}
-				}
-			}
-			// Extract metadata invoice_id if provided as metadata[invoice_id] or invoice_id
-			if inv := strings.TrimSpace(r.FormValue("metadata[invoice_id]")); inv != "" {
-				if v, err := strconv.ParseInt(inv, 10, 64); err == nil {
-					invoiceIDFromMeta = v
-				}
-			}
-			if invoiceIDFromMeta == 0 {
-				if inv := strings.TrimSpace(r.FormValue("invoice_id")); inv != "" {
-					if v, err := strconv.ParseInt(inv, 10, 64); err == nil {
-						invoiceIDFromMeta = v
-					}
-				}
-			}
-			if invoiceIDFromMeta == 0 {
-				if inv := strings.TrimSpace(r.FormValue("data[metadata][invoice_id]")); inv != "" {
-					if v, err := strconv.ParseInt(inv, 10, 64); err == nil {
-						invoiceIDFromMeta = v
-					}
-				}
-			}
-			// Derive invoice id from description "invoice:<id>" or "Invoice #INV-YYYY-NNNNNN" if metadata missing
-			if invoiceIDFromMeta == 0 {
-				desc := strings.TrimSpace(r.FormValue("description"))
-				if desc == "" {
-					desc = strings.TrimSpace(r.FormValue("data[description]"))
-				}
-				if strings.HasPrefix(strings.ToLower(desc), "invoice:") {
-					if v, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(desc, "invoice:")), 10, 64); err == nil {
-						invoiceIDFromMeta = v
-					}
-				}
-				// Try to extract from "Invoice #INV-YYYY-NNNNNN" format
-				if invoiceIDFromMeta == 0 && strings.Contains(desc, "Invoice #INV-") {
-					parts := strings.Split(desc, "Invoice #INV-")
-					if len(parts) > 1 {
-						invNum := strings.TrimSpace(parts[1])
-						// invNum is like "2025-000030" or "2025-000030..."
-						invNum = strings.Split(invNum, " ")[0] // Take first part before space
-						// Query database to find invoice by number
-						var invID int64
-						if err := db.Stdlib().QueryRowContext(r.Context(), `SELECT id FROM invoices WHERE number=$1`, "INV-"+invNum).Scan(&invID); err == nil {
-							invoiceIDFromMeta = invID
-						}
-					}
-				}
-			}
-			// Some providers wrap JSON in a 'payload' field; try to parse it
-			if p := strings.TrimSpace(r.FormValue("payload")); p != "" && evt.ID == "" {
-				var t map[string]any
-				if err := json.Unmarshal([]byte(p), &t); err == nil {
-					if dm, ok := t["data"].(map[string]any); ok {
-						if v, ok := dm["id"].(string); ok {
-							evt.ID = v
-						}
-						if v, ok := dm["status"].(string); ok && evt.Status == "" {
-							evt.Status = v
-						}
-						if v, ok := dm["currency"].(string); ok && evt.Currency == "" {
-							evt.Currency = v
-						}
-						if v, ok := dm["amount"].(float64); ok && evt.Amount == 0 {
-							evt.Amount = int64(v)
-						}
-						if v, ok := dm["captured"].(float64); ok && evt.Captured == 0 {
-							evt.Captured = int64(v)
-						}
-						if m, ok := dm["metadata"].(map[string]any); ok && invoiceIDFromMeta == 0 {
-							if inv, ok := m["invoice_id"]; ok {
-								switch t := inv.(type) {
-								case string:
-									if v, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64); err == nil {
-										invoiceIDFromMeta = v
-									}
-								case float64:
-									invoiceIDFromMeta = int64(t)
-								}
-							}
-						}
-					}
-				}
-			}
-			parsed = true
+// FakeCheckoutSubmit completes a fake checkout (outcome=paid|failed), delivers the signed
+// webhook in-process and redirects the browser back like a real gateway would
+//
+//encore:api public raw method=POST path=/payments/fake/checkout/:ref
+func FakeCheckoutSubmit(w http.ResponseWriter, r *http.Request) {
+	if !payments.FakeAllowed() {
+		http.NotFound(w, r)
+		return
+	}
+	fake := payments.Fake()
+	outcome := "paid"
+	if err := r.ParseForm(); err == nil && strings.TrimSpace(r.FormValue("outcome")) != "" {
+		outcome = strings.TrimSpace(r.FormValue("outcome"))
+	}
+	body, sig, redirectURL, err := fake.Complete(encore.CurrentRequest().PathParams.Get("ref"), outcome)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"code":"PAY_FAKE_CHECKOUT_FAILED","message":"` + err.Error() + `"}`))
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(payments.FakeSignatureHeader, sig)
+	if status, resp := receiveWebhook(r.Context(), fake, header, body); status != http.StatusOK {
+		w.WriteHeader(status)
+		_, _ = w.Write(resp)
+		return
+	}
+
+	if redirectURL == "" {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// serveProviderWebhook reads a webhook request and hands it to the named provider
+func serveProviderWebhook(w http.ResponseWriter, r *http.Request, providerName string) {
+	provider, err := payments.Get(providerName)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	raw, _ := io.ReadAll(r.Body)
+	status, resp := receiveWebhook(r.Context(), provider, r.Header, raw)
+	w.WriteHeader(status)
+	_, _ = w.Write(resp)
+}
+
+// receiveWebhook verifies a webhook with the provider, records the raw payload on the payment
+// and publishes it for processing. Returns the HTTP status and body to answer with.
+func receiveWebhook(ctx context.Context, provider payments.Provider, header http.Header, raw []byte) (int, []byte) {
+	gateway := provider.Name()
+	evt, err := provider.VerifyWebhook(ctx, header, raw)
+	if err != nil {
+		logger.Info(ctx, "payment webhook rejected", logger.Fields{
+			"gateway":     gateway,
+			"error":       err.Error(),
+			"content_len": len(raw),
+		})
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			return http.StatusUnauthorized, []byte(`{"code":"PAY_WEBHOOK_INVALID_SIGNATURE","message":"invalid signature"}`)
 		}
+		return http.StatusBadRequest, []byte(`{"code":"PAY_WEBHOOK_MALFORMED","message":"malformed payload"}`)
 	}
-	if !parsed {
-		_ = json.Unmarshal(raw, &evt)
-		// Try to parse metadata.invoice_id from JSON
-		var rawMap map[string]any
-		if err := json.Unmarshal(raw, &rawMap); err == nil {
-			// If event envelope has data{...}
-			if dm, ok := rawMap["data"].(map[string]any); ok {
-				if evt.ID == "" {
-					if v, ok := dm["id"].(string); ok {
-						evt.ID = v
-					}
-				}
-				if evt.Status == "" {
-					if v, ok := dm["status"].(string); ok {
-						evt.Status = v
-					}
-				}
-				if evt.Currency == "" {
-					if v, ok := dm["currency"].(string); ok {
-						evt.Currency = v
-					}
-				}
-				if evt.Amount == 0 {
-					if v, ok := dm["amount"].(float64); ok {
-						evt.Amount = int64(v)
-					}
-				}
-				if evt.Captured == 0 {
-					if v, ok := dm["captured"].(float64); ok {
-						evt.Captured = int64(v)
-					}
-				}
-				if m, ok := dm["metadata"].(map[string]any); ok {
-					if invoiceIDFromMeta == 0 {
-						if inv, ok := m["invoice_id"]; ok {
-							switch t := inv.(type) {
-							case string:
-								if v, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64); err == nil {
-									invoiceIDFromMeta = v
-								}
-							case float64:
-								invoiceIDFromMeta = int64(t)
-							}
-						}
-					}
-				}
-				if invoiceIDFromMeta == 0 {
-					if d, ok := dm["description"].(string); ok {
-						if strings.HasPrefix(strings.ToLower(d), "invoice:") {
-							if v, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(d, "invoice:")), 10, 64); err == nil {
-								invoiceIDFromMeta = v
-							}
-						}
-						// Try to extract from "Invoice #INV-YYYY-NNNNNN" format
-						if invoiceIDFromMeta == 0 && strings.Contains(d, "Invoice #INV-") {
-							parts := strings.Split(d, "Invoice #INV-")
-							if len(parts) > 1 {
-								invNum := strings.TrimSpace(parts[1])
-								invNum = strings.Split(invNum, " ")[0]
-								var invID int64
-								if err := db.Stdlib().QueryRowContext(r.Context(), `SELECT id FROM invoices WHERE number=$1`, "INV-"+invNum).Scan(&invID); err == nil {
-									invoiceIDFromMeta = invID
-								}
-							}
-						}
-					}
-				}
-			}
-			// look for metadata.invoice_id or invoice_id at top-level
-			if m, ok := rawMap["metadata"].(map[string]any); ok {
-				if inv, ok := m["invoice_id"]; ok {
-					switch t := inv.(type) {
-					case string:
-						if v, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64); err == nil {
-							invoiceIDFromMeta = v
-						}
-					case float64:
-						invoiceIDFromMeta = int64(t)
-					}
-				}
-			}
-			if invoiceIDFromMeta == 0 {
-				if inv, ok := rawMap["invoice_id"]; ok {
-					switch t := inv.(type) {
-					case string:
-						if v, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64); err == nil {
-							invoiceIDFromMeta = v
-						}
-					case float64:
-						invoiceIDFromMeta = int64(t)
-					}
-				}
-			}
-			if invoiceIDFromMeta == 0 {
-				if d, ok := rawMap["description"].(string); ok {
-					if strings.HasPrefix(strings.ToLower(d), "invoice:") {
-						if v, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(d, "invoice:")), 10, 64); err == nil {
-							invoiceIDFromMeta = v
-						}
-					}
-					// Try to extract from "Invoice #INV-YYYY-NNNNNN" format
-					if invoiceIDFromMeta == 0 && strings.Contains(d, "Invoice #INV-") {
-						parts := strings.Split(d, "Invoice #INV-")
-						if len(parts) > 1 {
-							invNum := strings.TrimSpace(parts[1])
-							invNum = strings.Split(invNum, " ")[0]
-							var invID int64
-							if err := db.Stdlib().QueryRowContext(r.Context(), `SELECT id FROM invoices WHERE number=$1`, "INV-"+invNum).Scan(&invID); err == nil {
-								invoiceIDFromMeta = invID
-							}
-						}
-					}
-				}
-			}
-			// If status still empty, try mapping from event 'type'
-			if evt.Status == "" {
-				if typ, ok := rawMap["type"].(string); ok {
-					lt := strings.ToLower(typ)
-					switch {
-					case strings.Contains(lt, "captured"), strings.Contains(lt, "paid"), strings.Contains(lt, "succeeded"):
-						evt.Status = "paid"
-					case strings.Contains(lt, "authorized"):
-						evt.Status = "authorized"
-					case strings.Contains(lt, "failed"), strings.Contains(lt, "void"):
-						evt.Status = "failed"
-					}
-				}
-			}
+
+	// Resolve invoice by number when the gateway only echoed the description
+	invoiceIDFromMeta := evt.InvoiceID
+	if invoiceIDFromMeta == 0 && evt.InvoiceNumber != "" {
+		var invID int64
+		if err := db.Stdlib().QueryRowContext(ctx, `SELECT id FROM invoices WHERE number=$1`, evt.InvoiceNumber).Scan(&invID); err == nil {
+			invoiceIDFromMeta = invID
 		}
 	}
 
-	gatewayRef := strings.TrimSpace(evt.ID)
+	gatewayRef := evt.GatewayRef
+	ct := header.Get("Content-Type")
 
 	// Persist raw payload for diagnostics (store as JSONB object with raw text & content-type)
 	if gatewayRef != "" {
-		_, _ = db.Stdlib().ExecContext(r.Context(),
+		_, _ = db.Stdlib().ExecContext(ctx,
 			`UPDATE payments SET raw_response = jsonb_build_object('raw', $1::text, 'ct', $2::text), updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE gateway_ref=$3`,
 			string(raw), ct, gatewayRef,
 		)
 	}
 	if gatewayRef == "" && invoiceIDFromMeta > 0 {
 		// Fallback: attach raw_response to the most recent pending/initiated payment for this invoice (via subquery)
-		_, _ = db.Stdlib().ExecContext(r.Context(),
+		_, _ = db.Stdlib().ExecContext(ctx,
 			`UPDATE payments SET raw_response = jsonb_build_object('raw', $1::text, 'ct', $2::text), updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
              WHERE id = (
                SELECT id FROM payments
-               WHERE invoice_id=$3 AND status IN ('initiated','pending') AND gateway='moyasar'
+               WHERE invoice_id=$3 AND status IN ('initiated','pending') AND gateway=$4
                ORDER BY created_at DESC
                LIMIT 1
              )`,
-			string(raw), ct, invoiceIDFromMeta,
+			string(raw), ct, invoiceIDFromMeta, gateway,
 		)
 	}
 
 	if gatewayRef == "" {
 		// No actionable id – acknowledge to avoid retries but do nothing
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("{}"))
-		return
+		return http.StatusOK, []byte("{}")
 	}
 
 	// If no payment row has this gateway_ref yet, try to claim the pending payment for invoice and set its gateway_ref now
 	if invoiceIDFromMeta > 0 {
-		logger.Info(r.Context(), "attach gateway_ref by invoice fallback attempt", logger.Fields{
+		logger.Info(ctx, "attach gateway_ref by invoice fallback attempt", logger.Fields{
 			"invoice_id":  invoiceIDFromMeta,
 			"gateway_ref": gatewayRef,
 		})
-		res, err := db.Stdlib().ExecContext(r.Context(),
+		res, err := db.Stdlib().ExecContext(ctx,
 			`UPDATE payments SET gateway_ref=$1, updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
              WHERE id = (
                SELECT id FROM payments
-               WHERE invoice_id=$2 AND gateway='moyasar' AND status IN ('initiated','pending') AND (gateway_ref IS NULL OR gateway_ref='')
+               WHERE invoice_id=$2 AND gateway=$3 AND status IN ('initiated','pending') AND (gateway_ref IS NULL OR gateway_ref='')
                ORDER BY created_at DESC
                LIMIT 1
              )`,
-			gatewayRef, invoiceIDFromMeta,
+			gatewayRef, invoiceIDFromMeta, gateway,
 		)
 		if err != nil {
-			logger.LogError(r.Context(), err, "attach gateway_ref by invoice fallback failed", logger.Fields{
+			logger.LogError(ctx, err, "attach gateway_ref by invoice fallback failed", logger.Fields{
 				"invoice_id":  invoiceIDFromMeta,
 				"gateway_ref": gatewayRef,
 			})
-			return
+			return http.StatusOK, []byte("{}")
 		}
 		rows, _ := res.RowsAffected()
 		if rows > 0 {
-			logger.Info(r.Context(), "attached gateway_ref by invoice fallback", logger.Fields{
+			logger.Info(ctx, "attached gateway_ref by invoice fallback", logger.Fields{
 				"invoice_id":  invoiceIDFromMeta,
 				"gateway_ref": gatewayRef,
 			})
@@ -806,9 +590,10 @@ func MoyasarWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log parsed summary for diagnostics (no secrets)
-	logger.Info(r.Context(), "moyasar webhook parsed", logger.Fields{
+	logger.Info(ctx, "payment webhook parsed", logger.Fields{
+		"gateway":  gateway,
 		"ct":       ct,
-		"evt_id":   evt.ID,
+		"evt_id":   evt.GatewayRef,
 		"status":   evt.Status,
 		"amount":   evt.Amount,
 		"captured": evt.Captured,
@@ -823,22 +608,35 @@ func MoyasarWebhook(w http.ResponseWriter, r *http.Request) {
 	})
 
 	pe := &PaymentEvent{
+		Gateway:    gateway,
 		GatewayRef: gatewayRef,
-		Status:     strings.ToLower(strings.TrimSpace(evt.Status)),
+		Status:     evt.Status,
 		Amount:     evt.Amount,
 		Captured:   evt.Captured,
-		Currency:   strings.ToUpper(strings.TrimSpace(evt.Currency)),
+		Currency:   evt.Currency,
 		ReceivedAt: time.Now().UTC().Format(time.RFC3339),
 		InvoiceID:  invoiceIDFromMeta,
 	}
-	_, _ = PaymentWebhookEvents.Publish(r.Context(), pe)
+	// Persist before acknowledging; the same gateway event redelivered maps to the same outbox row
+	key := outbox.Key("payment-webhook", gateway, gatewayRef, pe.Status, pe.Captured)
+	if err := outbox.Add(ctx, db, outbox.Event(paymentEventsTopic, pe, key)); err != nil {
+		logger.LogError(ctx, err, "payment webhook enqueue failed", logger.Fields{
+			"gateway":     gateway,
+			"gateway_ref": gatewayRef,
+		})
+		return http.StatusInternalServerError, []byte(`{"code":"PAY_WEBHOOK_ENQUEUE_FAILED","message":"retry later"}`)
+	}
+	kickPaymentEvents()
 
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("{}"))
+	return http.StatusOK, []byte("{}")
 }
 
-func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64, status string, amount, captured int64, currency string, now time.Time) error {
+func processWebhook(ctx context.Context, gateway, gatewayRef string, invoiceIDHint int64, status string, amount, captured int64, currency string, now time.Time) error {
+	if gateway == "" {
+		gateway = "moyasar"
+	}
 	logger.Info(ctx, "processWebhook begin", logger.Fields{
+		"gateway":      gateway,
 		"gateway_ref":  gatewayRef,
 		"invoice_hint": invoiceIDHint,
 		"status":       status,
@@ -846,12 +644,8 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
 		"captured":     captured,
 		"currency":     currency,
 	})
-	// Post-commit notification data (if order transitions to paid)
+	// Set when the order transitions to paid; its notifications are queued in the outbox inside the tx
 	var notifyPaid bool
-	var notifOrderID int64
-	var notifInvoiceID int64
-	var notifGrandTotal float64
-	var notifBuyerName, notifBuyerEmail string
 
 	err := withTx(ctx, func(tx *sql.Tx) error {
 		var paymentID, invoiceID int64
@@ -863,11 +657,11 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
 				err = tx.QueryRowContext(ctx, `
                     SELECT id, invoice_id, status::text
                     FROM payments
-                    WHERE invoice_id=$1 AND gateway='moyasar' AND status IN ('initiated','pending')
+                    WHERE invoice_id=$1 AND gateway=$2 AND status IN ('initiated','pending')
                     ORDER BY created_at DESC
                     LIMIT 1
                     FOR UPDATE
-                `, invoiceIDHint).Scan(&paymentID, &invoiceID, &currentStatus)
+                `, invoiceIDHint, gateway).Scan(&paymentID, &invoiceID, &currentStatus)
 				if err == sql.ErrNoRows {
 					logger.Info(ctx, "no payment found for invoice (early exit)", logger.Fields{
 						"invoice_id": invoiceIDHint,
@@ -899,7 +693,7 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
                     SELECT p.id, p.invoice_id, p.status::text
                     FROM payments p
                     JOIN invoices i ON i.id = p.invoice_id
-                    WHERE p.gateway='moyasar'
+                    WHERE p.gateway=$3
                       AND p.status IN ('initiated','pending')
                       AND i.status IN ('payment_in_progress')
                       AND p.currency = $1
@@ -907,7 +701,7 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
                     ORDER BY p.created_at DESC
                     LIMIT 1
                     FOR UPDATE
-                `, currency, sar).Scan(&paymentID, &invoiceID, &currentStatus)
+                `, currency, sar, gateway).Scan(&paymentID, &invoiceID, &currentStatus)
 				if err == sql.ErrNoRows {
 					return nil
 				}
@@ -1009,6 +803,9 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
 				if _, err := tx.ExecContext(ctx, `UPDATE invoices SET status='failed', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$1 AND status!='failed'`, invoiceID); err != nil {
 					return err
 				}
+				if err := releaseInvoiceHolds(ctx, tx, invoiceID, stockhold.ReasonExpired); err != nil {
+					return err
+				}
 			} else {
 				// Keep payment pending until capture; snapshot authorized amount/currency
 				if _, err := tx.ExecContext(ctx, `UPDATE payments SET status='pending', amount_authorized=GREATEST(amount_authorized,$1), currency=COALESCE($2,currency), updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$3`, float64(amount)/100.0, currency, paymentID); err != nil {
@@ -1037,6 +834,10 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
 					logger.LogError(ctx, err, "update order awaiting_admin_refund failed", logger.Fields{"invoice_id": invoiceID})
 					return err
 				}
+				// The order is refunded, not fulfilled: hand back anything still held for it
+				if err := releaseInvoiceHolds(ctx, tx, invoiceID, stockhold.ReasonExpired); err != nil {
+					return err
+				}
 			} else {
 				// Normal success path
 				// Finalize the order atomically and idempotently (update order FIRST to avoid trigger race)
@@ -1052,22 +853,23 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
 				rows, _ := res.RowsAffected()
 				if rows > 0 {
 					notifyPaid = true
-					notifOrderID = orderID
-					notifInvoiceID = invoiceID
 					// Fetch order details for notification
 					var buyerName, buyerEmail string
 					if err := tx.QueryRowContext(ctx, `SELECT COALESCE(u.name,''), COALESCE(u.email,'') FROM orders o JOIN users u ON u.id = o.user_id WHERE o.id=$1`, orderID).Scan(&buyerName, &buyerEmail); err != nil {
 						return err
 					}
-					notifBuyerName = buyerName
-					notifBuyerEmail = buyerEmail
 					// Fetch order grand total for notification
 					var grandTotal float64
 					if err := tx.QueryRowContext(ctx, `SELECT COALESCE(grand_total,0) FROM orders WHERE id=$1`, orderID).Scan(&grandTotal); err != nil {
 						return err
 					}
-					notifGrandTotal = grandTotal
+					// Checkout holds cover most lines: consume them first (reserved pigeons → sold, supply stock already taken)
+					heldPigeons, heldSupplies, err := stockhold.Consume(ctx, tx, orderID)
+					if err != nil {
+						return err
+					}
 					// First time we mark order paid → perform atomic stock/product transitions with conflict detection
+					// for the lines without a hold (auction orders, holds released before the payment arrived)
 					// Count expected pigeon lines
 					var pigeonsTotal int64
 					_ = tx.QueryRowContext(ctx, `
@@ -1087,6 +889,7 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
 						return err
 					}
 					soldPigeons, _ := resP.RowsAffected()
+					soldPigeons += heldPigeons
 					logger.Info(ctx, "webhook: sold pigeons", logger.Fields{"expected": pigeonsTotal, "updated": soldPigeons})
 
 					// Count expected supply lines
@@ -1104,11 +907,16 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
                         FROM order_items oi
                         JOIN products p ON p.id = oi.product_id AND p.type='supply'
                         WHERE oi.order_id=$1 AND s.product_id = oi.product_id AND s.stock_qty >= oi.qty
+                          AND NOT EXISTS (
+                              SELECT 1 FROM stock_reservations sr
+                              WHERE sr.order_id=oi.order_id AND sr.product_id=oi.product_id AND sr.status='consumed'
+                          )
                     `, orderID)
 					if err != nil {
 						return err
 					}
 					deductedSupplies, _ := resS.RowsAffected()
+					deductedSupplies += heldSupplies
 					logger.Info(ctx, "webhook: deducted supplies", logger.Fields{"expected": suppliesTotal, "updated": deductedSupplies})
 
 					// If any conflict (couldn't sell or deduct), mark refund_required and admin follow-up
@@ -1148,9 +956,14 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
                             )
                         `, orderID, "لوفت الدغيري - بريدة، القصيم")
 					}
+
+					// Queue buyer notifications with the state change so they survive a crash and never fire on rollback
+					if err := queueOrderPaidMessages(ctx, tx, orderID, invoiceID, grandTotal, buyerName, buyerEmail, currency, hasPigeons); err != nil {
+						return err
+					}
 				}
 				// Mark invoice paid at the end to ensure order gating above succeeds
-				if _, err := tx.ExecContext(ctx, `UPDATE invoices SET status='paid', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$1 AND status!='paid'`, invoiceID); err != nil {
+				if _, err := tx.ExecContext(ctx, `UPDATE invoices SET status='paid', issued_at=COALESCE(issued_at, NOW()), updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$1 AND status!='paid'`, invoiceID); err != nil {
 					return err
 				}
 			}
@@ -1164,6 +977,10 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
 			if _, err := tx.ExecContext(ctx, `UPDATE invoices SET status='failed', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$1 AND status!='failed'`, invoiceID); err != nil {
 				return err
 			}
+			// Failed payment ends the session: release the checkout holds now rather than at expiry
+			if err := releaseInvoiceHolds(ctx, tx, invoiceID, stockhold.ReasonPaymentFailed); err != nil {
+				return err
+			}
 			return nil
 		}
 
@@ -1173,71 +990,8 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
 		return err
 	}
 
-	// After commit, optionally notify the buyer their order was paid
 	if notifyPaid {
-		var buyerID int64
-		_ = db.Stdlib().QueryRowContext(ctx, `SELECT user_id FROM orders WHERE id=$1`, notifOrderID).Scan(&buyerID)
-		if buyerID > 0 {
-			payload := map[string]any{
-				"order_id":    notifOrderID,
-				"invoice_id":  notifInvoiceID,
-				"grand_total": fmt.Sprintf("%.2f", notifGrandTotal),
-				"name":        notifBuyerName,
-				"email":       notifBuyerEmail,
-				"currency":    currency,
-			}
-			// Fire-and-forget notification; ignore errors
-			_, _ = notifications.EnqueueInternal(ctx, buyerID, "order_paid", payload)
-
-			// Send confirmation email to buyer
-			go func() {
-				// Check if order has pigeons for pickup info
-				var hasPigeons bool
-				_ = db.Stdlib().QueryRowContext(context.Background(), `
-                    SELECT EXISTS(
-                        SELECT 1 FROM order_items oi
-                        JOIN products p ON p.id = oi.product_id
-                        WHERE oi.order_id = $1 AND p.type = 'pigeon'
-                    )
-                `, notifOrderID).Scan(&hasPigeons)
-
-				// Frontend URL للايميل
-				frontendURL := "https://www.dughairiloft.com"
-				if encore.Meta().Environment.Type == encore.EnvDevelopment || encore.Meta().Environment.Type == encore.EnvLocal {
-					frontendURL = "http://localhost:3000"
-				}
-
-				emailData := templates.TemplateData{
-					"name":        notifBuyerName,
-					"order_id":    notifOrderID,
-					"invoice_id":  notifInvoiceID,
-					"grand_total": fmt.Sprintf("%.2f", notifGrandTotal),
-					"has_pigeons": hasPigeons,
-					"order_url":   fmt.Sprintf("%s/account/orders/%d", frontendURL, notifOrderID),
-				}
-
-				subject, htmlBody, textBody, err := templates.RenderTemplate("order_confirmation", "ar", emailData)
-				if err != nil {
-					fmt.Printf("ERROR: Failed to render order confirmation template: %v\n", err)
-					return
-				}
-
-				mailClient := mailer.NewClient()
-				err = mailClient.Send(context.Background(), mailer.Mail{
-					// FromName and FromEmail will use defaults from mailer.Client secrets
-					ToName:  notifBuyerName,
-					ToEmail: notifBuyerEmail,
-					Subject: subject,
-					HTML:    htmlBody,
-					Text:    textBody,
-				})
-				if err != nil {
-					fmt.Printf("ERROR: Failed to send order confirmation email to %s: %v\n", notifBuyerEmail, err)
-				} else {
-					fmt.Printf("INFO: Order confirmation email sent successfully to %s (Order #%d)\n", notifBuyerEmail, notifOrderID)
-				}
-			}()
-		}
+		notifications.KickOutbox()
 	}
 
 	logger.Info(ctx, "processWebhook completed successfully", logger.Fields{
@@ -1248,6 +1002,42 @@ func processWebhook(ctx context.Context, gatewayRef string, invoiceIDHint int64,
 	return nil
 }
 
+// queueOrderPaidMessages writes the buyer's "order_paid" inbox notification and order confirmation email to the outbox
+func queueOrderPaidMessages(ctx context.Context, tx *sql.Tx, orderID, invoiceID int64, grandTotal float64, buyerName, buyerEmail, currency string, hasPigeons bool) error {
+	var buyerID int64
+	if err := tx.QueryRowContext(ctx, `SELECT user_id FROM orders WHERE id=$1`, orderID).Scan(&buyerID); err != nil {
+		return err
+	}
+	msgs := []outbox.Message{
+		outbox.Internal(buyerID, "order_paid", map[string]any{
+			"order_id":    orderID,
+			"invoice_id":  invoiceID,
+			"grand_total": fmt.Sprintf("%.2f", grandTotal),
+			"name":        buyerName,
+			"email":       buyerEmail,
+			"currency":    currency,
+		}, outbox.Key("order", orderID, "order_paid")),
+	}
+	if buyerEmail != "" {
+		// Frontend URL للايميل
+		frontendURL := "https://www.dughairiloft.com"
+		if encore.Meta().Environment.Type == encore.EnvDevelopment || encore.Meta().Environment.Type == encore.EnvLocal {
+			frontendURL = "http://localhost:3000"
+		}
+		msgs = append(msgs, outbox.Email(buyerID, "order_confirmation", map[string]any{
+			"name":        buyerName,
+			"email":       buyerEmail,
+			"order_id":    orderID,
+			"invoice_id":  invoiceID,
+			"grand_total": fmt.Sprintf("%.2f", grandTotal),
+			"has_pigeons": hasPigeons,
+			"order_url":   fmt.Sprintf("%s/account/orders/%d", frontendURL, orderID),
+			"language":    "ar",
+		}, outbox.Key("order", orderID, "order_confirmation")))
+	}
+	return outbox.AddTx(ctx, tx, msgs...)
+}
+
 func withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
 	std := db.Stdlib()
 	tx, err := std.BeginTx(ctx, &sql.TxOptions{})
@@ -1324,105 +1114,20 @@ func GetPayment(ctx context.Context, id int64) (*PaymentDTO, error) {
 	return &dto, nil
 }
 
-type RefundRequest struct {
-	Amount float64 `json:"amount"`
-}
-
-type RefundResponse struct {
-	PaymentID     int64   `json:"payment_id"`
-	InvoiceID     int64   `json:"invoice_id"`
-	Refunded      float64 `json:"refunded"`
-	TotalRefunded float64 `json:"total_refunded"`
-	Captured      float64 `json:"captured"`
-	RefundPartial bool    `json:"refund_partial"`
-	PaymentStatus string  `json:"payment_status"`
-	InvoiceStatus string  `json:"invoice_status"`
-}
-
-//encore:api auth method=POST path=/admin/payments/:id/refund
-func AdminRefundPayment(ctx context.Context, id int64, req *RefundRequest) (*RefundResponse, error) {
-	uidStr, ok := auth.UserID()
-	if !ok {
-		return nil, &errs.Error{Code: errs.Unauthenticated, Message: "مطلوب تسجيل الدخول"}
-	}
-	// admin check
-	uid, _ := strconv.ParseInt(string(uidStr), 10, 64)
-	var role string
-	_ = db.Stdlib().QueryRowContext(ctx, `SELECT role::text FROM users WHERE id=$1`, uid).Scan(&role)
-	if strings.ToLower(role) != "admin" {
-		return nil, &errs.Error{Code: errs.Forbidden, Message: "يتطلب صلاحيات مدير"}
-	}
-	if req == nil || req.Amount <= 0 {
-		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "قيمة الاسترداد غير صالحة"}
+// releaseInvoiceHolds releases the checkout holds of the invoice's order
+func releaseInvoiceHolds(ctx context.Context, tx *sql.Tx, invoiceID int64, reason string) error {
+	var orderID int64
+	if err := tx.QueryRowContext(ctx, `SELECT order_id FROM invoices WHERE id=$1`, invoiceID).Scan(&orderID); err != nil {
+		return err
 	}
-
-	var resp RefundResponse
-	err := withTx(ctx, func(tx *sql.Tx) error {
-		var amountCaptured, amountRefunded float64
-		var invoiceID int64
-		var payStatus, gatewayRef, currency string
-		if err := tx.QueryRowContext(ctx, `SELECT invoice_id, amount_captured, amount_refunded, status::text, gateway_ref, currency FROM payments WHERE id=$1 FOR UPDATE`, id).Scan(&invoiceID, &amountCaptured, &amountRefunded, &payStatus, &gatewayRef, &currency); err != nil {
-			if err == sql.ErrNoRows {
-				return &errs.Error{Code: "PAY_NOT_FOUND", Message: "الدفع غير موجود"}
-			}
-			return err
-		}
-		remaining := amountCaptured - amountRefunded
-		if remaining <= 0 {
-			return &errs.Error{Code: errs.Conflict, Message: "لا يوجد مبلغ متبقٍ للاسترداد"}
-		}
-		if req.Amount > remaining+1e-9 {
-			return &errs.Error{Code: errs.InvalidArgument, Message: "المبلغ يتجاوز المبلغ القابل للاسترداد"}
-		}
-
-		// Call Moyasar refund API (amount in halalas)
-		refundHalalas := int(req.Amount*100.0 + 0.5)
-		if err := moyasar.RefundPayment(gatewayRef, refundHalalas); err != nil {
-			return &errs.Error{Code: errs.ServiceUnavailable, Message: "تعذر تنفيذ الاسترداد مع مزود الدفع"}
-		}
-
-		newTotal := amountRefunded + req.Amount
-		refundPartial := newTotal < amountCaptured-1e-9
-		newPayStatus := payStatus
-		if !refundPartial {
-			newPayStatus = "refunded"
-		}
-		if _, err := tx.ExecContext(ctx, `UPDATE payments SET amount_refunded=$1, refund_partial=$2, status=CASE WHEN $2 THEN status ELSE 'refunded' END, updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$3`, newTotal, refundPartial, id); err != nil {
-			return err
-		}
-
-		var invStatus string
-		if refundPartial {
-			if _, err := tx.ExecContext(ctx, `UPDATE invoices SET status='refund_required', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$1`, invoiceID); err != nil {
-				return err
-			}
-			invStatus = "refund_required"
-		} else {
-			if _, err := tx.ExecContext(ctx, `UPDATE invoices SET status='refunded', updated_at=(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id=$1`, invoiceID); err != nil {
-				return err
-			}
-			invStatus = "refunded"
-		}
-
-		resp = RefundResponse{
-			PaymentID:     id,
-			InvoiceID:     invoiceID,
-			Refunded:      req.Amount,
-			TotalRefunded: newTotal,
-			Captured:      amountCaptured,
-			RefundPartial: refundPartial,
-			PaymentStatus: newPayStatus,
-			InvoiceStatus: invStatus,
-		}
-		return nil
-	})
+	n, err := stockhold.ReleaseOrder(ctx, tx, orderID, reason)
 	if err != nil {
-		if e, ok := err.(*errs.Error); ok {
-			return nil, e
-		}
-		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر تنفيذ الاسترداد"}
+		return err
 	}
-	return &resp, nil
+	if n > 0 {
+		logger.Info(ctx, "released checkout holds", logger.Fields{"order_id": orderID, "released": n, "reason": reason})
+	}
+	return nil
 }
 
 // CleanupResponse represents the result of cleaning expired payment sessions
@@ -1441,6 +1146,9 @@ func CleanupExpiredPaymentSessions(ctx context.Context) (*CleanupResponse, error
 
 	nowUTC := time.Now().UTC()
 
+	// 0) Last gateway check for sessions about to expire (recovers lost webhooks)
+	reconcileBeforeExpiry(ctx, ttl)
+
 	// 1) Mark stale invoices as failed (payment_in_progress past TTL)
 	// Some historical rows may contain malformed pay_started_at strings; filter candidates defensively.
 	invRes, err := db.Stdlib().ExecContext(ctx, `
@@ -1466,7 +1174,24 @@ func CleanupExpiredPaymentSessions(ctx context.Context) (*CleanupResponse, error
 		}
 	}
 
-	_ = nowUTC // reserved for potential future logging/metrics
+	// 2) Release checkout holds that expired, plus any left on invoices that just failed
+	released := 0
+	err = withTx(ctx, func(tx *sql.Tx) error {
+		n, err := stockhold.ReleaseExpired(ctx, tx, nowUTC)
+		if err != nil {
+			return err
+		}
+		m, err := stockhold.ReleaseFailed(ctx, tx)
+		released = n + m
+		return err
+	})
+	if err != nil {
+		logger.LogError(ctx, err, "release expired stock holds failed", nil)
+		return nil, &errs.Error{Code: errs.Internal, Message: "تعذر تحرير الحجوزات المنتهية"}
+	}
+	if released > 0 {
+		logger.Info(ctx, "released expired stock holds", logger.Fields{"released": released})
+	}
 
-	return &CleanupResponse{FailedInvoices: failedCount, ProductsReleased: 0}, nil
+	return &CleanupResponse{FailedInvoices: failedCount, ProductsReleased: released}, nil
 }
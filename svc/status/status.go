@@ -0,0 +1,84 @@
+// Package status reports platform availability and enforces maintenance mode for every service
+package status
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"encore.app/pkg/errs"
+	"encore.app/pkg/logger"
+	"encore.app/pkg/maintenance"
+	authsvc "encore.app/svc/auth"
+	encore "encore.dev"
+	"encore.dev/beta/auth"
+	"encore.dev/middleware"
+	"encore.dev/storage/sqldb"
+)
+
+var db = sqldb.Named("coredb")
+
+// StatusResponse is the public platform status
+type StatusResponse struct {
+	Maintenance         bool                 `json:"maintenance"`
+	Message             string               `json:"message,omitempty"`
+	EndsAt              *time.Time           `json:"ends_at,omitempty"`
+	RetryAfterSeconds   int                  `json:"retry_after_seconds,omitempty"`
+	RegistrationEnabled bool                 `json:"registration_enabled"`
+	UpcomingWindows     []maintenance.Window `json:"upcoming_windows"` // scheduled maintenance announced ahead of time
+	ServerTime          time.Time            `json:"server_time"`
+}
+
+// GetStatus returns whether the platform is in maintenance and the scheduled windows
+//
+//encore:api public method=GET path=/status
+func GetStatus(ctx context.Context) (*StatusResponse, error) {
+	st, err := maintenance.Current(ctx, db)
+	if err != nil {
+		logger.LogError(ctx, err, "Failed to load maintenance status", nil)
+		return nil, errs.E(ctx, errs.Internal, "فشل تحميل حالة المنصة")
+	}
+	now := time.Now().UTC()
+	resp := &StatusResponse{
+		Maintenance:         st.Active,
+		RegistrationEnabled: st.RegistrationEnabled,
+		UpcomingWindows:     st.Upcoming,
+		ServerTime:          now,
+	}
+	if st.Active {
+		resp.Message = st.Message
+		resp.EndsAt = st.EndsAt
+		resp.RetryAfterSeconds = int(st.RetryAfter(now).Seconds())
+	}
+	return resp, nil
+}
+
+// MaintenanceGuard rejects non-admin writes on every service while maintenance mode is on or
+// a scheduled window is running. Reads, sign-in and payment webhooks are not affected (see
+// maintenance.Guarded).
+//
+//encore:middleware global target=all
+func MaintenanceGuard(req middleware.Request, next middleware.Next) middleware.Response {
+	data := req.Data()
+	if data.Type != encore.APICall || data.CronIdempotencyKey != "" || !maintenance.Guarded(data.Method, data.Path) {
+		return next(req)
+	}
+	if ad, ok := auth.Data().(*authsvc.AuthData); ok && ad != nil && ad.Role == "admin" {
+		return next(req)
+	}
+
+	ctx := req.Context()
+	st, err := maintenance.Current(ctx, db)
+	if err != nil {
+		// Fail open: an unreadable flag must not take the platform down
+		logger.LogError(ctx, err, "Failed to load maintenance status", nil)
+	}
+	if !st.Active {
+		return next(req)
+	}
+
+	resp := middleware.Response{Err: st.Error(ctx), HTTPStatus: http.StatusServiceUnavailable}
+	resp.Header().Set("Retry-After", strconv.Itoa(int(st.RetryAfter(time.Now()).Seconds())))
+	return resp
+}
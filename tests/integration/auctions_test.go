@@ -299,6 +299,208 @@ func TestBidRateLimiting(t *testing.T) {
 	}
 }
 
+// TestProxyBidding اختبار المزايدة التلقائية بحد أقصى
+func TestProxyBidding(t *testing.T) {
+	ctx := context.Background()
+	db := testDB
+
+	// تنظيف البيانات القديمة
+	cleanupAuctionTestData(t, db)
+	defer cleanupAuctionTestData(t, db)
+
+	bidService := auctionssvc.NewBidService(testDB)
+
+	auctionID := createTestAuction(t, db, 1000.00, 100, "live")
+
+	user1ID := createTestUser(t, db, "proxy_bidder1@example.com", "SecurePass123!", true)
+	_, _ = db.Exec(ctx, `UPDATE users SET role='verified' WHERE id=$1`, user1ID)
+	user1Ctx := auth.WithContext(ctx, auth.UID(strconv.FormatInt(user1ID, 10)), &authsvc.AuthData{UserID: user1ID, Role: "verified", Email: "pb1@example.com"})
+
+	user2ID := createTestUser(t, db, "proxy_bidder2@example.com", "SecurePass123!", true)
+	_, _ = db.Exec(ctx, `UPDATE users SET role='verified' WHERE id=$1`, user2ID)
+	user2Ctx := auth.WithContext(ctx, auth.UID(strconv.FormatInt(user2ID, 10)), &authsvc.AuthData{UserID: user2ID, Role: "verified", Email: "pb2@example.com"})
+
+	// المستخدم الأول يضع حداً أقصى 1500 بدون مبلغ صريح: يُفتح المزاد بالحد الأدنى
+	bid, err := bidService.PlaceProxyBid(user1Ctx, auctionID, user1ID, 0, 1500.00)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if bid.Amount != 1100.00 || !bid.IsAuto {
+		t.Errorf("Expected auto bid of 1100, got %f (auto=%t)", bid.Amount, bid.IsAuto)
+	}
+
+	// المستخدم الثاني يزايد يدوياً بـ 1300: يرد الحد الأقصى تلقائياً بـ 1400
+	if _, err := bidService.PlaceBid(user2Ctx, auctionID, user2ID, 1300.00); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var leaderID int64
+	var leaderAmount float64
+	var isAuto bool
+	err = db.QueryRow(ctx, `SELECT user_id, amount::float8, is_auto FROM bids WHERE auction_id=$1 ORDER BY amount DESC LIMIT 1`, auctionID).Scan(&leaderID, &leaderAmount, &isAuto)
+	if err != nil {
+		t.Fatalf("Failed to read leader: %v", err)
+	}
+	if leaderID != user1ID || leaderAmount != 1400.00 || !isAuto {
+		t.Errorf("Expected user1 to lead with auto bid 1400, got user %d amount %f auto=%t", leaderID, leaderAmount, isAuto)
+	}
+
+	// المستخدم الثاني يضع حداً أقصى 2000: يتغلب على حد المستخدم الأول بخطوة واحدة
+	if _, err := bidService.PlaceProxyBid(user2Ctx, auctionID, user2ID, 0, 2000.00); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = db.QueryRow(ctx, `SELECT user_id, amount::float8 FROM bids WHERE auction_id=$1 ORDER BY amount DESC LIMIT 1`, auctionID).Scan(&leaderID, &leaderAmount)
+	if err != nil {
+		t.Fatalf("Failed to read leader: %v", err)
+	}
+	if leaderID != user2ID || leaderAmount != 1600.00 {
+		t.Errorf("Expected user2 to lead with 1600, got user %d amount %f", leaderID, leaderAmount)
+	}
+
+	proxy, err := bidService.GetProxyBid(user1Ctx, auctionID, user1ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if proxy.Status != auctionssvc.ProxyBidStatusExhausted {
+		t.Errorf("Expected user1 proxy to be exhausted, got %s", proxy.Status)
+	}
+
+	// إيقاف المزايدة التلقائية
+	if err := bidService.CancelProxyBid(user2Ctx, auctionID, user2ID); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := bidService.CancelProxyBid(user2Ctx, auctionID, user2ID); err == nil {
+		t.Errorf("Expected error when cancelling an inactive proxy bid")
+	}
+}
+
+// TestAuctionWatchlist اختبار قائمة متابعة المزادات
+func TestAuctionWatchlist(t *testing.T) {
+	ctx := context.Background()
+	db := testDB
+
+	// تنظيف البيانات القديمة
+	cleanupAuctionTestData(t, db)
+	defer cleanupAuctionTestData(t, db)
+
+	watchlist := auctionssvc.NewWatchlistService(testDB)
+
+	liveAuctionID := createTestAuction(t, db, 1000.00, 100, "live")
+	endedAuctionID := createTestAuction(t, db, 1000.00, 100, "ended")
+	userID := createTestUser(t, db, "test_watcher@example.com", "SecurePass123!", true)
+
+	// المتابعة عملية متكررة بأمان (idempotent)
+	for i := 0; i < 2; i++ {
+		if err := watchlist.Watch(ctx, liveAuctionID, userID); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := watchlist.Watch(ctx, endedAuctionID, userID); err == nil {
+		t.Errorf("Expected error when watching an ended auction")
+	}
+	if err := watchlist.Watch(ctx, 99999, userID); err == nil {
+		t.Errorf("Expected error when watching a missing auction")
+	}
+
+	ids, err := watchlist.ListWatchedAuctionIDs(ctx, userID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != liveAuctionID {
+		t.Errorf("Expected watchlist [%d], got %v", liveAuctionID, ids)
+	}
+
+	// تذكير "ينتهي قريباً" يُرسل مرة واحدة فقط
+	_, _ = db.Exec(ctx, `UPDATE auctions SET end_at = NOW() + INTERVAL '5 minutes' WHERE id=$1`, liveAuctionID)
+	for i := 0; i < 2; i++ {
+		if err := watchlist.ProcessReminders(ctx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	var reminders int
+	_ = db.QueryRow(ctx, `SELECT COUNT(*) FROM notifications WHERE user_id=$1 AND channel='internal' AND template_id='auction_watch_ending_soon'`, userID).Scan(&reminders)
+	if reminders != 1 {
+		t.Errorf("Expected exactly 1 ending soon reminder, got %d", reminders)
+	}
+
+	if err := watchlist.Unwatch(ctx, liveAuctionID, userID); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := watchlist.Unwatch(ctx, liveAuctionID, userID); err == nil {
+		t.Errorf("Expected error when unwatching an auction not in the watchlist")
+	}
+	_, _ = db.Exec(ctx, `DELETE FROM notifications WHERE user_id=$1`, userID)
+}
+
+// TestSecondChanceOffers اختبار عروض الفرصة الثانية بعد عدم سداد الفائز
+func TestSecondChanceOffers(t *testing.T) {
+	ctx := context.Background()
+	db := testDB
+
+	// تنظيف البيانات القديمة
+	cleanupAuctionTestData(t, db)
+	defer cleanupAuctionTestData(t, db)
+
+	policies := auctionssvc.NewFailurePolicyService(testDB)
+
+	auctionID := createTestAuction(t, db, 1000.00, 100, "winner_unpaid")
+	winnerID := createTestUser(t, db, "sc_bidder1@example.com", "SecurePass123!", true)
+	secondID := createTestUser(t, db, "sc_bidder2@example.com", "SecurePass123!", true)
+	thirdID := createTestUser(t, db, "sc_bidder3@example.com", "SecurePass123!", true)
+	for userID, amount := range map[int64]float64{thirdID: 1100.00, secondID: 1200.00, winnerID: 1300.00} {
+		if _, err := db.Exec(ctx, `INSERT INTO bids (auction_id, user_id, amount, bidder_name_snapshot, bidder_city_id_snapshot, created_at) VALUES ($1, $2, $3, 'Bidder', 1, NOW())`, auctionID, userID, amount); err != nil {
+			t.Fatalf("failed to create bid: %v", err)
+		}
+	}
+
+	if _, err := policies.SetPolicy(ctx, auctionID, &auctionssvc.FailurePolicyDTO{Action: "second_chance"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// الفائز غير المسدد لا يُعرض عليه، والعرض يذهب للمزايد التالي بمبلغ آخر مزايدة له (مرة واحدة فقط)
+	for i := 0; i < 2; i++ {
+		if err := policies.HandleFailedAuction(ctx, auctionID, auctionssvc.FailureReasonWinnerUnpaid); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	offers, err := policies.ListUserOffers(ctx, secondID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(offers) != 1 || offers[0].Amount != 1200.00 || offers[0].Status != auctionssvc.SecondChanceOfferPending {
+		t.Fatalf("Expected one pending offer of 1200 for the runner-up, got %+v", offers)
+	}
+
+	// لا يمكن لمستخدم آخر رفض العرض
+	if err := policies.DeclineOffer(ctx, offers[0].ID, thirdID); err == nil {
+		t.Errorf("Expected error when declining another user's offer")
+	}
+
+	// الرفض ينقل العرض إلى المزايد الثالث
+	if err := policies.DeclineOffer(ctx, offers[0].ID, secondID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	offers, _ = policies.ListUserOffers(ctx, thirdID)
+	if len(offers) != 1 || offers[0].Amount != 1100.00 {
+		t.Fatalf("Expected offer of 1100 for the third bidder, got %+v", offers)
+	}
+
+	// انتهاء المهلة دون مرشحين آخرين يعيد المنتج للبيع
+	_, _ = db.Exec(ctx, `UPDATE second_chance_offers SET expires_at = NOW() - INTERVAL '1 minute' WHERE id=$1`, offers[0].ID)
+	if err := policies.ExpireOffers(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := policies.AcceptOffer(ctx, offers[0].ID, thirdID); err == nil {
+		t.Errorf("Expected error when accepting an expired offer")
+	}
+	var productStatus string
+	_ = db.QueryRow(ctx, `SELECT p.status FROM products p JOIN auctions a ON a.product_id = p.id WHERE a.id=$1`, auctionID).Scan(&productStatus)
+	if productStatus != "available" {
+		t.Errorf("Expected product to be available after offers are exhausted, got %s", productStatus)
+	}
+	_, _ = db.Exec(ctx, `DELETE FROM notifications WHERE user_id IN ($1, $2, $3)`, winnerID, secondID, thirdID)
+}
+
 // TestCancelAuction اختبار إلغاء مزاد
 func TestCancelAuction(t *testing.T) {
 	ctx := context.Background()
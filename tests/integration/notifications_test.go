@@ -10,6 +10,7 @@ import (
 
 	authlib "encore.dev/beta/auth"
 
+	"encore.app/pkg/outbox"
 	"encore.app/pkg/templates"
 	authsvc "encore.app/svc/auth"
 	notifs "encore.app/svc/notifications"
@@ -94,3 +95,122 @@ func TestNotifications_Templates_And_TestEmail(t *testing.T) {
 		t.Fatalf("expected at least one internal notification")
 	}
 }
+
+func TestNotifications_Outbox_AtLeastOnceWithDedupe(t *testing.T) {
+	adminID := createAdminUserNotifications(t)
+	ctx := context.Background()
+	key := fmt.Sprintf("test:outbox:%d", time.Now().UnixNano())
+	defer func() {
+		_, _ = testDB.Exec(ctx, `DELETE FROM outbox_events WHERE dedupe_key LIKE $1`, key+"%")
+		_, _ = testDB.Exec(ctx, `DELETE FROM notifications WHERE user_id=$1`, adminID)
+	}()
+
+	// A rolled-back transaction leaves nothing behind
+	tx, err := testDB.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := outbox.Add(ctx, tx, outbox.Internal(adminID, "test_internal", map[string]any{"msg": "rolled back"}, key+":rollback")); err != nil {
+		t.Fatalf("outbox add: %v", err)
+	}
+	_ = tx.Rollback()
+
+	// The same logical event written twice is stored once
+	msg := outbox.Internal(adminID, "test_internal", map[string]any{"msg": "hello"}, key)
+	for i := 0; i < 2; i++ {
+		if err := outbox.Add(ctx, testDB, msg); err != nil {
+			t.Fatalf("outbox add: %v", err)
+		}
+	}
+	var rows int
+	_ = testDB.QueryRow(ctx, `SELECT COUNT(*) FROM outbox_events WHERE dedupe_key LIKE $1`, key+"%").Scan(&rows)
+	if rows != 1 {
+		t.Fatalf("expected 1 outbox row, got %d", rows)
+	}
+
+	if _, err := notifs.DispatchOutbox(ctx); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	// Simulate a crash after delivery but before the ack: the row is redelivered but not duplicated
+	_, _ = testDB.Exec(ctx, `UPDATE outbox_events SET status='pending', next_attempt_at=NOW() WHERE dedupe_key=$1`, key)
+	if _, err := notifs.DispatchOutbox(ctx); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+
+	var status string
+	_ = testDB.QueryRow(ctx, `SELECT status FROM outbox_events WHERE dedupe_key=$1`, key).Scan(&status)
+	if status != "delivered" {
+		t.Errorf("expected outbox row delivered, got %s", status)
+	}
+	var delivered int
+	_ = testDB.QueryRow(ctx, `SELECT COUNT(*) FROM notifications WHERE user_id=$1 AND payload->>'outbox_key' LIKE $2`, adminID, key+"%").Scan(&delivered)
+	if delivered != 1 {
+		t.Errorf("expected exactly 1 delivered notification, got %d", delivered)
+	}
+}
+
+func TestNotifications_Preferences(t *testing.T) {
+	userID := createAdminUserNotifications(t)
+	ctx := adminCtx(t, userID)
+	svc := &notifs.Service{}
+	defer func() {
+		_, _ = testDB.Exec(context.Background(), `DELETE FROM notifications WHERE user_id=$1`, userID)
+	}()
+
+	prefs, err := svc.GetMyNotificationPreferences(ctx)
+	if err != nil {
+		t.Fatalf("get preferences: %v", err)
+	}
+	for _, c := range prefs.Categories {
+		if !c.Internal || !c.Email || c.SMS || c.Digest != "off" {
+			t.Fatalf("unexpected defaults for %s: %+v", c.Category, c)
+		}
+	}
+
+	// Mandatory categories cannot be muted
+	off := false
+	if _, err := svc.UpdateMyNotificationPreferences(ctx, &notifs.UpdatePreferencesRequest{
+		Preferences: []notifs.PreferenceUpdate{{Category: "account", Email: &off}},
+	}); err == nil {
+		t.Fatalf("expected error when muting a mandatory category")
+	}
+
+	// Mute bidding entirely and put watchlist emails in the daily digest
+	daily := "daily"
+	if _, err := svc.UpdateMyNotificationPreferences(ctx, &notifs.UpdatePreferencesRequest{
+		Preferences: []notifs.PreferenceUpdate{
+			{Category: "bidding", Internal: &off, Email: &off},
+			{Category: "watchlist", Digest: &daily},
+		},
+	}); err != nil {
+		t.Fatalf("update preferences: %v", err)
+	}
+
+	payload := map[string]any{"email": "pref@example.com", "name": "Pref", "product_title": "حمامة"}
+	if id, err := notifs.EnqueueEmail(ctx, userID, "bid_outbid", payload); err != nil || id != 0 {
+		t.Errorf("muted email: got id=%d err=%v, want 0,nil", id, err)
+	}
+	if id, err := notifs.EnqueueInternal(ctx, userID, "bid_outbid", payload); err != nil || id != 0 {
+		t.Errorf("muted inbox: got id=%d err=%v, want 0,nil", id, err)
+	}
+	if id, err := notifs.EnqueueEmail(ctx, userID, "password_reset", payload); err != nil || id == 0 {
+		t.Errorf("mandatory email: got id=%d err=%v, want a queued notification", id, err)
+	}
+	if _, err := notifs.EnqueueEmail(ctx, userID, "auction_watch_ended", payload); err != nil {
+		t.Fatalf("digest email: %v", err)
+	}
+	var parked int
+	_ = testDB.QueryRow(ctx, `SELECT COUNT(*) FROM notification_digest_items WHERE user_id=$1 AND digested_at IS NULL`, userID).Scan(&parked)
+	if parked != 1 {
+		t.Fatalf("expected 1 parked digest item, got %d", parked)
+	}
+
+	if _, err := notifs.SendNotificationDigests(ctx); err != nil {
+		t.Fatalf("send digests: %v", err)
+	}
+	var queued int
+	_ = testDB.QueryRow(ctx, `SELECT COUNT(*) FROM outbox_events WHERE user_id=$1 AND target='notification_digest'`, userID).Scan(&queued)
+	if queued != 1 {
+		t.Errorf("expected one digest in the outbox, got %d", queued)
+	}
+}
@@ -110,11 +110,60 @@ func TestRefundPayment(t *testing.T) {
 	}
 }
 
+// TestRefundLedger: تسجيل استرداد بعناصر وإشعار دائن فريد مرتبط برقم الفاتورة
+func TestRefundLedger(t *testing.T) {
+	ctx := context.Background()
+	db := testDB
+
+	cleanupOrderTestData(t, db)
+	defer cleanupOrderTestData(t, db)
+
+	_, winnerID, productID := createCompletedAuctionForOrders(t, db)
+	orderID, _, amount := createTestOrderForOrders(t, db, winnerID, productID)
+	invoiceID := createInvoiceForOrder(t, db, orderID)
+	paymentID := createPaymentForInvoice(t, db, invoiceID, amount)
+
+	var invoiceNumber string
+	var orderItemID int64
+	if err := db.QueryRow(ctx, `SELECT number FROM invoices WHERE id=$1`, invoiceID).Scan(&invoiceNumber); err != nil {
+		t.Fatalf("failed to read invoice number: %v", err)
+	}
+	if err := db.QueryRow(ctx, `SELECT id FROM order_items WHERE order_id=$1`, orderID).Scan(&orderItemID); err != nil {
+		t.Fatalf("failed to read order item: %v", err)
+	}
+
+	creditNote := invoiceNumber + "-CN-01"
+	var refundID int64
+	if err := db.QueryRow(ctx, `
+		INSERT INTO refunds (payment_id, invoice_id, amount, reason, gateway, gateway_refund_id, status, credit_note_number)
+		VALUES ($1, $2, $3, 'damaged in transit', 'fake', 'rf_1', 'succeeded', $4) RETURNING id
+	`, paymentID, invoiceID, amount/2, creditNote).Scan(&refundID); err != nil {
+		t.Fatalf("failed to insert refund: %v", err)
+	}
+	if _, err := db.Exec(ctx, `INSERT INTO refund_items (refund_id, order_item_id, qty, amount) VALUES ($1, $2, 1, $3)`, refundID, orderItemID, amount/2); err != nil {
+		t.Fatalf("failed to insert refund item: %v", err)
+	}
+
+	// رقم إشعار الدائن فريد
+	if _, err := db.Exec(ctx, `
+		INSERT INTO refunds (payment_id, invoice_id, amount, gateway, status, credit_note_number)
+		VALUES ($1, $2, 1, 'fake', 'succeeded', $3)
+	`, paymentID, invoiceID, creditNote); err == nil {
+		t.Errorf("expected duplicate credit note number to be rejected")
+	}
+
+	var items int
+	if err := db.QueryRow(ctx, `SELECT COUNT(*) FROM refund_items ri JOIN refunds r ON r.id=ri.refund_id WHERE r.invoice_id=$1`, invoiceID).Scan(&items); err != nil || items != 1 {
+		t.Errorf("expected 1 refund item, got %d err=%v", items, err)
+	}
+}
+
 // Helpers (kept in-file)
 
 func cleanupOrderTestData(t *testing.T, db *sqldb.Database) {
 	ctx := context.Background()
 	queries := []string{
+		"DELETE FROM refunds",
 		"DELETE FROM payments",
 		"DELETE FROM invoices",
 		"DELETE FROM order_items",